@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"inkwell/internal/config"
+)
+
+// runConfig shows or checks the configuration
+func runConfig(args []string) error {
+	usage := "Usage: inkwell config show [--json] [inkwell options]\n" +
+		"       inkwell config validate [file]"
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+
+	switch args[0] {
+	case "show":
+		return showConfig(args[1:])
+	case "validate":
+		return validateConfig(args[1:])
+	default:
+		return errors.New(usage)
+	}
+}
+
+// showConfig prints every option's effective value and where it came from.
+// Options given after show are applied as they would be on the command line.
+func showConfig(args []string) error {
+	asJSON := false
+	if len(args) > 0 && (args[0] == "--json" || args[0] == "-json") {
+		asJSON = true
+		args = args[1:]
+	}
+
+	options, path, err := config.Effective(args)
+	if err != nil {
+		return err
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(map[string]interface{}{
+			"configFile": path,
+			"options":    options,
+		})
+	}
+
+	if path == "" {
+		fmt.Println("Configuration file: none")
+	} else {
+		fmt.Printf("Configuration file: %s\n", path)
+	}
+	fmt.Println()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "OPTION\tVALUE\tSOURCE")
+	for _, opt := range options {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", opt.Name, opt.Value, opt.Source)
+	}
+	return w.Flush()
+}
+
+// validateConfig checks a configuration file, and the INKWELL_* variables
+// that would be applied with it
+func validateConfig(args []string) error {
+	fs := flag.NewFlagSet("config validate", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: inkwell config validate [file]")
+	}
+	fs.Parse(args)
+
+	path, err := config.FindConfigFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if path == "" {
+		def, _ := config.DefaultConfigPath()
+		fmt.Printf("No configuration file at %s; defaults will be used.\n", def)
+		return nil
+	}
+
+	if err := config.ValidateFile(path); err != nil {
+		return err
+	}
+	if _, _, err := config.Effective([]string{"--config", path}); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s is valid.\n", path)
+	return nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"path/filepath"
+
+	"inkwell/internal/config"
+	"inkwell/internal/doctor"
+	"inkwell/internal/git"
+)
+
+// runDoctor checks the installation and prints fixes for any problems
+func runDoctor(args []string) error {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	configPath := fs.String("config", "", "Configuration file to check (default: ~/.config/inkwell/config.toml)")
+	host := fs.String("host", "", "Interface Inkwell will listen on")
+	port := fs.Int("port", 0, "Check that this port is free")
+	noGit := fs.Bool("no-git", false, "Skip the git, SSH and repository checks")
+	reposDir := fs.String("repos-dir", "", "Check the repositories cloned into this directory (default: ~/.inkwell/repos)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: inkwell doctor [--port N] [--config FILE] [--repos-dir DIR] [--no-git] [directory]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	rootDir := "."
+	if fs.NArg() > 0 {
+		rootDir = fs.Arg(0)
+	}
+	rootDir, err := filepath.Abs(rootDir)
+	if err != nil {
+		return err
+	}
+
+	cfgFile, err := config.FindConfigFile(*configPath)
+	if err != nil {
+		return err
+	}
+
+	opts := doctor.Options{
+		RootDir:    rootDir,
+		ConfigFile: cfgFile,
+		Host:       *host,
+		Port:       *port,
+		NoGit:      *noGit,
+	}
+	if *reposDir != "" {
+		opts.ReposDir = *reposDir
+	} else if dir, err := git.DefaultReposDir(); err == nil {
+		opts.ReposDir = dir
+	}
+
+	checks := doctor.Run(context.Background(), opts)
+	for _, c := range checks {
+		fmt.Printf("[%-4s] %s: %s\n", c.Status, c.Name, c.Message)
+		if c.Fix != "" {
+			fmt.Printf("       fix: %s\n", c.Fix)
+		}
+	}
+
+	if doctor.Failed(checks) {
+		return errors.New("some checks failed")
+	}
+	return nil
+}
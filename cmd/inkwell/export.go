@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+
+	"inkwell/internal/cite"
+	"inkwell/internal/config"
+	"inkwell/internal/export"
+	"inkwell/internal/filesystem"
+	"inkwell/internal/i18n"
+	"inkwell/internal/render"
+	"inkwell/internal/settings"
+)
+
+// runExport renders notes to disk without starting the server
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	format := fs.String("format", "html", "Output format: html, pdf or site")
+	out := fs.String("out", "", "Directory to write to (required)")
+	noMath := fs.Bool("no-math", false, "Disable $...$ math rendering")
+	chrome := fs.String("chrome", "", "Chrome, Chromium or Edge binary used for PDF export (default: found on PATH)")
+	plantumlServer := fs.String("plantuml-server", "", "PlantUML server URL for diagram rendering")
+	plantumlJar := fs.String("plantuml-jar", "", "Path to a local plantuml.jar (requires java)")
+	toc := fs.Bool("toc", false, "Put a table of contents at the top of each page")
+	anchors := fs.Bool("anchors", false, "Give each heading a link to itself")
+	slug := fs.String("slug", "", "How heading IDs are made: goldmark, github or pandoc (default: the vault's, else goldmark)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: inkwell export --format html|pdf|site --out DIR [note or folder]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	f, err := export.ParseFormat(*format)
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		return errors.New("--out is required")
+	}
+
+	target := "."
+	if fs.NArg() > 0 {
+		target = fs.Arg(0)
+	}
+	cfg, err := config.New(target)
+	if err != nil {
+		return err
+	}
+
+	vault, err := config.LoadVault(cfg.RootDir)
+	if err != nil {
+		return err
+	}
+
+	math := !*noMath
+	if vault.Export.Math != nil {
+		math = *vault.Export.Math
+	}
+	opts := render.Options{Math: math}
+	// Render with the same markdown flavor as the preview
+	if saved, err := settings.New(); err == nil {
+		current := saved.Get()
+		opts.Flavor, opts.Extensions = current.MarkdownFlavor, current.MarkdownExtensions
+	}
+	renderer := render.New(opts)
+	if *plantumlServer != "" || *plantumlJar != "" {
+		p, err := render.NewPlantUML(*plantumlServer, *plantumlJar, render.PlantUMLCacheDir(cfg.RootDir))
+		if err != nil {
+			return err
+		}
+		renderer.SetPlantUML(p)
+	}
+
+	e := &export.Exporter{
+		FS: &filesystem.FileSystem{
+			RootDir:    cfg.RootDir,
+			Ignore:     vault.Ignore,
+			Extensions: vault.Extensions,
+			AssetsDir:  vault.AssetsDir,
+		},
+		Renderer:   renderer,
+		Chrome:     *chrome,
+		Locale:     i18n.FromEnv(),
+		Navigation: vault.Export.Navigation(),
+	}
+	e.Navigation.TOC = e.Navigation.TOC || *toc
+	e.Navigation.Anchors = e.Navigation.Anchors || *anchors
+	if *slug != "" {
+		if !render.ValidSlug(*slug) {
+			return fmt.Errorf("unknown --slug %q (use %s)", *slug, strings.Join(render.Slugs, ", "))
+		}
+		e.Navigation.Slug = *slug
+	}
+
+	// Citations resolve against the vault's bibliography, if it has one
+	if bibPath, err := cite.Find(e.FS, vault.Export.Bibliography); err == nil {
+		if e.Bibliography, err = cite.Load(e.FS, bibPath); err != nil {
+			return err
+		}
+	} else if !errors.Is(err, cite.ErrNoBibliography) {
+		return err
+	}
+
+	path := cfg.InitialFile
+	if path == "" {
+		path = "."
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	written, err := e.Export(ctx, f, path, *out)
+	if err != nil {
+		return err
+	}
+	for _, name := range written {
+		fmt.Println(filepath.Join(*out, filepath.FromSlash(name)))
+	}
+	return nil
+}
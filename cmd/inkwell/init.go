@@ -0,0 +1,42 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"inkwell/internal/starter"
+)
+
+// runInit creates a starter vault with welcome notes and templates
+func runInit(args []string) error {
+	fs := flag.NewFlagSet("init", flag.ExitOnError)
+	useGit := fs.Bool("git", false, "Make the vault a git repository with the starter files committed")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: inkwell init [--git] [directory]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	dir := "."
+	if fs.NArg() > 0 {
+		dir = fs.Arg(0)
+	}
+
+	result, err := starter.Create(dir, starter.Options{Git: *useGit})
+	if result != nil {
+		for _, name := range result.Created {
+			fmt.Printf("created %s\n", name)
+		}
+		for _, name := range result.Skipped {
+			fmt.Printf("kept %s (already exists)\n", name)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if result.Commit != "" {
+		fmt.Printf("Committed the starter files as %s\n", result.Commit[:7])
+	}
+	fmt.Printf("Open the vault with: inkwell %s\n", result.Path)
+	return nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"inkwell/internal/config"
+	"inkwell/internal/instances"
+	"inkwell/internal/version"
+)
+
+// registerInstance records this server in the instance registry and
+// returns a function that removes it again
+func registerInstance(cfg *config.Config) func() {
+	registry, err := instances.New()
+	if err != nil {
+		slog.Warn("Failed to open instance registry", "error", err)
+		return func() {}
+	}
+
+	pid := os.Getpid()
+	err = registry.Register(instances.Instance{
+		PID:     pid,
+		Port:    cfg.Port,
+		Root:    cfg.RootDir,
+		URL:     fmt.Sprintf("http://localhost:%d", cfg.Port),
+		Version: version.Get().Version,
+		Started: time.Now(),
+	})
+	if err != nil {
+		slog.Warn("Failed to register instance", "error", err)
+		return func() {}
+	}
+
+	return func() {
+		if err := registry.Unregister(pid); err != nil {
+			slog.Warn("Failed to unregister instance", "error", err)
+		}
+	}
+}
+
+// runList prints the running Inkwell servers
+func runList(args []string) error {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: inkwell list")
+	}
+	fs.Parse(args)
+
+	registry, err := instances.New()
+	if err != nil {
+		return err
+	}
+	list, err := registry.List()
+	if err != nil {
+		return err
+	}
+
+	if len(list) == 0 {
+		fmt.Println("No Inkwell servers are running.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PID\tURL\tVAULT\tSTARTED")
+	for _, inst := range list {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", inst.PID, inst.URL, inst.Root, inst.Started.Format(time.DateTime))
+	}
+	return w.Flush()
+}
+
+// runStop shuts down the server serving a vault
+func runStop(args []string) error {
+	fs := flag.NewFlagSet("stop", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: inkwell stop <vault path, folder name, port or PID>")
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("name the vault to stop")
+	}
+
+	registry, err := instances.New()
+	if err != nil {
+		return err
+	}
+	matches, err := registry.Find(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	switch len(matches) {
+	case 0:
+		return fmt.Errorf("no running Inkwell server matches %q", fs.Arg(0))
+	case 1:
+		inst := matches[0]
+		if err := instances.Stop(inst); err != nil {
+			return err
+		}
+		fmt.Printf("Stopped %s (%s)\n", inst.Root, inst.URL)
+		return nil
+	default:
+		var urls []string
+		for _, inst := range matches {
+			urls = append(urls, fmt.Sprintf("  %d  %s  %s", inst.PID, inst.URL, inst.Root))
+		}
+		return fmt.Errorf("%q matches several servers; give a port or PID:\n%s", fs.Arg(0), strings.Join(urls, "\n"))
+	}
+}
@@ -4,7 +4,7 @@ import (
 	"context"
 	"embed"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
@@ -12,7 +12,9 @@ import (
 	"time"
 
 	"inkwell/internal/config"
+	"inkwell/internal/logging"
 	"inkwell/internal/server"
+	"inkwell/internal/version"
 
 	"github.com/pkg/browser"
 )
@@ -20,7 +22,32 @@ import (
 //go:embed all:web
 var webContent embed.FS
 
+// commands are subcommands given as the first argument
+var commands = map[string]func(args []string) error{
+	"config":         runConfig,
+	"doctor":         runDoctor,
+	"export":         runExport,
+	"export-profile": runExportProfile,
+	"import-profile": runImportProfile,
+	"init":           runInit,
+	"list":           runList,
+	"migrate-assets": runMigrateAssets,
+	"service":        runService,
+	"stop":           runStop,
+	"update":         runUpdate,
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		if run, ok := commands[os.Args[1]]; ok {
+			if err := run(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
 	// Parse configuration
 	cfg, err := config.Parse()
 	if err != nil {
@@ -28,6 +55,25 @@ func main() {
 		os.Exit(1)
 	}
 
+	if cfg.ShowVersion {
+		fmt.Println(version.Get())
+		return
+	}
+
+	closeLog, err := logging.Setup(cfg.LogLevel, cfg.LogFormat, cfg.LogFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer closeLog()
+
+	if cfg.EncryptVault {
+		if cfg.Passphrase, err = readPassphrase(cfg); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
 	// Create server with embedded web content
 	srv, err := server.New(cfg, webContent)
 	if err != nil {
@@ -35,6 +81,9 @@ func main() {
 		os.Exit(1)
 	}
 
+	unregister := registerInstance(cfg)
+	defer unregister()
+
 	// Channel to listen for errors from server
 	serverErrors := make(chan error, 1)
 
@@ -48,13 +97,13 @@ func main() {
 		go func() {
 			time.Sleep(200 * time.Millisecond)
 			url := cfg.URL()
-			fmt.Printf("\n  Inkwell is running at: %s\n\n", url)
+			announce(cfg)
 			if err := browser.OpenURL(url); err != nil {
-				log.Printf("Failed to open browser: %v", err)
+				slog.Warn("Failed to open browser", "error", err)
 			}
 		}()
 	} else {
-		fmt.Printf("\n  Inkwell is running at: %s\n\n", cfg.URL())
+		announce(cfg)
 	}
 
 	// Channel to listen for interrupt signal
@@ -65,20 +114,37 @@ func main() {
 	select {
 	case err := <-serverErrors:
 		if err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Server error: %v", err)
+			slog.Error("Server error", "error", err)
+			unregister()
+			closeLog()
+			os.Exit(1)
 		}
 	case sig := <-shutdown:
-		log.Printf("Received signal %v, shutting down...", sig)
+		slog.Info("Shutting down", "signal", sig.String())
 
 		// Give outstanding requests 5 seconds to complete
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 
 		if err := srv.Shutdown(ctx); err != nil {
-			log.Printf("Graceful shutdown failed: %v", err)
+			slog.Error("Graceful shutdown failed", "error", err)
+			unregister()
+			closeLog()
 			os.Exit(1)
 		}
 	}
 
-	fmt.Println("Inkwell stopped.")
+	if !cfg.PrintURL {
+		fmt.Println("Inkwell stopped.")
+	}
+}
+
+// announce prints the server URL, alone on one line with --print-url so
+// wrapping scripts can read it
+func announce(cfg *config.Config) {
+	if cfg.PrintURL {
+		fmt.Println(cfg.URL())
+		return
+	}
+	fmt.Printf("\n  Inkwell is running at: %s\n\n", cfg.URL())
 }
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"inkwell/internal/config"
+	"inkwell/internal/filesystem"
+	"inkwell/internal/git"
+)
+
+// runMigrateAssets moves a vault's images into another layout and rewrites
+// the notes that use them
+func runMigrateAssets(args []string) error {
+	fs := flag.NewFlagSet("migrate-assets", flag.ExitOnError)
+	layout := fs.String("layout", "", "Layout to move images into: flat or per-note (default: the vault's assetLayout)")
+	dryRun := fs.Bool("dry-run", false, "Print what would change without changing anything")
+	stage := fs.Bool("stage", false, "Stage the changes in git, ready to commit")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: inkwell migrate-assets [--layout flat|per-note] [--dry-run] [--stage] [vault]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	rootDir := "."
+	if fs.NArg() > 0 {
+		rootDir = fs.Arg(0)
+	}
+	rootDir, err := filepath.Abs(rootDir)
+	if err != nil {
+		return err
+	}
+	vault, err := config.LoadVault(rootDir)
+	if err != nil {
+		return err
+	}
+	if *layout == "" {
+		*layout = vault.AssetLayout
+	}
+
+	files := &filesystem.FileSystem{
+		RootDir:     rootDir,
+		Ignore:      vault.Ignore,
+		Extensions:  vault.Extensions,
+		AssetsDir:   vault.AssetsDir,
+		AssetLayout: vault.AssetLayout,
+	}
+	migration, err := files.MigrateAssets(*layout, *dryRun)
+	if err != nil {
+		return err
+	}
+
+	for _, move := range migration.Moved {
+		fmt.Printf("%s -> %s\n", move.From, move.To)
+	}
+	for _, note := range migration.Notes {
+		fmt.Printf("rewrote %s\n", note)
+	}
+	for _, asset := range migration.Shared {
+		fmt.Printf("kept %s (used by several notes)\n", asset)
+	}
+	if len(migration.Changed) == 0 {
+		fmt.Printf("Assets are already %s\n", migration.Layout)
+		return nil
+	}
+	if *dryRun || !*stage {
+		return nil
+	}
+
+	repo, err := git.Open(rootDir)
+	if err != nil {
+		return err
+	}
+	if repo == nil {
+		return errors.New("--stage: vault is not in a git repository")
+	}
+	paths := make([]string, 0, len(migration.Changed))
+	for _, p := range migration.Changed {
+		rel, err := filepath.Rel(repo.Path(), filepath.Join(rootDir, filepath.FromSlash(p)))
+		if err == nil && !strings.HasPrefix(rel, "..") {
+			paths = append(paths, rel)
+		}
+	}
+	return repo.StageChanges(paths)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"golang.org/x/term"
+
+	"inkwell/internal/config"
+	"inkwell/internal/encryption"
+)
+
+// passphraseEnv holds the passphrase for --encrypt-vault when set, for
+// scripts and services that can't answer a prompt
+const passphraseEnv = "INKWELL_PASSPHRASE"
+
+// readPassphrase finds the passphrase for an encrypted vault: from the
+// environment, then the OS keychain, then by asking on the terminal
+func readPassphrase(cfg *config.Config) (string, error) {
+	if passphrase := os.Getenv(passphraseEnv); passphrase != "" {
+		return passphrase, nil
+	}
+
+	passphrase, err := encryption.KeychainPassphrase(cfg.RootDir)
+	if err == nil {
+		return passphrase, nil
+	}
+	if !errors.Is(err, encryption.ErrNoKeychain) {
+		slog.Debug("No passphrase in keychain", "error", err)
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("--encrypt-vault needs a passphrase: set %s or store it in the keychain", passphraseEnv)
+	}
+
+	newVault := !encryption.Configured(cfg.RootDir)
+	if newVault {
+		fmt.Fprintln(os.Stderr, "Choose a passphrase to encrypt this vault. It cannot be recovered if lost.")
+	}
+	passphrase, err = prompt(fd, "Vault passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if newVault {
+		again, err := prompt(fd, "Repeat passphrase: ")
+		if err != nil {
+			return "", err
+		}
+		if again != passphrase {
+			return "", errors.New("passphrases do not match")
+		}
+	}
+	return passphrase, nil
+}
+
+// prompt reads a line from the terminal without echoing it
+func prompt(fd int, label string) (string, error) {
+	fmt.Fprint(os.Stderr, label)
+	line, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to read passphrase: %w", err)
+	}
+	return string(line), nil
+}
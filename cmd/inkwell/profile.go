@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"inkwell/internal/instances"
+	"inkwell/internal/profile"
+)
+
+// runExportProfile writes settings, recents, favorites, users and API keys
+// to a zip file for import-profile on another machine
+func runExportProfile(args []string) error {
+	fs := flag.NewFlagSet("export-profile", flag.ExitOnError)
+	noSecrets := fs.Bool("no-secrets", false, "Leave out users and API keys")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: inkwell export-profile [--no-secrets] FILE.zip")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("a file to write is required")
+	}
+
+	dir, err := profile.Dir()
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	names, err := profile.Export(&buf, dir, !*noSecrets)
+	if err != nil {
+		return err
+	}
+	// Users and API keys are only hashed, but still private
+	if err := os.WriteFile(fs.Arg(0), buf.Bytes(), 0600); err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		fmt.Println(name)
+	}
+	fmt.Printf("Exported %d files to %s.\n", len(names), fs.Arg(0))
+	return nil
+}
+
+// runImportProfile restores a profile written by export-profile
+func runImportProfile(args []string) error {
+	fs := flag.NewFlagSet("import-profile", flag.ExitOnError)
+	force := fs.Bool("force", false, "Replace settings, recents and other files already here")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: inkwell import-profile [--force] FILE.zip")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("a file to import is required")
+	}
+
+	// A running server would write its own recents and settings back
+	registry, err := instances.New()
+	if err != nil {
+		return err
+	}
+	if running, err := registry.List(); err == nil && len(running) > 0 {
+		return fmt.Errorf("%d Inkwell servers are running; stop them first (see inkwell list)", len(running))
+	}
+
+	data, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	dir, err := profile.Dir()
+	if err != nil {
+		return err
+	}
+	names, err := profile.Import(bytes.NewReader(data), int64(len(data)), dir, *force)
+	if errors.Is(err, profile.ErrExists) {
+		return fmt.Errorf("%w; pass --force to replace them", err)
+	} else if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		fmt.Println(name)
+	}
+	fmt.Printf("Imported %d files into %s.\n", len(names), dir)
+	return nil
+}
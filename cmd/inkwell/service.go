@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"inkwell/internal/service"
+)
+
+// runService installs or removes a background service for a vault
+func runService(args []string) error {
+	usage := "Usage: inkwell service install [--name NAME] [--print] [--no-start] <vault> [-- inkwell options]\n" +
+		"       inkwell service uninstall <name or vault>"
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+
+	switch args[0] {
+	case "install":
+		return installService(args[1:])
+	case "uninstall":
+		fs := flag.NewFlagSet("service uninstall", flag.ExitOnError)
+		fs.Parse(args[1:])
+		if fs.NArg() != 1 {
+			return errors.New(usage)
+		}
+		path, err := service.Uninstall(context.Background(), runtime.GOOS, service.Name(fs.Arg(0)))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Removed %s\n", path)
+		return nil
+	default:
+		return errors.New(usage)
+	}
+}
+
+// installService writes and starts the service definition
+func installService(args []string) error {
+	fs := flag.NewFlagSet("service install", flag.ExitOnError)
+	name := fs.String("name", "", "Service name (default: the vault's folder name)")
+	printOnly := fs.Bool("print", false, "Print the service definition instead of installing it")
+	noStart := fs.Bool("no-start", false, "Install without enabling or starting the service")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: inkwell service install [options] <vault> [-- inkwell options]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("name the vault to serve")
+	}
+	vault, err := filepath.Abs(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if info, err := os.Stat(vault); err != nil || !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", vault)
+	}
+
+	// Options after the vault (following "--") are passed to the server
+	extra := fs.Args()[1:]
+	if len(extra) > 0 && extra[0] == "--" {
+		extra = extra[1:]
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return err
+	}
+
+	spec := service.Spec{
+		Name:       *name,
+		Executable: exe,
+		Vault:      vault,
+		Args:       extra,
+	}
+	if spec.Name == "" {
+		spec.Name = service.Name(vault)
+	}
+
+	if *printOnly {
+		def, err := service.Definition(runtime.GOOS, spec)
+		if err != nil {
+			return err
+		}
+		fmt.Print(def)
+		return nil
+	}
+
+	path, err := service.Install(context.Background(), runtime.GOOS, spec, !*noStart)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Installed %s\n", path)
+	if !*noStart {
+		fmt.Printf("Service %q is running and will start at login.\n", spec.Name)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"inkwell/internal/update"
+	"inkwell/internal/version"
+)
+
+// runUpdate replaces this binary with the latest release
+func runUpdate(args []string) error {
+	fs := flag.NewFlagSet("update", flag.ExitOnError)
+	checkOnly := fs.Bool("check-only", false, "Only report whether a newer release is available")
+	force := fs.Bool("force", false, "Install the latest release even if this build is as new")
+	insecure := fs.Bool("insecure", false, "Install a release whose signature can't be verified")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: inkwell update [--check-only] [--force] [--insecure]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	ctx := context.Background()
+	u := update.New()
+	u.Insecure = *insecure
+
+	current := version.Get().Version
+	rel, err := u.Latest(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !update.Newer(rel.Tag, current) && !*force {
+		fmt.Printf("Inkwell %s is up to date.\n", current)
+		return nil
+	}
+
+	fmt.Printf("Inkwell %s is available (you have %s).\n", rel.Tag, current)
+	if rel.URL != "" {
+		fmt.Printf("Release notes: %s\n", rel.URL)
+	}
+	if *checkOnly {
+		return nil
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("cannot locate the running binary: %w", err)
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return fmt.Errorf("cannot locate the running binary: %w", err)
+	}
+
+	fmt.Printf("Downloading %s...\n", rel.Tag)
+	data, err := u.Download(ctx, rel)
+	if errors.Is(err, update.ErrUnsigned) {
+		return fmt.Errorf("%w; pass --insecure to install it anyway", err)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := update.Replace(exe, data); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", exe, err)
+	}
+
+	fmt.Printf("Updated %s to %s.\n", exe, rel.Tag)
+	return nil
+}
@@ -11,6 +11,8 @@ interface ApiResponse<T> {
 interface FileData {
   path: string;
   content: string;
+  encrypted?: boolean;
+  version?: string;
 }
 
 interface FileNode {
@@ -18,16 +20,289 @@ interface FileNode {
   path: string;
   isDir: boolean;
   children?: FileNode[];
+  size?: number;
+  title?: string;
+  conflictOf?: string;
+  index?: string; // Landing page of a folder
+  truncated?: boolean; // Children left out; load the whole tree for them
+}
+
+// Everything the app loads before its first paint, in one request
+interface Bootstrap {
+  config: ConfigData;
+  tree?: FileNode; // First level only
+  git?: GitStatusResponse;
+  start: StartPage;
+  settings?: Settings;
+  session?: Session;
+  errors?: Record<string, string>; // Parts that failed to load
 }
 
 interface ConfigData {
   theme: string;
   rootDir: string;
   initialFile: string;
+  initialFiles: string[];
+  activeFile: string;
+  render?: RenderOptions;
+  git?: boolean;
+  ai?: boolean; // A language model is configured for writing help
+  configFile?: string;
+  options?: ConfigOption[];
+}
+
+interface ConfigOption {
+  name: string;
+  value: string;
+  source: 'default' | 'file' | 'env' | 'flag';
+  env?: string;
+  usage: string;
+}
+
+interface Session {
+  files: string[];
+  active?: string;
+  expanded?: string[];
+  scroll?: Record<string, number>;
+}
+
+interface IndexStatus {
+  state: 'idle' | 'indexing' | 'ready';
+  indexed: number;
+  total: number;
+  percent: number;
+  started?: string;
+  finished?: string;
+}
+
+interface SearchResult {
+  path: string;
+  title: string;
+  score: number;
+}
+
+interface IndexedNote {
+  path: string;
+  title: string;
+  tags?: string[];
+  links?: string[];
+  date?: string; // YYYY-MM-DD from front matter or the file name
+}
+
+interface CalendarMonth {
+  month: string; // YYYY-MM
+  days: { date: string; notes: IndexedNote[] }[]; // Only days with notes
+  months: string[]; // Every month with dated notes, newest first
+  streak: { current: number; longest: number; last?: string };
+}
+
+interface DuplicateGroup {
+  notes: IndexedNote[];
+  similarity: number; // 0-1, lowest between two notes grouped together
+  identical: boolean;
+}
+
+interface BibEntry {
+  key: string;
+  type: string;
+  title: string;
+  authors?: string[]; // "Family, Given"
+  year?: string;
+  container?: string; // Journal, book or publisher
+  volume?: string;
+  pages?: string;
+  doi?: string;
+  url?: string;
+}
+
+interface TagCount {
+  name: string;
+  count: number;
+}
+
+interface FixupIssue {
+  kind: 'missing-footnote' | 'unused-footnote' | 'duplicate-footnote' | 'missing-reference' | 'unused-reference' | 'duplicate-reference';
+  line: number;
+  label: string;
+  message: string;
+}
+
+interface Fixup {
+  name: string;
+  description: string;
+}
+
+interface FixupResult {
+  content: string;
+  changes: number;
+  saved: boolean;
+  issues: FixupIssue[]; // Left after the fix
+}
+
+interface Task {
+  path: string;
+  line: number; // 1-based, counting front matter
+  text: string;
+  done: boolean;
+  due?: string; // YYYY-MM-DD
+  tags?: string[];
+}
+
+interface TaskFilter {
+  done?: boolean;
+  tag?: string;
+  path?: string; // A folder or note
+  dueBefore?: string;
+  dueAfter?: string;
+}
+
+interface EncryptionStatus {
+  configured: boolean;
+  unlocked: boolean;
+}
+
+interface CredentialStoreStatus {
+  configured: boolean;
+  method?: 'keychain' | 'dpapi' | 'passphrase';
+  unlocked: boolean;
+  keychain: boolean; // Can be set up without a passphrase
+}
+
+// A saved git credential; the secret itself is never sent back
+interface StoredCredential {
+  id: string;
+  kind: 'token' | 'ssh-passphrase';
+  target: string; // Host or URL prefix for tokens, key path for passphrases
+  username?: string;
+  label?: string;
+  createdAt: string;
+  updatedAt: string;
+}
+
+interface CredentialInput {
+  kind: 'token' | 'ssh-passphrase';
+  target: string;
+  username?: string;
+  label?: string;
+  secret?: string; // Leave out when updating to keep the saved one
+}
+
+interface SyncConflict {
+  path: string;
+  original: string;
+  source: string;
+  modTime: string;
+  originalExists: boolean;
+}
+
+interface SyncConflictMerge extends SyncConflict {
+  merged: string;
+}
+
+interface PublishOptions {
+  branch?: string;
+  repo?: string;
+  folder?: string;
+  message?: string;
+}
+
+interface PublishProgress {
+  stage: 'rendering' | 'committing' | 'pushing';
+  current?: number;
+  total?: number;
+  message?: string;
+}
+
+interface PublishResult {
+  branch: string;
+  remote: string;
+  commit?: string;
+  files: number;
+  message: string;
+}
+
+// LockedError is thrown when a note is encrypted and the vault is locked
+class LockedError extends Error {}
+
+interface VersionInfo {
+  version: string;
+  commit?: string;
+  buildTime?: string;
+  goVersion: string;
+  platform: string;
+}
+
+interface DiagnosticCheck {
+  name: string;
+  status: 'ok' | 'warn' | 'fail';
+  message: string;
+  fix?: string;
+}
+
+interface Diagnostics {
+  checks: DiagnosticCheck[];
+  failed: boolean;
+}
+
+interface BackupStatus {
+  enabled: boolean;
+  dest?: string;
+  running: boolean;
+  lastBackup?: string;
+  lastFile?: string;
+  lastSize?: number;
+  backups: number;
+  error?: string;
+}
+
+interface Settings {
+  theme?: string;
+  fontSize: number;
+  autosaveInterval: number;
+  defaultExtension: string;
+  markdownFlavor: MarkdownFlavor;
+  markdownExtensions: MarkdownExtensions; // Used with the custom flavor
+}
+
+type MarkdownFlavor = 'github' | 'obsidian' | 'commonmark' | 'custom';
+
+interface MarkdownExtensions {
+  tables: boolean;
+  taskLists: boolean;
+  footnotes: boolean;
+  strikethrough: boolean;
+  linkify: boolean;
+  typographer: boolean; // Smart quotes, dashes and ellipses
+  hardWraps: boolean; // Line breaks within a paragraph are kept
+}
+
+interface RenderOptions {
+  math: boolean;
+  flavor: MarkdownFlavor;
+  extensions: MarkdownExtensions; // Active extensions of the flavor
 }
 
 interface ImageUploadResult {
   path: string;
+  url?: string;
+}
+
+interface PastedImage {
+  path: string;
+  url: string;
+  markdown: string; // ![alt](url), ready to insert
+}
+
+interface UploadResult {
+  name: string;
+  path?: string;
+  error?: string;
+}
+
+interface MultiUploadResult {
+  results: UploadResult[];
+  saved: number;
+  failed: number;
 }
 
 interface DirectoryEntry {
@@ -44,15 +319,46 @@ interface DirectoryListResult {
 
 interface FileMetadata {
   path: string;
-  size: number;
-  modifiedTime: string;
+  size: number; // Bytes; for a folder, of every file inside it
+  modifiedTime: string; // ISO-8601; for a folder, the latest change inside it
   isDir: boolean;
+  contents?: FolderContents; // Only for folders
+}
+
+interface FolderContents {
+  files: number;
+  notes: number;
+  folders: number;
 }
 
 interface RecentLocation {
   path: string;
   name: string;
   lastOpened: string;
+  pinned?: boolean;
+  lastFile?: string;
+  missing?: boolean;
+  exists?: boolean;
+  notes?: number;
+  git?: boolean;
+  branch?: string;
+  dirty?: boolean;
+  ahead?: number;
+  behind?: number;
+}
+
+interface RecentFile {
+  path: string;
+  name: string;
+  lastOpened: string;
+}
+
+interface StartPage {
+  current: string;
+  pinned: RecentLocation[];
+  recents: RecentLocation[];
+  files: RecentFile[];
+  favorites: RecentFile[];
 }
 
 // Git types
@@ -70,11 +376,43 @@ interface GitStatus {
   hasConflicts: boolean;
   isClean: boolean;
   remoteUrl?: string;
+  groups?: { dir: string; files: GitFileStatus[] }[]; // With group: 'dir'
+  filtered?: number; // Changed files the options left out
+}
+
+interface SyncStatus {
+  enabled: boolean; // Syncs run every --fetch-interval
+  mode: 'fetch' | 'pull' | 'push';
+  running: boolean;
+  lastSync?: string;
+  nextSync?: string;
+  branch?: string;
+  ahead: number;
+  behind: number;
+  pulled?: number; // Commits the last sync brought in
+  pushed?: boolean;
+  skipped?: string; // Why the last sync didn't pull or push
+  paused?: boolean; // The remote needs credentials; only a manual sync retries
+  error?: string;
+}
+
+interface StarterVault {
+  path: string;
+  created: string[];
+  skipped?: string[]; // Already there and left alone
+  commit?: string; // The first commit, when git was set up
+}
+
+interface GitStatusOptions {
+  markdown?: boolean; // Only notes
+  exclude?: ('ignored' | 'assets')[];
+  group?: 'dir';
 }
 
 interface GitStatusResponse {
   isRepo: boolean;
   status?: GitStatus;
+  operation?: { name: string; since: string }; // A push, commit or other change under way
 }
 
 interface GitCommit {
@@ -86,6 +424,14 @@ interface GitCommit {
   date: string;
 }
 
+interface HistoryPage {
+  commits: GitCommit[];
+  next?: string; // Cursor for the following page
+  hasMore: boolean;
+  total?: number; // Commits in the whole history, once counted
+  estimated?: boolean; // total was counted before the branch moved
+}
+
 interface GitBranch {
   name: string;
   isRemote: boolean;
@@ -93,6 +439,49 @@ interface GitBranch {
   upstream?: string;
 }
 
+interface GitTag {
+  name: string;
+  hash: string;
+  annotated: boolean;
+  message?: string;
+  tagger?: string;
+  date?: string;
+  subject?: string;
+}
+
+interface GitRemote {
+  name: string;
+  urls: string[]; // The first is fetched from; all are pushed to
+}
+
+interface GitAccount {
+  host: string;
+  provider: 'github' | 'gitlab';
+  scope?: string;
+  createdAt: string;
+  expiry?: string;
+}
+
+// A sign-in to GitHub or GitLab; the user enters userCode at verificationUri
+interface DeviceSignIn {
+  id: string;
+  provider: 'github' | 'gitlab';
+  host: string;
+  userCode: string;
+  verificationUri: string;
+  verificationUriComplete?: string;
+  expiresAt: string;
+  interval: number; // Seconds between status checks
+  state: 'pending' | 'complete' | 'denied' | 'expired' | 'cancelled' | 'failed';
+  error?: string;
+}
+
+interface PickResult {
+  commit?: GitCommit;
+  conflicts: string[];
+  message: string;
+}
+
 interface PushPullResult {
   success: boolean;
   message: string;
@@ -104,10 +493,17 @@ interface PullResult extends PushPullResult {
 }
 
 interface AuthOptions {
+  remote?: string; // Push, pull or fetch this remote instead of origin
   sshKeyPath?: string;
   sshPassphrase?: string;
   username?: string;
   password?: string;
+  remember?: boolean; // Save the password or passphrase once it works
+}
+
+interface PushOptions {
+  branch?: string; // Default: the current branch
+  force?: boolean;
 }
 
 class Api {
@@ -121,8 +517,16 @@ class Api {
       ...options,
     });
 
+    // Session expired or never signed in on an instance with accounts
+    if (response.status === 401) {
+      window.location.href = '/login';
+    }
+
     const data: ApiResponse<T> = await response.json();
 
+    if (response.status === 423) {
+      throw new LockedError(data.error || 'Note is encrypted');
+    }
     if (!response.ok || !data.success) {
       throw new Error(data.error || 'Request failed');
     }
@@ -130,8 +534,8 @@ class Api {
     return data.data as T;
   }
 
-  async getTree(): Promise<FileNode> {
-    return this.request<FileNode>('/tree');
+  async getTree(metadata = false): Promise<FileNode> {
+    return this.request<FileNode>(metadata ? '/tree?metadata=true' : '/tree');
   }
 
   async getFile(path: string): Promise<FileData> {
@@ -145,22 +549,34 @@ class Api {
     });
   }
 
-  async updateFile(path: string, content: string): Promise<{ path: string }> {
-    return this.request<{ path: string }>(`/files?path=${encodeURIComponent(path)}`, {
+  async updateFile(path: string, content: string): Promise<{ path: string; version?: string }> {
+    return this.request<{ path: string; version?: string }>(`/files?path=${encodeURIComponent(path)}`, {
       method: 'PUT',
       body: JSON.stringify({ path, content }),
     });
   }
 
+  async restoreFile(path: string, content: string): Promise<{ path: string }> {
+    return this.request<{ path: string }>('/files/restore', {
+      method: 'POST',
+      body: JSON.stringify({ path, content }),
+    });
+  }
+
   async deleteFile(path: string): Promise<void> {
     await this.request<void>(`/files?path=${encodeURIComponent(path)}`, {
       method: 'DELETE',
     });
   }
 
-  async uploadImage(file: File): Promise<ImageUploadResult> {
+  // note, if given, is the note the image is for, so it can be saved in the
+  // note's folder when the vault keeps assets per note
+  async uploadImage(file: File, note?: string): Promise<ImageUploadResult> {
     const formData = new FormData();
     formData.append('image', file);
+    if (note) {
+      formData.append('note', note);
+    }
 
     const response = await fetch(`${API_BASE}/images`, {
       method: 'POST',
@@ -176,34 +592,452 @@ class Api {
     return data.data as ImageUploadResult;
   }
 
+  // Save an image pasted as a data URL or bare base64
+  async pasteImage(data: string, name?: string, alt?: string, note?: string): Promise<PastedImage> {
+    return this.request<PastedImage>('/images/paste', {
+      method: 'POST',
+      body: JSON.stringify({ data, name, alt, note }),
+    });
+  }
+
+  // Upload several images at once. paths keeps each file's location when a
+  // folder is dropped.
+  async uploadImages(files: File[], paths?: string[]): Promise<MultiUploadResult> {
+    const formData = new FormData();
+    files.forEach((file, i) => {
+      formData.append('files', file);
+      if (paths) {
+        formData.append('paths', paths[i]);
+      }
+    });
+
+    const response = await fetch(`${API_BASE}/images`, {
+      method: 'POST',
+      body: formData,
+    });
+
+    const data: ApiResponse<MultiUploadResult> = await response.json();
+
+    if (!data.data) {
+      throw new Error(data.error || 'Upload failed');
+    }
+
+    return data.data;
+  }
+
+  async getBootstrap(): Promise<Bootstrap> {
+    return this.request<Bootstrap>('/bootstrap');
+  }
+
   async getConfig(): Promise<ConfigData> {
     return this.request<ConfigData>('/config');
   }
 
+  async getVersion(): Promise<VersionInfo> {
+    return this.request<VersionInfo>('/version');
+  }
+
+  async getDiagnostics(): Promise<Diagnostics> {
+    return this.request<Diagnostics>('/diagnostics');
+  }
+
+  // When the vault was last backed up; only served with --backup-dir
+  async getBackupStatus(): Promise<BackupStatus> {
+    return this.request<BackupStatus>('/backup');
+  }
+
+  async backupNow(): Promise<BackupStatus> {
+    return this.request<BackupStatus>('/backup/now', { method: 'POST' });
+  }
+
+  async getSettings(): Promise<Settings> {
+    return this.request<Settings>('/settings');
+  }
+
+  async updateSettings(changes: Partial<Settings>): Promise<Settings> {
+    return this.request<Settings>('/settings', {
+      method: 'PUT',
+      body: JSON.stringify(changes),
+    });
+  }
+
+  async getIndexStatus(): Promise<IndexStatus> {
+    return this.request<IndexStatus>('/index/status');
+  }
+
+  async reindex(): Promise<IndexStatus> {
+    return this.request<IndexStatus>('/index', { method: 'POST' });
+  }
+
+  async search(query: string): Promise<SearchResult[]> {
+    return this.request<SearchResult[]>(`/search?q=${encodeURIComponent(query)}`);
+  }
+
+  async getBacklinks(path: string): Promise<IndexedNote[]> {
+    return this.request<IndexedNote[]>(`/backlinks?path=${encodeURIComponent(path)}`);
+  }
+
+  async getTags(): Promise<TagCount[]> {
+    return this.request<TagCount[]>('/tags');
+  }
+
+  // Notes dated in a month (YYYY-MM, default this month), for a month view
+  async getCalendar(month?: string): Promise<CalendarMonth> {
+    return this.request<CalendarMonth>(month ? `/calendar?month=${encodeURIComponent(month)}` : '/calendar');
+  }
+
+  // Groups of near-identical notes, optionally only the copies of path
+  async getDuplicates(threshold?: number, path?: string): Promise<DuplicateGroup[]> {
+    const params = new URLSearchParams();
+    if (threshold !== undefined) params.set('threshold', String(threshold));
+    if (path) params.set('path', path);
+    const query = params.toString();
+    return this.request<DuplicateGroup[]>(query ? `/duplicates?${query}` : '/duplicates');
+  }
+
+  // The index.md or README.md of a folder, rendered as its landing page
+  async renderFolder(path: string = ''): Promise<FolderLandingPage> {
+    return this.request<FolderLandingPage>(`/render/folder?path=${encodeURIComponent(path)}`);
+  }
+
+  // A note's headings with the IDs they are exported with
+  async getOutline(path: string, slug?: HeadingSlug): Promise<NoteOutline> {
+    const params = new URLSearchParams({ path });
+    if (slug) params.set('slug', slug);
+    return this.request<NoteOutline>(`/outline?${params}`);
+  }
+
+  // Bibliography entries matching a query, for completing [@key] citations
+  async searchBibliography(query: string, limit?: number): Promise<BibEntry[]> {
+    const params = new URLSearchParams({ q: query });
+    if (limit) params.set('limit', String(limit));
+    return this.request<BibEntry[]>(`/bibliography?${params}`);
+  }
+
+  async getTasks(filter: TaskFilter = {}): Promise<Task[]> {
+    const params = new URLSearchParams();
+    if (filter.done !== undefined) params.set('done', String(filter.done));
+    if (filter.tag) params.set('tag', filter.tag);
+    if (filter.path) params.set('path', filter.path);
+    if (filter.dueBefore) params.set('due_before', filter.dueBefore);
+    if (filter.dueAfter) params.set('due_after', filter.dueAfter);
+    const query = params.toString();
+    return this.request<Task[]>(query ? `/tasks?${query}` : '/tasks');
+  }
+
+  // Checks or unchecks a task in its note. Passing the task's text makes a
+  // stale list fail instead of toggling whatever is now on that line.
+  async toggleTask(task: Task, done: boolean): Promise<Task> {
+    return this.request<Task>('/tasks/toggle', {
+      method: 'POST',
+      body: JSON.stringify({ path: task.path, line: task.line, done, text: task.text }),
+    });
+  }
+
+  async getEncryptionStatus(): Promise<EncryptionStatus> {
+    return this.request<EncryptionStatus>('/encryption');
+  }
+
+  async setupEncryption(passphrase: string): Promise<EncryptionStatus> {
+    return this.request<EncryptionStatus>('/encryption/setup', {
+      method: 'POST',
+      body: JSON.stringify({ passphrase }),
+    });
+  }
+
+  async unlockEncryption(passphrase: string): Promise<EncryptionStatus> {
+    return this.request<EncryptionStatus>('/encryption/unlock', {
+      method: 'POST',
+      body: JSON.stringify({ passphrase }),
+    });
+  }
+
+  async lockEncryption(): Promise<EncryptionStatus> {
+    return this.request<EncryptionStatus>('/encryption/lock', { method: 'POST' });
+  }
+
+  // Saved git credentials
+  async getCredentials(): Promise<{ status: CredentialStoreStatus; credentials: StoredCredential[] }> {
+    return this.request<{ status: CredentialStoreStatus; credentials: StoredCredential[] }>('/secrets');
+  }
+
+  // Without a passphrase the key is kept in the OS keychain
+  async setupCredentialStore(passphrase?: string): Promise<CredentialStoreStatus> {
+    return this.request<CredentialStoreStatus>('/secrets/setup', {
+      method: 'POST',
+      body: JSON.stringify({ passphrase: passphrase ?? '' }),
+    });
+  }
+
+  async unlockCredentialStore(passphrase: string): Promise<CredentialStoreStatus> {
+    return this.request<CredentialStoreStatus>('/secrets/unlock', {
+      method: 'POST',
+      body: JSON.stringify({ passphrase }),
+    });
+  }
+
+  async lockCredentialStore(): Promise<CredentialStoreStatus> {
+    return this.request<CredentialStoreStatus>('/secrets/lock', { method: 'POST' });
+  }
+
+  async saveCredential(credential: CredentialInput): Promise<StoredCredential> {
+    return this.request<StoredCredential>('/secrets', {
+      method: 'POST',
+      body: JSON.stringify(credential),
+    });
+  }
+
+  async updateCredential(id: string, credential: CredentialInput): Promise<StoredCredential> {
+    return this.request<StoredCredential>(`/secrets/${encodeURIComponent(id)}`, {
+      method: 'PUT',
+      body: JSON.stringify(credential),
+    });
+  }
+
+  async deleteCredential(id: string): Promise<void> {
+    await this.request<void>(`/secrets/${encodeURIComponent(id)}`, { method: 'DELETE' });
+  }
+
+  async encryptNote(path: string): Promise<{ path: string; encrypted: boolean }> {
+    return this.request<{ path: string; encrypted: boolean }>('/encryption/encrypt', {
+      method: 'POST',
+      body: JSON.stringify({ path }),
+    });
+  }
+
+  async decryptNote(path: string): Promise<{ path: string; encrypted: boolean }> {
+    return this.request<{ path: string; encrypted: boolean }>('/encryption/decrypt', {
+      method: 'POST',
+      body: JSON.stringify({ path }),
+    });
+  }
+
+  async getSyncConflicts(): Promise<SyncConflict[]> {
+    return this.request<SyncConflict[]>('/sync-conflicts');
+  }
+
+  async mergeSyncConflict(path: string): Promise<SyncConflictMerge> {
+    return this.request<SyncConflictMerge>(`/sync-conflicts/merge?path=${encodeURIComponent(path)}`);
+  }
+
+  async resolveSyncConflict(path: string, keep: 'original' | 'copy' | 'merged', content?: string): Promise<{ path: string }> {
+    return this.request<{ path: string }>('/sync-conflicts/resolve', {
+      method: 'POST',
+      body: JSON.stringify({ path, keep, content }),
+    });
+  }
+
+  // Reads a newline-delimited JSON response, calling onItem for each line as
+  // it arrives. An {"error": ...} line ends the stream with that error.
+  private async stream<T>(endpoint: string, onItem: (item: T) => void, init?: RequestInit): Promise<void> {
+    const response = await fetch(`${API_BASE}${endpoint}`, init);
+    if (response.status === 401) {
+      window.location.href = '/login';
+    }
+    if (!response.ok || !response.body) {
+      const data: ApiResponse<unknown> = await response.json();
+      throw new Error(data.error || 'Request failed');
+    }
+
+    const reader = response.body.getReader();
+    const decoder = new TextDecoder();
+    let buffered = '';
+    const emit = (line: string) => {
+      if (!line.trim()) return;
+      const item = JSON.parse(line);
+      if (item && typeof item.error === 'string') {
+        throw new Error(item.error);
+      }
+      onItem(item as T);
+    };
+
+    for (;;) {
+      const { done, value } = await reader.read();
+      if (done) break;
+      buffered += decoder.decode(value, { stream: true });
+      const lines = buffered.split('\n');
+      buffered = lines.pop() || '';
+      lines.forEach(emit);
+    }
+    emit(buffered);
+  }
+
+  // Renders the vault as a static site and pushes it to gh-pages (or the
+  // vault's configured branch or repository), reporting progress as it goes
+  async publish(options: PublishOptions, onProgress: (progress: PublishProgress) => void): Promise<PublishResult> {
+    let result: PublishResult | null = null;
+    await this.stream<PublishProgress | { result: PublishResult }>('/publish', (item) => {
+      if ('result' in item) {
+        result = item.result;
+      } else {
+        onProgress(item);
+      }
+    }, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(options),
+    });
+    if (!result) throw new Error('Publish ended without a result');
+    return result;
+  }
+
+  // Has the language model write a commit message for the staged changes
+  async suggestCommitMessage(): Promise<{ message: string; provider: string }> {
+    return this.request<{ message: string; provider: string }>('/ai/commit-message', {
+      method: 'POST',
+    });
+  }
+
+  // Has the language model summarize a note, or the given text
+  async summarize(path: string, content?: string): Promise<{ summary: string; provider: string }> {
+    return this.request<{ summary: string; provider: string }>('/ai/summarize', {
+      method: 'POST',
+      body: JSON.stringify({ path, content }),
+    });
+  }
+
+  // Footnote and reference link problems in a note, with the fix-ups on offer
+  async checkFixups(path: string): Promise<{ path: string; issues: FixupIssue[]; fixes: Fixup[] }> {
+    return this.request<{ path: string; issues: FixupIssue[]; fixes: Fixup[] }>(`/fixups?path=${encodeURIComponent(path)}`);
+  }
+
+  // Runs a fix-up over the editor's content, or over the saved note when
+  // content is left out
+  async applyFixup(path: string, fix: string, content?: string): Promise<FixupResult> {
+    return this.request<FixupResult>('/fixups', {
+      method: 'POST',
+      body: JSON.stringify({ path, fix, content }),
+    });
+  }
+
+  async getSession(): Promise<Session> {
+    return this.request<Session>('/session');
+  }
+
+  async saveSession(session: Session): Promise<Session> {
+    return this.request<Session>('/session', {
+      method: 'PUT',
+      body: JSON.stringify(session),
+    });
+  }
+
   async listDirectories(path?: string): Promise<DirectoryListResult> {
     const params = path ? `?path=${encodeURIComponent(path)}` : '';
     return this.request<DirectoryListResult>(`/directories${params}`);
   }
 
-  async changeDirectory(path: string): Promise<{ path: string }> {
-    return this.request<{ path: string }>('/directories', {
+  async changeDirectory(path: string): Promise<{ path: string; lastFile: string }> {
+    return this.request<{ path: string; lastFile: string }>('/directories', {
       method: 'POST',
       body: JSON.stringify({ path }),
     });
   }
 
 
+  // Fill a folder, by default the open vault, with starter notes and templates
+  async initVault(options: { path?: string; git?: boolean } = {}): Promise<StarterVault> {
+    return this.request<StarterVault>('/vault/init', {
+      method: 'POST',
+      body: JSON.stringify(options),
+    });
+  }
+
+  async openExternal(path: string): Promise<{ path: string }> {
+    return this.request<{ path: string }>('/files/open-external', {
+      method: 'POST',
+      body: JSON.stringify({ path }),
+    });
+  }
+
+  async revealFile(path: string): Promise<{ path: string }> {
+    return this.request<{ path: string }>('/files/reveal', {
+      method: 'POST',
+      body: JSON.stringify({ path }),
+    });
+  }
+
   async getFileMetadata(path: string): Promise<FileMetadata> {
     return this.request<FileMetadata>(`/files/metadata?path=${encodeURIComponent(path)}`);
   }
 
+  async getStart(): Promise<StartPage> {
+    return this.request<StartPage>('/start');
+  }
+
   async getRecents(): Promise<RecentLocation[]> {
     return this.request<RecentLocation[]>('/recents');
   }
 
+  async getRecentFiles(days?: number): Promise<RecentFile[]> {
+    return this.request<RecentFile[]>(days ? `/recents/files?days=${days}` : '/recents/files');
+  }
+
+  async pinRecent(path: string, pinned: boolean): Promise<RecentLocation[]> {
+    return this.request<RecentLocation[]>('/recents/pin', {
+      method: 'POST',
+      body: JSON.stringify({ path, pinned }),
+    });
+  }
+
+  async getFavorites(): Promise<RecentFile[]> {
+    return this.request<RecentFile[]>('/recents/favorites');
+  }
+
+  async addFavorite(path: string): Promise<RecentFile[]> {
+    return this.request<RecentFile[]>('/recents/favorites', {
+      method: 'POST',
+      body: JSON.stringify({ path }),
+    });
+  }
+
+  async removeFavorite(path: string): Promise<RecentFile[]> {
+    return this.request<RecentFile[]>(`/recents/favorites?path=${encodeURIComponent(path)}`, {
+      method: 'DELETE',
+    });
+  }
+
+  async removeRecent(path: string): Promise<RecentLocation[]> {
+    return this.request<RecentLocation[]>(`/recents?path=${encodeURIComponent(path)}`, {
+      method: 'DELETE',
+    });
+  }
+
+  async clearRecents(): Promise<RecentLocation[]> {
+    return this.request<RecentLocation[]>('/recents/all', { method: 'DELETE' });
+  }
+
   // Git operations
-  async getGitStatus(): Promise<GitStatusResponse> {
-    return this.request<GitStatusResponse>('/git/status');
+  async getGitStatus(options: GitStatusOptions = {}): Promise<GitStatusResponse> {
+    const params = new URLSearchParams();
+    if (options.markdown) params.set('markdown', 'true');
+    if (options.exclude?.length) params.set('exclude', options.exclude.join(','));
+    if (options.group) params.set('group', options.group);
+    const query = params.toString();
+    return this.request<GitStatusResponse>(query ? `/git/status?${query}` : '/git/status');
+  }
+
+  // Hosts signed in to for HTTPS remotes, and the providers that can be
+  async getGitAccounts(): Promise<{ accounts: GitAccount[]; providers: { name: string; host: string }[] }> {
+    return this.request<{ accounts: GitAccount[]; providers: { name: string; host: string }[] }>('/git/auth');
+  }
+
+  async startGitSignIn(provider: 'github' | 'gitlab'): Promise<DeviceSignIn> {
+    return this.request<DeviceSignIn>('/git/auth/device', {
+      method: 'POST',
+      body: JSON.stringify({ provider }),
+    });
+  }
+
+  async getGitSignIn(id: string): Promise<DeviceSignIn> {
+    return this.request<DeviceSignIn>(`/git/auth/device/${encodeURIComponent(id)}`);
+  }
+
+  async cancelGitSignIn(id: string): Promise<void> {
+    await this.request<void>(`/git/auth/device/${encodeURIComponent(id)}`, { method: 'DELETE' });
+  }
+
+  async gitSignOut(host: string): Promise<void> {
+    await this.request<void>(`/git/auth/${encodeURIComponent(host)}`, { method: 'DELETE' });
   }
 
   async initGitRepo(): Promise<GitStatusResponse> {
@@ -212,6 +1046,26 @@ class Api {
     });
   }
 
+  // The repository's .gitignore
+  async getGitIgnore(): Promise<{ path: string; content: string }> {
+    return this.request<{ path: string; content: string }>('/git/ignore');
+  }
+
+  async setGitIgnore(content: string): Promise<{ content: string; status: GitStatus }> {
+    return this.request<{ content: string; status: GitStatus }>('/git/ignore', {
+      method: 'PUT',
+      body: JSON.stringify({ content }),
+    });
+  }
+
+  // Add patterns to .gitignore, skipping those it already has
+  async addGitIgnore(patterns: string[]): Promise<{ content: string; added: string[] | null; status: GitStatus }> {
+    return this.request<{ content: string; added: string[] | null; status: GitStatus }>('/git/ignore', {
+      method: 'POST',
+      body: JSON.stringify({ patterns }),
+    });
+  }
+
   // Stage files for commit
   async stageFiles(files: string[], all: boolean = false): Promise<{ status: GitStatus }> {
     return this.request<{ status: GitStatus }>('/git/stage', {
@@ -229,10 +1083,11 @@ class Api {
   }
 
   // Create a commit
-  async commit(message: string, files?: string[]): Promise<{ commit: GitCommit; status: GitStatus }> {
-    return this.request<{ commit: GitCommit; status: GitStatus }>('/git/commit', {
+  // With --git-size-check block, large files are refused unless force is set
+  async commit(message: string, files?: string[], force: boolean = false): Promise<{ commit: GitCommit; status: GitStatus; warnings: SizeWarning[] }> {
+    return this.request<{ commit: GitCommit; status: GitStatus; warnings: SizeWarning[] }>('/git/commit', {
       method: 'POST',
-      body: JSON.stringify({ message, files }),
+      body: JSON.stringify({ message, files, force }),
     });
   }
 
@@ -244,11 +1099,20 @@ class Api {
     });
   }
 
-  // Push commits to remote
-  async push(auth?: AuthOptions): Promise<{ result: PushPullResult; status: GitStatus }> {
+  // Settle a merge conflict with our version, theirs, or content merged by hand, and stage it
+  async resolveConflict(path: string, strategy: 'ours' | 'theirs' | 'custom', content?: string): Promise<ResolveConflictResult> {
+    return this.request<ResolveConflictResult>('/git/resolve', {
+      method: 'POST',
+      body: JSON.stringify({ path, strategy, content }),
+    });
+  }
+
+  // Push commits to remote. A forced push is refused if the remote branch
+  // has moved since the last fetch.
+  async push(auth?: AuthOptions, options: PushOptions = {}): Promise<{ result: PushPullResult; status: GitStatus }> {
     return this.request<{ result: PushPullResult; status: GitStatus }>('/git/push', {
       method: 'POST',
-      body: JSON.stringify(auth || {}),
+      body: JSON.stringify({ ...auth, ...options }),
     });
   }
 
@@ -268,6 +1132,16 @@ class Api {
     });
   }
 
+  // How the last background or manual sync went
+  async getSyncStatus(): Promise<SyncStatus> {
+    return this.request<SyncStatus>('/git/sync');
+  }
+
+  // Sync with the remote now, as far as --auto-sync allows
+  async syncNow(): Promise<SyncStatus> {
+    return this.request<SyncStatus>('/git/sync', { method: 'POST' });
+  }
+
   // List all branches
   async listBranches(): Promise<{ branches: GitBranch[]; current: string }> {
     return this.request<{ branches: GitBranch[]; current: string }>('/git/branches');
@@ -297,6 +1171,83 @@ class Api {
     });
   }
 
+  // Tags, newest first
+  async listTags(): Promise<{ tags: GitTag[] }> {
+    return this.request<{ tags: GitTag[] }>('/git/tags');
+  }
+
+  // Tag HEAD, or target; a message makes the tag annotated
+  async createTag(name: string, message?: string, target?: string): Promise<{ tag: GitTag; tags: GitTag[] }> {
+    return this.request<{ tag: GitTag; tags: GitTag[] }>('/git/tags/create', {
+      method: 'POST',
+      body: JSON.stringify({ name, message, target }),
+    });
+  }
+
+  async deleteTag(name: string): Promise<{ tags: GitTag[] }> {
+    return this.request<{ tags: GitTag[] }>('/git/tags/delete', {
+      method: 'POST',
+      body: JSON.stringify({ name }),
+    });
+  }
+
+  // Push the named tags, or every tag when none are named
+  async pushTags(tags?: string[], auth?: AuthOptions): Promise<{ result: PushPullResult }> {
+    return this.request<{ result: PushPullResult }>('/git/tags/push', {
+      method: 'POST',
+      body: JSON.stringify({ ...auth, tags }),
+    });
+  }
+
+  async listRemotes(): Promise<{ remotes: GitRemote[] }> {
+    return this.request<{ remotes: GitRemote[] }>('/git/remotes');
+  }
+
+  async addRemote(name: string, url: string): Promise<{ remotes: GitRemote[] }> {
+    return this.request<{ remotes: GitRemote[] }>('/git/remotes/add', {
+      method: 'POST',
+      body: JSON.stringify({ name, url }),
+    });
+  }
+
+  // Removes a remote along with its remote-tracking branches
+  async removeRemote(name: string): Promise<{ remotes: GitRemote[] }> {
+    return this.request<{ remotes: GitRemote[] }>('/git/remotes/remove', {
+      method: 'POST',
+      body: JSON.stringify({ name }),
+    });
+  }
+
+  async renameRemote(name: string, newName: string): Promise<{ remotes: GitRemote[] }> {
+    return this.request<{ remotes: GitRemote[] }>('/git/remotes/rename', {
+      method: 'POST',
+      body: JSON.stringify({ name, newName }),
+    });
+  }
+
+  async setRemoteURL(name: string, url: string): Promise<{ remotes: GitRemote[] }> {
+    return this.request<{ remotes: GitRemote[] }>('/git/remotes/set-url', {
+      method: 'POST',
+      body: JSON.stringify({ name, url }),
+    });
+  }
+
+  // Commit the inverse of a commit, leaving clashing changes as conflicts
+  async revertCommit(hash: string): Promise<{ result: PickResult; conflicts: string[]; status: GitStatus }> {
+    return this.request<{ result: PickResult; conflicts: string[]; status: GitStatus }>('/git/revert', {
+      method: 'POST',
+      body: JSON.stringify({ hash }),
+    });
+  }
+
+  // Commit a commit's changes on top of HEAD, leaving clashing changes as conflicts
+  async cherryPick(hash: string): Promise<{ result: PickResult; conflicts: string[]; status: GitStatus }> {
+    return this.request<{ result: PickResult; conflicts: string[]; status: GitStatus }>('/git/cherry-pick', {
+      method: 'POST',
+      body: JSON.stringify({ hash }),
+    });
+  }
+
   // Rename a branch
   async renameBranch(name: string, newName: string): Promise<{ branches: GitBranch[]; status: GitStatus }> {
     return this.request<{ branches: GitBranch[]; status: GitStatus }>('/git/branches/rename', {
@@ -305,13 +1256,25 @@ class Api {
     });
   }
 
-  // Get commit history
-  async getHistory(limit: number = 50, skip: number = 0, filePath?: string): Promise<{ commits: GitCommit[] }> {
-    let url = `/git/history?limit=${limit}&skip=${skip}`;
+  // Get a page of commit history; pass the page's next to get the one after
+  async getHistory(limit: number = 50, cursor?: string, filePath?: string): Promise<HistoryPage> {
+    let url = `/git/history?limit=${limit}`;
+    if (cursor) {
+      url += `&cursor=${encodeURIComponent(cursor)}`;
+    }
     if (filePath) {
       url += `&path=${encodeURIComponent(filePath)}`;
     }
-    return this.request<{ commits: GitCommit[] }>(url);
+    return this.request<HistoryPage>(url);
+  }
+
+  // Stream the whole history, or limit commits of it, one commit at a time
+  async streamHistory(onCommit: (commit: GitCommit) => void, limit: number = 0, filePath?: string): Promise<void> {
+    let url = `/git/history/stream?limit=${limit}`;
+    if (filePath) {
+      url += `&path=${encodeURIComponent(filePath)}`;
+    }
+    return this.stream<GitCommit>(url, onCommit);
   }
 
   // Get commit details
@@ -320,14 +1283,38 @@ class Api {
   }
 
   // Get diff between two commits
-  async getDiff(fromHash: string, toHash: string, filePath?: string): Promise<DiffResult> {
+  async getDiff(fromHash: string, toHash: string, filePath?: string, full: boolean = false): Promise<DiffResult> {
     let url = `/git/diff?from=${encodeURIComponent(fromHash)}&to=${encodeURIComponent(toHash)}`;
     if (filePath) {
       url += `&path=${encodeURIComponent(filePath)}`;
     }
+    if (full) {
+      url += '&full=true';
+    }
     return this.request<DiffResult>(url);
   }
 
+  // Stream the diff between two commits one file at a time
+  async streamDiff(fromHash: string, toHash: string, onFile: (file: FileDiff) => void, full: boolean = false): Promise<void> {
+    let url = `/git/diff/stream?from=${encodeURIComponent(fromHash)}&to=${encodeURIComponent(toHash)}`;
+    if (full) {
+      url += '&full=true';
+    }
+    return this.stream<FileDiff>(url, onFile);
+  }
+
+  // Get the uncommitted changes, staged or not yet staged, to a file or folder or to everything
+  async getWorkingDiff(staged: boolean, path?: string, full: boolean = false): Promise<WorkingDiffResult> {
+    let url = `/git/diff/worktree?staged=${staged}`;
+    if (path) {
+      url += `&path=${encodeURIComponent(path)}`;
+    }
+    if (full) {
+      url += '&full=true';
+    }
+    return this.request<WorkingDiffResult>(url);
+  }
+
   // Get file content at a specific commit
   async getFileAtCommit(hash: string, filePath: string): Promise<{ content: string; hash: string; path: string }> {
     return this.request<{ content: string; hash: string; path: string }>(
@@ -336,10 +1323,10 @@ class Api {
   }
 
   // Quick commit: stage, commit, and optionally push
-  async quickCommit(message: string, files?: string[], push: boolean = false): Promise<QuickCommitResult> {
+  async quickCommit(message: string, files?: string[], push: boolean = false, force: boolean = false): Promise<QuickCommitResult> {
     return this.request<QuickCommitResult>('/git/quick-commit', {
       method: 'POST',
-      body: JSON.stringify({ message, files, push }),
+      body: JSON.stringify({ message, files, push, force }),
     });
   }
 }
@@ -351,6 +1338,7 @@ interface FileChange {
   action: 'added' | 'modified' | 'deleted' | 'renamed';
   additions: number;
   deletions: number;
+  binary?: boolean;
 }
 
 interface CommitDetail {
@@ -373,20 +1361,67 @@ interface FileDiff {
   lines: DiffLine[];
   additions: number;
   deletions: number;
+  truncated?: boolean;
+  oldSize?: number;
+  newSize?: number;
 }
 
 interface DiffResult {
   fromCommit: string;
   toCommit: string;
   files: FileDiff[];
+  truncated?: boolean;
+}
+
+interface WorkingDiffResult {
+  staged: boolean;
+  files: FileDiff[];
+  truncated: boolean;
+}
+
+interface ResolveConflictResult {
+  path: string;
+  conflicts: string[];
+  status: GitStatus;
+}
+
+type HeadingSlug = 'goldmark' | 'github' | 'pandoc';
+
+interface OutlineHeading {
+  level: number;
+  text: string;
+  id: string;
+}
+
+interface NoteOutline {
+  path: string;
+  headings: OutlineHeading[];
+}
+
+interface FolderLandingPage {
+  folder: string;
+  path: string;
+  content: string;
+  html: string;
+}
+
+// A file, or the whole commit when path is missing, that is too large
+interface SizeWarning {
+  path?: string;
+  size: number;
+  limit?: number;
+  reason: 'large' | 'lfs' | 'commit';
+  message: string;
 }
 
 interface QuickCommitResult {
   commit: GitCommit;
   status: GitStatus;
+  warnings: SizeWarning[];
   push?: PushPullResult;
   pushError?: string;
 }
 
 export const api = new Api();
-export type { FileNode, FileData, ConfigData, DirectoryEntry, DirectoryListResult, FileMetadata, RecentLocation, GitStatus, GitFileStatus, GitStatusResponse, GitCommit, GitBranch, PushPullResult, PullResult, AuthOptions, FileChange, CommitDetail, DiffLine, FileDiff, DiffResult, QuickCommitResult };
+export { LockedError };
+export type { Bootstrap, FileNode, FileData, ConfigData, DirectoryEntry, DirectoryListResult, FileMetadata, FolderContents, RecentLocation, RecentFile, StartPage, GitStatus, GitFileStatus, GitStatusResponse, SyncStatus, GitCommit, HistoryPage, GitBranch, GitTag, GitRemote, GitAccount, DeviceSignIn, CredentialStoreStatus, StoredCredential, CredentialInput, PickResult, PushPullResult, PullResult, AuthOptions, FileChange, CommitDetail, DiffLine, FileDiff, DiffResult, WorkingDiffResult, ResolveConflictResult, QuickCommitResult, SizeWarning, UploadResult, MultiUploadResult, PastedImage, Settings, MarkdownFlavor, MarkdownExtensions, RenderOptions, VersionInfo, Session, IndexStatus, SearchResult, IndexedNote, TagCount, CalendarMonth, DuplicateGroup, FolderLandingPage, BibEntry, FixupIssue, Fixup, FixupResult, Task, TaskFilter, EncryptionStatus, SyncConflict, SyncConflictMerge, PublishOptions, PublishProgress, PublishResult, Diagnostics, DiagnosticCheck, BackupStatus, ConfigOption };
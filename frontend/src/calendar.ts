@@ -0,0 +1,151 @@
+// Month view of date-named notes and notes with a front matter date
+
+import { api, CalendarMonth, IndexedNote } from './api';
+
+const WEEKDAYS = ['Mon', 'Tue', 'Wed', 'Thu', 'Fri', 'Sat', 'Sun'];
+
+export class CalendarDialog {
+  private dialog: HTMLDivElement | null = null;
+  private onOpen: ((path: string) => void) | null = null;
+  private calendar: CalendarMonth | null = null;
+  private selected: string | null = null; // Day whose notes are listed
+
+  async show(onOpen: (path: string) => void, month?: string): Promise<void> {
+    this.onOpen = onOpen;
+    if (!this.dialog) {
+      this.dialog = document.createElement('div');
+      this.dialog.className = 'modal';
+      document.body.appendChild(this.dialog);
+    }
+    await this.load(month);
+  }
+
+  close(): void {
+    this.dialog?.remove();
+    this.dialog = null;
+    this.selected = null;
+  }
+
+  private async load(month?: string): Promise<void> {
+    try {
+      this.calendar = await api.getCalendar(month);
+      this.render();
+    } catch (err) {
+      console.error('Failed to load calendar:', err);
+      alert('Failed to load calendar: ' + (err as Error).message);
+      this.close();
+    }
+  }
+
+  private render(): void {
+    if (!this.dialog || !this.calendar) return;
+    const cal = this.calendar;
+    const [year, month] = cal.month.split('-').map(Number);
+    const title = new Date(year, month - 1, 1).toLocaleDateString(undefined, { month: 'long', year: 'numeric' });
+
+    const notesByDay = new Map(cal.days.map(d => [d.date, d.notes]));
+    const today = this.formatDate(new Date());
+    const daysInMonth = new Date(year, month, 0).getDate();
+    const leading = (new Date(year, month - 1, 1).getDay() + 6) % 7; // Weeks start on Monday
+
+    let cells = '';
+    for (let i = 0; i < leading; i++) {
+      cells += '<div class="calendar-cell empty"></div>';
+    }
+    for (let day = 1; day <= daysInMonth; day++) {
+      const date = `${cal.month}-${String(day).padStart(2, '0')}`;
+      const notes = notesByDay.get(date) ?? [];
+      const classes = ['calendar-cell'];
+      if (notes.length > 0) classes.push('has-notes');
+      if (date === today) classes.push('today');
+      if (date === this.selected) classes.push('selected');
+      cells += `
+        <button class="${classes.join(' ')}" data-date="${date}" ${notes.length === 0 ? 'disabled' : ''}
+          title="${notes.length === 1 ? this.escape(notes[0].title) : notes.length > 1 ? `${notes.length} notes` : ''}">
+          ${day}
+        </button>
+      `;
+    }
+
+    const selectedNotes = this.selected ? notesByDay.get(this.selected) ?? [] : [];
+    const streak = cal.streak;
+
+    this.dialog.innerHTML = `
+      <div class="modal-backdrop"></div>
+      <div class="modal-content calendar-dialog">
+        <div class="calendar-header">
+          <button class="calendar-nav" data-month="${this.shiftMonth(cal.month, -1)}" title="Previous month">&lsaquo;</button>
+          <h3>${this.escape(title)}</h3>
+          <button class="calendar-nav" data-month="${this.shiftMonth(cal.month, 1)}" title="Next month">&rsaquo;</button>
+        </div>
+        <div class="calendar-grid">
+          ${WEEKDAYS.map(d => `<div class="calendar-weekday">${d}</div>`).join('')}
+          ${cells}
+        </div>
+        <div class="calendar-streak">
+          ${streak.current > 0 ? `${streak.current}-day streak` : 'No current streak'}
+          ${streak.longest > 0 ? ` &middot; longest ${streak.longest} days` : ''}
+        </div>
+        ${selectedNotes.length > 1 ? `
+          <ul class="calendar-notes">
+            ${selectedNotes.map(n => `<li><a href="#" data-path="${this.escape(n.path)}">${this.escape(n.title)}</a></li>`).join('')}
+          </ul>
+        ` : ''}
+        <div class="modal-actions">
+          <button class="btn-secondary calendar-today">Today</button>
+          <button class="btn-secondary calendar-close">Close</button>
+        </div>
+      </div>
+    `;
+
+    this.dialog.querySelector('.modal-backdrop')?.addEventListener('click', () => this.close());
+    this.dialog.querySelector('.calendar-close')?.addEventListener('click', () => this.close());
+    this.dialog.querySelector('.calendar-today')?.addEventListener('click', () => this.load());
+    this.dialog.querySelectorAll('.calendar-nav').forEach(btn => {
+      btn.addEventListener('click', () => this.load((btn as HTMLElement).dataset.month));
+    });
+    this.dialog.querySelectorAll('.calendar-cell.has-notes').forEach(cell => {
+      cell.addEventListener('click', () => {
+        const notes = notesByDay.get((cell as HTMLElement).dataset.date!) ?? [];
+        if (notes.length === 1) {
+          this.open(notes[0]);
+        } else {
+          this.selected = (cell as HTMLElement).dataset.date!;
+          this.render();
+        }
+      });
+    });
+    this.dialog.querySelectorAll('.calendar-notes a').forEach(link => {
+      link.addEventListener('click', (e) => {
+        e.preventDefault();
+        const path = (link as HTMLElement).dataset.path!;
+        const note = selectedNotes.find(n => n.path === path);
+        if (note) this.open(note);
+      });
+    });
+  }
+
+  private open(note: IndexedNote): void {
+    const onOpen = this.onOpen;
+    this.close();
+    onOpen?.(note.path);
+  }
+
+  private shiftMonth(month: string, delta: number): string {
+    const [year, m] = month.split('-').map(Number);
+    const date = new Date(year, m - 1 + delta, 1);
+    return `${date.getFullYear()}-${String(date.getMonth() + 1).padStart(2, '0')}`;
+  }
+
+  private formatDate(date: Date): string {
+    return `${date.getFullYear()}-${String(date.getMonth() + 1).padStart(2, '0')}-${String(date.getDate()).padStart(2, '0')}`;
+  }
+
+  private escape(text: string): string {
+    const div = document.createElement('div');
+    div.textContent = text;
+    return div.innerHTML.replace(/"/g, '&quot;');
+  }
+}
+
+export const calendarDialog = new CalendarDialog();
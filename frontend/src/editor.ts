@@ -27,7 +27,7 @@ import '@milkdown/crepe/theme/common/image-block.css';
 import '@milkdown/crepe/theme/common/cursor.css';
 import '@milkdown/crepe/theme/frame.css';
 
-import { api } from './api';
+import { api, LockedError } from './api';
 
 export interface Heading {
   level: number;
@@ -50,6 +50,7 @@ export class MarkdownEditor {
   private currentPath: string | null = null;
   private isDirty = false;
   private saveTimeout: number | null = null;
+  private autosaveInterval = 1000;
   private lastContent = '';
   private initialized = false;
 
@@ -176,9 +177,9 @@ export class MarkdownEditor {
           onUpload: async (file: File) => {
             try {
               self.options.onStatus?.('Uploading image...');
-              const result = await api.uploadImage(file);
+              const result = await api.uploadImage(file, self.currentPath ?? undefined);
               self.options.onStatus?.('Image uploaded');
-              return '/images/' + result.path.replace('assets/', '');
+              return result.url ?? '/images/' + result.path.replace('assets/', '');
             } catch (error) {
               console.error('Failed to upload image:', error);
               self.options.onError?.('Failed to upload image: ' + (error as Error).message);
@@ -205,7 +206,7 @@ export class MarkdownEditor {
   async loadFile(path: string): Promise<void> {
     try {
       console.log('[Editor] Loading file:', path);
-      const data = await api.getFile(path);
+      const data = await this.fetchFile(path);
       console.log('[Editor] File data received, content length:', data.content.length);
       this.currentPath = path;
       this.isDirty = false;
@@ -220,6 +221,25 @@ export class MarkdownEditor {
     }
   }
 
+  // fetchFile gets a note, asking for the passphrase if it is encrypted and
+  // the vault is locked
+  private async fetchFile(path: string): Promise<{ content: string }> {
+    for (;;) {
+      try {
+        return await api.getFile(path);
+      } catch (error) {
+        if (!(error instanceof LockedError)) throw error;
+        const passphrase = prompt('This note is encrypted. Enter the vault passphrase:');
+        if (passphrase === null) throw error;
+        try {
+          await api.unlockEncryption(passphrase);
+        } catch (unlockError) {
+          alert((unlockError as Error).message);
+        }
+      }
+    }
+  }
+
   async setContent(markdown: string): Promise<void> {
     this.lastContent = markdown;
 
@@ -245,11 +265,19 @@ export class MarkdownEditor {
     // Debounced auto-save
     if (this.saveTimeout) {
       clearTimeout(this.saveTimeout);
+      this.saveTimeout = null;
+    }
+
+    if (this.autosaveInterval > 0) {
+      this.saveTimeout = window.setTimeout(() => {
+        this.save();
+      }, this.autosaveInterval);
     }
+  }
 
-    this.saveTimeout = window.setTimeout(() => {
-      this.save();
-    }, 1000);
+  // Set the auto-save delay in milliseconds; 0 turns auto-save off
+  setAutosaveInterval(ms: number): void {
+    this.autosaveInterval = ms;
   }
 
   async save(): Promise<void> {
@@ -298,8 +326,8 @@ export class MarkdownEditor {
   private async uploadAndInsertImage(file: File): Promise<void> {
     try {
       this.options.onStatus?.('Uploading image...');
-      const result = await api.uploadImage(file);
-      const imagePath = '/images/' + result.path.replace('assets/', '');
+      const result = await api.uploadImage(file, this.currentPath ?? undefined);
+      const imagePath = result.url ?? '/images/' + result.path.replace('assets/', '');
 
       // Insert image markdown at cursor
       if (this.crepe) {
@@ -14,6 +14,7 @@ interface FileTreeOptions {
   onFileDeleted?: (path: string) => void;
   onRename?: (path: string) => void;
   onHeadingClick?: (path: string, line: number) => void;
+  onExpandedChange?: (dirs: string[]) => void;
 }
 
 export class FileTree {
@@ -46,7 +47,15 @@ export class FileTree {
     }
   }
 
-  async load(): Promise<void> {
+  // Load the tree. A partial tree given to start with is shown at once,
+  // and the whole tree replaces it when it arrives.
+  async load(initial?: FileNode): Promise<void> {
+    if (initial) {
+      this.tree = initial;
+      this.render();
+      void this.refresh();
+      return;
+    }
     this.showLoading();
     try {
       this.tree = await api.getTree();
@@ -90,7 +99,7 @@ export class FileTree {
       if (this.searchQuery) {
         this.showEmpty('No files match your search');
       } else {
-        this.showEmpty('No markdown files found');
+        this.showEmpty('No markdown files found', true);
       }
       return;
     }
@@ -223,6 +232,10 @@ export class FileTree {
       if (this.activeFile === node.path) {
         item.classList.add('active');
       }
+      if (node.conflictOf) {
+        item.classList.add('sync-conflict');
+        item.title = `Conflicted copy of ${node.conflictOf}`;
+      }
     }
 
     let html = '';
@@ -319,6 +332,10 @@ export class FileTree {
       if (this.activeFile === node.path) {
         item.classList.add('active');
       }
+      if (node.conflictOf) {
+        item.classList.add('sync-conflict');
+        item.title = `Conflicted copy of ${node.conflictOf}`;
+      }
     }
 
     let html = '';
@@ -444,6 +461,15 @@ export class FileTree {
     this.render();
   }
 
+  getExpandedDirs(): string[] {
+    return [...this.expandedDirs];
+  }
+
+  setExpandedDirs(dirs: string[]): void {
+    this.expandedDirs = new Set(dirs);
+    this.render();
+  }
+
   private toggleDir(path: string): void {
     if (this.expandedDirs.has(path)) {
       this.expandedDirs.delete(path);
@@ -456,6 +482,7 @@ export class FileTree {
     } catch {
       // Ignore
     }
+    this.options.onExpandedChange?.([...this.expandedDirs]);
 
     this.render();
   }
@@ -481,6 +508,30 @@ export class FileTree {
         </svg>
         Open
       </div>`;
+      if (node.conflictOf) {
+        html += `<div class="tree-context-menu-item" data-action="resolve-conflict">
+          <svg width="16" height="16" viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2">
+            <circle cx="18" cy="18" r="3"/>
+            <circle cx="6" cy="6" r="3"/>
+            <path d="M6 21V9a9 9 0 0 0 9 9"/>
+          </svg>
+          Resolve Conflict...
+        </div>`;
+      }
+      html += `<div class="tree-context-menu-item" data-action="encrypt">
+        <svg width="16" height="16" viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2">
+          <rect x="3" y="11" width="18" height="11" rx="2" ry="2"/>
+          <path d="M7 11V7a5 5 0 0 1 10 0v4"/>
+        </svg>
+        Encrypt
+      </div>`;
+      html += `<div class="tree-context-menu-item" data-action="decrypt">
+        <svg width="16" height="16" viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2">
+          <rect x="3" y="11" width="18" height="11" rx="2" ry="2"/>
+          <path d="M7 11V7a5 5 0 0 1 9.9-1"/>
+        </svg>
+        Decrypt
+      </div>`;
     }
 
     html += `<div class="tree-context-menu-item" data-action="rename">
@@ -516,6 +567,26 @@ export class FileTree {
         case 'rename':
           this.options.onRename?.(node.path);
           break;
+        case 'encrypt':
+        case 'decrypt':
+          try {
+            if (!(await this.unlockVault())) break;
+            if (action === 'encrypt') {
+              await api.encryptNote(node.path);
+            } else {
+              await api.decryptNote(node.path);
+            }
+          } catch (error) {
+            alert(`Failed to ${action}: ` + (error as Error).message);
+          }
+          break;
+        case 'resolve-conflict':
+          try {
+            await this.resolveConflict(node);
+          } catch (error) {
+            alert('Failed to resolve conflict: ' + (error as Error).message);
+          }
+          break;
         case 'delete':
           if (confirm(`Delete "${node.name}"?`)) {
             try {
@@ -541,6 +612,57 @@ export class FileTree {
     setTimeout(() => document.addEventListener('click', closeMenu), 0);
   }
 
+  // resolveConflict asks which version of a conflicted copy to keep. A merge
+  // saves both into the original, with conflict markers where they differ,
+  // and opens it for tidying up.
+  private async resolveConflict(node: FileNode): Promise<void> {
+    const choice = prompt(
+      `"${node.name}" is a conflicted copy of "${node.conflictOf}".\n` +
+      'Type "original" to keep the original, "copy" to keep this copy, or "merge" to combine them:',
+      'merge'
+    );
+    if (choice === null) return;
+
+    const keep = choice.trim().toLowerCase();
+    let result: { path: string };
+    if (keep === 'merge') {
+      const draft = await api.mergeSyncConflict(node.path);
+      result = await api.resolveSyncConflict(node.path, 'merged', draft.merged);
+    } else if (keep === 'original' || keep === 'copy') {
+      result = await api.resolveSyncConflict(node.path, keep);
+    } else {
+      alert(`Unknown choice "${choice}"`);
+      return;
+    }
+
+    this.options.onFileDeleted?.(node.path);
+    await this.refresh();
+    this.options.onFileOpen?.(result.path);
+  }
+
+  // unlockVault makes sure the vault key is unlocked, setting up encryption
+  // the first time. It returns false if the user cancels.
+  private async unlockVault(): Promise<boolean> {
+    const status = await api.getEncryptionStatus();
+    if (status.unlocked) return true;
+
+    if (!status.configured) {
+      const passphrase = prompt('Choose a passphrase for encrypted notes. It cannot be recovered if lost:');
+      if (passphrase === null) return false;
+      if (prompt('Enter the passphrase again:') !== passphrase) {
+        alert('The passphrases do not match');
+        return false;
+      }
+      await api.setupEncryption(passphrase);
+      return true;
+    }
+
+    const passphrase = prompt('Enter the vault passphrase:');
+    if (passphrase === null) return false;
+    await api.unlockEncryption(passphrase);
+    return true;
+  }
+
   private showLoading(): void {
     this.container.innerHTML = `
       <div class="tree-loading">
@@ -550,7 +672,8 @@ export class FileTree {
     `;
   }
 
-  private showEmpty(message = 'No markdown files found'): void {
+  // An empty vault offers to add the starter notes and templates
+  private showEmpty(message = 'No markdown files found', offerStarter = false): void {
     this.container.innerHTML = `
       <div class="tree-empty">
         <svg viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2">
@@ -558,8 +681,22 @@ export class FileTree {
           <polyline points="14 2 14 8 20 8"/>
         </svg>
         ${this.escapeHtml(message)}
+        ${offerStarter ? '<button class="btn-secondary tree-empty-action">Add starter notes</button>' : ''}
       </div>
     `;
+
+    const starter = this.container.querySelector('.tree-empty-action') as HTMLButtonElement | null;
+    starter?.addEventListener('click', async () => {
+      starter.disabled = true;
+      try {
+        await api.initVault();
+        await this.refresh();
+        this.options.onFileOpen?.('Welcome.md');
+      } catch (error) {
+        starter.disabled = false;
+        console.error('Failed to add starter notes:', error);
+      }
+    });
   }
 
   private showError(message: string): void {
@@ -631,8 +768,10 @@ export class FileTree {
       tooltip.innerHTML = `
         <div class="tooltip-path">${this.escapeHtml(node.path)}</div>
         <div class="tooltip-meta">
-          <span class="tooltip-size">${node.isDir ? 'Directory' : this.formatFileSize(metadata.size)}</span>
-          <span class="tooltip-modified">Modified: ${metadata.modifiedTime}</span>
+          <span class="tooltip-size">${metadata.contents
+            ? `${metadata.contents.notes} notes, ${this.formatFileSize(metadata.size)}`
+            : this.formatFileSize(metadata.size)}</span>
+          <span class="tooltip-modified">Modified: ${new Date(metadata.modifiedTime).toLocaleString()}</span>
         </div>
       `;
       tooltip.classList.add('visible');
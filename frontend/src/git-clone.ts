@@ -50,7 +50,7 @@ export class GitCloneDialog {
                             </div>
                             <div class="form-group">
                                 <label for="https-password">Password / Token</label>
-                                <input type="password" id="https-password" placeholder="Personal access token recommended" />
+                                <input type="password" id="https-password" placeholder="Blank to use your git credential helper" />
                                 <span class="form-hint">Use a personal access token for better security</span>
                             </div>
                         </div>
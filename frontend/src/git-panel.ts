@@ -1,5 +1,5 @@
 // Git panel component for managing git operations
-import { api, GitStatus, GitFileStatus, GitCommit, GitBranch, DiffResult, FileDiff } from './api';
+import { api, GitStatus, GitFileStatus, GitCommit, HistoryPage, GitBranch, DiffResult, FileDiff } from './api';
 
 type TabType = 'changes' | 'history' | 'branches';
 
@@ -12,13 +12,21 @@ export class GitPanel {
   private onStatusChange: ((status: GitStatus | null) => void) | null = null;
   private isPushing: boolean = false;
   private isPulling: boolean = false;
+  private publishStatus: string | null = null; // Progress while publishing
+  private aiEnabled: boolean = false; // A language model can write commit messages
+  private isSuggesting: boolean = false;
   private isOpen: boolean = true;
 
   // History state
   private commits: GitCommit[] = [];
   private selectedCommits: string[] = []; // For diff comparison (max 2)
   private diffResult: DiffResult | null = null;
+  private diffHashes: [string, string] | null = null; // Full hashes of the diff shown
   private isLoadingHistory: boolean = false;
+  private historyNext: string | null = null; // Cursor for the next page
+  private historyTotal: number = 0;
+  private historyEstimated: boolean = false;
+  private isLoadingMore: boolean = false;
   private showDiffViewer: boolean = false;
 
   constructor(container: HTMLElement) {
@@ -30,6 +38,10 @@ export class GitPanel {
     this.onStatusChange = callback;
   }
 
+  setAIEnabled(enabled: boolean): void {
+    this.aiEnabled = enabled;
+  }
+
   // Panel visibility methods
   toggle(): void {
     this.isOpen = !this.isOpen;
@@ -101,20 +113,57 @@ export class GitPanel {
     this.render();
 
     try {
-      const result = await api.getHistory(50);
-      this.commits = result.commits;
+      const page = await api.getHistory(50);
+      this.commits = page.commits;
+      this.setHistoryPage(page);
     } catch (err) {
       console.error('Failed to load history:', err);
       this.commits = [];
+      this.historyNext = null;
     } finally {
       this.isLoadingHistory = false;
       this.render();
     }
   }
 
-  private async loadDiff(fromHash: string, toHash: string): Promise<void> {
+  // Appends the next page of history as the list is scrolled to its end
+  private async loadMoreHistory(): Promise<void> {
+    if (this.isLoadingMore || this.isLoadingHistory || !this.historyNext) return;
+    this.isLoadingMore = true;
+    this.renderKeepingScroll();
+
     try {
-      this.diffResult = await api.getDiff(fromHash, toHash);
+      const page = await api.getHistory(50, this.historyNext);
+      this.commits = this.commits.concat(page.commits);
+      this.setHistoryPage(page);
+    } catch (err) {
+      console.error('Failed to load more history:', err);
+    } finally {
+      this.isLoadingMore = false;
+      this.renderKeepingScroll();
+    }
+  }
+
+  private setHistoryPage(page: HistoryPage): void {
+    this.historyNext = page.hasMore ? page.next ?? null : null;
+    this.historyTotal = page.total ?? 0;
+    this.historyEstimated = page.estimated ?? false;
+  }
+
+  // Re-renders without jumping the history list back to the top
+  private renderKeepingScroll(): void {
+    const scrollTop = this.container.querySelector('.git-history-list')?.scrollTop ?? 0;
+    this.render();
+    const list = this.container.querySelector('.git-history-list');
+    if (list) {
+      list.scrollTop = scrollTop;
+    }
+  }
+
+  private async loadDiff(fromHash: string, toHash: string, full: boolean = false): Promise<void> {
+    try {
+      this.diffResult = await api.getDiff(fromHash, toHash, undefined, full);
+      this.diffHashes = [fromHash, toHash];
       this.showDiffViewer = true;
       this.render();
     } catch (err) {
@@ -187,6 +236,12 @@ export class GitPanel {
                 </svg>
                 ${this.isPushing ? 'Pushing...' : 'Push'}
               </button>
+              <button class="git-remote-btn" data-action="publish" title="Publish the vault as a site on the gh-pages branch" ${this.publishStatus ? 'disabled' : ''}>
+                <svg width="14" height="14" viewBox="0 0 16 16" fill="currentColor">
+                  <path d="M8 0a8 8 0 110 16A8 8 0 018 0zM1.5 8a6.5 6.5 0 0011.48 4.18L11 10.2V9a1 1 0 00-1-1H7V6h1a1 1 0 001-1V3.2A6.5 6.5 0 001.5 8z"/>
+                </svg>
+                ${this.publishStatus ?? 'Publish'}
+              </button>
             </div>
           ` : ''}
         </div>
@@ -240,10 +295,19 @@ export class GitPanel {
          </div>`
       : '';
 
+    let footer = '';
+    if (this.historyNext) {
+      const total = this.historyTotal > 0
+        ? ` of ${this.historyEstimated ? 'about ' : ''}${this.historyTotal}`
+        : '';
+      footer = `<div class="git-history-more">${this.isLoadingMore ? 'Loading more...' : `${this.commits.length}${total} commits`}</div>`;
+    }
+
     return `
       ${selectedInfo}
       <div class="git-history-list">
         ${this.commits.map(commit => this.renderCommitItem(commit)).join('')}
+        ${footer}
       </div>
     `;
   }
@@ -294,6 +358,12 @@ export class GitPanel {
             ${this.diffResult.fromCommit} → ${this.diffResult.toCommit}
           </span>
         </div>
+        ${this.diffResult.truncated ? `
+          <div class="git-diff-truncated">
+            Large files are shortened.
+            <button data-action="load-full-diff">Load full diff</button>
+          </div>
+        ` : ''}
         <div class="git-diff-files">
           ${this.diffResult.files.map(file => this.renderFileDiff(file)).join('')}
         </div>
@@ -301,10 +371,26 @@ export class GitPanel {
     `;
   }
 
+  // Describes how a binary file's size changed, e.g. " (12 KB → 15 KB)"
+  private describeSizeChange(file: FileDiff): string {
+    const format = (bytes: number): string => {
+      if (bytes < 1024) return `${bytes} B`;
+      if (bytes < 1024 * 1024) return `${Math.round(bytes / 1024)} KB`;
+      return `${(bytes / (1024 * 1024)).toFixed(1)} MB`;
+    };
+    if (file.oldSize && file.newSize) return ` (${format(file.oldSize)} → ${format(file.newSize)})`;
+    if (file.newSize) return ` (${format(file.newSize)})`;
+    if (file.oldSize) return ` (${format(file.oldSize)})`;
+    return '';
+  }
+
   private renderFileDiff(file: FileDiff): string {
-    const stats = file.binary
-      ? '<span class="git-diff-binary">Binary file</span>'
+    let stats = file.binary
+      ? `<span class="git-diff-binary">Binary file${this.describeSizeChange(file)}</span>`
       : `<span class="git-diff-add">+${file.additions}</span> <span class="git-diff-del">-${file.deletions}</span>`;
+    if (file.truncated) {
+      stats += ' <span class="git-diff-binary">truncated</span>';
+    }
 
     const actionClass = file.action === 'added' ? 'added' : file.action === 'deleted' ? 'deleted' : 'modified';
 
@@ -315,7 +401,7 @@ export class GitPanel {
           <span class="git-diff-file-path">${this.escapeHtml(file.path)}</span>
           <span class="git-diff-file-stats">${stats}</span>
         </div>
-        ${!file.binary && file.lines.length > 0 ? `
+        ${!file.binary && file.lines && file.lines.length > 0 ? `
           <div class="git-diff-content">
             ${file.lines.map(line => `
               <div class="git-diff-line ${line.type}">
@@ -512,8 +598,13 @@ export class GitPanel {
     if (hasChanges) {
       html += `
         <div class="git-commit-section">
-          <textarea class="git-commit-message" placeholder="Commit message..." rows="3"></textarea>
+          <textarea class="git-commit-message" placeholder="${this.aiEnabled ? 'Commit message... (Ctrl+Shift+Enter writes one and commits)' : 'Commit message...'}" rows="3"></textarea>
           <div class="git-commit-actions-row">
+            ${this.aiEnabled ? `
+              <button class="git-suggest-btn" title="Write a commit message for the changes" ${this.isSuggesting ? 'disabled' : ''}>
+                ${this.isSuggesting ? 'Writing...' : 'Suggest'}
+              </button>
+            ` : ''}
             ${stagedFiles.length > 0 ? `
               <button class="git-commit-btn" data-action="commit">
                 <svg width="14" height="14" viewBox="0 0 16 16" fill="currentColor">
@@ -572,6 +663,13 @@ export class GitPanel {
                 <path d="M8 4a.75.75 0 01.75.75v5.69l1.72-1.72a.75.75 0 111.06 1.06l-3 3a.75.75 0 01-1.06 0l-3-3a.75.75 0 011.06-1.06l1.72 1.72V4.75A.75.75 0 018 4z"/>
               </svg>
             </button>
+            ${file.status === 'untracked' ? `
+              <button class="git-file-action" data-action="ignore" title="Add to .gitignore">
+                <svg width="12" height="12" viewBox="0 0 16 16" fill="currentColor">
+                  <path d="M8 1.5a6.5 6.5 0 100 13 6.5 6.5 0 000-13zM3 8a5 5 0 018.06-3.95l-7.01 7.01A4.98 4.98 0 013 8zm1.94 3.95l7.01-7.01a5 5 0 01-7.01 7.01z"/>
+                </svg>
+              </button>
+            ` : ''}
             <button class="git-file-action git-action-danger" data-action="discard" title="Discard changes">
               <svg width="12" height="12" viewBox="0 0 16 16" fill="currentColor">
                 <path d="M3.72 3.72a.75.75 0 011.06 0L8 6.94l3.22-3.22a.75.75 0 111.06 1.06L9.06 8l3.22 3.22a.75.75 0 11-1.06 1.06L8 9.06l-3.22 3.22a.75.75 0 01-1.06-1.06L6.94 8 3.72 4.78a.75.75 0 010-1.06z"/>
@@ -625,6 +723,14 @@ export class GitPanel {
     });
 
     // History tab actions
+    // Load the next page near the end of the list
+    this.container.querySelector('.git-history-list')?.addEventListener('scroll', (e) => {
+      const list = e.currentTarget as HTMLElement;
+      if (list.scrollTop + list.clientHeight >= list.scrollHeight - 100) {
+        this.loadMoreHistory();
+      }
+    });
+
     // Select commit checkboxes
     this.container.querySelectorAll('[data-action="select-commit"]').forEach(checkbox => {
       checkbox.addEventListener('change', (e) => {
@@ -676,9 +782,17 @@ export class GitPanel {
     this.container.querySelector('[data-action="close-diff"]')?.addEventListener('click', () => {
       this.showDiffViewer = false;
       this.diffResult = null;
+      this.diffHashes = null;
       this.render();
     });
 
+    // Reload a truncated diff without the size limits
+    this.container.querySelector('[data-action="load-full-diff"]')?.addEventListener('click', async () => {
+      if (this.diffHashes) {
+        await this.loadDiff(this.diffHashes[0], this.diffHashes[1], true);
+      }
+    });
+
     // View commit details button
     this.container.querySelectorAll('[data-action="view-commit"]').forEach(btn => {
       btn.addEventListener('click', async (e) => {
@@ -708,6 +822,10 @@ export class GitPanel {
       await this.handlePull();
     });
 
+    this.container.querySelector('[data-action="publish"]')?.addEventListener('click', async () => {
+      await this.handlePublish();
+    });
+
     // Create branch
     this.container.querySelector('[data-action="create-branch"]')?.addEventListener('click', async () => {
       await this.handleCreateBranch();
@@ -767,6 +885,9 @@ export class GitPanel {
           case 'discard':
             await this.handleDiscardFile(path);
             break;
+          case 'ignore':
+            await this.handleIgnoreFile(path);
+            break;
         }
       });
     });
@@ -801,9 +922,22 @@ export class GitPanel {
       });
     });
 
-    // Commit on Ctrl+Enter
+    this.container.querySelector('.git-suggest-btn')?.addEventListener('click', async () => {
+      await this.suggestCommitMessage();
+    });
+
+    // Commit on Ctrl+Enter; with Shift, have the language model write the
+    // message first
     this.container.querySelector('.git-commit-message')?.addEventListener('keydown', async (e) => {
       const event = e as KeyboardEvent;
+      if ((event.ctrlKey || event.metaKey) && event.shiftKey && event.key === 'Enter' && this.aiEnabled) {
+        event.preventDefault();
+        const message = await this.suggestCommitMessage();
+        if (message) {
+          await this.handleCommit(message);
+        }
+        return;
+      }
       if ((event.ctrlKey || event.metaKey) && event.key === 'Enter') {
         const textarea = e.target as HTMLTextAreaElement;
         const message = textarea?.value.trim();
@@ -858,6 +992,19 @@ export class GitPanel {
     }
   }
 
+  private async handleIgnoreFile(path: string): Promise<void> {
+    try {
+      const result = await api.addGitIgnore(['/' + path]);
+      this.updateStatus(result.status);
+      if (this.onStatusChange) {
+        this.onStatusChange(result.status);
+      }
+    } catch (err) {
+      console.error('Failed to ignore file:', err);
+      alert('Failed to ignore file: ' + (err as Error).message);
+    }
+  }
+
   private async handleStageAll(): Promise<void> {
     try {
       const result = await api.stageFiles([], true);
@@ -884,6 +1031,35 @@ export class GitPanel {
     }
   }
 
+  // Asks the language model for a message describing the staged changes,
+  // staging every change first when nothing is staged, and puts it in the
+  // message box
+  private async suggestCommitMessage(): Promise<string | null> {
+    if (this.isSuggesting) return null;
+    const draft = (this.container.querySelector('.git-commit-message') as HTMLTextAreaElement)?.value ?? '';
+    let message: string | null = null;
+    this.isSuggesting = true;
+    this.render();
+    try {
+      if (this.status && !this.status.files.some(f => f.staged)) {
+        const staged = await api.stageFiles([], true);
+        this.status = staged.status;
+      }
+      message = (await api.suggestCommitMessage()).message;
+    } catch (err) {
+      console.error('Failed to suggest a commit message:', err);
+      this.showNotification((err as Error).message, 'error');
+    } finally {
+      this.isSuggesting = false;
+      this.render();
+      const textarea = this.container.querySelector('.git-commit-message') as HTMLTextAreaElement;
+      if (textarea) {
+        textarea.value = message ?? draft;
+      }
+    }
+    return message;
+  }
+
   private async handleCommit(message: string): Promise<void> {
     try {
       const result = await api.commit(message);
@@ -1006,6 +1182,40 @@ export class GitPanel {
     }
   }
 
+  private async handlePublish(): Promise<void> {
+    if (this.publishStatus) return;
+
+    this.publishStatus = 'Rendering...';
+    this.render();
+
+    try {
+      const result = await api.publish({}, (progress) => {
+        switch (progress.stage) {
+          case 'rendering':
+            this.publishStatus = `Rendering ${progress.current}/${progress.total}`;
+            break;
+          case 'committing':
+            this.publishStatus = 'Committing...';
+            break;
+          case 'pushing':
+            this.publishStatus = 'Pushing...';
+            break;
+        }
+        this.render();
+      });
+      const message = result.commit
+        ? `Published ${result.files} files to ${result.branch}`
+        : `${result.branch} is already up to date`;
+      this.showNotification(message, 'success');
+    } catch (error) {
+      console.error('Publish failed:', error);
+      this.showNotification(error instanceof Error ? error.message : 'Publish failed', 'error');
+    } finally {
+      this.publishStatus = null;
+      this.render();
+    }
+  }
+
   private async handlePull(): Promise<void> {
     if (this.isPulling || !this.status) return;
     
@@ -1,10 +1,11 @@
 // Git status component for displaying branch and status in header
-import { api, GitStatus, GitStatusResponse } from './api';
+import { api, GitStatus, GitStatusResponse, SyncStatus } from './api';
 
 export class GitStatusComponent {
   private container: HTMLElement;
   private isRepo: boolean = false;
   private status: GitStatus | null = null;
+  private sync: SyncStatus | null = null;
   private onInitRepo: (() => void) | null = null;
   private onCloneRepo: (() => void) | null = null;
   private onTogglePanel: (() => void) | null = null;
@@ -31,6 +32,9 @@ export class GitStatusComponent {
       const response = await api.getGitStatus();
       this.isRepo = response.isRepo;
       this.status = response.status || null;
+      if (this.isRepo && !this.sync) {
+        this.sync = await api.getSyncStatus().catch(() => null);
+      }
       this.render();
     } catch (err) {
       console.error('Failed to get git status:', err);
@@ -46,6 +50,31 @@ export class GitStatusComponent {
     this.render();
   }
 
+  // Takes the ahead and behind counts from a sync, which are sent after
+  // every sync even when nothing changed
+  updateSync(sync: SyncStatus): void {
+    this.sync = sync;
+    if (this.status && (!sync.branch || sync.branch === this.status.branch)) {
+      this.status = { ...this.status, ahead: sync.ahead, behind: sync.behind };
+    }
+    this.render();
+  }
+
+  // Describes the last sync for the branch's tooltip
+  private syncTitle(): string {
+    const sync = this.sync;
+    if (!sync?.lastSync || sync.lastSync.startsWith('0001-')) {
+      return '';
+    }
+    let title = `Last synced ${new Date(sync.lastSync).toLocaleTimeString()}`;
+    if (sync.error) {
+      title += sync.paused ? ': the remote needs credentials' : `: ${sync.error}`;
+    } else if (sync.skipped) {
+      title += `; didn't pull because ${sync.skipped}`;
+    }
+    return title;
+  }
+
   private render(): void {
     if (!this.isRepo) {
       this.container.innerHTML = `
@@ -109,12 +138,17 @@ export class GitStatusComponent {
         </svg>
         <span class="git-branch-name">${branch}</span>
         ${syncIndicator}
+        ${this.sync?.error ? '<span class="git-status-indicator git-sync-error">⚠</span>' : ''}
         ${statusIndicator}
       </div>
     `;
 
     // Add click handler for toggling panel
-    const branchDiv = this.container.querySelector('.git-branch');
+    const branchDiv = this.container.querySelector<HTMLElement>('.git-branch');
+    const syncTitle = this.syncTitle();
+    if (branchDiv && syncTitle) {
+      branchDiv.title = `${syncTitle}\nClick to toggle Git panel`;
+    }
     branchDiv?.addEventListener('click', () => {
       if (this.onTogglePanel) {
         this.onTogglePanel();
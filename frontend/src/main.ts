@@ -1,12 +1,13 @@
 // Main application entry point
 
-import { api, DirectoryEntry, RecentLocation } from './api';
-import { ws, FileEvent } from './websocket';
+import { api, Bootstrap, DirectoryEntry, GitStatus, IndexStatus, RecentLocation, Session, Settings, SyncStatus } from './api';
+import { ws, FileEvent, HookResult } from './websocket';
 import { FileTree } from './filetree';
 import { MarkdownEditor } from './editor';
 import { MermaidRenderer } from './mermaid-renderer';
 import { GitStatusComponent } from './git-status';
 import { gitCloneDialog } from './git-clone';
+import { calendarDialog } from './calendar';
 import { GitPanel } from './git-panel';
 import './styles/main.css';
 
@@ -29,6 +30,9 @@ class InkwellApp {
   private markdownPanelOpen = false;
   private directoryPath = '';
   private recents: RecentLocation[] = [];
+  private defaultExtension = '.md';
+  private sessionTimer: number | null = null;
+  private scrollPositions: Map<string, number> = new Map();
 
   private elements = {
     sidebar: document.getElementById('sidebar')!,
@@ -50,6 +54,7 @@ class InkwellApp {
     confirmNewFile: document.getElementById('confirm-new-file')!,
     cancelNewFile: document.getElementById('cancel-new-file')!,
     openFolderBtn: document.getElementById('open-folder-btn')!,
+    calendarBtn: document.getElementById('calendar-btn')!,
     directoryModal: document.getElementById('directory-modal')!,
     directoryPathInput: document.getElementById('directory-path-input') as HTMLInputElement,
     directoryList: document.getElementById('directory-list')!,
@@ -70,12 +75,31 @@ class InkwellApp {
   };
 
   async init(): Promise<void> {
+    // Load everything needed for the first paint in one request
+    let boot: Bootstrap | null = null;
+    try {
+      boot = await api.getBootstrap();
+      for (const [part, message] of Object.entries(boot.errors ?? {})) {
+        console.error(`Failed to load ${part}:`, message);
+      }
+    } catch (e) {
+      console.error('Failed to load initial state:', e);
+    }
+
     // Load config
+    let startupFiles: string[] = [];
+    let startupActive = '';
+    let gitEnabled = true;
+    let aiEnabled = false;
     try {
-      const config = await api.getConfig();
+      const config = boot?.config ?? await api.getConfig();
       if (config.theme === 'dark') {
         this.setTheme('dark');
       }
+      startupFiles = config.initialFiles ?? [];
+      startupActive = config.activeFile ?? '';
+      gitEnabled = config.git !== false;
+      aiEnabled = config.ai === true;
     } catch (e) {
       console.error('Failed to load config:', e);
     }
@@ -86,9 +110,11 @@ class InkwellApp {
       onFileOpen: (path) => this.openFile(path),
       onFileDeleted: (path) => this.closeTab(path),
       onHeadingClick: (path, line) => this.scrollToHeading(path, line),
+      onExpandedChange: () => this.scheduleSessionSave(),
     });
 
-    await this.fileTree.load();
+    await this.fileTree.load(boot?.tree);
+    await this.restoreViewState(boot?.session);
 
     // Initialize editor
     this.editor = new MarkdownEditor(this.elements.editorEl, {
@@ -101,45 +127,79 @@ class InkwellApp {
 
     await this.editor.init();
 
+    // Apply saved preferences
+    try {
+      this.applySettings(boot?.settings ?? await api.getSettings());
+    } catch (e) {
+      console.error('Failed to load settings:', e);
+    }
+
     // Initialize Mermaid diagram renderer
     this.mermaidRenderer = new MermaidRenderer(this.elements.editorEl);
     this.mermaidRenderer.start();
 
-    // Initialize Git status component
-    this.gitStatus = new GitStatusComponent(this.elements.gitStatusContainer);
-    this.gitStatus.setOnInitRepo(() => this.handleGitRepoChange());
-    this.gitStatus.setOnCloneRepo(() => this.showCloneDialog());
-    this.gitStatus.setOnTogglePanel(() => this.gitPanel?.toggle());
-    await this.gitStatus.refresh();
-
-    // Initialize Git panel
-    this.gitPanel = new GitPanel(this.elements.gitPanelContainer);
-    this.gitPanel.setOnStatusChange((status) => {
-      if (this.gitStatus && status) {
-        this.gitStatus.update({ isRepo: true, status });
+    if (gitEnabled) {
+      // Initialize Git status component
+      this.gitStatus = new GitStatusComponent(this.elements.gitStatusContainer);
+      this.gitStatus.setOnInitRepo(() => this.handleGitRepoChange());
+      this.gitStatus.setOnCloneRepo(() => this.showCloneDialog());
+      this.gitStatus.setOnTogglePanel(() => this.gitPanel?.toggle());
+      if (boot?.git) {
+        this.gitStatus.update(boot.git);
+      } else {
+        await this.gitStatus.refresh();
+      }
+
+      // Initialize Git panel
+      this.gitPanel = new GitPanel(this.elements.gitPanelContainer);
+      this.gitPanel.setAIEnabled(aiEnabled);
+      this.gitPanel.setOnStatusChange((status) => {
+        if (this.gitStatus && status) {
+          this.gitStatus.update({ isRepo: true, status });
+        }
+      });
+      if (boot?.git) {
+        this.gitPanel.updateStatus(boot.git.status ?? null, boot.git.isRepo);
+      } else {
+        await this.gitPanel.refresh();
       }
-    });
-    await this.gitPanel.refresh();
+    } else {
+      this.elements.gitStatusContainer.hidden = true;
+      this.elements.gitPanelContainer.hidden = true;
+    }
 
     // Connect WebSocket
     ws.connect();
     ws.on('fileEvent', (event) => this.handleFileEvent(event as FileEvent));
     ws.on('connected', () => this.setStatus('Connected'));
     ws.on('disconnected', () => this.setStatus('Disconnected'));
+    ws.on('hookResult', (result) => this.handleHookResult(result as HookResult));
+    ws.on('settings', (settings) => this.applySettings(settings as Settings));
+    ws.on('indexStatus', (status) => this.handleIndexStatus(status as IndexStatus));
+    ws.on('gitStatus', (status) => {
+      // Sent after a sync changed the repository
+      this.gitPanel?.updateStatus(status as GitStatus);
+      this.gitStatus?.update({ isRepo: true, status: status as GitStatus });
+    });
+    ws.on('syncStatus', (sync) => this.gitStatus?.updateSync(sync as SyncStatus));
 
     // Setup event listeners
     this.setupEventListeners();
 
-    // Check for initial file from URL
+    // Open files from the URL, or those given on the command line or
+    // restored from the last session
     const params = new URLSearchParams(window.location.search);
-    const initialFile = params.get('file');
-    if (initialFile) {
-      this.openFile(initialFile);
+    const urlFiles = params.getAll('file');
+    if (urlFiles.length > 0) {
+      await this.openFiles(urlFiles, urlFiles[0]);
+    } else if (startupFiles.length > 0) {
+      await this.openFiles(startupFiles, startupActive);
     }
 
     // Check for recents and show startup modal if available
     try {
-      this.recents = await api.getRecents();
+      const start = boot?.start ?? await api.getStart();
+      this.recents = [...start.pinned, ...start.recents];
       if (this.recents.length > 1) {
         // Only show if there are multiple recent locations to choose from
         this.showStartupModal();
@@ -174,6 +234,7 @@ class InkwellApp {
         const theme = (card as HTMLElement).dataset.theme!;
         this.setTheme(theme);
         this.updateThemeCards();
+        api.updateSettings({ theme }).catch((e) => console.error('Failed to save theme:', e));
       });
     });
 
@@ -187,6 +248,11 @@ class InkwellApp {
       }
     });
 
+    // Calendar of dated notes
+    this.elements.calendarBtn.addEventListener('click', () => {
+      calendarDialog.show((path) => this.openFile(path));
+    });
+
     // New file button
     this.elements.newFileBtn.addEventListener('click', () => {
       this.showNewFileModal();
@@ -266,6 +332,14 @@ class InkwellApp {
       });
     });
 
+    // Remember how far each note is scrolled
+    this.elements.editorWrapper.addEventListener('scroll', () => {
+      if (this.activeTab) {
+        this.scrollPositions.set(this.activeTab, this.elements.editorWrapper.scrollTop);
+        this.scheduleSessionSave();
+      }
+    }, { passive: true });
+
     // Close markdown panel when clicking into editor
     this.elements.editorWrapper.addEventListener('click', () => {
       if (this.markdownPanelOpen) {
@@ -327,6 +401,54 @@ class InkwellApp {
     this.switchToTab(path);
   }
 
+  private async openFiles(paths: string[], active: string): Promise<void> {
+    for (const path of paths) {
+      if (!this.tabs.some(t => t.path === path)) {
+        this.tabs.push({ path, name: path.split('/').pop() || path, dirty: false });
+      }
+    }
+    await this.switchToTab(active || paths[0]);
+  }
+
+  // Restore the expanded folders and scroll positions saved for the vault
+  private async restoreViewState(loaded?: Session): Promise<void> {
+    try {
+      const session = loaded ?? await api.getSession();
+      if (session.expanded && session.expanded.length > 0) {
+        this.fileTree?.setExpandedDirs(session.expanded);
+      }
+      this.scrollPositions = new Map(Object.entries(session.scroll ?? {}));
+    } catch (e) {
+      console.error('Failed to load session:', e);
+    }
+  }
+
+  private scheduleSessionSave(): void {
+    if (this.sessionTimer !== null) {
+      clearTimeout(this.sessionTimer);
+    }
+    this.sessionTimer = window.setTimeout(() => this.saveSession(), 1000);
+  }
+
+  private async saveSession(): Promise<void> {
+    if (this.sessionTimer !== null) {
+      clearTimeout(this.sessionTimer);
+      this.sessionTimer = null;
+    }
+    try {
+      await api.saveSession({
+        files: this.tabs.map(t => t.path),
+        active: this.activeTab ?? undefined,
+        expanded: this.fileTree?.getExpandedDirs(),
+        scroll: Object.fromEntries(
+          [...this.scrollPositions].filter(([path]) => this.tabs.some(t => t.path === path))
+        ),
+      });
+    } catch (e) {
+      console.error('Failed to save session:', e);
+    }
+  }
+
   private async switchToTab(path: string): Promise<void> {
     // Save current editor state
     if (this.activeTab && this.editor) {
@@ -336,6 +458,7 @@ class InkwellApp {
     this.activeTab = path;
     this.fileTree?.setActiveFile(path);
     this.renderTabs();
+    this.scheduleSessionSave();
 
     // Show editor
     this.elements.emptyState.classList.add('hidden');
@@ -353,6 +476,7 @@ class InkwellApp {
     this.updateWordCount();
     this.updateOutline();
     this.editor?.focus();
+    this.elements.editorWrapper.scrollTop = this.scrollPositions.get(path) ?? 0;
   }
 
   private closeTab(path: string): void {
@@ -371,6 +495,7 @@ class InkwellApp {
     // Remove tab
     this.tabs.splice(index, 1);
     this.editors.delete(path);
+    this.scrollPositions.delete(path);
 
     // Switch to another tab or show empty state
     if (this.activeTab === path) {
@@ -386,6 +511,7 @@ class InkwellApp {
     }
 
     this.renderTabs();
+    this.scheduleSessionSave();
   }
 
   private renderTabs(): void {
@@ -431,6 +557,33 @@ class InkwellApp {
     }
   }
 
+  private handleHookResult(result: HookResult): void {
+    if (result.error) {
+      console.warn(`On-save command failed: ${result.command}\n${result.output}`);
+      this.setStatus(`Hook failed: ${result.error}`);
+    } else {
+      this.setStatus(`Hook finished in ${result.durationMs}ms`);
+    }
+  }
+
+  private handleIndexStatus(status: IndexStatus): void {
+    if (status.state === 'indexing') {
+      this.setStatus(`Indexing ${status.percent}%`);
+    } else if (status.state === 'ready') {
+      this.setStatus('Ready');
+    }
+  }
+
+  private applySettings(settings: Settings): void {
+    if (settings.theme) {
+      this.setTheme(settings.theme);
+      this.updateThemeCards();
+    }
+    this.elements.editorEl.style.fontSize = `${settings.fontSize}px`;
+    this.editor?.setAutosaveInterval(settings.autosaveInterval);
+    this.defaultExtension = settings.defaultExtension;
+  }
+
   private handleFileEvent(event: FileEvent): void {
     console.log('File event:', event);
 
@@ -501,13 +654,14 @@ class InkwellApp {
     let name = this.elements.newFileName.value.trim();
     if (!name) return;
 
-    // Add .md extension if not present
-    if (!name.toLowerCase().endsWith('.md')) {
-      name += '.md';
+    // Add the default extension if not present
+    const ext = this.defaultExtension;
+    if (!name.toLowerCase().endsWith(ext)) {
+      name += ext;
     }
 
     try {
-      await api.createFile(name, `# ${name.replace('.md', '')}\n\n`);
+      await api.createFile(name, `# ${name.slice(0, -ext.length)}\n\n`);
       this.hideNewFileModal();
       await this.fileTree?.refresh();
       this.openFile(name);
@@ -535,6 +689,24 @@ class InkwellApp {
     return div.innerHTML;
   }
 
+  private describeRecent(recent: RecentLocation): string {
+    if (recent.exists === false) return 'Missing';
+    const parts: string[] = [];
+    if (recent.notes !== undefined) {
+      parts.push(`${recent.notes} ${recent.notes === 1 ? 'note' : 'notes'}`);
+    }
+    if (recent.git) {
+      parts.push((recent.branch || 'git') + (recent.dirty ? ' (modified)' : ''));
+    }
+    if (recent.ahead || recent.behind) {
+      parts.push(`↑${recent.ahead || 0} ↓${recent.behind || 0}`);
+    }
+    if (recent.lastFile) {
+      parts.push(recent.lastFile);
+    }
+    return parts.join(' · ');
+  }
+
   private escapeAttr(text: string): string {
     return text.replace(/"/g, '&quot;');
   }
@@ -579,7 +751,7 @@ class InkwellApp {
     // Show recents section if we have recents and we're at the initial view
     if (this.recents.length > 0) {
       html += '<div class="recents-section">';
-      html += '<div class="recents-header">Recent Locations</div>';
+      html += '<div class="recents-header">Recent Locations<button class="recents-clear">Clear</button></div>';
       for (const recent of this.recents) {
         html += `
           <div class="directory-item recent" data-path="${this.escapeAttr(recent.path)}" data-recent="true">
@@ -590,7 +762,10 @@ class InkwellApp {
             <div class="recent-info">
               <span class="recent-name">${this.escapeHtml(recent.name)}</span>
               <span class="recent-path">${this.escapeHtml(recent.path)}</span>
+              <span class="recent-meta">${this.escapeHtml(this.describeRecent(recent))}</span>
             </div>
+            <button class="recent-pin${recent.pinned ? ' pinned' : ''}" title="${recent.pinned ? 'Unpin' : 'Pin'}">&#128204;</button>
+            <button class="recent-remove" title="Remove from recents">&times;</button>
           </div>
         `;
       }
@@ -659,6 +834,37 @@ class InkwellApp {
         this.elements.directoryPathInput.value = path;
         await this.openDirectory();
       });
+
+      el.querySelector('.recent-pin')?.addEventListener('click', async (e) => {
+        e.stopPropagation();
+        const path = (el as HTMLElement).dataset.path!;
+        const recent = this.recents.find(r => r.path === path);
+        try {
+          this.recents = await api.pinRecent(path, !recent?.pinned);
+          this.renderDirectoryList(directories, parent);
+        } catch (error) {
+          console.error('Failed to pin recent location:', error);
+        }
+      });
+
+      el.querySelector('.recent-remove')?.addEventListener('click', async (e) => {
+        e.stopPropagation();
+        try {
+          this.recents = await api.removeRecent((el as HTMLElement).dataset.path!);
+          this.renderDirectoryList(directories, parent);
+        } catch (error) {
+          console.error('Failed to remove recent location:', error);
+        }
+      });
+    });
+
+    this.elements.directoryList.querySelector('.recents-clear')?.addEventListener('click', async () => {
+      try {
+        this.recents = await api.clearRecents();
+        this.renderDirectoryList(directories, parent);
+      } catch (error) {
+        console.error('Failed to clear recent locations:', error);
+      }
     });
   }
 
@@ -667,7 +873,11 @@ class InkwellApp {
     if (!path) return;
 
     try {
-      await api.changeDirectory(path);
+      // Record this vault's tabs before they are closed
+      if (this.sessionTimer !== null) {
+        await this.saveSession();
+      }
+      const result = await api.changeDirectory(path);
       this.hideDirectoryModal();
 
       // Close all tabs
@@ -683,8 +893,14 @@ class InkwellApp {
 
       // Refresh file tree and git status
       await this.fileTree?.load();
+      await this.restoreViewState();
       await this.gitStatus?.refresh();
       await this.gitPanel?.refresh();
+
+      // Resume at the file last opened in this directory
+      if (result.lastFile) {
+        await this.openFile(result.lastFile);
+      }
       this.setStatus('Opened: ' + path);
     } catch (error) {
       alert('Failed to open directory: ' + (error as Error).message);
@@ -7,6 +7,29 @@ interface FileEvent {
   eventType: string;
 }
 
+interface SaveConflict {
+  path: string;
+  content: string;
+  version: string;
+}
+
+interface FileRemoved {
+  path: string;
+  eventType: string;
+  content: string;
+  restorable: boolean;
+}
+
+interface HookResult {
+  command: string;
+  path: string;
+  exitCode: number;
+  output: string;
+  error?: string;
+  durationMs: number;
+  timedOut?: boolean;
+}
+
 class WebSocketClient {
   private ws: WebSocket | null = null;
   private listeners: Map<string, EventCallback[]> = new Map();
@@ -42,8 +65,10 @@ class WebSocketClient {
 
     this.ws.onmessage = (event) => {
       try {
-        const message = JSON.parse(event.data);
-        this.handleMessage(message);
+        // Broadcasts close together arrive as one array
+        const parsed = JSON.parse(event.data);
+        const messages = Array.isArray(parsed) ? parsed : [parsed];
+        messages.forEach((message) => this.handleMessage(message));
       } catch (e) {
         console.error('Failed to parse WebSocket message:', e);
       }
@@ -67,17 +92,56 @@ class WebSocketClient {
     }, delay);
   }
 
-  private handleMessage(message: { type: string; path?: string; content?: string; data?: { eventType?: string } }): void {
+  private handleMessage(message: { type: string; path?: string; content?: string; version?: string; data?: { eventType?: string } | HookResult | Record<string, unknown> }): void {
     switch (message.type) {
       case 'fileEvent':
         this.emit('fileEvent', {
           path: message.path,
-          eventType: message.data?.eventType,
+          eventType: (message.data as { eventType?: string } | undefined)?.eventType,
         } as FileEvent);
         break;
 
+      case 'fileRemoved': {
+        const data = message.data as { eventType?: string; restorable?: boolean } | undefined;
+        this.emit('fileRemoved', {
+          path: message.path,
+          eventType: data?.eventType,
+          content: message.content ?? '',
+          restorable: data?.restorable ?? false,
+        } as FileRemoved);
+        break;
+      }
+
+      case 'hookResult':
+        this.emit('hookResult', message.data as HookResult);
+        break;
+
+      case 'settings':
+        this.emit('settings', message.data);
+        break;
+
+      case 'indexStatus':
+        this.emit('indexStatus', message.data);
+        break;
+
+      case 'gitStatus':
+        this.emit('gitStatus', message.data);
+        break;
+
+      case 'syncStatus':
+        this.emit('syncStatus', message.data);
+        break;
+
       case 'saved':
-        this.emit('saved', { path: message.path });
+        this.emit('saved', { path: message.path, version: message.version });
+        break;
+
+      case 'saveConflict':
+        this.emit('saveConflict', {
+          path: message.path,
+          content: message.content ?? '',
+          version: message.version,
+        } as SaveConflict);
         break;
 
       case 'error':
@@ -106,8 +170,11 @@ class WebSocketClient {
     this.send('unsubscribe', { path });
   }
 
-  save(path: string, content: string): void {
-    this.send('save', { path, content });
+  // Saves a note; with the version it was edited from, a newer note on
+  // disk is reported as a saveConflict instead of overwritten. Wait for
+  // 'saved' and send its version with the next save.
+  save(path: string, content: string, version?: string): void {
+    this.send('save', { path, content, version });
   }
 
   on(event: string, callback: EventCallback): void {
@@ -143,4 +210,4 @@ class WebSocketClient {
 }
 
 export const ws = new WebSocketClient();
-export type { FileEvent };
+export type { FileEvent, FileRemoved, SaveConflict, HookResult };
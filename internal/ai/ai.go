@@ -0,0 +1,190 @@
+// Package ai asks a language model for help with writing, such as drafting
+// a commit message from a diff or summarizing a note. The model is served
+// by an OpenAI-compatible chat API or a local Ollama.
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Supported providers
+const (
+	ProviderOpenAI = "openai" // Any OpenAI-compatible chat completions API
+	ProviderOllama = "ollama" // A local Ollama server
+)
+
+// MaxInput bounds the text sent to the model; longer input is cut short
+const MaxInput = 32 * 1024
+
+const (
+	keyEnv        = "INKWELL_AI_KEY"
+	openAIKeyEnv  = "OPENAI_API_KEY"
+	maxReplyBytes = 1 << 20
+)
+
+// defaults holds the URL and model used when none is configured
+var defaults = map[string]struct{ url, model string }{
+	ProviderOpenAI: {"https://api.openai.com/v1", "gpt-4o-mini"},
+	ProviderOllama: {"http://localhost:11434", "llama3.2"},
+}
+
+// Provider completes a prompt with a language model
+type Provider interface {
+	// Complete returns the model's reply to prompt, following the
+	// instructions in system
+	Complete(ctx context.Context, system, prompt string) (string, error)
+	// Name describes the provider and model, such as "ollama/llama3.2"
+	Name() string
+}
+
+// New returns the provider for name, using its default URL and model when
+// baseURL or model are empty. The OpenAI API key comes from INKWELL_AI_KEY
+// or OPENAI_API_KEY.
+func New(name, baseURL, model string) (Provider, error) {
+	d, ok := defaults[name]
+	if !ok {
+		return nil, fmt.Errorf("unsupported AI provider %q (use openai or ollama)", name)
+	}
+	if baseURL == "" {
+		baseURL = d.url
+	}
+	if model == "" {
+		model = d.model
+	}
+	c := client{
+		name:    name,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		model:   model,
+		http:    &http.Client{Timeout: 2 * time.Minute},
+	}
+
+	if name == ProviderOllama {
+		return &ollama{c}, nil
+	}
+	c.key = os.Getenv(keyEnv)
+	if c.key == "" {
+		c.key = os.Getenv(openAIKeyEnv)
+	}
+	return &openAI{c}, nil
+}
+
+type client struct {
+	name    string
+	baseURL string
+	model   string
+	key     string
+	http    *http.Client
+}
+
+func (c *client) Name() string {
+	return c.name + "/" + c.model
+}
+
+type message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// post sends body as JSON to path and decodes the reply into out
+func (c *client) post(ctx context.Context, path string, body, out interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.key != "" {
+		req.Header.Set("Authorization", "Bearer "+c.key)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	reply, err := io.ReadAll(io.LimitReader(resp.Body, maxReplyBytes))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", resp.Status, errorMessage(reply))
+	}
+	if err := json.Unmarshal(reply, out); err != nil {
+		return fmt.Errorf("invalid reply from %s: %w", c.name, err)
+	}
+	return nil
+}
+
+// errorMessage pulls the message out of an error reply from either API
+func errorMessage(reply []byte) string {
+	var e struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if json.Unmarshal(reply, &e) == nil && len(e.Error) > 0 {
+		var detail struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(e.Error, &detail) == nil && detail.Message != "" {
+			return detail.Message
+		}
+		var s string
+		if json.Unmarshal(e.Error, &s) == nil && s != "" {
+			return s
+		}
+	}
+	return strings.TrimSpace(string(reply))
+}
+
+// openAI uses the chat completions API
+type openAI struct{ client }
+
+func (p *openAI) Complete(ctx context.Context, system, prompt string) (string, error) {
+	var reply struct {
+		Choices []struct {
+			Message message `json:"message"`
+		} `json:"choices"`
+	}
+	err := p.post(ctx, "/chat/completions", map[string]interface{}{
+		"model":       p.model,
+		"messages":    []message{{"system", system}, {"user", prompt}},
+		"temperature": 0.2,
+	}, &reply)
+	if err != nil {
+		return "", err
+	}
+	if len(reply.Choices) == 0 {
+		return "", errors.New("the model returned no reply")
+	}
+	return reply.Choices[0].Message.Content, nil
+}
+
+// ollama uses Ollama's chat API
+type ollama struct{ client }
+
+func (p *ollama) Complete(ctx context.Context, system, prompt string) (string, error) {
+	var reply struct {
+		Message message `json:"message"`
+	}
+	err := p.post(ctx, "/api/chat", map[string]interface{}{
+		"model":    p.model,
+		"messages": []message{{"system", system}, {"user", prompt}},
+		"stream":   false,
+		"options":  map[string]interface{}{"temperature": 0.2},
+	}, &reply)
+	if err != nil {
+		return "", err
+	}
+	return reply.Message.Content, nil
+}
@@ -0,0 +1,101 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestOpenAI(t *testing.T) {
+	t.Setenv(keyEnv, "secret")
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":{"message":"bad key"}}`))
+			return
+		}
+		var req struct {
+			Model    string    `json:"model"`
+			Messages []message `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Model != "small" || len(req.Messages) != 2 || req.Messages[0].Role != "system" {
+			t.Errorf("Unexpected request %+v", req)
+		}
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"\"Fix typo in notes\""}}]}`))
+	}))
+	defer srv.Close()
+
+	p, err := New(ProviderOpenAI, srv.URL+"/v1/", "small")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name() != "openai/small" {
+		t.Errorf("Unexpected name %q", p.Name())
+	}
+	msg, err := CommitMessage(context.Background(), p, "--- a/x.md\n+++ b/x.md\n-teh\n+the\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg != "Fix typo in notes" {
+		t.Errorf("Expected quotes stripped, got %q", msg)
+	}
+
+	t.Setenv(keyEnv, "wrong")
+	p, _ = New(ProviderOpenAI, srv.URL+"/v1", "small")
+	if _, err := Summarize(context.Background(), p, "note"); err == nil || !strings.Contains(err.Error(), "bad key") {
+		t.Errorf("Expected the API's error message, got %v", err)
+	}
+}
+
+func TestOllama(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		w.Write([]byte(`{"message":{"role":"assistant","content":"  - one\n  - two  "}}`))
+	}))
+	defer srv.Close()
+
+	p, err := New(ProviderOllama, srv.URL, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name() != "ollama/llama3.2" {
+		t.Errorf("Expected the default model, got %q", p.Name())
+	}
+	summary, err := Summarize(context.Background(), p, "# Note")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary != "- one\n  - two" {
+		t.Errorf("Unexpected summary %q", summary)
+	}
+	if _, err := Summarize(context.Background(), p, "  \n"); err != ErrEmpty {
+		t.Errorf("Expected ErrEmpty, got %v", err)
+	}
+}
+
+func TestNewUnsupported(t *testing.T) {
+	if _, err := New("claude", "", ""); err == nil {
+		t.Error("Expected an unsupported provider to fail")
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	long := strings.Repeat("line of text\n", MaxInput/10)
+	cut := truncate(long)
+	if len(cut) > MaxInput+20 || !strings.HasSuffix(cut, "[truncated]\n") {
+		t.Errorf("Expected a cut at MaxInput, got %d bytes", len(cut))
+	}
+	if truncate("short") != "short" {
+		t.Error("Expected short text unchanged")
+	}
+}
@@ -0,0 +1,76 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
+
+const commitSystem = `You write git commit messages for changes to a folder of Markdown notes.
+Reply with only the commit message: a summary line of at most 72 characters
+in the imperative mood, then, only if the change needs explaining, a blank
+line and a short body. No quotes, no Markdown formatting.`
+
+const summarySystem = `You summarize notes. Reply with only the summary: a few
+sentences or a short bulleted list in Markdown, in the language of the note.`
+
+// ErrEmpty is returned when there is nothing to send to the model
+var ErrEmpty = errors.New("nothing to send to the model")
+
+// CommitMessage drafts a commit message for a diff
+func CommitMessage(ctx context.Context, p Provider, diff string) (string, error) {
+	if strings.TrimSpace(diff) == "" {
+		return "", ErrEmpty
+	}
+	reply, err := p.Complete(ctx, commitSystem, "Write a commit message for this diff:\n\n"+truncate(diff))
+	if err != nil {
+		return "", err
+	}
+	return clean(reply), nil
+}
+
+// Summarize summarizes a note
+func Summarize(ctx context.Context, p Provider, note string) (string, error) {
+	if strings.TrimSpace(note) == "" {
+		return "", ErrEmpty
+	}
+	reply, err := p.Complete(ctx, summarySystem, "Summarize this note:\n\n"+truncate(note))
+	if err != nil {
+		return "", err
+	}
+	return clean(reply), nil
+}
+
+// truncate cuts text to MaxInput bytes on a line break where possible
+func truncate(text string) string {
+	if len(text) <= MaxInput {
+		return text
+	}
+	cut := text[:MaxInput]
+	if i := strings.LastIndexByte(cut, '\n'); i > MaxInput/2 {
+		cut = cut[:i+1]
+	}
+	for !utf8.ValidString(cut) {
+		cut = cut[:len(cut)-1]
+	}
+	return cut + "\n[truncated]\n"
+}
+
+// clean strips the code fences and quotes models like to wrap replies in
+func clean(reply string) string {
+	reply = strings.TrimSpace(reply)
+	if strings.HasPrefix(reply, "```") && strings.HasSuffix(reply, "```") && len(reply) >= 6 {
+		reply = strings.TrimSuffix(reply, "```")
+		if i := strings.IndexByte(reply, '\n'); i >= 0 {
+			reply = reply[i+1:]
+		} else {
+			reply = strings.TrimPrefix(reply, "```")
+		}
+		reply = strings.TrimSpace(reply)
+	}
+	if len(reply) >= 2 && reply[0] == '"' && reply[len(reply)-1] == '"' && !strings.Contains(reply[1:len(reply)-1], `"`) {
+		reply = reply[1 : len(reply)-1]
+	}
+	return strings.TrimSpace(reply)
+}
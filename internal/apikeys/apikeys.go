@@ -0,0 +1,271 @@
+// Package apikeys manages scoped API keys for scripted access to Inkwell
+package apikeys
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	inkwellDir  = ".inkwell"
+	keysFile    = "apikeys.json"
+	tokenPrefix = "ink_"
+	prefixLen   = len(tokenPrefix) + 6 // Shown in listings to identify a key
+
+	// lastUsedInterval is how stale a key's recorded last use may get, so
+	// busy keys don't write the key file on every request
+	lastUsedInterval = time.Minute
+)
+
+// Scope limits what a key may access
+type Scope string
+
+const (
+	ScopeRead  Scope = "read"  // Read-only access to files and git
+	ScopeFiles Scope = "files" // Read and write files
+	ScopeGit   Scope = "git"   // Read and run git operations
+	ScopeAdmin Scope = "admin" // Everything, including key management
+)
+
+// ErrInvalidKey is returned when a token does not match any key
+var ErrInvalidKey = errors.New("invalid API key")
+
+// ErrKeyNotFound is returned when revoking an unknown key
+var ErrKeyNotFound = errors.New("API key not found")
+
+// Key is a stored API key. Only the hash of the token is kept.
+type Key struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Prefix    string     `json:"prefix"`
+	Hash      string     `json:"hash"`
+	Scopes    []Scope    `json:"scopes"`
+	CreatedAt time.Time  `json:"createdAt"`
+	LastUsed  *time.Time `json:"lastUsed,omitempty"`
+}
+
+// HasScope reports whether the key was granted a scope. Admin keys have
+// every scope.
+func (k *Key) HasScope(scope Scope) bool {
+	for _, s := range k.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// Manager handles API key storage and verification
+type Manager struct {
+	mu       sync.RWMutex
+	keys     []Key
+	filePath string
+
+	// saveMu makes saves take turns, so an older list of keys is never
+	// written over a newer one
+	saveMu sync.Mutex
+}
+
+// New creates a new API key manager
+func New() (*Manager, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+
+	inkwellPath := filepath.Join(home, inkwellDir)
+	if err := os.MkdirAll(inkwellPath, 0755); err != nil {
+		return nil, err
+	}
+
+	m := &Manager{
+		filePath: filepath.Join(inkwellPath, keysFile),
+		keys:     make([]Key, 0),
+	}
+
+	if err := m.load(); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to load API keys: %w", err)
+	}
+
+	return m, nil
+}
+
+// load reads keys from disk
+func (m *Manager) load() error {
+	data, err := os.ReadFile(m.filePath)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return json.Unmarshal(data, &m.keys)
+}
+
+// save writes keys to disk atomically, readable only by the owner
+func (m *Manager) save() error {
+	m.saveMu.Lock()
+	defer m.saveMu.Unlock()
+
+	m.mu.RLock()
+	data, err := json.MarshalIndent(m.keys, "", "  ")
+	m.mu.RUnlock()
+
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(m.filePath), keysFile+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), m.filePath)
+}
+
+// ParseScopes validates scope names
+func ParseScopes(names []string) ([]Scope, error) {
+	if len(names) == 0 {
+		return nil, errors.New("at least one scope is required")
+	}
+
+	scopes := make([]Scope, 0, len(names))
+	for _, name := range names {
+		switch scope := Scope(strings.TrimSpace(name)); scope {
+		case ScopeRead, ScopeFiles, ScopeGit, ScopeAdmin:
+			scopes = append(scopes, scope)
+		default:
+			return nil, fmt.Errorf("unknown scope: %s", name)
+		}
+	}
+	return scopes, nil
+}
+
+// Create generates a new key and returns it along with the plaintext
+// token, which cannot be recovered later
+func (m *Manager) Create(name string, scopes []Scope) (Key, string, error) {
+	if len(scopes) == 0 {
+		return Key{}, "", errors.New("at least one scope is required")
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return Key{}, "", fmt.Errorf("failed to generate key: %w", err)
+	}
+	token := tokenPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	key := Key{
+		ID:        uuid.NewString(),
+		Name:      name,
+		Prefix:    token[:prefixLen],
+		Hash:      hashToken(token),
+		Scopes:    scopes,
+		CreatedAt: time.Now(),
+	}
+
+	m.mu.Lock()
+	m.keys = append(m.keys, key)
+	m.mu.Unlock()
+
+	if err := m.save(); err != nil {
+		m.remove(key.ID)
+		return Key{}, "", fmt.Errorf("failed to save API key: %w", err)
+	}
+
+	return key, token, nil
+}
+
+// Revoke deletes a key by ID
+func (m *Manager) Revoke(id string) error {
+	if !m.remove(id) {
+		return ErrKeyNotFound
+	}
+	return m.save()
+}
+
+// remove deletes a key from memory, reporting whether it existed
+func (m *Manager) remove(id string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, key := range m.keys {
+		if key.ID == id {
+			m.keys = append(m.keys[:i], m.keys[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// List returns all keys
+func (m *Manager) List() []Key {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make([]Key, len(m.keys))
+	copy(result, m.keys)
+	return result
+}
+
+// Authenticate returns the key matching a plaintext token, recording when
+// it was last used
+func (m *Manager) Authenticate(token string) (*Key, error) {
+	if !strings.HasPrefix(token, tokenPrefix) {
+		return nil, ErrInvalidKey
+	}
+	hash := []byte(hashToken(token))
+
+	m.mu.Lock()
+	for i := range m.keys {
+		if subtle.ConstantTimeCompare(hash, []byte(m.keys[i].Hash)) == 1 {
+			now := time.Now()
+			stale := m.keys[i].LastUsed == nil || now.Sub(*m.keys[i].LastUsed) >= lastUsedInterval
+			if stale {
+				m.keys[i].LastUsed = &now
+			}
+			key := m.keys[i]
+			m.mu.Unlock()
+
+			if stale {
+				// Only a record; failing to save it mustn't refuse the key
+				m.save()
+			}
+			return &key, nil
+		}
+	}
+	m.mu.Unlock()
+	return nil, ErrInvalidKey
+}
+
+// hashToken returns the stored form of a token
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,95 @@
+package apikeys
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCreateAndAuthenticate(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	m, err := New()
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	key, token, err := m.Create("ci", []Scope{ScopeRead})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	if !strings.HasPrefix(token, tokenPrefix) || !strings.HasPrefix(token, key.Prefix) {
+		t.Errorf("Unexpected token %q for prefix %q", token, key.Prefix)
+	}
+
+	// The plaintext token must never be written to disk
+	data, err := os.ReadFile(m.filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), token) {
+		t.Error("Token stored in plaintext")
+	}
+
+	// Keys survive a reload
+	reloaded, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := reloaded.Authenticate(token)
+	if err != nil {
+		t.Fatalf("Authenticate failed: %v", err)
+	}
+	if got.ID != key.ID || !got.HasScope(ScopeRead) || got.HasScope(ScopeGit) {
+		t.Errorf("Unexpected key: %+v", got)
+	}
+
+	if _, err := reloaded.Authenticate(token + "x"); err != ErrInvalidKey {
+		t.Errorf("Expected ErrInvalidKey, got %v", err)
+	}
+
+	// So does when they were last used
+	again, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys := again.List(); len(keys) != 1 || keys[0].LastUsed == nil {
+		t.Errorf("Expected the last use saved, got %+v", keys)
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	m, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, token, err := m.Create("cron", []Scope{ScopeAdmin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Revoke(key.ID); err != nil {
+		t.Fatalf("Revoke failed: %v", err)
+	}
+	if _, err := m.Authenticate(token); err != ErrInvalidKey {
+		t.Errorf("Revoked key still authenticates: %v", err)
+	}
+	if err := m.Revoke(key.ID); err != ErrKeyNotFound {
+		t.Errorf("Expected ErrKeyNotFound, got %v", err)
+	}
+}
+
+func TestParseScopes(t *testing.T) {
+	if _, err := ParseScopes([]string{"read", "git"}); err != nil {
+		t.Errorf("ParseScopes failed: %v", err)
+	}
+	if _, err := ParseScopes([]string{"write"}); err == nil {
+		t.Error("Expected error for unknown scope")
+	}
+	if _, err := ParseScopes(nil); err == nil {
+		t.Error("Expected error for no scopes")
+	}
+}
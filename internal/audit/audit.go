@@ -0,0 +1,189 @@
+// Package audit records mutating operations to an append-only log
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	inkwellDir = ".inkwell"
+	auditFile  = "audit.log"
+)
+
+// Actions recorded in the log
+const (
+	ActionFileCreate      = "file.create"
+	ActionFileWrite       = "file.write"
+	ActionFileDelete      = "file.delete"
+	ActionFileRestore     = "file.restore"
+	ActionImageUpload     = "image.upload"
+	ActionFileUpload      = "file.upload"
+	ActionUploadRejected  = "upload.rejected"
+	ActionZipImport       = "zip.import"
+	ActionAssetMigrate    = "asset.migrate"
+	ActionDirectoryChange = "directory.change"
+	ActionVaultInit       = "vault.init"
+	ActionAPIKeyCreate    = "apikey.create"
+	ActionAPIKeyRevoke    = "apikey.revoke"
+	ActionUserCreate      = "user.create"
+	ActionUserUpdate      = "user.update"
+	ActionUserDelete      = "user.delete"
+	ActionSettingsChange  = "settings.change"
+	ActionAccessChange    = "access.change"
+	ActionGitInit         = "git.init"
+	ActionGitClone        = "git.clone"
+	ActionGitStage        = "git.stage"
+	ActionGitUnstage      = "git.unstage"
+	ActionGitCommit       = "git.commit"
+	ActionGitDiscard      = "git.discard"
+	ActionGitResolve      = "git.resolve"
+	ActionGitPush         = "git.push"
+	ActionGitPull         = "git.pull"
+	ActionGitSync         = "git.sync"
+	ActionGitCheckout     = "git.checkout"
+	ActionGitBranch       = "git.branch"
+	ActionGitTag          = "git.tag"
+	ActionGitRemote       = "git.remote"
+	ActionGitRevert       = "git.revert"
+	ActionGitCherryPick   = "git.cherrypick"
+	ActionGitSignIn       = "git.signin"
+	ActionGitSignOut      = "git.signout"
+	ActionSecretCreate    = "secret.create"
+	ActionSecretUpdate    = "secret.update"
+	ActionSecretDelete    = "secret.delete"
+	ActionBackup          = "backup.run"
+)
+
+// Entry is a single audit record
+type Entry struct {
+	Time    time.Time `json:"time"`
+	Actor   string    `json:"actor"`
+	Action  string    `json:"action"`
+	Path    string    `json:"path,omitempty"`
+	Summary string    `json:"summary,omitempty"`
+}
+
+// Filter selects entries when reading the log
+type Filter struct {
+	Action string    // Exact action, or empty for all
+	Actor  string    // Exact actor, or empty for all
+	Path   string    // Exact path, or empty for all
+	Since  time.Time // Only entries at or after this time
+	Limit  int       // Maximum entries returned, 0 for no limit
+}
+
+// Log is an append-only JSON lines audit log
+type Log struct {
+	mu       sync.Mutex
+	filePath string
+}
+
+// New opens the audit log under ~/.inkwell
+func New() (*Log, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+
+	inkwellPath := filepath.Join(home, inkwellDir)
+	if err := os.MkdirAll(inkwellPath, 0755); err != nil {
+		return nil, err
+	}
+
+	return &Log{filePath: filepath.Join(inkwellPath, auditFile)}, nil
+}
+
+// Record appends an entry, stamping the current time if unset
+func (l *Log) Record(entry Entry) error {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f, err := os.OpenFile(l.filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Entries returns matching entries, newest first
+func (l *Log) Entries(filter Filter) ([]Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f, err := os.Open(l.filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []Entry{}, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// Skip a torn or hand-edited line rather than failing the read
+			continue
+		}
+		if filter.matches(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// Reverse to newest first
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+
+	if filter.Limit > 0 && len(entries) > filter.Limit {
+		entries = entries[:filter.Limit]
+	}
+	if entries == nil {
+		entries = []Entry{}
+	}
+
+	return entries, nil
+}
+
+// matches reports whether an entry passes the filter
+func (f Filter) matches(entry Entry) bool {
+	if f.Action != "" && entry.Action != f.Action {
+		return false
+	}
+	if f.Actor != "" && entry.Actor != f.Actor {
+		return false
+	}
+	if f.Path != "" && entry.Path != f.Path {
+		return false
+	}
+	if !f.Since.IsZero() && entry.Time.Before(f.Since) {
+		return false
+	}
+	return true
+}
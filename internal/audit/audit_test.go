@@ -0,0 +1,72 @@
+package audit
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestRecordAndEntries(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	l, err := New()
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	records := []Entry{
+		{Actor: "local", Action: ActionFileWrite, Path: "a.md"},
+		{Actor: "apikey:ci", Action: ActionGitCommit, Summary: "Update notes"},
+		{Actor: "local", Action: ActionFileDelete, Path: "b.md"},
+	}
+	for _, e := range records {
+		if err := l.Record(e); err != nil {
+			t.Fatalf("Record failed: %v", err)
+		}
+	}
+
+	all, err := l.Entries(Filter{})
+	if err != nil {
+		t.Fatalf("Entries failed: %v", err)
+	}
+	if len(all) != 3 || all[0].Action != ActionFileDelete {
+		t.Fatalf("Expected 3 entries newest first, got %+v", all)
+	}
+	if all[0].Time.IsZero() {
+		t.Error("Time was not stamped")
+	}
+
+	byActor, _ := l.Entries(Filter{Actor: "local", Limit: 1})
+	if len(byActor) != 1 || byActor[0].Path != "b.md" {
+		t.Errorf("Unexpected filtered entries: %+v", byActor)
+	}
+
+	future, _ := l.Entries(Filter{Since: time.Now().Add(time.Hour)})
+	if len(future) != 0 {
+		t.Errorf("Expected no entries, got %d", len(future))
+	}
+}
+
+func TestEntriesSkipsCorruptLines(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	l, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(l.filePath, []byte("not json\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Record(Entry{Actor: "local", Action: ActionFileWrite}); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := l.Entries(Filter{})
+	if err != nil {
+		t.Fatalf("Entries failed: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Expected 1 entry, got %d", len(entries))
+	}
+}
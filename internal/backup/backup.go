@@ -0,0 +1,254 @@
+// Package backup keeps rotated zip archives of a vault in a folder, for
+// vaults that aren't kept in git
+package backup
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultInterval is how often backups are made when no interval is set
+	DefaultInterval = 24 * time.Hour
+
+	// DefaultKeep is how many backups of a vault are kept when no number is set
+	DefaultKeep = 7
+
+	// timeFormat names backups so they sort oldest first
+	timeFormat = "20060102-150405"
+)
+
+// Status reports how backing up is going
+type Status struct {
+	Enabled    bool      `json:"enabled"`
+	Dest       string    `json:"dest,omitempty"`       // Folder backups are written to
+	Running    bool      `json:"running"`              // A backup is being made
+	LastBackup time.Time `json:"lastBackup,omitempty"` // When the newest backup was written
+	LastFile   string    `json:"lastFile,omitempty"`   // Path of the newest backup
+	LastSize   int64     `json:"lastSize,omitempty"`
+	Backups    int       `json:"backups"`         // Backups of the vault kept
+	Error      string    `json:"error,omitempty"` // From the last backup, if it failed
+}
+
+// Service zips vaults into a folder, keeping the newest few of each
+type Service struct {
+	dest string
+	keep int
+
+	run     sync.Mutex // Serializes backups
+	mu      sync.Mutex // Guards running and errors
+	running bool
+	errors  map[string]string // Why the last backup of a vault failed, by root
+}
+
+// New writes backups to dest, which is created if needed, keeping keep of
+// each vault
+func New(dest string, keep int) (*Service, error) {
+	dest, err := filepath.Abs(dest)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create backup folder: %w", err)
+	}
+	if keep <= 0 {
+		keep = DefaultKeep
+	}
+	return &Service{
+		dest:   dest,
+		keep:   keep,
+		errors: make(map[string]string),
+	}, nil
+}
+
+// Status reports on the backups of the vault at rootDir, those left by
+// earlier runs included
+func (s *Service) Status(rootDir string) Status {
+	s.mu.Lock()
+	status := Status{
+		Enabled: true,
+		Dest:    s.dest,
+		Running: s.running,
+		Error:   s.errors[rootDir],
+	}
+	s.mu.Unlock()
+
+	backups, _ := s.list(rootDir)
+	status.Backups = len(backups)
+	if len(backups) > 0 {
+		newest := backups[len(backups)-1]
+		if info, err := os.Stat(newest); err == nil {
+			status.LastBackup = info.ModTime()
+			status.LastFile = newest
+			status.LastSize = info.Size()
+		}
+	}
+	return status
+}
+
+// Run zips the vault at rootDir into the backup folder, then deletes its
+// oldest backups beyond the number kept. Files are stored as they are on
+// disk, so an encrypted vault stays encrypted; the .git folder is left
+// out, and so is the backup folder if it is inside the vault.
+func (s *Service) Run(rootDir string) (Status, error) {
+	s.run.Lock()
+	defer s.run.Unlock()
+
+	s.mu.Lock()
+	s.running = true
+	s.mu.Unlock()
+
+	err := s.write(rootDir)
+	if err == nil {
+		err = s.rotate(rootDir)
+	}
+
+	s.mu.Lock()
+	s.running = false
+	delete(s.errors, rootDir)
+	if err != nil {
+		s.errors[rootDir] = err.Error()
+	}
+	s.mu.Unlock()
+
+	return s.Status(rootDir), err
+}
+
+// prefix starts the name of every backup of the vault at rootDir
+func prefix(rootDir string) string {
+	name := strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == ':' || r < ' ' {
+			return '_'
+		}
+		return r
+	}, filepath.Base(rootDir))
+	return "inkwell-" + name + "-"
+}
+
+// write zips the vault to a temporary file, renaming it into place once
+// complete
+func (s *Service) write(rootDir string) error {
+	tmp, err := os.CreateTemp(s.dest, ".inkwell-backup-*")
+	if err != nil {
+		return fmt.Errorf("failed to create backup: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	err = s.zip(tmp, rootDir)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write backup: %w", err)
+	}
+
+	path := filepath.Join(s.dest, prefix(rootDir)+time.Now().Format(timeFormat)+".zip")
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to save backup: %w", err)
+	}
+	return nil
+}
+
+// zip writes every file of the vault, under a folder named after it
+func (s *Service) zip(w io.Writer, rootDir string) error {
+	base := filepath.Base(rootDir)
+	zw := zip.NewWriter(w)
+
+	err := filepath.WalkDir(rootDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return nil // Skip entries we can't read
+		}
+		if d.IsDir() {
+			if path != rootDir && (d.Name() == ".git" || path == s.dest) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(rootDir, path)
+		if err != nil {
+			return err
+		}
+		return addFile(zw, path, base+"/"+filepath.ToSlash(rel))
+	})
+	if err != nil {
+		zw.Close()
+		return err
+	}
+	return zw.Close()
+}
+
+// addFile copies a file into the archive, keeping its modification time
+func addFile(zw *zip.Writer, path, name string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	header.Method = zip.Deflate
+
+	entry, err := zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(entry, f)
+	return err
+}
+
+// list returns the backups of the vault at rootDir, oldest first
+func (s *Service) list(rootDir string) ([]string, error) {
+	entries, err := os.ReadDir(s.dest)
+	if err != nil {
+		return nil, err
+	}
+	p := prefix(rootDir)
+	var backups []string
+	for _, entry := range entries {
+		name := entry.Name()
+		stamp, ok := strings.CutPrefix(name, p)
+		if !ok || entry.IsDir() || !strings.HasSuffix(stamp, ".zip") {
+			continue
+		}
+		if _, err := time.Parse(timeFormat, strings.TrimSuffix(stamp, ".zip")); err != nil {
+			continue // Another vault whose name starts the same
+		}
+		backups = append(backups, filepath.Join(s.dest, name))
+	}
+	sort.Strings(backups)
+	return backups, nil
+}
+
+// rotate deletes the oldest backups of the vault beyond the number kept
+func (s *Service) rotate(rootDir string) error {
+	backups, err := s.list(rootDir)
+	if err != nil {
+		return err
+	}
+	for len(backups) > s.keep {
+		if err := os.Remove(backups[0]); err != nil {
+			return fmt.Errorf("failed to delete old backup: %w", err)
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
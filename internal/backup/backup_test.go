@@ -0,0 +1,66 @@
+package backup
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "notes")
+	os.MkdirAll(filepath.Join(root, ".git"), 0755)
+	os.MkdirAll(filepath.Join(root, "sub"), 0755)
+	os.WriteFile(filepath.Join(root, "a.md"), []byte("# A\n"), 0644)
+	os.WriteFile(filepath.Join(root, "sub", "b.md"), []byte("# B\n"), 0644)
+	os.WriteFile(filepath.Join(root, ".git", "HEAD"), []byte("ref: refs/heads/main\n"), 0644)
+
+	// A backup folder inside the vault isn't backed up into itself
+	s, err := New(filepath.Join(root, "backups"), 2)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if status := s.Status(root); status.Backups != 0 || !status.LastBackup.IsZero() {
+		t.Errorf("Expected no backups yet, got %+v", status)
+	}
+
+	status, err := s.Run(root)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if status.Backups != 1 || status.LastFile == "" || status.LastSize == 0 {
+		t.Fatalf("Unexpected status: %+v", status)
+	}
+
+	zr, err := zip.OpenReader(status.LastFile)
+	if err != nil {
+		t.Fatalf("Failed to open backup: %v", err)
+	}
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	zr.Close()
+	sort.Strings(names)
+	if len(names) != 2 || names[0] != "notes/a.md" || names[1] != "notes/sub/b.md" {
+		t.Errorf("Expected only the notes, got %v", names)
+	}
+
+	// Older backups beyond the number kept are deleted, the one just made
+	// being replaced as it has the same name
+	for _, stamp := range []string{"20200101-000000", "20210101-000000"} {
+		os.WriteFile(filepath.Join(s.dest, prefix(root)+stamp+".zip"), nil, 0644)
+	}
+	os.WriteFile(filepath.Join(s.dest, "inkwell-notes-old-20200101-000000.zip"), nil, 0644)
+	if status, err = s.Run(root); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	backups, _ := s.list(root)
+	if status.Backups != 2 || len(backups) != 2 || backups[0] != filepath.Join(s.dest, prefix(root)+"20210101-000000.zip") {
+		t.Errorf("Expected the 2 newest backups kept, got %v", backups)
+	}
+	if _, err := os.Stat(filepath.Join(s.dest, "inkwell-notes-old-20200101-000000.zip")); err != nil {
+		t.Errorf("Expected another vault's backup left alone: %v", err)
+	}
+}
@@ -0,0 +1,108 @@
+// Package branding applies vault-level customization to the web UI
+package branding
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+)
+
+const (
+	// Dir is where a vault keeps its branding, relative to the vault root
+	Dir = ".inkwell/branding"
+
+	configFile = "branding.json"
+	defaultCSS = "custom.css"
+)
+
+var (
+	titleTag  = regexp.MustCompile(`<title>[^<]*</title>`)
+	logoText  = regexp.MustCompile(`(<h1 class="logo-text">)[^<]*(</h1>)`)
+	logoImage = regexp.MustCompile(`src="/logo-(dark|light)\.png"`)
+)
+
+// Branding describes how a vault customizes the UI. Every field is
+// optional; files in the branding directory also replace embedded web
+// assets with the same path.
+type Branding struct {
+	Title string `json:"title,omitempty"` // Page title and sidebar heading
+	Logo  string `json:"logo,omitempty"`  // Logo image, relative to the branding directory
+	CSS   string `json:"css,omitempty"`   // Stylesheet loaded after the built-in ones
+
+	dir fs.FS
+}
+
+// Load reads the branding of the vault at rootDir. It returns nil when the
+// vault has no branding directory.
+func Load(rootDir string) (*Branding, error) {
+	dir := filepath.Join(rootDir, filepath.FromSlash(Dir))
+	info, err := os.Stat(dir)
+	if err != nil || !info.IsDir() {
+		return nil, nil
+	}
+
+	b := &Branding{dir: os.DirFS(dir)}
+
+	data, err := fs.ReadFile(b.dir, configFile)
+	if err == nil {
+		if err := json.Unmarshal(data, b); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", configFile, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if b.CSS == "" && b.exists(defaultCSS) {
+		b.CSS = defaultCSS
+	}
+
+	for _, name := range []string{b.Logo, b.CSS} {
+		if name != "" && !fs.ValidPath(name) {
+			return nil, fmt.Errorf("invalid branding path: %s", name)
+		}
+	}
+
+	return b, nil
+}
+
+// Open returns a branding file that overrides the embedded asset at name,
+// a slash-separated path without a leading slash
+func (b *Branding) Open(name string) (fs.File, error) {
+	if name == configFile {
+		return nil, fs.ErrNotExist
+	}
+	return b.dir.Open(path.Clean(name))
+}
+
+// exists reports whether the branding directory has a regular file at name
+func (b *Branding) exists(name string) bool {
+	info, err := fs.Stat(b.dir, name)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// Apply rewrites an HTML page with the branded title, logo and stylesheet
+func (b *Branding) Apply(page []byte) []byte {
+	if b.Title != "" {
+		title := html.EscapeString(b.Title)
+		page = titleTag.ReplaceAll(page, []byte("<title>"+title+"</title>"))
+		page = logoText.ReplaceAll(page, []byte("${1}"+title+"${2}"))
+	}
+
+	if b.Logo != "" {
+		src := `src="/` + html.EscapeString(b.Logo) + `"`
+		page = logoImage.ReplaceAll(page, []byte(src))
+	}
+
+	if b.CSS != "" {
+		link := `<link rel="stylesheet" href="/` + html.EscapeString(b.CSS) + `">` + "\n</head>"
+		page = bytes.Replace(page, []byte("</head>"), []byte(link), 1)
+	}
+
+	return page
+}
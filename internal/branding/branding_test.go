@@ -0,0 +1,104 @@
+package branding
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const page = `<html><head>
+<title>Inkwell</title>
+</head><body>
+<img src="/logo-dark.png" /><img src="/logo-light.png" />
+<h1 class="logo-text">Inkwell</h1>
+</body></html>`
+
+func writeBranding(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		full := filepath.Join(root, filepath.FromSlash(Dir), name)
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLoadWithoutBranding(t *testing.T) {
+	b, err := Load(t.TempDir())
+	if err != nil || b != nil {
+		t.Errorf("Load = %v, %v; want nil, nil", b, err)
+	}
+}
+
+func TestApply(t *testing.T) {
+	root := t.TempDir()
+	writeBranding(t, root, map[string]string{
+		"branding.json": `{"title": "Team <Wiki>", "logo": "team.svg"}`,
+		"custom.css":    "body { color: red; }",
+		"team.svg":      "<svg/>",
+	})
+
+	b, err := Load(root)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	out := string(b.Apply([]byte(page)))
+	for _, want := range []string{
+		"<title>Team &lt;Wiki&gt;</title>",
+		`<h1 class="logo-text">Team &lt;Wiki&gt;</h1>`,
+		`<img src="/team.svg" /><img src="/team.svg" />`,
+		`<link rel="stylesheet" href="/custom.css">`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestOpen(t *testing.T) {
+	root := t.TempDir()
+	writeBranding(t, root, map[string]string{
+		"branding.json":   `{}`,
+		"logo-dark.png":   "png",
+		"assets/extra.js": "js",
+	})
+
+	b, err := Load(root)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	f, err := b.Open("assets/extra.js")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	data, _ := io.ReadAll(f)
+	f.Close()
+	if string(data) != "js" {
+		t.Errorf("Open content = %q", data)
+	}
+
+	if _, err := b.Open("branding.json"); err == nil {
+		t.Error("branding.json should not be served")
+	}
+	if _, err := b.Open("../../secret.md"); err == nil {
+		t.Error("Open should reject paths outside the branding directory")
+	}
+}
+
+func TestLoadRejectsInvalidPaths(t *testing.T) {
+	root := t.TempDir()
+	writeBranding(t, root, map[string]string{
+		"branding.json": `{"css": "../../notes.md"}`,
+	})
+
+	if _, err := Load(root); err == nil {
+		t.Error("Load should reject paths outside the branding directory")
+	}
+}
@@ -0,0 +1,307 @@
+package cite
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// ParseBibTeX reads the entries of a BibTeX file. @string macros are
+// expanded; @comment and @preamble blocks are skipped.
+func ParseBibTeX(data string) ([]Entry, error) {
+	p := &bibParser{s: data, macros: make(map[string]string)}
+	var entries []Entry
+	for {
+		at := strings.IndexByte(p.s[p.i:], '@')
+		if at < 0 {
+			return entries, nil
+		}
+		p.i += at + 1
+
+		kind := strings.ToLower(p.ident())
+		p.space()
+		if p.i >= len(p.s) || (p.s[p.i] != '{' && p.s[p.i] != '(') {
+			continue // An @ in running text between entries
+		}
+		close := byte('}')
+		if p.s[p.i] == '(' {
+			close = ')'
+		}
+		p.i++
+
+		switch kind {
+		case "comment", "preamble":
+			if err := p.skipBlock(close); err != nil {
+				return nil, err
+			}
+			continue
+		case "string":
+			p.space()
+			name := strings.ToLower(p.ident())
+			p.space()
+			if !p.consume('=') {
+				return nil, p.errorf("expected = in @string")
+			}
+			value, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			p.macros[name] = value
+			p.space()
+			p.consume(close)
+			continue
+		}
+
+		p.space()
+		start := p.i
+		for p.i < len(p.s) && p.s[p.i] != ',' && p.s[p.i] != close && !unicode.IsSpace(rune(p.s[p.i])) {
+			p.i++
+		}
+		e := Entry{Key: p.s[start:p.i], Type: kind}
+		fields := make(map[string]string)
+		for {
+			p.space()
+			if p.consume(close) {
+				break
+			}
+			if !p.consume(',') {
+				return nil, p.errorf("expected , or %c in %s", close, e.Key)
+			}
+			p.space()
+			if p.consume(close) {
+				break // Trailing comma
+			}
+			name := strings.ToLower(p.ident())
+			if name == "" {
+				return nil, p.errorf("expected a field name in %s", e.Key)
+			}
+			p.space()
+			if !p.consume('=') {
+				return nil, p.errorf("expected = after %s in %s", name, e.Key)
+			}
+			value, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			fields[name] = value
+		}
+		e.fromBibTeX(fields)
+		entries = append(entries, e)
+	}
+}
+
+func (e *Entry) fromBibTeX(fields map[string]string) {
+	e.Title = fields["title"]
+	for _, author := range strings.Split(fields["author"], " and ") {
+		if author = strings.TrimSpace(author); author != "" {
+			e.Authors = append(e.Authors, author)
+		}
+	}
+	if len(e.Authors) == 0 {
+		for _, editor := range strings.Split(fields["editor"], " and ") {
+			if editor = strings.TrimSpace(editor); editor != "" {
+				e.Authors = append(e.Authors, editor)
+			}
+		}
+	}
+	e.Year = fields["year"]
+	if e.Year == "" && len(fields["date"]) >= 4 {
+		e.Year = fields["date"][:4]
+	}
+	for _, name := range []string{"journal", "journaltitle", "booktitle", "publisher", "school", "institution"} {
+		if fields[name] != "" {
+			e.Container = fields[name]
+			break
+		}
+	}
+	e.Volume = fields["volume"]
+	e.Pages = strings.ReplaceAll(fields["pages"], "--", "–")
+	e.DOI = fields["doi"]
+	e.URL = fields["url"]
+}
+
+type bibParser struct {
+	s      string
+	i      int
+	macros map[string]string
+}
+
+func (p *bibParser) errorf(format string, args ...interface{}) error {
+	line := strings.Count(p.s[:min(p.i, len(p.s))], "\n") + 1
+	return fmt.Errorf("line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+func (p *bibParser) space() {
+	for p.i < len(p.s) && unicode.IsSpace(rune(p.s[p.i])) {
+		p.i++
+	}
+}
+
+func (p *bibParser) consume(c byte) bool {
+	if p.i < len(p.s) && p.s[p.i] == c {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *bibParser) ident() string {
+	start := p.i
+	for p.i < len(p.s) {
+		c := p.s[p.i]
+		if !(c == '_' || c == '-' || c == ':' || c == '.' || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c))) {
+			break
+		}
+		p.i++
+	}
+	return p.s[start:p.i]
+}
+
+// skipBlock skips to the close of a block, respecting nested braces
+func (p *bibParser) skipBlock(close byte) error {
+	depth := 0
+	for ; p.i < len(p.s); p.i++ {
+		switch c := p.s[p.i]; {
+		case c == '{':
+			depth++
+		case c == '}' && depth > 0:
+			depth--
+		case c == close && depth == 0:
+			p.i++
+			return nil
+		}
+	}
+	return p.errorf("unterminated block")
+}
+
+// value reads a field value: braced or quoted text, numbers and macros,
+// joined with #
+func (p *bibParser) value() (string, error) {
+	var b strings.Builder
+	for {
+		p.space()
+		if p.i >= len(p.s) {
+			return "", p.errorf("unexpected end of file")
+		}
+		switch c := p.s[p.i]; {
+		case c == '{':
+			text, err := p.delimited('}')
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(text)
+		case c == '"':
+			text, err := p.delimited('"')
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(text)
+		default:
+			word := p.ident()
+			if word == "" {
+				return "", p.errorf("expected a value")
+			}
+			if macro, ok := p.macros[strings.ToLower(word)]; ok {
+				word = macro
+			}
+			b.WriteString(word)
+		}
+		p.space()
+		if !p.consume('#') {
+			return cleanTeX(b.String()), nil
+		}
+	}
+}
+
+// delimited reads text up to close, which may hold nested braces
+func (p *bibParser) delimited(close byte) (string, error) {
+	p.i++ // The opening brace or quote
+	start := p.i
+	depth := 0
+	for ; p.i < len(p.s); p.i++ {
+		c := p.s[p.i]
+		switch {
+		case c == '\\':
+			p.i++
+		case c == '{':
+			depth++
+		case c == '}' && depth > 0:
+			depth--
+		case c == close && depth == 0:
+			text := p.s[start:p.i]
+			p.i++
+			return text, nil
+		}
+	}
+	return "", p.errorf("unterminated value")
+}
+
+// texAccents maps the common TeX accent commands to combining marks
+var texAccents = map[byte]rune{
+	'\'': '́', '`': '̀', '^': '̂', '"': '̈', '~': '̃',
+	'=': '̄', '.': '̇', 'c': '̧', 'v': '̌', 'u': '̆', 'H': '̋',
+}
+
+// texSymbols maps TeX commands for letters and symbols to their text
+var texSymbols = map[string]string{
+	"ss": "ß", "o": "ø", "O": "Ø", "ae": "æ", "AE": "Æ", "oe": "œ", "OE": "Œ",
+	"aa": "å", "AA": "Å", "l": "ł", "L": "Ł", "i": "ı", "&": "&", "%": "%",
+	"$": "$", "_": "_", "#": "#", "textendash": "–", "textemdash": "—",
+}
+
+// cleanTeX turns the TeX in a value into plain text: accents become
+// Unicode, braces and unknown commands are dropped and space is collapsed
+func cleanTeX(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '{' || c == '}':
+		case c == '~':
+			b.WriteByte(' ')
+		case c == '-' && strings.HasPrefix(s[i:], "---"):
+			b.WriteString("—")
+			i += 2
+		case c == '-' && strings.HasPrefix(s[i:], "--"):
+			b.WriteString("–")
+			i++
+		case c == '\\' && i+1 < len(s):
+			i++
+			if mark, ok := texAccents[s[i]]; ok && (!unicode.IsLetter(rune(s[i])) || i+1 < len(s) && (s[i+1] == '{' || s[i+1] == ' ')) {
+				// \'e, \'{e}, \c{c} or \c c
+				j := i + 1
+				for j < len(s) && (s[j] == '{' || s[j] == ' ') {
+					j++
+				}
+				if j+1 < len(s) && s[j] == '\\' && (s[j+1] == 'i' || s[j+1] == 'j') {
+					j++ // \'{\i} accents a dotless i
+				}
+				if j < len(s) {
+					b.WriteByte(s[j])
+					b.WriteRune(mark)
+					i = j
+					for i+1 < len(s) && s[i+1] == '}' {
+						i++
+					}
+				}
+				continue
+			}
+			j := i
+			for j < len(s) && unicode.IsLetter(rune(s[j])) {
+				j++
+			}
+			if j == i {
+				j = i + 1 // A single symbol such as \&
+			}
+			if sym, ok := texSymbols[s[i:j]]; ok {
+				b.WriteString(sym)
+			}
+			i = j - 1
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return norm.NFC.String(strings.Join(strings.Fields(b.String()), " "))
+}
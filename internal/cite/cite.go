@@ -0,0 +1,166 @@
+// Package cite reads a vault's bibliography, in BibTeX or CSL JSON, and
+// resolves pandoc-style citations such as [@smith2020, p. 4] against it
+// for export
+package cite
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"inkwell/internal/filesystem"
+)
+
+// DefaultFiles are looked for at the vault root when no bibliography is
+// configured
+var DefaultFiles = []string{"references.bib", "references.json", "bibliography.bib", "bibliography.json"}
+
+// ErrNoBibliography is returned when a vault has no bibliography
+var ErrNoBibliography = errors.New("no bibliography found")
+
+// searchLimit is the most entries Search returns by default
+const searchLimit = 20
+
+// Entry is a work that can be cited
+type Entry struct {
+	Key       string   `json:"key"`
+	Type      string   `json:"type"` // article, book, ... as the file names it
+	Title     string   `json:"title"`
+	Authors   []string `json:"authors,omitempty"` // "Family, Given"
+	Year      string   `json:"year,omitempty"`
+	Container string   `json:"container,omitempty"` // Journal, book or publisher
+	Volume    string   `json:"volume,omitempty"`
+	Pages     string   `json:"pages,omitempty"`
+	DOI       string   `json:"doi,omitempty"`
+	URL       string   `json:"url,omitempty"`
+}
+
+// Bibliography is the entries of a bibliography file by key
+type Bibliography struct {
+	Path    string // Relative to the vault root
+	entries map[string]*Entry
+	keys    []string // In file order
+}
+
+// Find returns the path of the vault's bibliography: configured if set,
+// otherwise the first of DefaultFiles that exists
+func Find(fs *filesystem.FileSystem, configured string) (string, error) {
+	if configured != "" {
+		return filepath.FromSlash(configured), nil
+	}
+	for _, name := range DefaultFiles {
+		if fs.FileExists(name) {
+			return name, nil
+		}
+	}
+	return "", ErrNoBibliography
+}
+
+// Load reads the bibliography at path, relative to the vault root. Files
+// ending in .json are read as CSL JSON, anything else as BibTeX.
+func Load(fs *filesystem.FileSystem, path string) (*Bibliography, error) {
+	data, err := fs.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		entries, err = ParseCSLJSON([]byte(data))
+	} else {
+		entries, err = ParseBibTeX(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return New(path, entries), nil
+}
+
+// New builds a bibliography from entries. Later entries with a key
+// already seen are ignored.
+func New(path string, entries []Entry) *Bibliography {
+	b := &Bibliography{Path: path, entries: make(map[string]*Entry, len(entries))}
+	for i := range entries {
+		e := &entries[i]
+		if _, dup := b.entries[e.Key]; dup || e.Key == "" {
+			continue
+		}
+		b.entries[e.Key] = e
+		b.keys = append(b.keys, e.Key)
+	}
+	return b
+}
+
+// Len returns the number of entries
+func (b *Bibliography) Len() int {
+	return len(b.keys)
+}
+
+// Entry returns the entry with key
+func (b *Bibliography) Entry(key string) (Entry, bool) {
+	e, ok := b.entries[key]
+	if !ok {
+		return Entry{}, false
+	}
+	return *e, true
+}
+
+// Search returns up to limit entries whose key, title, authors or year
+// contain every word of query, keys starting with the query first. An
+// empty query lists entries in file order. A limit of zero or less uses
+// the default.
+func (b *Bibliography) Search(query string, limit int) []Entry {
+	if limit <= 0 {
+		limit = searchLimit
+	}
+	words := strings.Fields(strings.ToLower(strings.TrimPrefix(query, "@")))
+
+	type match struct {
+		entry *Entry
+		score int
+	}
+	var matches []match
+	for i, key := range b.keys {
+		e := b.entries[key]
+		haystack := strings.ToLower(e.Key + " " + e.Title + " " + strings.Join(e.Authors, " ") + " " + e.Year)
+		ok := true
+		for _, w := range words {
+			if !strings.Contains(haystack, w) {
+				ok = false
+				break
+			}
+		}
+		if !ok {
+			continue
+		}
+		score := -i // Keep file order among equals
+		if len(words) > 0 && strings.HasPrefix(strings.ToLower(e.Key), words[0]) {
+			score += len(b.keys)
+		}
+		matches = append(matches, match{e, score})
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].score > matches[j].score })
+
+	result := []Entry{}
+	for _, m := range matches {
+		if len(result) == limit {
+			break
+		}
+		result = append(result, *m.entry)
+	}
+	return result
+}
+
+// familyName returns the family name of an author written "Family, Given"
+// or "Given Family"
+func familyName(author string) string {
+	if family, _, ok := strings.Cut(author, ","); ok {
+		return strings.TrimSpace(family)
+	}
+	fields := strings.Fields(author)
+	if len(fields) == 0 {
+		return author
+	}
+	return fields[len(fields)-1]
+}
@@ -0,0 +1,163 @@
+package cite
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"inkwell/internal/filesystem"
+)
+
+const testBib = `
+@string{jrn = "Journal of Notes"}
+
+@comment{Exported from a reference manager}
+
+@article{smith2020,
+  author  = {Smith, John and Doe, Ann},
+  title   = {On {Markdown} Notes},
+  journal = jrn,
+  year    = 2020,
+  volume  = {12},
+  pages   = {1--10},
+  doi     = {10.1000/notes},
+}
+
+@book{garcia2018,
+  author    = {Gabriel Garc{\'\i}a and M\"uller, Karl and Lee, Ann},
+  title     = "Writing " # "Things Down",
+  publisher = {Inkwell Press},
+  year      = {2018}
+}
+`
+
+func TestParseBibTeX(t *testing.T) {
+	entries, err := ParseBibTeX(testBib)
+	if err != nil {
+		t.Fatalf("ParseBibTeX failed: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(entries))
+	}
+
+	e := entries[0]
+	if e.Key != "smith2020" || e.Type != "article" || e.Title != "On Markdown Notes" || e.Container != "Journal of Notes" ||
+		e.Year != "2020" || e.Pages != "1–10" || e.DOI != "10.1000/notes" || len(e.Authors) != 2 {
+		t.Errorf("Unexpected entry %+v", e)
+	}
+
+	e = entries[1]
+	if e.Title != "Writing Things Down" || e.Authors[0] != "Gabriel García" || e.Authors[1] != "Müller, Karl" {
+		t.Errorf("Unexpected entry %+v", e)
+	}
+
+	if _, err := ParseBibTeX("@article{broken, title = {no end}"); err == nil {
+		t.Error("Expected an error for an unterminated entry")
+	}
+}
+
+func TestParseCSLJSON(t *testing.T) {
+	data := `[{"id": "doe2019", "type": "article-journal", "title": "Linking Notes",
+		"author": [{"family": "Doe", "given": "Ann"}], "issued": {"date-parts": [[2019, 3]]},
+		"container-title": "Notes Quarterly", "page": "5-9"}]`
+	entries, err := ParseCSLJSON([]byte(data))
+	if err != nil {
+		t.Fatalf("ParseCSLJSON failed: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 entry, got %d", len(entries))
+	}
+	e := entries[0]
+	if e.Key != "doe2019" || e.Year != "2019" || e.Authors[0] != "Doe, Ann" || e.Container != "Notes Quarterly" || e.Pages != "5–9" {
+		t.Errorf("Unexpected entry %+v", e)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	root := t.TempDir()
+	fs := &filesystem.FileSystem{RootDir: root}
+
+	if _, err := Find(fs, ""); err != ErrNoBibliography {
+		t.Errorf("Expected ErrNoBibliography, got %v", err)
+	}
+
+	os.WriteFile(filepath.Join(root, "references.bib"), []byte(testBib), 0644)
+	path, err := Find(fs, "")
+	if err != nil || path != "references.bib" {
+		t.Fatalf("Find = %q, %v", path, err)
+	}
+	b, err := Load(fs, path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if b.Len() != 2 {
+		t.Errorf("Expected 2 entries, got %d", b.Len())
+	}
+}
+
+func TestSearch(t *testing.T) {
+	entries, _ := ParseBibTeX(testBib)
+	b := New("references.bib", entries)
+
+	if got := b.Search("", 0); len(got) != 2 {
+		t.Errorf("Empty query should list every entry, got %d", len(got))
+	}
+	if got := b.Search("@gar", 0); len(got) != 1 || got[0].Key != "garcia2018" {
+		t.Errorf("Search by key = %+v", got)
+	}
+	if got := b.Search("doe 2020", 0); len(got) != 1 || got[0].Key != "smith2020" {
+		t.Errorf("Search by author and year = %+v", got)
+	}
+	if got := b.Search("ann", 1); len(got) != 1 {
+		t.Errorf("Limit not applied, got %d", len(got))
+	}
+}
+
+func TestProcess(t *testing.T) {
+	entries, _ := ParseBibTeX(testBib)
+	b := New("references.bib", entries)
+
+	src := strings.Join([]string{
+		"As @smith2020 [p. 4] shows, notes link [see @garcia2018, ch. 2; @smith2020].",
+		"Later work [-@garcia2018] agrees, unlike [@missing].",
+		"Mail me@example.com or see [@handle](https://example.com).",
+		"Code `@smith2020` stays.",
+		"```",
+		"[@smith2020]",
+		"```",
+	}, "\n")
+	res := b.Process(src)
+
+	for _, want := range []string{
+		"[Smith and Doe (2020, p. 4)](#ref-smith2020) shows",
+		"(see [García et al. 2018](#ref-garcia2018), ch. 2; [Smith and Doe 2020](#ref-smith2020))",
+		"([2018](#ref-garcia2018)) agrees, unlike [@missing].",
+		"me@example.com or see [@handle](https://example.com).",
+		"`@smith2020` stays.",
+		"```\n[@smith2020]\n```",
+	} {
+		if !strings.Contains(res.Source, want) {
+			t.Errorf("Source missing %q:\n%s", want, res.Source)
+		}
+	}
+	if len(res.Cited) != 2 || res.Cited[0].Key != "smith2020" || res.Cited[1].Key != "garcia2018" {
+		t.Errorf("Unexpected cited %+v", res.Cited)
+	}
+	if len(res.Missing) != 1 || res.Missing[0] != "missing" {
+		t.Errorf("Unexpected missing %v", res.Missing)
+	}
+
+	refs := ReferencesHTML(res.Cited)
+	if !strings.Contains(refs, `id="ref-garcia2018"`) || strings.Index(refs, "García") > strings.Index(refs, "Smith") {
+		t.Errorf("References should be sorted by author:\n%s", refs)
+	}
+	if !strings.Contains(refs, "Smith, J. &amp; Doe, A. (2020). On Markdown Notes. <em>Journal of Notes</em>, 12, 1–10.") {
+		t.Errorf("Unexpected reference:\n%s", refs)
+	}
+
+	var none *Bibliography
+	if source, refs := none.Resolve(src); source != src || refs != "" {
+		t.Error("A nil bibliography should leave markdown alone")
+	}
+}
@@ -0,0 +1,94 @@
+package cite
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// cslItem is the part of a CSL JSON item Inkwell uses
+type cslItem struct {
+	ID             json.RawMessage `json:"id"`
+	Type           string          `json:"type"`
+	Title          string          `json:"title"`
+	Author         []cslName       `json:"author"`
+	Editor         []cslName       `json:"editor"`
+	Issued         cslDate         `json:"issued"`
+	ContainerTitle string          `json:"container-title"`
+	Publisher      string          `json:"publisher"`
+	Volume         json.RawMessage `json:"volume"`
+	Page           string          `json:"page"`
+	DOI            string          `json:"DOI"`
+	URL            string          `json:"URL"`
+}
+
+type cslName struct {
+	Family  string `json:"family"`
+	Given   string `json:"given"`
+	Literal string `json:"literal"`
+}
+
+type cslDate struct {
+	DateParts [][]json.RawMessage `json:"date-parts"`
+	Raw       string              `json:"raw"`
+	Literal   string              `json:"literal"`
+}
+
+// ParseCSLJSON reads the items of a CSL JSON file, as exported by Zotero
+// and pandoc-citeproc
+func ParseCSLJSON(data []byte) ([]Entry, error) {
+	var items []cslItem
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, fmt.Errorf("invalid CSL JSON: %w", err)
+	}
+
+	entries := make([]Entry, 0, len(items))
+	for _, item := range items {
+		e := Entry{
+			Key:       scalar(item.ID),
+			Type:      item.Type,
+			Title:     item.Title,
+			Container: item.ContainerTitle,
+			Volume:    scalar(item.Volume),
+			Pages:     strings.ReplaceAll(item.Page, "-", "–"),
+			DOI:       item.DOI,
+			URL:       item.URL,
+		}
+		if e.Container == "" {
+			e.Container = item.Publisher
+		}
+		names := item.Author
+		if len(names) == 0 {
+			names = item.Editor
+		}
+		for _, n := range names {
+			switch {
+			case n.Literal != "":
+				e.Authors = append(e.Authors, n.Literal)
+			case n.Given != "":
+				e.Authors = append(e.Authors, n.Family+", "+n.Given)
+			case n.Family != "":
+				e.Authors = append(e.Authors, n.Family)
+			}
+		}
+		switch d := item.Issued; {
+		case len(d.DateParts) > 0 && len(d.DateParts[0]) > 0:
+			e.Year = scalar(d.DateParts[0][0])
+		case len(d.Raw) >= 4:
+			e.Year = d.Raw[:4]
+		case len(d.Literal) >= 4:
+			e.Year = d.Literal[:4]
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// scalar returns a JSON string or number as text
+func scalar(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	return strings.TrimSpace(string(raw))
+}
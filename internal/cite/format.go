@@ -0,0 +1,383 @@
+package cite
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	// bracketPattern matches [see @smith2020, p. 4; @doe2019] not followed
+	// by a link destination
+	bracketPattern = regexp.MustCompile(`\[([^\[\]]*@[^\[\]]*)\]`)
+	// keyPattern matches a citation key with an optional - that leaves
+	// out the author
+	keyPattern = regexp.MustCompile(`(-?)@([\p{L}\p{N}_][\p{L}\p{N}_:.#$%&\-+?<>~/]*)`)
+	// locatorPattern matches the [p. 4] after an in-text citation
+	locatorPattern = regexp.MustCompile(`^ ?\[([^\[\]@]*)\]`)
+)
+
+// Result is a note's markdown with its citations resolved
+type Result struct {
+	Source  string   // Markdown with citations replaced by links to the references
+	Cited   []Entry  // Works cited, in order of first citation
+	Missing []string // Cited keys not in the bibliography
+}
+
+// Process replaces pandoc-style citations in markdown with author-date
+// links to a reference list: [@key] and [see @a, p. 4; @b] become
+// "(Smith 2020)" and "(see Smith 2020, p. 4; Doe 2019)", [-@key] leaves out
+// the author and a bare @key becomes "Smith (2020)". Code is left alone,
+// as are keys the bibliography doesn't have.
+func (b *Bibliography) Process(markdown string) Result {
+	c := &citer{b: b, seen: make(map[string]bool), missing: make(map[string]bool)}
+
+	lines := strings.SplitAfter(markdown, "\n")
+	fence := ""
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fence = trimmed[:3]
+			continue
+		}
+		if strings.ContainsRune(line, '@') {
+			lines[i] = c.line(line)
+		}
+	}
+
+	return Result{Source: strings.Join(lines, ""), Cited: c.cited, Missing: c.missingKeys}
+}
+
+// Resolve processes markdown's citations, returning the markdown to render
+// and the reference list to append to its HTML. A nil bibliography leaves
+// the markdown as it is.
+func (b *Bibliography) Resolve(markdown string) (string, string) {
+	if b == nil {
+		return markdown, ""
+	}
+	res := b.Process(markdown)
+	return res.Source, ReferencesHTML(res.Cited)
+}
+
+type citer struct {
+	b           *Bibliography
+	cited       []Entry
+	seen        map[string]bool
+	missing     map[string]bool
+	missingKeys []string
+}
+
+// line resolves the citations on a line outside `code spans`
+func (c *citer) line(line string) string {
+	var out strings.Builder
+	for line != "" {
+		start := strings.IndexByte(line, '`')
+		if start < 0 {
+			out.WriteString(c.text(line))
+			break
+		}
+		out.WriteString(c.text(line[:start]))
+		ticks := start
+		for ticks < len(line) && line[ticks] == '`' {
+			ticks++
+		}
+		end := strings.Index(line[ticks:], line[start:ticks])
+		if end < 0 {
+			out.WriteString(line[start:])
+			break
+		}
+		end += ticks + (ticks - start)
+		out.WriteString(line[start:end])
+		line = line[end:]
+	}
+	return out.String()
+}
+
+// text resolves the citations in text without code
+func (c *citer) text(text string) string {
+	var out strings.Builder
+	for text != "" {
+		loc := bracketPattern.FindStringSubmatchIndex(text)
+		if loc == nil {
+			out.WriteString(c.inText(text))
+			break
+		}
+		out.WriteString(c.inText(text[:loc[0]]))
+		after := text[loc[1]:]
+		if strings.HasPrefix(after, "(") || strings.HasPrefix(after, "[") {
+			// A link such as [@handle](https://...)
+			out.WriteString(text[loc[0]:loc[1]])
+		} else if cited, ok := c.bracketed(text[loc[2]:loc[3]]); ok {
+			out.WriteString(cited)
+		} else {
+			out.WriteString(text[loc[0]:loc[1]])
+		}
+		text = after
+	}
+	return out.String()
+}
+
+// bracketed formats the items of a [...] citation, separated by ;
+func (c *citer) bracketed(inner string) (string, bool) {
+	var parts []string
+	for _, item := range strings.Split(inner, ";") {
+		loc := keyPattern.FindStringSubmatchIndex(item)
+		if loc == nil {
+			return "", false
+		}
+		key := trimKey(item[loc[4]:loc[5]])
+		e, ok := c.entry(key)
+		if !ok {
+			return "", false
+		}
+		prefix := strings.TrimSpace(item[:loc[0]])
+		suffix := strings.TrimSpace(item[loc[4]+len(key):])
+
+		label := e.year()
+		if item[loc[2]:loc[3]] != "-" {
+			label = e.authorLabel() + " " + label
+		}
+		part := c.link(e, label)
+		if prefix != "" {
+			part = escape(prefix) + " " + part
+		}
+		if suffix != "" {
+			if !strings.HasPrefix(suffix, ",") {
+				suffix = ", " + suffix
+			}
+			part += escape(suffix)
+		}
+		parts = append(parts, part)
+	}
+	return "(" + strings.Join(parts, "; ") + ")", true
+}
+
+// inText formats @key citations written into a sentence, such as "as
+// @smith2020 [p. 4] shows"
+func (c *citer) inText(text string) string {
+	var out strings.Builder
+	for {
+		loc := keyPattern.FindStringSubmatchIndex(text)
+		if loc == nil {
+			out.WriteString(text)
+			return out.String()
+		}
+		key := trimKey(text[loc[4]:loc[5]])
+		at := loc[4] - 1 // The @
+		end := loc[4] + len(key)
+
+		prev, _ := utf8.DecodeLastRuneInString(text[:at])
+		e, ok := Entry{}, false
+		if loc[2] == loc[3] && (at == 0 || unicode.IsSpace(prev) || prev == '(') {
+			e, ok = c.entry(key)
+		}
+		if !ok {
+			out.WriteString(text[:end])
+			text = text[end:]
+			continue
+		}
+
+		inside := e.year()
+		if m := locatorPattern.FindStringSubmatch(text[end:]); m != nil {
+			inside += ", " + escape(strings.TrimSpace(m[1]))
+			end += len(m[0])
+		}
+		out.WriteString(text[:at])
+		out.WriteString(c.link(e, e.authorLabel()+" ("+inside+")"))
+		text = text[end:]
+	}
+}
+
+// entry looks up a cited key, remembering the works cited and the keys
+// missing
+func (c *citer) entry(key string) (Entry, bool) {
+	e, ok := c.b.Entry(key)
+	if !ok {
+		if !c.missing[key] {
+			c.missing[key] = true
+			c.missingKeys = append(c.missingKeys, key)
+		}
+		return Entry{}, false
+	}
+	if !c.seen[key] {
+		c.seen[key] = true
+		c.cited = append(c.cited, e)
+	}
+	return e, true
+}
+
+func (c *citer) link(e Entry, label string) string {
+	return "[" + escape(label) + "](#" + RefID(e.Key) + ")"
+}
+
+// trimKey drops punctuation that ends a sentence rather than the key
+func trimKey(key string) string {
+	return strings.TrimRight(key, ".:,;?")
+}
+
+// escape keeps text from being read as markdown
+func escape(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		if strings.ContainsRune("\\`*_[]<>#!|", r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// RefID is the HTML id of an entry in the reference list
+func RefID(key string) string {
+	var b strings.Builder
+	b.WriteString("ref-")
+	for _, r := range key {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_' || r == '.' || r == ':' {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('-')
+		}
+	}
+	return b.String()
+}
+
+func (e Entry) year() string {
+	if e.Year == "" {
+		return "n.d."
+	}
+	return e.Year
+}
+
+// authorLabel names the authors in a citation: "Smith", "Smith and Doe"
+// or "Smith et al.", falling back to the title
+func (e Entry) authorLabel() string {
+	switch len(e.Authors) {
+	case 0:
+		if e.Title != "" {
+			return e.Title
+		}
+		return e.Key
+	case 1:
+		return familyName(e.Authors[0])
+	case 2:
+		return familyName(e.Authors[0]) + " and " + familyName(e.Authors[1])
+	default:
+		return familyName(e.Authors[0]) + " et al."
+	}
+}
+
+// ReferencesHTML renders a reference list of entries, sorted by author and
+// year, for the end of an exported note. It is empty without entries.
+func ReferencesHTML(entries []Entry) string {
+	if len(entries) == 0 {
+		return ""
+	}
+	sorted := append([]Entry(nil), entries...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := strings.ToLower(sorted[i].authorLabel()), strings.ToLower(sorted[j].authorLabel())
+		if a != b {
+			return a < b
+		}
+		return sorted[i].Year < sorted[j].Year
+	})
+
+	var b strings.Builder
+	b.WriteString("<section class=\"references\">\n<h2>References</h2>\n")
+	for _, e := range sorted {
+		fmt.Fprintf(&b, "<p id=\"%s\" class=\"reference\">%s</p>\n", RefID(e.Key), e.Reference())
+	}
+	b.WriteString("</section>\n")
+	return b.String()
+}
+
+// Reference formats the entry for a reference list, roughly in APA style,
+// as HTML
+func (e Entry) Reference() string {
+	var b strings.Builder
+	if len(e.Authors) > 0 {
+		names := make([]string, len(e.Authors))
+		for i, a := range e.Authors {
+			names[i] = html.EscapeString(initials(a))
+		}
+		if len(names) > 1 {
+			names[len(names)-1] = "&amp; " + names[len(names)-1]
+		}
+		sep := ", "
+		if len(names) == 2 {
+			sep = " "
+		}
+		b.WriteString(strings.Join(names, sep))
+		b.WriteString(" ")
+	}
+	fmt.Fprintf(&b, "(%s). ", html.EscapeString(e.year()))
+
+	title := html.EscapeString(strings.TrimRight(e.Title, "."))
+	container := html.EscapeString(e.Container)
+	if e.Type == "book" || e.Container == "" {
+		fmt.Fprintf(&b, "<em>%s</em>.", title)
+		if container != "" {
+			fmt.Fprintf(&b, " %s.", container)
+		}
+	} else {
+		fmt.Fprintf(&b, "%s. <em>%s</em>", title, container)
+		if e.Volume != "" {
+			fmt.Fprintf(&b, ", %s", html.EscapeString(e.Volume))
+		}
+		if e.Pages != "" {
+			fmt.Fprintf(&b, ", %s", html.EscapeString(e.Pages))
+		}
+		b.WriteString(".")
+	}
+
+	link := e.URL
+	if e.DOI != "" {
+		link = "https://doi.org/" + strings.TrimPrefix(e.DOI, "https://doi.org/")
+	}
+	if link != "" {
+		fmt.Fprintf(&b, " <a href=\"%s\">%s</a>", html.EscapeString(link), html.EscapeString(link))
+	}
+	return b.String()
+}
+
+// initials shortens an author's given names: "Smith, John Paul" becomes
+// "Smith, J. P."
+func initials(author string) string {
+	family := familyName(author)
+	var given string
+	if f, g, ok := strings.Cut(author, ","); ok {
+		family, given = strings.TrimSpace(f), g
+	} else {
+		given = strings.TrimSuffix(strings.TrimSpace(author), family)
+	}
+
+	var letters []string
+	for _, name := range strings.FieldsFunc(given, func(r rune) bool { return unicode.IsSpace(r) || r == '.' }) {
+		r, _ := utf8.DecodeRuneInString(name)
+		if strings.Contains(name, "-") {
+			// Jean-Paul becomes J.-P.
+			var parts []string
+			for _, part := range strings.Split(name, "-") {
+				if p, _ := utf8.DecodeRuneInString(part); part != "" {
+					parts = append(parts, string(p)+".")
+				}
+			}
+			letters = append(letters, strings.Join(parts, "-"))
+			continue
+		}
+		letters = append(letters, string(r)+".")
+	}
+	if len(letters) == 0 {
+		return family
+	}
+	return family + ", " + strings.Join(letters, " ")
+}
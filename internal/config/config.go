@@ -1,89 +1,554 @@
 package config
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"net"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"inkwell/internal/backup"
+	"inkwell/internal/git"
+	"inkwell/internal/recents"
+	"inkwell/internal/storage"
+	autosync "inkwell/internal/sync"
 )
 
 // Config holds the application configuration
 type Config struct {
 	RootDir     string // Directory to serve markdown files from
+	Host        string // Interface to listen on; empty for all
 	Port        int    // HTTP server port
+	PortRange   string // Range scanned for a free port when Port is unset (e.g. 8000-8100)
+	PrintURL    bool   // Print only the URL on stdout once the server is listening
 	Theme       string // Initial theme (light/dark)
 	NoBrowser   bool   // Don't auto-open browser
 	InitialFile string // Initial file to open (if specified)
+	NoMath      bool   // Disable math rendering in HTML output
+
+	InitialFiles   []string // Files opened in tabs at startup, relative to RootDir
+	RestoreSession bool     // Reopen the vault's tabs from its last run
+
+	PlantUMLServer string // PlantUML server used to render diagrams
+	PlantUMLJar    string // Local plantuml.jar used instead of a server
+
+	OnSave      []string      // Commands run after a file is saved
+	HookTimeout time.Duration // Maximum run time of each on-save command
+
+	ScanUploads string        // Command each upload is checked with before it is kept
+	ScanTimeout time.Duration // Maximum run time of the upload scanner
+
+	MaxBodySize    int64 // Maximum request body size in bytes
+	MaxUploadSize  int64 // Maximum multipart upload size in bytes
+	MaxChunkedSize int64 // Maximum total size of a chunked upload in bytes
+
+	ContentSecurityPolicy string // Replaces the built-in policy when set
+	FrameAncestors        string // Sources allowed to embed Inkwell in a frame
+	ReferrerPolicy        string // Referrer-Policy header value
+
+	Ignore []string // Name patterns left out of the file tree
+
+	Storage         string        // Remote storage URL the vault lives in (webdav://, webdavs:// or s3://); empty for local
+	StorageInterval time.Duration // How often remote storage is checked for changes made elsewhere
+
+	BackupDir      string        // Folder zip backups of the vault are kept in; empty disables them
+	BackupInterval time.Duration // How often the vault is backed up
+	BackupKeep     int           // Number of backups of the vault kept
+
+	AIProvider string // Language model used for writing help: openai or ollama; empty disables it
+	AIURL      string // Base URL of the provider's API (default: the provider's own)
+	AIModel    string // Model to ask (default: the provider's default)
+
+	EncryptVault bool   // Encrypt the contents of every file in the vault at rest
+	Passphrase   string // Unlocks an encrypted vault; set at startup, never from flags or files
+
+	RecentsLimit int    // Number of recent locations remembered
+	KeepMissing  bool   // Keep recent locations whose directory is gone, marked missing
+	SyncProfile  string // Profile file or directory shared with other machines
+
+	NoGit      bool   // Disable git integration
+	ReposDir   string // Where repositories are cloned (default ~/.inkwell/repos)
+	GitName    string // Default commit author name
+	GitEmail   string // Default commit author email
+	GitSignKey string // Armored OpenPGP private key commits are signed with
+
+	GitEmailDomain string // Domain of the commit email of signed-in users who haven't set one
+
+	GitHubClientID string // OAuth app for signing in to GitHub; empty disables it
+	GitLabClientID string // OAuth application for signing in to GitLab; empty disables it
+	GitLabURL      string // GitLab instance signed in to
+
+	GitSizeCheck     string // What to do when a commit holds large files: warn, block or off
+	GitMaxFileSize   int64  // Files larger than this are warned about; 0 disables
+	GitMaxCommitSize int64  // Commits holding more than this are warned about; 0 disables
+
+	HistoryCacheSize int64         // Memory for caching file versions and diffs from git history
+	FetchInterval    time.Duration // How often to sync with origin in the background; 0 never
+	AutoSync         string        // How far background syncs go: fetch, pull or push
+	GitTimeout       time.Duration // Longest a push, pull or fetch may take; 0 never gives up
+
+	LogLevel  string // Minimum level logged: debug, info, warn or error
+	LogFormat string // Log output format: text or json
+	LogFile   string // File logs are appended to instead of stderr
+
+	Debug bool // Serve pprof profiles and runtime stats
+
+	ConfigFile string            // Configuration file that was loaded, if any
+	Sources    map[string]string // Where each option's value came from, by flag name
+	Options    []Option          // Effective value of every option, for display
+
+	ShowVersion bool // Print the version and exit
+}
+
+// Default request size limits
+const (
+	DefaultMaxBodySize    = 10 << 20 // 10MB
+	DefaultMaxUploadSize  = 10 << 20 // 10MB
+	DefaultMaxChunkedSize = 2 << 30  // 2GB
+)
+
+// Default security header values. Framing is denied unless allowed with
+// --frame-ancestors.
+const (
+	DefaultFrameAncestors = "'none'"
+	DefaultReferrerPolicy = "same-origin"
+)
+
+// stringList is a flag value that can be given multiple times
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// byteSize is a flag value accepting sizes such as 512KB, 10MB or 1GB
+type byteSize int64
+
+func (b *byteSize) String() string {
+	return strconv.FormatInt(int64(*b), 10)
+}
+
+func (b *byteSize) Set(value string) error {
+	n, err := ParseByteSize(value)
+	if err != nil {
+		return err
+	}
+	*b = byteSize(n)
+	return nil
+}
+
+// ParseByteSize parses a size with an optional B, KB, MB or GB suffix
+func ParseByteSize(value string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(value))
+
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		size   int64
+	}{
+		{"GB", 1 << 30},
+		{"MB", 1 << 20},
+		{"KB", 1 << 10},
+		{"B", 1},
+	} {
+		if strings.HasSuffix(s, unit.suffix) {
+			s = strings.TrimSpace(strings.TrimSuffix(s, unit.suffix))
+			multiplier = unit.size
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid size: %s", value)
+	}
+	return n * multiplier, nil
+}
+
+// flagValues holds options as set by flags, the environment and the
+// configuration file, before they are copied into a Config
+type flagValues struct {
+	version        bool
+	config         string
+	host           string
+	port           int
+	portRange      string
+	printURL       bool
+	restoreSession bool
+	theme          string
+	noBrowser      bool
+	noMath         bool
+	plantumlServer string
+	plantumlJar    string
+	onSave         stringList
+	hookTimeout    time.Duration
+	scanUploads    string
+	scanTimeout    time.Duration
+	maxBody        byteSize
+	maxUpload      byteSize
+	maxChunked     byteSize
+	historyCache   byteSize
+	gitSizeCheck   string
+	gitMaxFile     byteSize
+	gitMaxCommit   byteSize
+	fetchInterval  time.Duration
+	autoSync       string
+	gitTimeout     time.Duration
+	csp            string
+	frameAncestors string
+	referrerPolicy string
+	ignore         stringList
+	encryptVault   bool
+	storage        string
+	storageSync    time.Duration
+	backupDir      string
+	backupInterval time.Duration
+	backupKeep     int
+	aiProvider     string
+	aiURL          string
+	aiModel        string
+	recents        int
+	keepMissing    bool
+	syncProfile    string
+	noGit          bool
+	reposDir       string
+	gitName        string
+	gitEmail       string
+	gitSign        string
+	gitEmailDomain string
+	githubClientID string
+	gitlabClientID string
+	gitlabURL      string
+	logLevel       string
+	logFormat      string
+	logFile        string
+	debug          bool
+}
+
+// register defines every option on fs
+func (v *flagValues) register(fs *flag.FlagSet) {
+	v.maxBody = byteSize(DefaultMaxBodySize)
+	v.maxUpload = byteSize(DefaultMaxUploadSize)
+	v.maxChunked = byteSize(DefaultMaxChunkedSize)
+	v.historyCache = byteSize(git.DefaultHistoryCacheSize)
+	v.gitMaxFile = byteSize(git.DefaultMaxFileSize)
+	v.gitMaxCommit = byteSize(git.DefaultMaxCommitSize)
+
+	fs.BoolVar(&v.version, "version", false, "Print the version and exit")
+	fs.StringVar(&v.config, configFlagName, "", "Configuration file (default: ~/.config/inkwell/config.toml)")
+	fs.StringVar(&v.host, "host", "", "Interface to listen on (default: all)")
+	fs.IntVar(&v.port, "port", 0, "HTTP server port; fails if it is in use (default: random available)")
+	fs.StringVar(&v.portRange, "port-range", "", "Use the first free port in this range (e.g. 8000-8100)")
+	fs.BoolVar(&v.printURL, "print-url", false, "Print only the server URL on stdout, for scripts")
+	fs.StringVar(&v.theme, "theme", "light", "Initial theme (light/dark)")
+	fs.BoolVar(&v.noBrowser, "no-browser", false, "Don't auto-open browser")
+	fs.BoolVar(&v.restoreSession, "restore-session", false, "Reopen the tabs that were open when the vault was last used")
+	fs.BoolVar(&v.noMath, "no-math", false, "Disable $...$ math rendering in HTML output")
+	fs.StringVar(&v.plantumlServer, "plantuml-server", "", "PlantUML server URL for diagram rendering (e.g. https://www.plantuml.com/plantuml)")
+	fs.StringVar(&v.plantumlJar, "plantuml-jar", "", "Path to a local plantuml.jar (requires java)")
+	fs.Var(&v.onSave, "on-save", "Command to run after a file is saved; repeatable. Placeholders: {path} {file} {dir} {name} {root}")
+	fs.DurationVar(&v.hookTimeout, "hook-timeout", 30*time.Second, "Maximum run time of each on-save command")
+	fs.StringVar(&v.scanUploads, "scan-uploads", "", "Command that checks each upload before it is kept, rejecting it with a non-zero exit (e.g. \"clamscan --no-summary {file}\"). Placeholders: {file} {name}")
+	fs.DurationVar(&v.scanTimeout, "scan-timeout", 2*time.Minute, "Maximum run time of the upload scanner; uploads it doesn't finish are rejected")
+	fs.Var(&v.maxBody, "max-body-size", "Maximum request body size (e.g. 512KB, 10MB)")
+	fs.Var(&v.maxUpload, "max-upload-size", "Maximum file upload size (e.g. 10MB, 1GB)")
+	fs.Var(&v.maxChunked, "max-chunked-upload-size", "Maximum total size of a resumable chunked upload (e.g. 2GB)")
+	fs.StringVar(&v.csp, "csp", "", "Content-Security-Policy to send instead of the built-in one")
+	fs.StringVar(&v.frameAncestors, "frame-ancestors", DefaultFrameAncestors, "Sources allowed to embed Inkwell in an iframe (e.g. \"'self' https://portal.example.com\")")
+	fs.StringVar(&v.referrerPolicy, "referrer-policy", DefaultReferrerPolicy, "Referrer-Policy header value")
+	fs.Var(&v.ignore, "ignore", "File or folder name pattern to hide from the file tree (e.g. node_modules, *.draft.md); repeatable")
+	fs.BoolVar(&v.noGit, "no-git", false, "Disable git integration: no git panel, cloning or repository detection")
+	fs.StringVar(&v.reposDir, "repos-dir", "", "Directory repositories are cloned into; existing clones are moved there (default: ~/.inkwell/repos)")
+	fs.StringVar(&v.gitName, "git-name", "", "Default commit author name (default: user.name from git config)")
+	fs.StringVar(&v.gitEmail, "git-email", "", "Default commit author email (default: user.email from git config)")
+	fs.StringVar(&v.gitSign, "git-sign", "", "Sign commits with this ASCII-armored OpenPGP private key file")
+	fs.StringVar(&v.gitEmailDomain, "git-email-domain", "", "Domain of the commit email of signed-in users who haven't set one (default: inkwell.local)")
+	fs.StringVar(&v.githubClientID, "github-client-id", "", "Client ID of a GitHub OAuth app with device flow enabled, for signing in to GitHub for HTTPS remotes")
+	fs.StringVar(&v.gitlabClientID, "gitlab-client-id", "", "Client ID of a GitLab OAuth application, for signing in to GitLab for HTTPS remotes")
+	fs.StringVar(&v.gitlabURL, "gitlab-url", "https://gitlab.com", "GitLab instance signed in to with --gitlab-client-id")
+	fs.StringVar(&v.gitSizeCheck, "git-size-check", git.SizeCheckWarn, "When a commit holds large files or media better kept in Git LFS: warn, block (unless forced) or off")
+	fs.Var(&v.gitMaxFile, "git-max-file-size", "Largest file a commit may hold without a warning (e.g. 10MB; 0 disables)")
+	fs.Var(&v.gitMaxCommit, "git-max-commit-size", "Most a commit may hold in total without a warning (e.g. 100MB; 0 disables)")
+	fs.Var(&v.historyCache, "history-cache", "Memory for caching file versions and diffs from git history (e.g. 64MB; 0 disables)")
+	fs.DurationVar(&v.fetchInterval, "fetch-interval", 0, "Fetch from origin this often while idle, keeping the behind count fresh (e.g. 10m; 0 disables)")
+	fs.StringVar(&v.autoSync, "auto-sync", string(autosync.ModeFetch), "What each --fetch-interval does: fetch, pull (also fast-forward when nothing is changed locally) or push (also push local commits)")
+	fs.DurationVar(&v.gitTimeout, "git-timeout", git.DefaultNetworkTimeout, "Give up on a push, pull or fetch that takes longer than this (0 waits forever)")
+	fs.BoolVar(&v.encryptVault, "encrypt-vault", false, "Encrypt the contents of every file in the vault at rest, asking for the passphrase at startup")
+	fs.StringVar(&v.storage, "storage", "", "Edit a vault in remote storage, cached locally (e.g. webdavs://user@cloud.example.com/remote.php/dav/files/user/notes, s3://bucket/notes)")
+	fs.DurationVar(&v.storageSync, "storage-interval", storage.DefaultInterval, "How often remote storage is checked for changes made elsewhere")
+	fs.StringVar(&v.backupDir, "backup-dir", "", "Back up the vault to zip files in this folder, for vaults not kept in git")
+	fs.DurationVar(&v.backupInterval, "backup-interval", backup.DefaultInterval, "How often the vault is backed up with --backup-dir")
+	fs.IntVar(&v.backupKeep, "backup-keep", backup.DefaultKeep, "Number of backups kept, the oldest being deleted first")
+	fs.StringVar(&v.aiProvider, "ai", "", "Offer commit messages and note summaries from a language model: openai (any compatible API) or ollama. The key is read from INKWELL_AI_KEY or OPENAI_API_KEY")
+	fs.StringVar(&v.aiURL, "ai-url", "", "Base URL of the language model API (default: https://api.openai.com/v1 or http://localhost:11434)")
+	fs.StringVar(&v.aiModel, "ai-model", "", "Language model to use (default: gpt-4o-mini or llama3.2)")
+	fs.IntVar(&v.recents, "recents", recents.DefaultLimit, "Number of recently opened locations to remember")
+	fs.BoolVar(&v.keepMissing, "keep-missing-recents", false, "Keep recent locations whose directory no longer exists, marked as missing")
+	fs.StringVar(&v.syncProfile, "sync-profile", "", "Share recents, favorites and settings through a profile in this synced folder or git repository")
+	fs.StringVar(&v.logLevel, "log-level", "info", "Minimum log level (debug/info/warn/error)")
+	fs.StringVar(&v.logFormat, "log-format", "text", "Log output format (text/json)")
+	fs.StringVar(&v.logFile, "log-file", "", "Append logs to this file instead of stderr")
+	fs.BoolVar(&v.debug, "debug", false, "Serve pprof profiles at /debug/pprof/ and runtime stats at /api/debug/stats")
 }
 
 var (
 	flagsInitialized bool
-	portFlag         int
-	themeFlag        string
-	noBrowserFlag    bool
+	flags            flagValues
 )
 
 func initFlags() {
 	if flagsInitialized {
 		return
 	}
-	flag.IntVar(&portFlag, "port", 0, "HTTP server port (default: random available)")
-	flag.StringVar(&themeFlag, "theme", "light", "Initial theme (light/dark)")
-	flag.BoolVar(&noBrowserFlag, "no-browser", false, "Don't auto-open browser")
+	flags.register(flag.CommandLine)
 	flagsInitialized = true
 }
 
-// Parse parses command line arguments and returns a Config
+// Parse parses command line arguments and returns a Config. Options not
+// given as flags are taken from INKWELL_* environment variables, then the
+// configuration file, then their defaults.
 func Parse() (*Config, error) {
 	initFlags()
 	cfg := &Config{}
 
 	flag.Parse()
 
-	cfg.Port = portFlag
-	cfg.Theme = themeFlag
-	cfg.NoBrowser = noBrowserFlag
+	if flags.version {
+		return &Config{ShowVersion: true}, nil
+	}
+
+	var err error
+	if cfg.ConfigFile, cfg.Sources, err = loadSources(flag.CommandLine, flags.config); err != nil {
+		return nil, err
+	}
+	cfg.Options = listOptions(flag.CommandLine, cfg.Sources)
+
+	cfg.Host = flags.host
+	cfg.Port = flags.port
+	cfg.PortRange = flags.portRange
+	cfg.PrintURL = flags.printURL
+	cfg.Theme = flags.theme
+	cfg.NoBrowser = flags.noBrowser
+	cfg.NoMath = flags.noMath
+	cfg.RestoreSession = flags.restoreSession
+	cfg.PlantUMLServer = flags.plantumlServer
+	cfg.PlantUMLJar = flags.plantumlJar
+	cfg.OnSave = flags.onSave
+	cfg.HookTimeout = flags.hookTimeout
+	cfg.ScanUploads = flags.scanUploads
+	cfg.ScanTimeout = flags.scanTimeout
+	cfg.MaxBodySize = int64(flags.maxBody)
+	cfg.MaxUploadSize = int64(flags.maxUpload)
+	cfg.MaxChunkedSize = int64(flags.maxChunked)
+	cfg.ContentSecurityPolicy = flags.csp
+	cfg.FrameAncestors = flags.frameAncestors
+	cfg.ReferrerPolicy = flags.referrerPolicy
+	cfg.Ignore = flags.ignore
+	cfg.EncryptVault = flags.encryptVault
+	cfg.Storage = flags.storage
+	cfg.StorageInterval = flags.storageSync
+	cfg.BackupDir = flags.backupDir
+	cfg.BackupInterval = flags.backupInterval
+	cfg.BackupKeep = flags.backupKeep
+	cfg.AIProvider = flags.aiProvider
+	cfg.AIURL = flags.aiURL
+	cfg.AIModel = flags.aiModel
+	cfg.RecentsLimit = flags.recents
+	cfg.KeepMissing = flags.keepMissing
+	cfg.SyncProfile = flags.syncProfile
+	cfg.NoGit = flags.noGit
+	cfg.HistoryCacheSize = int64(flags.historyCache)
+	cfg.FetchInterval = flags.fetchInterval
+	cfg.AutoSync = flags.autoSync
+	cfg.GitTimeout = flags.gitTimeout
+	cfg.ReposDir = flags.reposDir
+	cfg.GitName = flags.gitName
+	cfg.GitEmail = flags.gitEmail
+	cfg.GitSignKey = flags.gitSign
+	cfg.GitEmailDomain = flags.gitEmailDomain
+	cfg.GitHubClientID = flags.githubClientID
+	cfg.GitLabClientID = flags.gitlabClientID
+	cfg.GitLabURL = flags.gitlabURL
+	cfg.GitSizeCheck = flags.gitSizeCheck
+	cfg.GitMaxFileSize = int64(flags.gitMaxFile)
+	cfg.GitMaxCommitSize = int64(flags.gitMaxCommit)
+	cfg.LogLevel = flags.logLevel
+	cfg.LogFormat = flags.logFormat
+	cfg.LogFile = flags.logFile
+	cfg.Debug = flags.debug
 
-	// Get the directory/file argument
+	// Get the directory/file arguments
 	args := flag.Args()
-	var targetPath string
-	if len(args) > 0 {
-		targetPath = args[0]
-	} else {
-		targetPath = "."
+	if len(args) == 0 {
+		args = []string{"."}
+	}
+
+	if err := cfg.setTarget(args[0]); err != nil {
+		return nil, err
+	}
+	for _, file := range args[1:] {
+		if err := cfg.addInitialFile(file); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.AssignPort(); err != nil {
+		return nil, err
 	}
 
+	return cfg, nil
+}
+
+// New returns a Config with default settings for the given directory or
+// file. It is used when Inkwell is embedded rather than run from the CLI.
+func New(path string) (*Config, error) {
+	cfg := &Config{
+		Theme:          "light",
+		MaxBodySize:    DefaultMaxBodySize,
+		MaxUploadSize:  DefaultMaxUploadSize,
+		MaxChunkedSize: DefaultMaxChunkedSize,
+		FrameAncestors: DefaultFrameAncestors,
+		ReferrerPolicy: DefaultReferrerPolicy,
+
+		HistoryCacheSize: git.DefaultHistoryCacheSize,
+		GitSizeCheck:     git.SizeCheckWarn,
+		GitMaxFileSize:   git.DefaultMaxFileSize,
+		GitMaxCommitSize: git.DefaultMaxCommitSize,
+		GitTimeout:       git.DefaultNetworkTimeout,
+		StorageInterval:  storage.DefaultInterval,
+		BackupInterval:   backup.DefaultInterval,
+		BackupKeep:       backup.DefaultKeep,
+	}
+
+	if err := cfg.setTarget(path); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// setTarget sets RootDir (and InitialFile if path is a file)
+func (c *Config) setTarget(targetPath string) error {
 	// Resolve to absolute path
 	absPath, err := filepath.Abs(targetPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to resolve path: %w", err)
+		return fmt.Errorf("failed to resolve path: %w", err)
 	}
 
 	// Check if path exists
 	info, err := os.Stat(absPath)
 	if err != nil {
-		return nil, fmt.Errorf("path does not exist: %w", err)
+		return fmt.Errorf("path does not exist: %w", err)
 	}
 
 	// If it's a file, set the root to parent dir and remember the file
 	if info.IsDir() {
-		cfg.RootDir = absPath
+		c.RootDir = absPath
 	} else {
-		cfg.RootDir = filepath.Dir(absPath)
-		cfg.InitialFile = filepath.Base(absPath)
+		c.RootDir = filepath.Dir(absPath)
+		c.InitialFile = filepath.Base(absPath)
+		c.InitialFiles = []string{c.InitialFile}
+	}
+
+	return nil
+}
+
+// addInitialFile opens another file at startup. It must be inside RootDir.
+func (c *Config) addInitialFile(path string) error {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("failed to resolve path: %w", err)
+	}
+
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return fmt.Errorf("path does not exist: %w", err)
 	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is a directory; only the first argument can be one", path)
+	}
+
+	rel, err := filepath.Rel(c.RootDir, absPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("%s is outside %s", path, c.RootDir)
+	}
+
+	rel = filepath.ToSlash(rel)
+	if c.InitialFile == "" {
+		c.InitialFile = rel
+	}
+	c.InitialFiles = append(c.InitialFiles, rel)
+	return nil
+}
 
-	// If no port specified, find an available one
-	if cfg.Port == 0 {
-		port, err := findAvailablePort()
+// AssignPort checks that a configured port is free, or otherwise picks one
+// from PortRange or lets the OS choose
+func (c *Config) AssignPort() error {
+	if c.Port != 0 {
+		if c.PortRange != "" {
+			return errors.New("--port and --port-range cannot be used together")
+		}
+		if !portAvailable(c.Host, c.Port) {
+			return fmt.Errorf("port %d is already in use", c.Port)
+		}
+		return nil
+	}
+
+	if c.PortRange != "" {
+		low, high, err := ParsePortRange(c.PortRange)
 		if err != nil {
-			return nil, fmt.Errorf("failed to find available port: %w", err)
+			return err
 		}
-		cfg.Port = port
+		for port := low; port <= high; port++ {
+			if portAvailable(c.Host, port) {
+				c.Port = port
+				return nil
+			}
+		}
+		return fmt.Errorf("no free port in range %d-%d", low, high)
 	}
 
-	return cfg, nil
+	port, err := findAvailablePort()
+	if err != nil {
+		return fmt.Errorf("failed to find available port: %w", err)
+	}
+	c.Port = port
+	return nil
+}
+
+// ParsePortRange parses a range such as 8000-8100
+func ParsePortRange(value string) (low, high int, err error) {
+	lowStr, highStr, ok := strings.Cut(value, "-")
+	if ok {
+		low, err = strconv.Atoi(strings.TrimSpace(lowStr))
+		if err == nil {
+			high, err = strconv.Atoi(strings.TrimSpace(highStr))
+		}
+	}
+	if !ok || err != nil || low < 1 || high > 65535 || low > high {
+		return 0, 0, fmt.Errorf("invalid port range: %s", value)
+	}
+	return low, high, nil
+}
+
+// portAvailable reports whether the port can be listened on
+func portAvailable(host string, port int) bool {
+	listener, err := net.Listen("tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		return false
+	}
+	listener.Close()
+	return true
 }
 
 // findAvailablePort finds an available port to listen on
@@ -98,9 +563,18 @@ func findAvailablePort() (int, error) {
 
 // URL returns the full URL to access the application
 func (c *Config) URL() string {
-	url := fmt.Sprintf("http://localhost:%d", c.Port)
-	if c.InitialFile != "" {
-		url += fmt.Sprintf("?file=%s", c.InitialFile)
+	files := c.InitialFiles
+	if len(files) == 0 && c.InitialFile != "" {
+		files = []string{c.InitialFile}
+	}
+
+	u := fmt.Sprintf("http://localhost:%d", c.Port)
+	for i, file := range files {
+		sep := "&"
+		if i == 0 {
+			sep = "?"
+		}
+		u += sep + "file=" + url.QueryEscape(file)
 	}
-	return url
+	return u
 }
@@ -1,6 +1,8 @@
 package config
 
 import (
+	"fmt"
+	"net"
 	"os"
 	"path/filepath"
 	"testing"
@@ -38,6 +40,15 @@ func TestConfigURL(t *testing.T) {
 			},
 			expectedURL: "http://localhost:3000?file=readme.md",
 		},
+		{
+			name: "URLWithInitialFiles",
+			cfg: Config{
+				Port:         3000,
+				InitialFile:  "a.md",
+				InitialFiles: []string{"a.md", "notes/b & c.md"},
+			},
+			expectedURL: "http://localhost:3000?file=a.md&file=notes%2Fb+%26+c.md",
+		},
 	}
 
 	for _, tt := range tests {
@@ -115,3 +126,108 @@ func TestConfigParseWithFile(t *testing.T) {
 		t.Errorf("InitialFile = %q, want %q", cfg.InitialFile, "test.md")
 	}
 }
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    int64
+		wantErr bool
+	}{
+		{"1024", 1024, false},
+		{"512KB", 512 << 10, false},
+		{"10mb", 10 << 20, false},
+		{"1 GB", 1 << 30, false},
+		{"100B", 100, false},
+		{"", 0, true},
+		{"-1MB", 0, true},
+		{"ten", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseByteSize(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseByteSize(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseByteSize(%q) = %d, want %d", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePortRange(t *testing.T) {
+	low, high, err := ParsePortRange("8000-8100")
+	if err != nil || low != 8000 || high != 8100 {
+		t.Errorf("ParsePortRange = %d, %d, %v", low, high, err)
+	}
+
+	for _, value := range []string{"8000", "9000-8000", "0-10", "a-b", "1-70000"} {
+		if _, _, err := ParsePortRange(value); err == nil {
+			t.Errorf("ParsePortRange(%q) should fail", value)
+		}
+	}
+}
+
+func TestAssignPortInUse(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	busy := listener.Addr().(*net.TCPAddr).Port
+
+	cfg := &Config{Host: "127.0.0.1", Port: busy}
+	if err := cfg.AssignPort(); err == nil {
+		t.Error("AssignPort should fail for a port in use")
+	}
+
+	cfg = &Config{Host: "127.0.0.1", PortRange: fmt.Sprintf("%d-%d", busy, busy+20)}
+	if err := cfg.AssignPort(); err != nil {
+		t.Fatalf("AssignPort failed: %v", err)
+	}
+	if cfg.Port <= busy || cfg.Port > busy+20 {
+		t.Errorf("Port = %d, want one in %d-%d other than %d", cfg.Port, busy, busy+20, busy)
+	}
+
+	cfg = &Config{Port: 8080, PortRange: "8000-8100"}
+	if err := cfg.AssignPort(); err == nil {
+		t.Error("AssignPort should reject --port with --port-range")
+	}
+}
+
+func TestConfigParseWithMultipleFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, name := range []string{"a.md", "notes/b.md"} {
+		path := filepath.Join(tmpDir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte("# Test"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	os.Args = []string{"inkwell", tmpDir, filepath.Join(tmpDir, "notes", "b.md"), filepath.Join(tmpDir, "a.md")}
+	cfg, err := Parse()
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(cfg.InitialFiles) != 2 || cfg.InitialFiles[0] != "notes/b.md" || cfg.InitialFiles[1] != "a.md" {
+		t.Errorf("InitialFiles = %v", cfg.InitialFiles)
+	}
+	if cfg.InitialFile != "notes/b.md" {
+		t.Errorf("InitialFile = %q", cfg.InitialFile)
+	}
+
+	os.Args = []string{"inkwell", filepath.Join(tmpDir, "notes", "b.md"), filepath.Join(tmpDir, "a.md")}
+	if _, err := Parse(); err == nil {
+		t.Error("Parse should reject files outside the first file's directory")
+	}
+
+	os.Args = []string{"inkwell", tmpDir, filepath.Join(tmpDir, "notes")}
+	if _, err := Parse(); err == nil {
+		t.Error("Parse should reject a directory after the first argument")
+	}
+}
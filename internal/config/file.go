@@ -0,0 +1,320 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"inkwell/internal/storage"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Where a configuration value came from, in increasing precedence
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
+
+// envPrefix starts the environment variable for each option, so --max-body-size
+// can also be given as INKWELL_MAX_BODY_SIZE
+const envPrefix = "INKWELL_"
+
+// configFlagName is the flag that names the configuration file
+const configFlagName = "config"
+
+// cliOnly lists flags that can only be given on the command line
+var cliOnly = map[string]bool{
+	configFlagName: true,
+	"version":      true,
+}
+
+// DefaultConfigPath returns ~/.config/inkwell/config.toml, honouring
+// $XDG_CONFIG_HOME
+func DefaultConfigPath() (string, error) {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "inkwell", "config.toml"), nil
+}
+
+// EnvName returns the environment variable for a flag
+func EnvName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// resolveConfigPath picks the configuration file from --config, then
+// $INKWELL_CONFIG, then the default location. required reports whether the
+// file was asked for explicitly and so must exist.
+func resolveConfigPath(flagValue string) (path string, required bool, err error) {
+	if flagValue != "" {
+		return flagValue, true, nil
+	}
+	if env := os.Getenv(EnvName(configFlagName)); env != "" {
+		return env, true, nil
+	}
+	path, err = DefaultConfigPath()
+	return path, false, err
+}
+
+// applySources fills every flag that wasn't given on the command line from
+// the environment or, failing that, the configuration file. It returns the
+// source of each option's value.
+func applySources(fs *flag.FlagSet, file map[string][]string, filePath string) (map[string]string, error) {
+	sources := make(map[string]string)
+	fs.Visit(func(f *flag.Flag) {
+		sources[f.Name] = SourceFlag
+	})
+
+	for name := range file {
+		if cliOnly[name] || fs.Lookup(name) == nil {
+			return nil, fmt.Errorf("unknown option %q in %s", name, filePath)
+		}
+	}
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || cliOnly[f.Name] || sources[f.Name] != "" {
+			return
+		}
+
+		if value, ok := os.LookupEnv(EnvName(f.Name)); ok {
+			if setErr := fs.Set(f.Name, value); setErr != nil {
+				err = fmt.Errorf("invalid value %q for %s: %w", value, EnvName(f.Name), setErr)
+				return
+			}
+			sources[f.Name] = SourceEnv
+			return
+		}
+
+		if values, ok := file[f.Name]; ok {
+			for _, value := range values {
+				if setErr := fs.Set(f.Name, value); setErr != nil {
+					err = fmt.Errorf("invalid value %q for %s in %s: %w", value, f.Name, filePath, setErr)
+					return
+				}
+			}
+			sources[f.Name] = SourceFile
+			return
+		}
+
+		sources[f.Name] = SourceDefault
+	})
+
+	return sources, err
+}
+
+// loadSources reads the configuration file named by the --config value
+// (or found by default) and applies it and the environment to fs. It
+// returns the file that was loaded, if any, and each option's source.
+func loadSources(fs *flag.FlagSet, configFlag string) (string, map[string]string, error) {
+	path, required, err := resolveConfigPath(configFlag)
+	if err != nil {
+		return "", nil, err
+	}
+	file, err := loadConfigFile(path, required)
+	if err != nil {
+		return "", nil, err
+	}
+	if file == nil {
+		path = ""
+	}
+
+	sources, err := applySources(fs, file, path)
+	if err != nil {
+		return "", nil, err
+	}
+	return path, sources, nil
+}
+
+// Option is an option's effective value and where it came from
+type Option struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+	Env    string `json:"env,omitempty"` // Environment variable that sets it
+	Usage  string `json:"usage"`
+}
+
+// listOptions describes every option in fs, in name order
+func listOptions(fs *flag.FlagSet, sources map[string]string) []Option {
+	var options []Option
+	fs.VisitAll(func(f *flag.Flag) {
+		if f.Name == "version" {
+			return
+		}
+		opt := Option{
+			Name:   f.Name,
+			Value:  f.Value.String(),
+			Source: sources[f.Name],
+			Usage:  f.Usage,
+		}
+		if f.Name == "storage" {
+			opt.Value = storage.Redact(opt.Value) // May hold a WebDAV password
+		}
+		if !cliOnly[f.Name] {
+			opt.Env = EnvName(f.Name)
+		}
+		if opt.Source == "" {
+			opt.Source = SourceDefault
+		}
+		options = append(options, opt)
+	})
+	return options
+}
+
+// Effective parses args as the command line would be and returns every
+// option's value and source, and the configuration file that was loaded
+func Effective(args []string) ([]Option, string, error) {
+	fs := flag.NewFlagSet("inkwell", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	var values flagValues
+	values.register(fs)
+
+	if err := fs.Parse(args); err != nil {
+		return nil, "", err
+	}
+
+	path, sources, err := loadSources(fs, values.config)
+	if err != nil {
+		return nil, "", err
+	}
+	return listOptions(fs, sources), path, nil
+}
+
+// readConfigFile parses a TOML configuration file into flag values. Keys
+// are flag names; tables are flattened with dashes, so a [git] table with
+// a name key sets --git-name. Arrays set repeatable flags once per item.
+func readConfigFile(path string) (map[string][]string, error) {
+	var raw map[string]interface{}
+	if _, err := toml.DecodeFile(path, &raw); err != nil {
+		return nil, err
+	}
+
+	values := make(map[string][]string)
+	if err := flattenConfig(values, "", raw); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return values, nil
+}
+
+// flattenConfig converts decoded TOML into flag-name keyed values
+func flattenConfig(values map[string][]string, prefix string, table map[string]interface{}) error {
+	keys := make([]string, 0, len(table))
+	for key := range table {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		name := strings.ReplaceAll(key, "_", "-")
+		if prefix != "" {
+			name = prefix + "-" + name
+		}
+
+		switch v := table[key].(type) {
+		case map[string]interface{}:
+			if err := flattenConfig(values, name, v); err != nil {
+				return err
+			}
+		case []interface{}:
+			list := make([]string, 0, len(v))
+			for _, item := range v {
+				s, err := configScalar(name, item)
+				if err != nil {
+					return err
+				}
+				list = append(list, s)
+			}
+			values[name] = list
+		default:
+			s, err := configScalar(name, v)
+			if err != nil {
+				return err
+			}
+			values[name] = []string{s}
+		}
+	}
+	return nil
+}
+
+// configScalar formats a single TOML value as flag text
+func configScalar(name string, value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case int64, float64, bool:
+		return fmt.Sprint(v), nil
+	default:
+		return "", fmt.Errorf("unsupported value for %s", name)
+	}
+}
+
+// loadConfigFile reads the configuration file, treating a missing default
+// file as empty
+func loadConfigFile(path string, required bool) (map[string][]string, error) {
+	values, err := readConfigFile(path)
+	if err != nil {
+		if !required && errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to load config file: %w", err)
+	}
+	return values, nil
+}
+
+// ValidateFile checks that a configuration file parses and that every
+// option in it exists and has a valid value
+func ValidateFile(path string) error {
+	file, err := readConfigFile(path)
+	if err != nil {
+		return err
+	}
+
+	fs := flag.NewFlagSet("inkwell", flag.ContinueOnError)
+	var values flagValues
+	values.register(fs)
+
+	names := make([]string, 0, len(file))
+	for name := range file {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if cliOnly[name] || fs.Lookup(name) == nil {
+			return fmt.Errorf("unknown option %q in %s", name, path)
+		}
+		for _, value := range file[name] {
+			if err := fs.Set(name, value); err != nil {
+				return fmt.Errorf("invalid value %q for %s in %s: %w", value, name, path, err)
+			}
+		}
+	}
+	return nil
+}
+
+// FindConfigFile returns the configuration file Parse would load for the
+// given --config value, or "" if there is none
+func FindConfigFile(flagValue string) (string, error) {
+	path, required, err := resolveConfigPath(flagValue)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(path); err != nil && !required {
+		return "", nil
+	}
+	return path, nil
+}
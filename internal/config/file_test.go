@@ -0,0 +1,175 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	content := `
+port = 8080
+theme = "dark"
+no_browser = true
+on-save = ["make", "lint {path}"]
+
+[git]
+name = "Ada"
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	values, err := readConfigFile(path)
+	if err != nil {
+		t.Fatalf("readConfigFile failed: %v", err)
+	}
+
+	want := map[string][]string{
+		"port":       {"8080"},
+		"theme":      {"dark"},
+		"no-browser": {"true"},
+		"on-save":    {"make", "lint {path}"},
+		"git-name":   {"Ada"},
+	}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("readConfigFile = %v, want %v", values, want)
+	}
+}
+
+func TestApplySourcesPrecedence(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	port := fs.Int("port", 0, "")
+	theme := fs.String("theme", "light", "")
+	host := fs.String("host", "", "")
+	var hooks stringList
+	fs.Var(&hooks, "on-save", "")
+	fs.Bool("no-math", false, "")
+
+	if err := fs.Parse([]string{"--port", "9000"}); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("INKWELL_THEME", "dark-nord")
+
+	file := map[string][]string{
+		"port":    {"8080"},
+		"theme":   {"light-ocean"},
+		"host":    {"127.0.0.1"},
+		"on-save": {"a", "b"},
+	}
+
+	sources, err := applySources(fs, file, "config.toml")
+	if err != nil {
+		t.Fatalf("applySources failed: %v", err)
+	}
+
+	if *port != 9000 || *theme != "dark-nord" || *host != "127.0.0.1" {
+		t.Errorf("Got port=%d theme=%s host=%s", *port, *theme, *host)
+	}
+	if !reflect.DeepEqual([]string(hooks), []string{"a", "b"}) {
+		t.Errorf("on-save = %v", hooks)
+	}
+
+	want := map[string]string{
+		"port":    SourceFlag,
+		"theme":   SourceEnv,
+		"host":    SourceFile,
+		"on-save": SourceFile,
+		"no-math": SourceDefault,
+	}
+	if !reflect.DeepEqual(sources, want) {
+		t.Errorf("sources = %v, want %v", sources, want)
+	}
+}
+
+func TestApplySourcesErrors(t *testing.T) {
+	newFlags := func() *flag.FlagSet {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.Int("port", 0, "")
+		return fs
+	}
+
+	if _, err := applySources(newFlags(), map[string][]string{"colour": {"red"}}, "config.toml"); err == nil {
+		t.Error("Unknown option should fail")
+	}
+	if _, err := applySources(newFlags(), map[string][]string{"port": {"high"}}, "config.toml"); err == nil {
+		t.Error("Invalid value should fail")
+	}
+}
+
+func TestLoadConfigFileMissing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing.toml")
+
+	if values, err := loadConfigFile(path, false); err != nil || values != nil {
+		t.Errorf("Missing default file: %v, %v", values, err)
+	}
+	if _, err := loadConfigFile(path, true); err == nil {
+		t.Error("Missing explicit file should fail")
+	}
+}
+
+func TestValidateFile(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	if err := ValidateFile(write("good.toml", "port = 8080\nmax-body-size = \"5MB\"\nignore = [\"drafts\"]\n")); err != nil {
+		t.Errorf("Valid file rejected: %v", err)
+	}
+	if err := ValidateFile(write("unknown.toml", "colour = \"red\"\n")); err == nil {
+		t.Error("Unknown option should fail")
+	}
+	if err := ValidateFile(write("value.toml", "max-body-size = \"lots\"\n")); err == nil {
+		t.Error("Invalid value should fail")
+	}
+	if err := ValidateFile(write("syntax.toml", "port = \n")); err == nil {
+		t.Error("Syntax error should fail")
+	}
+}
+
+func TestEffective(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte("theme = \"dark\"\nport = 8080\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("INKWELL_PORT", "9000")
+
+	options, used, err := Effective([]string{"--config", path, "--no-browser"})
+	if err != nil {
+		t.Fatalf("Effective failed: %v", err)
+	}
+	if used != path {
+		t.Errorf("Config file = %q, want %q", used, path)
+	}
+
+	byName := make(map[string]Option)
+	for _, opt := range options {
+		byName[opt.Name] = opt
+	}
+	want := map[string][2]string{
+		"theme":      {"dark", SourceFile},
+		"port":       {"9000", SourceEnv},
+		"no-browser": {"true", SourceFlag},
+		"log-level":  {"info", SourceDefault},
+	}
+	for name, w := range want {
+		if got := byName[name]; got.Value != w[0] || got.Source != w[1] {
+			t.Errorf("%s = %q from %s, want %q from %s", name, got.Value, got.Source, w[0], w[1])
+		}
+	}
+	if byName["port"].Env != "INKWELL_PORT" || byName["config"].Env != "" {
+		t.Errorf("Unexpected env names: %+v %+v", byName["port"], byName["config"])
+	}
+
+	if _, _, err := Effective([]string{"--bogus"}); err == nil {
+		t.Error("Effective should reject unknown flags")
+	}
+}
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"inkwell/internal/filesystem"
+	"inkwell/internal/render"
+)
+
+// VaultConfigFile is the per-vault configuration, relative to the vault
+// root. It is meant to be committed so everyone working on the vault
+// shares it.
+const VaultConfigFile = ".inkwell/config.json"
+
+// VaultConfig holds settings that belong to a vault's content. Set fields
+// override the user's configuration while the vault is open.
+type VaultConfig struct {
+	Extensions   []string      `json:"extensions,omitempty"`   // Note file extensions, e.g. [".md", ".mdx"]
+	Ignore       []string      `json:"ignore,omitempty"`       // Replaces the --ignore patterns
+	AssetsDir    string        `json:"assetsDir,omitempty"`    // Folder for uploaded images
+	AssetLayout  string        `json:"assetLayout,omitempty"`  // "flat", or "per-note" for a folder of images per note
+	TemplatesDir string        `json:"templatesDir,omitempty"` // Folder of note templates
+	Export       ExportConfig  `json:"export"`
+	Publish      PublishConfig `json:"publish"`
+}
+
+// ExportConfig holds options for HTML export and printing
+type ExportConfig struct {
+	Math         *bool  `json:"math,omitempty"`         // Overrides --no-math
+	Bibliography string `json:"bibliography,omitempty"` // BibTeX or CSL JSON file citations resolve against; references.bib and friends when empty
+	TOC          bool   `json:"toc,omitempty"`          // Put a table of contents at the top of each page
+	Anchors      bool   `json:"anchors,omitempty"`      // Give each heading a link to itself
+	Slug         string `json:"slug,omitempty"`         // How heading IDs are made: goldmark, github or pandoc
+}
+
+// Navigation returns the table of contents and anchor options
+func (e ExportConfig) Navigation() render.Navigation {
+	return render.Navigation{TOC: e.TOC, Anchors: e.Anchors, Slug: e.Slug}
+}
+
+// PublishConfig says where POST /api/publish sends the rendered vault
+type PublishConfig struct {
+	Branch string `json:"branch,omitempty"` // Defaults to gh-pages
+	Repo   string `json:"repo,omitempty"`   // Separate repository to push to; the vault's origin when empty
+	Folder string `json:"folder,omitempty"` // Part of the vault to publish; all of it when empty
+}
+
+// LoadVault reads the vault configuration under rootDir. A vault without
+// one gets an empty configuration.
+func LoadVault(rootDir string) (*VaultConfig, error) {
+	vc := &VaultConfig{}
+
+	data, err := os.ReadFile(filepath.Join(rootDir, filepath.FromSlash(VaultConfigFile)))
+	if errors.Is(err, os.ErrNotExist) {
+		return vc, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, vc); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", VaultConfigFile, err)
+	}
+	if err := vc.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", VaultConfigFile, err)
+	}
+
+	return vc, nil
+}
+
+// Validate checks that extensions are well formed and folders stay inside
+// the vault
+func (vc *VaultConfig) Validate() error {
+	for _, ext := range vc.Extensions {
+		if len(ext) < 2 || ext[0] != '.' || strings.ContainsAny(ext[1:], `/\`) {
+			return fmt.Errorf("invalid extension %q", ext)
+		}
+	}
+
+	for _, dir := range []string{vc.AssetsDir, vc.TemplatesDir, vc.Publish.Folder} {
+		if dir == "" {
+			continue
+		}
+		clean := filepath.Clean(filepath.FromSlash(dir))
+		if filepath.IsAbs(dir) || clean == "." || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("folder %q must be inside the vault", dir)
+		}
+	}
+
+	if bib := vc.Export.Bibliography; bib != "" {
+		clean := filepath.Clean(filepath.FromSlash(bib))
+		if filepath.IsAbs(bib) || clean == "." || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("bibliography %q must be inside the vault", bib)
+		}
+	}
+	if !filesystem.ValidAssetLayout(vc.AssetLayout) {
+		return fmt.Errorf("unknown asset layout %q (use %s or %s)", vc.AssetLayout, filesystem.AssetLayoutFlat, filesystem.AssetLayoutPerNote)
+	}
+	if !render.ValidSlug(vc.Export.Slug) {
+		return fmt.Errorf("unknown heading slug %q (use %s)", vc.Export.Slug, strings.Join(render.Slugs, ", "))
+	}
+
+	return nil
+}
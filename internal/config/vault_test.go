@@ -0,0 +1,63 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeVaultConfig(t *testing.T, content string) string {
+	t.Helper()
+	root := t.TempDir()
+	path := filepath.Join(root, filepath.FromSlash(VaultConfigFile))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func TestLoadVaultMissing(t *testing.T) {
+	vc, err := LoadVault(t.TempDir())
+	if err != nil {
+		t.Fatalf("LoadVault failed: %v", err)
+	}
+	if vc.AssetsDir != "" || vc.Extensions != nil || vc.Export.Math != nil {
+		t.Errorf("Expected empty config, got %+v", vc)
+	}
+}
+
+func TestLoadVault(t *testing.T) {
+	root := writeVaultConfig(t, `{
+		"extensions": [".md", ".mdx"],
+		"assetsDir": "static/img",
+		"templatesDir": "templates",
+		"export": {"math": false}
+	}`)
+
+	vc, err := LoadVault(root)
+	if err != nil {
+		t.Fatalf("LoadVault failed: %v", err)
+	}
+	if len(vc.Extensions) != 2 || vc.AssetsDir != "static/img" || vc.TemplatesDir != "templates" {
+		t.Errorf("Unexpected config: %+v", vc)
+	}
+	if vc.Export.Math == nil || *vc.Export.Math {
+		t.Error("Expected export.math to be false")
+	}
+}
+
+func TestLoadVaultInvalid(t *testing.T) {
+	for _, content := range []string{
+		`{"extensions": ["md"]}`,
+		`{"assetsDir": "../outside"}`,
+		`{"templatesDir": "/etc"}`,
+		`{"extensions": `,
+	} {
+		if _, err := LoadVault(writeVaultConfig(t, content)); err == nil {
+			t.Errorf("LoadVault(%s) should fail", content)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+// Package desktop hands files over to the operating system's own
+// applications
+package desktop
+
+import (
+	"os/exec"
+	"path/filepath"
+	"runtime"
+)
+
+// Open opens a file with its default application
+func Open(path string) error {
+	name, args := openCommand(runtime.GOOS, path)
+	return start(name, args...)
+}
+
+// Reveal shows a file in the system file manager, selecting it where the
+// platform supports that
+func Reveal(path string) error {
+	name, args := revealCommand(runtime.GOOS, path)
+	return start(name, args...)
+}
+
+// openCommand returns the command that opens path on goos
+func openCommand(goos, path string) (string, []string) {
+	switch goos {
+	case "darwin":
+		return "open", []string{path}
+	case "windows":
+		return "rundll32", []string{"url.dll,FileProtocolHandler", path}
+	default:
+		return "xdg-open", []string{path}
+	}
+}
+
+// revealCommand returns the command that shows path in the file manager
+// on goos. Linux file managers have no common way to select a file, so the
+// containing folder is opened instead.
+func revealCommand(goos, path string) (string, []string) {
+	switch goos {
+	case "darwin":
+		return "open", []string{"-R", path}
+	case "windows":
+		return "explorer", []string{"/select," + path}
+	default:
+		return "xdg-open", []string{filepath.Dir(path)}
+	}
+}
+
+// start launches a command without waiting for the application to exit
+func start(name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	// Reap the process once the application closes
+	go cmd.Wait()
+	return nil
+}
@@ -0,0 +1,47 @@
+package desktop
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestOpenCommand(t *testing.T) {
+	tests := []struct {
+		goos string
+		name string
+		args []string
+	}{
+		{"darwin", "open", []string{"/notes/a.md"}},
+		{"windows", "rundll32", []string{"url.dll,FileProtocolHandler", "/notes/a.md"}},
+		{"linux", "xdg-open", []string{"/notes/a.md"}},
+	}
+
+	for _, tt := range tests {
+		name, args := openCommand(tt.goos, "/notes/a.md")
+		if name != tt.name || !reflect.DeepEqual(args, tt.args) {
+			t.Errorf("openCommand(%s) = %s %v, want %s %v", tt.goos, name, args, tt.name, tt.args)
+		}
+	}
+}
+
+func TestRevealCommand(t *testing.T) {
+	path := filepath.Join("notes", "a.md")
+
+	tests := []struct {
+		goos string
+		name string
+		args []string
+	}{
+		{"darwin", "open", []string{"-R", path}},
+		{"windows", "explorer", []string{"/select," + path}},
+		{"linux", "xdg-open", []string{"notes"}},
+	}
+
+	for _, tt := range tests {
+		name, args := revealCommand(tt.goos, path)
+		if name != tt.name || !reflect.DeepEqual(args, tt.args) {
+			t.Errorf("revealCommand(%s) = %s %v, want %s %v", tt.goos, name, args, tt.name, tt.args)
+		}
+	}
+}
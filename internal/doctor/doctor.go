@@ -0,0 +1,328 @@
+// Package doctor diagnoses common problems with an Inkwell installation
+package doctor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"inkwell/internal/config"
+	inkgit "inkwell/internal/git"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// Status is the outcome of a check
+type Status string
+
+const (
+	StatusOK   Status = "ok"
+	StatusWarn Status = "warn"
+	StatusFail Status = "fail"
+)
+
+// Check is the result of one diagnostic, with a suggested fix when it did
+// not pass
+type Check struct {
+	Name    string `json:"name"`
+	Status  Status `json:"status"`
+	Message string `json:"message"`
+	Fix     string `json:"fix,omitempty"`
+}
+
+// Options describe the installation being diagnosed
+type Options struct {
+	RootDir    string // Vault being served or about to be
+	ReposDir   string // Where cloned repositories live
+	ConfigFile string // Configuration file to validate, if any
+	Host       string
+	Port       int  // Port to check; 0 skips the check
+	Serving    bool // The port is expected to be held by this Inkwell
+	NoGit      bool // Git integration is disabled; skips git, SSH and repo checks
+}
+
+// inotifyWatchesFile holds the per-user limit of inotify watches on Linux
+const inotifyWatchesFile = "/proc/sys/fs/inotify/max_user_watches"
+
+// Run performs every check
+func Run(ctx context.Context, opts Options) []Check {
+	var checks []Check
+	if !opts.NoGit {
+		checks = append(checks, checkGit(ctx), checkSSH())
+	}
+	if runtime.GOOS == "linux" && opts.RootDir != "" {
+		checks = append(checks, checkWatchLimit(opts.RootDir, inotifyWatchesFile))
+	}
+	if opts.Port != 0 {
+		checks = append(checks, checkPort(opts.Host, opts.Port, opts.Serving))
+	}
+	if opts.ReposDir != "" && !opts.NoGit {
+		checks = append(checks, checkRepos(opts.ReposDir)...)
+	}
+	checks = append(checks, checkConfig(opts.ConfigFile))
+	if opts.RootDir != "" {
+		checks = append(checks, checkVault(opts.RootDir))
+	}
+	return checks
+}
+
+// Failed reports whether any check failed
+func Failed(checks []Check) bool {
+	for _, c := range checks {
+		if c.Status == StatusFail {
+			return true
+		}
+	}
+	return false
+}
+
+// checkGit looks for the git command. Inkwell works without it, but
+// credential helpers and hooks in repositories expect it.
+func checkGit(ctx context.Context) Check {
+	c := Check{Name: "git"}
+
+	path, err := exec.LookPath("git")
+	if err != nil {
+		c.Status = StatusWarn
+		c.Message = "git is not installed; repository hooks and credential helpers will not run"
+		c.Fix = "Install git from https://git-scm.com/downloads"
+		return c
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, path, "--version").Output()
+	if err != nil {
+		c.Status = StatusWarn
+		c.Message = fmt.Sprintf("%s does not run: %v", path, err)
+		c.Fix = "Reinstall git"
+		return c
+	}
+
+	c.Status = StatusOK
+	c.Message = strings.TrimSpace(string(out))
+	return c
+}
+
+// checkSSH looks for an SSH agent or a default key for pushing over SSH
+func checkSSH() Check {
+	c := Check{Name: "ssh"}
+
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		if _, err := os.Stat(sock); err == nil {
+			c.Status = StatusOK
+			c.Message = "SSH agent is running"
+			return c
+		}
+	}
+
+	if key := inkgit.GetDefaultSSHKeyPath(); key != "" {
+		if err := inkgit.ValidateSSHKey(key); err != nil {
+			c.Status = StatusWarn
+			c.Message = err.Error()
+			c.Fix = "Add the key to an SSH agent with ssh-add " + key
+			return c
+		}
+		c.Status = StatusOK
+		c.Message = "Using SSH key " + key
+		return c
+	}
+
+	c.Status = StatusWarn
+	c.Message = "No SSH agent or key found; SSH remotes will not authenticate"
+	c.Fix = "Create a key with ssh-keygen -t ed25519 and add it to your Git host"
+	return c
+}
+
+// checkWatchLimit compares the vault's directory count with the inotify
+// watch limit, since the watcher needs one watch per directory
+func checkWatchLimit(rootDir, limitFile string) Check {
+	c := Check{Name: "file watches"}
+
+	data, err := os.ReadFile(limitFile)
+	if err != nil {
+		c.Status = StatusWarn
+		c.Message = "Could not read the inotify watch limit: " + err.Error()
+		return c
+	}
+	limit, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		c.Status = StatusWarn
+		c.Message = "Unexpected inotify watch limit: " + strings.TrimSpace(string(data))
+		return c
+	}
+
+	dirs := countDirs(rootDir)
+	c.Message = fmt.Sprintf("%d directories to watch, limit %d", dirs, limit)
+	switch {
+	case dirs > limit:
+		c.Status = StatusFail
+	case dirs > limit/2:
+		c.Status = StatusWarn
+	default:
+		c.Status = StatusOK
+		return c
+	}
+	c.Fix = "Raise the limit with: sudo sysctl fs.inotify.max_user_watches=524288"
+	return c
+}
+
+// countDirs counts the directories the watcher would watch under root
+func countDirs(root string) int {
+	count := 0
+	filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		if path != root && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		count++
+		return nil
+	})
+	return count
+}
+
+// checkPort checks that the port can be listened on or, for a running
+// server, that it accepts connections
+func checkPort(host string, port int, serving bool) Check {
+	c := Check{Name: "port"}
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+
+	if serving {
+		dialHost := host
+		if dialHost == "" || dialHost == "0.0.0.0" || dialHost == "::" {
+			dialHost = "localhost"
+		}
+		conn, err := net.DialTimeout("tcp", net.JoinHostPort(dialHost, strconv.Itoa(port)), 2*time.Second)
+		if err != nil {
+			c.Status = StatusFail
+			c.Message = fmt.Sprintf("Port %d is not reachable: %v", port, err)
+			c.Fix = "Check firewall rules for the port, or listen on another interface with --host"
+			return c
+		}
+		conn.Close()
+		c.Status = StatusOK
+		c.Message = fmt.Sprintf("Listening on %s", addr)
+		return c
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		c.Status = StatusFail
+		c.Message = fmt.Sprintf("Port %d is not available: %v", port, err)
+		c.Fix = "Choose another --port, or use --port-range to pick a free one"
+		return c
+	}
+	listener.Close()
+	c.Status = StatusOK
+	c.Message = fmt.Sprintf("Port %d is free", port)
+	return c
+}
+
+// checkRepos opens every cloned repository and reads its latest commit
+func checkRepos(reposDir string) []Check {
+	entries, err := os.ReadDir(reposDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return []Check{{
+			Name:    "repositories",
+			Status:  StatusWarn,
+			Message: "Could not read " + reposDir + ": " + err.Error(),
+		}}
+	}
+
+	var checks []Check
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		checks = append(checks, checkRepo(filepath.Join(reposDir, entry.Name())))
+	}
+	return checks
+}
+
+// checkRepo checks that a cloned repository can be read
+func checkRepo(path string) Check {
+	c := Check{Name: "repository " + filepath.Base(path)}
+	fix := "Remove " + path + " and clone it again"
+
+	repo, err := git.PlainOpen(path)
+	if err != nil {
+		c.Status = StatusFail
+		c.Message = "Cannot open repository: " + err.Error()
+		c.Fix = fix
+		return c
+	}
+
+	head, err := repo.Head()
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		c.Status = StatusOK
+		c.Message = "No commits yet"
+		return c
+	}
+	if err != nil {
+		c.Status = StatusFail
+		c.Message = "Cannot read HEAD: " + err.Error()
+		c.Fix = fix
+		return c
+	}
+	if _, err := repo.CommitObject(head.Hash()); err != nil {
+		c.Status = StatusFail
+		c.Message = "Latest commit is unreadable: " + err.Error()
+		c.Fix = fix
+		return c
+	}
+
+	c.Status = StatusOK
+	c.Message = "On " + head.Name().Short()
+	return c
+}
+
+// checkConfig validates the configuration file
+func checkConfig(path string) Check {
+	c := Check{Name: "config file"}
+
+	if path == "" {
+		c.Status = StatusOK
+		c.Message = "No configuration file; using defaults"
+		return c
+	}
+	if err := config.ValidateFile(path); err != nil {
+		c.Status = StatusFail
+		c.Message = err.Error()
+		c.Fix = "Correct " + path + " (run inkwell --help for the option names)"
+		return c
+	}
+
+	c.Status = StatusOK
+	c.Message = path + " is valid"
+	return c
+}
+
+// checkVault validates the vault's own configuration
+func checkVault(rootDir string) Check {
+	c := Check{Name: "vault config"}
+
+	if _, err := config.LoadVault(rootDir); err != nil {
+		c.Status = StatusFail
+		c.Message = err.Error()
+		c.Fix = "Correct " + filepath.Join(rootDir, filepath.FromSlash(config.VaultConfigFile))
+		return c
+	}
+
+	c.Status = StatusOK
+	c.Message = "Valid"
+	return c
+}
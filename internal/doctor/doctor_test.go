@@ -0,0 +1,96 @@
+package doctor
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+)
+
+func TestCheckWatchLimit(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"a", "b", "c/d", ".git/objects"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	limitFile := filepath.Join(t.TempDir(), "max_user_watches")
+	tests := map[string]Status{"100": StatusOK, "8": StatusWarn, "3": StatusFail}
+	for limit, want := range tests {
+		os.WriteFile(limitFile, []byte(limit+"\n"), 0644)
+		if c := checkWatchLimit(root, limitFile); c.Status != want {
+			t.Errorf("Limit %s: status %s (%s), want %s", limit, c.Status, c.Message, want)
+		}
+	}
+}
+
+func TestCheckPort(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	if c := checkPort("127.0.0.1", port, false); c.Status != StatusFail || c.Fix == "" {
+		t.Errorf("Busy port: %+v", c)
+	}
+	if c := checkPort("127.0.0.1", port, true); c.Status != StatusOK {
+		t.Errorf("Serving port: %+v", c)
+	}
+}
+
+func TestCheckRepos(t *testing.T) {
+	reposDir := t.TempDir()
+	if _, err := git.PlainInit(filepath.Join(reposDir, "empty"), false); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(reposDir, "broken"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	checks := checkRepos(reposDir)
+	if len(checks) != 2 {
+		t.Fatalf("Expected 2 checks, got %+v", checks)
+	}
+	for _, c := range checks {
+		switch c.Name {
+		case "repository broken":
+			if c.Status != StatusFail || c.Fix == "" {
+				t.Errorf("Broken repo: %+v", c)
+			}
+		case "repository empty":
+			if c.Status != StatusOK {
+				t.Errorf("Empty repo: %+v", c)
+			}
+		default:
+			t.Errorf("Unexpected check %q", c.Name)
+		}
+	}
+}
+
+func TestCheckConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.toml")
+	os.WriteFile(path, []byte("colour = \"red\"\n"), 0644)
+
+	if c := checkConfig(path); c.Status != StatusFail {
+		t.Errorf("Invalid config: %+v", c)
+	}
+	if c := checkConfig(""); c.Status != StatusOK {
+		t.Errorf("No config: %+v", c)
+	}
+
+	os.MkdirAll(filepath.Join(dir, ".inkwell"), 0755)
+	os.WriteFile(filepath.Join(dir, ".inkwell", "config.json"), []byte("{not json"), 0644)
+	if c := checkVault(dir); c.Status != StatusFail {
+		t.Errorf("Invalid vault config: %+v", c)
+	}
+	if !Failed(Run(context.Background(), Options{RootDir: dir})) {
+		t.Error("Run should report the failing vault config")
+	}
+}
@@ -0,0 +1,278 @@
+// Package encryption keeps notes encrypted on disk and in git. Content is
+// sealed with AES-256-GCM under a key derived from the vault passphrase with
+// scrypt. Notes encrypted one by one are stored as armored text; a vault
+// encrypted at rest stores every file in a binary sealed form.
+package encryption
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// KeyFile holds the salt and scrypt parameters for the vault key, relative
+// to the vault root. It is committed alongside the notes; without the
+// passphrase it reveals nothing.
+const KeyFile = ".inkwell/encryption.json"
+
+const (
+	header     = "-----BEGIN INKWELL ENCRYPTED NOTE-----"
+	footer     = "-----END INKWELL ENCRYPTED NOTE-----"
+	lineWidth  = 64
+	version    = 1
+	saltLen    = 16
+	keyLen     = 32
+	scryptLogN = 15
+	scryptR    = 8
+	scryptP    = 1
+	checkText  = "inkwell"
+
+	// sealedMagic starts every file sealed at rest. The NUL keeps git and
+	// editors from taking it for text.
+	sealedMagic = "INKWELL-SEALED\x00\x01"
+)
+
+// MinPassphraseLen is the shortest passphrase Setup accepts
+const MinPassphraseLen = 8
+
+// ErrNotConfigured is returned when unlocking a vault without a key file
+var ErrNotConfigured = errors.New("encryption is not set up for this vault")
+
+// ErrConfigured is returned when setting up a vault that already has a key
+var ErrConfigured = errors.New("encryption is already set up for this vault")
+
+// ErrWrongPassphrase is returned when a passphrase doesn't unlock the vault
+var ErrWrongPassphrase = errors.New("wrong passphrase")
+
+// ErrCorrupt is returned for encrypted content that can't be decrypted
+var ErrCorrupt = errors.New("encrypted content is damaged or was sealed with another key")
+
+// keyFile is the stored form of KeyFile
+type keyFile struct {
+	Version int    `json:"version"`
+	Salt    []byte `json:"salt"`
+	LogN    int    `json:"logN"`
+	R       int    `json:"r"`
+	P       int    `json:"p"`
+	Check   []byte `json:"check"` // checkText sealed with the key
+}
+
+// Key encrypts and decrypts notes and files. It is safe for concurrent use.
+type Key struct {
+	aead cipher.AEAD
+}
+
+// Configured reports whether the vault under rootDir has a key file
+func Configured(rootDir string) bool {
+	_, err := os.Stat(keyPath(rootDir))
+	return err == nil
+}
+
+// Setup creates the key file for a vault and returns its key
+func Setup(rootDir, passphrase string) (*Key, error) {
+	if len(passphrase) < MinPassphraseLen {
+		return nil, fmt.Errorf("passphrase must be at least %d characters", MinPassphraseLen)
+	}
+	if Configured(rootDir) {
+		return nil, ErrConfigured
+	}
+
+	kf := &keyFile{
+		Version: version,
+		Salt:    make([]byte, saltLen),
+		LogN:    scryptLogN,
+		R:       scryptR,
+		P:       scryptP,
+	}
+	if _, err := rand.Read(kf.Salt); err != nil {
+		return nil, err
+	}
+
+	key, err := deriveKey(kf, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if kf.Check, err = key.seal([]byte(checkText)); err != nil {
+		return nil, err
+	}
+
+	data, err := json.MarshalIndent(kf, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	path := keyPath(rootDir)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// Unlock derives the vault key from a passphrase, checking it against the
+// key file
+func Unlock(rootDir, passphrase string) (*Key, error) {
+	data, err := os.ReadFile(keyPath(rootDir))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotConfigured
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	kf := &keyFile{}
+	if err := json.Unmarshal(data, kf); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", KeyFile, err)
+	}
+	if kf.Version != version {
+		return nil, fmt.Errorf("unsupported %s version %d", KeyFile, kf.Version)
+	}
+
+	key, err := deriveKey(kf, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	check, err := key.open(kf.Check)
+	if err != nil || subtle.ConstantTimeCompare(check, []byte(checkText)) != 1 {
+		return nil, ErrWrongPassphrase
+	}
+	return key, nil
+}
+
+// IsEncrypted reports whether content is an encrypted note
+func IsEncrypted(content string) bool {
+	return strings.HasPrefix(strings.TrimLeft(content, "\r\n\t "), header)
+}
+
+// Encrypt seals a note, returning its armored form
+func (k *Key) Encrypt(plaintext string) (string, error) {
+	sealed, err := k.seal([]byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.StdEncoding.EncodeToString(sealed)
+
+	var b strings.Builder
+	b.WriteString(header + "\n")
+	for len(encoded) > lineWidth {
+		b.WriteString(encoded[:lineWidth] + "\n")
+		encoded = encoded[lineWidth:]
+	}
+	b.WriteString(encoded + "\n")
+	b.WriteString(footer + "\n")
+	return b.String(), nil
+}
+
+// Decrypt opens an armored note
+func (k *Key) Decrypt(content string) (string, error) {
+	body := strings.TrimSpace(content)
+	if !strings.HasPrefix(body, header) || !strings.HasSuffix(body, footer) {
+		return "", ErrCorrupt
+	}
+	body = strings.TrimSuffix(strings.TrimPrefix(body, header), footer)
+	body = strings.Join(strings.Fields(body), "")
+
+	sealed, err := base64.StdEncoding.DecodeString(body)
+	if err != nil {
+		return "", ErrCorrupt
+	}
+	plaintext, err := k.open(sealed)
+	if err != nil {
+		return "", ErrCorrupt
+	}
+	return string(plaintext), nil
+}
+
+// IsSealed reports whether data is a file sealed at rest
+func IsSealed(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(sealedMagic))
+}
+
+// Seal encrypts a file for storing at rest
+func (k *Key) Seal(plaintext []byte) ([]byte, error) {
+	sealed, err := k.seal(plaintext)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(sealedMagic), sealed...), nil
+}
+
+// Open decrypts a file sealed at rest. Data that isn't sealed, such as a
+// file written before the vault was encrypted, is returned as it is.
+func (k *Key) Open(data []byte) ([]byte, error) {
+	if !IsSealed(data) {
+		return data, nil
+	}
+	plaintext, err := k.open(data[len(sealedMagic):])
+	if err != nil {
+		return nil, ErrCorrupt
+	}
+	return plaintext, nil
+}
+
+// seal encrypts with a fresh nonce, which is kept in front of the result
+func (k *Key) seal(plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, k.aead.NonceSize(), k.aead.NonceSize()+len(plaintext)+k.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return k.aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func (k *Key) open(sealed []byte) ([]byte, error) {
+	n := k.aead.NonceSize()
+	if len(sealed) < n+k.aead.Overhead() {
+		return nil, ErrCorrupt
+	}
+	return k.aead.Open(nil, sealed[:n], sealed[n:], nil)
+}
+
+// DeriveKey derives a key from a passphrase and a random salt, with the
+// same scrypt parameters as vault keys
+func DeriveKey(passphrase string, salt []byte) (*Key, error) {
+	return deriveKey(&keyFile{Salt: salt, LogN: scryptLogN, R: scryptR, P: scryptP}, passphrase)
+}
+
+func deriveKey(kf *keyFile, passphrase string) (*Key, error) {
+	if len(kf.Salt) == 0 || kf.LogN < 1 || kf.LogN > 30 {
+		return nil, fmt.Errorf("invalid %s", KeyFile)
+	}
+	raw, err := scrypt.Key([]byte(passphrase), kf.Salt, 1<<kf.LogN, kf.R, kf.P, keyLen)
+	if err != nil {
+		return nil, err
+	}
+	return NewKey(raw)
+}
+
+// NewKey makes a key from 32 random bytes, for secrets that are kept under
+// a stored key rather than a passphrase
+func NewKey(raw []byte) (*Key, error) {
+	if len(raw) != keyLen {
+		return nil, fmt.Errorf("key must be %d bytes", keyLen)
+	}
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Key{aead: aead}, nil
+}
+
+func keyPath(rootDir string) string {
+	return filepath.Join(rootDir, filepath.FromSlash(KeyFile))
+}
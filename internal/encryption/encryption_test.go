@@ -0,0 +1,124 @@
+package encryption
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSetupAndUnlock(t *testing.T) {
+	dir := t.TempDir()
+
+	if Configured(dir) {
+		t.Fatal("Configured() = true before setup")
+	}
+	if _, err := Unlock(dir, "correct horse"); !errors.Is(err, ErrNotConfigured) {
+		t.Fatalf("Unlock() before setup error = %v, want ErrNotConfigured", err)
+	}
+	if _, err := Setup(dir, "short"); err == nil {
+		t.Fatal("Setup() with a short passphrase succeeded")
+	}
+
+	key, err := Setup(dir, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Configured(dir) {
+		t.Fatal("Configured() = false after setup")
+	}
+	if _, err := Setup(dir, "another passphrase"); !errors.Is(err, ErrConfigured) {
+		t.Fatalf("second Setup() error = %v, want ErrConfigured", err)
+	}
+	if _, err := Unlock(dir, "wrong horse"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatalf("Unlock() with the wrong passphrase error = %v, want ErrWrongPassphrase", err)
+	}
+
+	note := "# Blood tests\n\n" + strings.Repeat("All normal. ", 50)
+	sealed, err := key.Encrypt(note)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsEncrypted(sealed) || IsEncrypted(note) {
+		t.Errorf("IsEncrypted() got the wrong answer for %q", sealed)
+	}
+	if strings.Contains(sealed, "normal") {
+		t.Error("encrypted note contains plaintext")
+	}
+	for _, line := range strings.Split(strings.TrimSpace(sealed), "\n") {
+		if len(line) > lineWidth && line != header && line != footer {
+			t.Errorf("armored line is %d characters, want at most %d", len(line), lineWidth)
+		}
+	}
+	if again, _ := key.Encrypt(note); again == sealed {
+		t.Error("encrypting twice gave the same ciphertext")
+	}
+
+	// A key unlocked later opens notes sealed by the first
+	unlocked, err := Unlock(dir, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := unlocked.Decrypt(sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != note {
+		t.Errorf("Decrypt() = %q, want %q", got, note)
+	}
+
+	tampered := []byte(sealed)
+	i := len(header) + 5 // Inside the first line of ciphertext
+	if tampered[i] == 'A' {
+		tampered[i] = 'B'
+	} else {
+		tampered[i] = 'A'
+	}
+	if _, err := unlocked.Decrypt(string(tampered)); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("Decrypt() of a tampered note error = %v, want ErrCorrupt", err)
+	}
+
+	other, err := Setup(t.TempDir(), "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.Decrypt(sealed); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("Decrypt() with another vault's key error = %v, want ErrCorrupt", err)
+	}
+}
+
+func TestSealAndOpen(t *testing.T) {
+	key, err := Setup(t.TempDir(), "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	image := []byte("\x89PNG\r\n\x1a\n pixels")
+	sealed, err := key.Seal(image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsSealed(sealed) || IsSealed(image) {
+		t.Error("IsSealed() got the wrong answer")
+	}
+	if strings.Contains(string(sealed), "pixels") {
+		t.Error("sealed file contains plaintext")
+	}
+
+	got, err := key.Open(sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(image) {
+		t.Errorf("Open() = %q, want %q", got, image)
+	}
+
+	// Files written before the vault was encrypted read as they are
+	if got, err := key.Open([]byte("# Plain")); err != nil || string(got) != "# Plain" {
+		t.Errorf("Open() of a plain file = %q, %v", got, err)
+	}
+
+	sealed[len(sealed)-1] ^= 1
+	if _, err := key.Open(sealed); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("Open() of a damaged file error = %v, want ErrCorrupt", err)
+	}
+}
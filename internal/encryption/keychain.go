@@ -0,0 +1,44 @@
+package encryption
+
+import (
+	"errors"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// keychainService names the entries Inkwell looks up in the OS keychain
+const keychainService = "inkwell"
+
+// ErrNoKeychain is returned when the OS has no keychain Inkwell can read
+var ErrNoKeychain = errors.New("no supported keychain")
+
+// KeychainPassphrase looks up the passphrase for the vault at rootDir in
+// the OS keychain: the login keychain on macOS, or the Secret Service
+// through secret-tool on Linux. Entries belong to the "inkwell" service,
+// with the vault's absolute path as the account.
+func KeychainPassphrase(rootDir string) (string, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "find-generic-password", "-s", keychainService, "-a", rootDir, "-w")
+	case "linux", "freebsd", "openbsd":
+		cmd = exec.Command("secret-tool", "lookup", "service", keychainService, "vault", rootDir)
+	default:
+		return "", ErrNoKeychain
+	}
+	if cmd.Err != nil {
+		// The keychain tool isn't installed
+		return "", ErrNoKeychain
+	}
+
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	passphrase := strings.TrimRight(string(out), "\r\n")
+	if passphrase == "" {
+		return "", errors.New("empty keychain entry")
+	}
+	return passphrase, nil
+}
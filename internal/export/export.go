@@ -0,0 +1,414 @@
+// Package export writes notes to disk as HTML, PDF or a static site
+// without running the server
+package export
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"inkwell/internal/cite"
+	"inkwell/internal/filesystem"
+	"inkwell/internal/i18n"
+	"inkwell/internal/render"
+)
+
+// Format is an export output format
+type Format string
+
+const (
+	FormatHTML Format = "html" // One standalone page per note
+	FormatPDF  Format = "pdf"  // One print layout PDF per note
+	FormatSite Format = "site" // Pages plus assets and an index, ready to publish
+)
+
+// ParseFormat validates a --format value
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case FormatHTML, FormatPDF, FormatSite:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unknown export format %q (use html, pdf or site)", s)
+	}
+}
+
+// Exporter renders notes from a vault
+type Exporter struct {
+	FS       *filesystem.FileSystem
+	Renderer *render.Renderer
+	Chrome   string // Browser used to print PDFs; searched for when empty
+
+	// Bibliography, if set, resolves citations such as [@smith2020] and
+	// adds a reference list to notes that cite it
+	Bibliography *cite.Bibliography
+
+	// Progress, if set, is called after each note is written
+	Progress func(done, total int)
+
+	// Locale formats dates on generated pages; English when nil
+	Locale *i18n.Locale
+
+	// Navigation adds a table of contents and heading anchors to pages
+	Navigation render.Navigation
+}
+
+// Export writes the note or folder at relativePath to outDir and returns
+// the files written, relative to outDir
+func (e *Exporter) Export(ctx context.Context, format Format, relativePath, outDir string) ([]string, error) {
+	full, err := e.FS.ResolvePath(relativePath)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(full)
+	if err != nil {
+		return nil, err
+	}
+
+	notes := []string{relativePath}
+	if info.IsDir() {
+		if notes, err = e.FS.ListNotes(relativePath); err != nil {
+			return nil, err
+		}
+	} else if !e.FS.IsNote(info.Name()) {
+		return nil, fmt.Errorf("%s is not a note", relativePath)
+	}
+	sort.Strings(notes)
+
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return nil, err
+	}
+
+	base := relativePath
+	if !info.IsDir() {
+		base = filepath.Dir(relativePath)
+	}
+
+	switch format {
+	case FormatPDF:
+		return e.exportPDF(ctx, notes, base, outDir)
+	case FormatSite:
+		written, err := e.exportHTML(notes, base, outDir)
+		if err != nil {
+			return nil, err
+		}
+		if info.IsDir() {
+			assets, err := e.copyAssets(relativePath, outDir)
+			if err != nil {
+				return nil, err
+			}
+			written = append(written, assets...)
+		}
+		if index, err := e.writeIndex(notes, base, outDir, e.title(relativePath)); err != nil {
+			return nil, err
+		} else if index != "" {
+			written = append(written, index)
+		}
+		return written, nil
+	default:
+		return e.exportHTML(notes, base, outDir)
+	}
+}
+
+// exportHTML writes a standalone page for each note, with links between
+// notes pointing at their pages
+func (e *Exporter) exportHTML(notes []string, base, outDir string) ([]string, error) {
+	var written []string
+	for _, note := range notes {
+		content, err := e.FS.ReadFile(note)
+		if err != nil {
+			return nil, err
+		}
+		source, refs := e.Bibliography.Resolve(content)
+		body, err := e.Renderer.WithNavigation(e.Navigation).RenderLinks([]byte(source), e.pageLink)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", note, err)
+		}
+		body += refs
+
+		name := pageName(note, base, ".html")
+		if err := writeFile(filepath.Join(outDir, name), []byte(e.Renderer.Document(e.title(note), body))); err != nil {
+			return nil, err
+		}
+		written = append(written, filepath.ToSlash(name))
+		if e.Progress != nil {
+			e.Progress(len(written), len(notes))
+		}
+	}
+	return written, nil
+}
+
+// exportPDF prints each note's print layout through a headless browser
+func (e *Exporter) exportPDF(ctx context.Context, notes []string, base, outDir string) ([]string, error) {
+	chrome := e.Chrome
+	if chrome == "" {
+		var err error
+		if chrome, err = findChrome(); err != nil {
+			return nil, err
+		}
+	}
+
+	tmpDir, err := os.MkdirTemp("", "inkwell-export-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	var written []string
+	for i, note := range notes {
+		content, err := e.FS.ReadFile(note)
+		if err != nil {
+			return nil, err
+		}
+		source, refs := e.Bibliography.Resolve(content)
+		body, err := e.Renderer.WithNavigation(e.Navigation).RenderPrint([]byte(source))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", note, err)
+		}
+		body += refs
+
+		// Relative images load from the vault on disk
+		full, _ := e.FS.ResolvePath(note)
+		page := e.Renderer.PrintDocument(e.title(note), body, fileURL(filepath.Dir(full))+"/")
+		htmlPath := filepath.Join(tmpDir, fmt.Sprintf("%d.html", i))
+		if err := os.WriteFile(htmlPath, []byte(page), 0644); err != nil {
+			return nil, err
+		}
+
+		name := pageName(note, base, ".pdf")
+		pdfPath := filepath.Join(outDir, name)
+		if err := os.MkdirAll(filepath.Dir(pdfPath), 0755); err != nil {
+			return nil, err
+		}
+		if err := printPDF(ctx, chrome, htmlPath, pdfPath); err != nil {
+			return nil, fmt.Errorf("%s: %w", note, err)
+		}
+		written = append(written, filepath.ToSlash(name))
+	}
+	return written, nil
+}
+
+// copyAssets copies every file that is not a note, such as images, so
+// pages can reference them
+func (e *Exporter) copyAssets(relativeDir, outDir string) ([]string, error) {
+	root, err := e.FS.ResolvePath(relativeDir)
+	if err != nil {
+		return nil, err
+	}
+	absOut, err := filepath.Abs(outDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var written []string
+	err = filepath.WalkDir(root, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == absOut {
+			return filepath.SkipDir
+		}
+		if p != root && filesystem.IsHidden(p) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || e.FS.IsNote(d.Name()) || !d.Type().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		if e.FS.Cipher != nil {
+			// Assets in an encrypted vault are stored sealed
+			data, err := e.FS.ReadBytes(filepath.Join(relativeDir, rel))
+			if err != nil {
+				return err
+			}
+			if err := writeFile(filepath.Join(outDir, rel), data); err != nil {
+				return err
+			}
+		} else if err := copyFile(p, filepath.Join(outDir, rel)); err != nil {
+			return err
+		}
+		written = append(written, filepath.ToSlash(rel))
+		return nil
+	})
+	return written, err
+}
+
+// pageLink points relative links to notes at their exported pages
+func (e *Exporter) pageLink(dest string) string {
+	u, err := url.Parse(dest)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.Path == "" || path.IsAbs(u.Path) {
+		return dest
+	}
+	if !e.FS.IsNote(path.Base(u.Path)) {
+		return dest
+	}
+	u.Path = strings.TrimSuffix(u.Path, path.Ext(u.Path)) + ".html"
+	return u.String()
+}
+
+// title names a page after its file
+func (e *Exporter) title(relativePath string) string {
+	name := filepath.Base(relativePath)
+	if relativePath == "" || relativePath == "." {
+		name = filepath.Base(e.FS.RootDir)
+	}
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
+
+// pageName maps a note to its output file relative to the export base
+func pageName(note, base, ext string) string {
+	rel, err := filepath.Rel(base, note)
+	if err != nil {
+		rel = filepath.Base(note)
+	}
+	return strings.TrimSuffix(rel, filepath.Ext(rel)) + ext
+}
+
+// writeIndex adds an index.html listing every page with when its note was
+// last changed, unless a note already became one
+func (e *Exporter) writeIndex(notes []string, base, outDir, title string) (string, error) {
+	for _, note := range notes {
+		if pageName(note, base, ".html") == "index.html" {
+			return "", nil
+		}
+	}
+	locale := e.Locale
+	if locale == nil {
+		locale = i18n.Default()
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "<!DOCTYPE html>\n<html lang=\"%s\">\n<head>\n<meta charset=\"utf-8\">\n", locale.Tag())
+	fmt.Fprintf(&buf, "<title>%s</title>\n</head>\n<body>\n<h1>%s</h1>\n", html.EscapeString(title), html.EscapeString(title))
+	fmt.Fprintf(&buf, "<p>%s</p>\n<ul>\n", html.EscapeString(locale.Sprintf(i18n.MsgNotes, len(notes))))
+	for _, note := range notes {
+		page := (&url.URL{Path: filepath.ToSlash(pageName(note, base, ".html"))}).String()
+		label := filepath.ToSlash(pageName(note, base, ""))
+		fmt.Fprintf(&buf, "<li><a href=\"%s\">%s</a>", html.EscapeString(page), html.EscapeString(label))
+		if full, err := e.FS.ResolvePath(note); err == nil {
+			if info, err := os.Stat(full); err == nil {
+				updated := locale.Sprintf(i18n.MsgUpdated, locale.Date(info.ModTime()))
+				fmt.Fprintf(&buf, " <time datetime=\"%s\">%s</time>", info.ModTime().UTC().Format(time.RFC3339), html.EscapeString(updated))
+			}
+		}
+		buf.WriteString("</li>\n")
+	}
+	buf.WriteString("</ul>\n</body>\n</html>\n")
+
+	if err := writeFile(filepath.Join(outDir, "index.html"), buf.Bytes()); err != nil {
+		return "", err
+	}
+	return "index.html", nil
+}
+
+// chromeNames are the browser commands that can print to PDF
+var chromeNames = []string{"chromium", "chromium-browser", "google-chrome", "google-chrome-stable", "chrome", "msedge"}
+
+// chromeApps are browser locations outside PATH, by OS
+var chromeApps = map[string][]string{
+	"darwin": {
+		"/Applications/Google Chrome.app/Contents/MacOS/Google Chrome",
+		"/Applications/Chromium.app/Contents/MacOS/Chromium",
+		"/Applications/Microsoft Edge.app/Contents/MacOS/Microsoft Edge",
+	},
+	"windows": {
+		`C:\Program Files\Google\Chrome\Application\chrome.exe`,
+		`C:\Program Files (x86)\Microsoft\Edge\Application\msedge.exe`,
+	},
+}
+
+// findChrome locates a Chrome-based browser for printing PDFs
+func findChrome() (string, error) {
+	for _, name := range chromeNames {
+		if p, err := exec.LookPath(name); err == nil {
+			return p, nil
+		}
+	}
+	for _, p := range chromeApps[runtime.GOOS] {
+		if _, err := os.Stat(p); err == nil {
+			return p, nil
+		}
+	}
+	return "", errors.New("PDF export needs Chrome, Chromium or Edge; install one or pass --chrome")
+}
+
+// printPDF prints a page to PDF with a headless browser
+func printPDF(ctx context.Context, chrome, htmlPath, pdfPath string) error {
+	absPDF, err := filepath.Abs(pdfPath)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.CommandContext(ctx, chrome,
+		"--headless",
+		"--disable-gpu",
+		"--no-pdf-header-footer",
+		"--print-to-pdf="+absPDF,
+		fileURL(htmlPath),
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("printing failed: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	if _, err := os.Stat(absPDF); err != nil {
+		return errors.New("printing failed: no PDF was written")
+	}
+	return nil
+}
+
+// fileURL returns the file:// URL of an absolute path
+func fileURL(p string) string {
+	slashed := filepath.ToSlash(p)
+	if !strings.HasPrefix(slashed, "/") {
+		slashed = "/" + slashed
+	}
+	return (&url.URL{Scheme: "file", Path: slashed}).String()
+}
+
+// writeFile writes data, creating parent directories
+func writeFile(p string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(p, data, 0644)
+}
+
+// copyFile copies src to dst, creating parent directories
+func copyFile(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
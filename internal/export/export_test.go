@@ -0,0 +1,175 @@
+package export
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"inkwell/internal/cite"
+	"inkwell/internal/filesystem"
+	"inkwell/internal/i18n"
+	"inkwell/internal/render"
+)
+
+// newVault creates a vault with two linked notes and an image
+func newVault(t *testing.T) *Exporter {
+	t.Helper()
+
+	root := t.TempDir()
+	files := map[string]string{
+		"intro.md":        "# Intro\n\nSee [setup](guide/setup.md#install) and [site](https://example.com).\n",
+		"guide/setup.md":  "# Setup\n\n![diagram](../img/d.png)\n",
+		"img/d.png":       "png",
+		".inkwell/x.json": "{}",
+	}
+	for name, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(p), 0755)
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return &Exporter{
+		FS:       &filesystem.FileSystem{RootDir: root},
+		Renderer: render.New(render.Options{}),
+	}
+}
+
+func TestExportHTML(t *testing.T) {
+	e := newVault(t)
+	out := t.TempDir()
+
+	written, err := e.Export(context.Background(), FormatHTML, "intro.md", out)
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if !reflect.DeepEqual(written, []string{"intro.html"}) {
+		t.Errorf("Written = %v", written)
+	}
+
+	page, _ := os.ReadFile(filepath.Join(out, "intro.html"))
+	for _, want := range []string{"<title>intro</title>", `href="guide/setup.html#install"`, `href="https://example.com"`} {
+		if !strings.Contains(string(page), want) {
+			t.Errorf("Page missing %s:\n%s", want, page)
+		}
+	}
+}
+
+func TestExportSite(t *testing.T) {
+	e := newVault(t)
+	out := t.TempDir()
+
+	written, err := e.Export(context.Background(), FormatSite, ".", out)
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	sort.Strings(written)
+	want := []string{"guide/setup.html", "img/d.png", "index.html", "intro.html"}
+	if !reflect.DeepEqual(written, want) {
+		t.Errorf("Written = %v, want %v", written, want)
+	}
+
+	index, _ := os.ReadFile(filepath.Join(out, "index.html"))
+	if !strings.Contains(string(index), `<a href="guide/setup.html">guide/setup</a>`) {
+		t.Errorf("Index missing page link:\n%s", index)
+	}
+	if _, err := os.Stat(filepath.Join(out, ".inkwell")); !os.IsNotExist(err) {
+		t.Error("Hidden folders should not be exported")
+	}
+}
+
+func TestExportSiteLocale(t *testing.T) {
+	e := newVault(t)
+	e.Locale = i18n.Negotiate("de-DE,de;q=0.9")
+	modified := time.Date(2024, 3, 5, 9, 0, 0, 0, time.UTC)
+	os.Chtimes(filepath.Join(e.FS.RootDir, "intro.md"), modified, modified)
+	out := t.TempDir()
+
+	if _, err := e.Export(context.Background(), FormatSite, ".", out); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	index, _ := os.ReadFile(filepath.Join(out, "index.html"))
+	for _, want := range []string{`<html lang="de">`, `<time datetime="2024-03-05T09:00:00Z">Updated 05.03.2024</time>`} {
+		if !strings.Contains(string(index), want) {
+			t.Errorf("Index missing %s:\n%s", want, index)
+		}
+	}
+}
+
+func TestExportPDF(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script as the browser")
+	}
+
+	// A stand-in browser that writes the file named by --print-to-pdf
+	chrome := filepath.Join(t.TempDir(), "chrome")
+	script := "#!/bin/sh\nfor a in \"$@\"; do case $a in --print-to-pdf=*) echo pdf > \"${a#--print-to-pdf=}\";; esac; done\n"
+	if err := os.WriteFile(chrome, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	e := newVault(t)
+	e.Chrome = chrome
+	out := t.TempDir()
+
+	written, err := e.Export(context.Background(), FormatPDF, "guide", out)
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if !reflect.DeepEqual(written, []string{"setup.pdf"}) {
+		t.Errorf("Written = %v", written)
+	}
+	if _, err := os.Stat(filepath.Join(out, "setup.pdf")); err != nil {
+		t.Errorf("PDF not written: %v", err)
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	if f, err := ParseFormat("SITE"); err != nil || f != FormatSite {
+		t.Errorf("ParseFormat = %v, %v", f, err)
+	}
+	if _, err := ParseFormat("docx"); err == nil {
+		t.Error("ParseFormat should reject unknown formats")
+	}
+}
+
+func TestExportCitations(t *testing.T) {
+	e := newVault(t)
+	e.Bibliography = cite.New("references.bib", []cite.Entry{{Key: "doe2019", Title: "Notes", Authors: []string{"Doe, Ann"}, Year: "2019"}})
+	os.WriteFile(filepath.Join(e.FS.RootDir, "cited.md"), []byte("As [@doe2019] argues.\n"), 0644)
+	out := t.TempDir()
+
+	if _, err := e.Export(context.Background(), FormatHTML, "cited.md", out); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	page, _ := os.ReadFile(filepath.Join(out, "cited.html"))
+	for _, want := range []string{`<a href="#ref-doe2019">Doe 2019</a>`, `<p id="ref-doe2019" class="reference">`} {
+		if !strings.Contains(string(page), want) {
+			t.Errorf("Page missing %s:\n%s", want, page)
+		}
+	}
+}
+
+func TestExportNavigation(t *testing.T) {
+	e := newVault(t)
+	e.Navigation = render.Navigation{TOC: true, Anchors: true}
+	out := t.TempDir()
+
+	if _, err := e.Export(context.Background(), FormatHTML, "intro.md", out); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	page, _ := os.ReadFile(filepath.Join(out, "intro.html"))
+	for _, want := range []string{`<nav class="toc">`, `<li><a href="#intro">Intro</a></li>`, `<a class="anchor" href="#intro"`} {
+		if !strings.Contains(string(page), want) {
+			t.Errorf("Page missing %s:\n%s", want, page)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package filesystem
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+)
+
+// ErrAccessDenied is returned for paths a Guard refuses
+var ErrAccessDenied = errors.New("access denied")
+
+// Guard decides whether a path may be read, or changed when write is set.
+// Paths are relative to the root, cleaned and slash-separated; the root
+// itself is ".".
+type Guard func(relativePath string, write bool) bool
+
+// WithGuard returns a view of the file system that checks every path it is
+// given against guard. Views share the tree cache with fs. A nil guard
+// allows everything.
+func (fs *FileSystem) WithGuard(guard Guard) *FileSystem {
+	view := *fs
+	view.guard = guard
+	return &view
+}
+
+// CanRead reports whether the guard lets a path be read
+func (fs *FileSystem) CanRead(relativePath string) bool {
+	return fs.checkPath(relativePath, false) == nil
+}
+
+// CanWrite reports whether the guard lets a path be changed
+func (fs *FileSystem) CanWrite(relativePath string) bool {
+	return fs.checkPath(relativePath, true) == nil
+}
+
+// checkPath validates a path and asks the guard about it
+func (fs *FileSystem) checkPath(relativePath string, write bool) error {
+	if err := fs.validatePath(relativePath); err != nil {
+		return err
+	}
+	if fs.guard != nil && !fs.guard(filepath.ToSlash(filepath.Clean(relativePath)), write) {
+		return fmt.Errorf("%s: %w", filepath.ToSlash(relativePath), ErrAccessDenied)
+	}
+	return nil
+}
+
+// pruneTree returns a copy of node without the entries the guard hides.
+// A folder that can't be read is kept if something inside it can.
+func (fs *FileSystem) pruneTree(node *FileNode) *FileNode {
+	if fs.guard == nil || node == nil {
+		return node
+	}
+	pruned := *node
+	pruned.Children = nil
+	for _, child := range node.Children {
+		if c := fs.pruneTree(child); c != nil {
+			pruned.Children = append(pruned.Children, c)
+		}
+	}
+	if pruned.Index != "" {
+		pruned.Index = landingPage(pruned.Children)
+	}
+	path := node.Path
+	if path == "" {
+		path = "."
+	}
+	if node.IsDir && (path == "." || len(pruned.Children) > 0) {
+		return &pruned
+	}
+	if !fs.guard(filepath.ToSlash(filepath.Clean(path)), false) {
+		return nil
+	}
+	return &pruned
+}
@@ -0,0 +1,240 @@
+package filesystem
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"inkwell/internal/fixup"
+
+	"github.com/google/uuid"
+)
+
+// Asset layouts, how images are arranged in the assets folder
+const (
+	AssetLayoutFlat    = "flat"     // Directly in the assets folder
+	AssetLayoutPerNote = "per-note" // In a folder per note, e.g. assets/notes/plan/ for notes/plan.md
+)
+
+// ValidAssetLayout reports whether layout names an asset layout
+func ValidAssetLayout(layout string) bool {
+	switch layout {
+	case "", AssetLayoutFlat, AssetLayoutPerNote:
+		return true
+	}
+	return false
+}
+
+// SaveNoteImage saves an image pasted or dropped into a note, in the
+// note's own folder when the layout is per-note
+func (fs *FileSystem) SaveNoteImage(note string, data []byte, extension string) (string, error) {
+	if note == "" || fs.AssetLayout != AssetLayoutPerNote {
+		return fs.SaveImage(data, extension)
+	}
+	return fs.SaveImageAt(path.Join(noteAssetsFolder(note), uuid.New().String()+extension), data)
+}
+
+// noteAssetsFolder returns the folder under the assets folder that holds a
+// note's images in the per-note layout, slash-separated
+func noteAssetsFolder(note string) string {
+	note = filepath.ToSlash(note)
+	return strings.TrimSuffix(note, path.Ext(note))
+}
+
+// AssetMove is an asset moved by a migration
+type AssetMove struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// AssetMigration describes the changes that bring a vault's assets into a
+// layout
+type AssetMigration struct {
+	Layout string      `json:"layout"`
+	Moved  []AssetMove `json:"moved"`
+	Notes  []string    `json:"notes"`  // Notes whose references were rewritten
+	Shared []string    `json:"shared"` // Assets several notes use, left where they are in the per-note layout
+
+	// Changed lists every path added, removed or modified, for staging
+	Changed []string `json:"changed"`
+}
+
+// MigrateAssets moves the images notes refer to into layout and rewrites
+// the references to them, both /images/ URLs and relative paths. Images no
+// note refers to stay where they are. With dryRun, nothing is changed and
+// the result says what would be.
+func (fs *FileSystem) MigrateAssets(layout string, dryRun bool) (*AssetMigration, error) {
+	if layout == "" {
+		layout = AssetLayoutFlat
+	}
+	if !ValidAssetLayout(layout) {
+		return nil, fmt.Errorf("unknown asset layout %q (use %s or %s)", layout, AssetLayoutFlat, AssetLayoutPerNote)
+	}
+
+	notes, err := fs.ListNotes("")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list notes: %w", err)
+	}
+
+	// Find which notes use each asset
+	contents := make(map[string]string, len(notes))
+	users := make(map[string][]string)
+	for _, note := range notes {
+		content, err := fs.ReadFile(note)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", filepath.ToSlash(note), err)
+		}
+		contents[note] = content
+		seen := make(map[string]bool)
+		fixup.RewriteDestinations(content, func(dest string) string {
+			if asset, ok := fs.assetRef(note, dest); ok && !seen[asset] {
+				seen[asset] = true
+				users[asset] = append(users[asset], note)
+			}
+			return dest
+		})
+	}
+
+	migration := &AssetMigration{Layout: layout, Moved: []AssetMove{}, Notes: []string{}, Shared: []string{}, Changed: []string{}}
+	assets := make([]string, 0, len(users))
+	for asset := range users {
+		assets = append(assets, asset)
+	}
+	sort.Strings(assets)
+
+	// Plan where each asset goes, never onto a file already there
+	moves := make(map[string]string)
+	taken := make(map[string]bool)
+	for _, asset := range assets {
+		var dir string
+		switch {
+		case layout == AssetLayoutFlat:
+			dir = fs.assetsDir()
+		case len(users[asset]) == 1:
+			dir = filepath.Join(fs.assetsDir(), filepath.FromSlash(noteAssetsFolder(users[asset][0])))
+		default:
+			migration.Shared = append(migration.Shared, filepath.ToSlash(asset))
+			continue
+		}
+		if filepath.Dir(asset) == dir {
+			continue
+		}
+
+		target := filepath.Join(dir, filepath.Base(asset))
+		ext := filepath.Ext(target)
+		base := strings.TrimSuffix(target, ext)
+		for i := 1; taken[target] || fs.FileExists(target); i++ {
+			target = fmt.Sprintf("%s-%d%s", base, i, ext)
+		}
+		taken[target] = true
+		moves[asset] = target
+		migration.Moved = append(migration.Moved, AssetMove{From: filepath.ToSlash(asset), To: filepath.ToSlash(target)})
+		migration.Changed = append(migration.Changed, filepath.ToSlash(asset), filepath.ToSlash(target))
+	}
+
+	// Point the notes at the new locations, keeping the kind of reference
+	rewritten := make(map[string]string)
+	for _, note := range notes {
+		content, n := fixup.RewriteDestinations(contents[note], func(dest string) string {
+			asset, ok := fs.assetRef(note, dest)
+			if !ok || moves[asset] == "" {
+				return dest
+			}
+			return fs.assetDest(note, dest, moves[asset])
+		})
+		if n > 0 {
+			rewritten[note] = content
+			migration.Notes = append(migration.Notes, filepath.ToSlash(note))
+			migration.Changed = append(migration.Changed, filepath.ToSlash(note))
+		}
+	}
+	sort.Strings(migration.Changed)
+
+	if dryRun {
+		return migration, nil
+	}
+	for _, asset := range assets {
+		if target, ok := moves[asset]; ok {
+			if err := fs.RenameFile(asset, target); err != nil {
+				return nil, err
+			}
+			fs.removeEmptyAssetDirs(filepath.Dir(asset))
+		}
+	}
+	for _, note := range notes {
+		if content, ok := rewritten[note]; ok {
+			if err := fs.WriteFile(note, content); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return migration, nil
+}
+
+// assetRef returns the asset in the assets folder that a link or image
+// destination in note refers to, if it refers to one that exists
+func (fs *FileSystem) assetRef(note, dest string) (string, bool) {
+	target, _ := cutSuffix(dest)
+	if target == "" || strings.HasPrefix(target, "//") || strings.Contains(target, ":") {
+		return "", false
+	}
+	target, err := url.PathUnescape(target)
+	if err != nil {
+		return "", false
+	}
+
+	var asset string
+	if name, ok := strings.CutPrefix(target, "/images/"); ok {
+		asset = filepath.Join(fs.assetsDir(), filepath.FromSlash(name))
+	} else if !strings.HasPrefix(target, "/") {
+		asset = filepath.Join(filepath.Dir(note), filepath.FromSlash(target))
+	} else {
+		return "", false
+	}
+
+	rel, err := filepath.Rel(fs.assetsDir(), asset)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return "", false
+	}
+	if info, err := os.Stat(filepath.Join(fs.RootDir, asset)); err != nil || info.IsDir() {
+		return "", false
+	}
+	return asset, true
+}
+
+// assetDest returns the destination that refers to asset the way dest
+// referred to where it was, as an /images/ URL or relative to note
+func (fs *FileSystem) assetDest(note, dest, asset string) string {
+	target, suffix := cutSuffix(dest)
+	if strings.HasPrefix(target, "/images/") {
+		return fs.ImageURL(asset) + suffix
+	}
+	rel, err := filepath.Rel(filepath.Dir(note), asset)
+	if err != nil {
+		return dest
+	}
+	return filepath.ToSlash(rel) + suffix
+}
+
+// cutSuffix splits a destination at its query or fragment
+func cutSuffix(dest string) (target, suffix string) {
+	if i := strings.IndexAny(dest, "?#"); i >= 0 {
+		return dest[:i], dest[i:]
+	}
+	return dest, ""
+}
+
+// removeEmptyAssetDirs removes dir and its parents, up to the assets
+// folder, while they are empty
+func (fs *FileSystem) removeEmptyAssetDirs(dir string) {
+	for dir != fs.assetsDir() && dir != "." && strings.HasPrefix(dir, fs.assetsDir()+string(filepath.Separator)) {
+		if err := os.Remove(filepath.Join(fs.RootDir, dir)); err != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
@@ -0,0 +1,173 @@
+package filesystem
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Cipher encrypts file contents at rest. Filenames stay readable.
+type Cipher interface {
+	Seal(plaintext []byte) ([]byte, error)
+	// Open decrypts sealed data, returning data that was never sealed as
+	// it is
+	Open(data []byte) ([]byte, error)
+}
+
+// seal prepares data for writing to disk
+func (fs *FileSystem) seal(data []byte) ([]byte, error) {
+	if fs.Cipher == nil {
+		return data, nil
+	}
+	sealed, err := fs.Cipher.Seal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt file: %w", err)
+	}
+	return sealed, nil
+}
+
+// open decrypts data read from disk
+func (fs *FileSystem) open(data []byte) ([]byte, error) {
+	if fs.Cipher == nil {
+		return data, nil
+	}
+	plaintext, err := fs.Cipher.Open(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt file: %w", err)
+	}
+	return plaintext, nil
+}
+
+// Decrypt opens content sealed by this file system that was read some other
+// way, such as an old version from git
+func (fs *FileSystem) Decrypt(data []byte) ([]byte, error) {
+	return fs.open(data)
+}
+
+// ReadBytes reads any file in the root, decrypting it if needed
+func (fs *FileSystem) ReadBytes(relativePath string) ([]byte, error) {
+	if err := fs.checkPath(relativePath, false); err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(filepath.Join(fs.RootDir, relativePath))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	return fs.open(data)
+}
+
+// nopSeekCloser lets a decrypted file be served like an open one
+type nopSeekCloser struct {
+	*bytes.Reader
+}
+
+func (nopSeekCloser) Close() error { return nil }
+
+// Open opens a file for serving. Without a cipher the file is streamed
+// from disk; with one it is decrypted into memory first.
+func (fs *FileSystem) Open(relativePath string) (io.ReadSeekCloser, os.FileInfo, error) {
+	if err := fs.checkPath(relativePath, false); err != nil {
+		return nil, nil, err
+	}
+
+	f, err := os.Open(filepath.Join(fs.RootDir, relativePath))
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	if info.IsDir() || fs.Cipher == nil {
+		return f, info, nil
+	}
+
+	data, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+	if data, err = fs.open(data); err != nil {
+		return nil, nil, err
+	}
+	return nopSeekCloser{bytes.NewReader(data)}, info, nil
+}
+
+// SealFile encrypts a file in place if it isn't already. Files written
+// around the FileSystem, such as finished uploads, are sealed this way.
+func (fs *FileSystem) SealFile(relativePath string) error {
+	if fs.Cipher == nil {
+		return nil
+	}
+	if err := fs.checkPath(relativePath, true); err != nil {
+		return err
+	}
+	_, err := fs.sealFile(filepath.Join(fs.RootDir, relativePath))
+	return err
+}
+
+// SealAll encrypts every file in the root that isn't already, returning how
+// many were sealed. Hidden files and folders, such as .git, are left alone.
+func (fs *FileSystem) SealAll() (int, error) {
+	if fs.Cipher == nil {
+		return 0, nil
+	}
+
+	sealed := 0
+	err := filepath.WalkDir(fs.RootDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return nil // Skip entries we can't read
+		}
+		if path != fs.RootDir && IsHidden(path) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		done, err := fs.sealFile(path)
+		if done {
+			sealed++
+		}
+		return err
+	})
+	return sealed, err
+}
+
+// sealFile rewrites a file sealed, through a temporary file so a crash
+// can't leave it half written. It reports whether the file needed sealing.
+func (fs *FileSystem) sealFile(fullPath string) (bool, error) {
+	data, err := os.ReadFile(fullPath)
+	if err != nil {
+		return false, fmt.Errorf("failed to read file: %w", err)
+	}
+	if plain, err := fs.Cipher.Open(data); err != nil || !bytes.Equal(plain, data) {
+		return false, nil // Already sealed
+	}
+	sealed, err := fs.seal(data)
+	if err != nil {
+		return false, err
+	}
+
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		return false, err
+	}
+	// Hidden, so the tree never shows it
+	tmp := filepath.Join(filepath.Dir(fullPath), "."+filepath.Base(fullPath)+".tmp")
+	if err := os.WriteFile(tmp, sealed, info.Mode().Perm()); err != nil {
+		return false, fmt.Errorf("failed to encrypt %s: %w", filepath.Base(fullPath), err)
+	}
+	if err := os.Rename(tmp, fullPath); err != nil {
+		os.Remove(tmp)
+		return false, fmt.Errorf("failed to encrypt %s: %w", filepath.Base(fullPath), err)
+	}
+	return true, nil
+}
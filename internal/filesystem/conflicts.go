@@ -0,0 +1,269 @@
+package filesystem
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Ways a sync conflict can be resolved
+const (
+	KeepOriginal = "original" // Delete the copy
+	KeepCopy     = "copy"     // Replace the original with the copy
+	KeepMerged   = "merged"   // Save merged content over the original, then delete the copy
+)
+
+// ErrNotConflict is returned for files that aren't a sync conflict copy
+var ErrNotConflict = errors.New("not a sync conflict copy")
+
+// SyncConflict is a copy left beside a file by a sync tool that saw it
+// change in two places at once
+type SyncConflict struct {
+	Path           string    `json:"path"`     // The conflicted copy
+	Original       string    `json:"original"` // The file it is a copy of
+	Source         string    `json:"source"`   // Tool that made it: dropbox, nextcloud, syncthing, drive or inkwell
+	ModTime        time.Time `json:"modTime"`
+	OriginalExists bool      `json:"originalExists"`
+}
+
+// conflictPatterns match the stem of conflict copies, without the
+// extension. The first group is the stem of the original.
+var conflictPatterns = []struct {
+	source string
+	re     *regexp.Regexp
+}{
+	// notes (conflict 2024-05-01 1504).md, from --storage
+	{"inkwell", regexp.MustCompile(`^(.+) \(conflict \d{4}-\d{2}-\d{2} \d{4}\)$`)},
+	// notes (Alice's conflicted copy 2024-05-01).md
+	{"dropbox", regexp.MustCompile(`^(.+) \([^()]+'s conflicted copy(?: \d{4}-\d{2}-\d{2})?(?: \(\d+\))?\)$`)},
+	// notes (conflicted copy 2024-05-01 150423).md
+	{"nextcloud", regexp.MustCompile(`^(.+) \(conflicted copy(?: \d{4}-\d{2}-\d{2})?(?: \d+)?\)$`)},
+	// notes.sync-conflict-20240501-150423-ABCDEFG.md
+	{"syncthing", regexp.MustCompile(`^(.+)\.sync-conflict-\d{8}-\d{6}(?:-[A-Z0-9]{7})?$`)},
+}
+
+// numberedCopy matches "notes (1)", which Google Drive makes on conflicts
+// but people also make by hand, so it only counts beside its original
+var numberedCopy = regexp.MustCompile(`^(.+) \(\d+\)$`)
+
+// conflictOf returns the name of the file a conflict copy was made from
+// and the tool that made it. exists reports whether a name is taken in the
+// same folder.
+func conflictOf(name string, exists func(string) bool) (original, source string, ok bool) {
+	ext := filepath.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	for _, p := range conflictPatterns {
+		if m := p.re.FindStringSubmatch(stem); m != nil {
+			return m[1] + ext, p.source, true
+		}
+	}
+	if m := numberedCopy.FindStringSubmatch(stem); m != nil && exists(m[1]+ext) {
+		return m[1] + ext, "drive", true
+	}
+	return "", "", false
+}
+
+// SyncConflicts returns the conflict copies left in the vault by sync tools
+// such as Dropbox, Nextcloud, Syncthing or Google Drive
+func (fs *FileSystem) SyncConflicts() ([]SyncConflict, error) {
+	conflicts := []SyncConflict{}
+	err := filepath.WalkDir(fs.RootDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return nil // Skip entries we can't read
+		}
+		if path != fs.RootDir && IsHidden(path) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		dir := filepath.Dir(path)
+		exists := func(name string) bool {
+			_, err := os.Stat(filepath.Join(dir, name))
+			return err == nil
+		}
+		original, source, ok := conflictOf(d.Name(), exists)
+		if !ok {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(fs.RootDir, path)
+		if err != nil {
+			return err
+		}
+		if fs.guard != nil && !fs.CanRead(relPath) {
+			return nil
+		}
+		c := SyncConflict{
+			Path:           relPath,
+			Original:       filepath.Join(filepath.Dir(relPath), original),
+			Source:         source,
+			OriginalExists: exists(original),
+		}
+		if info, err := d.Info(); err == nil {
+			c.ModTime = info.ModTime()
+		}
+		conflicts = append(conflicts, c)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return conflicts, nil
+}
+
+// SyncConflict describes the conflict copy at relativePath
+func (fs *FileSystem) SyncConflict(relativePath string) (SyncConflict, error) {
+	if err := fs.checkPath(relativePath, false); err != nil {
+		return SyncConflict{}, err
+	}
+	dir := filepath.Dir(filepath.Join(fs.RootDir, relativePath))
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(dir, name))
+		return err == nil
+	}
+	original, source, ok := conflictOf(filepath.Base(relativePath), exists)
+	if !ok {
+		return SyncConflict{}, ErrNotConflict
+	}
+	return SyncConflict{
+		Path:           relativePath,
+		Original:       filepath.Join(filepath.Dir(relativePath), original),
+		Source:         source,
+		OriginalExists: exists(original),
+	}, nil
+}
+
+// ResolveSyncConflict deletes a conflict copy, or with KeepCopy moves it
+// over the original, and returns the path of the original
+func (fs *FileSystem) ResolveSyncConflict(relativePath, keep string) (string, error) {
+	c, err := fs.SyncConflict(relativePath)
+	if err != nil {
+		return "", err
+	}
+	if err := fs.checkPath(c.Path, true); err != nil {
+		return "", err
+	}
+	if err := fs.checkPath(c.Original, true); err != nil {
+		return "", err
+	}
+	copyPath := filepath.Join(fs.RootDir, c.Path)
+
+	switch keep {
+	case KeepOriginal:
+		if err := os.Remove(copyPath); err != nil {
+			return "", err
+		}
+		fs.removed.add(c.Path)
+	case KeepCopy:
+		if err := os.Rename(copyPath, filepath.Join(fs.RootDir, c.Original)); err != nil {
+			return "", err
+		}
+		fs.removed.add(c.Path)
+		fs.changed(c.Original)
+	default:
+		return "", fmt.Errorf("unknown resolution %q", keep)
+	}
+	fs.changed(c.Path)
+	return c.Original, nil
+}
+
+// maxMergeCells bounds the line comparison table; larger differences are
+// kept whole between conflict markers
+const maxMergeCells = 4_000_000
+
+// MergeLines merges two versions of a file that have no common ancestor,
+// as a starting point for resolving a conflict. Lines only one side has are
+// kept; where both sides changed the same lines, both versions are kept
+// between conflict markers.
+func MergeLines(ours, theirs, oursLabel, theirsLabel string) string {
+	a := strings.SplitAfter(ours, "\n")
+	b := strings.SplitAfter(theirs, "\n")
+	// Both end in a newline so the last lines compare equal
+	for _, lines := range []*[]string{&a, &b} {
+		if n := len(*lines); (*lines)[n-1] == "" {
+			*lines = (*lines)[:n-1]
+		} else {
+			(*lines)[n-1] += "\n"
+		}
+	}
+
+	var out strings.Builder
+	conflict := func(x, y []string) {
+		switch {
+		case len(x) == 0:
+			out.WriteString(strings.Join(y, ""))
+		case len(y) == 0:
+			out.WriteString(strings.Join(x, ""))
+		default:
+			out.WriteString("<<<<<<< " + oursLabel + "\n")
+			out.WriteString(strings.Join(x, ""))
+			out.WriteString("=======\n")
+			out.WriteString(strings.Join(y, ""))
+			out.WriteString(">>>>>>> " + theirsLabel + "\n")
+		}
+	}
+
+	// Lines both share at the start and end need no comparing
+	start := 0
+	for start < len(a) && start < len(b) && a[start] == b[start] {
+		start++
+	}
+	end := 0
+	for end < len(a)-start && end < len(b)-start && a[len(a)-1-end] == b[len(b)-1-end] {
+		end++
+	}
+	out.WriteString(strings.Join(a[:start], ""))
+	x, y := a[start:len(a)-end], b[start:len(b)-end]
+
+	if len(x)*len(y) > maxMergeCells {
+		conflict(x, y)
+	} else {
+		// lcs[i][j] is the longest common subsequence of x[i:] and y[j:]
+		lcs := make([][]int, len(x)+1)
+		for i := range lcs {
+			lcs[i] = make([]int, len(y)+1)
+		}
+		for i := len(x) - 1; i >= 0; i-- {
+			for j := len(y) - 1; j >= 0; j-- {
+				if x[i] == y[j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else {
+					lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+				}
+			}
+		}
+
+		i, j := 0, 0
+		hunkX, hunkY := i, j
+		for i < len(x) || j < len(y) {
+			switch {
+			case i < len(x) && j < len(y) && x[i] == y[j]:
+				conflict(x[hunkX:i], y[hunkY:j])
+				out.WriteString(x[i])
+				i, j = i+1, j+1
+				hunkX, hunkY = i, j
+			case j == len(y) || (i < len(x) && lcs[i+1][j] >= lcs[i][j+1]):
+				i++
+			default:
+				j++
+			}
+		}
+		conflict(x[hunkX:], y[hunkY:])
+	}
+
+	out.WriteString(strings.Join(a[len(a)-end:], ""))
+	result := out.String()
+	if !strings.HasSuffix(ours, "\n") && !strings.HasSuffix(theirs, "\n") {
+		result = strings.TrimSuffix(result, "\n")
+	}
+	return result
+}
@@ -0,0 +1,129 @@
+package filesystem
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+)
+
+// WriteZip streams a zip of a directory (the whole root when relativeDir
+// is empty), including assets. Hidden files and anything matched by the
+// root's .gitignore files are left out. Entries are placed under a folder
+// named after the exported directory.
+func (fs *FileSystem) WriteZip(w io.Writer, relativeDir string) error {
+	if err := fs.checkPath(relativeDir, false); err != nil {
+		return err
+	}
+
+	baseDir := filepath.Join(fs.RootDir, relativeDir)
+	info, err := os.Stat(baseDir)
+	if err != nil {
+		return fmt.Errorf("directory not found: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("not a directory: %s", relativeDir)
+	}
+
+	patterns, err := gitignore.ReadPatterns(osfs.New(fs.RootDir), nil)
+	if err != nil {
+		return fmt.Errorf("failed to read ignore rules: %w", err)
+	}
+	matcher := gitignore.NewMatcher(patterns)
+
+	prefix := filepath.Base(baseDir)
+	zw := zip.NewWriter(w)
+
+	err = filepath.WalkDir(baseDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return nil // Skip entries we can't read
+		}
+		if path == baseDir {
+			return nil
+		}
+
+		// Skip hidden files and directories
+		if IsHidden(path) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		relToRoot, err := filepath.Rel(fs.RootDir, path)
+		if err != nil {
+			return err
+		}
+		if matcher.Match(strings.Split(filepath.ToSlash(relToRoot), "/"), d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if d.IsDir() || !d.Type().IsRegular() {
+			return nil
+		}
+		if fs.guard != nil && !fs.CanRead(relToRoot) {
+			return nil
+		}
+
+		relToBase, err := filepath.Rel(baseDir, path)
+		if err != nil {
+			return err
+		}
+		return fs.addZipFile(zw, path, prefix+"/"+filepath.ToSlash(relToBase))
+	})
+	if err != nil {
+		zw.Close()
+		return err
+	}
+
+	return zw.Close()
+}
+
+// addZipFile copies a file into the archive, keeping its modification time.
+// Files in an encrypted vault are decrypted on the way.
+func (fs *FileSystem) addZipFile(zw *zip.Writer, path, name string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	header.Method = zip.Deflate
+
+	entry, err := zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+
+	if fs.Cipher == nil {
+		_, err = io.Copy(entry, f)
+		return err
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return err
+	}
+	if data, err = fs.open(data); err != nil {
+		return err
+	}
+	_, err = entry.Write(data)
+	return err
+}
@@ -2,16 +2,31 @@ package filesystem
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 // FileSystem handles all file operations within a root directory
 type FileSystem struct {
-	RootDir string
+	RootDir    string
+	Ignore     []string // Name patterns left out of the file tree
+	Extensions []string // Note extensions shown in the tree; empty for .md and .markdown
+	AssetsDir  string   // Folder for uploaded images, relative to the root; empty for "assets"
+	Cipher     Cipher   // Encrypts file contents on disk; nil stores them as they are
+
+	// AssetLayout is how images are arranged in the assets folder, one of
+	// the AssetLayout constants; empty for flat
+	AssetLayout string
+
+	tree    *treeCache // Set by TrackChanges
+	removed *removals  // Set by TrackChanges
+	guard   Guard      // Set by WithGuard
 }
 
 // New creates a new FileSystem with the given root directory
@@ -21,7 +36,7 @@ func New(rootDir string) *FileSystem {
 
 // ReadFile reads a file and returns its content
 func (fs *FileSystem) ReadFile(relativePath string) (string, error) {
-	if err := fs.validatePath(relativePath); err != nil {
+	if err := fs.checkPath(relativePath, false); err != nil {
 		return "", err
 	}
 
@@ -30,13 +45,16 @@ func (fs *FileSystem) ReadFile(relativePath string) (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("failed to read file: %w", err)
 	}
+	if content, err = fs.open(content); err != nil {
+		return "", err
+	}
 
 	return string(content), nil
 }
 
 // WriteFile writes content to a file
 func (fs *FileSystem) WriteFile(relativePath, content string) error {
-	if err := fs.validatePath(relativePath); err != nil {
+	if err := fs.checkPath(relativePath, true); err != nil {
 		return err
 	}
 
@@ -48,16 +66,21 @@ func (fs *FileSystem) WriteFile(relativePath, content string) error {
 		return fmt.Errorf("failed to create directory: %w", err)
 	}
 
-	if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+	data, err := fs.seal([]byte(content))
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(fullPath, data, 0644); err != nil {
 		return fmt.Errorf("failed to write file: %w", err)
 	}
 
+	fs.changed(relativePath)
 	return nil
 }
 
 // CreateFile creates a new file with optional initial content
 func (fs *FileSystem) CreateFile(relativePath, content string) error {
-	if err := fs.validatePath(relativePath); err != nil {
+	if err := fs.checkPath(relativePath, true); err != nil {
 		return err
 	}
 
@@ -73,7 +96,7 @@ func (fs *FileSystem) CreateFile(relativePath, content string) error {
 
 // DeleteFile deletes a file
 func (fs *FileSystem) DeleteFile(relativePath string) error {
-	if err := fs.validatePath(relativePath); err != nil {
+	if err := fs.checkPath(relativePath, true); err != nil {
 		return err
 	}
 
@@ -83,12 +106,14 @@ func (fs *FileSystem) DeleteFile(relativePath string) error {
 		return fmt.Errorf("failed to delete file: %w", err)
 	}
 
+	fs.removed.add(relativePath)
+	fs.changed(relativePath)
 	return nil
 }
 
 // CreateDirectory creates a new directory
 func (fs *FileSystem) CreateDirectory(relativePath string) error {
-	if err := fs.validatePath(relativePath); err != nil {
+	if err := fs.checkPath(relativePath, true); err != nil {
 		return err
 	}
 
@@ -103,17 +128,25 @@ func (fs *FileSystem) CreateDirectory(relativePath string) error {
 
 // SaveImage saves an image to the assets directory and returns its relative path
 func (fs *FileSystem) SaveImage(data []byte, extension string) (string, error) {
+	if err := fs.checkPath(fs.assetsDir(), true); err != nil {
+		return "", err
+	}
+
 	// Ensure assets directory exists
-	assetsDir := filepath.Join(fs.RootDir, "assets")
+	assetsDir := filepath.Join(fs.RootDir, fs.assetsDir())
 	if err := os.MkdirAll(assetsDir, 0755); err != nil {
 		return "", fmt.Errorf("failed to create assets directory: %w", err)
 	}
 
 	// Generate unique filename
 	filename := fmt.Sprintf("%s%s", uuid.New().String(), extension)
-	relativePath := filepath.Join("assets", filename)
+	relativePath := filepath.Join(fs.assetsDir(), filename)
 	fullPath := filepath.Join(fs.RootDir, relativePath)
 
+	data, err := fs.seal(data)
+	if err != nil {
+		return "", err
+	}
 	if err := os.WriteFile(fullPath, data, 0644); err != nil {
 		return "", fmt.Errorf("failed to save image: %w", err)
 	}
@@ -121,10 +154,59 @@ func (fs *FileSystem) SaveImage(data []byte, extension string) (string, error) {
 	return relativePath, nil
 }
 
+// SaveImageAt saves an image under the assets directory at the given
+// relative path, keeping folder structure from drag-and-drop uploads. An
+// existing file is never overwritten; a numeric suffix is added instead.
+func (fs *FileSystem) SaveImageAt(relativePath string, data []byte) (string, error) {
+	if err := fs.validatePath(relativePath); err != nil {
+		return "", err
+	}
+	relativePath = filepath.Join(fs.assetsDir(), filepath.FromSlash(relativePath))
+	if err := fs.checkPath(relativePath, true); err != nil {
+		return "", err
+	}
+
+	fullPath := filepath.Join(fs.RootDir, relativePath)
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory: %w", err)
+	}
+	data, err := fs.seal(data)
+	if err != nil {
+		return "", err
+	}
+
+	ext := filepath.Ext(relativePath)
+	base := strings.TrimSuffix(relativePath, ext)
+	for i := 1; ; i++ {
+		f, err := os.OpenFile(filepath.Join(fs.RootDir, relativePath), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if os.IsExist(err) {
+			relativePath = fmt.Sprintf("%s-%d%s", base, i, ext)
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to save image: %w", err)
+		}
+
+		if _, err := f.Write(data); err != nil {
+			f.Close()
+			return "", fmt.Errorf("failed to save image: %w", err)
+		}
+		if err := f.Close(); err != nil {
+			return "", fmt.Errorf("failed to save image: %w", err)
+		}
+		return relativePath, nil
+	}
+}
+
+// OpenImage opens an image from the assets directory for serving
+func (fs *FileSystem) OpenImage(filename string) (io.ReadSeekCloser, os.FileInfo, error) {
+	return fs.Open(filepath.Join(fs.assetsDir(), filename))
+}
+
 // GetImagePath returns the full path to an image file
 func (fs *FileSystem) GetImagePath(filename string) (string, error) {
-	relativePath := filepath.Join("assets", filename)
-	if err := fs.validatePath(relativePath); err != nil {
+	relativePath := filepath.Join(fs.assetsDir(), filename)
+	if err := fs.checkPath(relativePath, false); err != nil {
 		return "", err
 	}
 
@@ -138,6 +220,14 @@ func (fs *FileSystem) GetImagePath(filename string) (string, error) {
 	return fullPath, nil
 }
 
+// ResolvePath validates a relative path and returns its absolute location
+func (fs *FileSystem) ResolvePath(relativePath string) (string, error) {
+	if err := fs.checkPath(relativePath, false); err != nil {
+		return "", err
+	}
+	return filepath.Join(fs.RootDir, relativePath), nil
+}
+
 // validatePath ensures the path is safe and within the root directory
 func (fs *FileSystem) validatePath(relativePath string) error {
 	// Prevent path traversal attacks
@@ -148,22 +238,259 @@ func (fs *FileSystem) validatePath(relativePath string) error {
 	// Clean the path
 	cleanPath := filepath.Clean(relativePath)
 
-	// Ensure the path doesn't start with /
-	if filepath.IsAbs(cleanPath) {
+	// Ensure the path doesn't start with / or, on Windows, \ or a drive
+	if filepath.IsAbs(cleanPath) || filepath.VolumeName(cleanPath) != "" || strings.HasPrefix(cleanPath, string(filepath.Separator)) {
 		return fmt.Errorf("invalid path: absolute paths not allowed")
 	}
 
-	return nil
+	return checkName(relativePath)
 }
 
 // GetTree returns the file tree for the root directory
 func (fs *FileSystem) GetTree() (*FileNode, error) {
-	return BuildTree(fs.RootDir)
+	var tree *FileNode
+	var err error
+	if fs.tree != nil {
+		tree, err = fs.tree.get()
+	} else {
+		tree, err = buildTreeRecursive(fs.RootDir, fs.RootDir, "", fs.treeOptions())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return fs.pruneTree(tree), nil
+}
+
+// GetTreeWithMetadata returns the file tree with the size and title of
+// every note. It reads the start of each note, so it is never cached.
+func (fs *FileSystem) GetTreeWithMetadata() (*FileNode, error) {
+	opts := fs.treeOptions()
+	opts.metadata = true
+	tree, err := buildTreeRecursive(fs.RootDir, fs.RootDir, "", opts)
+	if err != nil {
+		return nil, err
+	}
+	return fs.pruneTree(tree), nil
+}
+
+// TrackChanges keeps the file tree in memory. Changes reported by w, and
+// those made through this FileSystem, rebuild only the folders they touch.
+// The returned trees are shared and must not be modified.
+func (fs *FileSystem) TrackChanges(w *Watcher) {
+	fs.tree = newTreeCache(fs.RootDir, fs.treeOptions())
+	fs.removed = &removals{paths: make(map[string]time.Time)}
+	w.Observe(func(event FileEvent) {
+		// Edits don't change the tree
+		if event.Type != EventModified {
+			fs.changed(event.Path)
+		}
+	})
+}
+
+// removalMemory is how long a file removed through a FileSystem is
+// remembered, comfortably longer than the watcher takes to report it
+const removalMemory = 10 * time.Second
+
+// removals remembers the files deleted or moved away through a FileSystem
+// and its views, so the watcher's events for them can be told from files
+// removed by other programs
+type removals struct {
+	mu    sync.Mutex
+	paths map[string]time.Time
+}
+
+// add records that a file was removed. A nil removals records nothing.
+func (r *removals) add(relativePath string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for p, at := range r.paths {
+		if now.Sub(at) > removalMemory {
+			delete(r.paths, p)
+		}
+	}
+	r.paths[filepath.Clean(relativePath)] = now
+}
+
+// RemovedHere reports whether a file was deleted or moved away through this
+// FileSystem moments ago, rather than by another program. It only knows
+// once TrackChanges is called.
+func (fs *FileSystem) RemovedHere(relativePath string) bool {
+	if fs.removed == nil {
+		return false
+	}
+	fs.removed.mu.Lock()
+	defer fs.removed.mu.Unlock()
+	at, ok := fs.removed.paths[filepath.Clean(relativePath)]
+	return ok && time.Since(at) <= removalMemory
+}
+
+// changed tells the tree cache that a file or folder was added, removed or
+// renamed
+func (fs *FileSystem) changed(relativePath string) {
+	fs.changedDir(filepath.Dir(filepath.Clean(relativePath)))
+}
+
+// changedDir tells the tree cache that the contents of a folder changed
+func (fs *FileSystem) changedDir(relativeDir string) {
+	if fs.tree == nil {
+		return
+	}
+	dir := filepath.Clean(relativeDir)
+	if dir == "." {
+		dir = ""
+	}
+	fs.tree.invalidate(dir)
+}
+
+// treeOptions returns which entries appear in the file tree
+func (fs *FileSystem) treeOptions() treeOptions {
+	return treeOptions{
+		ignore:     fs.Ignore,
+		extensions: fs.Extensions,
+		assetsDir:  fs.assetsDir(),
+		cipher:     fs.Cipher,
+	}
+}
+
+// ListNotes returns the notes under a directory, relative to the root.
+// A missing directory has no notes.
+func (fs *FileSystem) ListNotes(relativeDir string) ([]string, error) {
+	if err := fs.checkPath(relativeDir, false); err != nil {
+		return nil, err
+	}
+
+	notes := []string{}
+	baseDir := filepath.Join(fs.RootDir, relativeDir)
+	err := filepath.WalkDir(baseDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == baseDir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if path != baseDir && IsHidden(path) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || !isNoteFile(d.Name(), fs.Extensions) {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(fs.RootDir, path)
+		if err != nil {
+			return err
+		}
+		if fs.guard != nil && !fs.CanRead(relPath) {
+			return nil
+		}
+		notes = append(notes, relPath)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return notes, nil
+}
+
+// DirStats summarizes everything under a folder
+type DirStats struct {
+	Files          int       // Files anywhere below, notes or not
+	Notes          int       // Notes anywhere below
+	Folders        int       // Folders anywhere below, not counting the folder itself
+	Size           int64     // Bytes taken by the files
+	LatestModified time.Time // Latest modification of a file or folder below, or of the folder itself
+}
+
+// DirStats walks a folder and totals what's inside it. Hidden and ignored
+// entries, and those the guard won't let be read, aren't counted.
+func (fs *FileSystem) DirStats(relativeDir string) (*DirStats, error) {
+	if err := fs.validatePath(relativeDir); err != nil {
+		return nil, err
+	}
+
+	stats := &DirStats{}
+	baseDir := filepath.Join(fs.RootDir, relativeDir)
+	err := filepath.WalkDir(baseDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			if path == baseDir {
+				return err
+			}
+			return nil // Skip entries we can't read
+		}
+		if path != baseDir && (IsHidden(path) || isIgnored(d.Name(), fs.Ignore)) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		relPath, err := filepath.Rel(fs.RootDir, path)
+		if err != nil {
+			return err
+		}
+		if path != baseDir && fs.guard != nil && !fs.CanRead(relPath) {
+			return nil // Readable entries inside a hidden folder still count
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil // Removed while walking
+		}
+		if info.ModTime().After(stats.LatestModified) {
+			stats.LatestModified = info.ModTime()
+		}
+
+		switch {
+		case path == baseDir:
+		case d.IsDir():
+			stats.Folders++
+		default:
+			stats.Files++
+			stats.Size += info.Size()
+			if isNoteFile(d.Name(), fs.Extensions) {
+				stats.Notes++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read folder: %w", err)
+	}
+
+	return stats, nil
+}
+
+// IsNote reports whether a file name has one of the note extensions
+func (fs *FileSystem) IsNote(name string) bool {
+	return isNoteFile(name, fs.Extensions)
+}
+
+// ImageURL returns the URL an image saved by SaveImage is served from
+func (fs *FileSystem) ImageURL(relativePath string) string {
+	name, err := filepath.Rel(fs.assetsDir(), relativePath)
+	if err != nil {
+		name = filepath.Base(relativePath)
+	}
+	return "/images/" + filepath.ToSlash(name)
+}
+
+// assetsDir returns the folder uploaded images are stored in
+func (fs *FileSystem) assetsDir() string {
+	if fs.AssetsDir == "" {
+		return "assets"
+	}
+	return filepath.Clean(filepath.FromSlash(fs.AssetsDir))
 }
 
 // FileExists checks if a file exists
 func (fs *FileSystem) FileExists(relativePath string) bool {
-	if err := fs.validatePath(relativePath); err != nil {
+	if err := fs.checkPath(relativePath, false); err != nil {
 		return false
 	}
 
@@ -174,10 +501,10 @@ func (fs *FileSystem) FileExists(relativePath string) bool {
 
 // RenameFile renames or moves a file
 func (fs *FileSystem) RenameFile(oldPath, newPath string) error {
-	if err := fs.validatePath(oldPath); err != nil {
+	if err := fs.checkPath(oldPath, true); err != nil {
 		return err
 	}
-	if err := fs.validatePath(newPath); err != nil {
+	if err := fs.checkPath(newPath, true); err != nil {
 		return err
 	}
 
@@ -194,5 +521,8 @@ func (fs *FileSystem) RenameFile(oldPath, newPath string) error {
 		return fmt.Errorf("failed to rename file: %w", err)
 	}
 
+	fs.removed.add(oldPath)
+	fs.changed(oldPath)
+	fs.changed(newPath)
 	return nil
 }
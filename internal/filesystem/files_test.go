@@ -1,9 +1,18 @@
 package filesystem
 
 import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"hash/crc32"
+	"io"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestFileSystem(t *testing.T) {
@@ -97,6 +106,21 @@ func TestFileSystem(t *testing.T) {
 		}
 	})
 
+	t.Run("ResolvePath", func(t *testing.T) {
+		fullPath, err := fs.ResolvePath("subdir/nested.md")
+		if err != nil {
+			t.Fatalf("ResolvePath failed: %v", err)
+		}
+
+		if fullPath != filepath.Join(tmpDir, "subdir", "nested.md") {
+			t.Errorf("Unexpected resolved path: %s", fullPath)
+		}
+
+		if _, err := fs.ResolvePath("../outside.md"); err == nil {
+			t.Error("ResolvePath should reject path traversal")
+		}
+	})
+
 	t.Run("SaveImage", func(t *testing.T) {
 		imageData := []byte("fake image data")
 		path, err := fs.SaveImage(imageData, ".png")
@@ -113,6 +137,28 @@ func TestFileSystem(t *testing.T) {
 			t.Error("Image file was not created")
 		}
 	})
+
+	t.Run("SaveImageAt", func(t *testing.T) {
+		first, err := fs.SaveImageAt("shots/a.png", []byte("one"))
+		if err != nil {
+			t.Fatalf("Failed to save image: %v", err)
+		}
+		if first != filepath.Join("assets", "shots", "a.png") {
+			t.Errorf("Unexpected path %s", first)
+		}
+
+		second, err := fs.SaveImageAt("shots/a.png", []byte("two"))
+		if err != nil {
+			t.Fatalf("Failed to save image: %v", err)
+		}
+		if second != filepath.Join("assets", "shots", "a-1.png") {
+			t.Errorf("Existing image should not be overwritten, got %s", second)
+		}
+
+		if _, err := fs.SaveImageAt("../escape.png", []byte("x")); err == nil {
+			t.Error("SaveImageAt should reject path traversal")
+		}
+	})
 }
 
 func TestValidatePath(t *testing.T) {
@@ -139,3 +185,624 @@ func TestValidatePath(t *testing.T) {
 		})
 	}
 }
+
+func TestWriteZip(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	files := map[string]string{
+		"notes/a.md":           "# A",
+		"notes/assets/img.png": "png",
+		"notes/.hidden.md":     "secret",
+		"notes/build/out.html": "ignored",
+		"notes/draft.tmp":      "ignored",
+		"other.md":             "outside",
+		".gitignore":           "build/\n*.tmp\n",
+		"notes/.git/HEAD":      "ref",
+	}
+	for path, content := range files {
+		full := filepath.Join(tmpDir, path)
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fs := New(tmpDir)
+
+	var buf bytes.Buffer
+	if err := fs.WriteZip(&buf, "notes"); err != nil {
+		t.Fatalf("WriteZip failed: %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("Invalid zip: %v", err)
+	}
+
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	sort.Strings(names)
+
+	want := []string{"notes/a.md", "notes/assets/img.png"}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Errorf("Zip entries = %v, want %v", names, want)
+	}
+
+	if err := fs.WriteZip(&buf, "../"); err == nil {
+		t.Error("WriteZip should reject path traversal")
+	}
+}
+
+func buildZip(t *testing.T, files map[string]string) *zip.Reader {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return zr
+}
+
+func TestExtractZip(t *testing.T) {
+	tmpDir := t.TempDir()
+	fs := New(tmpDir)
+
+	if err := os.MkdirAll(filepath.Join(tmpDir, "imported"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "imported", "a.md"), []byte("existing"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	zr := buildZip(t, map[string]string{
+		"a.md":            "new",
+		"sub/b.md":        "b",
+		"__MACOSX/._a.md": "junk",
+		"sub/.DS_Store":   "junk",
+	})
+
+	// Rename keeps both copies
+	result, err := fs.ExtractZip(zr, "imported", ConflictRename, 0)
+	if err != nil {
+		t.Fatalf("ExtractZip failed: %v", err)
+	}
+	sort.Strings(result.Created)
+	want := []string{filepath.Join("imported", "a-1.md"), filepath.Join("imported", "sub", "b.md")}
+	if strings.Join(result.Created, ",") != strings.Join(want, ",") {
+		t.Errorf("Created = %v, want %v", result.Created, want)
+	}
+	if content, _ := fs.ReadFile("imported/a.md"); content != "existing" {
+		t.Errorf("Existing file changed to %q", content)
+	}
+
+	// Skip leaves everything alone
+	result, err = fs.ExtractZip(zr, "imported", ConflictSkip, 0)
+	if err != nil {
+		t.Fatalf("ExtractZip failed: %v", err)
+	}
+	if len(result.Created) != 0 || len(result.Skipped) != 2 {
+		t.Errorf("Skip: created %v, skipped %v", result.Created, result.Skipped)
+	}
+
+	// Overwrite replaces in place
+	if _, err := fs.ExtractZip(zr, "imported", ConflictOverwrite, 0); err != nil {
+		t.Fatalf("ExtractZip failed: %v", err)
+	}
+	if content, _ := fs.ReadFile("imported/a.md"); content != "new" {
+		t.Errorf("Overwrite: content = %q, want %q", content, "new")
+	}
+
+	// An entry bigger than it claims fails part way through, without
+	// touching the note it would replace
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	content := strings.Repeat("x", 100)
+	w, err := zw.CreateRaw(&zip.FileHeader{
+		Name:               "a.md",
+		Method:             zip.Store,
+		CRC32:              crc32.ChecksumIEEE([]byte(content)),
+		CompressedSize64:   uint64(len(content)),
+		UncompressedSize64: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte(content))
+	zw.Close()
+	zr, err = zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.ExtractZip(zr, "imported", ConflictOverwrite, 0); err == nil {
+		t.Fatal("Expected the oversized entry to fail")
+	}
+	if content, _ := fs.ReadFile("imported/a.md"); content != "new" {
+		t.Errorf("Failed overwrite changed the note to %q", content)
+	}
+	entries, _ := os.ReadDir(filepath.Join(tmpDir, "imported"))
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), ".tmp") {
+			t.Errorf("Left %s behind", e.Name())
+		}
+	}
+}
+
+func TestExtractZipRejectsUnsafeEntries(t *testing.T) {
+	tmpDir := t.TempDir()
+	fs := New(filepath.Join(tmpDir, "vault"))
+	os.MkdirAll(fs.RootDir, 0755)
+
+	for _, name := range []string{"../evil.md", "ok/../../evil.md", "/etc/evil.md", "..\\evil.md"} {
+		zr := buildZip(t, map[string]string{"good.md": "fine", name: "evil"})
+		if _, err := fs.ExtractZip(zr, "", ConflictRename, 0); err == nil {
+			t.Errorf("ExtractZip accepted entry %q", name)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(tmpDir, "evil.md")); !os.IsNotExist(err) {
+		t.Error("Entry escaped the vault")
+	}
+	if fs.FileExists("good.md") {
+		t.Error("Rejected archive was partially extracted")
+	}
+
+	zr := buildZip(t, map[string]string{"big.md": strings.Repeat("x", 100)})
+	if _, err := fs.ExtractZip(zr, "", ConflictRename, 10); err != ErrImportTooLarge {
+		t.Errorf("Expected ErrImportTooLarge, got %v", err)
+	}
+}
+
+// xorCipher stands in for the vault key: it marks sealed data and flips
+// every byte so plaintext can't be found on disk
+type xorCipher struct{}
+
+const xorMagic = "sealed\x00"
+
+func (xorCipher) Seal(plaintext []byte) ([]byte, error) {
+	out := []byte(xorMagic)
+	for _, b := range plaintext {
+		out = append(out, b^0x5a)
+	}
+	return out, nil
+}
+
+func (xorCipher) Open(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, []byte(xorMagic)) {
+		return data, nil
+	}
+	var out []byte
+	for _, b := range data[len(xorMagic):] {
+		out = append(out, b^0x5a)
+	}
+	return out, nil
+}
+
+func TestCipher(t *testing.T) {
+	tmpDir := t.TempDir()
+	for path, content := range map[string]string{
+		"diary.md":       "# Diary\n\nDear diary",
+		"assets/img.png": "png pixels",
+		".gitignore":     "*.tmp\n",
+	} {
+		full := filepath.Join(tmpDir, path)
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fs := New(tmpDir)
+	fs.Cipher = xorCipher{}
+	onDisk := func(path string) string {
+		data, err := os.ReadFile(filepath.Join(tmpDir, path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	n, err := fs.SealAll()
+	if err != nil {
+		t.Fatalf("SealAll failed: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("SealAll sealed %d files, want 2", n)
+	}
+	if n, _ := fs.SealAll(); n != 0 {
+		t.Errorf("second SealAll sealed %d files, want 0", n)
+	}
+	if strings.Contains(onDisk("diary.md"), "Dear") || strings.Contains(onDisk("assets/img.png"), "pixels") {
+		t.Error("Expected files to be sealed on disk")
+	}
+	if onDisk(".gitignore") != "*.tmp\n" {
+		t.Error("Expected hidden files to be left alone")
+	}
+
+	if content, err := fs.ReadFile("diary.md"); err != nil || content != "# Diary\n\nDear diary" {
+		t.Errorf("ReadFile = %q, %v", content, err)
+	}
+	if err := fs.WriteFile("new.md", "# New\n\nFresh"); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(onDisk("new.md"), "Fresh") {
+		t.Error("Expected WriteFile to seal the note")
+	}
+
+	imgPath, err := fs.SaveImage([]byte("more pixels"), ".png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(onDisk(imgPath), "pixels") {
+		t.Error("Expected SaveImage to seal the image")
+	}
+	file, _, err := fs.OpenImage(filepath.Base(imgPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(file)
+	file.Close()
+	if string(data) != "more pixels" {
+		t.Errorf("OpenImage read %q, want the decrypted image", data)
+	}
+
+	tree, err := fs.GetTreeWithMetadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, child := range tree.Children {
+		if child.Path == "diary.md" && child.Title != "Diary" {
+			t.Errorf("Title = %q, want %q", child.Title, "Diary")
+		}
+	}
+
+	// Archives hold plaintext; imported files are sealed
+	var buf bytes.Buffer
+	if err := fs.WriteZip(&buf, ""); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range zr.File {
+		if strings.HasSuffix(f.Name, "/diary.md") {
+			rc, _ := f.Open()
+			data, _ := io.ReadAll(rc)
+			rc.Close()
+			if !strings.Contains(string(data), "Dear diary") {
+				t.Errorf("Zip entry %s = %q, want plaintext", f.Name, data)
+			}
+		}
+	}
+
+	if _, err := fs.ExtractZip(buildZip(t, map[string]string{"imported.md": "# Imported secret"}), "", ConflictRename, 0); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(onDisk("imported.md"), "secret") {
+		t.Error("Expected imported files to be sealed")
+	}
+	if content, _ := fs.ReadFile("imported.md"); content != "# Imported secret" {
+		t.Errorf("ReadFile(imported.md) = %q", content)
+	}
+}
+
+func TestSyncConflicts(t *testing.T) {
+	tmpDir := t.TempDir()
+	fs := New(tmpDir)
+
+	files := map[string]string{
+		"plan.md": "# Plan\n\nShared\nOurs\nEnd\n",
+		"plan (Alice's conflicted copy 2024-05-01).md":       "# Plan\n\nShared\nTheirs\nEnd\nExtra\n",
+		"notes/log.sync-conflict-20240501-150423-ABCDEFG.md": "# Log\n",
+		"notes/todo (1).md": "# Todo\n",
+		"notes/todo.md":     "# Todo\n",
+		"Chapter (2).md":    "# Chapter 2\n",
+	}
+	for name, content := range files {
+		if err := fs.CreateFile(name, content); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	conflicts, err := fs.SyncConflicts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]SyncConflict)
+	for _, c := range conflicts {
+		got[c.Path] = c
+	}
+	if len(got) != 3 {
+		t.Fatalf("Expected 3 conflicts, got %+v", conflicts)
+	}
+	if c := got["plan (Alice's conflicted copy 2024-05-01).md"]; c.Original != "plan.md" || c.Source != "dropbox" || !c.OriginalExists {
+		t.Errorf("Unexpected Dropbox conflict: %+v", c)
+	}
+	if c := got[filepath.Join("notes", "log.sync-conflict-20240501-150423-ABCDEFG.md")]; c.Original != filepath.Join("notes", "log.md") || c.Source != "syncthing" || c.OriginalExists {
+		t.Errorf("Unexpected Syncthing conflict: %+v", c)
+	}
+	if c := got[filepath.Join("notes", "todo (1).md")]; c.Source != "drive" {
+		t.Errorf("Unexpected Drive conflict: %+v", c)
+	}
+
+	tree, err := fs.GetTree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, node := range tree.Children {
+		if strings.HasPrefix(node.Name, "plan (") && node.ConflictOf != "plan.md" {
+			t.Errorf("Expected the copy marked in the tree, got %+v", node)
+		}
+		if node.Name == "Chapter (2).md" && node.ConflictOf != "" {
+			t.Errorf("Expected a numbered name without its original left alone, got %+v", node)
+		}
+	}
+
+	ours, _ := fs.ReadFile("plan.md")
+	theirs, _ := fs.ReadFile("plan (Alice's conflicted copy 2024-05-01).md")
+	merged := MergeLines(ours, theirs, "plan.md", "plan (Alice's conflicted copy 2024-05-01).md")
+	want := "# Plan\n\nShared\n<<<<<<< plan.md\nOurs\n=======\nTheirs\n>>>>>>> plan (Alice's conflicted copy 2024-05-01).md\nEnd\nExtra\n"
+	if merged != want {
+		t.Errorf("MergeLines() =\n%s\nwant\n%s", merged, want)
+	}
+	if got := MergeLines("a\nb", "a\nc", "x", "y"); got != "a\n<<<<<<< x\nb\n=======\nc\n>>>>>>> y" {
+		t.Errorf("Expected no newline added at the end, got %q", got)
+	}
+
+	original, err := fs.ResolveSyncConflict("plan (Alice's conflicted copy 2024-05-01).md", KeepOriginal)
+	if err != nil || original != "plan.md" {
+		t.Fatalf("ResolveSyncConflict() = %q, %v", original, err)
+	}
+	if content, _ := fs.ReadFile("plan.md"); content != files["plan.md"] {
+		t.Errorf("Expected the original kept, got %q", content)
+	}
+	if fs.FileExists("plan (Alice's conflicted copy 2024-05-01).md") {
+		t.Error("Expected the copy deleted")
+	}
+
+	if _, err := fs.ResolveSyncConflict(filepath.Join("notes", "todo (1).md"), KeepCopy); err != nil {
+		t.Fatal(err)
+	}
+	if fs.FileExists(filepath.Join("notes", "todo (1).md")) || !fs.FileExists(filepath.Join("notes", "todo.md")) {
+		t.Error("Expected the copy moved over the original")
+	}
+
+	if _, err := fs.ResolveSyncConflict("Chapter (2).md", KeepOriginal); err != ErrNotConflict {
+		t.Errorf("Expected ErrNotConflict for an ordinary file, got %v", err)
+	}
+}
+
+func TestGuard(t *testing.T) {
+	tmpDir := t.TempDir()
+	fs := New(tmpDir)
+	for name, content := range map[string]string{
+		"public.md":           "# Public\n",
+		"team/plan.md":        "# Plan\n",
+		"team/hr/salaries.md": "# Salaries\n",
+		"archive/old.md":      "# Old\n",
+	} {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(tmpDir, name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// team/ is read-only, team/hr hidden, archive/ hidden except old.md
+	view := fs.WithGuard(func(p string, write bool) bool {
+		switch {
+		case p == "team/hr" || strings.HasPrefix(p, "team/hr/"):
+			return false
+		case p == "team" || strings.HasPrefix(p, "team/"):
+			return !write
+		case p == "archive":
+			return false
+		}
+		return true
+	})
+
+	if _, err := view.ReadFile("team/plan.md"); err != nil {
+		t.Errorf("Expected a read-only note readable, got %v", err)
+	}
+	if err := view.WriteFile("team/plan.md", "changed"); !errors.Is(err, ErrAccessDenied) {
+		t.Errorf("Expected ErrAccessDenied writing a read-only note, got %v", err)
+	}
+	if _, err := view.ReadFile("team/hr/salaries.md"); !errors.Is(err, ErrAccessDenied) {
+		t.Errorf("Expected ErrAccessDenied reading a hidden note, got %v", err)
+	}
+	if err := view.RenameFile("public.md", "team/public.md"); !errors.Is(err, ErrAccessDenied) {
+		t.Errorf("Expected ErrAccessDenied moving into a read-only folder, got %v", err)
+	}
+	if err := view.WriteFile("public.md", "# Changed\n"); err != nil {
+		t.Errorf("Expected an unrestricted note writable, got %v", err)
+	}
+	if _, err := fs.ReadFile("team/hr/salaries.md"); err != nil {
+		t.Errorf("Expected the unguarded file system unaffected, got %v", err)
+	}
+
+	notes, err := view.ListNotes("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(notes)
+	want := []string{"archive/old.md", "public.md", "team/plan.md"}
+	if strings.Join(notes, ",") != strings.Join(want, ",") {
+		t.Errorf("ListNotes() = %v, want %v", notes, want)
+	}
+
+	tree, err := view.GetTree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	var walk func(n *FileNode)
+	walk = func(n *FileNode) {
+		for _, c := range n.Children {
+			paths = append(paths, filepath.ToSlash(c.Path))
+			walk(c)
+		}
+	}
+	walk(tree)
+	sort.Strings(paths)
+	want = []string{"archive", "archive/old.md", "public.md", "team", "team/plan.md"}
+	if strings.Join(paths, ",") != strings.Join(want, ",") {
+		t.Errorf("Tree paths = %v, want %v", paths, want)
+	}
+}
+
+func TestDirStats(t *testing.T) {
+	tmpDir := t.TempDir()
+	fs := New(tmpDir)
+	for name, content := range map[string]string{
+		"top.md":                "# Top\n",
+		"projects/plan.md":      "# Plan\n",
+		"projects/diagram.png":  "png",
+		"projects/old/notes.md": "# Notes\n",
+		"projects/.cache/x.md":  "# Hidden\n",
+		"projects/secret/a.md":  "# Secret\n",
+	} {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(tmpDir, name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	latest := time.Now().Add(time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(filepath.Join(tmpDir, "projects/old/notes.md"), latest, latest); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := fs.DirStats("projects")
+	if err != nil {
+		t.Fatalf("DirStats failed: %v", err)
+	}
+	if stats.Files != 4 || stats.Notes != 3 || stats.Folders != 2 {
+		t.Errorf("Expected 4 files, 3 notes and 2 folders, got %+v", stats)
+	}
+	if want := int64(len("# Plan\n") + len("png") + len("# Notes\n") + len("# Secret\n")); stats.Size != want {
+		t.Errorf("Expected %d bytes, got %d", want, stats.Size)
+	}
+	if !stats.LatestModified.Equal(latest) {
+		t.Errorf("Expected latest modification %v, got %v", latest, stats.LatestModified)
+	}
+
+	view := fs.WithGuard(func(p string, write bool) bool {
+		return p != "projects/secret" && !strings.HasPrefix(p, "projects/secret/")
+	})
+	stats, err = view.DirStats("projects")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Files != 3 || stats.Folders != 1 {
+		t.Errorf("Expected the hidden folder left out, got %+v", stats)
+	}
+
+	if _, err := fs.DirStats("missing"); err == nil {
+		t.Error("Expected an error for a missing folder")
+	}
+}
+
+func TestMigrateAssets(t *testing.T) {
+	tmpDir := t.TempDir()
+	fs := New(tmpDir)
+	plan := "![a](/images/a.png) and ![b](../assets/b.png?raw=1)\n"
+	journal := "![shared](/images/shared.png) and [c](assets/c.png), `![code](/images/a.png)`\n"
+	files := map[string]string{
+		"notes/plan.md":     plan,
+		"notes/other.md":    "![shared](/images/shared.png)\n",
+		"journal.md":        journal,
+		"assets/a.png":      "a",
+		"assets/b.png":      "b",
+		"assets/c.png":      "c",
+		"assets/shared.png": "shared",
+		"assets/unused.png": "unused",
+	}
+	for name, content := range files {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(tmpDir, name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := fs.MigrateAssets("nested", false); err == nil {
+		t.Error("Expected an unknown layout to fail")
+	}
+
+	dry, err := fs.MigrateAssets(AssetLayoutPerNote, true)
+	if err != nil {
+		t.Fatalf("MigrateAssets failed: %v", err)
+	}
+	if len(dry.Moved) != 3 || !fs.FileExists("assets/a.png") {
+		t.Errorf("Expected a dry run to plan 3 moves and make none, got %+v", dry.Moved)
+	}
+
+	migration, err := fs.MigrateAssets(AssetLayoutPerNote, false)
+	if err != nil {
+		t.Fatalf("MigrateAssets failed: %v", err)
+	}
+	want := []AssetMove{
+		{From: "assets/a.png", To: "assets/notes/plan/a.png"},
+		{From: "assets/b.png", To: "assets/notes/plan/b.png"},
+		{From: "assets/c.png", To: "assets/journal/c.png"},
+	}
+	if !reflect.DeepEqual(migration.Moved, want) {
+		t.Errorf("Moved = %+v, want %+v", migration.Moved, want)
+	}
+	if !reflect.DeepEqual(migration.Shared, []string{"assets/shared.png"}) {
+		t.Errorf("Shared = %v", migration.Shared)
+	}
+	if len(migration.Changed) != 8 {
+		t.Errorf("Expected 6 moved paths and 2 notes changed, got %v", migration.Changed)
+	}
+
+	content, _ := fs.ReadFile("notes/plan.md")
+	if content != "![a](/images/notes/plan/a.png) and ![b](../assets/notes/plan/b.png?raw=1)\n" {
+		t.Errorf("Unexpected plan.md:\n%s", content)
+	}
+	content, _ = fs.ReadFile("journal.md")
+	if content != "![shared](/images/shared.png) and [c](assets/journal/c.png), `![code](/images/a.png)`\n" {
+		t.Errorf("Unexpected journal.md:\n%s", content)
+	}
+	if !fs.FileExists("assets/unused.png") || !fs.FileExists("assets/notes/plan/a.png") {
+		t.Error("Expected unused assets left alone and used ones moved")
+	}
+
+	// Going back to flat restores the notes, around a new file in the way
+	os.WriteFile(filepath.Join(tmpDir, "assets/a.png"), []byte("new"), 0644)
+	if _, err := fs.MigrateAssets(AssetLayoutFlat, false); err != nil {
+		t.Fatalf("MigrateAssets failed: %v", err)
+	}
+	content, _ = fs.ReadFile("notes/plan.md")
+	if content != "![a](/images/a-1.png) and ![b](../assets/b.png?raw=1)\n" {
+		t.Errorf("Unexpected plan.md:\n%s", content)
+	}
+	if content, _ = fs.ReadFile("journal.md"); content != journal {
+		t.Errorf("Unexpected journal.md:\n%s", content)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "assets/notes")); !os.IsNotExist(err) {
+		t.Errorf("Expected emptied per-note folders removed, got %v", err)
+	}
+}
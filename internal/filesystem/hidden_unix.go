@@ -0,0 +1,9 @@
+//go:build !windows
+
+package filesystem
+
+// hiddenAttribute reports whether the file system marks a file hidden.
+// Outside Windows only the leading dot counts.
+func hiddenAttribute(path string) bool {
+	return false
+}
@@ -0,0 +1,33 @@
+//go:build windows
+
+package filesystem
+
+import (
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/windows"
+)
+
+// hiddenAttribute reports whether Windows marks a file hidden or system,
+// as it does desktop.ini, Thumbs.db and folders like $RECYCLE.BIN
+func hiddenAttribute(path string) bool {
+	p, err := windows.UTF16PtrFromString(longPath(path))
+	if err != nil {
+		return false
+	}
+	attrs, err := windows.GetFileAttributes(p)
+	if err != nil {
+		return false
+	}
+	return attrs&(windows.FILE_ATTRIBUTE_HIDDEN|windows.FILE_ATTRIBUTE_SYSTEM) != 0
+}
+
+// longPath prefixes an absolute path with \\?\ so Windows API calls made
+// outside the os package, which does this itself, work past MAX_PATH
+func longPath(path string) string {
+	if len(path) < 248 || !filepath.IsAbs(path) || strings.HasPrefix(path, `\\`) {
+		return path
+	}
+	return `\\?\` + filepath.Clean(path)
+}
@@ -0,0 +1,45 @@
+//go:build windows
+
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/sys/windows"
+)
+
+func TestHiddenAttribute(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"desktop.ini", "notes.md"} {
+		os.WriteFile(filepath.Join(dir, name), []byte("x"), 0644)
+	}
+	p, _ := windows.UTF16PtrFromString(filepath.Join(dir, "desktop.ini"))
+	if err := windows.SetFileAttributes(p, windows.FILE_ATTRIBUTE_HIDDEN); err != nil {
+		t.Fatal(err)
+	}
+
+	if !IsHidden(filepath.Join(dir, "desktop.ini")) {
+		t.Error("Expected a file marked hidden to be hidden")
+	}
+	if IsHidden(filepath.Join(dir, "notes.md")) {
+		t.Error("Expected notes.md not to be hidden")
+	}
+	tree, err := New(dir).GetTree()
+	if err != nil || len(tree.Children) != 1 || tree.Children[0].Name != "notes.md" {
+		t.Errorf("Expected only notes.md in the tree, got %+v, %v", tree, err)
+	}
+}
+
+func TestLongPath(t *testing.T) {
+	short := `C:\notes\plan.md`
+	if got := longPath(short); got != short {
+		t.Errorf("longPath(%q) = %q", short, got)
+	}
+	long := `C:\` + strings.Repeat(`folder\`, 40) + "plan.md"
+	if got := longPath(long); got != `\\?\`+long {
+		t.Errorf("longPath(long) = %q", got)
+	}
+}
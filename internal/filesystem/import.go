@@ -0,0 +1,233 @@
+package filesystem
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ConflictStrategy decides what happens when an imported file already exists
+type ConflictStrategy string
+
+const (
+	ConflictRename    ConflictStrategy = "rename"    // Keep both, adding a numeric suffix
+	ConflictSkip      ConflictStrategy = "skip"      // Keep the existing file
+	ConflictOverwrite ConflictStrategy = "overwrite" // Replace the existing file
+)
+
+// ErrImportTooLarge is returned when an archive expands past the size limit
+var ErrImportTooLarge = errors.New("archive contents exceed the size limit")
+
+// ParseConflictStrategy validates a strategy name, defaulting to rename
+func ParseConflictStrategy(name string) (ConflictStrategy, error) {
+	switch s := ConflictStrategy(name); s {
+	case "":
+		return ConflictRename, nil
+	case ConflictRename, ConflictSkip, ConflictOverwrite:
+		return s, nil
+	default:
+		return "", fmt.Errorf("unknown conflict strategy: %s", name)
+	}
+}
+
+// ImportResult lists what an archive import did
+type ImportResult struct {
+	Created []string `json:"created"`
+	Skipped []string `json:"skipped"`
+}
+
+// ExtractZip extracts an archive into a directory of the vault. Every entry
+// name is checked before anything is written, so an archive that tries to
+// escape the target is rejected as a whole. Hidden entries (such as
+// __MACOSX or .DS_Store files) and symlinks are ignored. maxSize caps the
+// total uncompressed size; zero means no limit.
+func (fs *FileSystem) ExtractZip(zr *zip.Reader, targetDir string, onConflict ConflictStrategy, maxSize int64) (*ImportResult, error) {
+	if err := fs.checkPath(targetDir, true); err != nil {
+		return nil, err
+	}
+	defer fs.changedDir(targetDir)
+
+	type entry struct {
+		file *zip.File
+		path string
+	}
+
+	var entries []entry
+	var total uint64
+	for _, f := range zr.File {
+		name, err := zipEntryPath(f.Name)
+		if err != nil {
+			return nil, err
+		}
+		if name == "" || f.FileInfo().IsDir() || !f.Mode().IsRegular() || isHiddenPath(name) {
+			continue
+		}
+
+		total += f.UncompressedSize64
+		if maxSize > 0 && total > uint64(maxSize) {
+			return nil, ErrImportTooLarge
+		}
+
+		relativePath := filepath.Join(targetDir, filepath.FromSlash(name))
+		if err := fs.checkPath(relativePath, true); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry{file: f, path: relativePath})
+	}
+
+	result := &ImportResult{Created: []string{}, Skipped: []string{}}
+	var written int64
+	for _, e := range entries {
+		limit := int64(-1)
+		if maxSize > 0 {
+			limit = maxSize - written
+		}
+
+		saved, n, err := fs.extractZipFile(e.file, e.path, onConflict, limit)
+		written += n
+		if err != nil {
+			return result, fmt.Errorf("failed to extract %s: %w", e.file.Name, err)
+		}
+		if saved == "" {
+			result.Skipped = append(result.Skipped, e.path)
+		} else {
+			result.Created = append(result.Created, saved)
+		}
+	}
+
+	return result, nil
+}
+
+// extractZipFile writes one archive entry, returning the path it was saved
+// at (empty when skipped) and the number of bytes written. limit is the
+// remaining size budget, or negative for none. The entry is written to a
+// temporary file first, so an entry that fails part way leaves the file it
+// would replace untouched.
+func (fs *FileSystem) extractZipFile(f *zip.File, relativePath string, onConflict ConflictStrategy, limit int64) (string, int64, error) {
+	fullPath := filepath.Join(fs.RootDir, relativePath)
+	if onConflict == ConflictSkip {
+		if _, err := os.Lstat(fullPath); err == nil {
+			return "", 0, nil
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return "", 0, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	// Hidden, so the tree never shows it
+	tmp, err := os.CreateTemp(filepath.Dir(fullPath), "."+filepath.Base(fullPath)+".*.tmp")
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	n, err := fs.writeZipEntry(tmp, f, limit)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err != nil {
+		return "", n, err
+	}
+
+	if onConflict == ConflictOverwrite {
+		if err := os.Rename(tmp.Name(), fullPath); err != nil {
+			return "", n, err
+		}
+		return relativePath, n, nil
+	}
+
+	// Linking never replaces a file, even one created since the check above
+	ext := filepath.Ext(relativePath)
+	base := strings.TrimSuffix(relativePath, ext)
+	for i := 1; ; i++ {
+		err := os.Link(tmp.Name(), filepath.Join(fs.RootDir, relativePath))
+		if os.IsExist(err) {
+			if onConflict == ConflictSkip {
+				return "", 0, nil
+			}
+			relativePath = fmt.Sprintf("%s-%d%s", base, i, ext)
+			continue
+		}
+		if err != nil {
+			return "", n, err
+		}
+		return relativePath, n, nil
+	}
+}
+
+// writeZipEntry copies an archive entry to out, failing with
+// ErrImportTooLarge once it goes past limit
+func (fs *FileSystem) writeZipEntry(out io.Writer, f *zip.File, limit int64) (int64, error) {
+	in, err := f.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+
+	// The declared size can't be trusted, so the copy is capped as well
+	var src io.Reader = in
+	if limit >= 0 {
+		src = io.LimitReader(in, limit+1)
+	}
+	n, err := fs.copySealed(out, src)
+	if err == nil && limit >= 0 && n > limit {
+		err = ErrImportTooLarge
+	}
+	return n, err
+}
+
+// copySealed copies src to out, sealing it when the vault is encrypted, and
+// returns the number of plaintext bytes copied
+func (fs *FileSystem) copySealed(out io.Writer, src io.Reader) (int64, error) {
+	if fs.Cipher == nil {
+		return io.Copy(out, src)
+	}
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return int64(len(data)), err
+	}
+	sealed, err := fs.seal(data)
+	if err != nil {
+		return int64(len(data)), err
+	}
+	_, err = out.Write(sealed)
+	return int64(len(data)), err
+}
+
+// zipEntryPath normalizes an archive entry name, rejecting names that are
+// absolute or climb out of the archive root
+func zipEntryPath(name string) (string, error) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if strings.HasPrefix(name, "/") || filepath.VolumeName(name) != "" {
+		return "", fmt.Errorf("invalid archive entry %q: absolute paths not allowed", name)
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return "", fmt.Errorf("invalid archive entry %q: path traversal not allowed", name)
+		}
+	}
+
+	name = path.Clean(name)
+	if name == "." {
+		return "", nil
+	}
+	return name, nil
+}
+
+// isHiddenPath reports whether any component of a slash path is hidden
+func isHiddenPath(name string) bool {
+	for _, part := range strings.Split(name, "/") {
+		if strings.HasPrefix(part, ".") || part == "__MACOSX" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+package filesystem
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// landingPageNames are the notes that serve as a folder's landing page,
+// matched regardless of case, the first found winning
+var landingPageNames = []string{"index.md", "readme.md", "index.markdown", "readme.markdown"}
+
+// ErrNoLandingPage is returned for folders without an index or README note
+var ErrNoLandingPage = errors.New("folder has no landing page")
+
+// landingPage returns the path of the landing page among a folder's
+// entries, or "" if there is none
+func landingPage(children []*FileNode) string {
+	for _, name := range landingPageNames {
+		for _, child := range children {
+			if !child.IsDir && strings.EqualFold(child.Name, name) {
+				return child.Path
+			}
+		}
+	}
+	return ""
+}
+
+// LandingPage returns the path of a folder's landing page: its index.md or,
+// failing that, its README.md, of those the guard lets be read. An empty
+// dir is the vault's root.
+func (fs *FileSystem) LandingPage(dir string) (string, error) {
+	if err := fs.validatePath(dir); err != nil {
+		return "", err
+	}
+
+	entries, err := os.ReadDir(filepath.Join(fs.RootDir, dir))
+	if err != nil {
+		return "", fmt.Errorf("failed to read folder: %w", err)
+	}
+	var children []*FileNode
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if !entry.IsDir() && isNoteFile(entry.Name(), fs.Extensions) && fs.checkPath(path, false) == nil {
+			children = append(children, &FileNode{Name: entry.Name(), Path: path})
+		}
+	}
+
+	path := landingPage(children)
+	if path == "" {
+		return "", fmt.Errorf("%s: %w", filepath.ToSlash(dir), ErrNoLandingPage)
+	}
+	return path, nil
+}
@@ -0,0 +1,95 @@
+package filesystem
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// caseInsensitive is whether the platform's file systems ignore case by
+// default, so Notes/a.md and notes/A.md are the same file
+var caseInsensitive = runtime.GOOS == "windows" || runtime.GOOS == "darwin"
+
+// windowsNames is whether names Windows can't create are refused
+var windowsNames = runtime.GOOS == "windows"
+
+// reservedNames are device names Windows won't use as a file name, with or
+// without an extension
+var reservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// IsHidden reports whether a file or folder is hidden: named with a
+// leading dot, or on Windows, marked hidden or system
+func IsHidden(path string) bool {
+	return strings.HasPrefix(filepath.Base(path), ".") || hiddenAttribute(path)
+}
+
+// SamePath reports whether two cleaned paths name the same file, ignoring
+// case where the platform does
+func SamePath(a, b string) bool {
+	if caseInsensitive {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
+
+// InDir reports whether the cleaned path p is dir or inside it, ignoring
+// case where the platform does. Either separator may be used, as long as
+// both paths use the same one.
+func InDir(p, dir string) bool {
+	if dir == "" || dir == "." || SamePath(p, dir) {
+		return true
+	}
+	if len(p) <= len(dir) || !SamePath(p[:len(dir)], dir) {
+		return false
+	}
+	sep := p[len(dir)]
+	return sep == '/' || sep == filepath.Separator || strings.HasSuffix(dir, "/") || strings.HasSuffix(dir, string(filepath.Separator))
+}
+
+// checkName refuses path elements Windows can't create: device names,
+// reserved characters and names ending in a dot or space
+func checkName(relativePath string) error {
+	if !windowsNames {
+		return nil
+	}
+	for _, name := range strings.FieldsFunc(relativePath, func(r rune) bool { return r == '/' || r == '\\' }) {
+		if name == "." || name == ".." {
+			continue
+		}
+		base, _, _ := strings.Cut(name, ".")
+		if reservedNames[strings.ToUpper(strings.TrimRight(base, " "))] {
+			return fmt.Errorf("invalid path: %s is a reserved name on Windows", name)
+		}
+		if i := strings.IndexAny(name, `<>:"|?*`); i >= 0 {
+			return fmt.Errorf("invalid path: %q is not allowed in names on Windows", name[i])
+		}
+		for _, r := range name {
+			if r < 0x20 {
+				return fmt.Errorf("invalid path: control characters are not allowed in names on Windows")
+			}
+		}
+		if strings.HasSuffix(name, ".") || strings.HasSuffix(name, " ") {
+			return fmt.Errorf("invalid path: %s ends in a dot or space, which Windows drops", name)
+		}
+	}
+	return nil
+}
+
+// ExpandHome replaces a leading ~ with the home directory. Other users'
+// homes (~alice) aren't looked up and are returned as they are.
+func ExpandHome(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") && !strings.HasPrefix(path, "~"+string(filepath.Separator)) {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, path[1:]), nil
+}
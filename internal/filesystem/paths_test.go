@@ -0,0 +1,183 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// windowsLike makes path checks behave as they do on Windows for a test
+func windowsLike(t *testing.T) {
+	t.Helper()
+	oldCase, oldNames := caseInsensitive, windowsNames
+	caseInsensitive, windowsNames = true, true
+	t.Cleanup(func() { caseInsensitive, windowsNames = oldCase, oldNames })
+}
+
+func TestInDir(t *testing.T) {
+	tests := []struct {
+		p, dir     string
+		want       bool
+		wantFolded bool // With case ignored
+	}{
+		{"notes/a.md", "notes", true, true},
+		{"notes", "notes", true, true},
+		{"notes2/a.md", "notes", false, false},
+		{"Notes/a.md", "notes", false, true},
+		{"PRIVATE", "private", false, true},
+		{`private\plans.md`, "private", filepath.Separator == '\\', filepath.Separator == '\\'},
+		{"anything", ".", true, true},
+		{"anything", "", true, true},
+		{"a", "ab", false, false},
+	}
+	for _, tt := range tests {
+		old := caseInsensitive
+		caseInsensitive = false
+		got := InDir(tt.p, tt.dir)
+		caseInsensitive = true
+		folded := InDir(tt.p, tt.dir)
+		caseInsensitive = old
+		if got != tt.want || folded != tt.wantFolded {
+			t.Errorf("InDir(%q, %q) = %v, folded %v; want %v, %v", tt.p, tt.dir, got, folded, tt.want, tt.wantFolded)
+		}
+	}
+}
+
+func TestValidatePathWindows(t *testing.T) {
+	windowsLike(t)
+	fs := New(t.TempDir())
+
+	tests := []struct {
+		path    string
+		wantErr bool
+	}{
+		{"notes/plan.md", false},
+		{"Notes/.hidden/plan.md", false},
+		{"con.md", true},
+		{"notes/AUX", true},
+		{"lpt1.txt", true},
+		{"console.md", false},
+		{"what?.md", true},
+		{"a:b.md", true},
+		{"trailing./plan.md", true},
+		{"notes/plan.md ", true},
+		{"tab\t.md", true},
+	}
+	for _, tt := range tests {
+		if err := fs.validatePath(tt.path); (err != nil) != tt.wantErr {
+			t.Errorf("validatePath(%q) error = %v, wantErr %v", tt.path, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidatePathAllowsNamesOutsideWindows(t *testing.T) {
+	old := windowsNames
+	windowsNames = false
+	defer func() { windowsNames = old }()
+
+	if err := New(t.TempDir()).validatePath("what?.md"); err != nil {
+		t.Errorf("Expected names Windows refuses allowed elsewhere, got %v", err)
+	}
+}
+
+func TestCaseInsensitiveAssets(t *testing.T) {
+	windowsLike(t)
+	dir := t.TempDir()
+	for _, name := range []string{"Assets/img.md", "Media/pic.md", "notes/a.md"} {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(p), 0755)
+		os.WriteFile(p, []byte("# x"), 0644)
+	}
+
+	fs := New(dir)
+	fs.AssetsDir = "media"
+	tree, err := fs.GetTree()
+	if err != nil {
+		t.Fatalf("GetTree failed: %v", err)
+	}
+	if len(tree.Children) != 1 || tree.Children[0].Name != "notes" {
+		t.Errorf("Expected asset folders left out whatever their case, got %+v", tree.Children)
+	}
+}
+
+func TestIsHidden(t *testing.T) {
+	dir := t.TempDir()
+	for name, want := range map[string]bool{".git": true, ".inkwell": true, "notes.md": false, "a.b": false} {
+		if got := IsHidden(filepath.Join(dir, filepath.FromSlash(name))); got != want {
+			t.Errorf("IsHidden(%s) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestExpandHome(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	tests := map[string]string{
+		"~":                                    home,
+		"~/notes":                              filepath.Join(home, "notes"),
+		"~" + string(filepath.Separator) + "x": filepath.Join(home, "x"),
+		"~alice/notes":                         "~alice/notes",
+		"notes/~":                              "notes/~",
+	}
+	for in, want := range tests {
+		got, err := ExpandHome(in)
+		if err != nil || got != want {
+			t.Errorf("ExpandHome(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+}
+
+func TestLongPaths(t *testing.T) {
+	fs := New(t.TempDir())
+
+	// Deeper than Windows' 260 character MAX_PATH
+	var parts []string
+	for i := 0; i < 12; i++ {
+		parts = append(parts, strings.Repeat(string(rune('a'+i)), 24))
+	}
+	p := filepath.Join(append(parts, "plan.md")...)
+	if err := fs.CreateFile(p, "# Plan"); err != nil {
+		t.Fatalf("CreateFile failed: %v", err)
+	}
+	if content, err := fs.ReadFile(p); err != nil || content != "# Plan" {
+		t.Errorf("ReadFile = %q, %v", content, err)
+	}
+	notes, err := fs.ListNotes(".")
+	if err != nil || len(notes) != 1 {
+		t.Errorf("ListNotes = %v, %v", notes, err)
+	}
+}
+
+func TestWatcherSkipsHidden(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, ".trash"), 0755)
+
+	w, err := NewWatcher(dir)
+	if err != nil {
+		t.Fatalf("NewWatcher failed: %v", err)
+	}
+	defer w.Close()
+	events := w.Subscribe()
+	defer w.Unsubscribe(events)
+
+	os.WriteFile(filepath.Join(dir, ".draft.md"), []byte("x"), 0644)
+	os.WriteFile(filepath.Join(dir, ".trash", "old.md"), []byte("x"), 0644)
+	os.WriteFile(filepath.Join(dir, "plan.md"), []byte("x"), 0644)
+
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case event := <-events:
+			if event.Path != "plan.md" {
+				t.Fatalf("Expected no events for hidden files, got %+v", event)
+			}
+			return
+		case <-timeout:
+			t.Fatal("No event for plan.md")
+		}
+	}
+}
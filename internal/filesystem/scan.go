@@ -0,0 +1,195 @@
+package filesystem
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// treeWorkers is how many folders and notes may be read at once when
+// building a tree
+var treeWorkers = 4 * runtime.NumCPU()
+
+// titleReadLimit is how much of a note is read looking for its title
+const titleReadLimit = 4096
+
+// treeScanner walks a folder, reading subfolders and notes in parallel
+type treeScanner struct {
+	rootDir string
+	opts    treeOptions
+	slots   chan struct{} // One for each goroutine reading besides the caller
+}
+
+func buildTreeRecursive(rootDir, currentDir, relativePath string, opts treeOptions) (*FileNode, error) {
+	workers := opts.workers
+	if workers <= 0 {
+		workers = treeWorkers
+	}
+	s := &treeScanner{
+		rootDir: rootDir,
+		opts:    opts,
+		slots:   make(chan struct{}, workers-1),
+	}
+	return s.scan(currentDir, relativePath)
+}
+
+// scan reads a folder and everything below it
+func (s *treeScanner) scan(currentDir, relativePath string) (*FileNode, error) {
+	entries, err := os.ReadDir(currentDir)
+	if err != nil {
+		return nil, err
+	}
+
+	name := filepath.Base(currentDir)
+	if relativePath == "" {
+		name = filepath.Base(s.rootDir)
+	}
+
+	node := &FileNode{
+		Name:  name,
+		Path:  relativePath,
+		IsDir: true,
+	}
+
+	// Each entry fills its own slot, so no locking is needed
+	found := make([]*FileNode, len(entries))
+	var wg sync.WaitGroup
+
+	names := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		names[entry.Name()] = true
+	}
+	exists := func(name string) bool { return names[name] }
+
+	for i, entry := range entries {
+		entryName := entry.Name()
+
+		entryPath := filepath.Join(currentDir, entryName)
+		entryRelPath := filepath.Join(relativePath, entryName)
+
+		// Skip hidden files and directories
+		if IsHidden(entryPath) {
+			continue
+		}
+
+		// Skip assets directory (where images are stored)
+		if entry.IsDir() && (SamePath(entryName, "assets") || SamePath(entryRelPath, s.opts.assetsDir)) {
+			continue
+		}
+
+		if isIgnored(entryName, s.opts.ignore) {
+			continue
+		}
+
+		if entry.IsDir() {
+			s.run(&wg, func() {
+				childNode, err := s.scan(entryPath, entryRelPath)
+				if err != nil {
+					return // Skip directories we can't read
+				}
+				// Only include directories that have markdown files somewhere
+				if hasMarkdownFiles(childNode) {
+					found[i] = childNode
+				}
+			})
+		} else if isNoteFile(entryName, s.opts.extensions) {
+			file := &FileNode{
+				Name:  entryName,
+				Path:  entryRelPath,
+				IsDir: false,
+			}
+			if original, _, ok := conflictOf(entryName, exists); ok {
+				file.ConflictOf = filepath.Join(relativePath, original)
+			}
+			found[i] = file
+			if s.opts.metadata {
+				s.run(&wg, func() { readMetadata(file, entry, entryPath, s.opts.cipher) })
+			}
+		}
+	}
+	wg.Wait()
+
+	var children []*FileNode
+	for _, child := range found {
+		if child != nil {
+			children = append(children, child)
+		}
+	}
+	sortNodes(children)
+
+	node.Children = children
+	node.Index = landingPage(children)
+	return node, nil
+}
+
+// run calls fn in a new goroutine if a worker is free, or else right away
+func (s *treeScanner) run(wg *sync.WaitGroup, fn func()) {
+	select {
+	case s.slots <- struct{}{}:
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-s.slots }()
+			fn()
+		}()
+	default:
+		fn()
+	}
+}
+
+// readMetadata fills in the size and title of a note
+func readMetadata(node *FileNode, entry os.DirEntry, path string, cipher Cipher) {
+	if info, err := entry.Info(); err == nil {
+		node.Size = info.Size()
+	}
+	node.Title = noteTitle(path, cipher)
+}
+
+// noteTitle returns the title from a note's front matter, or else its first
+// heading, looking only at the start of the file. A sealed note has to be
+// read whole to be decrypted.
+func noteTitle(path string, cipher Cipher) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if cipher != nil {
+		data, err := io.ReadAll(f)
+		if err != nil {
+			return ""
+		}
+		if data, err = cipher.Open(data); err != nil {
+			return ""
+		}
+		r = bytes.NewReader(data)
+	}
+
+	scanner := bufio.NewScanner(io.LimitReader(r, titleReadLimit))
+	inFrontMatter := false
+	for first := true; scanner.Scan(); first = false {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case first && line == "---":
+			inFrontMatter = true
+		case inFrontMatter && line == "---":
+			inFrontMatter = false
+		case inFrontMatter:
+			if key, value, ok := strings.Cut(line, ":"); ok && strings.TrimSpace(key) == "title" {
+				if title := strings.Trim(strings.TrimSpace(value), `"'`); title != "" {
+					return title
+				}
+			}
+		case strings.HasPrefix(line, "# "):
+			return strings.TrimSpace(line[2:])
+		}
+	}
+	return ""
+}
@@ -1,7 +1,6 @@
 package filesystem
 
 import (
-	"os"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -9,85 +8,100 @@ import (
 
 // FileNode represents a file or directory in the tree
 type FileNode struct {
-	Name     string      `json:"name"`
-	Path     string      `json:"path"`     // Relative path from root
-	IsDir    bool        `json:"isDir"`
-	Children []*FileNode `json:"children,omitempty"`
+	Name       string      `json:"name"`
+	Path       string      `json:"path"` // Relative path from root
+	IsDir      bool        `json:"isDir"`
+	Children   []*FileNode `json:"children,omitempty"`
+	Size       int64       `json:"size,omitempty"`       // Only with metadata
+	Title      string      `json:"title,omitempty"`      // Only with metadata
+	ConflictOf string      `json:"conflictOf,omitempty"` // Original of a sync conflict copy
+	Index      string      `json:"index,omitempty"`      // Landing page of a folder: its index.md or README.md
+	Truncated  bool        `json:"truncated,omitempty"`  // Children left out by CutTree
+}
+
+// treeOptions controls which entries appear in a file tree
+type treeOptions struct {
+	ignore     []string // Name patterns to skip
+	extensions []string // Note extensions; empty for markdown
+	assetsDir  string   // Image folder, relative to the root
+	metadata   bool     // Fill in note sizes and titles
+	workers    int      // Folders and notes read at once; 0 for treeWorkers
+	cipher     Cipher   // Decrypts notes when reading titles
 }
 
 // BuildTree builds a file tree starting from the given root directory
 // It only includes markdown files (.md) and directories that contain them
 func BuildTree(rootDir string) (*FileNode, error) {
-	return buildTreeRecursive(rootDir, rootDir, "")
+	return buildTreeRecursive(rootDir, rootDir, "", treeOptions{assetsDir: "assets"})
 }
 
-func buildTreeRecursive(rootDir, currentDir, relativePath string) (*FileNode, error) {
-	entries, err := os.ReadDir(currentDir)
-	if err != nil {
-		return nil, err
+// CutTree returns a copy of a tree holding only its first depth levels
+// below the root, so a large vault can be shown before all of it is sent.
+// Folders whose contents were left out are marked Truncated.
+func CutTree(node *FileNode, depth int) *FileNode {
+	cut := *node
+	cut.Children = nil
+	if len(node.Children) == 0 {
+		return &cut
 	}
-
-	name := filepath.Base(currentDir)
-	if relativePath == "" {
-		name = filepath.Base(rootDir)
+	if depth <= 0 {
+		cut.Truncated = true
+		return &cut
 	}
-
-	node := &FileNode{
-		Name:  name,
-		Path:  relativePath,
-		IsDir: true,
+	cut.Children = make([]*FileNode, len(node.Children))
+	for i, child := range node.Children {
+		cut.Children[i] = CutTree(child, depth-1)
 	}
+	return &cut
+}
 
-	var children []*FileNode
-
-	for _, entry := range entries {
-		entryName := entry.Name()
-
-		// Skip hidden files and directories
-		if strings.HasPrefix(entryName, ".") {
-			continue
+// sortNodes sorts directories first, then files, alphabetically
+func sortNodes(children []*FileNode) {
+	sort.Slice(children, func(i, j int) bool {
+		if children[i].IsDir != children[j].IsDir {
+			return children[i].IsDir // Directories come first
 		}
+		return strings.ToLower(children[i].Name) < strings.ToLower(children[j].Name)
+	})
+}
 
-		// Skip assets directory (where images are stored)
-		if entryName == "assets" && entry.IsDir() {
-			continue
+// isIgnored checks if a name matches any of the ignore patterns
+func isIgnored(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := filepath.Match(pattern, name); matched {
+			return true
 		}
+	}
+	return false
+}
 
-		entryPath := filepath.Join(currentDir, entryName)
-		entryRelPath := filepath.Join(relativePath, entryName)
-
-		if entry.IsDir() {
-			// Recursively build subtree
-			childNode, err := buildTreeRecursive(rootDir, entryPath, entryRelPath)
-			if err != nil {
-				continue // Skip directories we can't read
-			}
-			// Only include directories that have markdown files somewhere
-			if hasMarkdownFiles(childNode) {
-				children = append(children, childNode)
-			}
-		} else {
-			// Only include markdown files
-			if isMarkdownFile(entryName) {
-				children = append(children, &FileNode{
-					Name:  entryName,
-					Path:  entryRelPath,
-					IsDir: false,
-				})
-			}
+// IsIgnoredPath reports whether the file tree leaves out a path, relative
+// to the root, because it or a folder it is in is hidden or matches an
+// ignore pattern
+func (fs *FileSystem) IsIgnoredPath(relativePath string) bool {
+	for _, name := range strings.Split(filepath.ToSlash(filepath.Clean(relativePath)), "/") {
+		if strings.HasPrefix(name, ".") && name != "." && name != ".." || isIgnored(name, fs.Ignore) {
+			return true
 		}
 	}
+	return false
+}
 
-	// Sort: directories first, then files, alphabetically
-	sort.Slice(children, func(i, j int) bool {
-		if children[i].IsDir != children[j].IsDir {
-			return children[i].IsDir // Directories come first
+// IsAssetPath reports whether a path, relative to the root, is in the
+// assets folder or a folder named assets, which hold images rather than
+// notes
+func (fs *FileSystem) IsAssetPath(relativePath string) bool {
+	clean := filepath.Clean(filepath.FromSlash(relativePath))
+	assets := fs.assetsDir()
+	if SamePath(clean, assets) || len(clean) > len(assets) && clean[len(assets)] == filepath.Separator && SamePath(clean[:len(assets)], assets) {
+		return true
+	}
+	for _, name := range strings.Split(filepath.ToSlash(filepath.Dir(clean)), "/") {
+		if SamePath(name, "assets") {
+			return true
 		}
-		return strings.ToLower(children[i].Name) < strings.ToLower(children[j].Name)
-	})
-
-	node.Children = children
-	return node, nil
+	}
+	return false
 }
 
 // isMarkdownFile checks if a filename is a markdown file
@@ -96,10 +110,26 @@ func isMarkdownFile(name string) bool {
 	return strings.HasSuffix(lower, ".md") || strings.HasSuffix(lower, ".markdown")
 }
 
-// hasMarkdownFiles checks if a node or any of its children contain markdown files
+// isNoteFile checks if a filename has one of the note extensions, falling
+// back to markdown when none are configured
+func isNoteFile(name string, extensions []string) bool {
+	if len(extensions) == 0 {
+		return isMarkdownFile(name)
+	}
+	lower := strings.ToLower(name)
+	for _, ext := range extensions {
+		if strings.HasSuffix(lower, strings.ToLower(ext)) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasMarkdownFiles checks if a node or any of its children contain notes.
+// Only notes are added to the tree, so any file counts.
 func hasMarkdownFiles(node *FileNode) bool {
 	if !node.IsDir {
-		return isMarkdownFile(node.Name)
+		return true
 	}
 	for _, child := range node.Children {
 		if hasMarkdownFiles(child) {
@@ -0,0 +1,200 @@
+package filesystem
+
+import (
+	"log/slog"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// treeVerifyInterval is how often a cached tree is compared with a full
+// rebuild, catching changes the watcher missed
+const treeVerifyInterval = 5 * time.Minute
+
+// treeCache keeps a file tree in memory and rebuilds only the folders that
+// changed. A tree it has returned is never modified: an update copies the
+// nodes on the way to the changed folder and shares the rest.
+type treeCache struct {
+	rootDir string
+	opts    treeOptions
+
+	mu        sync.Mutex
+	root      *FileNode
+	dirty     map[string]bool // Folders to rebuild, relative to the root
+	gen       uint64          // Counts changes, so a verification can tell it is stale
+	verified  time.Time       // When the tree was last built in full
+	verifying bool
+}
+
+func newTreeCache(rootDir string, opts treeOptions) *treeCache {
+	return &treeCache{
+		rootDir: rootDir,
+		opts:    opts,
+		dirty:   make(map[string]bool),
+	}
+}
+
+// invalidate marks a folder, relative to the root, as changed
+func (c *treeCache) invalidate(dir string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	if c.root != nil {
+		c.dirty[dir] = true
+	}
+}
+
+// get returns the tree, building it on first use and rebuilding the
+// folders that changed since the last call
+func (c *treeCache) get() (*FileNode, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.root == nil {
+		root, err := c.build("")
+		if err != nil {
+			return nil, err
+		}
+		c.root = root
+		c.verified = time.Now()
+		return root, nil
+	}
+
+	if len(c.dirty) > 0 {
+		if err := c.apply(); err != nil {
+			return nil, err
+		}
+	}
+
+	if time.Since(c.verified) > treeVerifyInterval && !c.verifying {
+		c.verifying = true
+		go c.verify(c.gen)
+	}
+	return c.root, nil
+}
+
+// apply rebuilds the changed folders. A folder inside another changed one
+// is covered by rebuilding the outer one. c.mu must be held.
+func (c *treeCache) apply() error {
+	dirs := make([]string, 0, len(c.dirty))
+	for dir := range c.dirty {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	c.dirty = make(map[string]bool)
+
+	var done []string
+	for _, dir := range dirs {
+		if containedIn(dir, done) {
+			continue
+		}
+		done = append(done, dir)
+
+		if dir == "" {
+			root, err := c.build("")
+			if err != nil {
+				return err
+			}
+			c.root = root
+			continue
+		}
+		if c.excluded(dir) {
+			continue
+		}
+
+		// A folder that is gone or holds no notes leaves the tree
+		sub, err := c.build(dir)
+		if err != nil || !hasMarkdownFiles(sub) {
+			sub = nil
+		}
+		c.root = splice(c.root, strings.Split(dir, string(filepath.Separator)), sub)
+	}
+	return nil
+}
+
+// verify rebuilds the whole tree and replaces the cached one if they
+// differ. The result is thrown away if something changed meanwhile.
+func (c *treeCache) verify(gen uint64) {
+	full, err := c.build("")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.verifying = false
+	if err != nil || c.gen != gen {
+		return
+	}
+	c.verified = time.Now()
+	if !reflect.DeepEqual(full, c.root) {
+		slog.Warn("File tree was out of date; rebuilt it", "root", c.rootDir)
+		c.root = full
+	}
+}
+
+// build walks a folder, relative to the root
+func (c *treeCache) build(dir string) (*FileNode, error) {
+	return buildTreeRecursive(c.rootDir, filepath.Join(c.rootDir, dir), dir, c.opts)
+}
+
+// excluded reports whether a folder, or one it is in, is left out of the
+// tree
+func (c *treeCache) excluded(dir string) bool {
+	rel := ""
+	for _, name := range strings.Split(dir, string(filepath.Separator)) {
+		rel = filepath.Join(rel, name)
+		if IsHidden(filepath.Join(c.rootDir, rel)) || SamePath(name, "assets") || SamePath(rel, c.opts.assetsDir) || isIgnored(name, c.opts.ignore) {
+			return true
+		}
+	}
+	return false
+}
+
+// splice returns a copy of node with the folder at path replaced by sub,
+// or removed if sub is nil. Folders left without notes are removed too.
+func splice(node *FileNode, path []string, sub *FileNode) *FileNode {
+	if len(path) == 0 {
+		return sub
+	}
+
+	var child *FileNode
+	children := make([]*FileNode, 0, len(node.Children)+1)
+	for _, c := range node.Children {
+		if c.IsDir && c.Name == path[0] {
+			child = c
+		} else {
+			children = append(children, c)
+		}
+	}
+	if child == nil {
+		if sub == nil {
+			return node
+		}
+		child = &FileNode{Name: path[0], Path: filepath.Join(node.Path, path[0]), IsDir: true}
+	}
+
+	if updated := splice(child, path[1:], sub); updated != nil && hasMarkdownFiles(updated) {
+		children = append(children, updated)
+		sortNodes(children)
+	}
+
+	copied := *node
+	copied.Children = children
+	if len(children) == 0 {
+		copied.Children = nil
+	}
+	return &copied
+}
+
+// containedIn reports whether dir is one of dirs or inside one of them
+func containedIn(dir string, dirs []string) bool {
+	for _, d := range dirs {
+		if InDir(dir, d) {
+			return true
+		}
+	}
+	return false
+}
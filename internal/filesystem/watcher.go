@@ -1,10 +1,9 @@
 package filesystem
 
 import (
-	"log"
+	"log/slog"
 	"os"
 	"path/filepath"
-	"strings"
 	"sync"
 	"time"
 
@@ -32,12 +31,14 @@ type Watcher struct {
 	rootDir      string
 	watcher      *fsnotify.Watcher
 	listeners    []chan FileEvent
+	observers    []func(FileEvent) // Called before listeners are notified
 	mu           sync.RWMutex
 	done         chan struct{}
 	closed       bool
 	watchedPaths map[string]bool  // Track watched directories
 	pathsMu      sync.RWMutex     // Separate mutex for paths map
 	debouncer    *eventDebouncer  // Debounce rapid events
+	extensions   []string         // Note extensions; empty for markdown
 }
 
 // eventDebouncer coalesces rapid file events
@@ -127,7 +128,7 @@ func NewWatcher(rootDir string) (*Watcher, error) {
 		return nil, err
 	}
 
-	log.Printf("Watcher initialized with %d directories", len(w.watchedPaths))
+	slog.Debug("Watcher initialized", "root", rootDir, "directories", len(w.watchedPaths))
 
 	// Start watching
 	go w.watch()
@@ -135,6 +136,23 @@ func NewWatcher(rootDir string) (*Watcher, error) {
 	return w, nil
 }
 
+// SetExtensions sets which file extensions count as notes for content
+// change events
+func (w *Watcher) SetExtensions(extensions []string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.extensions = extensions
+}
+
+// Observe calls fn for every event before any listener receives it, so
+// state derived from the files is up to date when listeners react. fn must
+// not call back into the watcher.
+func (w *Watcher) Observe(fn func(FileEvent)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.observers = append(w.observers, fn)
+}
+
 // Subscribe returns a channel that receives file events
 func (w *Watcher) Subscribe() chan FileEvent {
 	w.mu.Lock()
@@ -190,7 +208,7 @@ func (w *Watcher) Close() error {
 	w.watchedPaths = nil
 	w.pathsMu.Unlock()
 
-	log.Printf("Watcher closed")
+	slog.Debug("Watcher closed")
 	return w.watcher.Close()
 }
 
@@ -223,7 +241,7 @@ func (w *Watcher) handleEvent(event fsnotify.Event) {
 	}
 
 	// Skip hidden files and directories
-	if strings.HasPrefix(filepath.Base(event.Name), ".") {
+	if IsHidden(event.Name) {
 		return
 	}
 
@@ -243,8 +261,11 @@ func (w *Watcher) handleEvent(event fsnotify.Event) {
 			w.addDirRecursive(event.Name)
 		}
 	case event.Op&fsnotify.Write != 0:
-		// Only care about markdown files for content changes
-		if !isMarkdownFile(event.Name) {
+		// Only care about notes for content changes
+		w.mu.RLock()
+		isNote := isNoteFile(event.Name, w.extensions)
+		w.mu.RUnlock()
+		if !isNote {
 			return
 		}
 		fileEvent.Type = EventModified
@@ -277,6 +298,9 @@ func (w *Watcher) notifyListeners(event FileEvent) {
 		return
 	}
 
+	for _, fn := range w.observers {
+		fn(event)
+	}
 	for _, ch := range w.listeners {
 		select {
 		case ch <- event:
@@ -295,18 +319,17 @@ func (w *Watcher) removeDir(dir string) {
 	if w.watchedPaths[dir] {
 		if err := w.watcher.Remove(dir); err != nil {
 			// Log but continue - the path may already be gone
-			log.Printf("Warning: could not remove watch for %s: %v", dir, err)
+			slog.Warn("Could not remove watch", "path", dir, "error", err)
 		}
 		delete(w.watchedPaths, dir)
 	}
 
 	// Remove any subdirectories that start with this path
-	prefix := dir + string(filepath.Separator)
 	for path := range w.watchedPaths {
-		if strings.HasPrefix(path, prefix) {
+		if path != dir && InDir(path, dir) {
 			if err := w.watcher.Remove(path); err != nil {
 				// Log but continue
-				log.Printf("Warning: could not remove watch for %s: %v", path, err)
+				slog.Warn("Could not remove watch", "path", path, "error", err)
 			}
 			delete(w.watchedPaths, path)
 		}
@@ -321,7 +344,7 @@ func (w *Watcher) addDirRecursive(dir string) error {
 		}
 
 		// Skip hidden directories
-		if info.IsDir() && strings.HasPrefix(info.Name(), ".") && path != dir {
+		if info.IsDir() && path != dir && IsHidden(path) {
 			return filepath.SkipDir
 		}
 
@@ -336,7 +359,7 @@ func (w *Watcher) addDirRecursive(dir string) error {
 			}
 
 			if err := w.watcher.Add(path); err != nil {
-				log.Printf("Warning: could not watch directory %s: %v", path, err)
+				slog.Warn("Could not watch directory", "path", path, "error", err)
 				return nil // Continue with other directories
 			}
 
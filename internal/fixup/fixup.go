@@ -0,0 +1,285 @@
+// Package fixup checks a note's footnotes and reference links and applies
+// whole-document fixes to them, such as renumbering footnotes or turning
+// inline links into reference links
+package fixup
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Issue kinds reported by Check
+const (
+	KindMissingFootnote    = "missing-footnote"    // [^label] without a definition
+	KindUnusedFootnote     = "unused-footnote"     // [^label]: never referenced
+	KindDuplicateFootnote  = "duplicate-footnote"  // [^label]: defined more than once
+	KindMissingReference   = "missing-reference"   // [text][label] without a definition
+	KindUnusedReference    = "unused-reference"    // [label]: url never used
+	KindDuplicateReference = "duplicate-reference" // [label]: url defined more than once
+)
+
+// Issue is a problem with a footnote or reference link
+type Issue struct {
+	Kind    string `json:"kind"`
+	Line    int    `json:"line"` // 1-based, counting front matter
+	Label   string `json:"label"`
+	Message string `json:"message"`
+}
+
+// Fix is a change applied to a whole note
+type Fix struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+
+	// Apply returns the fixed markdown and the number of changes made
+	Apply func(markdown string) (string, int) `json:"-"`
+}
+
+// Fixes are the fixes the editor can offer, by name
+var Fixes = []Fix{
+	{
+		Name:        "renumber-footnotes",
+		Description: "Renumber footnotes 1, 2, 3... in the order they are referenced",
+		Apply:       RenumberFootnotes,
+	},
+	{
+		Name:        "reference-links",
+		Description: "Turn inline links into numbered reference links listed at the end",
+		Apply:       ReferenceLinks,
+	},
+	{
+		Name:        "remove-unused",
+		Description: "Remove footnote and link definitions nothing refers to",
+		Apply:       RemoveUnused,
+	},
+}
+
+// Lookup returns the fix called name
+func Lookup(name string) (Fix, bool) {
+	for _, f := range Fixes {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return Fix{}, false
+}
+
+var (
+	// footnoteRefPattern matches [^label], which is a definition when it
+	// starts a line and is followed by :
+	footnoteRefPattern = regexp.MustCompile(`\[\^([^\]\s]+)\]`)
+	footnoteDefPattern = regexp.MustCompile(`(?m)^ {0,3}\[\^([^\]\s]+)\]:`)
+	// linkDefPattern matches a link reference definition: [label]: url "title"
+	linkDefPattern = regexp.MustCompile(`(?m)^ {0,3}\[([^\]^][^\]]*)\]:[ \t]*(<[^>\n]*>|\S+)(?:[ \t]+("[^"\n]*"|'[^'\n]*'|\([^)\n]*\)))?[ \t]*$`)
+	// linkRefPattern matches [text][label] and [label][]
+	linkRefPattern = regexp.MustCompile(`\[((?:[^\[\]]|\[[^\[\]]*\])*)\]\[([^\[\]]*)\]`)
+	// shortcutPattern matches [label], which is a link only when defined
+	shortcutPattern = regexp.MustCompile(`\[([^\[\]^][^\[\]]*)\]`)
+)
+
+// Check reports footnotes and reference links that are used without a
+// definition, defined without being used, or defined twice. Code is not
+// checked.
+func Check(markdown string) []Issue {
+	masked := maskCode(markdown)
+	issues := []Issue{}
+	add := func(kind string, at int, label, message string) {
+		issues = append(issues, Issue{Kind: kind, Line: lineAt(markdown, at), Label: label, Message: message})
+	}
+
+	// Footnotes
+	defs := make(map[string]int)
+	for _, m := range footnoteDefPattern.FindAllStringSubmatchIndex(masked, -1) {
+		label := masked[m[2]:m[3]]
+		if _, dup := defs[label]; dup {
+			add(KindDuplicateFootnote, m[0], label, "Footnote [^"+label+"] is defined more than once")
+			continue
+		}
+		defs[label] = m[0]
+	}
+	used := make(map[string]bool)
+	for _, m := range footnoteRefs(masked) {
+		label := masked[m[2]:m[3]]
+		if _, ok := defs[label]; !ok {
+			add(KindMissingFootnote, m[0], label, "Footnote [^"+label+"] has no definition")
+		}
+		used[label] = true
+	}
+	for label, at := range defs {
+		if !used[label] {
+			add(KindUnusedFootnote, at, label, "Footnote [^"+label+"] is never referenced")
+		}
+	}
+
+	// Reference links
+	type linkDef struct {
+		at    int
+		label string // As written
+	}
+	links := make(map[string]linkDef)
+	for _, m := range linkDefPattern.FindAllStringSubmatchIndex(masked, -1) {
+		label := masked[m[2]:m[3]]
+		if _, dup := links[normalizeLabel(label)]; dup {
+			add(KindDuplicateReference, m[0], label, "Link ["+label+"] is defined more than once")
+			continue
+		}
+		links[normalizeLabel(label)] = linkDef{m[0], label}
+	}
+	usedLinks := make(map[string]bool)
+	body := linkDefPattern.ReplaceAllStringFunc(masked, blank)
+	for _, m := range linkRefPattern.FindAllStringSubmatchIndex(body, -1) {
+		label := body[m[4]:m[5]]
+		if label == "" {
+			label = body[m[2]:m[3]] // [label][]
+		}
+		if strings.HasPrefix(label, "^") {
+			continue // [text][^1] is text followed by a footnote
+		}
+		if _, ok := links[normalizeLabel(label)]; !ok {
+			add(KindMissingReference, m[0], label, "Link ["+label+"] has no definition")
+		}
+		usedLinks[normalizeLabel(label)] = true
+	}
+	for _, m := range shortcutPattern.FindAllStringSubmatchIndex(body, -1) {
+		usedLinks[normalizeLabel(body[m[2]:m[3]])] = true
+	}
+	for key, def := range links {
+		if !usedLinks[key] {
+			add(KindUnusedReference, def.at, def.label, "Link ["+def.label+"] is never used")
+		}
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool { return issues[i].Line < issues[j].Line })
+	return issues
+}
+
+// RemoveUnused deletes footnote definitions and link reference definitions
+// that nothing refers to, along with the indented lines that continue a
+// footnote
+func RemoveUnused(markdown string) (string, int) {
+	unused := make(map[int]bool) // Lines of definitions to remove
+	for _, issue := range Check(markdown) {
+		if issue.Kind == KindUnusedFootnote || issue.Kind == KindUnusedReference {
+			unused[issue.Line] = true
+		}
+	}
+	if len(unused) == 0 {
+		return markdown, 0
+	}
+
+	lines := strings.SplitAfter(markdown, "\n")
+	var b strings.Builder
+	removed := 0
+	for i := 0; i < len(lines); i++ {
+		if !unused[i+1] {
+			b.WriteString(lines[i])
+			continue
+		}
+		removed++
+		if strings.HasPrefix(strings.TrimSpace(lines[i]), "[^") {
+			// A footnote continues over indented lines
+			for i+1 < len(lines) && isContinuation(lines, i+1) {
+				i++
+			}
+		}
+	}
+	return b.String(), removed
+}
+
+// isContinuation says whether line i continues the footnote above it: it
+// is indented, or blank and followed by an indented line
+func isContinuation(lines []string, i int) bool {
+	indented := func(l string) bool { return strings.HasPrefix(l, "    ") || strings.HasPrefix(l, "\t") }
+	if indented(lines[i]) {
+		return true
+	}
+	return strings.TrimSpace(lines[i]) == "" && i+1 < len(lines) && indented(lines[i+1])
+}
+
+// footnoteRefs returns the matches of footnote references, leaving out the
+// labels of definitions
+func footnoteRefs(masked string) [][]int {
+	defs := make(map[int]bool)
+	for _, m := range footnoteDefPattern.FindAllStringSubmatchIndex(masked, -1) {
+		defs[m[1]-1] = true // The : after the label
+	}
+	var refs [][]int
+	for _, m := range footnoteRefPattern.FindAllStringSubmatchIndex(masked, -1) {
+		if !defs[m[1]] {
+			refs = append(refs, m)
+		}
+	}
+	return refs
+}
+
+// normalizeLabel matches labels the way CommonMark does: case-insensitive,
+// with runs of space collapsed
+func normalizeLabel(label string) string {
+	return strings.ToLower(strings.Join(strings.Fields(label), " "))
+}
+
+// maskCode blanks out fenced code blocks and `code spans`, keeping offsets
+// and line breaks, so patterns don't match inside code
+func maskCode(markdown string) string {
+	b := []byte(markdown)
+	fence := ""
+	start := 0
+	for start < len(b) {
+		end := start
+		for end < len(b) && b[end] != '\n' {
+			end++
+		}
+		trimmed := strings.TrimSpace(string(b[start:end]))
+		switch {
+		case fence != "":
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+			blankBytes(b[start:end])
+		case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+			fence = trimmed[:3]
+			blankBytes(b[start:end])
+		default:
+			maskSpans(b[start:end])
+		}
+		start = end + 1
+	}
+	return string(b)
+}
+
+// maskSpans blanks out the code spans on a line
+func maskSpans(line []byte) {
+	for i := 0; i < len(line); {
+		if line[i] != '`' {
+			i++
+			continue
+		}
+		ticks := i
+		for ticks < len(line) && line[ticks] == '`' {
+			ticks++
+		}
+		end := strings.Index(string(line[ticks:]), string(line[i:ticks]))
+		if end < 0 {
+			return
+		}
+		end += ticks + (ticks - i)
+		blankBytes(line[i:end])
+		i = end
+	}
+}
+
+func blankBytes(b []byte) {
+	for i := range b {
+		b[i] = ' '
+	}
+}
+
+func blank(s string) string {
+	return strings.Repeat(" ", len(s))
+}
+
+// lineAt returns the 1-based line of offset
+func lineAt(s string, offset int) int {
+	return strings.Count(s[:offset], "\n") + 1
+}
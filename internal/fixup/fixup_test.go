@@ -0,0 +1,122 @@
+package fixup
+
+import (
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	src := "# Notes\n\n" +
+		"Claim[^a] and another[^missing], see [the docs][docs] and [faq][].\n" +
+		"`[^code]` and [broken][nowhere].\n\n" +
+		"[^a]: Defined.\n" +
+		"[^a]: Again.\n" +
+		"[^spare]: Never used.\n\n" +
+		"[docs]: https://example.com/docs\n" +
+		"[FAQ]: https://example.com/faq\n" +
+		"[old]: https://example.com/old\n"
+
+	want := []Issue{
+		{Kind: KindMissingFootnote, Line: 3, Label: "missing"},
+		{Kind: KindMissingReference, Line: 4, Label: "nowhere"},
+		{Kind: KindDuplicateFootnote, Line: 7, Label: "a"},
+		{Kind: KindUnusedFootnote, Line: 8, Label: "spare"},
+		{Kind: KindUnusedReference, Line: 12, Label: "old"},
+	}
+	got := Check(src)
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d issues, got %+v", len(want), got)
+	}
+	for i, w := range want {
+		if got[i].Kind != w.Kind || got[i].Line != w.Line || got[i].Label != w.Label {
+			t.Errorf("Issue %d = %+v, want %+v", i, got[i], w)
+		}
+	}
+}
+
+func TestRenumberFootnotes(t *testing.T) {
+	src := "First[^note] then[^3] and[^note] again.\n\n" +
+		"[^3]: Second.\n" +
+		"[^note]: First,\n" +
+		"    continued.\n" +
+		"[^1]: Unused.\n"
+
+	got, n := RenumberFootnotes(src)
+	want := "First[^1] then[^2] and[^1] again.\n\n" +
+		"[^1]: First,\n" +
+		"    continued.\n" +
+		"[^2]: Second.\n" +
+		"[^3]: Unused.\n"
+	if got != want || n != 3 {
+		t.Errorf("RenumberFootnotes = %d changes:\n%s\nwant:\n%s", n, got, want)
+	}
+
+	if _, n := RenumberFootnotes(want); n != 0 {
+		t.Errorf("Numbered footnotes should be left alone, got %d changes", n)
+	}
+}
+
+func TestReferenceLinks(t *testing.T) {
+	src := "See [docs](https://example.com/docs \"Docs\"), [again](https://example.com/docs \"Docs\")\n" +
+		"and [home][1], but not ![img](a.png) or `[code](x)`.\n\n" +
+		"[1]: https://example.com\n"
+
+	got, n := ReferenceLinks(src)
+	want := "See [docs][2], [again][2]\n" +
+		"and [home][1], but not ![img](a.png) or `[code](x)`.\n\n" +
+		"[1]: https://example.com\n" +
+		"[2]: https://example.com/docs \"Docs\"\n"
+	if got != want || n != 2 {
+		t.Errorf("ReferenceLinks = %d changes:\n%s\nwant:\n%s", n, got, want)
+	}
+	if issues := Check(got); len(issues) != 0 {
+		t.Errorf("Converted note has issues: %+v", issues)
+	}
+}
+
+func TestRewriteDestinations(t *testing.T) {
+	src := "![chart](/images/a.png \"Chart\") and [file](<../assets/my a.png>)\n" +
+		"`![code](/images/a.png)` and [site](https://example.com)\n\n" +
+		"[ref]: /images/a.png\n"
+
+	got, n := RewriteDestinations(src, func(dest string) string {
+		switch dest {
+		case "/images/a.png":
+			return "/images/notes/a.png"
+		case "../assets/my a.png":
+			return "../assets/notes/my a.png"
+		}
+		return dest
+	})
+	want := "![chart](/images/notes/a.png \"Chart\") and [file](<../assets/notes/my a.png>)\n" +
+		"`![code](/images/a.png)` and [site](https://example.com)\n\n" +
+		"[ref]: /images/notes/a.png\n"
+	if got != want || n != 3 {
+		t.Errorf("RewriteDestinations = %d changes:\n%s\nwant:\n%s", n, got, want)
+	}
+}
+
+func TestRemoveUnused(t *testing.T) {
+	src := "Text[^1] and [link][a].\n\n" +
+		"[^1]: Used.\n" +
+		"[^2]: Unused,\n" +
+		"    over two lines.\n" +
+		"[a]: https://example.com/a\n" +
+		"[b]: https://example.com/b\n"
+
+	got, n := RemoveUnused(src)
+	want := "Text[^1] and [link][a].\n\n" +
+		"[^1]: Used.\n" +
+		"[a]: https://example.com/a\n"
+	if got != want || n != 2 {
+		t.Errorf("RemoveUnused = %d changes:\n%s\nwant:\n%s", n, got, want)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	if _, ok := Lookup("renumber-footnotes"); !ok {
+		t.Error("renumber-footnotes not found")
+	}
+	if _, ok := Lookup("nope"); ok {
+		t.Error("Unknown fix found")
+	}
+}
@@ -0,0 +1,132 @@
+package fixup
+
+import (
+	"strconv"
+	"strings"
+)
+
+// RenumberFootnotes relabels footnotes 1, 2, 3... in the order they are
+// first referenced. Definitions nothing refers to are numbered after the
+// rest, and definitions are moved into order when they sit together at the
+// end of the note.
+func RenumberFootnotes(markdown string) (string, int) {
+	masked := maskCode(markdown)
+
+	labels := make(map[string]string) // Old label to new
+	next := 1
+	assign := func(label string) {
+		if _, ok := labels[label]; !ok {
+			labels[label] = strconv.Itoa(next)
+			next++
+		}
+	}
+	for _, m := range footnoteRefs(masked) {
+		assign(masked[m[2]:m[3]])
+	}
+	for _, m := range footnoteDefPattern.FindAllStringSubmatchIndex(masked, -1) {
+		assign(masked[m[2]:m[3]])
+	}
+
+	changed := 0
+	for old, label := range labels {
+		if old != label {
+			changed++
+		}
+	}
+	if changed == 0 {
+		return markdown, 0
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, m := range footnoteRefPattern.FindAllStringSubmatchIndex(masked, -1) {
+		label, ok := labels[masked[m[2]:m[3]]]
+		if !ok {
+			continue
+		}
+		b.WriteString(markdown[last:m[2]])
+		b.WriteString(label)
+		last = m[3]
+	}
+	b.WriteString(markdown[last:])
+
+	return sortTrailingFootnotes(b.String()), changed
+}
+
+// sortTrailingFootnotes puts the footnote definitions ending a note in
+// numeric order. Definitions elsewhere are left where they are.
+func sortTrailingFootnotes(markdown string) string {
+	lines := strings.SplitAfter(markdown, "\n")
+
+	// Find the trailing run of definitions and their continuation lines
+	start := len(lines)
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := lines[i]
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "    ") || strings.HasPrefix(line, "\t") {
+			continue
+		}
+		if !footnoteDefPattern.MatchString(line) {
+			break
+		}
+		start = i
+	}
+	if start == len(lines) {
+		return markdown
+	}
+
+	type def struct {
+		n     int
+		lines []string
+	}
+	var defs []def
+	for _, line := range lines[start:] {
+		if m := footnoteDefPattern.FindStringSubmatch(line); m != nil {
+			n, _ := strconv.Atoi(m[1])
+			defs = append(defs, def{n: n})
+		}
+		defs[len(defs)-1].lines = append(defs[len(defs)-1].lines, line)
+	}
+
+	// Keep the blank line separating definitions, or the end of the note,
+	// with the position rather than the definition
+	trailing := func(d *def) []string {
+		end := len(d.lines)
+		for end > 1 && strings.TrimSpace(d.lines[end-1]) == "" {
+			end--
+		}
+		tail := d.lines[end:]
+		d.lines = d.lines[:end]
+		return tail
+	}
+	gaps := make([][]string, len(defs))
+	for i := range defs {
+		gaps[i] = trailing(&defs[i])
+	}
+	// Insertion sort keeps definitions with equal numbers in order
+	for i := 1; i < len(defs); i++ {
+		for j := i; j > 0 && defs[j].n < defs[j-1].n; j-- {
+			defs[j], defs[j-1] = defs[j-1], defs[j]
+		}
+	}
+
+	var b strings.Builder
+	for _, line := range lines[:start] {
+		b.WriteString(line)
+	}
+	for i, d := range defs {
+		for j, line := range d.lines {
+			if j == len(d.lines)-1 && !strings.HasSuffix(line, "\n") && i < len(defs)-1 {
+				line += "\n" // The note's last line moved up
+			}
+			b.WriteString(line)
+		}
+		for _, line := range gaps[i] {
+			b.WriteString(line)
+		}
+	}
+	out := b.String()
+	if !strings.HasSuffix(markdown, "\n") {
+		out = strings.TrimSuffix(out, "\n")
+	}
+	return out
+}
@@ -0,0 +1,136 @@
+package fixup
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// inlineLinkPattern matches [text](url "title"), images included
+var inlineLinkPattern = regexp.MustCompile(`(!?)\[((?:[^\[\]]|\[[^\[\]]*\])*)\]\((<[^<>\n]*>|[^\s()<>]*(?:\([^\s()]*\)[^\s()<>]*)*)(?:\s+("[^"\n]*"|'[^'\n]*'|\([^)\n]*\)))?\s*\)`)
+
+// ReferenceLinks turns inline links into reference links numbered after
+// any the note already has, listing the new definitions at the end.
+// Links to the same destination share a definition. Images and links
+// without a destination are left alone.
+func ReferenceLinks(markdown string) (string, int) {
+	masked := maskCode(markdown)
+
+	// Existing definitions are reused and numbered around
+	byTarget := make(map[string]string)
+	taken := make(map[string]bool)
+	next := 1
+	for _, m := range linkDefPattern.FindAllStringSubmatch(masked, -1) {
+		label := normalizeLabel(m[1])
+		taken[label] = true
+		target := definitionTarget(m[2], m[3])
+		if _, ok := byTarget[target]; !ok {
+			byTarget[target] = m[1]
+		}
+		if n, err := strconv.Atoi(label); err == nil && n >= next {
+			next = n + 1
+		}
+	}
+
+	var b strings.Builder
+	var added []string
+	last, changed := 0, 0
+	for _, m := range inlineLinkPattern.FindAllStringSubmatchIndex(masked, -1) {
+		if m[3] > m[2] || m[7] == m[6] {
+			continue // An image, or a link to nowhere
+		}
+		dest := markdown[m[6]:m[7]]
+		title := ""
+		if m[8] >= 0 {
+			title = markdown[m[8]:m[9]]
+		}
+		target := definitionTarget(dest, title)
+		label, ok := byTarget[target]
+		if !ok {
+			for taken[strconv.Itoa(next)] {
+				next++
+			}
+			label = strconv.Itoa(next)
+			taken[label] = true
+			byTarget[target] = label
+			added = append(added, "["+label+"]: "+target)
+		}
+
+		b.WriteString(markdown[last:m[0]])
+		b.WriteString("[" + markdown[m[4]:m[5]] + "][" + label + "]")
+		last = m[1]
+		changed++
+	}
+	if changed == 0 {
+		return markdown, 0
+	}
+	b.WriteString(markdown[last:])
+
+	out := b.String()
+	if len(added) > 0 {
+		out = strings.TrimRight(out, "\n")
+		lastLine := out[strings.LastIndexByte(out, '\n')+1:]
+		if linkDefPattern.MatchString(lastLine) {
+			out += "\n" // Continue the note's definitions
+		} else {
+			out += "\n\n"
+		}
+		out += strings.Join(added, "\n") + "\n"
+	}
+	return out, changed
+}
+
+// definitionTarget writes a destination and title as they appear in a
+// definition
+func definitionTarget(dest, title string) string {
+	if title == "" {
+		return dest
+	}
+	return dest + " " + title
+}
+
+// RewriteDestinations passes the destination of every inline link, image
+// and link definition through rewrite and returns the note with the
+// results and how many changed. Destinations in code are left alone.
+func RewriteDestinations(markdown string, rewrite func(dest string) string) (string, int) {
+	masked := maskCode(markdown)
+
+	var spans [][2]int
+	for _, m := range inlineLinkPattern.FindAllStringSubmatchIndex(masked, -1) {
+		if m[7] > m[6] {
+			spans = append(spans, [2]int{m[6], m[7]})
+		}
+	}
+	for _, m := range linkDefPattern.FindAllStringSubmatchIndex(masked, -1) {
+		spans = append(spans, [2]int{m[4], m[5]})
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i][0] < spans[j][0] })
+
+	var b strings.Builder
+	last, changed := 0, 0
+	for _, span := range spans {
+		dest := markdown[span[0]:span[1]]
+		bracketed := strings.HasPrefix(dest, "<")
+		if bracketed {
+			dest = strings.TrimSuffix(strings.TrimPrefix(dest, "<"), ">")
+		}
+		rewritten := rewrite(dest)
+		if rewritten == dest {
+			continue
+		}
+		if bracketed || strings.ContainsAny(rewritten, " \t") {
+			rewritten = "<" + rewritten + ">"
+		}
+
+		b.WriteString(markdown[last:span[0]])
+		b.WriteString(rewritten)
+		last = span[1]
+		changed++
+	}
+	if changed == 0 {
+		return markdown, 0
+	}
+	b.WriteString(markdown[last:])
+	return b.String(), changed
+}
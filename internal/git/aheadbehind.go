@@ -0,0 +1,145 @@
+package git
+
+import (
+	"bytes"
+	"container/heap"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+// aheadBehindEntrySize is what one cached count is taken to cost, so the
+// cache holds a few thousand branch pairs
+const aheadBehindEntrySize = 64
+
+// aheadBehind caches counts by the pair of commits compared. Commits never
+// change, so a pair always has the same counts.
+var aheadBehind = newLRUCache(4096 * aheadBehindEntrySize)
+
+// Which side of a comparison a commit is reachable from
+const (
+	reachLocal uint8 = 1 << iota
+	reachRemote
+	reachBoth = reachLocal | reachRemote
+)
+
+// countAheadBehind counts the commits reachable from local but not remote
+// (ahead) and from remote but not local (behind), the same as
+// git rev-list --left-right --count local...remote.
+//
+// Rather than loading both histories, it walks back from both commits
+// newest first, marking each commit with the sides it is reachable from,
+// and stops once every commit left to visit is reachable from both and
+// older than any commit seen from one side only: those are the merge base
+// and its ancestors, which count towards neither side. The walk is only as
+// long as the history since the merge base. As with git, a commit dated
+// before its parent can throw the counts off.
+func countAheadBehind(s storer.EncodedObjectStorer, local, remote plumbing.Hash) (ahead, behind int, err error) {
+	if local == remote {
+		return 0, 0, nil
+	}
+	key := cacheKey("ahead-behind", local.String(), remote.String())
+	if cached, ok := aheadBehind.get(key); ok {
+		counts := cached.([2]int)
+		return counts[0], counts[1], nil
+	}
+
+	reach := make(map[plumbing.Hash]uint8)
+	visited := make(map[plumbing.Hash]bool)
+	queue := &commitQueue{reach: reach}
+	for _, start := range []struct {
+		hash plumbing.Hash
+		side uint8
+	}{{local, reachLocal}, {remote, reachRemote}} {
+		c, err := object.GetCommit(s, start.hash)
+		if err != nil {
+			return 0, 0, err
+		}
+		reach[c.Hash] = start.side
+		heap.Push(queue, c)
+	}
+
+	// The oldest commit visited from one side only. Commits made in the
+	// same second can be visited before their children, so the walk goes
+	// on until it is past them.
+	var oneSided time.Time
+	for queue.Len() > 0 && !(queue.allBoth() && queue.commits[0].Committer.When.Before(oneSided)) {
+		c := heap.Pop(queue).(*object.Commit)
+		visited[c.Hash] = true
+		side := reach[c.Hash]
+		if side != reachBoth && (oneSided.IsZero() || c.Committer.When.Before(oneSided)) {
+			oneSided = c.Committer.When
+		}
+
+		for _, parent := range c.ParentHashes {
+			seen, known := reach[parent]
+			if seen|side == seen {
+				continue
+			}
+			reach[parent] = seen | side
+			if known && !visited[parent] {
+				// Still queued; it takes the new side with it when visited
+				continue
+			}
+			p, err := object.GetCommit(s, parent)
+			if err != nil {
+				// Beyond the edge of a shallow clone
+				continue
+			}
+			// Visited again when a side reaches it late, to pass it on
+			visited[parent] = false
+			heap.Push(queue, p)
+		}
+	}
+
+	for _, side := range reach {
+		switch side {
+		case reachLocal:
+			ahead++
+		case reachRemote:
+			behind++
+		}
+	}
+	aheadBehind.add(key, [2]int{ahead, behind}, aheadBehindEntrySize)
+	return ahead, behind, nil
+}
+
+// commitQueue orders commits newest first, as git does when walking
+// history, so a commit's descendants are visited before it
+type commitQueue struct {
+	commits []*object.Commit
+	reach   map[plumbing.Hash]uint8
+}
+
+func (q *commitQueue) Len() int { return len(q.commits) }
+
+func (q *commitQueue) Less(i, j int) bool {
+	a, b := q.commits[i], q.commits[j]
+	if !a.Committer.When.Equal(b.Committer.When) {
+		return a.Committer.When.After(b.Committer.When)
+	}
+	// The same order every time for commits made in the same second
+	return bytes.Compare(a.Hash[:], b.Hash[:]) < 0
+}
+
+func (q *commitQueue) Swap(i, j int) { q.commits[i], q.commits[j] = q.commits[j], q.commits[i] }
+
+func (q *commitQueue) Push(x interface{}) { q.commits = append(q.commits, x.(*object.Commit)) }
+
+func (q *commitQueue) Pop() interface{} {
+	last := q.commits[len(q.commits)-1]
+	q.commits = q.commits[:len(q.commits)-1]
+	return last
+}
+
+// allBoth reports whether every queued commit is reachable from both sides
+func (q *commitQueue) allBoth() bool {
+	for _, c := range q.commits {
+		if q.reach[c.Hash] != reachBoth {
+			return false
+		}
+	}
+	return true
+}
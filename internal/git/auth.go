@@ -1,11 +1,16 @@
 package git
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
 
+	"inkwell/internal/git/oauth"
+	"inkwell/internal/secrets"
+
 	"github.com/go-git/go-git/v5/plumbing/transport"
 	"github.com/go-git/go-git/v5/plumbing/transport/http"
 	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
@@ -27,6 +32,7 @@ type AuthConfig struct {
 	SSHPassphrase string   `json:"sshPassphrase,omitempty"`
 	Username      string   `json:"username,omitempty"`
 	Password      string   `json:"password,omitempty"` // or token
+	URL           string   `json:"url,omitempty"`      // Remote, for looking up stored HTTPS credentials
 }
 
 // DetectAuthType determines the authentication type from a URL
@@ -56,8 +62,41 @@ func GetAuth(config AuthConfig) (transport.AuthMethod, error) {
 		if config.SSHPassphrase != "" {
 			return getSSHAuthWithPassphrase(config.SSHKeyPath, config.SSHPassphrase)
 		}
-		return getSSHAuth(config.SSHKeyPath)
+		auth, err := getSSHAuth(config.SSHKeyPath)
+		if err != nil && strings.Contains(err.Error(), "requires passphrase") {
+			// Use the passphrase saved in the credential store
+			keyPath := config.SSHKeyPath
+			if keyPath == "" {
+				keyPath = findDefaultSSHKey()
+			}
+			if passphrase, ok := secrets.StoredSSHPassphrase(keyPath, keyPath == findDefaultSSHKey()); ok {
+				return getSSHAuthWithPassphrase(keyPath, passphrase)
+			}
+		}
+		return auth, err
 	case AuthTypeHTTPS:
+		if config.Password == "" && config.URL != "" {
+			// Fall back to a token from signing in to the host, then to the
+			// credential store, then to what the git credential helper has
+			// stored
+			if config.Username == "" {
+				if username, token, ok := oauth.Credential(context.Background(), config.URL); ok {
+					return getHTTPSAuth(username, token), nil
+				}
+			}
+			if username, token, ok := secrets.HTTPSCredential(config.URL, config.Username); ok {
+				return getHTTPSAuth(username, token), nil
+			}
+			cred, err := FillCredential(context.Background(), config.URL, config.Username)
+			if err != nil {
+				if config.Username == "" {
+					slog.Debug("No stored credentials, continuing without auth", "error", err)
+					return nil, nil
+				}
+				return nil, fmt.Errorf("no password given and %w", err)
+			}
+			return getHTTPSAuth(cred.Username, cred.Password), nil
+		}
 		return getHTTPSAuth(config.Username, config.Password), nil
 	case AuthTypeNone:
 		return nil, nil
@@ -66,6 +105,39 @@ func GetAuth(config AuthConfig) (transport.AuthMethod, error) {
 	}
 }
 
+// defaultAuth returns the auth to use for url when none was given: a
+// default SSH key for SSH remotes, or stored credentials for HTTPS ones.
+// Without either it returns nil, which works for public repos.
+func defaultAuth(url string) transport.AuthMethod {
+	authType := DetectAuthType(url)
+	if authType == AuthTypeNone {
+		return nil
+	}
+	auth, err := GetAuth(AuthConfig{Type: authType, URL: url})
+	if err != nil {
+		slog.Debug("No default auth available, continuing without auth", "error", err)
+		return nil
+	}
+	return auth
+}
+
+// remoteAuth returns the auth for talking to the remote at url: the given
+// config, or the default auth for it
+func remoteAuth(url string, authConfig *AuthConfig) (transport.AuthMethod, error) {
+	if authConfig == nil {
+		return defaultAuth(url), nil
+	}
+	config := *authConfig
+	if config.URL == "" {
+		config.URL = url
+	}
+	auth, err := GetAuth(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get auth: %w", err)
+	}
+	return auth, nil
+}
+
 // getSSHAuth returns SSH authentication using the specified key or default keys
 func getSSHAuth(keyPath string) (transport.AuthMethod, error) {
 	// If no key path specified, try default locations
@@ -147,6 +219,20 @@ func findDefaultSSHKey() string {
 	return ""
 }
 
+// CanAuthenticateSilently reports whether Fetch(nil) can authenticate to url
+// without asking for anything: a local or HTTP remote, or an SSH remote with
+// an unencrypted default key or an SSH agent. HTTP remotes that turn out to
+// need a password fail with transport.ErrAuthenticationRequired.
+func CanAuthenticateSilently(url string) bool {
+	if DetectAuthType(url) != AuthTypeSSH {
+		return true
+	}
+	if _, err := getSSHAuth(""); err == nil {
+		return true
+	}
+	return os.Getenv("SSH_AUTH_SOCK") != ""
+}
+
 // GetDefaultSSHKeyPath returns the path to the default SSH key if it exists
 func GetDefaultSSHKeyPath() string {
 	return findDefaultSSHKey()
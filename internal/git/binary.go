@@ -0,0 +1,108 @@
+package git
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/format/gitattributes"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// sniffLen is how much of a file is searched for a NUL byte to tell that it
+// is binary, as git itself does
+const sniffLen = 8000
+
+// binaryChecker tells binary files from text ones before they are diffed,
+// as building a patch of an image is slow and shows nothing useful
+type binaryChecker struct {
+	r     *Repository
+	attrs gitattributes.Matcher // From the commit's .gitattributes; nil without one
+}
+
+// newBinaryChecker reads the .gitattributes at the root of tree, if any
+func (r *Repository) newBinaryChecker(tree *object.Tree) *binaryChecker {
+	c := &binaryChecker{r: r}
+	if tree == nil {
+		return c
+	}
+	file, err := tree.File(".gitattributes")
+	if err != nil {
+		return c
+	}
+	reader, err := file.Reader()
+	if err != nil {
+		return c
+	}
+	defer reader.Close()
+
+	attrs, err := gitattributes.ReadAttributes(reader, nil, true)
+	if err != nil {
+		return c
+	}
+	c.attrs = gitattributes.NewMatcher(append(builtinMacros(), attrs...))
+	return c
+}
+
+// builtinMacros defines the binary macro git has built in
+func builtinMacros() []gitattributes.MatchAttribute {
+	m, err := gitattributes.ParseAttributesLine("[attr]binary -diff -merge -text", nil, true)
+	if err != nil {
+		return nil
+	}
+	return []gitattributes.MatchAttribute{m}
+}
+
+// inspect returns the sizes of both sides of a change, 0 for a missing
+// side, and whether either side is binary: marked so in .gitattributes, or
+// with a NUL byte near its start
+func (c *binaryChecker) inspect(change *object.Change) (oldSize, newSize int64, binary bool) {
+	for i, entry := range []object.ChangeEntry{change.From, change.To} {
+		if entry.Name == "" {
+			continue
+		}
+		if c.attrBinary(entry.Name) {
+			binary = true
+		}
+
+		blob, err := c.r.repo.BlobObject(entry.TreeEntry.Hash)
+		if err != nil {
+			continue
+		}
+		if i == 0 {
+			oldSize = blob.Size
+		} else {
+			newSize = blob.Size
+		}
+		if binary {
+			continue
+		}
+
+		reader, err := blob.Reader()
+		if err != nil {
+			continue
+		}
+		head := make([]byte, sniffLen)
+		n, _ := io.ReadFull(reader, head)
+		reader.Close()
+		if bytes.IndexByte(head[:n], 0) >= 0 {
+			binary = true
+		}
+	}
+	return oldSize, newSize, binary
+}
+
+// attrBinary reports whether .gitattributes says path isn't text to diff
+func (c *binaryChecker) attrBinary(path string) bool {
+	if c.attrs == nil {
+		return false
+	}
+	results, _ := c.attrs.Match(strings.Split(path, "/"), nil)
+	if attr, ok := results["binary"]; ok && attr.IsSet() {
+		return true
+	}
+	if attr, ok := results["diff"]; ok && attr.IsUnset() {
+		return true
+	}
+	return false
+}
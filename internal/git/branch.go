@@ -176,7 +176,7 @@ func (r *Repository) Checkout(name string) error {
 		return errors.New("repository not initialized")
 	}
 
-	wt, err := r.repo.Worktree()
+	wt, err := r.worktree()
 	if err != nil {
 		return fmt.Errorf("failed to get worktree: %w", err)
 	}
@@ -232,7 +232,7 @@ func (r *Repository) CheckoutCreate(name string) error {
 		return errors.New("repository not initialized")
 	}
 
-	wt, err := r.repo.Worktree()
+	wt, err := r.worktree()
 	if err != nil {
 		return fmt.Errorf("failed to get worktree: %w", err)
 	}
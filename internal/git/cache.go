@@ -0,0 +1,145 @@
+package git
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+)
+
+// DefaultHistoryCacheSize is the memory used to cache file contents and
+// diffs from history unless SetHistoryCacheSize is called
+const DefaultHistoryCacheSize = 32 << 20
+
+// CacheStats reports how the history cache is used
+type CacheStats struct {
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Evictions int64 `json:"evictions"`
+	Entries   int   `json:"entries"`
+	Bytes     int64 `json:"bytes"`
+	MaxBytes  int64 `json:"maxBytes"`
+}
+
+// history caches what GetFileAtCommit, GetDiff and GetFileDiff compute.
+// Commits never change, so entries only leave when the cache is full.
+// Cached results are shared by every caller and must not be modified.
+var history = newLRUCache(DefaultHistoryCacheSize)
+
+// SetHistoryCacheSize sets how many bytes of file contents and diffs from
+// history are kept in memory. Zero disables the cache.
+func SetHistoryCacheSize(n int64) {
+	history.resize(n)
+}
+
+// HistoryCacheStats returns the history cache's hit counts and size
+func HistoryCacheStats() CacheStats {
+	return history.stats()
+}
+
+// lruCache holds values up to a total size, evicting the least recently
+// used ones first
+type lruCache struct {
+	mu       sync.Mutex
+	maxBytes int64
+	order    *list.List // Most recently used at the front
+	items    map[string]*list.Element
+	counts   CacheStats
+}
+
+type cacheEntry struct {
+	key   string
+	value interface{}
+	size  int64
+}
+
+func newLRUCache(maxBytes int64) *lruCache {
+	return &lruCache{
+		maxBytes: maxBytes,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// get returns a cached value, marking it as recently used
+func (c *lruCache) get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		c.counts.Misses++
+		return nil, false
+	}
+	c.counts.Hits++
+	c.order.MoveToFront(el)
+	return el.Value.(*cacheEntry).value, true
+}
+
+// add caches a value. Values larger than the whole cache are not kept.
+func (c *lruCache) add(key string, value interface{}, size int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if size > c.maxBytes {
+		return
+	}
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+	c.items[key] = c.order.PushFront(&cacheEntry{key: key, value: value, size: size})
+	c.counts.Bytes += size
+	c.evict()
+}
+
+// resize changes the size limit, evicting entries that no longer fit
+func (c *lruCache) resize(maxBytes int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.maxBytes = maxBytes
+	c.evict()
+}
+
+func (c *lruCache) stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.counts
+	stats.Entries = len(c.items)
+	stats.MaxBytes = c.maxBytes
+	return stats
+}
+
+// evict drops the least recently used entries until the cache fits.
+// c.mu must be held.
+func (c *lruCache) evict() {
+	for c.counts.Bytes > c.maxBytes {
+		el := c.order.Back()
+		if el == nil {
+			return
+		}
+		c.remove(el)
+		c.counts.Evictions++
+	}
+}
+
+// remove drops an entry. c.mu must be held.
+func (c *lruCache) remove(el *list.Element) {
+	entry := c.order.Remove(el).(*cacheEntry)
+	delete(c.items, entry.key)
+	c.counts.Bytes -= entry.size
+}
+
+// cacheKey joins the parts identifying a cached result
+func cacheKey(parts ...string) string {
+	return strings.Join(parts, "\x00")
+}
+
+// diffSize estimates the memory a file diff takes
+func diffSize(d *FileDiff) int64 {
+	size := int64(64 + len(d.Path))
+	for _, line := range d.Lines {
+		size += int64(32 + len(line.Content))
+	}
+	return size
+}
@@ -3,6 +3,7 @@ package git
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
@@ -94,6 +95,9 @@ func (m *Manager) CloneWithProgress(ctx context.Context, opts CloneOptions, prog
 	}
 
 	// Get authentication
+	if opts.AuthConfig.URL == "" {
+		opts.AuthConfig.URL = opts.URL
+	}
 	auth, err := GetAuth(opts.AuthConfig)
 	if err != nil {
 		return nil, fmt.Errorf("authentication error: %w", err)
@@ -125,10 +129,12 @@ func (m *Manager) CloneWithProgress(ctx context.Context, opts CloneOptions, prog
 	}
 
 	// Perform clone
+	slog.Debug("Cloning repository", "url", opts.URL, "path", destPath)
 	repo, err := git.PlainCloneContext(ctx, destPath, false, cloneOpts)
 	if err != nil {
 		// Clean up on failure
 		os.RemoveAll(destPath)
+		slog.Debug("Clone failed", "url", opts.URL, "error", err)
 		return nil, fmt.Errorf("clone failed: %w", err)
 	}
 
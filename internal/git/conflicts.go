@@ -0,0 +1,128 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+)
+
+// Ways a conflicted file can be resolved
+const (
+	ResolveOurs   = "ours"   // Keep the version on the current branch
+	ResolveTheirs = "theirs" // Keep the version being merged in
+	ResolveCustom = "custom" // Keep content merged by hand
+)
+
+// ErrNotConflicted is returned when resolving a file that has no conflict
+var ErrNotConflicted = errors.New("file has no merge conflict")
+
+// Conflicts returns the files with unresolved merge conflicts, left by a
+// merge or rebase run with git itself, sorted by path
+func (r *Repository) Conflicts() ([]string, error) {
+	if r.repo == nil {
+		return nil, errors.New("repository not initialized")
+	}
+	idx, err := r.repo.Storer.Index()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index: %w", err)
+	}
+	return conflictedPaths(idx), nil
+}
+
+// conflictedPaths returns the paths with entries for the sides of a
+// conflict rather than a single merged one. go-git's index.Merged can't be
+// compared against, as it is defined as 1 rather than 0.
+func conflictedPaths(idx *index.Index) []string {
+	seen := make(map[string]bool)
+	var paths []string
+	for _, e := range idx.Entries {
+		if e.Stage != 0 && !seen[e.Name] {
+			seen[e.Name] = true
+			paths = append(paths, e.Name)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// ResolveConflict settles the conflict in a file by keeping our version,
+// theirs, or content merged by hand, and stages the result. Keeping the
+// side that deleted the file deletes it. The files still conflicted are
+// returned.
+func (r *Repository) ResolveConflict(path, strategy string, content []byte) ([]string, error) {
+	if r.repo == nil {
+		return nil, errors.New("repository not initialized")
+	}
+	path = filepath.ToSlash(filepath.Clean(path))
+
+	idx, err := r.repo.Storer.Index()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index: %w", err)
+	}
+	sides := make(map[index.Stage]*index.Entry)
+	for _, e := range idx.Entries {
+		if e.Name == path && e.Stage != 0 {
+			sides[e.Stage] = e
+		}
+	}
+	if len(sides) == 0 {
+		return nil, fmt.Errorf("%s: %w", path, ErrNotConflicted)
+	}
+
+	keep := true
+	switch strategy {
+	case ResolveOurs, ResolveTheirs:
+		stage := index.OurMode
+		if strategy == ResolveTheirs {
+			stage = index.TheirMode
+		}
+		entry, ok := sides[stage]
+		if !ok {
+			keep = false
+			break
+		}
+		text, err := r.blobContents(entry.Hash)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s version: %w", strategy, err)
+		}
+		content = []byte(text)
+	case ResolveCustom, "":
+	default:
+		return nil, fmt.Errorf("unknown strategy %q: use ours, theirs or custom", strategy)
+	}
+
+	full := filepath.Join(r.path, filepath.FromSlash(path))
+	if keep {
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create directory: %w", err)
+		}
+		if err := os.WriteFile(full, content, 0644); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", path, err)
+		}
+	} else if err := os.Remove(full); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to delete %s: %w", path, err)
+	}
+
+	// Drop the sides of the conflict, then stage the result in their place
+	entries := idx.Entries[:0]
+	for _, e := range idx.Entries {
+		if e.Name != path {
+			entries = append(entries, e)
+		}
+	}
+	idx.Entries = entries
+	if err := r.repo.Storer.SetIndex(idx); err != nil {
+		return nil, fmt.Errorf("failed to write index: %w", err)
+	}
+	if keep {
+		if err := r.Stage([]string{path}); err != nil {
+			return nil, err
+		}
+	}
+
+	return r.Conflicts()
+}
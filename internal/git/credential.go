@@ -0,0 +1,237 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	formatcfg "github.com/go-git/go-git/v5/plumbing/format/config"
+)
+
+// credentialTimeout bounds a credential helper, which may be waiting on
+// a keychain or a network call
+const credentialTimeout = 15 * time.Second
+
+// ErrNoCredentials is returned when no credential helper has credentials
+// for a remote
+var ErrNoCredentials = errors.New("no stored credentials")
+
+// Credential is what a git credential helper knows about a remote
+type Credential struct {
+	Username string
+	Password string
+}
+
+// FillCredential asks the git credential helpers configured for the user,
+// such as the ones gh or git-credential-manager install, for the
+// credentials of an HTTPS remote. It uses `git credential fill` when git is
+// installed, and otherwise runs the helpers in ~/.gitconfig itself. Helpers
+// are never allowed to prompt.
+func FillCredential(ctx context.Context, remoteURL, username string) (*Credential, error) {
+	u, err := url.Parse(remoteURL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("not an HTTP remote: %s", remoteURL)
+	}
+	if username == "" && u.User != nil {
+		username = u.User.Username()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, credentialTimeout)
+	defer cancel()
+
+	var cred *Credential
+	if path, lookErr := exec.LookPath("git"); lookErr == nil {
+		input := credentialInput(u, username, true)
+		cred, err = runCredential(exec.CommandContext(ctx, path, "credential", "fill"), input)
+	} else {
+		cred, err = fillFromGitConfig(ctx, u, username)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if cred.Password == "" {
+		return nil, ErrNoCredentials
+	}
+	return cred, nil
+}
+
+// credentialInput describes a remote in the format credential helpers read
+func credentialInput(u *url.URL, username string, withPath bool) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "protocol=%s\nhost=%s\n", u.Scheme, u.Host)
+	if withPath && u.Path != "" {
+		// git only passes this on when credential.useHttpPath is set
+		fmt.Fprintf(&b, "path=%s\n", strings.TrimPrefix(u.Path, "/"))
+	}
+	if username != "" {
+		fmt.Fprintf(&b, "username=%s\n", username)
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// runCredential runs a credential command and reads the username and
+// password it answers with
+func runCredential(cmd *exec.Cmd, input string) (*Credential, error) {
+	cmd.Stdin = strings.NewReader(input)
+	cmd.Env = append(os.Environ(),
+		"GIT_TERMINAL_PROMPT=0",
+		"GIT_ASKPASS=",
+		"SSH_ASKPASS=",
+		"GCM_INTERACTIVE=never",
+	)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		// With prompts disabled, git fails when no helper has an answer
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", ErrNoCredentials, msg)
+		}
+		return nil, fmt.Errorf("%w: %v", ErrNoCredentials, err)
+	}
+	return parseCredential(out), nil
+}
+
+// parseCredential reads the key=value lines a credential helper prints
+func parseCredential(out []byte) *Credential {
+	cred := &Credential{}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "username":
+			cred.Username = value
+		case "password":
+			cred.Password = value
+		}
+	}
+	return cred
+}
+
+// fillFromGitConfig runs the credential helpers ~/.gitconfig sets for a
+// remote in order, as git would, until one answers with a password
+func fillFromGitConfig(ctx context.Context, u *url.URL, username string) (*Credential, error) {
+	helpers, configured := gitConfigCredentials(u)
+	if username == "" {
+		username = configured
+	}
+
+	for _, helper := range helpers {
+		cred, err := runCredential(shellCommand(ctx, helperCommand(helper)+" get"), credentialInput(u, username, false))
+		if err != nil || cred.Password == "" {
+			continue
+		}
+		if cred.Username == "" {
+			cred.Username = username
+		}
+		return cred, nil
+	}
+	return nil, ErrNoCredentials
+}
+
+// gitConfigCredentials reads the credential helpers and username that the
+// user's git config sets for a remote. URL-specific sections apply after
+// the general one, and an empty helper clears the ones before it.
+func gitConfigCredentials(u *url.URL) (helpers []string, username string) {
+	for _, path := range gitConfigPaths() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		cfg := formatcfg.New()
+		if err := formatcfg.NewDecoder(bytes.NewReader(data)).Decode(cfg); err != nil {
+			continue
+		}
+		if !cfg.HasSection("credential") {
+			continue
+		}
+		section := cfg.Section("credential")
+
+		apply := func(values []string, user string) {
+			for _, v := range values {
+				if v == "" {
+					helpers = nil
+				} else {
+					helpers = append(helpers, v)
+				}
+			}
+			if user != "" {
+				username = user
+			}
+		}
+		apply(section.OptionAll("helper"), section.Option("username"))
+		for _, sub := range section.Subsections {
+			if credentialMatches(sub.Name, u) {
+				apply(sub.OptionAll("helper"), sub.Option("username"))
+			}
+		}
+	}
+	return helpers, username
+}
+
+// gitConfigPaths lists the user's git config files, XDG first, in the
+// order git reads them
+func gitConfigPaths() []string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+	xdg := os.Getenv("XDG_CONFIG_HOME")
+	if xdg == "" {
+		xdg = filepath.Join(home, ".config")
+	}
+	return []string{filepath.Join(xdg, "git", "config"), filepath.Join(home, ".gitconfig")}
+}
+
+// credentialMatches reports whether a credential.<url> section applies to
+// a remote: same scheme and host, and a path the remote's starts with
+func credentialMatches(pattern string, u *url.URL) bool {
+	p, err := url.Parse(pattern)
+	if err != nil {
+		return false
+	}
+	if p.Scheme != "" && !strings.EqualFold(p.Scheme, u.Scheme) {
+		return false
+	}
+	if p.Host != "" && !strings.EqualFold(p.Host, u.Host) {
+		return false
+	}
+	prefix := strings.Trim(p.Path, "/")
+	return prefix == "" || strings.HasPrefix(strings.Trim(u.Path, "/")+"/", prefix+"/")
+}
+
+// helperCommand turns a credential.helper value into a shell command: a
+// "!" value is a shell snippet, an absolute path is run as it is and
+// anything else names a git-credential-<name> program
+func helperCommand(helper string) string {
+	switch {
+	case strings.HasPrefix(helper, "!"):
+		return helper[1:]
+	case filepath.IsAbs(helper):
+		return helper
+	default:
+		return "git-credential-" + helper
+	}
+}
+
+// shellCommand builds a command run through the platform shell
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
@@ -1,11 +1,29 @@
 package git
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	gogit "github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/storage/memory"
 )
 
 // Helper to create a temporary directory
@@ -1152,3 +1170,1516 @@ func TestRenameBranch(t *testing.T) {
 		t.Error("Branch 'new-name' should exist")
 	}
 }
+
+// TestCommitConfigIdentity tests the author falls back to the git config
+func TestCommitConfigIdentity(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	dir := t.TempDir()
+
+	repo, err := Init(dir)
+	if err != nil {
+		t.Fatalf("Failed to init repo: %v", err)
+	}
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0644)
+	repo.Stage([]string{"a.txt"})
+
+	commit, err := repo.Commit(CommitOptions{Message: "No config"})
+	if err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if commit.Author != DefaultAuthorName || commit.Email != DefaultAuthorEmail {
+		t.Errorf("Expected fallback author, got %s <%s>", commit.Author, commit.Email)
+	}
+
+	cfg, err := repo.repo.Config()
+	if err != nil {
+		t.Fatalf("Config failed: %v", err)
+	}
+	cfg.User.Name = "Config User"
+	cfg.User.Email = "config@example.com"
+	if err := repo.repo.SetConfig(cfg); err != nil {
+		t.Fatalf("SetConfig failed: %v", err)
+	}
+
+	os.WriteFile(filepath.Join(dir, "b.txt"), []byte("b"), 0644)
+	repo.Stage([]string{"b.txt"})
+	commit, err = repo.Commit(CommitOptions{Message: "With config", AuthorEmail: "given@example.com"})
+	if err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if commit.Author != "Config User" || commit.Email != "given@example.com" {
+		t.Errorf("Expected Config User <given@example.com>, got %s <%s>", commit.Author, commit.Email)
+	}
+}
+
+// TestSignedCommit tests commits are signed with a loaded key
+func TestSignedCommit(t *testing.T) {
+	dir := t.TempDir()
+
+	entity, err := openpgp.NewEntity("Test User", "", "test@example.com", nil)
+	if err != nil {
+		t.Fatalf("NewEntity failed: %v", err)
+	}
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PrivateKeyType, nil)
+	if err != nil {
+		t.Fatalf("armor.Encode failed: %v", err)
+	}
+	if err := entity.SerializePrivate(w, nil); err != nil {
+		t.Fatalf("SerializePrivate failed: %v", err)
+	}
+	w.Close()
+
+	keyPath := filepath.Join(t.TempDir(), "key.asc")
+	os.WriteFile(keyPath, buf.Bytes(), 0600)
+	key, err := LoadSigningKey(keyPath)
+	if err != nil {
+		t.Fatalf("LoadSigningKey failed: %v", err)
+	}
+
+	repo, err := Init(dir)
+	if err != nil {
+		t.Fatalf("Failed to init repo: %v", err)
+	}
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0644)
+	repo.Stage([]string{"a.txt"})
+
+	commit, err := repo.Commit(CommitOptions{Message: "Signed", SignKey: key})
+	if err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	obj, err := repo.repo.CommitObject(plumbing.NewHash(commit.Hash))
+	if err != nil {
+		t.Fatalf("CommitObject failed: %v", err)
+	}
+	if obj.PGPSignature == "" {
+		t.Fatal("Expected commit to be signed")
+	}
+	if _, err := obj.Verify(buf.String()); err != nil {
+		t.Errorf("Signature did not verify: %v", err)
+	}
+
+	if _, err := LoadSigningKey(filepath.Join(dir, "a.txt")); err == nil {
+		t.Error("Expected error loading a file that is not a key")
+	}
+}
+
+// TestMigrateRepos tests clones are moved to a new repos directory
+func TestMigrateRepos(t *testing.T) {
+	from := t.TempDir()
+	to := filepath.Join(t.TempDir(), "repos")
+
+	if _, err := Init(filepath.Join(from, "notes")); err != nil {
+		t.Fatalf("Failed to init repo: %v", err)
+	}
+	if _, err := Init(filepath.Join(from, "taken")); err != nil {
+		t.Fatalf("Failed to init repo: %v", err)
+	}
+	os.MkdirAll(filepath.Join(from, "plain"), 0755)
+	os.MkdirAll(filepath.Join(to, "taken"), 0755)
+
+	moved, err := MigrateRepos(from, to)
+	if err != nil {
+		t.Fatalf("MigrateRepos failed: %v", err)
+	}
+	if len(moved) != 1 || moved[0] != filepath.Join(to, "notes") {
+		t.Fatalf("Expected notes to be moved, got %v", moved)
+	}
+	if !IsGitRepository(filepath.Join(to, "notes")) {
+		t.Error("Moved repository cannot be opened")
+	}
+	if !IsGitRepository(filepath.Join(from, "taken")) {
+		t.Error("Repository whose name was taken should stay in place")
+	}
+	if _, err := os.Stat(filepath.Join(from, "plain")); err != nil {
+		t.Error("Directories that are not repositories should stay in place")
+	}
+}
+
+func TestLRUCache(t *testing.T) {
+	c := newLRUCache(10)
+	c.add("a", "aaaa", 4)
+	c.add("b", "bbbb", 4)
+	c.get("a")
+	c.add("c", "cccc", 4) // Evicts b, the least recently used
+
+	if _, ok := c.get("b"); ok {
+		t.Error("Expected b to be evicted")
+	}
+	if v, ok := c.get("a"); !ok || v.(string) != "aaaa" {
+		t.Errorf("Expected a to stay cached, got %v, %v", v, ok)
+	}
+
+	c.add("big", "x", 11)
+	if _, ok := c.get("big"); ok {
+		t.Error("Expected a value larger than the cache not to be kept")
+	}
+
+	stats := c.stats()
+	if stats.Entries != 2 || stats.Bytes != 8 || stats.Evictions != 1 || stats.Hits != 2 || stats.Misses != 2 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	c.resize(0)
+	if stats := c.stats(); stats.Entries != 0 || stats.Bytes != 0 {
+		t.Errorf("Expected resizing to zero to empty the cache, got %+v", stats)
+	}
+}
+
+func TestHistoryCache(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	repo, err := Init(dir)
+	if err != nil {
+		t.Fatalf("Failed to init repo: %v", err)
+	}
+
+	var hashes []string
+	for _, content := range []string{"one\n", "one\ntwo\n"} {
+		if err := os.WriteFile(filepath.Join(dir, "note.md"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		commit, err := repo.Commit(CommitOptions{Message: "Update", Files: []string{"note.md"}})
+		if err != nil {
+			t.Fatalf("Commit failed: %v", err)
+		}
+		hashes = append(hashes, commit.Hash)
+	}
+
+	before := HistoryCacheStats()
+	for i := 0; i < 2; i++ {
+		content, err := repo.GetFileAtCommit(hashes[0], "note.md")
+		if err != nil || content != "one\n" {
+			t.Fatalf("GetFileAtCommit = %q, %v", content, err)
+		}
+		diff, err := repo.GetDiff(hashes[0], hashes[1])
+		if err != nil || len(diff.Files) != 1 || diff.Files[0].Additions != 1 {
+			t.Fatalf("GetDiff = %+v, %v", diff, err)
+		}
+	}
+
+	after := HistoryCacheStats()
+	if hits := after.Hits - before.Hits; hits != 2 {
+		t.Errorf("Expected the second round to be served from the cache, got %d hits", hits)
+	}
+}
+
+func TestWalkHistoryAndDiff(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	repo, err := Init(dir)
+	if err != nil {
+		t.Fatalf("Failed to init repo: %v", err)
+	}
+
+	var hashes []string
+	for i, name := range []string{"a.md", "b.md", "c.md"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(strings.Repeat("line\n", i+1)), 0644); err != nil {
+			t.Fatal(err)
+		}
+		commit, err := repo.Commit(CommitOptions{Message: "Add " + name, Files: []string{name}})
+		if err != nil {
+			t.Fatalf("Commit failed: %v", err)
+		}
+		hashes = append(hashes, commit.Hash)
+	}
+
+	var walked []string
+	if err := repo.WalkHistory(0, 0, "", func(c Commit) error {
+		walked = append(walked, c.Hash)
+		return nil
+	}); err != nil {
+		t.Fatalf("WalkHistory failed: %v", err)
+	}
+	if len(walked) != 3 || walked[0] != hashes[2] {
+		t.Errorf("Expected 3 commits newest first, got %v", walked)
+	}
+
+	// An error from the callback stops the walk
+	stop := errors.New("stop")
+	calls := 0
+	err = repo.WalkHistory(0, 0, "", func(Commit) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("Expected the walk to stop after one commit, got %d calls and %v", calls, err)
+	}
+
+	var files []string
+	if err := repo.WalkDiff(hashes[0], hashes[2], DefaultDiffLimits, func(d *FileDiff) error {
+		files = append(files, d.Path)
+		return nil
+	}); err != nil {
+		t.Fatalf("WalkDiff failed: %v", err)
+	}
+	if want := []string{"b.md", "c.md"}; !reflect.DeepEqual(files, want) {
+		t.Errorf("Expected diff of %v, got %v", want, files)
+	}
+}
+
+func TestDiffLimits(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	repo, err := Init(dir)
+	if err != nil {
+		t.Fatalf("Failed to init repo: %v", err)
+	}
+
+	var hashes []string
+	for _, n := range []int{1, 100} {
+		for _, name := range []string{"a.md", "b.md"} {
+			content := strings.Repeat(name+"\n", n)
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		commit, err := repo.Commit(CommitOptions{Message: "Update", Files: []string{"a.md", "b.md"}})
+		if err != nil {
+			t.Fatalf("Commit failed: %v", err)
+		}
+		hashes = append(hashes, commit.Hash)
+	}
+
+	// Each file is cut at 30 lines, and b.md gets only what is left of 50
+	diff, err := repo.GetDiffWithLimits(hashes[0], hashes[1], DiffLimits{FileLines: 30, TotalLines: 50})
+	if err != nil {
+		t.Fatalf("GetDiffWithLimits failed: %v", err)
+	}
+	if !diff.Truncated || len(diff.Files) != 2 {
+		t.Fatalf("Expected a truncated diff of 2 files, got %+v", diff)
+	}
+	a, b := diff.Files[0], diff.Files[1]
+	if !a.Truncated || len(a.Lines) != 30 || a.Additions != 99 {
+		t.Errorf("a.md: expected 30 lines of 99 additions, got %d lines, %d additions", len(a.Lines), a.Additions)
+	}
+	if !b.Truncated || len(b.Lines) != 20 {
+		t.Errorf("b.md: expected 20 lines, got %d", len(b.Lines))
+	}
+
+	// Files over the size limit aren't diffed at all
+	fileDiff, err := repo.GetFileDiffWithLimits(hashes[0], hashes[1], "a.md", DiffLimits{FileBytes: 100})
+	if err != nil {
+		t.Fatalf("GetFileDiffWithLimits failed: %v", err)
+	}
+	if !fileDiff.Truncated || len(fileDiff.Lines) != 0 {
+		t.Errorf("Expected no lines for a file over the size limit, got %d", len(fileDiff.Lines))
+	}
+
+	full, err := repo.GetDiffWithLimits(hashes[0], hashes[1], DiffLimits{})
+	if err != nil {
+		t.Fatalf("GetDiffWithLimits failed: %v", err)
+	}
+	if full.Truncated || len(full.Files[0].Lines) != 100 {
+		t.Errorf("Expected the full diff of 100 lines, got %d, truncated %v", len(full.Files[0].Lines), full.Truncated)
+	}
+}
+
+func TestDiffBinaryFiles(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	repo, err := Init(dir)
+	if err != nil {
+		t.Fatalf("Failed to init repo: %v", err)
+	}
+
+	versions := []map[string]string{
+		{".gitattributes": "*.dat binary\n", "data.dat": "text\n", "image.png": "\x89PNG\x00\x01", "note.md": "one\n"},
+		{"data.dat": "more text\n", "image.png": "\x89PNG\x00\x01\x02\x03", "note.md": "one\ntwo\n"},
+	}
+	var hashes []string
+	for _, files := range versions {
+		var names []string
+		for name, content := range files {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			names = append(names, name)
+		}
+		commit, err := repo.Commit(CommitOptions{Message: "Update", Files: names})
+		if err != nil {
+			t.Fatalf("Commit failed: %v", err)
+		}
+		hashes = append(hashes, commit.Hash)
+	}
+
+	diff, err := repo.GetDiff(hashes[0], hashes[1])
+	if err != nil {
+		t.Fatalf("GetDiff failed: %v", err)
+	}
+	files := make(map[string]FileDiff)
+	for _, f := range diff.Files {
+		files[f.Path] = f
+	}
+
+	if f := files["data.dat"]; !f.Binary || len(f.Lines) != 0 {
+		t.Errorf("Expected data.dat to be binary by .gitattributes, got %+v", f)
+	}
+	if f := files["image.png"]; !f.Binary || f.OldSize != 6 || f.NewSize != 8 {
+		t.Errorf("Expected image.png to be binary, 6 to 8 bytes, got %+v", f)
+	}
+	if f := files["note.md"]; f.Binary || f.Additions != 1 {
+		t.Errorf("Expected note.md to be a text diff, got %+v", f)
+	}
+
+	detail, err := repo.GetCommit(hashes[1])
+	if err != nil {
+		t.Fatalf("GetCommit failed: %v", err)
+	}
+	for _, c := range detail.Changes {
+		if c.Binary != (c.Path != "note.md") {
+			t.Errorf("%s: unexpected binary %v", c.Path, c.Binary)
+		}
+	}
+}
+
+func TestPublish(t *testing.T) {
+	remoteDir := t.TempDir()
+	if _, err := gogit.PlainInit(remoteDir, true); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	repo, err := Init(dir)
+	if err != nil {
+		t.Fatalf("Failed to init repo: %v", err)
+	}
+	if _, err := repo.repo.CreateRemote(&gitconfig.RemoteConfig{Name: "origin", URLs: []string{remoteDir}}); err != nil {
+		t.Fatal(err)
+	}
+
+	site := t.TempDir()
+	os.MkdirAll(filepath.Join(site, "notes"), 0755)
+	os.WriteFile(filepath.Join(site, "index.html"), []byte("<h1>Home</h1>"), 0644)
+	os.WriteFile(filepath.Join(site, "notes", "a.html"), []byte("<h1>A</h1>"), 0644)
+
+	opts := PublishOptions{Dir: site, AuthorName: "Test User", AuthorEmail: "test@example.com"}
+	first, err := repo.Publish(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	if first.Branch != "gh-pages" || first.Commit == "" || first.Files != 2 {
+		t.Fatalf("Unexpected result: %+v", first)
+	}
+
+	remote, err := gogit.PlainOpen(remoteDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	published := func() *object.Commit {
+		ref, err := remote.Reference(plumbing.NewBranchReferenceName("gh-pages"), true)
+		if err != nil {
+			t.Fatalf("Expected gh-pages on the remote: %v", err)
+		}
+		commit, err := remote.CommitObject(ref.Hash())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return commit
+	}
+	commit := published()
+	for _, name := range []string{"index.html", "notes/a.html", ".nojekyll"} {
+		if _, err := commit.File(name); err != nil {
+			t.Errorf("Expected %s published: %v", name, err)
+		}
+	}
+	if _, err := repo.repo.Head(); err == nil {
+		t.Error("Expected the current branch left alone")
+	}
+
+	again, err := repo.Publish(context.Background(), opts)
+	if err != nil || again.Commit != "" || again.Message != "Already up to date" {
+		t.Errorf("Expected nothing published twice, got %+v, %v", again, err)
+	}
+
+	os.Remove(filepath.Join(site, "notes", "a.html"))
+	os.WriteFile(filepath.Join(site, "index.html"), []byte("<h1>Home v2</h1>"), 0644)
+	second, err := repo.Publish(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	commit = published()
+	if commit.Hash.String() != second.Commit || len(commit.ParentHashes) != 1 || commit.ParentHashes[0].String() != first.Commit {
+		t.Errorf("Expected a commit on top of the first, got %s with parents %v", commit.Hash, commit.ParentHashes)
+	}
+	if _, err := commit.File("notes/a.html"); err == nil {
+		t.Error("Expected removed pages gone from the branch")
+	}
+}
+
+func TestStagedDiff(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := Init(dir)
+	if err != nil {
+		t.Fatalf("Failed to init repo: %v", err)
+	}
+
+	var lines []string
+	for i := 1; i <= 20; i++ {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+	os.WriteFile(filepath.Join(dir, "notes.md"), []byte(strings.Join(lines, "\n")+"\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "gone.md"), []byte("bye\n"), 0644)
+	repo.StageAll()
+	if _, err := repo.Commit(CommitOptions{Message: "First", AuthorName: "Test User", AuthorEmail: "test@example.com"}); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	lines[9] = "line ten"
+	os.WriteFile(filepath.Join(dir, "notes.md"), []byte(strings.Join(lines, "\n")+"\n"), 0644)
+	os.Remove(filepath.Join(dir, "gone.md"))
+	os.WriteFile(filepath.Join(dir, "new.md"), []byte("hello\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "unstaged.md"), []byte("not yet\n"), 0644)
+	if err := repo.StageAll(); err != nil {
+		t.Fatal(err)
+	}
+	repo.Unstage([]string{"unstaged.md"})
+
+	diff, err := repo.StagedDiff(0)
+	if err != nil {
+		t.Fatalf("StagedDiff failed: %v", err)
+	}
+	for _, want := range []string{
+		"--- a/gone.md\n+++ /dev/null\n-bye\n",
+		"--- /dev/null\n+++ b/new.md\n+hello\n",
+		" line 9\n-line 10\n+line ten\n line 11\n",
+	} {
+		if !strings.Contains(diff, want) {
+			t.Errorf("Expected %q in diff:\n%s", want, diff)
+		}
+	}
+	if strings.Contains(diff, "line 2\n") || strings.Contains(diff, "unstaged.md") {
+		t.Errorf("Expected only staged changes with little context:\n%s", diff)
+	}
+
+	if short, _ := repo.StagedDiff(40); len(short) > 60 || !strings.HasSuffix(short, "[diff truncated]\n") {
+		t.Errorf("Expected a truncated diff, got %q", short)
+	}
+}
+
+func TestWorkingDiff(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := Init(dir)
+	if err != nil {
+		t.Fatalf("Failed to init repo: %v", err)
+	}
+
+	os.WriteFile(filepath.Join(dir, "notes.md"), []byte("one\ntwo\nthree\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "gone.md"), []byte("bye\n"), 0644)
+	repo.StageAll()
+	if _, err := repo.Commit(CommitOptions{Message: "First", AuthorName: "Test User", AuthorEmail: "test@example.com"}); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	os.WriteFile(filepath.Join(dir, "notes.md"), []byte("one\n2\nthree\n"), 0644)
+	os.Remove(filepath.Join(dir, "gone.md"))
+	if err := repo.StageAll(); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(dir, "notes.md"), []byte("one\n2\nthree\nfour\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "new.md"), []byte("hello\n"), 0644)
+
+	staged, err := repo.GetWorkingDiff("", true)
+	if err != nil {
+		t.Fatalf("GetWorkingDiff failed: %v", err)
+	}
+	if len(staged) != 2 || staged[0].Path != "gone.md" || staged[0].Action != "deleted" || staged[1].Path != "notes.md" {
+		t.Fatalf("Unexpected staged diff: %+v", staged)
+	}
+	if notes := staged[1]; notes.Additions != 1 || notes.Deletions != 1 || notes.Lines[1] != (DiffLine{Type: "delete", Content: "two", OldLine: 2}) {
+		t.Errorf("Unexpected staged lines: %+v", notes)
+	}
+
+	unstaged, err := repo.GetWorkingDiff("", false)
+	if err != nil {
+		t.Fatalf("GetWorkingDiff failed: %v", err)
+	}
+	if len(unstaged) != 2 || unstaged[0].Path != "new.md" || unstaged[0].Action != "added" || unstaged[1].Path != "notes.md" {
+		t.Fatalf("Unexpected unstaged diff: %+v", unstaged)
+	}
+	if notes := unstaged[1]; notes.Additions != 1 || notes.Deletions != 0 || notes.Lines[3] != (DiffLine{Type: "add", Content: "four", NewLine: 4}) {
+		t.Errorf("Unexpected unstaged lines: %+v", notes)
+	}
+
+	only, err := repo.GetWorkingDiff("new.md", false)
+	if err != nil || len(only) != 1 || only[0].Path != "new.md" {
+		t.Errorf("Expected only new.md, got %+v, %v", only, err)
+	}
+	if short, _ := repo.GetWorkingDiffWithLimits("notes.md", false, DiffLimits{FileLines: 2}); len(short) != 1 || !short[0].Truncated || len(short[0].Lines) != 2 {
+		t.Errorf("Expected a truncated diff, got %+v", short)
+	}
+}
+
+func TestResolveConflict(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := Init(dir)
+	if err != nil {
+		t.Fatalf("Failed to init repo: %v", err)
+	}
+	for _, name := range []string{"plan.md", "todo.md", "gone.md"} {
+		os.WriteFile(filepath.Join(dir, name), []byte("base\n"), 0644)
+	}
+	repo.StageAll()
+	if _, err := repo.Commit(CommitOptions{Message: "First", AuthorName: "Test User", AuthorEmail: "test@example.com"}); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	// Leave the index as a merge with conflicts would, each file having an
+	// entry for the base, our and their version
+	blob := func(content string) plumbing.Hash {
+		obj := repo.repo.Storer.NewEncodedObject()
+		obj.SetType(plumbing.BlobObject)
+		w, _ := obj.Writer()
+		w.Write([]byte(content))
+		w.Close()
+		hash, err := repo.repo.Storer.SetEncodedObject(obj)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hash
+	}
+	idx, _ := repo.repo.Storer.Index()
+	idx.Entries = nil
+	for _, name := range []string{"gone.md", "plan.md", "todo.md"} {
+		idx.Entries = append(idx.Entries,
+			&index.Entry{Name: name, Hash: blob("base\n"), Mode: filemode.Regular, Stage: index.AncestorMode},
+			&index.Entry{Name: name, Hash: blob("ours " + name + "\n"), Mode: filemode.Regular, Stage: index.OurMode},
+		)
+		if name != "gone.md" {
+			idx.Entries = append(idx.Entries, &index.Entry{Name: name, Hash: blob("theirs " + name + "\n"), Mode: filemode.Regular, Stage: index.TheirMode})
+		}
+	}
+	repo.repo.Storer.SetIndex(idx)
+
+	status, err := repo.Status()
+	if err != nil || !status.HasConflicts {
+		t.Fatalf("Expected conflicts in status: %+v, %v", status, err)
+	}
+
+	remaining, err := repo.ResolveConflict("plan.md", ResolveTheirs, nil)
+	if err != nil {
+		t.Fatalf("ResolveConflict failed: %v", err)
+	}
+	if !reflect.DeepEqual(remaining, []string{"gone.md", "todo.md"}) {
+		t.Errorf("Remaining conflicts = %v", remaining)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "plan.md")); string(data) != "theirs plan.md\n" {
+		t.Errorf("Expected their version written, got %q", data)
+	}
+
+	if _, err := repo.ResolveConflict("todo.md", ResolveCustom, []byte("merged\n")); err != nil {
+		t.Fatalf("ResolveConflict failed: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "todo.md")); string(data) != "merged\n" {
+		t.Errorf("Expected the merged content written, got %q", data)
+	}
+
+	// Their side deleted gone.md
+	remaining, err = repo.ResolveConflict("gone.md", ResolveTheirs, nil)
+	if err != nil || len(remaining) != 0 {
+		t.Fatalf("Expected no conflicts left, got %v, %v", remaining, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "gone.md")); !os.IsNotExist(err) {
+		t.Errorf("Expected gone.md deleted, got %v", err)
+	}
+
+	if _, err := repo.ResolveConflict("plan.md", ResolveOurs, nil); !errors.Is(err, ErrNotConflicted) {
+		t.Errorf("Expected ErrNotConflicted, got %v", err)
+	}
+
+	staged, _ := repo.GetWorkingDiff("", true)
+	var summary []string
+	for _, f := range staged {
+		summary = append(summary, f.Path+" "+f.Action)
+	}
+	if !reflect.DeepEqual(summary, []string{"gone.md deleted", "plan.md modified", "todo.md modified"}) {
+		t.Errorf("Expected the resolutions staged, got %v", summary)
+	}
+}
+
+func TestTags(t *testing.T) {
+	remoteDir := t.TempDir()
+	if _, err := gogit.PlainInit(remoteDir, true); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	repo, err := Init(dir)
+	if err != nil {
+		t.Fatalf("Failed to init repo: %v", err)
+	}
+	if _, err := repo.repo.CreateRemote(&gitconfig.RemoteConfig{Name: "origin", URLs: []string{remoteDir}}); err != nil {
+		t.Fatal(err)
+	}
+
+	var hashes []string
+	for i, content := range []string{"first", "second"} {
+		os.WriteFile(filepath.Join(dir, "notes.md"), []byte(content), 0644)
+		if err := repo.Stage([]string{"notes.md"}); err != nil {
+			t.Fatalf("Stage failed: %v", err)
+		}
+		commit, err := repo.Commit(CommitOptions{Message: fmt.Sprintf("Commit %d", i+1)})
+		if err != nil {
+			t.Fatalf("Commit failed: %v", err)
+		}
+		hashes = append(hashes, commit.Hash)
+	}
+
+	if _, err := repo.CreateTag(TagOptions{Name: "draft", Target: hashes[0]}); err != nil {
+		t.Fatalf("CreateTag failed: %v", err)
+	}
+	release, err := repo.CreateTag(TagOptions{
+		Name:        "v1.0",
+		Message:     "Published version\n",
+		TaggerName:  "Test User",
+		TaggerEmail: "test@example.com",
+	})
+	if err != nil {
+		t.Fatalf("CreateTag failed: %v", err)
+	}
+	if !release.Annotated || release.Hash != hashes[1] || release.Message != "Published version" || release.Tagger != "Test User <test@example.com>" {
+		t.Errorf("Unexpected annotated tag: %+v", release)
+	}
+	if _, err := repo.CreateTag(TagOptions{Name: "v1.0"}); err == nil {
+		t.Error("Expected creating an existing tag to fail")
+	}
+	if _, err := repo.CreateTag(TagOptions{Name: "bad name"}); err == nil {
+		t.Error("Expected an invalid tag name to fail")
+	}
+
+	tags, err := repo.ListTags()
+	if err != nil {
+		t.Fatalf("ListTags failed: %v", err)
+	}
+	if len(tags) != 2 {
+		t.Fatalf("Expected 2 tags, got %+v", tags)
+	}
+	for _, tag := range tags {
+		if tag.Name == "draft" && (tag.Annotated || tag.Hash != hashes[0] || tag.Subject != "Commit 1") {
+			t.Errorf("Unexpected lightweight tag: %+v", tag)
+		}
+	}
+
+	if _, err := repo.PushTags([]string{"v1.0"}, nil); err != nil {
+		t.Fatalf("PushTags failed: %v", err)
+	}
+	remote, err := gogit.PlainOpen(remoteDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := remote.Tag("v1.0"); err != nil {
+		t.Errorf("Expected v1.0 on the remote: %v", err)
+	}
+	if _, err := remote.Tag("draft"); err == nil {
+		t.Error("Expected only the named tag pushed")
+	}
+	if _, err := repo.PushTags(nil, nil); err != nil {
+		t.Fatalf("PushTags failed: %v", err)
+	}
+	if _, err := remote.Tag("draft"); err != nil {
+		t.Errorf("Expected every tag pushed: %v", err)
+	}
+
+	if err := repo.DeleteTag("draft"); err != nil {
+		t.Fatalf("DeleteTag failed: %v", err)
+	}
+	if err := repo.DeleteTag("draft"); err == nil {
+		t.Error("Expected deleting a missing tag to fail")
+	}
+	if tags, _ := repo.ListTags(); len(tags) != 1 {
+		t.Errorf("Expected one tag left, got %+v", tags)
+	}
+}
+
+func TestCheckCommitSize(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := Init(dir)
+	if err != nil {
+		t.Fatalf("Failed to init repo: %v", err)
+	}
+
+	os.WriteFile(filepath.Join(dir, "notes.md"), []byte("# Notes\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "big.pdf"), bytes.Repeat([]byte{1}, 3000), 0644)
+	os.WriteFile(filepath.Join(dir, "clip.mov"), bytes.Repeat([]byte{1}, lfsMinSize), 0644)
+	os.WriteFile(filepath.Join(dir, "small.zip"), []byte("PK"), 0644)
+	if err := repo.Stage([]string{"big.pdf"}); err != nil {
+		t.Fatalf("Stage failed: %v", err)
+	}
+
+	limits := SizeLimits{MaxFileSize: 2 << 20, MaxCommitSize: 2 << 20}
+
+	// Only what is staged counts, unless more is about to be
+	warnings, err := repo.CheckCommitSize(nil, false, SizeLimits{MaxFileSize: 1000})
+	if err != nil {
+		t.Fatalf("CheckCommitSize failed: %v", err)
+	}
+	if len(warnings) != 1 || warnings[0].Path != "big.pdf" || warnings[0].Reason != SizeReasonLarge {
+		t.Errorf("Expected big.pdf over the limit, got %+v", warnings)
+	}
+
+	warnings, err = repo.CheckCommitSize([]string{"clip.mov", "small.zip"}, false, limits)
+	if err != nil {
+		t.Fatalf("CheckCommitSize failed: %v", err)
+	}
+	if len(warnings) != 1 || warnings[0].Path != "clip.mov" || warnings[0].Reason != SizeReasonLFS {
+		t.Errorf("Expected clip.mov suggested for LFS, got %+v", warnings)
+	}
+
+	limits.MaxCommitSize = lfsMinSize
+	warnings, err = repo.CheckCommitSize(nil, true, limits)
+	if err != nil {
+		t.Fatalf("CheckCommitSize failed: %v", err)
+	}
+	if len(warnings) != 2 || warnings[1].Reason != SizeReasonCommit || warnings[1].Path != "" {
+		t.Errorf("Expected clip.mov and the commit total, got %+v", warnings)
+	}
+}
+
+func TestMergeText(t *testing.T) {
+	base := "one\ntwo\nthree\nfour\nfive\n"
+	tests := []struct {
+		name, ours, theirs, want string
+		conflicted               bool
+	}{
+		{"separate changes", "ONE\ntwo\nthree\nfour\nfive\n", "one\ntwo\nthree\nfour\nFIVE\n", "ONE\ntwo\nthree\nfour\nFIVE\n", false},
+		{"same change", "one\nTWO\nthree\nfour\nfive\n", "one\nTWO\nthree\nfour\nfive\n", "one\nTWO\nthree\nfour\nfive\n", false},
+		{"insert and delete", "zero\none\ntwo\nthree\nfour\nfive\n", "one\ntwo\nfour\nfive\n", "zero\none\ntwo\nfour\nfive\n", false},
+		{"clash", "one\ntwo\n3\nfour\nfive\n", "one\ntwo\nTHREE\nfour\nfive\n",
+			"one\ntwo\n<<<<<<< HEAD\n3\n=======\nTHREE\n>>>>>>> theirs\nfour\nfive\n", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, conflicted := mergeText(base, tt.ours, tt.theirs, "HEAD", "theirs")
+			if got != tt.want || conflicted != tt.conflicted {
+				t.Errorf("mergeText = %q, %v; want %q, %v", got, conflicted, tt.want, tt.conflicted)
+			}
+		})
+	}
+}
+
+func TestRevertCherryPick(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := Init(dir)
+	if err != nil {
+		t.Fatalf("Failed to init repo: %v", err)
+	}
+	commit := func(message string, files map[string]string) *Commit {
+		t.Helper()
+		for name, content := range files {
+			if content == "" {
+				os.Remove(filepath.Join(dir, name))
+			} else {
+				os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+			}
+		}
+		repo.StageAll()
+		c, err := repo.Commit(CommitOptions{Message: message, AuthorName: "Test User", AuthorEmail: "test@example.com"})
+		if err != nil {
+			t.Fatalf("Commit failed: %v", err)
+		}
+		return c
+	}
+	opts := CommitOptions{AuthorName: "Test User", AuthorEmail: "test@example.com"}
+
+	commit("First", map[string]string{"notes.md": "a\nb\nc\nd\ne\n", "old.md": "old\n"})
+	second := commit("Second", map[string]string{"notes.md": "A\nb\nc\nd\ne\n", "old.md": "", "new.md": "new\n"})
+	commit("Third", map[string]string{"notes.md": "A\nb\nc\nd\nE\n"})
+
+	result, err := repo.Revert(second.Hash, opts)
+	if err != nil {
+		t.Fatalf("Revert failed: %v", err)
+	}
+	if result.Commit == nil || len(result.Conflicts) != 0 || !strings.HasPrefix(result.Message, `Revert "Second"`) {
+		t.Fatalf("Unexpected revert result: %+v", result)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "notes.md")); string(data) != "a\nb\nc\nd\nE\n" {
+		t.Errorf("Expected the later change kept, got %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "new.md")); !os.IsNotExist(err) {
+		t.Errorf("Expected new.md deleted, got %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "old.md")); string(data) != "old\n" {
+		t.Errorf("Expected old.md restored, got %q", data)
+	}
+	if status, _ := repo.Status(); !status.IsClean {
+		t.Errorf("Expected a clean tree after the revert, got %+v", status.Files)
+	}
+
+	// Picking the change back clashes with nothing
+	result, err = repo.CherryPick(second.Hash, opts)
+	if err != nil {
+		t.Fatalf("CherryPick failed: %v", err)
+	}
+	if result.Commit == nil || !strings.Contains(result.Message, "(cherry picked from commit "+second.Hash+")") {
+		t.Fatalf("Unexpected cherry-pick result: %+v", result)
+	}
+	if _, err := repo.CherryPick(second.Hash, opts); err == nil {
+		t.Error("Expected picking a change already there to fail")
+	}
+
+	// Uncommitted changes are refused
+	os.WriteFile(filepath.Join(dir, "notes.md"), []byte("dirty\n"), 0644)
+	if _, err := repo.Revert(second.Hash, opts); !errors.Is(err, ErrUncommittedChanges) {
+		t.Errorf("Expected ErrUncommittedChanges, got %v", err)
+	}
+	repo.Discard([]string{"notes.md"})
+
+	// Reverting a change edited since leaves a conflict
+	commit("Fourth", map[string]string{"notes.md": "Z\nb\nc\nd\nE\n"})
+	result, err = repo.Revert(second.Hash, opts)
+	if err != nil {
+		t.Fatalf("Revert failed: %v", err)
+	}
+	if result.Commit != nil || !reflect.DeepEqual(result.Conflicts, []string{"notes.md"}) {
+		t.Fatalf("Expected notes.md conflicted, got %+v", result)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "notes.md"))
+	if !strings.HasPrefix(string(data), "<<<<<<< HEAD\nZ\n=======\na\n>>>>>>> ") {
+		t.Errorf("Expected conflict markers, got %q", data)
+	}
+	status, err := repo.Status()
+	if err != nil || !status.HasConflicts {
+		t.Fatalf("Expected conflicts in status: %+v, %v", status, err)
+	}
+	if _, err := repo.ResolveConflict("notes.md", ResolveOurs, nil); err != nil {
+		t.Errorf("ResolveConflict failed: %v", err)
+	}
+}
+
+func TestRemoteContext(t *testing.T) {
+	remoteDir := t.TempDir()
+	if _, err := gogit.PlainInit(remoteDir, true); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	repo, err := Init(dir)
+	if err != nil {
+		t.Fatalf("Failed to init repo: %v", err)
+	}
+	if _, err := repo.repo.CreateRemote(&gitconfig.RemoteConfig{Name: "origin", URLs: []string{remoteDir}}); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(dir, "notes.md"), []byte("# Notes\n"), 0644)
+	if err := repo.Stage([]string{"notes.md"}); err != nil {
+		t.Fatalf("Stage failed: %v", err)
+	}
+	if _, err := repo.Commit(CommitOptions{Message: "Add notes"}); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := repo.PushContext(ctx, PushRequest{}, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected a cancelled push to fail with context.Canceled, got %v", err)
+	}
+	if _, err := repo.FetchContext(ctx, "", nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected a cancelled fetch to fail with context.Canceled, got %v", err)
+	}
+	if _, err := repo.PullContext(ctx, "", nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected a cancelled pull to fail with context.Canceled, got %v", err)
+	}
+
+	if _, err := repo.PushContext(context.Background(), PushRequest{}, nil); err != nil {
+		t.Fatalf("PushContext failed: %v", err)
+	}
+	if result, err := repo.FetchContext(context.Background(), "", nil); err != nil || !result.Success {
+		t.Errorf("Expected FetchContext to succeed, got %+v, %v", result, err)
+	}
+}
+
+func TestRemotes(t *testing.T) {
+	originDir := t.TempDir()
+	mirrorDir := t.TempDir()
+	for _, dir := range []string{originDir, mirrorDir} {
+		if _, err := gogit.PlainInit(dir, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dir := t.TempDir()
+	repo, err := Init(dir)
+	if err != nil {
+		t.Fatalf("Failed to init repo: %v", err)
+	}
+	os.WriteFile(filepath.Join(dir, "notes.md"), []byte("# Notes\n"), 0644)
+	if err := repo.Stage([]string{"notes.md"}); err != nil {
+		t.Fatalf("Stage failed: %v", err)
+	}
+	commit, err := repo.Commit(CommitOptions{Message: "Add notes"})
+	if err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	if err := repo.AddRemote("origin", originDir); err != nil {
+		t.Fatalf("AddRemote failed: %v", err)
+	}
+	if err := repo.AddRemote("backup", t.TempDir()); err != nil {
+		t.Fatalf("AddRemote failed: %v", err)
+	}
+	if err := repo.AddRemote("origin", mirrorDir); err == nil {
+		t.Error("Expected adding an existing remote to fail")
+	}
+	if err := repo.AddRemote("bad name/", mirrorDir); err == nil {
+		t.Error("Expected an invalid remote name to fail")
+	}
+
+	// The mirror gets pushed to and fetched from by name
+	if err := repo.RenameRemote("backup", "mirror"); err != nil {
+		t.Fatalf("RenameRemote failed: %v", err)
+	}
+	if err := repo.SetRemoteURL("mirror", mirrorDir); err != nil {
+		t.Fatalf("SetRemoteURL failed: %v", err)
+	}
+	remotes, err := repo.ListRemotes()
+	if err != nil {
+		t.Fatalf("ListRemotes failed: %v", err)
+	}
+	if len(remotes) != 2 || remotes[0].Name != "origin" || remotes[1].Name != "mirror" || remotes[1].URLs[0] != mirrorDir {
+		t.Fatalf("Unexpected remotes: %+v", remotes)
+	}
+
+	if _, err := repo.PushContext(context.Background(), PushRequest{Remote: "mirror"}, nil); err != nil {
+		t.Fatalf("Push to mirror failed: %v", err)
+	}
+	mirror, err := gogit.PlainOpen(mirrorDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head, err := mirror.Reference(plumbing.NewBranchReferenceName(repo.Branch()), false); err != nil || head.Hash().String() != commit.Hash {
+		t.Errorf("Expected the mirror to have the commit, got %v, %v", head, err)
+	}
+	if _, err := repo.FetchContext(context.Background(), "mirror", nil); err != nil {
+		t.Fatalf("Fetch from mirror failed: %v", err)
+	}
+	tracking := plumbing.NewRemoteReferenceName("mirror", repo.Branch())
+	if _, err := repo.repo.Reference(tracking, false); err != nil {
+		t.Errorf("Expected a remote-tracking branch for the mirror: %v", err)
+	}
+
+	// Renaming moves the remote-tracking branches along
+	if err := repo.RenameRemote("mirror", "archive"); err != nil {
+		t.Fatalf("RenameRemote failed: %v", err)
+	}
+	if _, err := repo.repo.Reference(tracking, false); err == nil {
+		t.Error("Expected the old remote-tracking branch gone")
+	}
+	if _, err := repo.repo.Reference(plumbing.NewRemoteReferenceName("archive", repo.Branch()), false); err != nil {
+		t.Errorf("Expected the remote-tracking branch renamed: %v", err)
+	}
+	if _, err := repo.FetchContext(context.Background(), "archive", nil); err != nil {
+		t.Errorf("Fetch after rename failed: %v", err)
+	}
+
+	if err := repo.RemoveRemote("archive"); err != nil {
+		t.Fatalf("RemoveRemote failed: %v", err)
+	}
+	if err := repo.RemoveRemote("archive"); err == nil {
+		t.Error("Expected removing a missing remote to fail")
+	}
+	if remotes, _ := repo.ListRemotes(); len(remotes) != 1 {
+		t.Errorf("Expected only origin left, got %+v", remotes)
+	}
+	if url := repo.RemoteURLOf(""); url != originDir {
+		t.Errorf("Expected origin by default, got %q", url)
+	}
+}
+
+func TestForcePush(t *testing.T) {
+	remoteDir := t.TempDir()
+	if _, err := gogit.PlainInit(remoteDir, true); err != nil {
+		t.Fatal(err)
+	}
+
+	commitFile := func(repo *Repository, dir, name string) {
+		t.Helper()
+		os.WriteFile(filepath.Join(dir, name), []byte("# "+name+"\n"), 0644)
+		if err := repo.Stage([]string{name}); err != nil {
+			t.Fatalf("Stage failed: %v", err)
+		}
+		if _, err := repo.Commit(CommitOptions{Message: "Add " + name}); err != nil {
+			t.Fatalf("Commit failed: %v", err)
+		}
+	}
+
+	dir := t.TempDir()
+	repo, err := Init(dir)
+	if err != nil {
+		t.Fatalf("Failed to init repo: %v", err)
+	}
+	if err := repo.AddRemote("origin", remoteDir); err != nil {
+		t.Fatal(err)
+	}
+	commitFile(repo, dir, "notes.md")
+	if _, err := repo.Push(nil); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if _, err := repo.Fetch(nil); err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	if _, err := repo.PushContext(context.Background(), PushRequest{Branch: "missing"}, nil); err == nil {
+		t.Error("Expected pushing a missing branch to fail")
+	}
+
+	// Someone else pushes while this clone commits something else
+	otherDir := t.TempDir()
+	if _, err := gogit.PlainClone(otherDir, false, &gogit.CloneOptions{URL: remoteDir}); err != nil {
+		t.Fatal(err)
+	}
+	other, err := Open(otherDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commitFile(other, otherDir, "theirs.md")
+	if _, err := other.Push(nil); err != nil {
+		t.Fatalf("Push from the other clone failed: %v", err)
+	}
+	commitFile(repo, dir, "ours.md")
+
+	if _, err := repo.Push(nil); err == nil {
+		t.Error("Expected a non-fast-forward push to fail")
+	}
+	force := PushRequest{Remote: "origin", Branch: repo.Branch(), Force: true}
+	if _, err := repo.PushContext(context.Background(), force, nil); !errors.Is(err, ErrStaleLease) {
+		t.Errorf("Expected forcing over unseen commits to fail with ErrStaleLease, got %v", err)
+	}
+	theirs, _ := other.repo.Head()
+	remote, err := gogit.PlainOpen(remoteDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ref, err := remote.Reference(theirs.Name(), false); err != nil || ref.Hash() != theirs.Hash() {
+		t.Errorf("Expected their commit kept on the remote, got %v, %v", ref, err)
+	}
+
+	// Once their commit has been fetched, forcing replaces it
+	if _, err := repo.Fetch(nil); err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	result, err := repo.PushContext(context.Background(), force, nil)
+	if err != nil {
+		t.Fatalf("Force push failed: %v", err)
+	}
+	if result.Message != "Force push successful" {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+
+	head, _ := repo.repo.Head()
+	if ref, err := remote.Reference(head.Name(), false); err != nil || ref.Hash() != head.Hash() {
+		t.Errorf("Expected the remote branch at %s, got %v, %v", head.Hash(), ref, err)
+	}
+
+	// A branch deleted since the last fetch is stale too
+	if err := remote.Storer.RemoveReference(head.Name()); err != nil {
+		t.Fatal(err)
+	}
+	commitFile(repo, dir, "again.md")
+	if _, err := repo.PushContext(context.Background(), force, nil); !errors.Is(err, ErrStaleLease) {
+		t.Errorf("Expected forcing over a deleted branch to fail with ErrStaleLease, got %v", err)
+	}
+}
+
+func TestStageChanges(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := Init(dir)
+	if err != nil {
+		t.Fatalf("Failed to init repo: %v", err)
+	}
+	os.MkdirAll(filepath.Join(dir, "assets"), 0755)
+	os.WriteFile(filepath.Join(dir, "assets", "a.png"), []byte("a"), 0644)
+	if err := repo.Stage([]string{"assets/a.png"}); err != nil {
+		t.Fatalf("Stage failed: %v", err)
+	}
+	if _, err := repo.Commit(CommitOptions{Message: "Add image"}); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	// A move, plus a file that was never tracked and is gone
+	os.MkdirAll(filepath.Join(dir, "assets", "plan"), 0755)
+	os.Rename(filepath.Join(dir, "assets", "a.png"), filepath.Join(dir, "assets", "plan", "a.png"))
+	if err := repo.StageChanges([]string{"assets/a.png", "assets/plan/a.png", "assets/gone.png"}); err != nil {
+		t.Fatalf("StageChanges failed: %v", err)
+	}
+
+	status, err := repo.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	staged := 0
+	for _, f := range status.Files {
+		if f.Staged {
+			staged++
+		}
+	}
+	if staged != 2 {
+		t.Errorf("Expected the move staged, got %+v", status.Files)
+	}
+}
+
+func TestOperationQueue(t *testing.T) {
+	repo, err := Init(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to init repo: %v", err)
+	}
+	manager := &Manager{reposDir: t.TempDir()}
+
+	release, err := manager.Begin(context.Background(), repo, "push")
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	if op, _ := manager.Running(repo); op != "push" {
+		t.Errorf("Expected push running, got %q", op)
+	}
+	if _, ok := manager.TryBegin(repo, "fetch"); ok {
+		t.Error("Expected TryBegin to fail while a push runs")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = manager.Begin(ctx, repo, "pull")
+	var busy *BusyError
+	if !errors.As(err, &busy) || busy.Operation != "push" || !errors.Is(err, ErrBusy) {
+		t.Errorf("Expected a BusyError naming the push, got %v", err)
+	}
+
+	// A waiting operation runs once the first is done
+	done := make(chan error)
+	go func() {
+		release, err := manager.Begin(context.Background(), repo, "commit")
+		if err == nil {
+			release()
+		}
+		done <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	release()
+	release() // Releasing twice is harmless
+	if err := <-done; err != nil {
+		t.Errorf("Expected the queued commit to run, got %v", err)
+	}
+
+	release, ok := manager.TryBegin(repo, "fetch")
+	if !ok {
+		t.Fatal("Expected TryBegin to succeed on an idle repository")
+	}
+	release()
+	if op, _ := manager.Running(repo); op != "" {
+		t.Errorf("Expected nothing running, got %q", op)
+	}
+	if len(manager.ops) != 0 {
+		t.Errorf("Expected idle repositories forgotten, got %d locks", len(manager.ops))
+	}
+}
+
+func TestFillCredential(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+
+	gitconfig := `[credential]
+	helper = "!f() { test \"$1\" = get && echo username=alice && echo password=token; }; f"
+[credential "https://gitlab.example.com"]
+	helper =
+`
+	if err := os.WriteFile(filepath.Join(home, ".gitconfig"), []byte(gitconfig), 0644); err != nil {
+		t.Fatalf("Failed to write gitconfig: %v", err)
+	}
+
+	auth, err := GetAuth(AuthConfig{Type: AuthTypeHTTPS, URL: "https://github.com/user/repo.git"})
+	if err != nil {
+		t.Fatalf("GetAuth failed: %v", err)
+	}
+	basic, ok := auth.(*http.BasicAuth)
+	if !ok || basic.Username != "alice" || basic.Password != "token" {
+		t.Errorf("Expected the helper's credentials, got %#v", auth)
+	}
+
+	// Credentials that are given win over the helper
+	auth, _ = GetAuth(AuthConfig{Type: AuthTypeHTTPS, URL: "https://github.com/user/repo.git", Username: "bob", Password: "secret"})
+	if basic, ok := auth.(*http.BasicAuth); !ok || basic.Password != "secret" {
+		t.Errorf("Expected the given credentials, got %#v", auth)
+	}
+
+	// Without git, the helpers in ~/.gitconfig are run directly
+	u, _ := url.Parse("https://github.com/user/repo.git")
+	cred, err := fillFromGitConfig(context.Background(), u, "")
+	if err != nil || cred.Password != "token" {
+		t.Errorf("Expected the token from gitconfig, got %+v, %v", cred, err)
+	}
+
+	// An empty helper clears the helpers for that host
+	u, _ = url.Parse("https://gitlab.example.com/user/repo.git")
+	if _, err := fillFromGitConfig(context.Background(), u, ""); !errors.Is(err, ErrNoCredentials) {
+		t.Errorf("Expected ErrNoCredentials, got %v", err)
+	}
+	auth, err = GetAuth(AuthConfig{Type: AuthTypeHTTPS, URL: "https://gitlab.example.com/user/repo.git"})
+	if err != nil || auth != nil {
+		t.Errorf("Expected no auth for an anonymous remote, got %#v, %v", auth, err)
+	}
+}
+
+func TestGroupByDirectory(t *testing.T) {
+	files := []FileStatus{
+		{Path: "notes/b.md", Status: "modified"},
+		{Path: "readme.md", Status: "added"},
+		{Path: "notes/a.md", Status: "untracked"},
+		{Path: "notes/deep/c.md", Status: "deleted"},
+	}
+
+	groups := GroupByDirectory(files)
+	var got []string
+	for _, g := range groups {
+		for _, f := range g.Files {
+			got = append(got, g.Dir+"|"+f.Path)
+		}
+	}
+	want := []string{"|readme.md", "notes|notes/a.md", "notes|notes/b.md", "notes/deep|notes/deep/c.md"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	if md := FilterMarkdownFiles(append(files, FileStatus{Path: "photo.png"})); len(md) != len(files) {
+		t.Errorf("Expected only the markdown files, got %v", md)
+	}
+}
+
+func TestStatusIgnoresFiles(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+
+	dir := t.TempDir()
+	repo, err := Init(dir)
+	if err != nil {
+		t.Fatalf("Failed to init repo: %v", err)
+	}
+
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("note.md", "# Note")
+	write("note.md~", "backup")
+	write("build/site.html", "<html>")
+	write("draft.swp", "swap")
+	write(".git/info/exclude", "build/\n")
+
+	// The user's default excludes file
+	if err := os.MkdirAll(filepath.Join(home, ".config", "git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(home, ".config", "git", "ignore"), []byte("*.swp\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if added, err := repo.AddIgnore([]string{"*~", "*~", " "}); err != nil || !reflect.DeepEqual(added, []string{"*~"}) {
+		t.Fatalf("AddIgnore() = %v, %v", added, err)
+	}
+	if added, _ := repo.AddIgnore([]string{"*~"}); len(added) != 0 {
+		t.Errorf("Expected a pattern already there to be skipped, got %v", added)
+	}
+	if content, _ := repo.ReadIgnore(); content != "*~\n" {
+		t.Errorf("ReadIgnore() = %q", content)
+	}
+
+	status, err := repo.Status()
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	var paths []string
+	for _, f := range status.Files {
+		paths = append(paths, f.Path)
+	}
+	sort.Strings(paths)
+	if want := []string{".gitignore", "note.md"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("Expected %v, got %v", want, paths)
+	}
+
+	if err := repo.WriteIgnore(""); err != nil {
+		t.Fatal(err)
+	}
+	status, _ = repo.Status()
+	if len(status.Files) != 3 {
+		t.Errorf("Expected the backup to show once .gitignore is emptied, got %+v", status.Files)
+	}
+}
+
+func TestCountAheadBehind(t *testing.T) {
+	s := memory.NewStorage()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	n := 0
+	commit := func(parents ...plumbing.Hash) plumbing.Hash {
+		t.Helper()
+		n++
+		sig := object.Signature{Name: "Test User", Email: "test@example.com", When: start.Add(time.Duration(n) * time.Hour)}
+		c := &object.Commit{Author: sig, Committer: sig, Message: fmt.Sprintf("commit %d", n), ParentHashes: parents}
+		obj := s.NewEncodedObject()
+		if err := c.Encode(obj); err != nil {
+			t.Fatal(err)
+		}
+		hash, err := s.SetEncodedObject(obj)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hash
+	}
+
+	// base ← l1 ← l2 on one side; base ← r1 ← merge(r1, l1) on the other
+	root := commit()
+	base := commit(root)
+	l1 := commit(base)
+	r1 := commit(base)
+	l2 := commit(l1)
+	r2 := commit(r1, l1)
+
+	// Commits made in the same second, as a script would make them
+	n = 99
+	s1 := commit(l2)
+	n = 99
+	s2 := commit(s1)
+	n = 99
+	s3 := commit(s2)
+
+	tests := []struct {
+		name          string
+		local, remote plumbing.Hash
+		ahead, behind int
+	}{
+		{"same commit", l2, l2, 0, 0},
+		{"only ahead", l2, base, 2, 0},
+		{"only behind", root, r2, 0, 4},
+		{"diverged after a merge", l2, r2, 1, 2},
+		{"diverged the other way", r2, l2, 2, 1},
+		{"behind within a second", s1, s3, 0, 2},
+		{"ahead within a second", s3, s1, 2, 0},
+		{"diverged within a second", s3, r2, 4, 2},
+	}
+
+	for _, tt := range tests {
+		for range 2 { // The second time from the cache
+			ahead, behind, err := countAheadBehind(s, tt.local, tt.remote)
+			if err != nil || ahead != tt.ahead || behind != tt.behind {
+				t.Errorf("%s: got %d ahead, %d behind, %v; want %d, %d", tt.name, ahead, behind, err, tt.ahead, tt.behind)
+			}
+		}
+	}
+
+	if _, _, err := countAheadBehind(s, l2, plumbing.NewHash("1234")); err == nil {
+		t.Error("Expected an error for a missing commit")
+	}
+}
+
+func TestHistoryPages(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := Init(dir)
+	if err != nil {
+		t.Fatalf("Failed to init repo: %v", err)
+	}
+	wt, err := repo.repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	n := 0
+	commit := func(name, content string, parents ...plumbing.Hash) plumbing.Hash {
+		t.Helper()
+		os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755)
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := wt.Add(name); err != nil {
+			t.Fatal(err)
+		}
+		n++
+		sig := &object.Signature{Name: "Test User", Email: "test@example.com", When: start.Add(time.Duration(n) * time.Hour)}
+		hash, err := wt.Commit("Change "+name, &gogit.CommitOptions{Author: sig, Parents: parents})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hash
+	}
+
+	// c1 ← c2 ← c3 ← merge ← c4, with s1 on a side branch from c2
+	c1 := commit("a.md", "one")
+	c2 := commit("b.md", "two", c1)
+	s1 := commit("notes/x.md", "side", c2)
+	if _, err := wt.Remove("notes/x.md"); err != nil {
+		t.Fatal(err)
+	}
+	c3 := commit("a.md", "three", c2)
+	merge := commit("notes/x.md", "side", c3, s1)
+	c4 := commit("notes/y.md", "four", merge)
+
+	pages := func(path string, limit int) [][]plumbing.Hash {
+		t.Helper()
+		var pages [][]plumbing.Hash
+		cursor := ""
+		for {
+			page, err := repo.History(HistoryOptions{Limit: limit, Cursor: cursor, Path: path})
+			if err != nil {
+				t.Fatalf("History(%q) failed: %v", cursor, err)
+			}
+			var hashes []plumbing.Hash
+			for _, c := range page.Commits {
+				hashes = append(hashes, plumbing.NewHash(c.Hash))
+			}
+			pages = append(pages, hashes)
+			if page.HasMore != (page.Next != "") {
+				t.Fatalf("HasMore is %v with next %q", page.HasMore, page.Next)
+			}
+			if !page.HasMore {
+				return pages
+			}
+			cursor = page.Next
+		}
+	}
+
+	// The second page carries on from both sides of the merge
+	want := [][]plumbing.Hash{{c4, merge}, {c3, s1}, {c2, c1}}
+	if got := pages("", 2); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected pages %v, got %v", want, got)
+	}
+	want = [][]plumbing.Hash{{c4, merge}, {s1}}
+	if got := pages("notes", 2); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected notes pages %v, got %v", want, got)
+	}
+
+	// Skipping still works, and agrees with the cursor
+	if commits, err := repo.GetHistory(2, 2, ""); err != nil || len(commits) != 2 || commits[0].Hash != c3.String() {
+		t.Errorf("GetHistory(2, 2) = %v, %v", commits, err)
+	}
+
+	// A page holding the whole history counts it
+	if page, err := repo.History(HistoryOptions{}); err != nil || page.Total != 6 || page.Estimated {
+		t.Errorf("Expected a total of 6, got %+v, %v", page, err)
+	}
+	if page, _ := repo.History(HistoryOptions{Path: "notes"}); page.Total != 3 {
+		t.Errorf("Expected a notes total of 3, got %d", page.Total)
+	}
+
+	// Moving the branch corrects the total, exactly without a path
+	commit("a.md", "five", c4)
+	if page, _ := repo.History(HistoryOptions{Limit: 1}); page.Total != 7 || page.Estimated {
+		t.Errorf("Expected a total of 7, got %d (estimated %v)", page.Total, page.Estimated)
+	}
+	if page, _ := repo.History(HistoryOptions{Limit: 1, Path: "notes"}); page.Total != 3 || !page.Estimated {
+		t.Errorf("Expected an estimated notes total of 3, got %d (estimated %v)", page.Total, page.Estimated)
+	}
+
+	if _, err := repo.History(HistoryOptions{Cursor: "nonsense"}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("Expected ErrInvalidCursor, got %v", err)
+	}
+}
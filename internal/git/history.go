@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"strings"
 
-	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
 )
@@ -17,6 +16,7 @@ type FileChange struct {
 	Action    string `json:"action"`            // added, modified, deleted, renamed
 	Additions int    `json:"additions"`
 	Deletions int    `json:"deletions"`
+	Binary    bool   `json:"binary,omitempty"`
 }
 
 // CommitDetail contains full commit information including changes.
@@ -42,6 +42,9 @@ type FileDiff struct {
 	Lines     []DiffLine `json:"lines"`
 	Additions int        `json:"additions"`
 	Deletions int        `json:"deletions"`
+	Truncated bool       `json:"truncated,omitempty"` // Lines were left out by DiffLimits
+	OldSize   int64      `json:"oldSize,omitempty"`   // Sizes of a binary file before and after
+	NewSize   int64      `json:"newSize,omitempty"`
 }
 
 // CommitDiffResult contains the diff between two commits.
@@ -49,65 +52,24 @@ type CommitDiffResult struct {
 	FromCommit string     `json:"fromCommit"`
 	ToCommit   string     `json:"toCommit"`
 	Files      []FileDiff `json:"files"`
+	Truncated  bool       `json:"truncated,omitempty"` // Some file is truncated
 }
 
 // GetHistory returns the commit history.
 func (r *Repository) GetHistory(limit int, skip int, filePath string) ([]Commit, error) {
-	if r.repo == nil {
-		return nil, errors.New("repository not initialized")
-	}
-
-	logOptions := &git.LogOptions{
-		Order: git.LogOrderCommitterTime,
-	}
-
-	// Filter by file path if specified
-	if filePath != "" {
-		logOptions.PathFilter = func(path string) bool {
-			return path == filePath || strings.HasPrefix(path, filePath+"/")
-		}
-	}
-
-	iter, err := r.repo.Log(logOptions)
+	page, err := r.History(HistoryOptions{Limit: limit, Skip: skip, Path: filePath})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get log: %w", err)
-	}
-	defer iter.Close()
-
-	var commits []Commit
-	count := 0
-	skipped := 0
-
-	err = iter.ForEach(func(c *object.Commit) error {
-		// Skip commits for pagination
-		if skipped < skip {
-			skipped++
-			return nil
-		}
-
-		// Limit number of commits
-		if limit > 0 && count >= limit {
-			return fmt.Errorf("limit reached")
-		}
-
-		commits = append(commits, Commit{
-			Hash:      c.Hash.String(),
-			ShortHash: c.Hash.String()[:7],
-			Message:   strings.TrimSpace(c.Message),
-			Author:    c.Author.Name,
-			Email:     c.Author.Email,
-			Date:      c.Author.When,
-		})
-		count++
-		return nil
-	})
-
-	// Ignore "limit reached" error
-	if err != nil && err.Error() != "limit reached" {
 		return nil, err
 	}
+	return page.Commits, nil
+}
 
-	return commits, nil
+// WalkHistory calls fn for each commit of the history, newest first, as it
+// is read. A limit of 0 walks the whole history. An error from fn stops the
+// walk and is returned.
+func (r *Repository) WalkHistory(limit int, skip int, filePath string, fn func(Commit) error) error {
+	_, err := r.walkHistory(nil, limit, skip, filePath, fn)
+	return err
 }
 
 // GetCommit returns details for a specific commit.
@@ -175,6 +137,7 @@ func (r *Repository) getCommitChanges(from, to *object.Commit) ([]FileChange, er
 	if err != nil {
 		return nil, err
 	}
+	binary := r.newBinaryChecker(toTree)
 
 	var fileChanges []FileChange
 	for _, change := range changes {
@@ -204,6 +167,12 @@ func (r *Repository) getCommitChanges(from, to *object.Commit) ([]FileChange, er
 			}
 		}
 
+		// Binary files have no line stats, and patching them is slow
+		if _, _, fc.Binary = binary.inspect(change); fc.Binary {
+			fileChanges = append(fileChanges, fc)
+			continue
+		}
+
 		// Get stats if possible
 		patch, err := change.Patch()
 		if err == nil {
@@ -222,96 +191,194 @@ func (r *Repository) getCommitChanges(from, to *object.Commit) ([]FileChange, er
 	return fileChanges, nil
 }
 
-// GetDiff returns the diff between two commits.
+// DiffLimits caps how much of a diff is computed, so a huge generated file
+// can't stall the server. Zero fields don't limit.
+type DiffLimits struct {
+	FileBytes  int64 // Files larger than this on either side get no lines
+	FileLines  int   // Lines kept for each file
+	TotalLines int   // Lines kept across all files
+}
+
+// DefaultDiffLimits are the limits GetDiff and GetFileDiff apply
+var DefaultDiffLimits = DiffLimits{FileBytes: 2 << 20, FileLines: 5000, TotalLines: 20000}
+
+// key identifies the limits in cache keys
+func (l DiffLimits) key() string {
+	return fmt.Sprintf("%d/%d/%d", l.FileBytes, l.FileLines, l.TotalLines)
+}
+
+// GetDiff returns the diff between two commits, within DefaultDiffLimits.
 func (r *Repository) GetDiff(fromHash, toHash string) (*CommitDiffResult, error) {
+	return r.GetDiffWithLimits(fromHash, toHash, DefaultDiffLimits)
+}
+
+// GetDiffWithLimits returns the diff between two commits. Files cut short
+// by the limits are marked truncated, and so is the result if any is.
+// DiffLimits{} returns the full diff.
+func (r *Repository) GetDiffWithLimits(fromHash, toHash string, limits DiffLimits) (*CommitDiffResult, error) {
 	if r.repo == nil {
 		return nil, errors.New("repository not initialized")
 	}
 
-	fromCommit, err := r.repo.CommitObject(plumbing.NewHash(fromHash))
-	if err != nil {
-		return nil, fmt.Errorf("from commit not found: %w", err)
+	key := cacheKey("diff", r.path, fromHash, toHash, limits.key())
+	if cached, ok := history.get(key); ok {
+		return cached.(*CommitDiffResult), nil
 	}
 
-	toCommit, err := r.repo.CommitObject(plumbing.NewHash(toHash))
-	if err != nil {
-		return nil, fmt.Errorf("to commit not found: %w", err)
+	result := &CommitDiffResult{
+		FromCommit: fromHash[:7],
+		ToCommit:   toHash[:7],
 	}
 
-	fromTree, err := fromCommit.Tree()
+	size := int64(64)
+	err := r.walkChanges(fromHash, toHash, limits, func(fileDiff *FileDiff) error {
+		result.Files = append(result.Files, *fileDiff)
+		result.Truncated = result.Truncated || fileDiff.Truncated
+		size += diffSize(fileDiff)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	toTree, err := toCommit.Tree()
-	if err != nil {
-		return nil, err
+	history.add(key, result, size)
+	return result, nil
+}
+
+// WalkDiff calls fn with the diff of each changed file between two commits
+// as it is computed, so a large diff need not be held in memory at once. A
+// diff already in the history cache is replayed from there; a walked one is
+// not cached. An error from fn stops the walk and is returned.
+func (r *Repository) WalkDiff(fromHash, toHash string, limits DiffLimits, fn func(*FileDiff) error) error {
+	if r.repo == nil {
+		return errors.New("repository not initialized")
 	}
 
-	changes, err := fromTree.Diff(toTree)
-	if err != nil {
-		return nil, err
+	if cached, ok := history.get(cacheKey("diff", r.path, fromHash, toHash, limits.key())); ok {
+		files := cached.(*CommitDiffResult).Files
+		for i := range files {
+			if err := fn(&files[i]); err != nil {
+				return err
+			}
+		}
+		return nil
 	}
 
-	result := &CommitDiffResult{
-		FromCommit: fromHash[:7],
-		ToCommit:   toHash[:7],
+	return r.walkChanges(fromHash, toHash, limits, fn)
+}
+
+// walkChanges computes the diff of each changed file between two commits,
+// sharing limits.TotalLines between them
+func (r *Repository) walkChanges(fromHash, toHash string, limits DiffLimits, fn func(*FileDiff) error) error {
+	changes, toTree, err := r.treeChanges(fromHash, toHash)
+	if err != nil {
+		return err
 	}
+	binary := r.newBinaryChecker(toTree)
 
+	used := 0
 	for _, change := range changes {
-		fileDiff, err := r.changeToFileDiff(change)
+		maxLines := limits.FileLines
+		if limits.TotalLines > 0 {
+			remaining := limits.TotalLines - used
+			if remaining < 0 {
+				remaining = 0
+			}
+			if maxLines == 0 || remaining < maxLines {
+				maxLines = remaining
+			}
+		} else if maxLines == 0 {
+			maxLines = -1
+		}
+
+		fileDiff, err := r.changeToFileDiff(change, binary, limits.FileBytes, maxLines)
 		if err != nil {
 			continue
 		}
-		result.Files = append(result.Files, *fileDiff)
+		used += len(fileDiff.Lines)
+		if err := fn(fileDiff); err != nil {
+			return err
+		}
 	}
-
-	return result, nil
+	return nil
 }
 
-// GetFileDiff returns the diff for a specific file between two commits.
-func (r *Repository) GetFileDiff(fromHash, toHash, filePath string) (*FileDiff, error) {
-	if r.repo == nil {
-		return nil, errors.New("repository not initialized")
-	}
-
+// treeChanges returns the files that differ between two commits, and the
+// tree of the later one
+func (r *Repository) treeChanges(fromHash, toHash string) (object.Changes, *object.Tree, error) {
 	fromCommit, err := r.repo.CommitObject(plumbing.NewHash(fromHash))
 	if err != nil {
-		return nil, fmt.Errorf("from commit not found: %w", err)
+		return nil, nil, fmt.Errorf("from commit not found: %w", err)
 	}
 
 	toCommit, err := r.repo.CommitObject(plumbing.NewHash(toHash))
 	if err != nil {
-		return nil, fmt.Errorf("to commit not found: %w", err)
+		return nil, nil, fmt.Errorf("to commit not found: %w", err)
 	}
 
 	fromTree, err := fromCommit.Tree()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	toTree, err := toCommit.Tree()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	changes, err := fromTree.Diff(toTree)
+	return changes, toTree, err
+}
+
+// GetFileDiff returns the diff for a specific file between two commits,
+// within DefaultDiffLimits.
+func (r *Repository) GetFileDiff(fromHash, toHash, filePath string) (*FileDiff, error) {
+	return r.GetFileDiffWithLimits(fromHash, toHash, filePath, DefaultDiffLimits)
+}
+
+// GetFileDiffWithLimits returns the diff for a specific file between two
+// commits, marked truncated if the limits cut it short. Only FileBytes and
+// FileLines apply.
+func (r *Repository) GetFileDiffWithLimits(fromHash, toHash, filePath string, limits DiffLimits) (*FileDiff, error) {
+	if r.repo == nil {
+		return nil, errors.New("repository not initialized")
+	}
+
+	key := cacheKey("filediff", r.path, fromHash, toHash, filePath, limits.key())
+	if cached, ok := history.get(key); ok {
+		return cached.(*FileDiff), nil
+	}
+
+	changes, toTree, err := r.treeChanges(fromHash, toHash)
 	if err != nil {
 		return nil, err
 	}
 
+	maxLines := limits.FileLines
+	if maxLines == 0 {
+		maxLines = -1
+	}
+
 	for _, change := range changes {
 		// Check if this change matches the file path
 		if change.From.Name == filePath || change.To.Name == filePath {
-			return r.changeToFileDiff(change)
+			fileDiff, err := r.changeToFileDiff(change, r.newBinaryChecker(toTree), limits.FileBytes, maxLines)
+			if err == nil {
+				history.add(key, fileDiff, diffSize(fileDiff))
+			}
+			return fileDiff, err
 		}
 	}
 
 	return nil, fmt.Errorf("file not found in diff: %s", filePath)
 }
 
-// changeToFileDiff converts a go-git Change to our FileDiff format.
-func (r *Repository) changeToFileDiff(change *object.Change) (*FileDiff, error) {
+// changeToFileDiff converts a go-git Change to our FileDiff format. Binary
+// files only get their sizes. Files larger than maxBytes get no lines, and
+// at most maxLines lines are kept; zero maxBytes and negative maxLines
+// don't limit. Truncated files still count every addition and deletion
+// when their lines were diffed.
+func (r *Repository) changeToFileDiff(change *object.Change, binary *binaryChecker, maxBytes int64, maxLines int) (*FileDiff, error) {
 	fileDiff := &FileDiff{}
 
 	action, err := change.Action()
@@ -338,6 +405,18 @@ func (r *Repository) changeToFileDiff(change *object.Change) (*FileDiff, error)
 		}
 	}
 
+	oldSize, newSize, isBinary := binary.inspect(change)
+	if isBinary {
+		fileDiff.Binary = true
+		fileDiff.OldSize = oldSize
+		fileDiff.NewSize = newSize
+		return fileDiff, nil
+	}
+	if maxLines == 0 || (maxBytes > 0 && max(oldSize, newSize) > maxBytes) {
+		fileDiff.Truncated = true
+		return fileDiff, nil
+	}
+
 	patch, err := change.Patch()
 	if err != nil {
 		return fileDiff, nil // Return without diff lines
@@ -387,6 +466,10 @@ func (r *Repository) changeToFileDiff(change *object.Change) (*FileDiff, error)
 					fileDiff.Deletions++
 				}
 
+				if maxLines >= 0 && len(fileDiff.Lines) >= maxLines {
+					fileDiff.Truncated = true
+					continue
+				}
 				fileDiff.Lines = append(fileDiff.Lines, diffLine)
 			}
 		}
@@ -401,6 +484,11 @@ func (r *Repository) GetFileAtCommit(hash, filePath string) (string, error) {
 		return "", errors.New("repository not initialized")
 	}
 
+	key := cacheKey("file", r.path, hash, filePath)
+	if cached, ok := history.get(key); ok {
+		return cached.(string), nil
+	}
+
 	commit, err := r.repo.CommitObject(plumbing.NewHash(hash))
 	if err != nil {
 		return "", fmt.Errorf("commit not found: %w", err)
@@ -421,5 +509,6 @@ func (r *Repository) GetFileAtCommit(hash, filePath string) (string, error) {
 		return "", err
 	}
 
+	history.add(key, content, int64(len(content)))
 	return content, nil
 }
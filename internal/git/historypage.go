@@ -0,0 +1,343 @@
+package git
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+// ErrInvalidCursor is returned for a history cursor this package didn't make
+var ErrInvalidCursor = errors.New("invalid history cursor")
+
+// HistoryOptions selects a page of history
+type HistoryOptions struct {
+	Limit  int    // Commits on the page; 0 for the rest of the history
+	Cursor string // Next from an earlier page, to carry on where it stopped
+	Skip   int    // Commits passed over first; a cursor doesn't walk them again
+	Path   string // Only commits changing this file or folder
+}
+
+// HistoryPage is a page of history, newest first
+type HistoryPage struct {
+	Commits   []Commit `json:"commits"`
+	Next      string   `json:"next,omitempty"` // Cursor for the following page
+	HasMore   bool     `json:"hasMore"`
+	Total     int      `json:"total,omitempty"`     // Commits in the whole history; 0 until counted
+	Estimated bool     `json:"estimated,omitempty"` // Total was counted before the branch moved
+}
+
+// historyCursor is where a history walk stopped: how many commits came
+// before it and the commits still to visit. Restarting a newest-first walk
+// from those commits carries on exactly where the last one stopped, so a
+// page never walks the commits before it again.
+type historyCursor struct {
+	offset int
+	next   []plumbing.Hash
+}
+
+// String encodes the cursor as the offset and the hashes, e.g.
+// "50:1a2b…" for a history without merges in progress
+func (c historyCursor) String() string {
+	hashes := make([]string, len(c.next))
+	for i, h := range c.next {
+		hashes[i] = h.String()
+	}
+	return strconv.Itoa(c.offset) + ":" + strings.Join(hashes, ",")
+}
+
+func parseHistoryCursor(s string) (historyCursor, error) {
+	offset, hashes, ok := strings.Cut(s, ":")
+	n, err := strconv.Atoi(offset)
+	if !ok || err != nil || n < 0 || hashes == "" {
+		return historyCursor{}, ErrInvalidCursor
+	}
+	cursor := historyCursor{offset: n}
+	for _, h := range strings.Split(hashes, ",") {
+		if !plumbing.IsHash(h) {
+			return historyCursor{}, ErrInvalidCursor
+		}
+		cursor.next = append(cursor.next, plumbing.NewHash(h))
+	}
+	return cursor, nil
+}
+
+// historyWalk visits commits newest first by committer time, as
+// git log does, from any number of starting commits
+type historyWalk struct {
+	store storer.EncodedObjectStorer
+	queue *commitQueue
+	seen  map[plumbing.Hash]bool
+}
+
+func newHistoryWalk(s storer.EncodedObjectStorer, from []plumbing.Hash) (*historyWalk, error) {
+	w := &historyWalk{store: s, queue: &commitQueue{}, seen: make(map[plumbing.Hash]bool)}
+	for _, hash := range from {
+		if w.seen[hash] {
+			continue
+		}
+		c, err := object.GetCommit(s, hash)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get commit %s: %w", hash, err)
+		}
+		w.seen[hash] = true
+		heap.Push(w.queue, c)
+	}
+	return w, nil
+}
+
+// peek returns the commit visited next, or nil at the end
+func (w *historyWalk) peek() *object.Commit {
+	if w.queue.Len() == 0 {
+		return nil
+	}
+	return w.queue.commits[0]
+}
+
+// advance moves past the commit peek returned, queueing its parents
+func (w *historyWalk) advance() {
+	c := heap.Pop(w.queue).(*object.Commit)
+	for _, parent := range c.ParentHashes {
+		if w.seen[parent] {
+			continue
+		}
+		w.seen[parent] = true
+		p, err := object.GetCommit(w.store, parent)
+		if err != nil {
+			// Beyond the edge of a shallow clone
+			continue
+		}
+		heap.Push(w.queue, p)
+	}
+}
+
+// pending returns the commits still to visit
+func (w *historyWalk) pending() []plumbing.Hash {
+	hashes := make([]plumbing.Hash, len(w.queue.commits))
+	for i, c := range w.queue.commits {
+		hashes[i] = c.Hash
+	}
+	return hashes
+}
+
+// changesPath reports whether c changed path, a file or folder, from its
+// first parent
+func changesPath(c *object.Commit, path string) (bool, error) {
+	tree, err := c.Tree()
+	if err != nil {
+		return false, err
+	}
+	entry := pathHash(tree, path)
+	if c.NumParents() == 0 {
+		return !entry.IsZero(), nil
+	}
+	parent, err := c.Parent(0)
+	if err != nil {
+		// Beyond the edge of a shallow clone: whatever is there was added
+		return !entry.IsZero(), nil
+	}
+	parentTree, err := parent.Tree()
+	if err != nil {
+		return false, err
+	}
+	return pathHash(parentTree, path) != entry, nil
+}
+
+// pathHash returns the hash of the file or folder at path, which changes
+// whenever anything in it does, or the zero hash if there is none
+func pathHash(tree *object.Tree, path string) plumbing.Hash {
+	entry, err := tree.FindEntry(path)
+	if err != nil {
+		return plumbing.ZeroHash
+	}
+	return entry.Hash
+}
+
+// walkHistory calls fn for up to limit commits from the cursor, or HEAD
+// without one, after passing over skip. It returns where it stopped, or
+// nil at the end of the history.
+func (r *Repository) walkHistory(cursor *historyCursor, limit, skip int, path string, fn func(Commit) error) (*historyCursor, error) {
+	if r.repo == nil {
+		return nil, errors.New("repository not initialized")
+	}
+
+	offset := 0
+	var from []plumbing.Hash
+	if cursor != nil {
+		offset, from = cursor.offset, cursor.next
+	} else {
+		head, err := r.repo.Head()
+		if errors.Is(err, plumbing.ErrReferenceNotFound) {
+			return nil, nil // No commits yet
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get log: %w", err)
+		}
+		from = []plumbing.Hash{head.Hash()}
+	}
+	w, err := newHistoryWalk(r.repo.Storer, from)
+	if err != nil {
+		return nil, err
+	}
+
+	count := 0
+	for c := w.peek(); c != nil; c = w.peek() {
+		if path != "" {
+			changed, err := changesPath(c, path)
+			if err != nil {
+				return nil, err
+			}
+			if !changed {
+				w.advance()
+				continue
+			}
+		}
+		if limit > 0 && count >= limit {
+			// c starts the next page
+			return &historyCursor{offset: offset, next: w.pending()}, nil
+		}
+		w.advance()
+		offset++
+		if skip > 0 {
+			skip--
+			continue
+		}
+
+		count++
+		if err := fn(Commit{
+			Hash:      c.Hash.String(),
+			ShortHash: c.Hash.String()[:7],
+			Message:   strings.TrimSpace(c.Message),
+			Author:    c.Author.Name,
+			Email:     c.Author.Email,
+			Date:      c.Author.When,
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// History returns a page of history. Following pages are asked for with
+// the page's Next cursor, which carries on without walking the commits
+// before it again.
+func (r *Repository) History(opts HistoryOptions) (*HistoryPage, error) {
+	var cursor *historyCursor
+	if opts.Cursor != "" {
+		c, err := parseHistoryCursor(opts.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		cursor = &c
+	}
+
+	page := &HistoryPage{Commits: []Commit{}}
+	next, err := r.walkHistory(cursor, opts.Limit, opts.Skip, opts.Path, func(c Commit) error {
+		page.Commits = append(page.Commits, c)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if next != nil {
+		page.Next = next.String()
+		page.HasMore = true
+	}
+
+	head, err := r.repo.Head()
+	if err != nil {
+		return page, nil
+	}
+	if next == nil && cursor == nil && opts.Skip == 0 {
+		// The whole history fit on the page
+		totals.set(r.path, opts.Path, head.Hash(), len(page.Commits))
+	}
+	page.Total, page.Estimated = totals.get(r, opts.Path, head.Hash())
+	return page, nil
+}
+
+// totals holds the number of commits in histories counted before
+var totals = &historyTotals{counts: newLRUCache(1024 * historyTotalSize), counting: make(map[string]bool)}
+
+// historyTotalSize is what one remembered total is taken to cost
+const historyTotalSize = 128
+
+// historyTotals remembers how many commits each history has, counting them
+// in the background the first time they're asked for
+type historyTotals struct {
+	counts *lruCache // historyTotal by repository and path
+
+	mu       sync.Mutex
+	counting map[string]bool
+}
+
+type historyTotal struct {
+	head  plumbing.Hash // The commit the history was counted from
+	count int
+}
+
+func (t *historyTotals) set(repoPath, path string, head plumbing.Hash, count int) {
+	t.counts.add(cacheKey(repoPath, path), historyTotal{head: head, count: count}, historyTotalSize)
+}
+
+// get returns the number of commits in the history from head, and whether
+// it is an estimate. A history whose branch moved since it was counted is
+// corrected by the commits added and removed, which is exact without a
+// path; with one the old total is an estimate until counted again. Zero
+// means it hasn't been counted yet.
+func (t *historyTotals) get(r *Repository, path string, head plumbing.Hash) (int, bool) {
+	var total historyTotal
+	cached, ok := t.counts.get(cacheKey(r.path, path))
+	if ok {
+		total = cached.(historyTotal)
+	}
+	if ok && total.head == head {
+		return total.count, false
+	}
+
+	if ok && path == "" {
+		added, removed, err := countAheadBehind(r.repo.Storer, head, total.head)
+		if err == nil {
+			count := total.count + added - removed
+			t.set(r.path, path, head, count)
+			return count, false
+		}
+	}
+	t.count(r, path, head)
+	return total.count, ok
+}
+
+// count counts the history in the background, once at a time per history
+func (t *historyTotals) count(r *Repository, path string, head plumbing.Hash) {
+	key := cacheKey(r.path, path)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.counting[key] {
+		return
+	}
+	t.counting[key] = true
+
+	go func() {
+		defer func() {
+			t.mu.Lock()
+			delete(t.counting, key)
+			t.mu.Unlock()
+		}()
+		n := 0
+		cursor := &historyCursor{next: []plumbing.Hash{head}}
+		_, err := r.walkHistory(cursor, 0, 0, path, func(Commit) error {
+			n++
+			return nil
+		})
+		if err != nil {
+			return
+		}
+		t.set(r.path, path, head, n)
+	}()
+}
@@ -0,0 +1,57 @@
+package git
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/go-git/go-git/v5/config"
+)
+
+// Fallback author used when no identity is configured anywhere
+const (
+	DefaultAuthorName  = "Inkwell User"
+	DefaultAuthorEmail = "user@inkwell.local"
+)
+
+// LoadSigningKey reads an ASCII-armored OpenPGP private key used to sign
+// commits. The key must not be protected by a passphrase.
+func LoadSigningKey(path string) (*openpgp.Entity, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open signing key: %w", err)
+	}
+	defer f.Close()
+
+	entities, err := openpgp.ReadArmoredKeyRing(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key %s: %w", path, err)
+	}
+	if len(entities) == 0 || entities[0].PrivateKey == nil {
+		return nil, fmt.Errorf("%s does not contain a private key", path)
+	}
+
+	entity := entities[0]
+	if entity.PrivateKey.Encrypted {
+		return nil, fmt.Errorf("signing key %s is protected by a passphrase", path)
+	}
+	return entity, nil
+}
+
+// configIdentity returns user.name and user.email from the repository's
+// git config, falling back to the global config
+func (r *Repository) configIdentity() (name, email string) {
+	for _, scope := range []config.Scope{config.LocalScope, config.GlobalScope} {
+		cfg, err := r.repo.ConfigScoped(scope)
+		if err != nil {
+			continue
+		}
+		if name == "" {
+			name = cfg.User.Name
+		}
+		if email == "" {
+			email = cfg.User.Email
+		}
+	}
+	return name, email
+}
@@ -0,0 +1,139 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-git/v5"
+	formatcfg "github.com/go-git/go-git/v5/plumbing/format/config"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+)
+
+// IgnoreFile is the ignore file at the root of a repository
+const IgnoreFile = ".gitignore"
+
+// worktree returns the repository's worktree, ignoring what git would: go-git
+// reads .gitignore files and .git/info/exclude itself, and the user's and
+// the system's excludes files are added here
+func (r *Repository) worktree() (*git.Worktree, error) {
+	wt, err := r.repo.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	wt.Excludes = append(wt.Excludes, globalExcludes()...)
+	return wt, nil
+}
+
+// globalExcludes reads the patterns in the system's and the user's
+// core.excludesFile, or ~/.config/git/ignore when the user hasn't set one
+func globalExcludes() []gitignore.Pattern {
+	patterns, _ := gitignore.LoadSystemPatterns(osfs.New("/"))
+
+	excludesFile := ""
+	for _, path := range gitConfigPaths() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		cfg := formatcfg.New()
+		if err := formatcfg.NewDecoder(bytes.NewReader(data)).Decode(cfg); err != nil {
+			continue
+		}
+		if file := cfg.Section("core").Option("excludesfile"); file != "" {
+			excludesFile = file
+		}
+	}
+	if excludesFile == "" {
+		if paths := gitConfigPaths(); len(paths) > 0 {
+			// git's default, next to the XDG config file
+			excludesFile = filepath.Join(filepath.Dir(paths[0]), "ignore")
+		}
+	} else if strings.HasPrefix(excludesFile, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			excludesFile = filepath.Join(home, excludesFile[2:])
+		}
+	}
+	if excludesFile == "" {
+		return patterns
+	}
+
+	f, err := os.Open(excludesFile)
+	if err != nil {
+		return patterns
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") || strings.TrimSpace(line) == "" {
+			continue
+		}
+		patterns = append(patterns, gitignore.ParsePattern(line, nil))
+	}
+	return patterns
+}
+
+// ReadIgnore returns the contents of the repository's .gitignore, or an
+// empty string if it has none
+func (r *Repository) ReadIgnore() (string, error) {
+	data, err := os.ReadFile(filepath.Join(r.path, IgnoreFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", IgnoreFile, err)
+	}
+	return string(data), nil
+}
+
+// WriteIgnore replaces the repository's .gitignore
+func (r *Repository) WriteIgnore(content string) error {
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	if err := os.WriteFile(filepath.Join(r.path, IgnoreFile), []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", IgnoreFile, err)
+	}
+	return nil
+}
+
+// AddIgnore appends patterns to the repository's .gitignore, skipping any
+// it already has, and returns the ones it added
+func (r *Repository) AddIgnore(patterns []string) ([]string, error) {
+	content, err := r.ReadIgnore()
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[string]bool)
+	for _, line := range strings.Split(content, "\n") {
+		existing[strings.TrimSpace(line)] = true
+	}
+
+	var added []string
+	for _, p := range patterns {
+		p = strings.TrimSpace(p)
+		if p == "" || existing[p] {
+			continue
+		}
+		if strings.Contains(p, "\n") {
+			return nil, fmt.Errorf("invalid pattern: %q", p)
+		}
+		existing[p] = true
+		added = append(added, p)
+	}
+	if len(added) == 0 {
+		return added, nil
+	}
+
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	return added, r.WriteIgnore(content + strings.Join(added, "\n"))
+}
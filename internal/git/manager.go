@@ -2,6 +2,7 @@
 package git
 
 import (
+	"log/slog"
 	"os"
 	"path/filepath"
 	"sync"
@@ -11,19 +12,40 @@ import (
 
 // Manager handles Git operations for Inkwell
 type Manager struct {
-	reposDir string // ~/.inkwell/repos/ for cloned repos
+	reposDir string // Where cloned repos are stored (default ~/.inkwell/repos/)
 	mu       sync.RWMutex
 	repo     *Repository // Current repository (if any)
+
+	// ops serializes the operations that change each repository, holding
+	// a lock only while something runs or waits
+	opsMu sync.Mutex
+	ops   map[string]*operationLock
 }
 
-// NewManager creates a new Git manager
-func NewManager() (*Manager, error) {
+// DefaultReposDir returns ~/.inkwell/repos
+func DefaultReposDir() (string, error) {
 	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".inkwell", "repos"), nil
+}
+
+// NewManager creates a new Git manager that clones into ~/.inkwell/repos
+func NewManager() (*Manager, error) {
+	reposDir, err := DefaultReposDir()
 	if err != nil {
 		return nil, err
 	}
+	return NewManagerAt(reposDir)
+}
 
-	reposDir := filepath.Join(homeDir, ".inkwell", "repos")
+// NewManagerAt creates a Git manager that clones into reposDir
+func NewManagerAt(reposDir string) (*Manager, error) {
+	reposDir, err := filepath.Abs(reposDir)
+	if err != nil {
+		return nil, err
+	}
 	if err := os.MkdirAll(reposDir, 0755); err != nil {
 		return nil, err
 	}
@@ -46,10 +68,24 @@ func (m *Manager) OpenRepository(path string) (*Repository, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	repo, err := Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	m.repo = repo
+	if repo != nil {
+		slog.Debug("Opened git repository", "root", repo.path, "path", path)
+	}
+	return repo, nil
+}
+
+// Open opens the git repository containing path without making it the
+// current one. Returns nil if the path is not in a git repository.
+func Open(path string) (*Repository, error) {
 	// First, find the git root (handles subdirectories)
 	gitRoot := FindGitRoot(path)
 	if gitRoot == "" {
-		m.repo = nil
 		return nil, nil // Not in a git repo
 	}
 
@@ -57,19 +93,15 @@ func (m *Manager) OpenRepository(path string) (*Repository, error) {
 	gitRepo, err := git.PlainOpen(gitRoot)
 	if err != nil {
 		if err == git.ErrRepositoryNotExists {
-			m.repo = nil
 			return nil, nil // Not a git repo, not an error
 		}
 		return nil, err
 	}
 
-	repo := &Repository{
+	return &Repository{
 		path: gitRoot,
 		repo: gitRepo,
-	}
-
-	m.repo = repo
-	return repo, nil
+	}, nil
 }
 
 // CurrentRepository returns the currently opened repository
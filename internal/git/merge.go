@@ -0,0 +1,133 @@
+package git
+
+import (
+	"strings"
+
+	"github.com/go-git/go-git/v5/utils/diff"
+)
+
+// hunk replaces base[start:end] with lines
+type hunk struct {
+	start, end int
+	lines      []string
+}
+
+// splitLines splits text after each newline, leaving no empty last line
+func splitLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// hunks lists the changes that turn base into other
+func hunks(base []string, other string) []hunk {
+	var out []hunk
+	var cur *hunk
+	pos := 0
+	for _, d := range diff.Do(strings.Join(base, ""), other) {
+		lines := splitLines(d.Text)
+		switch d.Type {
+		case 0: // Equal
+			if cur != nil {
+				out = append(out, *cur)
+				cur = nil
+			}
+			pos += len(lines)
+		case -1: // Delete
+			if cur == nil {
+				cur = &hunk{start: pos, end: pos}
+			}
+			pos += len(lines)
+			cur.end = pos
+		case 1: // Insert
+			if cur == nil {
+				cur = &hunk{start: pos, end: pos}
+			}
+			cur.lines = append(cur.lines, lines...)
+		}
+	}
+	if cur != nil {
+		out = append(out, *cur)
+	}
+	return out
+}
+
+// apply returns base[start:end] with the hunks, which lie within it, applied
+func apply(base []string, start, end int, hs []hunk) string {
+	var b strings.Builder
+	pos := start
+	for _, h := range hs {
+		b.WriteString(strings.Join(base[pos:h.start], ""))
+		b.WriteString(strings.Join(h.lines, ""))
+		pos = h.end
+	}
+	b.WriteString(strings.Join(base[pos:end], ""))
+	return b.String()
+}
+
+// mergeText merges the changes ours and theirs each made to base, as git
+// does. Where both changed the same or adjacent lines differently, both
+// versions are kept between conflict markers and conflicted is true.
+func mergeText(base, ours, theirs, oursLabel, theirsLabel string) (merged string, conflicted bool) {
+	baseLines := splitLines(base)
+	a, b := hunks(baseLines, ours), hunks(baseLines, theirs)
+
+	var out strings.Builder
+	pos := 0
+	for len(a) > 0 || len(b) > 0 {
+		// Start a group at the earliest hunk, then pull in every hunk from
+		// either side that overlaps or touches it
+		var groupA, groupB []hunk
+		start, end := 0, 0
+		if len(b) == 0 || (len(a) > 0 && a[0].start <= b[0].start) {
+			start, end = a[0].start, a[0].end
+		} else {
+			start, end = b[0].start, b[0].end
+		}
+		for {
+			if len(a) > 0 && a[0].start <= end {
+				end = max(end, a[0].end)
+				groupA, a = append(groupA, a[0]), a[1:]
+			} else if len(b) > 0 && b[0].start <= end {
+				end = max(end, b[0].end)
+				groupB, b = append(groupB, b[0]), b[1:]
+			} else {
+				break
+			}
+		}
+
+		out.WriteString(strings.Join(baseLines[pos:start], ""))
+		switch {
+		case len(groupB) == 0:
+			out.WriteString(apply(baseLines, start, end, groupA))
+		case len(groupA) == 0:
+			out.WriteString(apply(baseLines, start, end, groupB))
+		default:
+			x, y := apply(baseLines, start, end, groupA), apply(baseLines, start, end, groupB)
+			if x == y {
+				out.WriteString(x)
+				break
+			}
+			conflicted = true
+			out.WriteString("<<<<<<< " + oursLabel + "\n")
+			out.WriteString(withNewline(x))
+			out.WriteString("=======\n")
+			out.WriteString(withNewline(y))
+			out.WriteString(">>>>>>> " + theirsLabel + "\n")
+		}
+		pos = end
+	}
+	out.WriteString(strings.Join(baseLines[pos:], ""))
+	return out.String(), conflicted
+}
+
+// withNewline ends non-empty text with a newline, so a conflict marker
+// after it starts its own line
+func withNewline(text string) string {
+	if text != "" && !strings.HasSuffix(text, "\n") {
+		return text + "\n"
+	}
+	return text
+}
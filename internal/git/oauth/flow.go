@@ -0,0 +1,149 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// flowRetention is how long a finished sign-in can still be looked up
+const flowRetention = 10 * time.Minute
+
+// FlowState is where a sign-in stands
+type FlowState string
+
+const (
+	FlowPending   FlowState = "pending"   // Waiting for the user to enter the code
+	FlowComplete  FlowState = "complete"  // Signed in; the token is stored
+	FlowDenied    FlowState = "denied"    // The user declined
+	FlowExpired   FlowState = "expired"   // The code expired unused
+	FlowCancelled FlowState = "cancelled" // Cancelled from Inkwell
+	FlowFailed    FlowState = "failed"    // Anything else; see Error
+)
+
+// Flow is a sign-in in progress or recently finished
+type Flow struct {
+	ID                      string    `json:"id"`
+	Provider                string    `json:"provider"`
+	Host                    string    `json:"host"`
+	UserCode                string    `json:"userCode"`
+	VerificationURI         string    `json:"verificationUri"`
+	VerificationURIComplete string    `json:"verificationUriComplete,omitempty"`
+	ExpiresAt               time.Time `json:"expiresAt"`
+	Interval                int       `json:"interval"` // Seconds; how often polling the status is worthwhile
+	State                   FlowState `json:"state"`
+	Error                   string    `json:"error,omitempty"`
+
+	cancel   context.CancelFunc
+	finished time.Time
+}
+
+// Flows runs sign-ins in the background, saving the tokens they get
+type Flows struct {
+	mu    sync.Mutex
+	flows map[string]*Flow
+	store *Store
+}
+
+// NewFlows creates a flow runner that saves tokens to store
+func NewFlows(store *Store) *Flows {
+	return &Flows{flows: make(map[string]*Flow), store: store}
+}
+
+// Start asks a provider for a code and waits for the user to enter it in
+// the background. The returned flow has the code to show.
+func (f *Flows) Start(ctx context.Context, p *Provider) (Flow, error) {
+	code, err := p.RequestCode(ctx)
+	if err != nil {
+		return Flow{}, err
+	}
+
+	expiresIn := time.Duration(code.ExpiresIn) * time.Second
+	if expiresIn <= 0 {
+		expiresIn = 15 * time.Minute
+	}
+	waitCtx, cancel := context.WithTimeout(context.Background(), expiresIn)
+	flow := &Flow{
+		ID:                      uuid.NewString(),
+		Provider:                p.Name,
+		Host:                    p.Host,
+		UserCode:                code.UserCode,
+		VerificationURI:         code.VerificationURI,
+		VerificationURIComplete: code.VerificationURIComplete,
+		ExpiresAt:               time.Now().Add(expiresIn),
+		Interval:                code.Interval,
+		State:                   FlowPending,
+		cancel:                  cancel,
+	}
+
+	f.mu.Lock()
+	f.prune()
+	f.flows[flow.ID] = flow
+	snapshot := *flow
+	f.mu.Unlock()
+
+	go func() {
+		defer cancel()
+		token, err := p.WaitToken(waitCtx, code)
+		if err == nil {
+			err = f.store.Save(p, token)
+		}
+		f.finish(flow, err)
+	}()
+	return snapshot, nil
+}
+
+// Get returns a flow by ID
+func (f *Flows) Get(id string) (Flow, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	flow, ok := f.flows[id]
+	if !ok {
+		return Flow{}, false
+	}
+	return *flow, true
+}
+
+// Cancel stops waiting for a pending flow
+func (f *Flows) Cancel(id string) bool {
+	f.mu.Lock()
+	flow, ok := f.flows[id]
+	f.mu.Unlock()
+	if ok {
+		flow.cancel()
+	}
+	return ok
+}
+
+// finish records how a flow ended
+func (f *Flows) finish(flow *Flow, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	flow.finished = time.Now()
+	switch {
+	case err == nil:
+		flow.State = FlowComplete
+	case errors.Is(err, ErrDenied):
+		flow.State = FlowDenied
+	case errors.Is(err, ErrExpired), errors.Is(err, context.DeadlineExceeded):
+		flow.State = FlowExpired
+	case errors.Is(err, context.Canceled):
+		flow.State = FlowCancelled
+	default:
+		flow.State = FlowFailed
+		flow.Error = err.Error()
+	}
+}
+
+// prune forgets flows that finished a while ago. The caller holds f.mu.
+func (f *Flows) prune() {
+	for id, flow := range f.flows {
+		if !flow.finished.IsZero() && time.Since(flow.finished) > flowRetention {
+			delete(f.flows, id)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+// Package oauth signs in to GitHub and GitLab with the OAuth device
+// authorization flow (RFC 8628): Inkwell shows a short code, the user
+// enters it on the provider's site, and the token that comes back is kept
+// encrypted for HTTPS pushes, pulls and clones.
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Provider names
+const (
+	GitHub = "github"
+	GitLab = "gitlab"
+)
+
+// deviceGrant is the grant type of a device code token request
+const deviceGrant = "urn:ietf:params:oauth:grant-type:device_code"
+
+// Errors a token request can end with. ErrPending and ErrSlowDown mean the
+// user hasn't finished yet and the request should be repeated.
+var (
+	ErrPending  = errors.New("authorization pending")
+	ErrSlowDown = errors.New("polling too fast")
+	ErrExpired  = errors.New("the code expired before it was entered")
+	ErrDenied   = errors.New("access was denied")
+)
+
+// Provider is an OAuth application on GitHub or a GitLab instance
+type Provider struct {
+	Name      string // GitHub or GitLab
+	Host      string // Host the tokens are used for, e.g. github.com
+	ClientID  string
+	DeviceURL string // Device authorization endpoint
+	TokenURL  string // Token endpoint
+	Scope     string
+
+	// Username is sent with the token for git over HTTPS, which both
+	// providers accept in place of a password
+	Username string
+
+	Client *http.Client // nil for a client with a 30 second timeout
+}
+
+// NewGitHub returns the provider for a GitHub OAuth app. The app must have
+// device flow enabled.
+func NewGitHub(clientID string) *Provider {
+	return &Provider{
+		Name:      GitHub,
+		Host:      "github.com",
+		ClientID:  clientID,
+		DeviceURL: "https://github.com/login/device/code",
+		TokenURL:  "https://github.com/login/oauth/access_token",
+		Scope:     "repo",
+		Username:  "x-access-token",
+	}
+}
+
+// NewGitLab returns the provider for an OAuth application on the GitLab
+// instance at baseURL, such as https://gitlab.com
+func NewGitLab(baseURL, clientID string) (*Provider, error) {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil || u.Host == "" || (u.Scheme != "https" && u.Scheme != "http") {
+		return nil, fmt.Errorf("invalid GitLab URL: %s", baseURL)
+	}
+	base := u.String()
+	return &Provider{
+		Name:      GitLab,
+		Host:      u.Host,
+		ClientID:  clientID,
+		DeviceURL: base + "/oauth/authorize_device",
+		TokenURL:  base + "/oauth/token",
+		Scope:     "read_repository write_repository",
+		Username:  "oauth2",
+	}, nil
+}
+
+// DeviceCode is what the user needs to approve a sign-in
+type DeviceCode struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete,omitempty"`
+	ExpiresIn               int    `json:"expires_in"` // Seconds
+	Interval                int    `json:"interval"`   // Seconds to wait between token requests
+}
+
+// Token is an access token granted to Inkwell
+type Token struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	Scope        string    `json:"scope,omitempty"`
+	Expiry       time.Time `json:"expiry,omitempty"` // Zero for tokens that don't expire
+}
+
+// Expired reports whether a token has expired or is about to
+func (t *Token) Expired() bool {
+	return !t.Expiry.IsZero() && time.Until(t.Expiry) < time.Minute
+}
+
+// tokenResponse is the body of a token endpoint's answer
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	Scope        string `json:"scope"`
+	ExpiresIn    int    `json:"expires_in"`
+	Error        string `json:"error"`
+	Description  string `json:"error_description"`
+}
+
+// RequestCode starts a sign-in, returning the code for the user to enter
+func (p *Provider) RequestCode(ctx context.Context) (*DeviceCode, error) {
+	if p.ClientID == "" {
+		return nil, fmt.Errorf("no OAuth client ID configured for %s", p.Name)
+	}
+	var code DeviceCode
+	status, err := p.post(ctx, p.DeviceURL, url.Values{
+		"client_id": {p.ClientID},
+		"scope":     {p.Scope},
+	}, &code)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK || code.DeviceCode == "" {
+		return nil, fmt.Errorf("%s refused the sign-in request (HTTP %d)", p.Name, status)
+	}
+	if code.Interval <= 0 {
+		code.Interval = 5
+	}
+	return &code, nil
+}
+
+// PollToken asks once whether the user has approved a code. It returns
+// ErrPending or ErrSlowDown while they haven't.
+func (p *Provider) PollToken(ctx context.Context, code *DeviceCode) (*Token, error) {
+	return p.token(ctx, url.Values{
+		"client_id":   {p.ClientID},
+		"device_code": {code.DeviceCode},
+		"grant_type":  {deviceGrant},
+	})
+}
+
+// WaitToken polls until the user approves or denies a code, the code
+// expires or ctx is done
+func (p *Provider) WaitToken(ctx context.Context, code *DeviceCode) (*Token, error) {
+	interval := time.Duration(code.Interval) * time.Second
+	deadline := time.Now().Add(time.Duration(code.ExpiresIn) * time.Second)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(interval):
+		}
+		if code.ExpiresIn > 0 && time.Now().After(deadline) {
+			return nil, ErrExpired
+		}
+
+		token, err := p.PollToken(ctx, code)
+		switch {
+		case errors.Is(err, ErrPending):
+		case errors.Is(err, ErrSlowDown):
+			interval += 5 * time.Second
+		default:
+			return token, err
+		}
+	}
+}
+
+// Refresh exchanges a token's refresh token for a new token
+func (p *Provider) Refresh(ctx context.Context, token *Token) (*Token, error) {
+	if token.RefreshToken == "" {
+		return nil, errors.New("token can't be refreshed")
+	}
+	return p.token(ctx, url.Values{
+		"client_id":     {p.ClientID},
+		"refresh_token": {token.RefreshToken},
+		"grant_type":    {"refresh_token"},
+	})
+}
+
+// token makes a token request and turns the errors RFC 8628 defines into
+// the package's errors
+func (p *Provider) token(ctx context.Context, form url.Values) (*Token, error) {
+	var resp tokenResponse
+	status, err := p.post(ctx, p.TokenURL, form, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	switch resp.Error {
+	case "":
+	case "authorization_pending":
+		return nil, ErrPending
+	case "slow_down":
+		return nil, ErrSlowDown
+	case "expired_token":
+		return nil, ErrExpired
+	case "access_denied":
+		return nil, ErrDenied
+	default:
+		if resp.Description != "" {
+			return nil, fmt.Errorf("%s: %s", resp.Error, resp.Description)
+		}
+		return nil, errors.New(resp.Error)
+	}
+	if status != http.StatusOK || resp.AccessToken == "" {
+		return nil, fmt.Errorf("%s returned no token (HTTP %d)", p.Name, status)
+	}
+
+	token := &Token{AccessToken: resp.AccessToken, RefreshToken: resp.RefreshToken, Scope: resp.Scope}
+	if resp.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(resp.ExpiresIn) * time.Second)
+	}
+	return token, nil
+}
+
+// post sends a form and decodes the JSON answer, returning its status.
+// Error answers are decoded too, since they carry the OAuth error code.
+func (p *Provider) post(ctx context.Context, endpoint string, form url.Values, v interface{}) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	client := p.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to reach %s: %w", p.Name, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return resp.StatusCode, err
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return resp.StatusCode, fmt.Errorf("unexpected answer from %s (HTTP %d)", p.Name, resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
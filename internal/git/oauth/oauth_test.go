@@ -0,0 +1,157 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeProvider serves the device and token endpoints, approving the code
+// on the given poll
+func fakeProvider(t *testing.T, approveOn int) *Provider {
+	t.Helper()
+	polls := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/device", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("client_id") != "client" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"device_code":      "device-123",
+			"user_code":        "ABCD-1234",
+			"verification_uri": "https://example.com/device",
+			"expires_in":       900,
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		switch r.FormValue("grant_type") {
+		case deviceGrant:
+			polls++
+			if polls < approveOn {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{"error": "authorization_pending"})
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"access_token":  "first",
+				"refresh_token": "refresh-1",
+				"expires_in":    30, // Already within a minute of expiring
+			})
+		case "refresh_token":
+			if r.FormValue("refresh_token") != "refresh-1" {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "second", "expires_in": 7200})
+		}
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return &Provider{
+		Name:      GitLab,
+		Host:      "gitlab.example.com",
+		ClientID:  "client",
+		DeviceURL: server.URL + "/device",
+		TokenURL:  server.URL + "/token",
+		Username:  "oauth2",
+	}
+}
+
+func TestDeviceFlow(t *testing.T) {
+	p := fakeProvider(t, 2)
+	ctx := context.Background()
+
+	code, err := p.RequestCode(ctx)
+	if err != nil {
+		t.Fatalf("RequestCode() error = %v", err)
+	}
+	if code.UserCode != "ABCD-1234" || code.Interval != 5 {
+		t.Errorf("Unexpected code %+v", code)
+	}
+
+	if _, err := p.PollToken(ctx, code); !errors.Is(err, ErrPending) {
+		t.Errorf("Expected ErrPending, got %v", err)
+	}
+	token, err := p.PollToken(ctx, code)
+	if err != nil || token.AccessToken != "first" || !token.Expired() {
+		t.Fatalf("PollToken() = %+v, %v", token, err)
+	}
+
+	bad := *p
+	bad.ClientID = "other"
+	if _, err := bad.RequestCode(ctx); err == nil {
+		t.Error("Expected an error for an unknown client")
+	}
+
+	gitlab, err := NewGitLab("https://gitlab.example.com/", "id")
+	if err != nil || gitlab.TokenURL != "https://gitlab.example.com/oauth/token" || gitlab.Host != "gitlab.example.com" {
+		t.Errorf("NewGitLab() = %+v, %v", gitlab, err)
+	}
+	if _, err := NewGitLab("gitlab.example.com", "id"); err == nil {
+		t.Error("Expected an error for a URL without a scheme")
+	}
+}
+
+func TestStore(t *testing.T) {
+	p := fakeProvider(t, 1)
+	ctx := context.Background()
+	dir := t.TempDir()
+	store := NewStore(dir)
+
+	if _, _, err := store.Credential(ctx, p.Host); !errors.Is(err, ErrNotSignedIn) {
+		t.Errorf("Expected ErrNotSignedIn, got %v", err)
+	}
+
+	token, err := p.PollToken(ctx, &DeviceCode{DeviceCode: "device-123"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save(p, token); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	// Tokens are never written in the clear
+	data, err := os.ReadFile(filepath.Join(dir, tokensFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "first") || strings.Contains(string(data), "refresh-1") {
+		t.Error("Token stored unencrypted")
+	}
+	if info, err := os.Stat(filepath.Join(dir, keyFile)); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Key file missing or readable by others: %v", err)
+	}
+
+	// The expired token is refreshed, keeping the refresh token
+	username, access, err := store.Credential(ctx, "GitLab.example.com")
+	if err != nil || username != "oauth2" || access != "second" {
+		t.Fatalf("Credential() = %q, %q, %v", username, access, err)
+	}
+	_, access, _ = NewStore(dir).Credential(ctx, p.Host)
+	if access != "second" {
+		t.Errorf("Refreshed token not saved, got %q", access)
+	}
+
+	accounts, err := store.List()
+	if err != nil || len(accounts) != 1 || accounts[0].Provider != GitLab || time.Until(accounts[0].Expiry) < time.Hour {
+		t.Errorf("List() = %+v, %v", accounts, err)
+	}
+
+	if err := store.Delete(p.Host); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if err := store.Delete(p.Host); !errors.Is(err, ErrNotSignedIn) {
+		t.Errorf("Expected ErrNotSignedIn, got %v", err)
+	}
+}
@@ -0,0 +1,293 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"inkwell/internal/encryption"
+)
+
+const (
+	inkwellDir = ".inkwell"
+	tokensFile = "oauth.json"
+	keyFile    = "oauth.key"
+)
+
+// ErrNotSignedIn is returned when there is no token for a host
+var ErrNotSignedIn = errors.New("not signed in")
+
+// Account is a signed-in host, without its token
+type Account struct {
+	Host      string    `json:"host"`
+	Provider  string    `json:"provider"`
+	Scope     string    `json:"scope,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	Expiry    time.Time `json:"expiry,omitempty"` // Of the current access token; it is refreshed when it can be
+}
+
+// record is an Account as stored, with what's needed to use and refresh
+// its token
+type record struct {
+	Account
+	Username string `json:"username"`
+	ClientID string `json:"clientId"`
+	TokenURL string `json:"tokenUrl"`
+	Token    []byte `json:"token"` // The Token as JSON, sealed
+}
+
+// Store keeps tokens in ~/.inkwell, encrypted with a key of their own that
+// only the user can read. It is safe for concurrent use, also across
+// processes that don't write at the same moment.
+type Store struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// NewStore returns the store in dir
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// DefaultStore returns the store in ~/.inkwell
+func DefaultStore() (*Store, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	return NewStore(filepath.Join(home, inkwellDir)), nil
+}
+
+// Save stores the token a provider granted, replacing any for its host
+func (s *Store) Save(p *Provider, token *Token) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, err := s.key(true)
+	if err != nil {
+		return err
+	}
+	records, err := s.load()
+	if err != nil {
+		return err
+	}
+	rec := &record{
+		Account: Account{
+			Host:      strings.ToLower(p.Host),
+			Provider:  p.Name,
+			Scope:     token.Scope,
+			CreatedAt: time.Now(),
+		},
+		Username: p.Username,
+		ClientID: p.ClientID,
+		TokenURL: p.TokenURL,
+	}
+	if err := rec.seal(key, token); err != nil {
+		return err
+	}
+	records[rec.Host] = rec
+	return s.write(records)
+}
+
+// List returns the signed-in hosts in order
+func (s *Store) List() ([]Account, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	accounts := make([]Account, 0, len(records))
+	for _, rec := range records {
+		accounts = append(accounts, rec.Account)
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].Host < accounts[j].Host })
+	return accounts, nil
+}
+
+// Delete forgets the token for a host
+func (s *Store) Delete(host string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records, err := s.load()
+	if err != nil {
+		return err
+	}
+	host = strings.ToLower(host)
+	if _, ok := records[host]; !ok {
+		return ErrNotSignedIn
+	}
+	delete(records, host)
+	return s.write(records)
+}
+
+// Credential returns the git username and token to use for a host,
+// refreshing the token first if it has expired
+func (s *Store) Credential(ctx context.Context, host string) (username, token string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records, err := s.load()
+	if err != nil {
+		return "", "", err
+	}
+	rec, ok := records[strings.ToLower(host)]
+	if !ok {
+		return "", "", ErrNotSignedIn
+	}
+	key, err := s.key(false)
+	if err != nil {
+		return "", "", err
+	}
+	current, err := rec.open(key)
+	if err != nil {
+		return "", "", err
+	}
+
+	if current.Expired() && current.RefreshToken != "" {
+		p := &Provider{Name: rec.Provider, Host: rec.Host, ClientID: rec.ClientID, TokenURL: rec.TokenURL}
+		refreshed, err := p.Refresh(ctx, current)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to refresh the %s token: %w", rec.Host, err)
+		}
+		if refreshed.RefreshToken == "" {
+			refreshed.RefreshToken = current.RefreshToken
+		}
+		if err := rec.seal(key, refreshed); err != nil {
+			return "", "", err
+		}
+		if err := s.write(records); err != nil {
+			return "", "", err
+		}
+		current = refreshed
+	}
+	return rec.Username, current.AccessToken, nil
+}
+
+// Credential returns the username and token stored for the host of an
+// HTTPS remote, if the user signed in to it
+func Credential(ctx context.Context, remoteURL string) (username, token string, ok bool) {
+	u, err := url.Parse(remoteURL)
+	if err != nil || u.Host == "" {
+		return "", "", false
+	}
+	store, err := DefaultStore()
+	if err != nil {
+		return "", "", false
+	}
+	username, token, err = store.Credential(ctx, u.Host)
+	if err != nil {
+		if !errors.Is(err, ErrNotSignedIn) {
+			slog.Warn("Failed to use the OAuth token", "host", u.Host, "error", err)
+		}
+		return "", "", false
+	}
+	return username, token, true
+}
+
+func (r *record) seal(key *encryption.Key, token *Token) error {
+	data, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	if r.Token, err = key.Seal(data); err != nil {
+		return err
+	}
+	r.Scope = token.Scope
+	r.Expiry = token.Expiry
+	return nil
+}
+
+func (r *record) open(key *encryption.Key) (*Token, error) {
+	if !encryption.IsSealed(r.Token) {
+		return nil, encryption.ErrCorrupt
+	}
+	data, err := key.Open(r.Token)
+	if err != nil {
+		return nil, err
+	}
+	var token Token
+	if err := json.Unmarshal(data, &token); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// key reads the store's key, creating it if asked to
+func (s *Store) key(create bool) (*encryption.Key, error) {
+	path := filepath.Join(s.dir, keyFile)
+	raw, err := os.ReadFile(path)
+	if os.IsNotExist(err) && create {
+		raw = make([]byte, 32)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(s.dir, 0755); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(path, raw, 0600); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read the OAuth key: %w", err)
+	}
+	return encryption.NewKey(raw)
+}
+
+// load reads the records by host
+func (s *Store) load() (map[string]*record, error) {
+	records := make(map[string]*record)
+	data, err := os.ReadFile(filepath.Join(s.dir, tokensFile))
+	if os.IsNotExist(err) {
+		return records, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", tokensFile, err)
+	}
+	return records, nil
+}
+
+// write saves the records atomically, readable only by the owner
+func (s *Store) write(records map[string]*record) error {
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(s.dir, tokensFile+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(s.dir, tokensFile))
+}
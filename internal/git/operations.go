@@ -2,15 +2,17 @@ package git
 
 import (
 	"fmt"
+	"path/filepath"
 	"time"
 
+	"github.com/ProtonMail/go-crypto/openpgp"
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing/object"
 )
 
 // Stage adds files to the staging area
 func (r *Repository) Stage(paths []string) error {
-	worktree, err := r.repo.Worktree()
+	worktree, err := r.worktree()
 	if err != nil {
 		return fmt.Errorf("failed to get worktree: %w", err)
 	}
@@ -25,9 +27,33 @@ func (r *Repository) Stage(paths []string) error {
 	return nil
 }
 
+// StageChanges stages those of paths that git sees as changed, skipping
+// the rest, such as files that were never tracked and are now gone
+func (r *Repository) StageChanges(paths []string) error {
+	status, err := r.Status()
+	if err != nil {
+		return err
+	}
+	changed := make(map[string]bool, len(status.Files))
+	for _, f := range status.Files {
+		changed[f.Path] = true
+	}
+
+	var files []string
+	for _, p := range paths {
+		if changed[filepath.ToSlash(p)] {
+			files = append(files, filepath.ToSlash(p))
+		}
+	}
+	if len(files) == 0 {
+		return nil
+	}
+	return r.Stage(files)
+}
+
 // StageAll stages all changes (git add -A)
 func (r *Repository) StageAll() error {
-	worktree, err := r.repo.Worktree()
+	worktree, err := r.worktree()
 	if err != nil {
 		return fmt.Errorf("failed to get worktree: %w", err)
 	}
@@ -45,7 +71,7 @@ func (r *Repository) StageAll() error {
 
 // Unstage removes files from the staging area (git reset HEAD <files>)
 func (r *Repository) Unstage(paths []string) error {
-	worktree, err := r.repo.Worktree()
+	worktree, err := r.worktree()
 	if err != nil {
 		return fmt.Errorf("failed to get worktree: %w", err)
 	}
@@ -103,7 +129,7 @@ func (r *Repository) Unstage(paths []string) error {
 
 // UnstageAll unstages all files (git reset HEAD)
 func (r *Repository) UnstageAll() error {
-	worktree, err := r.repo.Worktree()
+	worktree, err := r.worktree()
 	if err != nil {
 		return fmt.Errorf("failed to get worktree: %w", err)
 	}
@@ -124,6 +150,9 @@ type CommitOptions struct {
 	AuthorName string   `json:"authorName,omitempty"`
 	AuthorEmail string  `json:"authorEmail,omitempty"`
 	Files      []string `json:"files,omitempty"` // If empty, commits all staged
+
+	// SignKey signs the commit when set
+	SignKey *openpgp.Entity `json:"-"`
 }
 
 // Commit creates a new commit with staged changes
@@ -132,7 +161,7 @@ func (r *Repository) Commit(opts CommitOptions) (*Commit, error) {
 		return nil, fmt.Errorf("commit message cannot be empty")
 	}
 
-	worktree, err := r.repo.Worktree()
+	worktree, err := r.worktree()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get worktree: %w", err)
 	}
@@ -164,15 +193,24 @@ func (r *Repository) Commit(opts CommitOptions) (*Commit, error) {
 		return nil, fmt.Errorf("nothing to commit, no staged changes")
 	}
 
-	// Set up author info
+	// Set up author info, falling back to the git config
 	authorName := opts.AuthorName
 	authorEmail := opts.AuthorEmail
 
+	if authorName == "" || authorEmail == "" {
+		name, email := r.configIdentity()
+		if authorName == "" {
+			authorName = name
+		}
+		if authorEmail == "" {
+			authorEmail = email
+		}
+	}
 	if authorName == "" {
-		authorName = "Inkwell User"
+		authorName = DefaultAuthorName
 	}
 	if authorEmail == "" {
-		authorEmail = "user@inkwell.local"
+		authorEmail = DefaultAuthorEmail
 	}
 
 	// Create the commit
@@ -182,6 +220,7 @@ func (r *Repository) Commit(opts CommitOptions) (*Commit, error) {
 			Email: authorEmail,
 			When:  time.Now(),
 		},
+		SignKey: opts.SignKey,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create commit: %w", err)
@@ -205,7 +244,7 @@ func (r *Repository) Commit(opts CommitOptions) (*Commit, error) {
 
 // Discard discards changes to files (git checkout -- <files>)
 func (r *Repository) Discard(paths []string) error {
-	worktree, err := r.repo.Worktree()
+	worktree, err := r.worktree()
 	if err != nil {
 		return fmt.Errorf("failed to get worktree: %w", err)
 	}
@@ -262,7 +301,7 @@ func (r *Repository) Discard(paths []string) error {
 
 // DiscardAll discards all unstaged changes
 func (r *Repository) DiscardAll() error {
-	worktree, err := r.repo.Worktree()
+	worktree, err := r.worktree()
 	if err != nil {
 		return fmt.Errorf("failed to get worktree: %w", err)
 	}
@@ -279,7 +318,7 @@ func (r *Repository) DiscardAll() error {
 
 // GetStagedFiles returns a list of staged file paths
 func (r *Repository) GetStagedFiles() ([]string, error) {
-	worktree, err := r.repo.Worktree()
+	worktree, err := r.worktree()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get worktree: %w", err)
 	}
@@ -301,7 +340,7 @@ func (r *Repository) GetStagedFiles() ([]string, error) {
 
 // GetUnstagedFiles returns a list of unstaged file paths (modified but not staged)
 func (r *Repository) GetUnstagedFiles() ([]string, error) {
-	worktree, err := r.repo.Worktree()
+	worktree, err := r.worktree()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get worktree: %w", err)
 	}
@@ -0,0 +1,307 @@
+package git
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// ErrUncommittedChanges is returned when reverting or cherry-picking over
+// changes that aren't committed
+var ErrUncommittedChanges = errors.New("commit or discard your changes first")
+
+// PickResult is the outcome of reverting or cherry-picking a commit
+type PickResult struct {
+	Commit    *Commit  `json:"commit,omitempty"` // Nil when conflicts are left to resolve
+	Conflicts []string `json:"conflicts"`        // Files left with conflict markers, sorted
+	Message   string   `json:"message"`          // Message committed, or to commit once conflicts are resolved
+}
+
+// Revert commits the inverse of a commit on top of HEAD. Changes that
+// clash with later ones are left as conflicts to resolve and commit.
+func (r *Repository) Revert(hash string, opts CommitOptions) (*PickResult, error) {
+	commit, parent, err := r.pickCommits(hash)
+	if err != nil {
+		return nil, err
+	}
+	message := fmt.Sprintf("Revert %q\n\nThis reverts commit %s.\n", firstLine(commit.Message), commit.Hash)
+	label := "reverted " + commit.Hash.String()[:7]
+	return r.pick(commit, parent, message, label, opts)
+}
+
+// CherryPick commits the changes a commit made on top of HEAD. Changes
+// that clash are left as conflicts to resolve and commit.
+func (r *Repository) CherryPick(hash string, opts CommitOptions) (*PickResult, error) {
+	commit, parent, err := r.pickCommits(hash)
+	if err != nil {
+		return nil, err
+	}
+	message := strings.TrimRight(commit.Message, "\n") + "\n\n(cherry picked from commit " + commit.Hash.String() + ")\n"
+	label := commit.Hash.String()[:7] + " (" + firstLine(commit.Message) + ")"
+	return r.pick(parent, commit, message, label, opts)
+}
+
+// pickCommits resolves a commit and its parent, nil for a root commit
+func (r *Repository) pickCommits(hash string) (commit, parent *object.Commit, err error) {
+	if r.repo == nil {
+		return nil, nil, errors.New("repository not initialized")
+	}
+	h, err := r.repo.ResolveRevision(plumbing.Revision(hash))
+	if err != nil {
+		return nil, nil, fmt.Errorf("commit %s not found", hash)
+	}
+	commit, err = r.repo.CommitObject(*h)
+	if err != nil {
+		return nil, nil, fmt.Errorf("commit %s not found", hash)
+	}
+	switch commit.NumParents() {
+	case 0:
+		return commit, nil, nil
+	case 1:
+		parent, err = commit.Parent(0)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read parent: %w", err)
+		}
+		return commit, parent, nil
+	default:
+		return nil, nil, fmt.Errorf("%s is a merge commit", commit.Hash.String()[:7])
+	}
+}
+
+// version is a file as one commit has it
+type version struct {
+	exists bool
+	hash   plumbing.Hash
+	mode   filemode.FileMode
+}
+
+// versionIn finds a file in a tree, which may be nil
+func versionIn(tree *object.Tree, p string) version {
+	if tree == nil {
+		return version{}
+	}
+	entry, err := tree.FindEntry(p)
+	if err != nil {
+		return version{}
+	}
+	return version{exists: true, hash: entry.Hash, mode: entry.Mode}
+}
+
+// pick applies the changes from one commit to another on top of HEAD:
+// from a commit's parent to it for a cherry-pick, the other way round for
+// a revert. Files HEAD has as from had them take the new version; files
+// changed since are merged line by line.
+func (r *Repository) pick(from, to *object.Commit, message, label string, opts CommitOptions) (*PickResult, error) {
+	worktree, err := r.worktree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get worktree: %w", err)
+	}
+	status, err := worktree.Status()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get status: %w", err)
+	}
+	for _, s := range status {
+		if s.Staging != git.Untracked && (s.Staging != git.Unmodified || s.Worktree != git.Unmodified) {
+			return nil, ErrUncommittedChanges
+		}
+	}
+
+	head, err := r.repo.Head()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get HEAD: %w", err)
+	}
+	headCommit, err := r.repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read HEAD: %w", err)
+	}
+
+	var fromTree, toTree *object.Tree
+	if from != nil {
+		if fromTree, err = from.Tree(); err != nil {
+			return nil, err
+		}
+	}
+	if to != nil {
+		if toTree, err = to.Tree(); err != nil {
+			return nil, err
+		}
+	}
+	headTree, err := headCommit.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := object.DiffTree(fromTree, toTree)
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff commits: %w", err)
+	}
+	seen := make(map[string]bool)
+	var paths []string
+	for _, change := range changes {
+		for _, name := range []string{change.From.Name, change.To.Name} {
+			if name != "" && !seen[name] {
+				seen[name] = true
+				paths = append(paths, name)
+			}
+		}
+	}
+	sort.Strings(paths)
+
+	type conflict struct {
+		path              string
+		base, ours, their version
+	}
+	var staged []string
+	var conflicts []conflict
+	for _, p := range paths {
+		base, ours, theirs := versionIn(fromTree, p), versionIn(headTree, p), versionIn(toTree, p)
+		switch {
+		case ours == theirs:
+			continue // Already as wanted
+		case ours == base:
+			if err := r.writeVersion(p, theirs); err != nil {
+				return nil, err
+			}
+			staged = append(staged, p)
+			continue
+		case !ours.exists || !theirs.exists:
+			// Changed on one side, deleted on the other: keep the file
+			// for the user to decide
+			if !ours.exists {
+				if err := r.writeVersion(p, theirs); err != nil {
+					return nil, err
+				}
+			}
+			conflicts = append(conflicts, conflict{p, base, ours, theirs})
+			continue
+		}
+
+		merged, clean, err := r.mergeVersions(base, ours, theirs, label)
+		if err != nil {
+			return nil, fmt.Errorf("failed to merge %s: %w", p, err)
+		}
+		if err := r.writeFile(p, merged, theirs.mode); err != nil {
+			return nil, err
+		}
+		if clean {
+			staged = append(staged, p)
+		} else {
+			conflicts = append(conflicts, conflict{p, base, ours, theirs})
+		}
+	}
+
+	if err := r.Stage(staged); err != nil {
+		return nil, err
+	}
+
+	result := &PickResult{Conflicts: []string{}, Message: message}
+	if len(conflicts) == 0 {
+		if len(staged) == 0 {
+			return nil, errors.New("nothing to commit, the changes are already there")
+		}
+		opts.Message = message
+		commit, err := r.Commit(opts)
+		if err != nil {
+			return nil, err
+		}
+		result.Commit = commit
+		return result, nil
+	}
+
+	// Record each side of the conflicts in the index, as git does, so they
+	// show as conflicted until resolved
+	idx, err := r.repo.Storer.Index()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index: %w", err)
+	}
+	for _, c := range conflicts {
+		entries := idx.Entries[:0]
+		for _, e := range idx.Entries {
+			if e.Name != c.path {
+				entries = append(entries, e)
+			}
+		}
+		idx.Entries = entries
+		for stage, v := range map[index.Stage]version{index.AncestorMode: c.base, index.OurMode: c.ours, index.TheirMode: c.their} {
+			if v.exists {
+				idx.Entries = append(idx.Entries, &index.Entry{Name: c.path, Hash: v.hash, Mode: v.mode, Stage: stage})
+			}
+		}
+		result.Conflicts = append(result.Conflicts, c.path)
+	}
+	sort.Slice(idx.Entries, func(i, j int) bool {
+		if idx.Entries[i].Name != idx.Entries[j].Name {
+			return idx.Entries[i].Name < idx.Entries[j].Name
+		}
+		return idx.Entries[i].Stage < idx.Entries[j].Stage
+	})
+	if err := r.repo.Storer.SetIndex(idx); err != nil {
+		return nil, fmt.Errorf("failed to write index: %w", err)
+	}
+	return result, nil
+}
+
+// mergeVersions merges two changed versions of a text file. Binary files
+// can't be merged, and keep our version as a conflict.
+func (r *Repository) mergeVersions(base, ours, theirs version, label string) (merged []byte, clean bool, err error) {
+	var texts [3]string
+	for i, v := range []version{base, ours, theirs} {
+		if !v.exists {
+			continue
+		}
+		if texts[i], err = r.blobContents(v.hash); err != nil {
+			return nil, false, err
+		}
+		if strings.IndexByte(texts[i][:min(len(texts[i]), sniffLen)], 0) >= 0 {
+			ours, err := r.blobContents(ours.hash)
+			return []byte(ours), false, err
+		}
+	}
+	text, conflicted := mergeText(texts[0], texts[1], texts[2], "HEAD", label)
+	return []byte(text), !conflicted, nil
+}
+
+// writeVersion puts a file in the working tree as a commit has it,
+// deleting it if the commit doesn't have it
+func (r *Repository) writeVersion(p string, v version) error {
+	if !v.exists {
+		if err := os.Remove(filepath.Join(r.path, filepath.FromSlash(p))); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to delete %s: %w", p, err)
+		}
+		return nil
+	}
+	content, err := r.blobContents(v.hash)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", p, err)
+	}
+	return r.writeFile(p, []byte(content), v.mode)
+}
+
+// writeFile writes a file in the working tree, executable if mode says so
+func (r *Repository) writeFile(p string, content []byte, mode filemode.FileMode) error {
+	full := filepath.Join(r.path, filepath.FromSlash(p))
+	if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	perm := os.FileMode(0644)
+	if mode == filemode.Executable {
+		perm = 0755
+	}
+	if existing, err := os.ReadFile(full); err == nil && bytes.Equal(existing, content) {
+		return nil
+	}
+	if err := os.WriteFile(full, content, perm); err != nil {
+		return fmt.Errorf("failed to write %s: %w", p, err)
+	}
+	return nil
+}
@@ -0,0 +1,272 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+// DefaultPublishBranch is the branch GitHub Pages serves by default
+const DefaultPublishBranch = "gh-pages"
+
+// PublishOptions holds options for publishing a folder to a branch
+type PublishOptions struct {
+	Dir         string      // Folder whose contents become the branch's files
+	Branch      string      // Defaults to gh-pages
+	Message     string      // Commit message; a default is used when empty
+	AuthorName  string      // Falls back to the git config, then the defaults
+	AuthorEmail string      // Falls back like AuthorName
+	Auth        *AuthConfig // Detected from the remote URL when nil
+	Progress    io.Writer   // Receives the remote's push progress, if set
+}
+
+// PublishResult contains the result of a publish
+type PublishResult struct {
+	Branch  string `json:"branch"`
+	Remote  string `json:"remote"`
+	Commit  string `json:"commit,omitempty"` // Empty when nothing changed
+	Files   int    `json:"files"`
+	Message string `json:"message"`
+}
+
+// Publish commits the files in opts.Dir to a branch of this repository,
+// replacing whatever the branch held, and pushes it to origin. The
+// worktree and current branch are left alone.
+func (r *Repository) Publish(ctx context.Context, opts PublishOptions) (*PublishResult, error) {
+	if r.repo == nil {
+		return nil, errors.New("repository not initialized")
+	}
+	url := r.GetRemoteURL()
+	if url == "" {
+		return nil, errors.New("no remote URL configured")
+	}
+	if opts.AuthorName == "" || opts.AuthorEmail == "" {
+		name, email := r.configIdentity()
+		if opts.AuthorName == "" {
+			opts.AuthorName = name
+		}
+		if opts.AuthorEmail == "" {
+			opts.AuthorEmail = email
+		}
+	}
+	return publish(ctx, r.repo, url, opts)
+}
+
+// PublishTo commits the files in opts.Dir to a branch of the repository at
+// url and pushes it, without cloning the repository to disk
+func PublishTo(ctx context.Context, url string, opts PublishOptions) (*PublishResult, error) {
+	if err := ValidateCloneURL(url); err != nil {
+		return nil, err
+	}
+	repo, err := git.Init(memory.NewStorage(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := repo.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{url}}); err != nil {
+		return nil, err
+	}
+	return publish(ctx, repo, url, opts)
+}
+
+func publish(ctx context.Context, repo *git.Repository, url string, opts PublishOptions) (*PublishResult, error) {
+	if opts.Branch == "" {
+		opts.Branch = DefaultPublishBranch
+	}
+	if opts.Message == "" {
+		opts.Message = "Publish " + time.Now().UTC().Format(time.RFC3339)
+	}
+	if opts.AuthorName == "" {
+		opts.AuthorName = DefaultAuthorName
+	}
+	if opts.AuthorEmail == "" {
+		opts.AuthorEmail = DefaultAuthorEmail
+	}
+
+	auth, err := remoteAuth(url, opts.Auth)
+	if err != nil {
+		return nil, err
+	}
+
+	branchRef := plumbing.NewBranchReferenceName(opts.Branch)
+	remoteRef := plumbing.NewRemoteReferenceName("origin", opts.Branch)
+	result := &PublishResult{Branch: opts.Branch, Remote: url}
+
+	// Build on what the branch holds remotely, so the push fast-forwards
+	err = repo.FetchContext(ctx, &git.FetchOptions{
+		RemoteName: "origin",
+		Auth:       auth,
+		RefSpecs:   []config.RefSpec{config.RefSpec("+" + branchRef + ":" + remoteRef)},
+	})
+	var noRef git.NoMatchingRefSpecError
+	switch {
+	case err == nil, errors.Is(err, git.NoErrAlreadyUpToDate):
+	case errors.As(err, &noRef), errors.Is(err, transport.ErrEmptyRemoteRepository):
+		// The branch doesn't exist yet
+	default:
+		return nil, fmt.Errorf("failed to fetch %s: %w", opts.Branch, err)
+	}
+
+	var parents []plumbing.Hash
+	var parentTree plumbing.Hash
+	for _, name := range []plumbing.ReferenceName{remoteRef, branchRef} {
+		ref, err := repo.Reference(name, true)
+		if err != nil {
+			continue
+		}
+		commit, err := repo.CommitObject(ref.Hash())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name.Short(), err)
+		}
+		parents = []plumbing.Hash{commit.Hash}
+		parentTree = commit.TreeHash
+		break
+	}
+
+	tree, files, err := writeTree(repo.Storer, opts.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store %s: %w", opts.Dir, err)
+	}
+	result.Files = files
+	if tree == parentTree {
+		result.Message = "Already up to date"
+		return result, nil
+	}
+
+	sig := object.Signature{Name: opts.AuthorName, Email: opts.AuthorEmail, When: time.Now()}
+	commit := &object.Commit{
+		Author:       sig,
+		Committer:    sig,
+		Message:      opts.Message,
+		TreeHash:     tree,
+		ParentHashes: parents,
+	}
+	obj := repo.Storer.NewEncodedObject()
+	if err := commit.Encode(obj); err != nil {
+		return nil, err
+	}
+	hash, err := repo.Storer.SetEncodedObject(obj)
+	if err != nil {
+		return nil, err
+	}
+	if err := repo.Storer.SetReference(plumbing.NewHashReference(branchRef, hash)); err != nil {
+		return nil, err
+	}
+
+	err = repo.PushContext(ctx, &git.PushOptions{
+		RemoteName: "origin",
+		Auth:       auth,
+		RefSpecs:   []config.RefSpec{config.RefSpec(branchRef + ":" + branchRef)},
+		Progress:   opts.Progress,
+	})
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return nil, fmt.Errorf("failed to push %s: %w", opts.Branch, err)
+	}
+	_ = repo.Storer.SetReference(plumbing.NewHashReference(remoteRef, hash))
+
+	result.Commit = hash.String()
+	result.Message = "Published"
+	return result, nil
+}
+
+// writeTree stores the files under dir as a tree, returning its hash and
+// the number of files. An empty .nojekyll file is added at the top so
+// GitHub Pages serves the files as they are.
+func writeTree(s storer.EncodedObjectStorer, dir string) (plumbing.Hash, int, error) {
+	files := 0
+	var write func(dir string, top bool) (plumbing.Hash, error)
+	write = func(dir string, top bool) (plumbing.Hash, error) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+
+		tree := &object.Tree{}
+		hasNoJekyll := false
+		for _, entry := range entries {
+			name := entry.Name()
+			if name == ".git" {
+				continue
+			}
+			full := filepath.Join(dir, name)
+			switch {
+			case entry.IsDir():
+				hash, err := write(full, false)
+				if err != nil {
+					return plumbing.ZeroHash, err
+				}
+				tree.Entries = append(tree.Entries, object.TreeEntry{Name: name, Mode: filemode.Dir, Hash: hash})
+			case entry.Type().IsRegular():
+				data, err := os.ReadFile(full)
+				if err != nil {
+					return plumbing.ZeroHash, err
+				}
+				hash, err := writeBlob(s, data)
+				if err != nil {
+					return plumbing.ZeroHash, err
+				}
+				tree.Entries = append(tree.Entries, object.TreeEntry{Name: name, Mode: filemode.Regular, Hash: hash})
+				files++
+				hasNoJekyll = hasNoJekyll || name == ".nojekyll"
+			}
+		}
+		if top && !hasNoJekyll {
+			hash, err := writeBlob(s, nil)
+			if err != nil {
+				return plumbing.ZeroHash, err
+			}
+			tree.Entries = append(tree.Entries, object.TreeEntry{Name: ".nojekyll", Mode: filemode.Regular, Hash: hash})
+		}
+
+		// Git orders entries by name, with folders compared as "name/"
+		sortKey := func(e object.TreeEntry) string {
+			if e.Mode == filemode.Dir {
+				return e.Name + "/"
+			}
+			return e.Name
+		}
+		sort.Slice(tree.Entries, func(i, j int) bool {
+			return sortKey(tree.Entries[i]) < sortKey(tree.Entries[j])
+		})
+
+		obj := s.NewEncodedObject()
+		if err := tree.Encode(obj); err != nil {
+			return plumbing.ZeroHash, err
+		}
+		return s.SetEncodedObject(obj)
+	}
+
+	hash, err := write(dir, true)
+	return hash, files, err
+}
+
+func writeBlob(s storer.EncodedObjectStorer, data []byte) (plumbing.Hash, error) {
+	obj := s.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	obj.SetSize(int64(len(data)))
+	w, err := obj.Writer()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if _, err := w.Write(data); err != nil {
+		w.Close()
+		return plumbing.ZeroHash, err
+	}
+	if err := w.Close(); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return s.SetEncodedObject(obj)
+}
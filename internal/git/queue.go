@@ -0,0 +1,150 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// MaxQueuedOperations is how many operations may wait for a repository
+// before more are turned away
+const MaxQueuedOperations = 8
+
+// ErrBusy is matched by the BusyError returned when a repository is taken
+var ErrBusy = errors.New("another git operation is in progress")
+
+// BusyError reports the operation a repository is taken by
+type BusyError struct {
+	Operation string
+	Since     time.Time
+}
+
+func (e *BusyError) Error() string {
+	if e.Operation == "" {
+		return ErrBusy.Error()
+	}
+	return fmt.Sprintf("another git operation is in progress: %s, started %s ago", e.Operation, time.Since(e.Since).Round(time.Second))
+}
+
+func (e *BusyError) Is(target error) bool {
+	return target == ErrBusy
+}
+
+// operationLock lets one operation at a time change a repository. go-git
+// doesn't guard its worktree or object store against concurrent writers.
+// Fields other than slot are guarded by Manager.opsMu.
+type operationLock struct {
+	slot    chan struct{} // Holds a token while an operation runs
+	current string        // Running operation
+	since   time.Time
+	waiting int
+	users   int // Operations running or waiting; the lock is dropped at 0
+}
+
+// Begin claims a repository for an operation, waiting in line behind the
+// one running until ctx is done. It returns a BusyError naming the running
+// operation if the wait runs out or the line is full. Call release when the
+// operation is finished.
+func (m *Manager) Begin(ctx context.Context, repo *Repository, op string) (release func(), err error) {
+	m.opsMu.Lock()
+	lock := m.operationLock(repo)
+	if lock.waiting >= MaxQueuedOperations {
+		busy := &BusyError{Operation: lock.current, Since: lock.since}
+		m.dropOperationLock(repo, lock)
+		m.opsMu.Unlock()
+		return nil, busy
+	}
+	lock.waiting++
+	m.opsMu.Unlock()
+
+	select {
+	case lock.slot <- struct{}{}:
+	case <-ctx.Done():
+		m.opsMu.Lock()
+		lock.waiting--
+		busy := &BusyError{Operation: lock.current, Since: lock.since}
+		m.dropOperationLock(repo, lock)
+		m.opsMu.Unlock()
+		return nil, busy
+	}
+
+	m.opsMu.Lock()
+	lock.waiting--
+	lock.current, lock.since = op, time.Now()
+	m.opsMu.Unlock()
+	return m.releaser(repo, lock), nil
+}
+
+// TryBegin claims a repository for an operation only if nothing else is
+// running or waiting, for work that can be skipped, such as a background
+// fetch
+func (m *Manager) TryBegin(repo *Repository, op string) (release func(), ok bool) {
+	m.opsMu.Lock()
+	defer m.opsMu.Unlock()
+	lock := m.operationLock(repo)
+	if lock.waiting > 0 {
+		m.dropOperationLock(repo, lock)
+		return nil, false
+	}
+	select {
+	case lock.slot <- struct{}{}:
+	default:
+		m.dropOperationLock(repo, lock)
+		return nil, false
+	}
+	lock.current, lock.since = op, time.Now()
+	return m.releaser(repo, lock), true
+}
+
+// Running returns the operation a repository is taken by, if any
+func (m *Manager) Running(repo *Repository) (op string, since time.Time) {
+	m.opsMu.Lock()
+	defer m.opsMu.Unlock()
+	if lock, ok := m.ops[repo.path]; ok {
+		return lock.current, lock.since
+	}
+	return "", time.Time{}
+}
+
+// releaser returns the function that frees lock, which does nothing after
+// the first call
+func (m *Manager) releaser(repo *Repository, lock *operationLock) func() {
+	released := false
+	return func() {
+		m.opsMu.Lock()
+		defer m.opsMu.Unlock()
+		if released {
+			return
+		}
+		released = true
+		lock.current, lock.since = "", time.Time{}
+		<-lock.slot // Holds this operation's token, so never blocks
+		m.dropOperationLock(repo, lock)
+	}
+}
+
+// operationLock returns the lock of a repository, by its path, counting
+// the caller as one of its users. m.opsMu must be held.
+func (m *Manager) operationLock(repo *Repository) *operationLock {
+	if m.ops == nil {
+		m.ops = make(map[string]*operationLock)
+	}
+	lock, ok := m.ops[repo.path]
+	if !ok {
+		lock = &operationLock{slot: make(chan struct{}, 1)}
+		m.ops[repo.path] = lock
+	}
+	lock.users++
+	return lock
+}
+
+// dropOperationLock stops counting a user of lock, forgetting it once
+// nothing runs or waits on it, so repositories opened once don't keep a
+// lock for the life of the manager. m.opsMu must be held.
+func (m *Manager) dropOperationLock(repo *Repository, lock *operationLock) {
+	lock.users--
+	if lock.users == 0 {
+		delete(m.ops, repo.path)
+	}
+}
@@ -1,8 +1,10 @@
 package git
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/config"
@@ -10,6 +12,15 @@ import (
 	"github.com/go-git/go-git/v5/plumbing/transport"
 )
 
+// DefaultNetworkTimeout is how long a push, pull or fetch started from the
+// server may take before it is abandoned
+const DefaultNetworkTimeout = 2 * time.Minute
+
+// ErrStaleLease is returned by a force push when the remote branch has
+// moved since it was last fetched, so forcing would drop commits nobody
+// here has seen
+var ErrStaleLease = errors.New("the remote branch has changed since it was last fetched; fetch and try again")
+
 // PushResult contains the result of a push operation.
 type PushResult struct {
 	Success bool   `json:"success"`
@@ -30,14 +41,37 @@ type FetchResult struct {
 	Message string `json:"message"`
 }
 
-// Push pushes local commits to the remote.
+// Push pushes the current branch to origin.
 func (r *Repository) Push(authConfig *AuthConfig) (*PushResult, error) {
+	return r.PushContext(context.Background(), PushRequest{}, authConfig)
+}
+
+// PushContext pushes a branch to a remote as req describes, giving up when
+// ctx is done. A forced push only goes ahead if the remote branch is where
+// it was when last fetched, like git push --force-with-lease; a branch that
+// was never fetched is forced outright.
+func (r *Repository) PushContext(ctx context.Context, req PushRequest, authConfig *AuthConfig) (*PushResult, error) {
 	if r.repo == nil {
 		return nil, errors.New("repository not initialized")
 	}
+	name := remoteName(req.Remote)
+
+	branch := req.Branch
+	if branch == "" {
+		head, err := r.repo.Head()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get HEAD: %w", err)
+		}
+		if !head.Name().IsBranch() {
+			return nil, errors.New("not on a branch")
+		}
+		branch = head.Name().Short()
+	} else if _, err := r.repo.Reference(plumbing.NewBranchReferenceName(branch), false); err != nil {
+		return nil, fmt.Errorf("branch '%s' not found", branch)
+	}
 
 	// Get remote URL to determine auth type
-	remote, err := r.repo.Remote("origin")
+	remote, err := r.repo.Remote(name)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get remote: %w", err)
 	}
@@ -48,29 +82,35 @@ func (r *Repository) Push(authConfig *AuthConfig) (*PushResult, error) {
 	}
 
 	// Get auth
-	var auth transport.AuthMethod
-	if authConfig != nil {
-		auth, err = GetAuth(*authConfig)
-		if err != nil {
-			return nil, fmt.Errorf("failed to get auth: %w", err)
-		}
-	} else {
-		// Try default auth
-		authType := DetectAuthType(urls[0])
-		if authType == AuthTypeSSH {
-			auth, err = GetAuth(AuthConfig{Type: AuthTypeSSH})
-			if err != nil {
-				// Continue without auth, might work for public repos
-				auth = nil
-			}
-		}
+	auth, err := remoteAuth(urls[0], authConfig)
+	if err != nil {
+		return nil, err
 	}
 
 	// Push
-	err = r.repo.Push(&git.PushOptions{
-		RemoteName: "origin",
+	refSpec := fmt.Sprintf("refs/heads/%s:refs/heads/%s", branch, branch)
+	opts := &git.PushOptions{
+		RemoteName: name,
 		Auth:       auth,
-	})
+	}
+	if req.Force {
+		tracking := plumbing.NewRemoteReferenceName(name, branch)
+		if ref, err := r.repo.Reference(tracking, true); err == nil {
+			// go-git rejects a broken lease with the same error as any
+			// other non-fast-forward push, so check it up front. The lease
+			// still guards against a push landing in between.
+			lease := &git.ForceWithLease{RefName: plumbing.NewBranchReferenceName(branch), Hash: ref.Hash()}
+			if err := checkLease(ctx, remote, auth, lease); err != nil {
+				return nil, err
+			}
+			opts.ForceWithLease = lease
+		} else {
+			refSpec = "+" + refSpec
+		}
+	}
+	opts.RefSpecs = []config.RefSpec{config.RefSpec(refSpec)}
+
+	err = r.repo.PushContext(ctx, opts)
 	if err != nil {
 		if errors.Is(err, git.NoErrAlreadyUpToDate) {
 			return &PushResult{
@@ -81,25 +121,58 @@ func (r *Repository) Push(authConfig *AuthConfig) (*PushResult, error) {
 		return nil, fmt.Errorf("failed to push: %w", err)
 	}
 
+	message := "Push successful"
+	if req.Force {
+		message = "Force push successful"
+	}
 	return &PushResult{
 		Success: true,
-		Message: "Push successful",
+		Message: message,
 	}, nil
 }
 
+// checkLease returns ErrStaleLease unless the remote branch is where the
+// lease expects it, as last fetched
+func checkLease(ctx context.Context, remote *git.Remote, auth transport.AuthMethod, lease *git.ForceWithLease) error {
+	refs, err := remote.ListContext(ctx, &git.ListOptions{Auth: auth})
+	if errors.Is(err, transport.ErrEmptyRemoteRepository) {
+		return ErrStaleLease
+	}
+	if err != nil {
+		return fmt.Errorf("failed to push: %w", err)
+	}
+	for _, ref := range refs {
+		if ref.Name() == lease.RefName {
+			if ref.Hash() != lease.Hash {
+				return ErrStaleLease
+			}
+			return nil
+		}
+	}
+	// Deleted since it was fetched
+	return ErrStaleLease
+}
+
 // Pull fetches and merges changes from the remote.
 func (r *Repository) Pull(authConfig *AuthConfig) (*PullResult, error) {
+	return r.PullContext(context.Background(), DefaultRemote, authConfig)
+}
+
+// PullContext fetches and merges changes from the named remote, or origin
+// when name is empty, giving up when ctx is done.
+func (r *Repository) PullContext(ctx context.Context, name string, authConfig *AuthConfig) (*PullResult, error) {
 	if r.repo == nil {
 		return nil, errors.New("repository not initialized")
 	}
+	name = remoteName(name)
 
-	wt, err := r.repo.Worktree()
+	wt, err := r.worktree()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get worktree: %w", err)
 	}
 
 	// Get remote URL to determine auth type
-	remote, err := r.repo.Remote("origin")
+	remote, err := r.repo.Remote(name)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get remote: %w", err)
 	}
@@ -110,30 +183,17 @@ func (r *Repository) Pull(authConfig *AuthConfig) (*PullResult, error) {
 	}
 
 	// Get auth
-	var auth transport.AuthMethod
-	if authConfig != nil {
-		auth, err = GetAuth(*authConfig)
-		if err != nil {
-			return nil, fmt.Errorf("failed to get auth: %w", err)
-		}
-	} else {
-		// Try default auth
-		authType := DetectAuthType(urls[0])
-		if authType == AuthTypeSSH {
-			auth, err = GetAuth(AuthConfig{Type: AuthTypeSSH})
-			if err != nil {
-				// Continue without auth
-				auth = nil
-			}
-		}
+	auth, err := remoteAuth(urls[0], authConfig)
+	if err != nil {
+		return nil, err
 	}
 
 	// Get current HEAD before pull
 	headBefore, _ := r.repo.Head()
 
 	// Pull
-	err = wt.Pull(&git.PullOptions{
-		RemoteName: "origin",
+	err = wt.PullContext(ctx, &git.PullOptions{
+		RemoteName: name,
 		Auth:       auth,
 	})
 	if err != nil {
@@ -177,12 +237,19 @@ func (r *Repository) Pull(authConfig *AuthConfig) (*PullResult, error) {
 
 // Fetch fetches changes from the remote without merging.
 func (r *Repository) Fetch(authConfig *AuthConfig) (*FetchResult, error) {
+	return r.FetchContext(context.Background(), DefaultRemote, authConfig)
+}
+
+// FetchContext fetches changes from the named remote, or origin when name
+// is empty, without merging, giving up when ctx is done.
+func (r *Repository) FetchContext(ctx context.Context, name string, authConfig *AuthConfig) (*FetchResult, error) {
 	if r.repo == nil {
 		return nil, errors.New("repository not initialized")
 	}
+	name = remoteName(name)
 
 	// Get remote URL
-	remote, err := r.repo.Remote("origin")
+	remote, err := r.repo.Remote(name)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get remote: %w", err)
 	}
@@ -193,29 +260,17 @@ func (r *Repository) Fetch(authConfig *AuthConfig) (*FetchResult, error) {
 	}
 
 	// Get auth
-	var auth transport.AuthMethod
-	if authConfig != nil {
-		auth, err = GetAuth(*authConfig)
-		if err != nil {
-			return nil, fmt.Errorf("failed to get auth: %w", err)
-		}
-	} else {
-		// Try default auth
-		authType := DetectAuthType(urls[0])
-		if authType == AuthTypeSSH {
-			auth, err = GetAuth(AuthConfig{Type: AuthTypeSSH})
-			if err != nil {
-				auth = nil
-			}
-		}
+	auth, err := remoteAuth(urls[0], authConfig)
+	if err != nil {
+		return nil, err
 	}
 
 	// Fetch
-	err = r.repo.Fetch(&git.FetchOptions{
-		RemoteName: "origin",
+	err = r.repo.FetchContext(ctx, &git.FetchOptions{
+		RemoteName: name,
 		Auth:       auth,
 		RefSpecs: []config.RefSpec{
-			"+refs/heads/*:refs/remotes/origin/*",
+			config.RefSpec("+refs/heads/*:refs/remotes/" + name + "/*"),
 		},
 	})
 	if err != nil {
@@ -272,6 +327,12 @@ func (r *Repository) SetUpstream(remoteName, remoteBranch string) error {
 
 // PushNewBranch pushes a new local branch to the remote and sets up tracking.
 func (r *Repository) PushNewBranch(authConfig *AuthConfig) (*PushResult, error) {
+	return r.PushNewBranchContext(context.Background(), authConfig)
+}
+
+// PushNewBranchContext pushes a new local branch to the remote and sets up
+// tracking, giving up when ctx is done.
+func (r *Repository) PushNewBranchContext(ctx context.Context, authConfig *AuthConfig) (*PushResult, error) {
 	if r.repo == nil {
 		return nil, errors.New("repository not initialized")
 	}
@@ -300,20 +361,9 @@ func (r *Repository) PushNewBranch(authConfig *AuthConfig) (*PushResult, error)
 	}
 
 	// Get auth
-	var auth transport.AuthMethod
-	if authConfig != nil {
-		auth, err = GetAuth(*authConfig)
-		if err != nil {
-			return nil, fmt.Errorf("failed to get auth: %w", err)
-		}
-	} else {
-		authType := DetectAuthType(urls[0])
-		if authType == AuthTypeSSH {
-			auth, err = GetAuth(AuthConfig{Type: AuthTypeSSH})
-			if err != nil {
-				auth = nil
-			}
-		}
+	auth, err := remoteAuth(urls[0], authConfig)
+	if err != nil {
+		return nil, err
 	}
 
 	// Push with refspec to create the remote branch
@@ -0,0 +1,216 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// DefaultRemote is the remote pushes, pulls and fetches use unless told
+// otherwise
+const DefaultRemote = "origin"
+
+// Remote represents a configured remote.
+type Remote struct {
+	Name string   `json:"name"`
+	URLs []string `json:"urls"` // The first is fetched from; all are pushed to
+}
+
+// remoteName returns name, or the default remote when it is empty
+func remoteName(name string) string {
+	if name == "" {
+		return DefaultRemote
+	}
+	return name
+}
+
+// ListRemotes returns the configured remotes, origin first and the rest by
+// name.
+func (r *Repository) ListRemotes() ([]Remote, error) {
+	if r.repo == nil {
+		return nil, errors.New("repository not initialized")
+	}
+
+	list, err := r.repo.Remotes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list remotes: %w", err)
+	}
+
+	remotes := make([]Remote, 0, len(list))
+	for _, remote := range list {
+		cfg := remote.Config()
+		remotes = append(remotes, Remote{Name: cfg.Name, URLs: cfg.URLs})
+	}
+	sort.Slice(remotes, func(i, j int) bool {
+		if (remotes[i].Name == DefaultRemote) != (remotes[j].Name == DefaultRemote) {
+			return remotes[i].Name == DefaultRemote
+		}
+		return remotes[i].Name < remotes[j].Name
+	})
+	return remotes, nil
+}
+
+// AddRemote adds a remote fetching every branch into refs/remotes/<name>.
+func (r *Repository) AddRemote(name, url string) error {
+	if r.repo == nil {
+		return errors.New("repository not initialized")
+	}
+	if strings.TrimSpace(url) == "" {
+		return errors.New("remote URL is required")
+	}
+
+	_, err := r.repo.CreateRemote(&config.RemoteConfig{Name: name, URLs: []string{url}})
+	if err != nil {
+		if errors.Is(err, git.ErrRemoteExists) {
+			return fmt.Errorf("remote '%s' already exists", name)
+		}
+		return fmt.Errorf("failed to add remote: %w", err)
+	}
+	return nil
+}
+
+// RemoveRemote removes a remote along with its remote-tracking branches.
+// Branches tracking it are left without an upstream.
+func (r *Repository) RemoveRemote(name string) error {
+	if r.repo == nil {
+		return errors.New("repository not initialized")
+	}
+
+	if err := r.repo.DeleteRemote(name); err != nil {
+		if errors.Is(err, git.ErrRemoteNotFound) {
+			return fmt.Errorf("remote '%s' not found", name)
+		}
+		return fmt.Errorf("failed to remove remote: %w", err)
+	}
+
+	cfg, err := r.repo.Config()
+	if err != nil {
+		return fmt.Errorf("failed to get config: %w", err)
+	}
+	for _, branch := range cfg.Branches {
+		if branch.Remote == name {
+			branch.Remote = ""
+			branch.Merge = ""
+		}
+	}
+	if err := r.repo.SetConfig(cfg); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	return r.moveRemoteRefs(name, "")
+}
+
+// RenameRemote renames a remote, moving its remote-tracking branches and
+// the branches tracking it along.
+func (r *Repository) RenameRemote(oldName, newName string) error {
+	if r.repo == nil {
+		return errors.New("repository not initialized")
+	}
+	if oldName == newName {
+		return nil
+	}
+
+	cfg, err := r.repo.Config()
+	if err != nil {
+		return fmt.Errorf("failed to get config: %w", err)
+	}
+	remote, ok := cfg.Remotes[oldName]
+	if !ok {
+		return fmt.Errorf("remote '%s' not found", oldName)
+	}
+	if _, exists := cfg.Remotes[newName]; exists {
+		return fmt.Errorf("remote '%s' already exists", newName)
+	}
+
+	renamed := &config.RemoteConfig{Name: newName, URLs: remote.URLs, Mirror: remote.Mirror}
+	oldPrefix := "refs/remotes/" + oldName + "/"
+	for _, spec := range remote.Fetch {
+		renamed.Fetch = append(renamed.Fetch, config.RefSpec(strings.Replace(string(spec), oldPrefix, "refs/remotes/"+newName+"/", 1)))
+	}
+	if err := renamed.Validate(); err != nil {
+		return fmt.Errorf("invalid remote name: %w", err)
+	}
+
+	delete(cfg.Remotes, oldName)
+	cfg.Remotes[newName] = renamed
+	for _, branch := range cfg.Branches {
+		if branch.Remote == oldName {
+			branch.Remote = newName
+		}
+	}
+	if err := r.repo.SetConfig(cfg); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	return r.moveRemoteRefs(oldName, newName)
+}
+
+// SetRemoteURL points a remote at a new URL.
+func (r *Repository) SetRemoteURL(name, url string) error {
+	if r.repo == nil {
+		return errors.New("repository not initialized")
+	}
+	if strings.TrimSpace(url) == "" {
+		return errors.New("remote URL is required")
+	}
+
+	cfg, err := r.repo.Config()
+	if err != nil {
+		return fmt.Errorf("failed to get config: %w", err)
+	}
+	remote, ok := cfg.Remotes[name]
+	if !ok {
+		return fmt.Errorf("remote '%s' not found", name)
+	}
+	remote.URLs = []string{url}
+	if err := r.repo.SetConfig(cfg); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	return nil
+}
+
+// moveRemoteRefs renames the remote-tracking references of a remote to
+// another, or deletes them when newName is empty
+func (r *Repository) moveRemoteRefs(oldName, newName string) error {
+	refs, err := r.repo.References()
+	if err != nil {
+		return fmt.Errorf("failed to list references: %w", err)
+	}
+
+	oldPrefix := "refs/remotes/" + oldName + "/"
+	var moved []*plumbing.Reference
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if strings.HasPrefix(ref.Name().String(), oldPrefix) {
+			moved = append(moved, ref)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list references: %w", err)
+	}
+
+	for _, ref := range moved {
+		if newName != "" {
+			name := plumbing.ReferenceName("refs/remotes/" + newName + "/" + strings.TrimPrefix(ref.Name().String(), oldPrefix))
+			var renamed *plumbing.Reference
+			if ref.Type() == plumbing.SymbolicReference {
+				target := strings.Replace(ref.Target().String(), oldPrefix, "refs/remotes/"+newName+"/", 1)
+				renamed = plumbing.NewSymbolicReference(name, plumbing.ReferenceName(target))
+			} else {
+				renamed = plumbing.NewHashReference(name, ref.Hash())
+			}
+			if err := r.repo.Storer.SetReference(renamed); err != nil {
+				return fmt.Errorf("failed to move %s: %w", ref.Name().Short(), err)
+			}
+		}
+		if err := r.repo.Storer.RemoveReference(ref.Name()); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", ref.Name().Short(), err)
+		}
+	}
+	return nil
+}
@@ -2,12 +2,13 @@ package git
 
 import (
 	"fmt"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
-	"github.com/go-git/go-git/v5/plumbing/object"
 )
 
 // Repository represents a git repository
@@ -29,7 +30,7 @@ func (r *Repository) RemoteURL() string {
 
 // Status returns the current git status
 func (r *Repository) Status() (*GitStatus, error) {
-	worktree, err := r.repo.Worktree()
+	worktree, err := r.worktree()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get worktree: %w", err)
 	}
@@ -46,11 +47,24 @@ func (r *Repository) Status() (*GitStatus, error) {
 		branch = head.Name().Short()
 	}
 
-	// Build file status list
+	// Build file status list. go-git doesn't report unmerged files, so
+	// they are found in the index.
 	var files []FileStatus
 	hasConflicts := false
 
+	conflicted := make(map[string]bool)
+	if paths, err := r.Conflicts(); err == nil {
+		for _, path := range paths {
+			conflicted[path] = true
+			files = append(files, FileStatus{Path: path, Status: "conflicted"})
+			hasConflicts = true
+		}
+	}
+
 	for path, fileStatus := range status {
+		if conflicted[path] {
+			continue
+		}
 		fs := FileStatus{
 			Path: path,
 		}
@@ -83,7 +97,7 @@ func (r *Repository) Status() (*GitStatus, error) {
 		files = append(files, fs)
 	}
 
-	// Calculate ahead/behind (simplified - just check if we have tracking)
+	// Calculate ahead/behind against the tracking branch, if any
 	ahead, behind := r.calculateAheadBehind()
 
 	return &GitStatus{
@@ -113,54 +127,10 @@ func (r *Repository) calculateAheadBehind() (ahead, behind int) {
 		return 0, 0
 	}
 
-	// Count commits ahead (local commits not in remote)
-	localCommit, err := r.repo.CommitObject(head.Hash())
-	if err != nil {
-		return 0, 0
-	}
-
-	remoteCommit, err := r.repo.CommitObject(remoteRef.Hash())
-	if err != nil {
-		return 0, 0
-	}
-
-	// Simple comparison - count commits reachable from local but not remote
-	localIter, err := r.repo.Log(&git.LogOptions{From: localCommit.Hash})
-	if err != nil {
-		return 0, 0
-	}
-
-	localHashes := make(map[string]bool)
-	localIter.ForEach(func(c *object.Commit) error {
-		localHashes[c.Hash.String()] = true
-		return nil
-	})
-
-	remoteIter, err := r.repo.Log(&git.LogOptions{From: remoteCommit.Hash})
+	ahead, behind, err = countAheadBehind(r.repo.Storer, head.Hash(), remoteRef.Hash())
 	if err != nil {
 		return 0, 0
 	}
-
-	remoteHashes := make(map[string]bool)
-	remoteIter.ForEach(func(c *object.Commit) error {
-		remoteHashes[c.Hash.String()] = true
-		return nil
-	})
-
-	// Count ahead (in local but not remote)
-	for hash := range localHashes {
-		if !remoteHashes[hash] {
-			ahead++
-		}
-	}
-
-	// Count behind (in remote but not local)
-	for hash := range remoteHashes {
-		if !localHashes[hash] {
-			behind++
-		}
-	}
-
 	return ahead, behind
 }
 
@@ -175,7 +145,7 @@ func (r *Repository) Branch() string {
 
 // IsClean returns true if there are no uncommitted changes
 func (r *Repository) IsClean() (bool, error) {
-	worktree, err := r.repo.Worktree()
+	worktree, err := r.worktree()
 	if err != nil {
 		return false, err
 	}
@@ -190,7 +160,13 @@ func (r *Repository) IsClean() (bool, error) {
 
 // GetRemoteURL returns the URL of the 'origin' remote
 func (r *Repository) GetRemoteURL() string {
-	remote, err := r.repo.Remote("origin")
+	return r.RemoteURLOf(DefaultRemote)
+}
+
+// RemoteURLOf returns the URL of the named remote, or origin when name is
+// empty
+func (r *Repository) RemoteURLOf(name string) string {
+	remote, err := r.repo.Remote(remoteName(name))
 	if err != nil {
 		return ""
 	}
@@ -213,3 +189,24 @@ func FilterMarkdownFiles(files []FileStatus) []FileStatus {
 	}
 	return result
 }
+
+// GroupByDirectory groups files by the directory they are in, with the
+// directories and the files in each sorted by path
+func GroupByDirectory(files []FileStatus) []StatusGroup {
+	byDir := make(map[string][]FileStatus)
+	for _, f := range files {
+		dir := path.Dir(f.Path)
+		if dir == "." {
+			dir = ""
+		}
+		byDir[dir] = append(byDir[dir], f)
+	}
+
+	groups := make([]StatusGroup, 0, len(byDir))
+	for dir, files := range byDir {
+		sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+		groups = append(groups, StatusGroup{Dir: dir, Files: files})
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Dir < groups[j].Dir })
+	return groups
+}
@@ -0,0 +1,173 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+)
+
+// What to do when a commit holds large files
+const (
+	SizeCheckWarn  = "warn"  // Commit, returning warnings
+	SizeCheckBlock = "block" // Refuse to commit unless forced
+	SizeCheckOff   = "off"   // Don't check
+)
+
+// Default commit size limits
+const (
+	DefaultMaxFileSize   = 10 << 20  // 10MB
+	DefaultMaxCommitSize = 100 << 20 // 100MB
+)
+
+// lfsMinSize is how large a file of a type better kept in Git LFS must be
+// before it is warned about, so small attachments pass
+const lfsMinSize = 1 << 20
+
+// lfsExtensions are file types git stores poorly: large, binary and
+// rewritten whole on every change
+var lfsExtensions = map[string]bool{
+	".mp4": true, ".mov": true, ".avi": true, ".mkv": true, ".webm": true, ".m4v": true,
+	".mp3": true, ".wav": true, ".flac": true, ".m4a": true, ".aac": true, ".ogg": true,
+	".zip": true, ".tar": true, ".gz": true, ".tgz": true, ".7z": true, ".rar": true,
+	".psd": true, ".ai": true, ".sketch": true, ".fig": true, ".blend": true,
+	".iso": true, ".dmg": true, ".exe": true, ".dll": true, ".so": true, ".bin": true,
+}
+
+// SizeLimits bounds what a commit may hold; zero disables a limit
+type SizeLimits struct {
+	MaxFileSize   int64 // Largest file
+	MaxCommitSize int64 // Total size of the files committed
+}
+
+// Reasons for a SizeWarning
+const (
+	SizeReasonLarge  = "large"  // File over MaxFileSize
+	SizeReasonLFS    = "lfs"    // Type better kept in Git LFS
+	SizeReasonCommit = "commit" // Files together over MaxCommitSize
+)
+
+// SizeWarning describes a file, or the whole commit, that is too large
+type SizeWarning struct {
+	Path    string `json:"path,omitempty"` // Empty for the whole commit
+	Size    int64  `json:"size"`
+	Limit   int64  `json:"limit,omitempty"`
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
+
+// ValidSizeCheck reports whether mode is a known size check mode
+func ValidSizeCheck(mode string) bool {
+	switch mode {
+	case SizeCheckWarn, SizeCheckBlock, SizeCheckOff:
+		return true
+	}
+	return false
+}
+
+// CheckCommitSize looks at what a commit would hold: the changes already
+// staged, plus files about to be staged from the working tree, or every
+// changed file when all is set. Files over the limits, and large files of
+// types better kept in Git LFS, are returned as warnings sorted by path.
+func (r *Repository) CheckCommitSize(files []string, all bool, limits SizeLimits) ([]SizeWarning, error) {
+	if r.repo == nil {
+		return nil, errors.New("repository not initialized")
+	}
+
+	worktree, err := r.worktree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get worktree: %w", err)
+	}
+	status, err := worktree.Status()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get status: %w", err)
+	}
+	idx, err := r.repo.Storer.Index()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index: %w", err)
+	}
+
+	sizes := make(map[string]int64)
+	for _, e := range idx.Entries {
+		s, ok := status[e.Name]
+		if e.Stage != 0 || !ok || (s.Staging != git.Added && s.Staging != git.Modified) {
+			continue
+		}
+		if blob, err := r.repo.BlobObject(e.Hash); err == nil {
+			sizes[e.Name] = blob.Size
+		}
+	}
+
+	// Files about to be staged count as they are in the working tree
+	stage := func(p string) {
+		info, err := os.Stat(filepath.Join(r.path, filepath.FromSlash(p)))
+		if err != nil || info.IsDir() {
+			delete(sizes, p)
+			return
+		}
+		sizes[p] = info.Size()
+	}
+	for _, f := range files {
+		stage(filepath.ToSlash(filepath.Clean(f)))
+	}
+	if all {
+		for p, s := range status {
+			if s.Worktree == git.Modified || s.Worktree == git.Untracked {
+				stage(p)
+			}
+		}
+	}
+
+	var warnings []SizeWarning
+	var total int64
+	for p, size := range sizes {
+		total += size
+		switch {
+		case limits.MaxFileSize > 0 && size > limits.MaxFileSize:
+			warnings = append(warnings, SizeWarning{
+				Path:    p,
+				Size:    size,
+				Limit:   limits.MaxFileSize,
+				Reason:  SizeReasonLarge,
+				Message: fmt.Sprintf("%s is %s, over the %s limit for a file", p, formatSize(size), formatSize(limits.MaxFileSize)),
+			})
+		case size >= lfsMinSize && lfsExtensions[strings.ToLower(path.Ext(p))]:
+			warnings = append(warnings, SizeWarning{
+				Path:    p,
+				Size:    size,
+				Reason:  SizeReasonLFS,
+				Message: fmt.Sprintf("%s is a %s %s file, better kept in Git LFS", p, formatSize(size), strings.ToLower(path.Ext(p))),
+			})
+		}
+	}
+	sort.Slice(warnings, func(i, j int) bool { return warnings[i].Path < warnings[j].Path })
+
+	if limits.MaxCommitSize > 0 && total > limits.MaxCommitSize {
+		warnings = append(warnings, SizeWarning{
+			Size:    total,
+			Limit:   limits.MaxCommitSize,
+			Reason:  SizeReasonCommit,
+			Message: fmt.Sprintf("The commit holds %s of files, over the %s limit", formatSize(total), formatSize(limits.MaxCommitSize)),
+		})
+	}
+	return warnings, nil
+}
+
+// formatSize formats a byte count for warnings
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1fGB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1fMB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1fKB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d bytes", n)
+	}
+}
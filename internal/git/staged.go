@@ -0,0 +1,156 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/utils/diff"
+)
+
+// stagedContext is the number of unchanged lines shown around changes
+const stagedContext = 3
+
+// StagedDiff returns the changes staged for the next commit as a unified
+// diff. Binary files are only named. The diff is cut short once it passes
+// maxBytes; zero doesn't limit.
+func (r *Repository) StagedDiff(maxBytes int) (string, error) {
+	if r.repo == nil {
+		return "", errors.New("repository not initialized")
+	}
+	staged, err := r.GetStagedFiles()
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(staged)
+
+	var headTree *object.Tree
+	if head, err := r.repo.Head(); err == nil {
+		commit, err := r.repo.CommitObject(head.Hash())
+		if err != nil {
+			return "", fmt.Errorf("failed to read HEAD: %w", err)
+		}
+		if headTree, err = commit.Tree(); err != nil {
+			return "", fmt.Errorf("failed to read HEAD: %w", err)
+		}
+	}
+
+	idx, err := r.repo.Storer.Index()
+	if err != nil {
+		return "", fmt.Errorf("failed to read index: %w", err)
+	}
+	indexed := make(map[string]plumbing.Hash, len(idx.Entries))
+	for _, e := range idx.Entries {
+		indexed[e.Name] = e.Hash
+	}
+
+	var out strings.Builder
+	for _, path := range staged {
+		var before, after string
+		var inHead, inIndex bool
+		if headTree != nil {
+			if f, err := headTree.File(path); err == nil {
+				if before, err = f.Contents(); err != nil {
+					return "", err
+				}
+				inHead = true
+			}
+		}
+		if hash, ok := indexed[path]; ok {
+			if after, err = r.blobContents(hash); err != nil {
+				return "", err
+			}
+			inIndex = true
+		}
+
+		writeUnified(&out, path, before, after, inHead, inIndex)
+		if maxBytes > 0 && out.Len() > maxBytes {
+			text := out.String()[:maxBytes]
+			if i := strings.LastIndexByte(text, '\n'); i >= 0 {
+				text = text[:i+1]
+			}
+			return text + "[diff truncated]\n", nil
+		}
+	}
+	return out.String(), nil
+}
+
+func (r *Repository) blobContents(hash plumbing.Hash) (string, error) {
+	blob, err := r.repo.BlobObject(hash)
+	if err != nil {
+		return "", err
+	}
+	reader, err := blob.Reader()
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	return string(data), err
+}
+
+// writeUnified writes the difference between two versions of a file with
+// a few lines of context around each change
+func writeUnified(out *strings.Builder, path, before, after string, existed, exists bool) {
+	from, to := "a/"+path, "b/"+path
+	if !existed {
+		from = "/dev/null"
+	}
+	if !exists {
+		to = "/dev/null"
+	}
+	fmt.Fprintf(out, "diff --git a/%s b/%s\n", path, path)
+	if strings.ContainsRune(before, 0) || strings.ContainsRune(after, 0) {
+		fmt.Fprintf(out, "Binary files %s and %s differ\n", from, to)
+		return
+	}
+	fmt.Fprintf(out, "--- %s\n+++ %s\n", from, to)
+
+	diffs := diff.Do(before, after)
+	for i, d := range diffs {
+		lines := strings.SplitAfter(d.Text, "\n")
+		if lines[len(lines)-1] == "" {
+			lines = lines[:len(lines)-1]
+		}
+		switch d.Type {
+		case 0: // Equal
+			first, last := i == 0, i == len(diffs)-1
+			if len(lines) > 2*stagedContext || first || last {
+				head, tail := lines, lines
+				if first {
+					head = nil
+				} else if len(head) > stagedContext {
+					head = head[:stagedContext]
+				}
+				if last {
+					tail = nil
+				} else if len(tail) > stagedContext {
+					tail = tail[len(tail)-stagedContext:]
+				}
+				writeLines(out, " ", head)
+				out.WriteString("@@\n")
+				writeLines(out, " ", tail)
+				continue
+			}
+			writeLines(out, " ", lines)
+		case 1: // Add
+			writeLines(out, "+", lines)
+		case -1: // Delete
+			writeLines(out, "-", lines)
+		}
+	}
+}
+
+func writeLines(out *strings.Builder, prefix string, lines []string) {
+	for _, line := range lines {
+		out.WriteString(prefix)
+		out.WriteString(line)
+		if !strings.HasSuffix(line, "\n") {
+			out.WriteString("\n")
+		}
+	}
+}
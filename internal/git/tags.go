@@ -0,0 +1,245 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// Tag represents a git tag.
+type Tag struct {
+	Name      string    `json:"name"`
+	Hash      string    `json:"hash"`              // Commit the tag points at
+	Annotated bool      `json:"annotated"`         // Has a message and tagger of its own
+	Message   string    `json:"message,omitempty"` // Annotated tags only
+	Tagger    string    `json:"tagger,omitempty"`  // Annotated tags only
+	Date      time.Time `json:"date,omitempty"`    // When tagged, or when the commit was made for lightweight tags
+	Subject   string    `json:"subject,omitempty"` // First line of the commit's message
+}
+
+// TagOptions describes a tag to create. A tag with a message is
+// annotated; one without is lightweight.
+type TagOptions struct {
+	Name        string `json:"name"`
+	Target      string `json:"target,omitempty"` // Revision to tag; HEAD when empty
+	Message     string `json:"message,omitempty"`
+	TaggerName  string `json:"taggerName,omitempty"`
+	TaggerEmail string `json:"taggerEmail,omitempty"`
+
+	// SignKey signs annotated tags when set
+	SignKey *openpgp.Entity `json:"-"`
+}
+
+// ListTags returns all tags, newest first.
+func (r *Repository) ListTags() ([]Tag, error) {
+	if r.repo == nil {
+		return nil, errors.New("repository not initialized")
+	}
+
+	iter, err := r.repo.Tags()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags: %w", err)
+	}
+
+	tags := []Tag{}
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		tag, err := r.tag(ref)
+		if err != nil {
+			slog.Debug("Skipping unreadable tag", "tag", ref.Name().Short(), "error", err)
+			return nil
+		}
+		tags = append(tags, *tag)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to iterate tags: %w", err)
+	}
+
+	sort.SliceStable(tags, func(i, j int) bool {
+		if !tags[i].Date.Equal(tags[j].Date) {
+			return tags[i].Date.After(tags[j].Date)
+		}
+		return tags[i].Name < tags[j].Name
+	})
+	return tags, nil
+}
+
+// tag describes the tag a reference names, following annotated tags to
+// their commit
+func (r *Repository) tag(ref *plumbing.Reference) (*Tag, error) {
+	tag := &Tag{Name: ref.Name().Short()}
+	hash := ref.Hash()
+
+	if obj, err := r.repo.TagObject(hash); err == nil {
+		tag.Annotated = true
+		tag.Message = strings.TrimSpace(obj.Message)
+		tag.Tagger = obj.Tagger.Name + " <" + obj.Tagger.Email + ">"
+		tag.Date = obj.Tagger.When
+		commit, err := obj.Commit()
+		if err != nil {
+			return nil, err
+		}
+		tag.Hash = commit.Hash.String()
+		tag.Subject = firstLine(commit.Message)
+		return tag, nil
+	} else if !errors.Is(err, plumbing.ErrObjectNotFound) {
+		return nil, err
+	}
+
+	commit, err := r.repo.CommitObject(hash)
+	if err != nil {
+		return nil, err
+	}
+	tag.Hash = commit.Hash.String()
+	tag.Date = commit.Committer.When
+	tag.Subject = firstLine(commit.Message)
+	return tag, nil
+}
+
+// firstLine returns the subject of a commit message
+func firstLine(message string) string {
+	subject, _, _ := strings.Cut(strings.TrimSpace(message), "\n")
+	return subject
+}
+
+// CreateTag tags a commit, annotating the tag when opts has a message.
+func (r *Repository) CreateTag(opts TagOptions) (*Tag, error) {
+	if r.repo == nil {
+		return nil, errors.New("repository not initialized")
+	}
+	if opts.Name == "" {
+		return nil, errors.New("tag name cannot be empty")
+	}
+
+	target := opts.Target
+	if target == "" {
+		target = "HEAD"
+	}
+	hash, err := r.repo.ResolveRevision(plumbing.Revision(target))
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", target, err)
+	}
+	if _, err := r.repo.CommitObject(*hash); err != nil {
+		return nil, fmt.Errorf("%s is not a commit", target)
+	}
+
+	var createOpts *git.CreateTagOptions
+	if opts.Message != "" {
+		name, email := opts.TaggerName, opts.TaggerEmail
+		if name == "" || email == "" {
+			cfgName, cfgEmail := r.configIdentity()
+			if name == "" {
+				name = cfgName
+			}
+			if email == "" {
+				email = cfgEmail
+			}
+		}
+		if name == "" {
+			name = DefaultAuthorName
+		}
+		if email == "" {
+			email = DefaultAuthorEmail
+		}
+		createOpts = &git.CreateTagOptions{
+			Tagger:  &object.Signature{Name: name, Email: email, When: time.Now()},
+			Message: opts.Message,
+			SignKey: opts.SignKey,
+		}
+	}
+
+	ref, err := r.repo.CreateTag(opts.Name, *hash, createOpts)
+	if err != nil {
+		if errors.Is(err, git.ErrTagExists) {
+			return nil, fmt.Errorf("tag '%s' already exists", opts.Name)
+		}
+		return nil, fmt.Errorf("failed to create tag: %w", err)
+	}
+	return r.tag(ref)
+}
+
+// DeleteTag deletes a local tag. Copies already pushed stay on the remote.
+func (r *Repository) DeleteTag(name string) error {
+	if r.repo == nil {
+		return errors.New("repository not initialized")
+	}
+
+	if err := r.repo.DeleteTag(name); err != nil {
+		if errors.Is(err, git.ErrTagNotFound) {
+			return fmt.Errorf("tag '%s' not found", name)
+		}
+		return fmt.Errorf("failed to delete tag: %w", err)
+	}
+	return nil
+}
+
+// PushTags pushes the named tags to the remote, or every tag when names is
+// empty. Tags already on the remote are left as they are there.
+func (r *Repository) PushTags(names []string, authConfig *AuthConfig) (*PushResult, error) {
+	return r.PushTagsContext(context.Background(), names, authConfig)
+}
+
+// PushTagsContext is PushTags giving up when ctx is done.
+func (r *Repository) PushTagsContext(ctx context.Context, names []string, authConfig *AuthConfig) (*PushResult, error) {
+	if r.repo == nil {
+		return nil, errors.New("repository not initialized")
+	}
+
+	remote, err := r.repo.Remote("origin")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get remote: %w", err)
+	}
+
+	urls := remote.Config().URLs
+	if len(urls) == 0 {
+		return nil, errors.New("no remote URL configured")
+	}
+
+	// Get auth
+	auth, err := remoteAuth(urls[0], authConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	refSpecs := []config.RefSpec{"refs/tags/*:refs/tags/*"}
+	if len(names) > 0 {
+		refSpecs = refSpecs[:0]
+		for _, name := range names {
+			refName := plumbing.NewTagReferenceName(name)
+			if _, err := r.repo.Reference(refName, false); err != nil {
+				return nil, fmt.Errorf("tag '%s' not found", name)
+			}
+			refSpecs = append(refSpecs, config.RefSpec(refName+":"+refName))
+		}
+	}
+
+	err = r.repo.PushContext(ctx, &git.PushOptions{
+		RemoteName: "origin",
+		Auth:       auth,
+		RefSpecs:   refSpecs,
+	})
+	if err != nil {
+		if errors.Is(err, git.NoErrAlreadyUpToDate) {
+			return &PushResult{
+				Success: true,
+				Message: "Already up to date",
+			}, nil
+		}
+		return nil, fmt.Errorf("failed to push tags: %w", err)
+	}
+
+	return &PushResult{
+		Success: true,
+		Message: "Tags pushed",
+	}, nil
+}
@@ -4,13 +4,21 @@ import "time"
 
 // GitStatus represents the current state of a git repository
 type GitStatus struct {
-	Branch       string       `json:"branch"`
-	Ahead        int          `json:"ahead"`
-	Behind       int          `json:"behind"`
-	Files        []FileStatus `json:"files"`
-	HasConflicts bool         `json:"hasConflicts"`
-	IsClean      bool         `json:"isClean"`
-	RemoteURL    string       `json:"remoteUrl,omitempty"`
+	Branch       string        `json:"branch"`
+	Ahead        int           `json:"ahead"`
+	Behind       int           `json:"behind"`
+	Files        []FileStatus  `json:"files"`
+	HasConflicts bool          `json:"hasConflicts"`
+	IsClean      bool          `json:"isClean"`
+	RemoteURL    string        `json:"remoteUrl,omitempty"`
+	Groups       []StatusGroup `json:"groups,omitempty"`   // Files by directory, when asked for
+	Filtered     int           `json:"filtered,omitempty"` // Changed files left out of Files
+}
+
+// StatusGroup holds the changed files in one directory
+type StatusGroup struct {
+	Dir   string       `json:"dir"` // Relative to the repository; empty for the top
+	Files []FileStatus `json:"files"`
 }
 
 // FileStatus represents a file's git status
@@ -100,7 +108,7 @@ type CheckoutRequest struct {
 type PushRequest struct {
 	Remote string `json:"remote,omitempty"` // Default: "origin"
 	Branch string `json:"branch,omitempty"` // Default: current branch
-	Force  bool   `json:"force,omitempty"`
+	Force  bool   `json:"force,omitempty"`  // Overwrite the remote branch, unless it moved since the last fetch
 }
 
 // PullRequest represents a request to pull
@@ -0,0 +1,228 @@
+package git
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/utils/diff"
+)
+
+// side is one version of a file in a working diff
+type side struct {
+	exists bool
+	size   int64
+	read   func() ([]byte, error)
+}
+
+// GetWorkingDiff returns the uncommitted changes to path, or to every file
+// when path is empty, within DefaultDiffLimits.
+func (r *Repository) GetWorkingDiff(path string, staged bool) ([]FileDiff, error) {
+	return r.GetWorkingDiffWithLimits(path, staged, DefaultDiffLimits)
+}
+
+// GetWorkingDiffWithLimits returns the uncommitted changes to path, a file
+// or folder, or to every file when path is empty. Staged changes are
+// diffed against HEAD; otherwise the working tree is diffed against the
+// index, as git diff does, and untracked files show as added. Files cut
+// short by the limits are marked truncated.
+func (r *Repository) GetWorkingDiffWithLimits(path string, staged bool, limits DiffLimits) ([]FileDiff, error) {
+	if r.repo == nil {
+		return nil, errors.New("repository not initialized")
+	}
+
+	worktree, err := r.worktree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get worktree: %w", err)
+	}
+	status, err := worktree.Status()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get status: %w", err)
+	}
+
+	var headTree *object.Tree
+	if head, err := r.repo.Head(); err == nil {
+		commit, err := r.repo.CommitObject(head.Hash())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read HEAD: %w", err)
+		}
+		if headTree, err = commit.Tree(); err != nil {
+			return nil, fmt.Errorf("failed to read HEAD: %w", err)
+		}
+	}
+
+	idx, err := r.repo.Storer.Index()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index: %w", err)
+	}
+	indexed := make(map[string]plumbing.Hash, len(idx.Entries))
+	for _, e := range idx.Entries {
+		// Conflicted files have entries from each side instead of stage 0.
+		// index.Merged can't be used here, as go-git defines it as 1.
+		if e.Stage == 0 {
+			indexed[e.Name] = e.Hash
+		}
+	}
+
+	path = strings.Trim(filepath.ToSlash(path), "/")
+	var paths []string
+	for p, s := range status {
+		if path != "" && p != path && !strings.HasPrefix(p, path+"/") {
+			continue
+		}
+		if staged && (s.Staging == git.Unmodified || s.Staging == git.Untracked) {
+			continue
+		}
+		if !staged && s.Worktree == git.Unmodified {
+			continue
+		}
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	binary := r.newBinaryChecker(headTree)
+	files := []FileDiff{}
+	used := 0
+	for _, p := range paths {
+		var from, to side
+		if staged {
+			if headTree != nil {
+				if f, err := headTree.File(p); err == nil {
+					from = r.blobSide(f.Hash)
+				}
+			}
+			if hash, ok := indexed[p]; ok {
+				to = r.blobSide(hash)
+			}
+		} else {
+			if hash, ok := indexed[p]; ok && status[p].Worktree != git.Untracked {
+				from = r.blobSide(hash)
+			}
+			to = r.fileSide(p)
+		}
+
+		maxLines := limits.FileLines
+		if limits.TotalLines > 0 {
+			remaining := max(limits.TotalLines-used, 0)
+			if maxLines == 0 || remaining < maxLines {
+				maxLines = remaining
+			}
+		} else if maxLines == 0 {
+			maxLines = -1
+		}
+
+		fileDiff, err := workingFileDiff(p, from, to, binary.attrBinary(p), limits.FileBytes, maxLines)
+		if err != nil {
+			return nil, fmt.Errorf("failed to diff %s: %w", p, err)
+		}
+		used += len(fileDiff.Lines)
+		files = append(files, *fileDiff)
+	}
+	return files, nil
+}
+
+// blobSide is a version of a file stored in the repository
+func (r *Repository) blobSide(hash plumbing.Hash) side {
+	s := side{exists: true, read: func() ([]byte, error) {
+		text, err := r.blobContents(hash)
+		return []byte(text), err
+	}}
+	if blob, err := r.repo.BlobObject(hash); err == nil {
+		s.size = blob.Size
+	}
+	return s
+}
+
+// fileSide is the version of a file in the working tree
+func (r *Repository) fileSide(p string) side {
+	full := filepath.Join(r.path, filepath.FromSlash(p))
+	info, err := os.Stat(full)
+	if err != nil || info.IsDir() {
+		return side{}
+	}
+	return side{exists: true, size: info.Size(), read: func() ([]byte, error) {
+		return os.ReadFile(full)
+	}}
+}
+
+// workingFileDiff diffs two versions of a file, with the same limits as
+// changeToFileDiff. Lines are numbered in both versions.
+func workingFileDiff(p string, from, to side, attrBinary bool, maxBytes int64, maxLines int) (*FileDiff, error) {
+	fileDiff := &FileDiff{Path: p, Action: "modified"}
+	switch {
+	case !from.exists:
+		fileDiff.Action = "added"
+	case !to.exists:
+		fileDiff.Action = "deleted"
+	}
+
+	if attrBinary {
+		fileDiff.Binary = true
+		fileDiff.OldSize, fileDiff.NewSize = from.size, to.size
+		return fileDiff, nil
+	}
+	if maxLines == 0 || (maxBytes > 0 && max(from.size, to.size) > maxBytes) {
+		fileDiff.Truncated = true
+		return fileDiff, nil
+	}
+
+	var before, after []byte
+	var err error
+	if from.exists {
+		if before, err = from.read(); err != nil {
+			return nil, err
+		}
+	}
+	if to.exists {
+		if after, err = to.read(); err != nil {
+			return nil, err
+		}
+	}
+	if bytes.IndexByte(before[:min(len(before), sniffLen)], 0) >= 0 || bytes.IndexByte(after[:min(len(after), sniffLen)], 0) >= 0 {
+		fileDiff.Binary = true
+		fileDiff.OldSize, fileDiff.NewSize = from.size, to.size
+		return fileDiff, nil
+	}
+
+	oldLine, newLine := 0, 0
+	for _, d := range diff.Do(string(before), string(after)) {
+		lines := strings.SplitAfter(d.Text, "\n")
+		if lines[len(lines)-1] == "" {
+			lines = lines[:len(lines)-1]
+		}
+		for _, line := range lines {
+			diffLine := DiffLine{Content: strings.TrimSuffix(line, "\n")}
+			switch d.Type {
+			case 0: // Equal
+				oldLine++
+				newLine++
+				diffLine.Type = "context"
+				diffLine.OldLine, diffLine.NewLine = oldLine, newLine
+			case 1: // Add
+				newLine++
+				diffLine.Type = "add"
+				diffLine.NewLine = newLine
+				fileDiff.Additions++
+			case -1: // Delete
+				oldLine++
+				diffLine.Type = "delete"
+				diffLine.OldLine = oldLine
+				fileDiff.Deletions++
+			}
+
+			if maxLines >= 0 && len(fileDiff.Lines) >= maxLines {
+				fileDiff.Truncated = true
+				continue
+			}
+			fileDiff.Lines = append(fileDiff.Lines, diffLine)
+		}
+	}
+	return fileDiff, nil
+}
@@ -0,0 +1,180 @@
+// Package hooks runs user-configured commands after files are saved and
+// on uploads before they are kept
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultTimeout is used when no timeout is configured
+	DefaultTimeout = 30 * time.Second
+
+	// maxOutputBytes caps the combined output kept per command
+	maxOutputBytes = 64 * 1024
+)
+
+// Result describes one finished hook command
+type Result struct {
+	Command  string `json:"command"`
+	Path     string `json:"path"`
+	ExitCode int    `json:"exitCode"`
+	Output   string `json:"output"`
+	Error    string `json:"error,omitempty"`
+	Duration int64  `json:"durationMs"`
+	TimedOut bool   `json:"timedOut,omitempty"`
+}
+
+// Runner executes on-save commands. Commands are run through the shell
+// after expanding these placeholders, each shell-quoted:
+//
+//	{path}  path relative to the root directory
+//	{file}  absolute path of the saved file
+//	{dir}   absolute directory containing the file
+//	{name}  file name without directory
+//	{root}  absolute root directory
+type Runner struct {
+	commands []string
+	timeout  time.Duration
+}
+
+// NewRunner creates a runner for the given commands
+func NewRunner(commands []string, timeout time.Duration) *Runner {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Runner{
+		commands: commands,
+		timeout:  timeout,
+	}
+}
+
+// Enabled reports whether any commands are configured
+func (r *Runner) Enabled() bool {
+	return r != nil && len(r.commands) > 0
+}
+
+// Run executes every command for a saved file in order, calling report
+// after each one finishes. It blocks until all commands have run.
+func (r *Runner) Run(ctx context.Context, rootDir, relativePath string, report func(Result)) {
+	if !r.Enabled() {
+		return
+	}
+
+	for _, command := range r.commands {
+		result := r.runOne(ctx, command, rootDir, relativePath)
+		if report != nil {
+			report(result)
+		}
+	}
+}
+
+// runOne executes a single command with the configured timeout
+func (r *Runner) runOne(ctx context.Context, command, rootDir, relativePath string) Result {
+	result := execute(ctx, Expand(command, rootDir, relativePath), rootDir, r.timeout)
+	result.Path = relativePath
+	return result
+}
+
+// execute runs an expanded command in dir, stopping it after timeout
+func execute(ctx context.Context, command, dir string, timeout time.Duration) Result {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	result := Result{Command: command}
+
+	cmd := shellCommand(ctx, command)
+	cmd.Dir = dir
+	// Don't wait on children of the shell that still hold the output pipe
+	cmd.WaitDelay = time.Second
+
+	output := &limitedBuffer{limit: maxOutputBytes}
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	start := time.Now()
+	err := cmd.Run()
+	result.Duration = time.Since(start).Milliseconds()
+	result.Output = output.String()
+
+	if err != nil {
+		result.Error = err.Error()
+		result.ExitCode = -1
+
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			result.ExitCode = exitErr.ExitCode()
+		}
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			result.TimedOut = true
+			result.Error = "command timed out after " + timeout.String()
+		}
+	}
+
+	return result
+}
+
+// Expand substitutes path placeholders in a command
+func Expand(command, rootDir, relativePath string) string {
+	file := filepath.Join(rootDir, relativePath)
+
+	replacer := strings.NewReplacer(
+		"{path}", quote(relativePath),
+		"{file}", quote(file),
+		"{dir}", quote(filepath.Dir(file)),
+		"{name}", quote(filepath.Base(file)),
+		"{root}", quote(rootDir),
+	)
+	return replacer.Replace(command)
+}
+
+// shellCommand builds a command run through the platform shell
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
+
+// quote makes a value safe to substitute into a shell command
+func quote(value string) string {
+	if runtime.GOOS == "windows" {
+		return `"` + strings.ReplaceAll(value, `"`, `""`) + `"`
+	}
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// limitedBuffer keeps the first limit bytes written and discards the rest
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if remaining := b.limit - b.buf.Len(); remaining > 0 {
+		if len(p) > remaining {
+			b.buf.Write(p[:remaining])
+			b.truncated = true
+		} else {
+			b.buf.Write(p)
+		}
+	} else if len(p) > 0 {
+		b.truncated = true
+	}
+	return len(p), nil
+}
+
+func (b *limitedBuffer) String() string {
+	if b.truncated {
+		return b.buf.String() + "\n[output truncated]"
+	}
+	return b.buf.String()
+}
@@ -0,0 +1,113 @@
+package hooks
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExpand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("POSIX quoting")
+	}
+
+	got := Expand("fmt {path} {file} {dir} {name} {root}", "/notes", "sub/it's.md")
+	want := `fmt 'sub/it'\''s.md' '/notes/sub/it'\''s.md' '/notes/sub' 'it'\''s.md' '/notes'`
+	if got != want {
+		t.Errorf("Expand() = %q, want %q", got, want)
+	}
+}
+
+func TestRunnerRun(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires sh")
+	}
+
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "a.md"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewRunner([]string{"cat {file}", "exit 3"}, 0)
+
+	var results []Result
+	r.Run(context.Background(), root, "a.md", func(res Result) {
+		results = append(results, res)
+	})
+
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+	if results[0].ExitCode != 0 || results[0].Output != "hello" {
+		t.Errorf("Unexpected first result: %+v", results[0])
+	}
+	if results[1].ExitCode != 3 || results[1].Error == "" {
+		t.Errorf("Unexpected second result: %+v", results[1])
+	}
+}
+
+func TestRunnerTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires sh")
+	}
+
+	r := NewRunner([]string{"sleep 5"}, 50*time.Millisecond)
+
+	var result Result
+	r.Run(context.Background(), t.TempDir(), "a.md", func(res Result) {
+		result = res
+	})
+
+	if !result.TimedOut || !strings.Contains(result.Error, "timed out") {
+		t.Errorf("Expected timeout, got %+v", result)
+	}
+}
+
+func TestLimitedBuffer(t *testing.T) {
+	b := &limitedBuffer{limit: 4}
+	b.Write([]byte("abc"))
+	b.Write([]byte("def"))
+
+	if got := b.String(); got != "abcd\n[output truncated]" {
+		t.Errorf("String() = %q", got)
+	}
+}
+
+func TestScanner(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires sh")
+	}
+
+	// Reject anything mentioning EICAR, as a stand-in for a virus scanner
+	s := NewScanner(`if grep -q EICAR {file}; then echo {name}: Eicar-Signature FOUND; exit 1; fi`, 0)
+	if !s.Enabled() {
+		t.Fatal("Expected the scanner enabled")
+	}
+
+	if err := s.ScanReader(context.Background(), strings.NewReader("just a photo"), "photo.png"); err != nil {
+		t.Errorf("Expected a clean upload accepted, got %v", err)
+	}
+
+	err := s.ScanReader(context.Background(), strings.NewReader("X5O EICAR test"), "evil.pdf")
+	scanErr, ok := err.(*ScanError)
+	if !ok {
+		t.Fatalf("Expected a ScanError, got %v", err)
+	}
+	if scanErr.Result.ExitCode != 1 || !strings.Contains(err.Error(), "evil.pdf: Eicar-Signature FOUND") {
+		t.Errorf("Unexpected rejection: %v (%+v)", err, scanErr.Result)
+	}
+
+	// A scanner that can't run rejects everything
+	broken := NewScanner("/nonexistent/clamscan {file}", 0)
+	if err := broken.ScanReader(context.Background(), strings.NewReader("x"), "a.txt"); err == nil {
+		t.Error("Expected a failing scanner to reject the upload")
+	}
+
+	if err := NewScanner("", 0).ScanReader(context.Background(), strings.NewReader("x"), "a.txt"); err != nil {
+		t.Errorf("Expected no scanner to accept everything, got %v", err)
+	}
+}
@@ -0,0 +1,106 @@
+package hooks
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultScanTimeout is used when no scan timeout is configured. Virus
+// scanners can take a while to load their signatures.
+const DefaultScanTimeout = 2 * time.Minute
+
+// ScanError is returned for uploads the scanner rejected, or that could
+// not be scanned
+type ScanError struct {
+	Name   string
+	Result Result
+}
+
+func (e *ScanError) Error() string {
+	reason := e.Result.Error
+	if line, _, _ := strings.Cut(strings.TrimSpace(e.Result.Output), "\n"); line != "" {
+		reason = line
+	}
+	return fmt.Sprintf("%s was rejected by the upload scanner: %s", e.Name, reason)
+}
+
+// Scanner checks uploads with a user-configured command, such as clamscan,
+// before they are kept. The command is run through the shell after
+// expanding these placeholders, each shell-quoted:
+//
+//	{file}  absolute path of the upload, in a temporary location
+//	{name}  file name the upload was given
+//
+// Any exit status but zero rejects the upload, as does a command that
+// can't be run or times out.
+type Scanner struct {
+	command string
+	timeout time.Duration
+}
+
+// NewScanner creates a scanner for command; an empty command scans nothing
+func NewScanner(command string, timeout time.Duration) *Scanner {
+	if timeout <= 0 {
+		timeout = DefaultScanTimeout
+	}
+	return &Scanner{
+		command: strings.TrimSpace(command),
+		timeout: timeout,
+	}
+}
+
+// Enabled reports whether a command is configured
+func (s *Scanner) Enabled() bool {
+	return s != nil && s.command != ""
+}
+
+// Scan checks the file at path, returning a *ScanError if it is rejected
+func (s *Scanner) Scan(ctx context.Context, path, name string) error {
+	if !s.Enabled() {
+		return nil
+	}
+
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	command := strings.NewReplacer(
+		"{file}", quote(path),
+		"{name}", quote(filepath.Base(name)),
+	).Replace(s.command)
+
+	result := execute(ctx, command, filepath.Dir(path), s.timeout)
+	result.Path = name
+	if result.ExitCode != 0 || result.Error != "" {
+		return &ScanError{Name: filepath.Base(name), Result: result}
+	}
+	return nil
+}
+
+// ScanReader copies an upload still in memory or a request body to a
+// temporary file and scans it
+func (s *Scanner) ScanReader(ctx context.Context, r io.Reader, name string) error {
+	if !s.Enabled() {
+		return nil
+	}
+
+	tmp, err := os.CreateTemp("", "inkwell-scan-*"+filepath.Ext(name))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = io.Copy(tmp, r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stage upload for scanning: %w", err)
+	}
+	return s.Scan(ctx, tmp.Name(), name)
+}
@@ -0,0 +1,121 @@
+// Package i18n formats dates, sizes and strings for pages Inkwell renders
+// itself, such as exported sites, in the reader's language. The API leaves
+// formatting to clients: it returns ISO-8601 times and sizes in bytes.
+package i18n
+
+import (
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// locales are the languages dates can be formatted for, the fallback first
+var locales = []struct {
+	tag            language.Tag
+	date, dateTime string // time.Format layouts
+}{
+	{language.AmericanEnglish, "Jan 2, 2006", "Jan 2, 2006 3:04 PM"},
+	{language.BritishEnglish, "2 Jan 2006", "2 Jan 2006 15:04"},
+	{language.German, "02.01.2006", "02.01.2006 15:04"},
+	{language.French, "02/01/2006", "02/01/2006 15:04"},
+	{language.Spanish, "02/01/2006", "02/01/2006 15:04"},
+	{language.Italian, "02/01/2006", "02/01/2006 15:04"},
+	{language.Portuguese, "02/01/2006", "02/01/2006 15:04"},
+	{language.Dutch, "02-01-2006", "02-01-2006 15:04"},
+	{language.Japanese, "2006/01/02", "2006/01/02 15:04"},
+	{language.Chinese, "2006/01/02", "2006/01/02 15:04"},
+}
+
+var matcher = func() language.Matcher {
+	tags := make([]language.Tag, len(locales))
+	for i, l := range locales {
+		tags[i] = l.tag
+	}
+	return language.NewMatcher(tags)
+}()
+
+// Locale formats for one language
+type Locale struct {
+	index   int
+	printer *message.Printer
+}
+
+// Default returns the locale used when no language is asked for
+func Default() *Locale {
+	return locale(0)
+}
+
+func locale(i int) *Locale {
+	return &Locale{index: i, printer: message.NewPrinter(locales[i].tag)}
+}
+
+// Negotiate picks the locale best matching an Accept-Language header,
+// falling back to Default
+func Negotiate(acceptLanguage string) *Locale {
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(tags) == 0 {
+		return Default()
+	}
+	_, i, confidence := matcher.Match(tags...)
+	if confidence == language.No {
+		return Default()
+	}
+	return locale(i)
+}
+
+// FromEnv picks the locale from LC_ALL, LC_MESSAGES or LANG, for the
+// command line
+func FromEnv() *Locale {
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		value := os.Getenv(name)
+		if value == "" {
+			continue
+		}
+		// de_DE.UTF-8@euro is de-DE
+		value, _, _ = strings.Cut(value, ".")
+		value, _, _ = strings.Cut(value, "@")
+		if value == "C" || value == "POSIX" {
+			return Default()
+		}
+		return Negotiate(strings.ReplaceAll(value, "_", "-"))
+	}
+	return Default()
+}
+
+// Tag returns the locale's language tag, for lang attributes
+func (l *Locale) Tag() language.Tag {
+	return locales[l.index].tag
+}
+
+// Sprintf formats a message from the catalog, translated if a translation
+// is registered, with numbers written the locale's way
+func (l *Locale) Sprintf(key string, args ...interface{}) string {
+	return l.printer.Sprintf(key, args...)
+}
+
+// Date formats the day of t
+func (l *Locale) Date(t time.Time) string {
+	return t.Format(locales[l.index].date)
+}
+
+// DateTime formats t to the minute
+func (l *Locale) DateTime(t time.Time) string {
+	return t.Format(locales[l.index].dateTime)
+}
+
+// Size formats a byte count
+func (l *Locale) Size(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return l.Sprintf(MsgGigabytes, float64(n)/(1<<30))
+	case n >= 1<<20:
+		return l.Sprintf(MsgMegabytes, float64(n)/(1<<20))
+	case n >= 1<<10:
+		return l.Sprintf(MsgKilobytes, float64(n)/(1<<10))
+	default:
+		return l.Sprintf(MsgBytes, n)
+	}
+}
@@ -0,0 +1,56 @@
+package i18n
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/text/language"
+)
+
+func TestNegotiate(t *testing.T) {
+	day := time.Date(2024, 3, 5, 14, 7, 0, 0, time.UTC)
+	tests := []struct {
+		header, tag, date, size string
+	}{
+		{"", "en-US", "Mar 5, 2024", "1.5 KB"},
+		{"en-GB,en;q=0.8", "en-GB", "5 Mar 2024", "1.5 KB"},
+		{"de-DE,de;q=0.9,en;q=0.5", "de", "05.03.2024", "1,5 KB"},
+		{"pt-BR", "pt", "05/03/2024", "1,5 KB"},
+		{"tlh", "en-US", "Mar 5, 2024", "1.5 KB"},
+		{"not a header;;", "en-US", "Mar 5, 2024", "1.5 KB"},
+	}
+	for _, tt := range tests {
+		l := Negotiate(tt.header)
+		if l.Tag().String() != tt.tag || l.Date(day) != tt.date || l.Size(1536) != tt.size {
+			t.Errorf("Negotiate(%q) = %s, %q, %q; want %s, %q, %q", tt.header, l.Tag(), l.Date(day), l.Size(1536), tt.tag, tt.date, tt.size)
+		}
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv("LC_ALL", "")
+	t.Setenv("LC_MESSAGES", "")
+	t.Setenv("LANG", "fr_FR.UTF-8")
+	if tag := FromEnv().Tag(); tag != language.French {
+		t.Errorf("FromEnv() = %s, want fr", tag)
+	}
+	t.Setenv("LC_ALL", "C")
+	if tag := FromEnv().Tag(); tag != language.AmericanEnglish {
+		t.Errorf("FromEnv() with C = %s, want en-US", tag)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	if err := Register(language.German, map[string]string{MsgUpdated: "Aktualisiert am %s"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := Negotiate("de").Sprintf(MsgUpdated, "05.03.2024"); got != "Aktualisiert am 05.03.2024" {
+		t.Errorf("Translated = %q", got)
+	}
+	if got := Default().Sprintf(MsgUpdated, "Mar 5, 2024"); got != "Updated Mar 5, 2024" {
+		t.Errorf("English = %q", got)
+	}
+	if got := Default().Size(12345); got != "12.1 KB" {
+		t.Errorf("Size = %q", got)
+	}
+}
@@ -0,0 +1,27 @@
+package i18n
+
+import (
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// Strings Inkwell renders itself. Each key is its English text, used as is
+// until a translation is registered for the reader's language.
+const (
+	MsgBytes     = "%d bytes"
+	MsgKilobytes = "%.1f KB"
+	MsgMegabytes = "%.1f MB"
+	MsgGigabytes = "%.1f GB"
+	MsgUpdated   = "Updated %s"
+	MsgNotes     = "%d notes"
+)
+
+// Register adds translations for a language, keyed by the English text
+func Register(tag language.Tag, translations map[string]string) error {
+	for key, text := range translations {
+		if err := message.SetString(tag, key, text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package index
+
+import (
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+)
+
+const dateLayout = "2006-01-02"
+
+// datePattern finds a date in a note's path, such as
+// journal/2024-05-01.md, 2024_05_01 standup.md or 2024/05/01.md
+var datePattern = regexp.MustCompile(`(?:^|\D)(\d{4})[-_./](\d{2})[-_./](\d{2})(?:\D|$)`)
+
+// Calendar is the dated notes of one month, for a month view of daily
+// notes
+type Calendar struct {
+	Month  string        `json:"month"`  // YYYY-MM
+	Days   []CalendarDay `json:"days"`   // Days with notes, in order
+	Months []string      `json:"months"` // Every month with dated notes, newest first
+	Streak Streak        `json:"streak"`
+}
+
+// CalendarDay is a day and the notes dated on it
+type CalendarDay struct {
+	Date  string `json:"date"` // YYYY-MM-DD
+	Notes []Note `json:"notes"`
+}
+
+// Streak counts the days in a row with a dated note. Notes dated after
+// today don't count.
+type Streak struct {
+	Current int    `json:"current"`        // Ending today, or yesterday if today has no note yet
+	Longest int    `json:"longest"`        // Longest ever
+	Last    string `json:"last,omitempty"` // Latest day with a note
+}
+
+// parseDate reads the YYYY-MM-DD date at the start of a value, as in
+// "2024-05-01" or "2024-05-01T09:30:00Z". Anything else gives "".
+func parseDate(value string) string {
+	if len(value) < len(dateLayout) {
+		return ""
+	}
+	if _, err := time.Parse(dateLayout, value[:len(dateLayout)]); err != nil {
+		return ""
+	}
+	return value[:len(dateLayout)]
+}
+
+// pathDate returns the date a note's path names, or ""
+func pathDate(path string) string {
+	m := datePattern.FindAllStringSubmatch(filepath.ToSlash(path), -1)
+	if m == nil {
+		return ""
+	}
+	// The file name wins over folders
+	last := m[len(m)-1]
+	return parseDate(last[1] + "-" + last[2] + "-" + last[3])
+}
+
+// Calendar returns the notes dated in month (YYYY-MM) and the streak of
+// daily notes up to today
+func (ix *Index) Calendar(month string, today time.Time) Calendar {
+	ix.mu.RLock()
+	byDate := make(map[string][]Note)
+	for _, note := range ix.notes {
+		if note.Date != "" {
+			byDate[note.Date] = append(byDate[note.Date], *note)
+		}
+	}
+	ix.mu.RUnlock()
+
+	cal := Calendar{Month: month, Days: []CalendarDay{}, Months: []string{}}
+	months := make(map[string]bool)
+	dates := make([]string, 0, len(byDate))
+	for date, notes := range byDate {
+		dates = append(dates, date)
+		months[date[:7]] = true
+		if date[:7] == month {
+			sort.Slice(notes, func(i, j int) bool { return notes[i].Path < notes[j].Path })
+			cal.Days = append(cal.Days, CalendarDay{Date: date, Notes: notes})
+		}
+	}
+	sort.Slice(cal.Days, func(i, j int) bool { return cal.Days[i].Date < cal.Days[j].Date })
+	for m := range months {
+		cal.Months = append(cal.Months, m)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(cal.Months)))
+
+	sort.Strings(dates)
+	cal.Streak = streak(dates, today)
+	return cal
+}
+
+// streak works out the runs of consecutive days in sorted dates
+func streak(dates []string, today time.Time) Streak {
+	todayDate := today.Format(dateLayout)
+	var s Streak
+	run := 0
+	var prev time.Time
+	for _, date := range dates {
+		if date > todayDate {
+			break
+		}
+		day, _ := time.Parse(dateLayout, date)
+		if run > 0 && day.Equal(prev.AddDate(0, 0, 1)) {
+			run++
+		} else {
+			run = 1
+		}
+		prev = day
+		s.Longest = max(s.Longest, run)
+		s.Last = date
+	}
+
+	yesterday := today.AddDate(0, 0, -1).Format(dateLayout)
+	if s.Last == todayDate || s.Last == yesterday {
+		s.Current = run
+	}
+	return s
+}
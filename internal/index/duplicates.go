@@ -0,0 +1,218 @@
+package index
+
+import (
+	"hash/fnv"
+	"sort"
+	"strings"
+)
+
+const (
+	// shingleSize is how many words in a row make up a shingle
+	shingleSize = 3
+
+	// signatureSize is the number of minhashes kept per note, split into
+	// signatureBands bands for finding candidate pairs. With 16 bands of 4
+	// rows, notes about 50% alike are likely to share a band.
+	signatureSize  = 64
+	signatureBands = 16
+
+	// minDuplicateWords leaves out notes too short to compare, such as
+	// fresh notes holding only a title
+	minDuplicateWords = 5
+)
+
+// DefaultDuplicateThreshold is the similarity from which notes are
+// reported as duplicates
+const DefaultDuplicateThreshold = 0.8
+
+// Duplicate is a group of notes with identical or highly similar content
+type Duplicate struct {
+	Notes      []Note  `json:"notes"`      // By path
+	Similarity float64 `json:"similarity"` // Estimated share of word runs in common, lowest between two notes grouped together
+	Identical  bool    `json:"identical"`  // The notes have the same words in the same order
+}
+
+// fingerprint summarizes a note's words for finding duplicates
+type fingerprint struct {
+	digest    uint64   // Hash of every word in order
+	signature []uint32 // Minhashes of the note's shingles
+}
+
+// newFingerprint computes the fingerprint of a note's words, in order
+func newFingerprint(words []string) fingerprint {
+	var fp fingerprint
+	if len(words) < minDuplicateWords {
+		return fp
+	}
+
+	h := fnv.New64a()
+	for _, word := range words {
+		h.Write([]byte(word))
+		h.Write([]byte{0})
+	}
+	fp.digest = h.Sum64()
+
+	fp.signature = make([]uint32, signatureSize)
+	for i := range fp.signature {
+		fp.signature[i] = ^uint32(0)
+	}
+	for i := 0; i+shingleSize <= len(words); i++ {
+		h := fnv.New64a()
+		h.Write([]byte(strings.Join(words[i:i+shingleSize], " ")))
+		shingle := h.Sum64()
+		for j := range fp.signature {
+			if v := uint32(mix(shingle^seeds[j]) >> 32); v < fp.signature[j] {
+				fp.signature[j] = v
+			}
+		}
+	}
+	return fp
+}
+
+// seeds give each minhash its own hash function
+var seeds = func() [signatureSize]uint64 {
+	var s [signatureSize]uint64
+	x := uint64(0x9e3779b97f4a7c15)
+	for i := range s {
+		x = mix(x + uint64(i))
+		s[i] = x
+	}
+	return s
+}()
+
+// mix is the splitmix64 finalizer
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// similarity estimates the share of shingles two notes have in common
+func similarity(a, b fingerprint) float64 {
+	if a.digest == b.digest {
+		return 1
+	}
+	same := 0
+	for i := range a.signature {
+		if a.signature[i] == b.signature[i] {
+			same++
+		}
+	}
+	return float64(same) / signatureSize
+}
+
+// Duplicates returns the groups of notes at least threshold alike, most
+// alike first. With path set, only the group holding that note is
+// returned. Notes of fewer than five words are never compared.
+func (ix *Index) Duplicates(threshold float64, path string) []Duplicate {
+	if threshold <= 0 || threshold > 1 {
+		threshold = DefaultDuplicateThreshold
+	}
+
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	// Notes sharing a band of their signature are candidates
+	rows := signatureSize / signatureBands
+	buckets := make(map[[2]uint64][]string)
+	for p, note := range ix.notes {
+		if note.fingerprint.signature == nil {
+			continue
+		}
+		for band := 0; band < signatureBands; band++ {
+			h := fnv.New64a()
+			for _, v := range note.fingerprint.signature[band*rows : (band+1)*rows] {
+				h.Write([]byte{byte(v), byte(v >> 8), byte(v >> 16), byte(v >> 24)})
+			}
+			key := [2]uint64{uint64(band), h.Sum64()}
+			buckets[key] = append(buckets[key], p)
+		}
+	}
+
+	// Group the candidates that are alike enough
+	parent := make(map[string]string)
+	var find func(p string) string
+	find = func(p string) string {
+		if parent[p] == p {
+			return p
+		}
+		parent[p] = find(parent[p])
+		return parent[p]
+	}
+	lowest := make(map[string]float64)
+	identical := make(map[string]bool)
+	seen := make(map[[2]string]bool)
+	for _, paths := range buckets {
+		for i := 0; i < len(paths); i++ {
+			for j := i + 1; j < len(paths); j++ {
+				a, b := paths[i], paths[j]
+				if a > b {
+					a, b = b, a
+				}
+				if seen[[2]string{a, b}] {
+					continue
+				}
+				seen[[2]string{a, b}] = true
+
+				sim := similarity(ix.notes[a].fingerprint, ix.notes[b].fingerprint)
+				if sim < threshold {
+					continue
+				}
+				for _, p := range []string{a, b} {
+					if _, ok := parent[p]; !ok {
+						parent[p] = p
+						lowest[p] = 1
+						identical[p] = true
+					}
+				}
+				ra, rb := find(a), find(b)
+				low := min(lowest[ra], lowest[rb], sim)
+				same := identical[ra] && identical[rb] && ix.notes[a].fingerprint.digest == ix.notes[b].fingerprint.digest
+				if ra != rb {
+					parent[rb] = ra
+				}
+				lowest[ra], identical[ra] = low, same
+			}
+		}
+	}
+
+	groups := make(map[string]*Duplicate)
+	for p := range parent {
+		root := find(p)
+		g, ok := groups[root]
+		if !ok {
+			g = &Duplicate{Similarity: lowest[root], Identical: identical[root]}
+			groups[root] = g
+		}
+		g.Notes = append(g.Notes, *ix.notes[p])
+	}
+
+	result := []Duplicate{}
+	for _, g := range groups {
+		sort.Slice(g.Notes, func(i, j int) bool { return g.Notes[i].Path < g.Notes[j].Path })
+		if path != "" && !g.has(path) {
+			continue
+		}
+		result = append(result, *g)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Similarity != result[j].Similarity {
+			return result[i].Similarity > result[j].Similarity
+		}
+		return result[i].Notes[0].Path < result[j].Notes[0].Path
+	})
+	return result
+}
+
+// has reports whether a note at path is in the group
+func (d *Duplicate) has(path string) bool {
+	for _, note := range d.Notes {
+		if note.Path == path {
+			return true
+		}
+	}
+	return false
+}
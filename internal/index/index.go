@@ -0,0 +1,437 @@
+// Package index keeps the words, links, tags and tasks of a vault's notes
+// in memory for search, backlinks, tag listings and task lists. The index
+// is built in the background so opening a large vault isn't held up by it.
+package index
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"inkwell/internal/encryption"
+	"inkwell/internal/filesystem"
+)
+
+// States an index can be in
+const (
+	StateIdle     = "idle"     // Not built yet
+	StateIndexing = "indexing" // Being built; results are incomplete
+	StateReady    = "ready"    // Built and kept up to date
+)
+
+// searchLimit is the most results Search returns
+const searchLimit = 50
+
+// Status reports how far building the index has got
+type Status struct {
+	State    string    `json:"state"`
+	Indexed  int       `json:"indexed"`
+	Total    int       `json:"total"`
+	Percent  int       `json:"percent"`
+	Started  time.Time `json:"started,omitempty"`
+	Finished time.Time `json:"finished,omitempty"`
+}
+
+// Note is what the index knows about a note
+type Note struct {
+	Path  string   `json:"path"`
+	Title string   `json:"title"`
+	Tags  []string `json:"tags,omitempty"`
+	Links []string `json:"links,omitempty"` // Linked notes, relative to the root
+	Date  string   `json:"date,omitempty"`  // YYYY-MM-DD from front matter or the file name
+
+	wikiLinks   []string
+	words       map[string]int
+	tasks       []Task
+	fingerprint fingerprint
+}
+
+// Result is a note matching a search
+type Result struct {
+	Path  string `json:"path"`
+	Title string `json:"title"`
+	Score int    `json:"score"`
+}
+
+// Tag is a tag and how many notes use it
+type Tag struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// Index holds the notes of one vault
+type Index struct {
+	fs *filesystem.FileSystem
+
+	mu     sync.RWMutex
+	notes  map[string]*Note
+	words  map[string]map[string]int // Word to the notes containing it, with counts
+	status Status
+	cancel context.CancelFunc
+}
+
+// New creates an empty index of the notes in fs
+func New(fs *filesystem.FileSystem) *Index {
+	return &Index{
+		fs:     fs,
+		notes:  make(map[string]*Note),
+		words:  make(map[string]map[string]int),
+		status: Status{State: StateIdle},
+	}
+}
+
+// Start builds the index in the background, stopping a build already
+// running. The previous contents stay searchable meanwhile. onProgress, if
+// not nil, is called whenever the percentage done changes.
+func (ix *Index) Start(onProgress func(Status)) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	ix.mu.Lock()
+	if ix.cancel != nil {
+		ix.cancel()
+	}
+	ix.cancel = cancel
+	ix.mu.Unlock()
+
+	go ix.build(ctx, onProgress)
+}
+
+// Close stops a running build
+func (ix *Index) Close() {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if ix.cancel != nil {
+		ix.cancel()
+		ix.cancel = nil
+	}
+}
+
+// Status returns how far building the index has got
+func (ix *Index) Status() Status {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return ix.status
+}
+
+func (ix *Index) build(ctx context.Context, onProgress func(Status)) {
+	paths, err := ix.fs.ListNotes("")
+	if err != nil {
+		slog.Warn("Failed to list notes to index", "root", ix.fs.RootDir, "error", err)
+		return
+	}
+
+	ix.setStatus(Status{State: StateIndexing, Total: len(paths), Started: time.Now()}, onProgress)
+
+	seen := make(map[string]bool, len(paths))
+	reported := -1
+	for i, path := range paths {
+		if ctx.Err() != nil {
+			return
+		}
+		seen[path] = true
+		ix.Update(path)
+
+		status := ix.Status()
+		status.Indexed = i + 1
+		status.Percent = status.Indexed * 100 / len(paths)
+		if status.Percent != reported {
+			reported = status.Percent
+			ix.setStatus(status, onProgress)
+		} else {
+			ix.mu.Lock()
+			ix.status = status
+			ix.mu.Unlock()
+		}
+	}
+
+	// Drop notes deleted since the last build. Notes created while
+	// building were added by Update and are kept.
+	ix.mu.RLock()
+	var unseen []string
+	for path := range ix.notes {
+		if !seen[path] {
+			unseen = append(unseen, path)
+		}
+	}
+	ix.mu.RUnlock()
+	for _, path := range unseen {
+		if !ix.fs.FileExists(path) {
+			ix.Remove(path)
+		}
+	}
+
+	status := ix.Status()
+	status.State = StateReady
+	status.Indexed = len(paths)
+	status.Percent = 100
+	status.Finished = time.Now()
+	ix.setStatus(status, onProgress)
+	slog.Info("Indexed notes", "root", ix.fs.RootDir, "notes", len(paths), "duration", status.Finished.Sub(status.Started))
+}
+
+// setStatus records and reports progress
+func (ix *Index) setStatus(status Status, onProgress func(Status)) {
+	ix.mu.Lock()
+	ix.status = status
+	ix.mu.Unlock()
+
+	if onProgress != nil {
+		onProgress(status)
+	}
+}
+
+// Filtered returns a copy of the index holding only the notes readable
+// allows, for users who may not see the whole vault. The copy is not kept
+// up to date.
+func (ix *Index) Filtered(readable func(path string) bool) *Index {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	view := New(ix.fs)
+	view.status = ix.status
+	for path, note := range ix.notes {
+		if readable(filepath.ToSlash(path)) {
+			view.notes[path] = note
+		}
+	}
+	for word, notes := range ix.words {
+		for path, count := range notes {
+			if _, ok := view.notes[path]; !ok {
+				continue
+			}
+			if view.words[word] == nil {
+				view.words[word] = make(map[string]int)
+			}
+			view.words[word][path] = count
+		}
+	}
+	return view
+}
+
+// Update indexes a note again after it changed, or every note in a folder.
+// A path that no longer exists is removed.
+func (ix *Index) Update(path string) {
+	fullPath, err := ix.fs.ResolvePath(path)
+	if err != nil {
+		return
+	}
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		ix.Remove(path)
+		return
+	}
+	if info.IsDir() {
+		notes, err := ix.fs.ListNotes(path)
+		if err != nil {
+			return
+		}
+		for _, note := range notes {
+			ix.Update(note)
+		}
+		return
+	}
+	if !ix.fs.IsNote(filepath.Base(path)) {
+		return
+	}
+
+	content, err := ix.fs.ReadFile(path)
+	if err != nil {
+		return
+	}
+	// Encrypted notes are indexed by name only, so nothing leaks into search
+	if encryption.IsEncrypted(content) {
+		content = ""
+	}
+	p := parse(path, content)
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	ix.removeLocked(path)
+	ix.notes[path] = &Note{
+		Path:        path,
+		Title:       p.title,
+		Tags:        p.tags,
+		Links:       p.links,
+		Date:        p.date,
+		wikiLinks:   p.wikiLinks,
+		words:       p.words,
+		tasks:       p.tasks,
+		fingerprint: p.fingerprint,
+	}
+	for word, count := range p.words {
+		if ix.words[word] == nil {
+			ix.words[word] = make(map[string]int)
+		}
+		ix.words[word][path] = count
+	}
+}
+
+// Remove drops a note, or every note in a folder, from the index
+func (ix *Index) Remove(path string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	prefix := path + string(filepath.Separator)
+	for p := range ix.notes {
+		if p == path || strings.HasPrefix(p, prefix) {
+			ix.removeLocked(p)
+		}
+	}
+}
+
+// removeLocked drops a single note. ix.mu must be held.
+func (ix *Index) removeLocked(path string) {
+	note, ok := ix.notes[path]
+	if !ok {
+		return
+	}
+	for word := range note.words {
+		delete(ix.words[word], path)
+		if len(ix.words[word]) == 0 {
+			delete(ix.words, word)
+		}
+	}
+	delete(ix.notes, path)
+}
+
+// Search returns the notes containing every word of query, best matches
+// first. The last word also matches longer words starting with it, so
+// results can be shown while typing.
+func (ix *Index) Search(query string) []Result {
+	terms := wordPattern.FindAllString(strings.ToLower(query), -1)
+	if len(terms) == 0 {
+		return []Result{}
+	}
+
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	var scores map[string]int
+	for i, term := range terms {
+		matches := make(map[string]int)
+		for word, notes := range ix.words {
+			if word != term && (i < len(terms)-1 || !strings.HasPrefix(word, term)) {
+				continue
+			}
+			for path, count := range notes {
+				matches[path] += count
+			}
+		}
+
+		if scores == nil {
+			scores = matches
+			continue
+		}
+		for path := range scores {
+			if n, ok := matches[path]; ok {
+				scores[path] += n
+			} else {
+				delete(scores, path)
+			}
+		}
+	}
+
+	results := make([]Result, 0, len(scores))
+	for path, score := range scores {
+		note := ix.notes[path]
+		title := strings.ToLower(note.Title)
+		for _, term := range terms {
+			if strings.Contains(title, term) {
+				score += 10
+			}
+		}
+		results = append(results, Result{Path: path, Title: note.Title, Score: score})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Path < results[j].Path
+	})
+	if len(results) > searchLimit {
+		results = results[:searchLimit]
+	}
+	return results
+}
+
+// Backlinks returns the notes linking to the note at path, by markdown
+// link or by [[wiki link]] to its name
+func (ix *Index) Backlinks(path string) []Note {
+	path = filepath.Clean(path)
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	withoutExt := filepath.ToSlash(strings.TrimSuffix(path, filepath.Ext(path)))
+
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	result := []Note{}
+	for _, note := range ix.notes {
+		if note.Path != path && note.linksTo(path, name, withoutExt) {
+			result = append(result, *note)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Path < result[j].Path })
+	return result
+}
+
+func (n *Note) linksTo(path, name, withoutExt string) bool {
+	for _, link := range n.Links {
+		if link == path {
+			return true
+		}
+	}
+	for _, link := range n.wikiLinks {
+		if strings.EqualFold(link, name) || strings.EqualFold(link, withoutExt) {
+			return true
+		}
+	}
+	return false
+}
+
+// Tags returns every tag in the vault, most used first
+func (ix *Index) Tags() []Tag {
+	ix.mu.RLock()
+	counts := make(map[string]int)
+	for _, note := range ix.notes {
+		for _, tag := range note.Tags {
+			counts[tag]++
+		}
+	}
+	ix.mu.RUnlock()
+
+	tags := make([]Tag, 0, len(counts))
+	for name, count := range counts {
+		tags = append(tags, Tag{Name: name, Count: count})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Count != tags[j].Count {
+			return tags[i].Count > tags[j].Count
+		}
+		return tags[i].Name < tags[j].Name
+	})
+	return tags
+}
+
+// Tagged returns the notes with a tag
+func (ix *Index) Tagged(tag string) []Note {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	result := []Note{}
+	for _, note := range ix.notes {
+		for _, t := range note.Tags {
+			if t == tag {
+				result = append(result, *note)
+				break
+			}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Path < result[j].Path })
+	return result
+}
@@ -0,0 +1,292 @@
+package index
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"inkwell/internal/filesystem"
+)
+
+func TestParse(t *testing.T) {
+	content := "---\ntitle: Meeting notes\ntags: [work, planning]\n---\n" +
+		"# Ignored heading\n\nSee [[Ideas]] and [the plan](../plan.md#goals) #urgent\n" +
+		"[site](https://example.com)\n\n```\n#notatag [[NotALink]]\n```\n"
+
+	p := parse(filepath.Join("notes", "meeting.md"), content)
+
+	if p.title != "Meeting notes" {
+		t.Errorf("title = %q, want %q", p.title, "Meeting notes")
+	}
+	if want := []string{"work", "planning", "urgent"}; !reflect.DeepEqual(p.tags, want) {
+		t.Errorf("tags = %v, want %v", p.tags, want)
+	}
+	if want := []string{"Ideas"}; !reflect.DeepEqual(p.wikiLinks, want) {
+		t.Errorf("wikiLinks = %v, want %v", p.wikiLinks, want)
+	}
+	if want := []string{"plan.md"}; !reflect.DeepEqual(p.links, want) {
+		t.Errorf("links = %v, want %v", p.links, want)
+	}
+	if p.words["plan"] == 0 || p.words["notalink"] == 0 {
+		t.Errorf("words = %v, want plan and code words counted", p.words)
+	}
+
+	if p := parse("untitled.md", "no heading here"); p.title != "untitled" {
+		t.Errorf("title without heading = %q, want %q", p.title, "untitled")
+	}
+}
+
+func TestIndex(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"ideas.md":         "# Ideas\n\nA garden of thoughts #garden\n",
+		"plan.md":          "# Plan\n\nWater the garden, see [[Ideas]]\n",
+		"notes/journal.md": "# Journal\n\nRead [the plan](../plan.md) #garden #daily\n",
+		"notes/other.txt":  "garden",
+	}
+	for path, content := range files {
+		full := filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ix := New(filesystem.New(dir))
+	defer ix.Close()
+
+	progress := make(chan Status, 100)
+	ix.Start(func(s Status) { progress <- s })
+
+	timeout := time.After(5 * time.Second)
+	for done := false; !done; {
+		select {
+		case s := <-progress:
+			done = s.State == StateReady
+		case <-timeout:
+			t.Fatalf("index not ready, status %+v", ix.Status())
+		}
+	}
+
+	if s := ix.Status(); s.Total != 3 || s.Indexed != 3 || s.Percent != 100 {
+		t.Errorf("Status() = %+v, want 3 of 3 notes", s)
+	}
+
+	var paths []string
+	for _, r := range ix.Search("gard") {
+		paths = append(paths, r.Path)
+	}
+	if want := []string{"ideas.md", filepath.Join("notes", "journal.md"), "plan.md"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("Search(gard) = %v, want %v", paths, want)
+	}
+	if results := ix.Search("water garden"); len(results) != 1 || results[0].Path != "plan.md" {
+		t.Errorf("Search(water garden) = %v, want plan.md", results)
+	}
+
+	if links := ix.Backlinks("ideas.md"); len(links) != 1 || links[0].Path != "plan.md" {
+		t.Errorf("Backlinks(ideas.md) = %v, want plan.md", links)
+	}
+	if links := ix.Backlinks("plan.md"); len(links) != 1 || links[0].Path != filepath.Join("notes", "journal.md") {
+		t.Errorf("Backlinks(plan.md) = %v, want notes/journal.md", links)
+	}
+
+	if want := []Tag{{"garden", 2}, {"daily", 1}}; !reflect.DeepEqual(ix.Tags(), want) {
+		t.Errorf("Tags() = %v, want %v", ix.Tags(), want)
+	}
+
+	// Updates follow changes on disk
+	if err := os.WriteFile(filepath.Join(dir, "ideas.md"), []byte("# Ideas\n\nNothing yet\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ix.Update("ideas.md")
+	if results := ix.Search("thoughts"); len(results) != 0 {
+		t.Errorf("Search(thoughts) after update = %v, want none", results)
+	}
+
+	ix.Remove("notes")
+	if notes := ix.Tagged("daily"); len(notes) != 0 {
+		t.Errorf("Tagged(daily) after removing folder = %v, want none", notes)
+	}
+}
+
+func TestTasks(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"todo.md": "---\ntags: [home]\n---\n# Todo\n\n- [ ] Water plants due:2024-05-03\n" +
+			"- [x] Buy soil 📅 2024-05-01\n  * [ ] Repot the fern #garden\n\n```\n- [ ] not a task\n```\n",
+		"work/plan.md": "1. [ ] Send report @due(2024-05-02) #work\n",
+	}
+	for path, content := range files {
+		full := filepath.Join(dir, path)
+		os.MkdirAll(filepath.Dir(full), 0755)
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ix := New(filesystem.New(dir))
+	ix.Update("todo.md")
+	ix.Update("work")
+
+	all := ix.Tasks(TaskFilter{})
+	want := []Task{
+		{Path: filepath.Join("work", "plan.md"), Line: 1, Text: "Send report @due(2024-05-02) #work", Due: "2024-05-02", Tags: []string{"work"}},
+		{Path: "todo.md", Line: 6, Text: "Water plants due:2024-05-03", Due: "2024-05-03"},
+		{Path: "todo.md", Line: 8, Text: "Repot the fern #garden", Tags: []string{"garden"}},
+		{Path: "todo.md", Line: 7, Text: "Buy soil 📅 2024-05-01", Done: true, Due: "2024-05-01"},
+	}
+	if !reflect.DeepEqual(all, want) {
+		t.Errorf("Tasks() = %+v, want %+v", all, want)
+	}
+
+	open := false
+	for _, tc := range []struct {
+		filter TaskFilter
+		want   int
+	}{
+		{TaskFilter{Done: &open}, 3},
+		{TaskFilter{Tag: "garden"}, 1},
+		{TaskFilter{Tag: "home"}, 0}, // Only the note is tagged
+		{TaskFilter{Folder: "work"}, 1},
+		{TaskFilter{DueBefore: "2024-05-02"}, 2},
+		{TaskFilter{DueAfter: "2024-05-02", DueBefore: "2024-05-03"}, 2},
+	} {
+		if got := ix.Tasks(tc.filter); len(got) != tc.want {
+			t.Errorf("Tasks(%+v) = %d tasks, want %d", tc.filter, len(got), tc.want)
+		}
+	}
+}
+
+func TestToggleTask(t *testing.T) {
+	content := "# Todo\r\n- [ ] One\r\n- [x] Two\r\nplain\r\n"
+
+	updated, task, err := ToggleTask("todo.md", content, 2, true, "One")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated != "# Todo\r\n- [x] One\r\n- [x] Two\r\nplain\r\n" || !task.Done || task.Line != 2 {
+		t.Errorf("ToggleTask = %q, %+v", updated, task)
+	}
+	if updated, _, _ = ToggleTask("todo.md", content, 3, false, ""); updated != "# Todo\r\n- [ ] One\r\n- [ ] Two\r\nplain\r\n" {
+		t.Errorf("Unchecking gave %q", updated)
+	}
+	if _, _, err := ToggleTask("todo.md", content, 4, true, ""); err != ErrNotTask {
+		t.Errorf("Expected ErrNotTask, got %v", err)
+	}
+	if _, _, err := ToggleTask("todo.md", content, 2, true, "Other"); err != ErrTaskChanged {
+		t.Errorf("Expected ErrTaskChanged, got %v", err)
+	}
+}
+
+func TestCalendar(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"journal/2024-05-01.md":  "# Wednesday\n",
+		"journal/2024-05-02.md":  "# Thursday\n",
+		"journal/2024-05-03.md":  "# Friday\n",
+		"2024/05/10.md":          "# Nested\n",
+		"standup 2024_05_10.md":  "# Standup\n",
+		"trip.md":                "---\ndate: \"2024-04-20T09:00:00Z\"\n---\n# Trip\n",
+		"plans/2024-05-20.md":    "# Future plans\n",
+		"journal/2024-13-01.md":  "# Not a date\n",
+		"notes/undated notes.md": "# Undated\n",
+	}
+	for path, content := range files {
+		full := filepath.Join(dir, path)
+		os.MkdirAll(filepath.Dir(full), 0755)
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ix := New(filesystem.New(dir))
+	for path := range files {
+		ix.Update(path)
+	}
+
+	today := time.Date(2024, 5, 11, 8, 0, 0, 0, time.UTC)
+	cal := ix.Calendar("2024-05", today)
+
+	var days []string
+	for _, day := range cal.Days {
+		days = append(days, day.Date)
+	}
+	if want := []string{"2024-05-01", "2024-05-02", "2024-05-03", "2024-05-10", "2024-05-20"}; !reflect.DeepEqual(days, want) {
+		t.Errorf("Days = %v, want %v", days, want)
+	}
+	if notes := cal.Days[3].Notes; len(notes) != 2 || notes[0].Path != filepath.Join("2024", "05", "10.md") {
+		t.Errorf("Notes on 2024-05-10 = %v", notes)
+	}
+	if want := []string{"2024-05", "2024-04"}; !reflect.DeepEqual(cal.Months, want) {
+		t.Errorf("Months = %v, want %v", cal.Months, want)
+	}
+	if want := (Streak{Current: 1, Longest: 3, Last: "2024-05-10"}); cal.Streak != want {
+		t.Errorf("Streak = %+v, want %+v", cal.Streak, want)
+	}
+
+	if s := ix.Calendar("2024-05", today.AddDate(0, 0, 2)).Streak; s.Current != 0 {
+		t.Errorf("Expected the streak broken two days later, got %+v", s)
+	}
+	if april := ix.Calendar("2024-04", today); len(april.Days) != 1 || april.Days[0].Notes[0].Title != "Trip" {
+		t.Errorf("Expected the front matter date used, got %+v", april.Days)
+	}
+}
+
+func TestDuplicates(t *testing.T) {
+	dir := t.TempDir()
+	text := "Inkwell keeps notes as plain markdown files in a folder, so any editor can open them and git can track every change made to them over time."
+	files := map[string]string{
+		"ideas.md":          "# Ideas\n\n" + text + "\n",
+		"ideas copy.md":     "# Ideas\n\n" + text + "\n",
+		"imports/ideas.md":  "# Ideas\n\n" + strings.Replace(text, "over time", "over the years", 1) + "\n",
+		"other.md":          "# Groceries\n\nMilk, eggs, bread, butter, apples and coffee for the week ahead.\n",
+		"short.md":          "# Ideas\n",
+		"short copy.md":     "# Ideas\n",
+		"drafts/similar.md": "# Ideas\n\nInkwell keeps notes as plain text. Something entirely different follows here, with new words throughout the rest.\n",
+	}
+	for path, content := range files {
+		full := filepath.Join(dir, path)
+		os.MkdirAll(filepath.Dir(full), 0755)
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ix := New(filesystem.New(dir))
+	for path := range files {
+		ix.Update(path)
+	}
+
+	paths := func(d Duplicate) []string {
+		var result []string
+		for _, note := range d.Notes {
+			result = append(result, filepath.ToSlash(note.Path))
+		}
+		return result
+	}
+
+	dups := ix.Duplicates(0.6, "")
+	if len(dups) != 1 {
+		t.Fatalf("Expected one group, got %+v", dups)
+	}
+	if want := []string{"ideas copy.md", "ideas.md", "imports/ideas.md"}; !reflect.DeepEqual(paths(dups[0]), want) {
+		t.Errorf("Group = %v, want %v", paths(dups[0]), want)
+	}
+	if dups[0].Identical || dups[0].Similarity < 0.6 || dups[0].Similarity >= 1 {
+		t.Errorf("Unexpected similarity %+v", dups[0])
+	}
+
+	// A strict threshold leaves only the exact copies
+	dups = ix.Duplicates(1, "ideas.md")
+	if len(dups) != 1 || !dups[0].Identical || !reflect.DeepEqual(paths(dups[0]), []string{"ideas copy.md", "ideas.md"}) {
+		t.Errorf("Unexpected identical group %+v", dups)
+	}
+	if dups := ix.Duplicates(0.6, "other.md"); len(dups) != 0 {
+		t.Errorf("Expected no duplicates of other.md, got %+v", dups)
+	}
+}
@@ -0,0 +1,167 @@
+package index
+
+import (
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	wikiLinkPattern = regexp.MustCompile(`\[\[([^\]|#]+)(?:#[^\]|]*)?(?:\|[^\]]*)?\]\]`)
+	linkPattern     = regexp.MustCompile(`\[[^\]]*\]\(<?([^)\s>]+)>?(?:\s+"[^"]*")?\)`)
+	tagPattern      = regexp.MustCompile(`(?:^|\s)#([\p{L}\p{N}_][\p{L}\p{N}_/-]*)`)
+	wordPattern     = regexp.MustCompile(`[\p{L}\p{N}]+`)
+)
+
+// parsed is what a note contributes to the index
+type parsed struct {
+	title     string
+	tags      []string
+	links     []string       // Notes linked with markdown links, relative to the root
+	wikiLinks []string       // Targets of [[wiki links]] as written
+	words     map[string]int // Lowercased words and how often they appear
+	tasks     []Task
+	date      string // YYYY-MM-DD from front matter or the file name
+
+	fingerprint fingerprint
+}
+
+// parse reads the title, tags, links and words of a note at path, relative
+// to the root
+func parse(path, content string) *parsed {
+	p := &parsed{words: make(map[string]int)}
+	body := content
+
+	// Front matter
+	if strings.HasPrefix(content, "---\n") || strings.HasPrefix(content, "---\r\n") {
+		rest := content[strings.Index(content, "\n")+1:]
+		if end := strings.Index(rest, "\n---"); end >= 0 {
+			p.frontMatter(rest[:end])
+			body = rest[end+4:]
+		}
+	}
+
+	// Task lines count from the top of the file, front matter included
+	offset := strings.Count(content[:len(content)-len(body)], "\n")
+
+	var sequence []string // Every word in order, for finding duplicates
+	inCode := false
+	for i, line := range strings.Split(body, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inCode = !inCode
+			continue
+		}
+		for _, word := range wordPattern.FindAllString(line, -1) {
+			word = strings.ToLower(word)
+			p.words[word]++
+			sequence = append(sequence, word)
+		}
+		if inCode {
+			continue
+		}
+
+		if task, ok := parseTask(path, offset+i+1, line); ok {
+			p.tasks = append(p.tasks, task)
+		}
+		if p.title == "" && strings.HasPrefix(trimmed, "# ") {
+			p.title = strings.TrimSpace(trimmed[2:])
+			continue
+		}
+		for _, m := range tagPattern.FindAllStringSubmatch(line, -1) {
+			p.addTag(m[1])
+		}
+		for _, m := range wikiLinkPattern.FindAllStringSubmatch(line, -1) {
+			p.wikiLinks = append(p.wikiLinks, strings.TrimSpace(m[1]))
+		}
+		for _, m := range linkPattern.FindAllStringSubmatch(line, -1) {
+			if target := resolveLink(path, m[1]); target != "" {
+				p.links = append(p.links, target)
+			}
+		}
+	}
+
+	for _, word := range wordPattern.FindAllString(p.title, -1) {
+		p.words[strings.ToLower(word)]++
+	}
+	if p.title == "" {
+		base := filepath.Base(path)
+		p.title = strings.TrimSuffix(base, filepath.Ext(base))
+	}
+	if p.date == "" {
+		p.date = pathDate(path)
+	}
+	p.fingerprint = newFingerprint(sequence)
+	return p
+}
+
+// frontMatter reads the title, date and tags from a note's YAML front matter.
+// Tags may be a list, an inline [a, b] list or comma-separated.
+func (p *parsed) frontMatter(block string) {
+	inTags := false
+	for _, line := range strings.Split(block, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if inTags && strings.HasPrefix(strings.TrimSpace(line), "- ") {
+			p.addTag(strings.TrimSpace(strings.TrimSpace(line)[2:]))
+			continue
+		}
+		inTags = false
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.TrimSpace(key) {
+		case "title":
+			p.title = strings.Trim(value, `"'`)
+		case "date":
+			p.date = parseDate(strings.Trim(value, `"'`))
+		case "tags":
+			if value == "" {
+				inTags = true
+				continue
+			}
+			for _, tag := range strings.Split(strings.Trim(value, "[]"), ",") {
+				p.addTag(tag)
+			}
+		}
+	}
+}
+
+func (p *parsed) addTag(tag string) {
+	tag = strings.TrimPrefix(strings.Trim(strings.TrimSpace(tag), `"'`), "#")
+	if tag == "" {
+		return
+	}
+	for _, existing := range p.tags {
+		if existing == tag {
+			return
+		}
+	}
+	p.tags = append(p.tags, tag)
+}
+
+// resolveLink turns a markdown link target in the note at from into a path
+// relative to the root. External links and links to anchors resolve to "".
+func resolveLink(from, target string) string {
+	if strings.Contains(target, "://") || strings.HasPrefix(target, "mailto:") || strings.HasPrefix(target, "#") {
+		return ""
+	}
+	target, _, _ = strings.Cut(target, "#")
+	if unescaped, err := url.PathUnescape(target); err == nil {
+		target = unescaped
+	}
+
+	var resolved string
+	if strings.HasPrefix(target, "/") {
+		resolved = filepath.Clean(filepath.FromSlash(target[1:]))
+	} else {
+		resolved = filepath.Join(filepath.Dir(from), filepath.FromSlash(target))
+	}
+	if resolved == "." || strings.HasPrefix(resolved, "..") {
+		return ""
+	}
+	return resolved
+}
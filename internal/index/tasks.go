@@ -0,0 +1,158 @@
+package index
+
+import (
+	"errors"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	// taskPattern matches "- [ ] text" list items, with any bullet
+	taskPattern = regexp.MustCompile(`^(\s*(?:[-*+]|\d+[.)])\s+\[)([ xX])(\]\s+)(.*)$`)
+	// duePattern matches due dates written as 📅 2024-05-01, due:2024-05-01
+	// or @due(2024-05-01)
+	duePattern = regexp.MustCompile(`(?:📅\s*|\bdue:\s*|@due\()(\d{4}-\d{2}-\d{2})\)?`)
+)
+
+// ErrNotTask is returned when toggling a line that isn't a task
+var ErrNotTask = errors.New("line is not a task")
+
+// ErrTaskChanged is returned when toggling a task whose text changed since
+// it was listed
+var ErrTaskChanged = errors.New("task has changed")
+
+// Task is a "- [ ]" item in a note
+type Task struct {
+	Path string   `json:"path"`
+	Line int      `json:"line"` // 1-based, counting front matter
+	Text string   `json:"text"`
+	Done bool     `json:"done"`
+	Due  string   `json:"due,omitempty"` // YYYY-MM-DD
+	Tags []string `json:"tags,omitempty"`
+}
+
+// TaskFilter selects tasks; zero fields match everything
+type TaskFilter struct {
+	Done      *bool  // Only done or only open tasks
+	Tag       string // Tasks tagged with it
+	Folder    string // Tasks in notes under this folder, or in this note
+	DueBefore string // Tasks due on or before this date (YYYY-MM-DD)
+	DueAfter  string // Tasks due on or after this date
+}
+
+// parseTask reads a task from a line of a note
+func parseTask(path string, line int, text string) (Task, bool) {
+	m := taskPattern.FindStringSubmatch(strings.TrimRight(text, "\r"))
+	if m == nil {
+		return Task{}, false
+	}
+	t := Task{
+		Path: path,
+		Line: line,
+		Text: strings.TrimSpace(m[4]),
+		Done: m[2] != " ",
+	}
+	if due := duePattern.FindStringSubmatch(t.Text); due != nil {
+		t.Due = due[1]
+	}
+	for _, tag := range tagPattern.FindAllStringSubmatch(t.Text, -1) {
+		t.Tags = append(t.Tags, tag[1])
+	}
+	return t, true
+}
+
+// Tasks returns the tasks matching filter: open tasks first, then by due
+// date, with undated tasks last, then by note and line
+func (ix *Index) Tasks(filter TaskFilter) []Task {
+	folder := filepath.Clean(filter.Folder)
+	prefix := folder + string(filepath.Separator)
+
+	ix.mu.RLock()
+	result := []Task{}
+	for _, note := range ix.notes {
+		if filter.Folder != "" && folder != "." && note.Path != folder && !strings.HasPrefix(note.Path, prefix) {
+			continue
+		}
+		for _, task := range note.tasks {
+			if filter.matches(task) {
+				result = append(result, task)
+			}
+		}
+	}
+	ix.mu.RUnlock()
+
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.Done != b.Done {
+			return !a.Done
+		}
+		if a.Due != b.Due {
+			if a.Due == "" || b.Due == "" {
+				return b.Due == ""
+			}
+			return a.Due < b.Due
+		}
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return a.Line < b.Line
+	})
+	return result
+}
+
+func (f TaskFilter) matches(t Task) bool {
+	if f.Done != nil && t.Done != *f.Done {
+		return false
+	}
+	if f.Tag != "" && !hasTag(t.Tags, f.Tag) {
+		return false
+	}
+	if f.DueBefore != "" && (t.Due == "" || t.Due > f.DueBefore) {
+		return false
+	}
+	if f.DueAfter != "" && (t.Due == "" || t.Due < f.DueAfter) {
+		return false
+	}
+	return true
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// ToggleTask marks the task on a line of content (1-based) done or not
+// done, returning the new content and task. If text is not empty it must
+// match the task's text, so a stale list doesn't toggle the wrong task.
+func ToggleTask(path, content string, line int, done bool, text string) (string, Task, error) {
+	lines := strings.SplitAfter(content, "\n")
+	if line < 1 || line > len(lines) {
+		return "", Task{}, ErrNotTask
+	}
+	current := lines[line-1]
+	ending := current[len(strings.TrimRight(current, "\r\n")):]
+	current = strings.TrimRight(current, "\r\n")
+
+	task, ok := parseTask(path, line, current)
+	if !ok {
+		return "", Task{}, ErrNotTask
+	}
+	if text != "" && strings.TrimSpace(text) != task.Text {
+		return "", Task{}, ErrTaskChanged
+	}
+
+	mark := " "
+	if done {
+		mark = "x"
+	}
+	m := taskPattern.FindStringSubmatchIndex(current)
+	lines[line-1] = current[:m[4]] + mark + current[m[5]:] + ending
+	task.Done = done
+	return strings.Join(lines, ""), task, nil
+}
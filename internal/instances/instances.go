@@ -0,0 +1,149 @@
+// Package instances keeps a registry of running Inkwell servers so they
+// can be listed and stopped from the command line
+package instances
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Instance is a running Inkwell server
+type Instance struct {
+	PID     int       `json:"pid"`
+	Port    int       `json:"port"`
+	Root    string    `json:"root"`
+	URL     string    `json:"url"`
+	Version string    `json:"version,omitempty"`
+	Started time.Time `json:"started"`
+}
+
+// Registry stores one file per running instance
+type Registry struct {
+	dir string
+}
+
+// New opens the registry in ~/.inkwell/instances
+func New() (*Registry, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	return NewAt(filepath.Join(home, ".inkwell", "instances"))
+}
+
+// NewAt opens a registry stored in dir
+func NewAt(dir string) (*Registry, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &Registry{dir: dir}, nil
+}
+
+// Register records a running instance
+func (r *Registry) Register(inst Instance) error {
+	data, err := json.MarshalIndent(inst, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(r.dir, "instance.*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), r.path(inst.PID))
+}
+
+// Unregister removes an instance's entry
+func (r *Registry) Unregister(pid int) error {
+	err := os.Remove(r.path(pid))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// List returns the running instances, oldest first, removing entries left
+// behind by processes that have exited
+func (r *Registry) List() ([]Instance, error) {
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	list := []Instance{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasSuffix(name, ".json") {
+			continue
+		}
+
+		var inst Instance
+		data, err := os.ReadFile(filepath.Join(r.dir, name))
+		if err != nil || json.Unmarshal(data, &inst) != nil || inst.PID <= 0 {
+			os.Remove(filepath.Join(r.dir, name))
+			continue
+		}
+		if !processAlive(inst.PID) {
+			r.Unregister(inst.PID)
+			continue
+		}
+		list = append(list, inst)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Started.Before(list[j].Started)
+	})
+	return list, nil
+}
+
+// Find returns the running instances matching a vault given as a path,
+// a directory name, a port or a PID
+func (r *Registry) Find(vault string) ([]Instance, error) {
+	list, err := r.List()
+	if err != nil {
+		return nil, err
+	}
+
+	abs, _ := filepath.Abs(vault)
+	n, numErr := strconv.Atoi(vault)
+
+	var matches []Instance
+	for _, inst := range list {
+		switch {
+		case numErr == nil && (inst.Port == n || inst.PID == n),
+			inst.Root == abs,
+			filepath.Base(inst.Root) == vault:
+			matches = append(matches, inst)
+		}
+	}
+	return matches, nil
+}
+
+// Stop asks an instance to shut down gracefully
+func Stop(inst Instance) error {
+	if err := stopProcess(inst.PID); err != nil {
+		return fmt.Errorf("failed to stop process %d: %w", inst.PID, err)
+	}
+	return nil
+}
+
+// path returns the file recording an instance
+func (r *Registry) path(pid int) string {
+	return filepath.Join(r.dir, strconv.Itoa(pid)+".json")
+}
@@ -0,0 +1,55 @@
+package instances
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRegistry(t *testing.T) {
+	r, err := NewAt(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewAt failed: %v", err)
+	}
+
+	root := t.TempDir()
+	self := Instance{PID: os.Getpid(), Port: 4321, Root: root, Started: time.Now()}
+	if err := r.Register(self); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	// An entry left behind by a process that no longer exists
+	stale := Instance{PID: 1 << 30, Port: 4322, Root: root}
+	if err := r.Register(stale); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	list, err := r.List()
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(list) != 1 || list[0].PID != self.PID {
+		t.Fatalf("List = %+v, want only this process", list)
+	}
+	if _, err := os.Stat(r.path(stale.PID)); !os.IsNotExist(err) {
+		t.Error("Stale entry should be removed")
+	}
+
+	for _, vault := range []string{root, filepath.Base(root), "4321"} {
+		matches, err := r.Find(vault)
+		if err != nil || len(matches) != 1 {
+			t.Errorf("Find(%q) = %+v, %v", vault, matches, err)
+		}
+	}
+	if matches, _ := r.Find("elsewhere"); len(matches) != 0 {
+		t.Errorf("Find(elsewhere) = %+v", matches)
+	}
+
+	if err := r.Unregister(self.PID); err != nil {
+		t.Fatalf("Unregister failed: %v", err)
+	}
+	if list, _ := r.List(); len(list) != 0 {
+		t.Errorf("List after Unregister = %+v", list)
+	}
+}
@@ -0,0 +1,19 @@
+//go:build !windows
+
+package instances
+
+import (
+	"errors"
+	"syscall"
+)
+
+// processAlive reports whether a process exists
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// stopProcess sends SIGTERM, which Inkwell handles with a graceful shutdown
+func stopProcess(pid int) error {
+	return syscall.Kill(pid, syscall.SIGTERM)
+}
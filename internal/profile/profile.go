@@ -0,0 +1,201 @@
+// Package profile copies the Inkwell data in ~/.inkwell to a zip file and
+// back, for setting up another machine
+package profile
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	inkwellDir = ".inkwell"
+	usersDir   = "users" // Per-user settings and recents, by user ID
+)
+
+// files are the profile's files in ~/.inkwell. Secret ones hold password
+// hashes and API key hashes, and are left out on request.
+var files = []struct {
+	name   string
+	secret bool
+}{
+	{"settings.json", false},
+	{"recents.json", false},
+	{"recent-files.json", false},
+	{"favorites.json", false},
+	{"access.json", false},
+	{"users.json", true},
+	{"apikeys.json", true},
+}
+
+// ErrExists is returned when an import would replace files already there
+var ErrExists = errors.New("profile files already exist")
+
+// Dir returns ~/.inkwell
+func Dir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, inkwellDir), nil
+}
+
+// allowed reports whether name, a slash-separated path in the archive, is
+// part of a profile, and whether it is secret
+func allowed(name string) (ok, secret bool) {
+	for _, f := range files {
+		if name == f.name {
+			return true, f.secret
+		}
+	}
+	// users/<id>/<file>.json, but not lock files or anything deeper
+	parts := strings.Split(name, "/")
+	if len(parts) == 3 && parts[0] == usersDir && parts[1] != "" && parts[1] != "." && parts[1] != ".." &&
+		strings.HasSuffix(parts[2], ".json") && path.Clean(name) == name {
+		return true, false
+	}
+	return false, false
+}
+
+// Export writes the profile in dir to w as a zip archive, leaving out
+// users and API keys unless secrets is set. The names of the files
+// written are returned.
+func Export(w io.Writer, dir string, secrets bool) ([]string, error) {
+	var names []string
+	for _, f := range files {
+		if f.secret && !secrets {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dir, f.name)); err == nil {
+			names = append(names, f.name)
+		}
+	}
+	userFiles, _ := filepath.Glob(filepath.Join(dir, usersDir, "*", "*.json"))
+	for _, p := range userFiles {
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return nil, err
+		}
+		if ok, _ := allowed(filepath.ToSlash(rel)); ok {
+			names = append(names, filepath.ToSlash(rel))
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no Inkwell profile found in %s", dir)
+	}
+
+	zw := zip.NewWriter(w)
+	for _, name := range names {
+		if err := addFile(zw, filepath.Join(dir, filepath.FromSlash(name)), name); err != nil {
+			zw.Close()
+			return nil, fmt.Errorf("failed to export %s: %w", name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// addFile copies a file into the archive
+func addFile(zw *zip.Writer, p, name string) error {
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	header.Method = zip.Deflate
+
+	entry, err := zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(entry, f)
+	return err
+}
+
+// Import restores a profile written by Export into dir. Unless overwrite is
+// set, nothing is written if any of its files already exist there. Entries
+// that aren't part of a profile are skipped. The names of the files
+// restored are returned.
+func Import(r io.ReaderAt, size int64, dir string, overwrite bool) ([]string, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("not a profile archive: %w", err)
+	}
+
+	var entries []*zip.File
+	var existing []string
+	for _, f := range zr.File {
+		if ok, _ := allowed(f.Name); !ok || f.FileInfo().IsDir() {
+			continue
+		}
+		entries = append(entries, f)
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(f.Name))); err == nil {
+			existing = append(existing, f.Name)
+		}
+	}
+	if len(entries) == 0 {
+		return nil, errors.New("archive holds no Inkwell profile")
+	}
+	if len(existing) > 0 && !overwrite {
+		sort.Strings(existing)
+		return nil, fmt.Errorf("%w: %s", ErrExists, strings.Join(existing, ", "))
+	}
+
+	var names []string
+	for _, f := range entries {
+		if err := extract(f, filepath.Join(dir, filepath.FromSlash(f.Name))); err != nil {
+			return names, fmt.Errorf("failed to import %s: %w", f.Name, err)
+		}
+		names = append(names, f.Name)
+	}
+	return names, nil
+}
+
+// extract writes an archive entry to p, replacing it in one step so a
+// failed import leaves no half-written file
+func extract(f *zip.File, p string) error {
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	src, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".import-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = io.Copy(tmp, src)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
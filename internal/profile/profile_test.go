@@ -0,0 +1,87 @@
+package profile
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestExportImport(t *testing.T) {
+	src := t.TempDir()
+	os.MkdirAll(filepath.Join(src, "users", "u1"), 0755)
+	os.MkdirAll(filepath.Join(src, "repos", "notes"), 0755)
+	for name, content := range map[string]string{
+		"settings.json":          `{"theme":"dark"}`,
+		"recents.json":           `[]`,
+		"users.json":             `[{"id":"u1"}]`,
+		"apikeys.json":           `[]`,
+		"users/u1/settings.json": `{"theme":"light"}`,
+		"users/u1/recents.lock":  ``,
+		"audit.log":              `{}`,
+		"repos/notes/README.md":  `# Notes`,
+	} {
+		os.WriteFile(filepath.Join(src, filepath.FromSlash(name)), []byte(content), 0644)
+	}
+
+	var buf bytes.Buffer
+	names, err := Export(&buf, src, false)
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	want := []string{"settings.json", "recents.json", "users/u1/settings.json"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("Expected %v without secrets, got %v", want, names)
+	}
+
+	buf.Reset()
+	if _, err := Export(&buf, src, true); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	dest := t.TempDir()
+	data := buf.Bytes()
+	names, err = Import(bytes.NewReader(data), int64(len(data)), dest, false)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if len(names) != 5 {
+		t.Errorf("Expected 5 files imported, got %v", names)
+	}
+	if got, _ := os.ReadFile(filepath.Join(dest, "users", "u1", "settings.json")); string(got) != `{"theme":"light"}` {
+		t.Errorf("Unexpected user settings: %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "audit.log")); err == nil {
+		t.Error("Expected the audit log left out")
+	}
+
+	// Nothing is replaced without overwrite
+	os.WriteFile(filepath.Join(dest, "settings.json"), []byte(`{"theme":"sepia"}`), 0644)
+	if _, err := Import(bytes.NewReader(data), int64(len(data)), dest, false); !errors.Is(err, ErrExists) {
+		t.Fatalf("Expected ErrExists, got %v", err)
+	}
+	if _, err := Import(bytes.NewReader(data), int64(len(data)), dest, true); err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(dest, "settings.json")); string(got) != `{"theme":"dark"}` {
+		t.Errorf("Expected settings replaced, got %q", got)
+	}
+}
+
+func TestAllowed(t *testing.T) {
+	for name, want := range map[string]bool{
+		"settings.json":          true,
+		"users/u1/recents.json":  true,
+		"users/../settings.json": false,
+		"users/u1/a/b.json":      false,
+		"../settings.json":       false,
+		"/etc/passwd":            false,
+		"instances/1.json":       false,
+	} {
+		if ok, _ := allowed(name); ok != want {
+			t.Errorf("allowed(%q) = %v, want %v", name, ok, want)
+		}
+	}
+}
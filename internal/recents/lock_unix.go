@@ -0,0 +1,25 @@
+//go:build !windows
+
+package recents
+
+import (
+	"os"
+	"syscall"
+)
+
+// lock takes an exclusive lock on path, shared by every Inkwell process,
+// and returns a function that releases it
+func lock(path string) (func(), error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return func() {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, nil
+}
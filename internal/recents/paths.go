@@ -0,0 +1,126 @@
+package recents
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// resolve returns the absolute path with symlinks resolved. A path that
+// can't be resolved, e.g. because it no longer exists, is only made
+// absolute.
+func resolve(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.Clean(path)
+	}
+	if real, err := filepath.EvalSymlinks(abs); err == nil {
+		return real
+	}
+	return abs
+}
+
+// key returns the path a directory is stored under: resolved, and spelled
+// like an existing location for the same directory, so that a case variant
+// on a case-insensitive file system matches it. m.mu must be held.
+func (m *Manager) key(path string) string {
+	path = resolve(path)
+	return canonical(m.locations, path)
+}
+
+// canonical returns the path of the location in locations that is the same
+// directory as path, or path itself if there is none
+func canonical(locations []Location, path string) string {
+	var info os.FileInfo
+	for _, loc := range locations {
+		if loc.Path == path {
+			return path
+		}
+		if !strings.EqualFold(loc.Path, path) {
+			continue
+		}
+		if info == nil {
+			var err error
+			if info, err = os.Stat(path); err != nil {
+				return path
+			}
+		}
+		if other, err := os.Stat(loc.Path); err == nil && os.SameFile(info, other) {
+			return loc.Path
+		}
+	}
+	return path
+}
+
+// normalize resolves the paths of locations listed newest first, marks
+// those whose directory is gone as missing, and folds duplicates into the
+// newest entry, which stays pinned and keeps a last file if any of them had
+func normalize(locations []Location) []Location {
+	result := make([]Location, 0, len(locations))
+	for _, loc := range locations {
+		loc.Path = canonical(result, resolve(loc.Path))
+		info, err := os.Stat(loc.Path)
+		loc.Missing = err != nil || !info.IsDir()
+
+		duplicate := false
+		for i := range result {
+			if result[i].Path == loc.Path {
+				result[i].Pinned = result[i].Pinned || loc.Pinned
+				if result[i].LastFile == "" {
+					result[i].LastFile = loc.LastFile
+				}
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			result = append(result, loc)
+		}
+	}
+	return result
+}
+
+// rekey moves files stored under a root spelled differently than its
+// location to the location's path. m.mu must be held.
+func (m *Manager) rekey(byRoot map[string][]File) {
+	for root, files := range byRoot {
+		key := m.key(root)
+		if key == root {
+			continue
+		}
+		delete(byRoot, root)
+		for _, f := range files {
+			found := false
+			for _, existing := range byRoot[key] {
+				if existing.Path == f.Path {
+					found = true
+					break
+				}
+			}
+			if !found {
+				byRoot[key] = append(byRoot[key], f)
+			}
+		}
+	}
+}
+
+// DropMissing removes the locations whose directory no longer existed when
+// the list was loaded. Pinned locations stay, marked missing, as they are
+// often on a drive that isn't mounted.
+func (m *Manager) DropMissing() error {
+	m.mu.Lock()
+	kept := make([]Location, 0, len(m.locations))
+	for _, loc := range m.locations {
+		if !loc.Missing || loc.Pinned {
+			kept = append(kept, loc)
+		}
+	}
+	dropped := len(kept) != len(m.locations)
+	m.locations = kept
+	m.mu.Unlock()
+
+	if !dropped {
+		return nil
+	}
+	return m.save()
+}
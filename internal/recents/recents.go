@@ -3,23 +3,43 @@ package recents
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
 	"time"
 )
 
 const (
-	maxRecents   = 5
-	inkwellDir   = ".inkwell"
-	recentsFile  = "recents.json"
+	inkwellDir    = ".inkwell"
+	recentsFile   = "recents.json"
+	filesFile     = "recent-files.json"
+	favoritesFile = "favorites.json"
+	lockFile      = "recents.lock"
 )
 
+// DefaultLimit is the number of locations kept unless SetLimit is called
+const DefaultLimit = 5
+
+// fileLimit is the number of recent files kept per workspace
+const fileLimit = 50
+
 // Location represents a recently opened directory
 type Location struct {
 	Path       string    `json:"path"`
 	Name       string    `json:"name"`
 	LastOpened time.Time `json:"lastOpened"`
+	Pinned     bool      `json:"pinned,omitempty"`   // Kept regardless of the limit
+	LastFile   string    `json:"lastFile,omitempty"` // File last opened there, relative to Path
+	Missing    bool      `json:"missing,omitempty"`  // Directory was gone when the list was loaded
+}
+
+// File represents a recently opened or saved file in a workspace
+type File struct {
+	Path       string    `json:"path"` // Relative to the workspace root
+	Name       string    `json:"name"`
+	LastOpened time.Time `json:"lastOpened"`
 }
 
 // Manager handles recent locations storage and retrieval
@@ -27,6 +47,17 @@ type Manager struct {
 	mu        sync.RWMutex
 	locations []Location
 	filePath  string
+	limit     int
+
+	files     map[string][]File // Recent files by workspace root
+	filesPath string
+
+	favorites     map[string][]File // Favorite files by workspace root
+	favoritesPath string
+
+	lockPath string
+	synced   time.Time       // When the list was last read from or written to disk
+	saves    chan chan error // Save requests, handled one at a time by saver
 }
 
 // New creates a new recents manager
@@ -36,61 +67,71 @@ func New() (*Manager, error) {
 		return nil, err
 	}
 
-	inkwellPath := filepath.Join(home, inkwellDir)
-	if err := os.MkdirAll(inkwellPath, 0755); err != nil {
+	return NewAt(filepath.Join(home, inkwellDir))
+}
+
+// NewAt creates a recents manager that stores its list in dir
+func NewAt(dir string) (*Manager, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
 		return nil, err
 	}
 
 	m := &Manager{
-		filePath:  filepath.Join(inkwellPath, recentsFile),
+		filePath:  filepath.Join(dir, recentsFile),
 		locations: make([]Location, 0),
+		limit:     DefaultLimit,
+		files:     make(map[string][]File),
+		filesPath: filepath.Join(dir, filesFile),
+
+		favorites:     make(map[string][]File),
+		favoritesPath: filepath.Join(dir, favoritesFile),
+
+		lockPath: filepath.Join(dir, lockFile),
+		saves:    make(chan chan error, 1),
 	}
+	go m.saver()
 
-	// Load existing recents
-	if err := m.load(); err != nil && !os.IsNotExist(err) {
-		// Log but don't fail - we can start fresh
-		return m, nil
+	// Load existing recents. Failing is not fatal - we can start fresh
+	m.load()
+
+	m.mu.Lock()
+	if data, err := os.ReadFile(m.filesPath); err == nil {
+		json.Unmarshal(data, &m.files)
 	}
+	if data, err := os.ReadFile(m.favoritesPath); err == nil {
+		json.Unmarshal(data, &m.favorites)
+	}
+	m.rekey(m.files)
+	m.rekey(m.favorites)
+	m.mu.Unlock()
 
 	return m, nil
 }
 
-// load reads recents from disk
+// load reads recents from disk, resolving their paths
 func (m *Manager) load() error {
 	data, err := os.ReadFile(m.filePath)
 	if err != nil {
 		return err
 	}
 
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
-	return json.Unmarshal(data, &m.locations)
-}
-
-// save writes recents to disk
-func (m *Manager) save() error {
-	m.mu.RLock()
-	data, err := json.MarshalIndent(m.locations, "", "  ")
-	m.mu.RUnlock()
-
-	if err != nil {
+	var locations []Location
+	if err := json.Unmarshal(data, &locations); err != nil {
 		return err
 	}
 
-	return os.WriteFile(m.filePath, data, 0644)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.synced = time.Now()
+	m.locations = normalize(locations)
+	return nil
 }
 
 // Add adds or updates a location in the recents list
 func (m *Manager) Add(path string) error {
-	// Get absolute path
-	absPath, err := filepath.Abs(path)
-	if err != nil {
-		return err
-	}
-
 	// Check if directory exists
-	info, err := os.Stat(absPath)
+	info, err := os.Stat(path)
 	if err != nil || !info.IsDir() {
 		return err
 	}
@@ -98,31 +139,29 @@ func (m *Manager) Add(path string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	// Remove if already exists
-	newLocations := make([]Location, 0, maxRecents)
-	for _, loc := range m.locations {
-		if loc.Path != absPath {
-			newLocations = append(newLocations, loc)
-		}
-	}
+	absPath := m.key(path)
 
-	// Add to front
+	// Remove if already exists, keeping its pin and last file
 	loc := Location{
 		Path:       absPath,
 		Name:       filepath.Base(absPath),
 		LastOpened: time.Now(),
 	}
-	newLocations = append([]Location{loc}, newLocations...)
-
-	// Trim to max size
-	if len(newLocations) > maxRecents {
-		newLocations = newLocations[:maxRecents]
+	newLocations := make([]Location, 0, m.limit)
+	for _, existing := range m.locations {
+		if existing.Path == absPath {
+			loc.Pinned = existing.Pinned
+			loc.LastFile = existing.LastFile
+		} else {
+			newLocations = append(newLocations, existing)
+		}
 	}
 
-	m.locations = newLocations
+	// Add to front and trim to max size
+	m.locations = trim(append([]Location{loc}, newLocations...), m.limit)
 
 	// Save asynchronously
-	go m.save()
+	m.saveAsync()
 
 	return nil
 }
@@ -145,4 +184,270 @@ func (m *Manager) Clear() error {
 	m.mu.Unlock()
 
 	return m.save()
-}
\ No newline at end of file
+}
+
+// trim keeps every pinned location and the newest limit unpinned ones
+func trim(locations []Location, limit int) []Location {
+	kept := make([]Location, 0, len(locations))
+	unpinned := 0
+	for _, loc := range locations {
+		if !loc.Pinned {
+			if unpinned == limit {
+				continue
+			}
+			unpinned++
+		}
+		kept = append(kept, loc)
+	}
+	return kept
+}
+
+// SetLimit changes how many unpinned locations are kept, dropping the
+// oldest ones if there are more than n
+func (m *Manager) SetLimit(n int) {
+	if n < 1 {
+		n = DefaultLimit
+	}
+
+	m.mu.Lock()
+	m.limit = n
+	before := len(m.locations)
+	m.locations = trim(m.locations, n)
+	trimmed := len(m.locations) != before
+	m.mu.Unlock()
+
+	if trimmed {
+		m.save()
+	}
+}
+
+// Remove removes a single location, reporting whether it was in the list
+func (m *Manager) Remove(path string) (bool, error) {
+	m.mu.Lock()
+	absPath := m.key(path)
+	newLocations := make([]Location, 0, len(m.locations))
+	for _, loc := range m.locations {
+		if loc.Path != absPath {
+			newLocations = append(newLocations, loc)
+		}
+	}
+	removed := len(newLocations) != len(m.locations)
+	m.locations = newLocations
+	m.mu.Unlock()
+
+	if !removed {
+		return false, nil
+	}
+	return true, m.save()
+}
+
+// AddFile records that a file in the workspace at root was opened or saved,
+// making it the file that location resumes at
+func (m *Manager) AddFile(root, path string) {
+	path = filepath.ToSlash(filepath.Clean(path))
+
+	m.mu.Lock()
+	root = m.key(root)
+	files := make([]File, 0, fileLimit)
+	files = append(files, File{
+		Path:       path,
+		Name:       filepath.Base(path),
+		LastOpened: time.Now(),
+	})
+	for _, f := range m.files[root] {
+		if f.Path != path && len(files) < fileLimit {
+			files = append(files, f)
+		}
+	}
+	m.files[root] = files
+	for i := range m.locations {
+		if m.locations[i].Path == root {
+			m.locations[i].LastFile = path
+		}
+	}
+	m.mu.Unlock()
+
+	m.saveAsync()
+}
+
+// LastFile returns the file last opened in the recent location at root, or
+// "" if there is none
+func (m *Manager) LastFile(root string) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	root = m.key(root)
+	for _, loc := range m.locations {
+		if loc.Path == root {
+			return loc.LastFile
+		}
+	}
+	return ""
+}
+
+// Files returns the files recently used in the workspace at root, newest
+// first. Files last used before since are left out unless since is zero.
+func (m *Manager) Files(root string, since time.Time) []File {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	root = m.key(root)
+	result := make([]File, 0, len(m.files[root]))
+	for _, f := range m.files[root] {
+		if f.LastOpened.Before(since) {
+			break
+		}
+		result = append(result, f)
+	}
+	return result
+}
+
+// Pin pins or unpins a location. Pinning a directory that is not in the
+// list adds it.
+func (m *Manager) Pin(path string, pinned bool) error {
+	m.mu.Lock()
+	absPath := m.key(path)
+	found := false
+	for i := range m.locations {
+		if m.locations[i].Path == absPath {
+			m.locations[i].Pinned = pinned
+			found = true
+		}
+	}
+	if !found && pinned {
+		info, err := os.Stat(absPath)
+		if err != nil {
+			m.mu.Unlock()
+			return err
+		}
+		if !info.IsDir() {
+			m.mu.Unlock()
+			return fmt.Errorf("%s is not a directory", absPath)
+		}
+		m.locations = append(m.locations, Location{
+			Path:       absPath,
+			Name:       filepath.Base(absPath),
+			LastOpened: time.Now(),
+			Pinned:     true,
+		})
+	}
+	m.locations = trim(m.locations, m.limit)
+	m.mu.Unlock()
+
+	return m.save()
+}
+
+// Favorites returns the favorite files of the workspace at root
+func (m *Manager) Favorites(root string) []File {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	root = m.key(root)
+	result := make([]File, len(m.favorites[root]))
+	copy(result, m.favorites[root])
+	return result
+}
+
+// SetFavorite adds a file of the workspace at root to its favorites, or
+// removes it. It reports whether the list changed.
+func (m *Manager) SetFavorite(root, path string, favorite bool) (bool, error) {
+	path = filepath.ToSlash(filepath.Clean(path))
+
+	m.mu.Lock()
+	root = m.key(root)
+	favorites := make([]File, 0, len(m.favorites[root])+1)
+	found := false
+	for _, f := range m.favorites[root] {
+		if f.Path == path {
+			found = true
+			if !favorite {
+				continue
+			}
+		}
+		favorites = append(favorites, f)
+	}
+	if found == favorite {
+		m.mu.Unlock()
+		return false, nil
+	}
+	if favorite {
+		favorites = append(favorites, File{
+			Path:       path,
+			Name:       filepath.Base(path),
+			LastOpened: time.Now(),
+		})
+	}
+	if len(favorites) == 0 {
+		delete(m.favorites, root)
+	} else {
+		m.favorites[root] = favorites
+	}
+	m.mu.Unlock()
+
+	return true, m.save()
+}
+
+// Merge adds locations recorded elsewhere, such as on another machine. A
+// location in both lists keeps the entry that was opened most recently.
+func (m *Manager) Merge(locations []Location) error {
+	m.mu.Lock()
+	m.locations = trim(normalize(mergeLocations(m.locations, locations)), m.limit)
+	m.mu.Unlock()
+
+	return m.save()
+}
+
+// mergeLocations combines two lists, newest first. A path in both keeps
+// the entry that was opened most recently.
+func mergeLocations(a, b []Location) []Location {
+	byPath := make(map[string]Location, len(a)+len(b))
+	for _, loc := range append(append([]Location{}, a...), b...) {
+		if existing, ok := byPath[loc.Path]; !ok || loc.LastOpened.After(existing.LastOpened) {
+			byPath[loc.Path] = loc
+		}
+	}
+
+	merged := make([]Location, 0, len(byPath))
+	for _, loc := range byPath {
+		merged = append(merged, loc)
+	}
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].LastOpened.After(merged[j].LastOpened)
+	})
+	return merged
+}
+
+// AllFavorites returns the favorite files of every workspace, by root
+func (m *Manager) AllFavorites() map[string][]File {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make(map[string][]File, len(m.favorites))
+	for root, files := range m.favorites {
+		result[root] = append([]File{}, files...)
+	}
+	return result
+}
+
+// MergeFavorites adds favorite files recorded elsewhere
+func (m *Manager) MergeFavorites(favorites map[string][]File) error {
+	m.mu.Lock()
+	for root, files := range favorites {
+		root = m.key(root)
+		for _, f := range files {
+			found := false
+			for _, existing := range m.favorites[root] {
+				if existing.Path == f.Path {
+					found = true
+					break
+				}
+			}
+			if !found {
+				m.favorites[root] = append(m.favorites[root], f)
+			}
+		}
+	}
+	m.mu.Unlock()
+
+	return m.save()
+}
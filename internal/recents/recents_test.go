@@ -0,0 +1,316 @@
+package recents
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLimitAndRemove(t *testing.T) {
+	// Add saves in the background, so the store may be written after the
+	// test returns
+	store, err := os.MkdirTemp("", "recents-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(store)
+
+	m, err := NewAt(store)
+	if err != nil {
+		t.Fatalf("NewAt failed: %v", err)
+	}
+
+	base := t.TempDir()
+	var dirs []string
+	for _, name := range []string{"a", "b", "c", "d"} {
+		dir := filepath.Join(base, name)
+		if err := os.Mkdir(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		dirs = append(dirs, dir)
+	}
+
+	m.SetLimit(3)
+	for _, dir := range dirs {
+		m.Add(dir)
+	}
+
+	got := m.GetAll()
+	if len(got) != 3 || got[0].Path != dirs[3] || got[2].Path != dirs[1] {
+		t.Fatalf("Expected the three newest locations, got %v", got)
+	}
+
+	removed, err := m.Remove(dirs[2])
+	if err != nil || !removed {
+		t.Fatalf("Remove = %v, %v; want true, nil", removed, err)
+	}
+	if removed, _ := m.Remove(dirs[0]); removed {
+		t.Error("Remove reported a location that was not in the list")
+	}
+
+	m.SetLimit(1)
+	if got := m.GetAll(); len(got) != 1 || got[0].Path != dirs[3] {
+		t.Errorf("Expected only the newest location after lowering the limit, got %v", got)
+	}
+}
+
+func TestFiles(t *testing.T) {
+	store, err := os.MkdirTemp("", "recents-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(store)
+
+	m, err := NewAt(store)
+	if err != nil {
+		t.Fatalf("NewAt failed: %v", err)
+	}
+
+	m.AddFile("/vault", "a.md")
+	m.AddFile("/vault", "notes/b.md")
+	m.AddFile("/vault", "a.md")
+	m.AddFile("/other", "c.md")
+
+	files := m.Files("/vault", time.Time{})
+	if len(files) != 2 || files[0].Path != "a.md" || files[1].Path != "notes/b.md" {
+		t.Fatalf("Expected a.md then notes/b.md, got %v", files)
+	}
+	if files[1].Name != "b.md" {
+		t.Errorf("Expected name b.md, got %s", files[1].Name)
+	}
+
+	if files := m.Files("/vault", time.Now().Add(time.Hour)); len(files) != 0 {
+		t.Errorf("Expected no files used in the future, got %v", files)
+	}
+}
+
+func TestPinAndFavorites(t *testing.T) {
+	store, err := os.MkdirTemp("", "recents-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(store)
+
+	m, err := NewAt(store)
+	if err != nil {
+		t.Fatalf("NewAt failed: %v", err)
+	}
+	m.SetLimit(2)
+
+	base := t.TempDir()
+	var dirs []string
+	for _, name := range []string{"a", "b", "c", "d"} {
+		dir := filepath.Join(base, name)
+		if err := os.Mkdir(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		dirs = append(dirs, dir)
+	}
+
+	if err := m.Pin(dirs[0], true); err != nil {
+		t.Fatalf("Pin failed: %v", err)
+	}
+	for _, dir := range dirs[1:] {
+		m.Add(dir)
+	}
+
+	got := m.GetAll()
+	if len(got) != 3 || got[0].Path != dirs[3] || got[1].Path != dirs[2] || got[2].Path != dirs[0] || !got[2].Pinned {
+		t.Fatalf("Expected the pinned location to survive the limit, got %v", got)
+	}
+
+	m.Add(dirs[0])
+	if got := m.GetAll(); !got[0].Pinned {
+		t.Error("Reopening a pinned location unpinned it")
+	}
+
+	if err := m.Pin(dirs[0], false); err != nil {
+		t.Fatalf("Unpin failed: %v", err)
+	}
+	if got := m.GetAll(); len(got) != 2 {
+		t.Errorf("Expected the limit to apply after unpinning, got %v", got)
+	}
+
+	if changed, err := m.SetFavorite("/vault", "notes/a.md", true); err != nil || !changed {
+		t.Fatalf("SetFavorite = %v, %v; want true, nil", changed, err)
+	}
+	if changed, _ := m.SetFavorite("/vault", "notes/a.md", true); changed {
+		t.Error("Adding a favorite twice reported a change")
+	}
+	if favs := m.Favorites("/vault"); len(favs) != 1 || favs[0].Name != "a.md" {
+		t.Errorf("Expected notes/a.md as a favorite, got %v", favs)
+	}
+
+	// Favorites are persisted
+	reloaded, err := NewAt(store)
+	if err != nil {
+		t.Fatalf("NewAt failed: %v", err)
+	}
+	if favs := reloaded.Favorites("/vault"); len(favs) != 1 {
+		t.Errorf("Expected favorites to be reloaded, got %v", favs)
+	}
+
+	if changed, _ := m.SetFavorite("/vault", "notes/a.md", false); !changed {
+		t.Error("Removing a favorite reported no change")
+	}
+	if favs := m.Favorites("/vault"); len(favs) != 0 {
+		t.Errorf("Expected no favorites, got %v", favs)
+	}
+}
+
+func TestConcurrentInstances(t *testing.T) {
+	store, err := os.MkdirTemp("", "recents-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(store)
+
+	a, err := NewAt(store)
+	if err != nil {
+		t.Fatalf("NewAt failed: %v", err)
+	}
+	b, err := NewAt(store)
+	if err != nil {
+		t.Fatalf("NewAt failed: %v", err)
+	}
+
+	base := t.TempDir()
+	first, second := filepath.Join(base, "first"), filepath.Join(base, "second")
+	for _, dir := range []string{first, second} {
+		if err := os.Mkdir(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Each instance only knows about its own location, but neither write
+	// may drop the other's
+	a.Add(first)
+	if err := a.save(); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+	b.Add(second)
+	if err := b.save(); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+
+	c, err := NewAt(store)
+	if err != nil {
+		t.Fatalf("NewAt failed: %v", err)
+	}
+	got := c.GetAll()
+	if len(got) != 2 || got[0].Path != second || got[1].Path != first {
+		t.Errorf("Expected both locations after concurrent writes, got %v", got)
+	}
+}
+
+func TestLastFile(t *testing.T) {
+	store, err := os.MkdirTemp("", "recents-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(store)
+
+	m, err := NewAt(store)
+	if err != nil {
+		t.Fatalf("NewAt failed: %v", err)
+	}
+
+	root := t.TempDir()
+	m.Add(root)
+	if last := m.LastFile(root); last != "" {
+		t.Errorf("Expected no last file for a new location, got %q", last)
+	}
+
+	m.AddFile(root, "notes/a.md")
+	m.AddFile(root, "b.md")
+	m.Add(root)
+	if last := m.LastFile(root); last != "b.md" {
+		t.Errorf("Expected b.md to survive reopening the location, got %q", last)
+	}
+
+	if err := m.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	reloaded, err := NewAt(store)
+	if err != nil {
+		t.Fatalf("NewAt failed: %v", err)
+	}
+	if got := reloaded.GetAll(); len(got) != 1 || got[0].LastFile != "b.md" {
+		t.Errorf("Expected the last file to be saved, got %v", got)
+	}
+}
+
+func TestNormalizePaths(t *testing.T) {
+	store, err := os.MkdirTemp("", "recents-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(store)
+
+	m, err := NewAt(store)
+	if err != nil {
+		t.Fatalf("NewAt failed: %v", err)
+	}
+
+	base, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	notes, link, gone, kept := filepath.Join(base, "notes"), filepath.Join(base, "link"), filepath.Join(base, "gone"), filepath.Join(base, "kept")
+	for _, dir := range []string{notes, gone, kept} {
+		if err := os.Mkdir(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(notes, link); err != nil {
+		t.Skipf("Symlinks not supported: %v", err)
+	}
+
+	m.Add(gone)
+	m.Add(kept)
+	m.Pin(kept, true)
+	m.Add(notes)
+	m.Add(link)
+	m.AddFile(link, "a.md")
+
+	got := m.GetAll()
+	if len(got) != 3 || got[0].Path != notes {
+		t.Fatalf("Expected the symlink to fold into %s, got %v", notes, got)
+	}
+	if files := m.Files(notes, time.Time{}); len(files) != 1 {
+		t.Errorf("Expected a.md under the resolved path, got %v", files)
+	}
+
+	if err := m.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if err := os.Remove(gone); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(kept); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := NewAt(store)
+	if err != nil {
+		t.Fatalf("NewAt failed: %v", err)
+	}
+	missing := 0
+	for _, loc := range reloaded.GetAll() {
+		if loc.Missing {
+			missing++
+		}
+	}
+	if missing != 2 {
+		t.Errorf("Expected two locations marked missing, got %v", reloaded.GetAll())
+	}
+
+	if err := reloaded.DropMissing(); err != nil {
+		t.Fatalf("DropMissing failed: %v", err)
+	}
+	got = reloaded.GetAll()
+	if len(got) != 2 || got[0].Path != notes || got[1].Path != kept || !got[1].Missing {
+		t.Errorf("Expected notes and the pinned missing location, got %v", got)
+	}
+}
@@ -0,0 +1,95 @@
+package recents
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"inkwell/internal/atomicfile"
+)
+
+// saver writes the lists to disk for every save request, one at a time
+func (m *Manager) saver() {
+	for done := range m.saves {
+		err := m.persist()
+		if done != nil {
+			done <- err
+		}
+	}
+}
+
+// save writes the lists to disk and waits for the write to finish
+func (m *Manager) save() error {
+	done := make(chan error, 1)
+	m.saves <- done
+	return <-done
+}
+
+// Flush writes the lists to disk, including changes still waiting to be
+// saved in the background
+func (m *Manager) Flush() error {
+	return m.save()
+}
+
+// saveAsync asks for the lists to be written without waiting. Requests
+// made while one is already queued are folded into it, as the queued write
+// picks up the latest state.
+func (m *Manager) saveAsync() {
+	select {
+	case m.saves <- nil:
+	default:
+	}
+}
+
+// persist writes the lists while holding the lock shared with other
+// Inkwell processes. Locations another process opened since this one last
+// synced are merged in first, so concurrent instances don't drop each
+// other's entries.
+func (m *Manager) persist() error {
+	unlock, err := lock(m.lockPath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	m.mu.Lock()
+	if data, err := os.ReadFile(m.filePath); err == nil {
+		var onDisk []Location
+		if json.Unmarshal(data, &onDisk) == nil {
+			var newer []Location
+			for _, loc := range onDisk {
+				if loc.LastOpened.After(m.synced) {
+					newer = append(newer, loc)
+				}
+			}
+			if len(newer) > 0 {
+				m.locations = trim(mergeLocations(m.locations, newer), m.limit)
+			}
+		}
+	}
+	m.synced = time.Now()
+
+	locations, err := json.MarshalIndent(m.locations, "", "  ")
+	if err != nil {
+		m.mu.Unlock()
+		return err
+	}
+	files, err := json.MarshalIndent(m.files, "", "  ")
+	if err != nil {
+		m.mu.Unlock()
+		return err
+	}
+	favorites, err := json.MarshalIndent(m.favorites, "", "  ")
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := atomicfile.WriteFile(m.filePath, locations, 0600); err != nil {
+		return err
+	}
+	if err := atomicfile.WriteFile(m.filesPath, files, 0600); err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(m.favoritesPath, favorites, 0600)
+}
@@ -0,0 +1,217 @@
+package render
+
+import (
+	"bytes"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// KindMathInline is the node kind for inline $...$ math
+var KindMathInline = ast.NewNodeKind("MathInline")
+
+// KindMathBlock is the node kind for display $$...$$ math
+var KindMathBlock = ast.NewNodeKind("MathBlock")
+
+// MathInline represents an inline formula
+type MathInline struct {
+	ast.BaseInline
+}
+
+// Kind implements ast.Node
+func (n *MathInline) Kind() ast.NodeKind {
+	return KindMathInline
+}
+
+// Dump implements ast.Node
+func (n *MathInline) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, nil, nil)
+}
+
+// MathBlock represents a display formula
+type MathBlock struct {
+	ast.BaseBlock
+	closed bool // Opened and closed on the same line
+}
+
+// Kind implements ast.Node
+func (n *MathBlock) Kind() ast.NodeKind {
+	return KindMathBlock
+}
+
+// IsRaw implements ast.Node
+func (n *MathBlock) IsRaw() bool {
+	return true
+}
+
+// Dump implements ast.Node
+func (n *MathBlock) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, nil, nil)
+}
+
+// mathInlineParser parses $...$ spans. Like pandoc, the opening $ must be
+// followed by a non-space and the closing $ must not be preceded by a space
+// or followed by a digit, so prices such as "$5 and $10" stay plain text.
+type mathInlineParser struct{}
+
+func (p *mathInlineParser) Trigger() []byte {
+	return []byte{'$'}
+}
+
+func (p *mathInlineParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, segment := block.PeekLine()
+
+	// $$...$$ inside a paragraph is display math written inline
+	delim := 1
+	if len(line) > 1 && line[1] == '$' {
+		delim = 2
+	}
+	if len(line) <= delim || util.IsSpace(line[delim]) {
+		return nil
+	}
+
+	for i := delim; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '$':
+			if delim == 2 && (i+1 >= len(line) || line[i+1] != '$') {
+				continue
+			}
+			if util.IsSpace(line[i-1]) {
+				continue
+			}
+			end := i + delim
+			if end < len(line) && line[end] >= '0' && line[end] <= '9' {
+				continue
+			}
+			node := &MathInline{}
+			node.AppendChild(node, ast.NewRawTextSegment(text.NewSegment(segment.Start+delim, segment.Start+i)))
+			block.Advance(end)
+			return node
+		}
+	}
+
+	return nil
+}
+
+// mathBlockParser parses $$ fenced display math
+type mathBlockParser struct{}
+
+func (b *mathBlockParser) Trigger() []byte {
+	return []byte{'$'}
+}
+
+func (b *mathBlockParser) Open(parent ast.Node, reader text.Reader, pc parser.Context) (ast.Node, parser.State) {
+	line, segment := reader.PeekLine()
+	pos := pc.BlockOffset()
+	if pos < 0 || !bytes.HasPrefix(line[pos:], []byte("$$")) {
+		return nil, parser.NoChildren
+	}
+
+	node := &MathBlock{}
+	rest := util.TrimRightSpace(line[pos+2:])
+
+	// Single-line form: $$ x^2 $$
+	if len(rest) >= 2 && bytes.HasSuffix(rest, []byte("$$")) {
+		start := segment.Start + pos + 2
+		node.Lines().Append(text.NewSegment(start, start+len(rest)-2))
+		node.closed = true
+		return node, parser.NoChildren
+	}
+
+	// Anything after the opening fence is the first line of the formula
+	if !util.IsBlank(rest) {
+		node.Lines().Append(text.NewSegment(segment.Start+pos+2, segment.Stop))
+	}
+	return node, parser.NoChildren
+}
+
+func (b *mathBlockParser) Continue(node ast.Node, reader text.Reader, pc parser.Context) parser.State {
+	if node.(*MathBlock).closed {
+		return parser.Close
+	}
+
+	line, segment := reader.PeekLine()
+	trimmed := util.TrimRightSpace(line)
+
+	if bytes.HasSuffix(trimmed, []byte("$$")) {
+		if content := trimmed[:len(trimmed)-2]; !util.IsBlank(content) {
+			node.Lines().Append(segment.WithStop(segment.Start + len(content)))
+		}
+		reader.Advance(segment.Len() - 1)
+		return parser.Close
+	}
+
+	node.Lines().Append(segment)
+	reader.Advance(segment.Len() - 1)
+	return parser.Continue | parser.NoChildren
+}
+
+func (b *mathBlockParser) Close(node ast.Node, reader text.Reader, pc parser.Context) {}
+
+func (b *mathBlockParser) CanInterruptParagraph() bool {
+	return true
+}
+
+func (b *mathBlockParser) CanAcceptIndentedLine() bool {
+	return false
+}
+
+// mathHTMLRenderer emits the \(...\) and \[...\] delimiters that both
+// KaTeX auto-render and MathJax pick up by default
+type mathHTMLRenderer struct{}
+
+func (r *mathHTMLRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindMathInline, r.renderInline)
+	reg.Register(KindMathBlock, r.renderBlock)
+}
+
+func (r *mathHTMLRenderer) renderInline(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+
+	w.WriteString(`<span class="math inline">\(`)
+	for c := node.FirstChild(); c != nil; c = c.NextSibling() {
+		if t, ok := c.(*ast.Text); ok {
+			w.Write(util.EscapeHTML(t.Segment.Value(source)))
+		}
+	}
+	w.WriteString(`\)</span>`)
+	return ast.WalkSkipChildren, nil
+}
+
+func (r *mathHTMLRenderer) renderBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+
+	w.WriteString(`<div class="math display">\[`)
+	lines := node.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		seg := lines.At(i)
+		w.Write(util.EscapeHTML(seg.Value(source)))
+	}
+	w.WriteString("\\]</div>\n")
+	return ast.WalkContinue, nil
+}
+
+type mathExtension struct{}
+
+// Math is a goldmark extension for $...$ and $$...$$ formulas
+var Math goldmark.Extender = &mathExtension{}
+
+func (e *mathExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithBlockParsers(util.Prioritized(&mathBlockParser{}, 90)),
+		parser.WithInlineParsers(util.Prioritized(&mathInlineParser{}, 150)),
+	)
+	m.Renderer().AddOptions(
+		renderer.WithNodeRenderers(util.Prioritized(&mathHTMLRenderer{}, 500)),
+	)
+}
@@ -0,0 +1,238 @@
+package render
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	plantumlTimeout  = 30 * time.Second
+	maxPlantUMLBytes = 10 << 20 // 10MB
+)
+
+// plantumlEncoding is the base64 variant PlantUML uses in diagram URLs
+var plantumlEncoding = base64.NewEncoding("0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz-_").WithPadding(base64.NoPadding)
+
+// ErrDiagramNotCached is returned when a cached diagram is requested
+// that has not been rendered yet
+var ErrDiagramNotCached = errors.New("diagram not in cache")
+
+// PlantUMLCacheDir returns where the vault at rootDir caches its rendered
+// diagrams
+func PlantUMLCacheDir(rootDir string) string {
+	return filepath.Join(rootDir, ".inkwell", "cache", "plantuml")
+}
+
+// PlantUML renders diagrams to SVG through a PlantUML server or a local
+// plantuml.jar, caching results on disk by content hash
+type PlantUML struct {
+	serverURL string
+	jarPath   string
+	cacheDir  string
+	client    *http.Client
+}
+
+// NewPlantUML creates a PlantUML renderer caching diagrams in cacheDir,
+// which is created when the first diagram is stored. One of serverURL or
+// jarPath must be set; the jar takes precedence when both are.
+func NewPlantUML(serverURL, jarPath, cacheDir string) (*PlantUML, error) {
+	if serverURL == "" && jarPath == "" {
+		return nil, errors.New("either a PlantUML server URL or jar path is required")
+	}
+
+	if jarPath != "" {
+		if _, err := os.Stat(jarPath); err != nil {
+			return nil, fmt.Errorf("PlantUML jar not found: %w", err)
+		}
+	}
+
+	return &PlantUML{
+		serverURL: strings.TrimSuffix(serverURL, "/"),
+		jarPath:   jarPath,
+		cacheDir:  cacheDir,
+		client:    &http.Client{Timeout: plantumlTimeout},
+	}, nil
+}
+
+// Hash returns the cache key for a diagram source
+func (p *PlantUML) Hash(source string) string {
+	sum := sha256.Sum256([]byte(source))
+	return hex.EncodeToString(sum[:])
+}
+
+// RenderSVG returns the SVG for a diagram, rendering it on a cache miss. A
+// diagram with errors renders as an SVG describing them, which is returned
+// but not cached, so it is rendered again once the server can be reached
+// or has been fixed.
+func (p *PlantUML) RenderSVG(ctx context.Context, source string) ([]byte, error) {
+	hash := p.Hash(source)
+	if svg, err := p.Cached(hash); err == nil {
+		return svg, nil
+	}
+
+	var svg []byte
+	var ok bool
+	var err error
+	if p.jarPath != "" {
+		svg, ok, err = p.renderWithJar(ctx, source)
+	} else {
+		svg, ok, err = p.renderWithServer(ctx, source)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return svg, nil
+	}
+
+	if err := p.store(hash, svg); err != nil {
+		return nil, err
+	}
+
+	return svg, nil
+}
+
+// Cached returns a previously rendered diagram by hash
+func (p *PlantUML) Cached(hash string) ([]byte, error) {
+	if len(hash) != sha256.Size*2 {
+		return nil, ErrDiagramNotCached
+	}
+	if _, err := hex.DecodeString(hash); err != nil {
+		return nil, ErrDiagramNotCached
+	}
+
+	svg, err := os.ReadFile(filepath.Join(p.cacheDir, hash+".svg"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrDiagramNotCached
+		}
+		return nil, err
+	}
+	return svg, nil
+}
+
+// store writes a rendered diagram to the cache atomically
+func (p *PlantUML) store(hash string, svg []byte) error {
+	if err := createCacheDir(p.cacheDir); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(p.cacheDir, hash+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to cache diagram: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(svg); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to cache diagram: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to cache diagram: %w", err)
+	}
+
+	return os.Rename(tmp.Name(), filepath.Join(p.cacheDir, hash+".svg"))
+}
+
+// renderWithServer fetches the SVG from a PlantUML server. It reports
+// whether the diagram rendered without errors.
+func (p *PlantUML) renderWithServer(ctx context.Context, source string) ([]byte, bool, error) {
+	encoded, err := encodePlantUML(source)
+	if err != nil {
+		return nil, false, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.serverURL+"/svg/"+encoded, nil)
+	if err != nil {
+		return nil, false, err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, false, fmt.Errorf("PlantUML server request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	svg, err := io.ReadAll(io.LimitReader(resp.Body, maxPlantUMLBytes))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read PlantUML response: %w", err)
+	}
+
+	// PlantUML reports syntax errors as a 400 with an SVG describing the
+	// problem, which is still worth showing to the user
+	if resp.StatusCode != http.StatusOK && !bytes.Contains(svg, []byte("<svg")) {
+		return nil, false, fmt.Errorf("PlantUML server returned %s", resp.Status)
+	}
+
+	return svg, resp.StatusCode == http.StatusOK, nil
+}
+
+// renderWithJar pipes the source through a local plantuml.jar. It reports
+// whether the diagram rendered without errors.
+func (p *PlantUML) renderWithJar(ctx context.Context, source string) ([]byte, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, plantumlTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "java", "-jar", p.jarPath, "-tsvg", "-pipe", "-charset", "UTF-8")
+	cmd.Stdin = strings.NewReader(source)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if err != nil && !bytes.Contains(stdout.Bytes(), []byte("<svg")) {
+		return nil, false, fmt.Errorf("plantuml failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.Bytes(), err == nil, nil
+}
+
+// createCacheDir creates the cache directory, which ignores its own
+// contents so diagrams never show up as changes in a vault kept in git
+func createCacheDir(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	ignore := filepath.Join(dir, ".gitignore")
+	if _, err := os.Stat(ignore); os.IsNotExist(err) {
+		return os.WriteFile(ignore, []byte("*\n"), 0644)
+	}
+	return nil
+}
+
+// encodePlantUML deflates and encodes a diagram for use in a server URL
+func encodePlantUML(source string) (string, error) {
+	var buf bytes.Buffer
+	zw, err := flate.NewWriter(&buf, flate.BestCompression)
+	if err != nil {
+		return "", err
+	}
+	if _, err := zw.Write([]byte(source)); err != nil {
+		return "", err
+	}
+	if err := zw.Close(); err != nil {
+		return "", err
+	}
+
+	// PlantUML always encodes whole 3-byte groups, padding with zeros
+	data := buf.Bytes()
+	for len(data)%3 != 0 {
+		data = append(data, 0)
+	}
+
+	return plantumlEncoding.EncodeToString(data), nil
+}
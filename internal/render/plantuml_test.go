@@ -0,0 +1,138 @@
+package render
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEncodePlantUML(t *testing.T) {
+	source := "@startuml\nBob -> Alice : hello\n@enduml"
+
+	encoded, err := encodePlantUML(source)
+	if err != nil {
+		t.Fatalf("encodePlantUML failed: %v", err)
+	}
+
+	if strings.ContainsAny(encoded, "+/=") {
+		t.Errorf("Encoded diagram contains characters outside the PlantUML alphabet: %s", encoded)
+	}
+
+	data, err := plantumlEncoding.DecodeString(encoded)
+	if err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+
+	decoded, err := io.ReadAll(flate.NewReader(bytes.NewReader(data)))
+	if err != nil {
+		t.Fatalf("Failed to inflate: %v", err)
+	}
+
+	if string(decoded) != source {
+		t.Errorf("Round trip = %q, want %q", decoded, source)
+	}
+}
+
+func TestPlantUMLServerCaching(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if !strings.HasPrefix(r.URL.Path, "/svg/") {
+			t.Errorf("Unexpected request path: %s", r.URL.Path)
+		}
+		w.Write([]byte(`<?xml version="1.0"?><svg></svg>`))
+	}))
+	defer server.Close()
+
+	p, err := NewPlantUML(server.URL, "", PlantUMLCacheDir(t.TempDir()))
+	if err != nil {
+		t.Fatalf("NewPlantUML failed: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		svg, err := p.RenderSVG(context.Background(), "A -> B")
+		if err != nil {
+			t.Fatalf("RenderSVG failed: %v", err)
+		}
+		if !bytes.Contains(svg, []byte("<svg")) {
+			t.Errorf("Unexpected SVG: %s", svg)
+		}
+	}
+
+	if requests != 1 {
+		t.Errorf("Expected 1 server request, got %d", requests)
+	}
+
+	if _, err := p.Cached(p.Hash("A -> B")); err != nil {
+		t.Errorf("Diagram should be cached: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(p.cacheDir, ".gitignore")); err != nil {
+		t.Errorf("Cache should be ignored by git: %v", err)
+	}
+
+	if _, err := p.Cached("../../etc/passwd"); err != ErrDiagramNotCached {
+		t.Errorf("Invalid hash should not be served, got %v", err)
+	}
+
+	html, err := New(Options{}).Render([]byte("```plantuml\nA -> B\n```\n"))
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if !strings.Contains(html, "language-plantuml") {
+		t.Errorf("Without PlantUML the block should render as code: %s", html)
+	}
+
+	r := New(Options{})
+	r.SetPlantUML(p)
+	html, err = r.Render([]byte("```plantuml\nA -> B\n```\n"))
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	svg := base64.StdEncoding.EncodeToString([]byte(`<?xml version="1.0"?><svg></svg>`))
+	if !strings.Contains(html, `<div class="diagram plantuml"><img alt="PlantUML diagram" src="data:image/svg+xml;base64,`+svg+`"></div>`) {
+		t.Errorf("Expected the SVG as an image, got %s", html)
+	}
+}
+
+func TestPlantUMLErrorsNotCached(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`<svg><text>Syntax Error?</text></svg>`))
+	}))
+	defer server.Close()
+
+	vault := t.TempDir()
+	p, err := NewPlantUML(server.URL, "", PlantUMLCacheDir(vault))
+	if err != nil {
+		t.Fatalf("NewPlantUML failed: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		svg, err := p.RenderSVG(context.Background(), "A ->")
+		if err != nil {
+			t.Fatalf("RenderSVG failed: %v", err)
+		}
+		if !bytes.Contains(svg, []byte("Syntax Error")) {
+			t.Errorf("Expected the error diagram, got %s", svg)
+		}
+	}
+	if requests != 2 {
+		t.Errorf("Expected the diagram rendered again, got %d requests", requests)
+	}
+	if _, err := p.Cached(p.Hash("A ->")); err != ErrDiagramNotCached {
+		t.Errorf("Error diagram should not be cached, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(vault, ".inkwell")); !os.IsNotExist(err) {
+		t.Errorf("Nothing should be written to the vault, got %v", err)
+	}
+}
@@ -0,0 +1,90 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+// printStylesheet lays a note out for paper: each top-level section starts
+// on a new page and blocks are kept from splitting across pages
+const printStylesheet = `<style>
+@page { margin: 2cm; }
+body { font-family: Georgia, "Times New Roman", serif; font-size: 11pt; line-height: 1.5; color: #000; background: #fff; max-width: 42em; margin: 0 auto; }
+h1 { break-before: page; page-break-before: always; }
+h1:first-of-type { break-before: avoid; page-break-before: avoid; }
+h1, h2, h3, h4 { break-after: avoid; page-break-after: avoid; }
+pre, blockquote, table, img, figure, .diagram, .math.display { break-inside: avoid; page-break-inside: avoid; }
+pre { white-space: pre-wrap; font-size: 9pt; border: 1px solid #ccc; padding: 0.5em; }
+img, svg { max-width: 100%; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #999; padding: 0.25em 0.5em; }
+a { color: inherit; text-decoration: none; }
+.link-ref { font-size: 0.75em; vertical-align: super; line-height: 0; }
+.link-notes { border-top: 1px solid #999; margin-top: 2em; font-size: 0.85em; }
+.link-notes li { word-break: break-all; }
+.anchor { display: none; }
+.toc ul { list-style: none; padding-left: 1.5em; }
+.toc > ul { padding-left: 0; }
+</style>
+`
+
+// RenderPrint converts markdown to HTML for printing. External link URLs
+// are collected into numbered notes at the end of the document, since
+// they are lost once the page is on paper.
+func (r *Renderer) RenderPrint(source []byte) (string, error) {
+	doc := r.parse(source)
+
+	var urls []string
+	var links []*ast.Link
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if link, ok := n.(*ast.Link); ok && entering && isExternalURL(string(link.Destination)) {
+			links = append(links, link)
+		}
+		return ast.WalkContinue, nil
+	})
+
+	// Insert markers after walking so the tree isn't modified mid-walk
+	for _, link := range links {
+		urls = append(urls, string(link.Destination))
+		marker := ast.NewString([]byte(fmt.Sprintf(`<span class="link-ref">[%d]</span>`, len(urls))))
+		marker.SetCode(true)
+		link.Parent().InsertAfter(link.Parent(), link, marker)
+	}
+	toc := r.navigate(doc, source)
+
+	var buf bytes.Buffer
+	buf.WriteString(toc)
+	if err := r.md.Renderer().Render(&buf, source, doc); err != nil {
+		return "", fmt.Errorf("failed to render markdown: %w", err)
+	}
+
+	if len(urls) > 0 {
+		buf.WriteString("<section class=\"link-notes\">\n<ol>\n")
+		for _, url := range urls {
+			fmt.Fprintf(&buf, "<li>%s</li>\n", html.EscapeString(url))
+		}
+		buf.WriteString("</ol>\n</section>\n")
+	}
+
+	return buf.String(), nil
+}
+
+// PrintDocument wraps print HTML in a standalone page with the print
+// stylesheet. Relative links and images resolve against baseURL.
+func (r *Renderer) PrintDocument(title, body, baseURL string) string {
+	head := printStylesheet
+	if baseURL != "" {
+		head = fmt.Sprintf("<base href=\"%s\">\n", html.EscapeString(baseURL)) + head
+	}
+	return r.document(title, body, head)
+}
+
+// isExternalURL reports whether a link points off the page
+func isExternalURL(url string) bool {
+	lower := strings.ToLower(url)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://") || strings.HasPrefix(lower, "mailto:")
+}
@@ -0,0 +1,254 @@
+// Package render converts markdown notes to HTML for preview and export
+package render
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"html"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	gmhtml "github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/util"
+)
+
+// KaTeX assets loaded by standalone documents that contain math
+const (
+	katexVersion = "0.16.11"
+	katexBaseURL = "https://cdn.jsdelivr.net/npm/katex@" + katexVersion + "/dist"
+)
+
+// Markdown flavors, each the extensions another tool renders notes with
+const (
+	FlavorGitHub     = "github"
+	FlavorObsidian   = "obsidian"
+	FlavorCommonMark = "commonmark"
+	FlavorCustom     = "custom" // Extensions chosen one by one
+)
+
+// Flavors lists the flavors that can be chosen
+var Flavors = []string{FlavorGitHub, FlavorObsidian, FlavorCommonMark, FlavorCustom}
+
+// Extensions are the markdown syntaxes beyond CommonMark a note is
+// rendered with
+type Extensions struct {
+	Tables        bool `json:"tables"`
+	TaskLists     bool `json:"taskLists"`
+	Footnotes     bool `json:"footnotes"`
+	Strikethrough bool `json:"strikethrough"`
+	Linkify       bool `json:"linkify"`     // Bare URLs become links
+	Typographer   bool `json:"typographer"` // Smart quotes, dashes and ellipses
+	HardWraps     bool `json:"hardWraps"`   // Line breaks within a paragraph are kept
+}
+
+// FlavorExtensions returns the extensions of a named flavor. Custom and
+// unknown flavors have none.
+func FlavorExtensions(flavor string) (Extensions, bool) {
+	switch flavor {
+	case FlavorGitHub:
+		return Extensions{Tables: true, TaskLists: true, Footnotes: true, Strikethrough: true, Linkify: true}, true
+	case FlavorObsidian:
+		return Extensions{Tables: true, TaskLists: true, Footnotes: true, Strikethrough: true, Linkify: true, HardWraps: true}, true
+	case FlavorCommonMark:
+		return Extensions{}, true
+	}
+	return Extensions{}, false
+}
+
+// Options controls which markdown extensions are enabled
+type Options struct {
+	Math       bool       `json:"math"`       // Render $...$ and $$...$$ formulas
+	Flavor     string     `json:"flavor"`     // One of Flavors; empty for github
+	Extensions Extensions `json:"extensions"` // Chosen for the custom flavor, else filled in from Flavor
+}
+
+// Renderer converts markdown to HTML
+type Renderer struct {
+	opts     Options
+	md       goldmark.Markdown
+	plantuml *PlantUML
+	nav      Navigation
+}
+
+// New creates a new renderer with the given options
+func New(opts Options) *Renderer {
+	if opts.Flavor != FlavorCustom {
+		ext, ok := FlavorExtensions(opts.Flavor)
+		if !ok {
+			opts.Flavor = FlavorGitHub
+			ext, _ = FlavorExtensions(FlavorGitHub)
+		}
+		opts.Extensions = ext
+	}
+
+	var extensions []goldmark.Extender
+	for _, e := range []struct {
+		on       bool
+		extender goldmark.Extender
+	}{
+		{opts.Extensions.Tables, extension.Table},
+		{opts.Extensions.TaskLists, extension.TaskList},
+		{opts.Extensions.Footnotes, extension.Footnote},
+		{opts.Extensions.Strikethrough, extension.Strikethrough},
+		{opts.Extensions.Linkify, extension.Linkify},
+		{opts.Extensions.Typographer, extension.Typographer},
+		{opts.Math, Math},
+	} {
+		if e.on {
+			extensions = append(extensions, e.extender)
+		}
+	}
+
+	r := &Renderer{opts: opts}
+	rendererOptions := []renderer.Option{
+		renderer.WithNodeRenderers(util.Prioritized(&codeBlockRenderer{r: r}, 500)),
+	}
+	if opts.Extensions.HardWraps {
+		rendererOptions = append(rendererOptions, gmhtml.WithHardWraps())
+	}
+	r.md = goldmark.New(
+		goldmark.WithExtensions(extensions...),
+		goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+		goldmark.WithRendererOptions(rendererOptions...),
+	)
+
+	return r
+}
+
+// With returns a renderer with other options that shares this one's
+// diagram backend
+func (r *Renderer) With(opts Options) *Renderer {
+	renderer := New(opts)
+	renderer.plantuml = r.plantuml
+	return renderer
+}
+
+// SetPlantUML enables inline SVG rendering of ```plantuml blocks
+func (r *Renderer) SetPlantUML(p *PlantUML) {
+	r.plantuml = p
+}
+
+// Options returns the options the renderer was created with, with the
+// extensions of its flavor filled in
+func (r *Renderer) Options() Options {
+	return r.opts
+}
+
+// Render converts markdown source to an HTML fragment
+func (r *Renderer) Render(source []byte) (string, error) {
+	doc := r.parse(source)
+	toc := r.navigate(doc, source)
+
+	var buf bytes.Buffer
+	buf.WriteString(toc)
+	if err := r.md.Renderer().Render(&buf, source, doc); err != nil {
+		return "", fmt.Errorf("failed to render markdown: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// RenderLinks converts markdown to HTML, passing every link and image
+// destination through rewrite first
+func (r *Renderer) RenderLinks(source []byte, rewrite func(dest string) string) (string, error) {
+	doc := r.parse(source)
+
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch node := n.(type) {
+		case *ast.Link:
+			node.Destination = []byte(rewrite(string(node.Destination)))
+		case *ast.Image:
+			node.Destination = []byte(rewrite(string(node.Destination)))
+		}
+		return ast.WalkContinue, nil
+	})
+	toc := r.navigate(doc, source)
+
+	var buf bytes.Buffer
+	buf.WriteString(toc)
+	if err := r.md.Renderer().Render(&buf, source, doc); err != nil {
+		return "", fmt.Errorf("failed to render markdown: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// Document wraps an HTML fragment in a standalone page, pulling in
+// KaTeX when math rendering is enabled
+func (r *Renderer) Document(title, body string) string {
+	return r.document(title, body, "")
+}
+
+// document builds a standalone page with extra markup appended to <head>
+func (r *Renderer) document(title, body, head string) string {
+	var buf bytes.Buffer
+
+	buf.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	fmt.Fprintf(&buf, "<title>%s</title>\n", html.EscapeString(title))
+	if r.opts.Math {
+		fmt.Fprintf(&buf, "<link rel=\"stylesheet\" href=\"%s/katex.min.css\">\n", katexBaseURL)
+		fmt.Fprintf(&buf, "<script defer src=\"%s/katex.min.js\"></script>\n", katexBaseURL)
+		fmt.Fprintf(&buf, "<script defer src=\"%s/contrib/auto-render.min.js\" onload=\"renderMathInElement(document.body)\"></script>\n", katexBaseURL)
+	}
+	buf.WriteString(head)
+	buf.WriteString("</head>\n<body>\n")
+	buf.WriteString(body)
+	buf.WriteString("</body>\n</html>\n")
+
+	return buf.String()
+}
+
+// codeBlockRenderer renders fenced code blocks, replacing diagram
+// languages with their SVG when a diagram backend is configured
+type codeBlockRenderer struct {
+	r *Renderer
+}
+
+func (c *codeBlockRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindFencedCodeBlock, c.renderFencedCodeBlock)
+}
+
+func (c *codeBlockRenderer) renderFencedCodeBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+
+	n := node.(*ast.FencedCodeBlock)
+	language := n.Language(source)
+
+	var code bytes.Buffer
+	lines := n.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		seg := lines.At(i)
+		code.Write(seg.Value(source))
+	}
+
+	if c.r.plantuml != nil && string(language) == "plantuml" {
+		svg, err := c.r.plantuml.RenderSVG(context.Background(), code.String())
+		if err == nil {
+			// As an image, so scripts in the SVG never run in the page
+			w.WriteString(`<div class="diagram plantuml"><img alt="PlantUML diagram" src="data:image/svg+xml;base64,`)
+			w.WriteString(base64.StdEncoding.EncodeToString(svg))
+			w.WriteString(`"></div>` + "\n")
+			return ast.WalkSkipChildren, nil
+		}
+		// Fall back to showing the source
+	}
+
+	w.WriteString("<pre><code")
+	if language != nil {
+		w.WriteString(` class="language-`)
+		w.Write(util.EscapeHTML(language))
+		w.WriteString(`"`)
+	}
+	w.WriteString(">")
+	w.Write(util.EscapeHTML(code.Bytes()))
+	w.WriteString("</code></pre>\n")
+	return ast.WalkSkipChildren, nil
+}
@@ -0,0 +1,195 @@
+package render
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderMath(t *testing.T) {
+	r := New(Options{Math: true})
+
+	tests := []struct {
+		name     string
+		source   string
+		contains string
+	}{
+		{"Inline", "Euler: $e^{i\\pi} + 1 = 0$", `<span class="math inline">\(e^{i\pi} + 1 = 0\)</span>`},
+		{"InlineEscapesHTML", "$a < b$", `\(a &lt; b\)`},
+		{"InlineDisplay", "see $$x^2$$ here", `<span class="math inline">\(x^2\)</span>`},
+		{"Block", "$$\n\\int_0^1 x\\,dx\n$$\n", "<div class=\"math display\">\\[\\int_0^1 x\\,dx\n\\]</div>"},
+		{"SingleLineBlock", "$$ a + b $$\n", `<div class="math display">\[ a + b \]</div>`},
+		{"Currency", "costs $5 and $10", "costs $5 and $10"},
+		{"EscapedDollar", "\\$x$", "$x$"},
+		{"CodeSpan", "`$x$`", "<code>$x$</code>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := r.Render([]byte(tt.source))
+			if err != nil {
+				t.Fatalf("Render failed: %v", err)
+			}
+			if !strings.Contains(got, tt.contains) {
+				t.Errorf("Render(%q) = %q, want it to contain %q", tt.source, got, tt.contains)
+			}
+		})
+	}
+}
+
+func TestRenderMathDisabled(t *testing.T) {
+	r := New(Options{Math: false})
+
+	got, err := r.Render([]byte("$x^2$"))
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if strings.Contains(got, "math") {
+		t.Errorf("Math markup rendered while disabled: %q", got)
+	}
+}
+
+func TestFlavors(t *testing.T) {
+	source := "| a |\n|---|\n| 1 |\n\n- [x] done\n\n~~old~~ \"quoted\" note[^1]\nnext line\n\n[^1]: Footnote.\n"
+
+	tests := []struct {
+		name    string
+		opts    Options
+		want    []string
+		notWant []string
+	}{
+		{"Default", Options{}, []string{"<table>", `type="checkbox"`, "<del>old</del>", `class="footnote-ref"`}, []string{"<br>", "&ldquo;"}},
+		{"Obsidian", Options{Flavor: FlavorObsidian}, []string{"<table>", "<br>"}, []string{"&ldquo;"}},
+		{"CommonMark", Options{Flavor: FlavorCommonMark}, nil, []string{"<table>", "<del>", "footnote", "<br>"}},
+		{"Custom", Options{Flavor: FlavorCustom, Extensions: Extensions{Typographer: true, HardWraps: true}}, []string{"&ldquo;quoted&rdquo;", "<br>"}, []string{"<table>", "<del>"}},
+		{"Unknown", Options{Flavor: "wiki"}, []string{"<table>"}, []string{"<br>"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := New(tt.opts)
+			got, err := r.Render([]byte(source))
+			if err != nil {
+				t.Fatalf("Render failed: %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("Expected %q in %q", want, got)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(got, notWant) {
+					t.Errorf("Didn't expect %q in %q", notWant, got)
+				}
+			}
+		})
+	}
+
+	if got := New(Options{}).Options().Flavor; got != FlavorGitHub {
+		t.Errorf("Expected the default flavor reported as github, got %q", got)
+	}
+}
+
+func TestDocument(t *testing.T) {
+	withMath := New(Options{Math: true}).Document("Notes <1>", "<p>hi</p>")
+	if !strings.Contains(withMath, "<title>Notes &lt;1&gt;</title>") {
+		t.Error("Title was not escaped")
+	}
+	if !strings.Contains(withMath, "katex.min.js") {
+		t.Error("KaTeX should be included when math is enabled")
+	}
+
+	withoutMath := New(Options{}).Document("Notes", "<p>hi</p>")
+	if strings.Contains(withoutMath, "katex") {
+		t.Error("KaTeX should not be included when math is disabled")
+	}
+}
+
+func TestRenderPrint(t *testing.T) {
+	r := New(Options{})
+
+	got, err := r.RenderPrint([]byte("See [Go](https://go.dev) and [notes](other.md).\n"))
+	if err != nil {
+		t.Fatalf("RenderPrint failed: %v", err)
+	}
+
+	if !strings.Contains(got, `<a href="https://go.dev">Go</a><span class="link-ref">[1]</span>`) {
+		t.Errorf("External link should be followed by a note marker: %s", got)
+	}
+	if strings.Contains(got, "[2]") {
+		t.Errorf("Relative links should not get notes: %s", got)
+	}
+	if !strings.Contains(got, "<li>https://go.dev</li>") {
+		t.Errorf("Link notes section missing: %s", got)
+	}
+
+	doc := r.PrintDocument("Notes", got, "/raw/dir/")
+	if !strings.Contains(doc, `<base href="/raw/dir/">`) || !strings.Contains(doc, "break-before: page") {
+		t.Errorf("Print document missing base or stylesheet: %s", doc)
+	}
+}
+
+func TestOutline(t *testing.T) {
+	source := []byte("# Café *menu*\n\nText\n\n## 1. Starters\n\n## Starters\n\n### `code` & more\n")
+
+	tests := []struct {
+		slug string
+		ids  []string
+	}{
+		{"", []string{"caf-menu", "1-starters", "starters", "code--more"}},
+		{SlugGitHub, []string{"café-menu", "1-starters", "starters", "code--more"}},
+		{SlugPandoc, []string{"café-menu", "starters", "starters-1", "code-more"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.slug, func(t *testing.T) {
+			headings := New(Options{}).WithNavigation(Navigation{Slug: tt.slug}).Outline(source)
+			if len(headings) != len(tt.ids) {
+				t.Fatalf("Outline returned %d headings, want %d", len(headings), len(tt.ids))
+			}
+			for i, h := range headings {
+				if h.ID != tt.ids[i] {
+					t.Errorf("heading %d ID = %q, want %q", i, h.ID, tt.ids[i])
+				}
+			}
+			if headings[0].Text != "Café menu" || headings[3].Level != 3 {
+				t.Errorf("unexpected first or last heading: %+v, %+v", headings[0], headings[3])
+			}
+		})
+	}
+}
+
+func TestNavigation(t *testing.T) {
+	source := []byte("# Title\n\n## One\n\n#### Deep\n\n## Two\n")
+	r := New(Options{}).WithNavigation(Navigation{TOC: true, Anchors: true, Slug: SlugGitHub})
+
+	got, err := r.Render(source)
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+
+	toc := "<nav class=\"toc\">\n<ul>\n<li><a href=\"#title\">Title</a>\n<ul>\n" +
+		"<li><a href=\"#one\">One</a>\n<ul>\n<li><a href=\"#deep\">Deep</a></li>\n</ul>\n</li>\n" +
+		"<li><a href=\"#two\">Two</a></li>\n</ul>\n</li>\n</ul>\n</nav>\n"
+	if !strings.HasPrefix(got, toc) {
+		t.Errorf("Render() = %q, want it to start with %q", got, toc)
+	}
+	if !strings.Contains(got, `<h2 id="one">One<a class="anchor" href="#one" aria-hidden="true">#</a></h2>`) {
+		t.Errorf("Render() = %q, want an anchor on each heading", got)
+	}
+
+	print, err := r.RenderPrint(source)
+	if err != nil {
+		t.Fatalf("RenderPrint failed: %v", err)
+	}
+	if !strings.HasPrefix(print, "<nav class=\"toc\">") {
+		t.Errorf("RenderPrint() = %q, want a table of contents", print)
+	}
+
+	plain, err := New(Options{}).Render(source)
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if strings.Contains(plain, "toc") || strings.Contains(plain, "anchor") {
+		t.Errorf("Render() without navigation = %q", plain)
+	}
+}
@@ -0,0 +1,254 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+)
+
+// Heading slug algorithms. Each gives the IDs another tool gives headings,
+// so links written for it keep working.
+const (
+	SlugGoldmark = "goldmark" // ASCII letters and digits, as the preview does
+	SlugGitHub   = "github"   // Keeps letters of any script, as GitHub and GitLab do
+	SlugPandoc   = "pandoc"   // Drops everything before the first letter, as pandoc does
+)
+
+// Slugs lists the slug algorithms that can be chosen
+var Slugs = []string{SlugGoldmark, SlugGitHub, SlugPandoc}
+
+// Heading is an entry in a note's outline
+type Heading struct {
+	Level int    `json:"level"`
+	Text  string `json:"text"`
+	ID    string `json:"id"` // Anchor the heading renders with
+}
+
+// Navigation adds ways around a long document to what is rendered
+type Navigation struct {
+	TOC     bool   `json:"toc"`            // Put a table of contents before the body
+	Anchors bool   `json:"anchors"`        // Give each heading a link to itself
+	Slug    string `json:"slug,omitempty"` // One of Slugs; empty for goldmark
+}
+
+// ValidSlug reports whether slug names a slug algorithm
+func ValidSlug(slug string) bool {
+	if slug == "" {
+		return true
+	}
+	for _, s := range Slugs {
+		if s == slug {
+			return true
+		}
+	}
+	return false
+}
+
+// WithNavigation returns a renderer that adds nav to everything it renders
+func (r *Renderer) WithNavigation(nav Navigation) *Renderer {
+	renderer := *r
+	renderer.nav = nav
+	return &renderer
+}
+
+// Outline returns the headings of a note in order, with the IDs they are
+// rendered with
+func (r *Renderer) Outline(source []byte) []Heading {
+	return outline(r.parse(source), source)
+}
+
+// parse parses a note, generating heading IDs with the slug algorithm
+func (r *Renderer) parse(source []byte) ast.Node {
+	var opts []parser.ParseOption
+	if r.nav.Slug != "" && r.nav.Slug != SlugGoldmark {
+		ids := &slugIDs{slug: r.nav.Slug, used: map[string]bool{}}
+		opts = append(opts, parser.WithContext(parser.NewContext(parser.WithIDs(ids))))
+	}
+	return r.md.Parser().Parse(text.NewReader(source), opts...)
+}
+
+// navigate adds heading anchors to a parsed note and returns the table of
+// contents to put before it, as the renderer's navigation asks
+func (r *Renderer) navigate(doc ast.Node, source []byte) string {
+	if !r.nav.TOC && !r.nav.Anchors {
+		return ""
+	}
+	headings := outline(doc, source)
+
+	if r.nav.Anchors {
+		var nodes []*ast.Heading
+		ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+			if h, ok := n.(*ast.Heading); ok && entering {
+				nodes = append(nodes, h)
+				return ast.WalkSkipChildren, nil
+			}
+			return ast.WalkContinue, nil
+		})
+		// Insert after walking so the tree isn't modified mid-walk
+		for _, h := range nodes {
+			id, ok := h.AttributeString("id")
+			if !ok {
+				continue
+			}
+			anchor := ast.NewString([]byte(fmt.Sprintf(`<a class="anchor" href="#%s" aria-hidden="true">#</a>`, html.EscapeString(string(id.([]byte))))))
+			anchor.SetCode(true)
+			h.AppendChild(h, anchor)
+		}
+	}
+
+	if !r.nav.TOC || len(headings) == 0 {
+		return ""
+	}
+	return tableOfContents(headings)
+}
+
+// outline collects the headings of a parsed note
+func outline(doc ast.Node, source []byte) []Heading {
+	headings := []Heading{}
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		h, ok := n.(*ast.Heading)
+		if !ok || !entering {
+			return ast.WalkContinue, nil
+		}
+		heading := Heading{Level: h.Level, Text: plainText(h, source)}
+		if id, ok := h.AttributeString("id"); ok {
+			if b, ok := id.([]byte); ok {
+				heading.ID = string(b)
+			}
+		}
+		headings = append(headings, heading)
+		return ast.WalkSkipChildren, nil
+	})
+	return headings
+}
+
+// plainText returns the text of a node without its markup
+func plainText(n ast.Node, source []byte) string {
+	var buf bytes.Buffer
+	ast.Walk(n, func(c ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch t := c.(type) {
+		case *ast.Text:
+			buf.Write(t.Segment.Value(source))
+			if t.SoftLineBreak() || t.HardLineBreak() {
+				buf.WriteByte(' ')
+			}
+		case *ast.String:
+			if !t.IsCode() {
+				buf.Write(t.Value)
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	return strings.TrimSpace(buf.String())
+}
+
+// tableOfContents renders headings as nested lists. A heading more than
+// one level below the one before it is nested only one level deeper.
+func tableOfContents(headings []Heading) string {
+	var buf bytes.Buffer
+	buf.WriteString("<nav class=\"toc\">\n<ul>\n")
+
+	// levels holds the heading level of each open list
+	levels := []int{headings[0].Level}
+	for i, h := range headings {
+		if i > 0 {
+			switch {
+			case h.Level > levels[len(levels)-1]:
+				buf.WriteString("\n<ul>\n")
+				levels = append(levels, h.Level)
+			default:
+				buf.WriteString("</li>\n")
+				for len(levels) > 1 && h.Level < levels[len(levels)-1] {
+					buf.WriteString("</ul>\n</li>\n")
+					levels = levels[:len(levels)-1]
+				}
+			}
+		}
+		fmt.Fprintf(&buf, "<li><a href=\"#%s\">%s</a>", html.EscapeString(h.ID), html.EscapeString(h.Text))
+	}
+	buf.WriteString("</li>\n")
+	for len(levels) > 1 {
+		buf.WriteString("</ul>\n</li>\n")
+		levels = levels[:len(levels)-1]
+	}
+
+	buf.WriteString("</ul>\n</nav>\n")
+	return buf.String()
+}
+
+// slugIDs generates heading IDs with a slug algorithm, numbering repeats
+// the way GitHub does: intro, intro-1, intro-2
+type slugIDs struct {
+	slug string
+	used map[string]bool
+}
+
+func (s *slugIDs) Generate(value []byte, kind ast.NodeKind) []byte {
+	var id string
+	switch s.slug {
+	case SlugPandoc:
+		id = pandocSlug(string(value))
+	default:
+		id = githubSlug(string(value))
+	}
+	if id == "" {
+		id = "section"
+	}
+
+	unique := id
+	for n := 1; s.used[unique]; n++ {
+		unique = id + "-" + strconv.Itoa(n)
+	}
+	s.used[unique] = true
+	return []byte(unique)
+}
+
+func (s *slugIDs) Put(value []byte) {
+	s.used[string(value)] = true
+}
+
+// githubSlug lowercases text, turns spaces into hyphens and drops
+// punctuation other than hyphens and underscores
+func githubSlug(value string) string {
+	var b strings.Builder
+	for _, c := range strings.TrimSpace(value) {
+		switch {
+		case unicode.IsLetter(c) || unicode.IsDigit(c) || unicode.IsMark(c) || c == '_' || c == '-':
+			b.WriteRune(unicode.ToLower(c))
+		case c == ' ':
+			b.WriteByte('-')
+		}
+	}
+	return b.String()
+}
+
+// pandocSlug is githubSlug that also keeps periods, drops everything
+// before the first letter and joins runs of whitespace with one hyphen
+func pandocSlug(value string) string {
+	value = strings.TrimLeftFunc(value, func(c rune) bool { return !unicode.IsLetter(c) })
+	var b strings.Builder
+	space := false
+	for _, c := range value {
+		switch {
+		case unicode.IsLetter(c) || unicode.IsDigit(c) || c == '_' || c == '-' || c == '.':
+			if space && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			space = false
+			b.WriteRune(unicode.ToLower(c))
+		case unicode.IsSpace(c):
+			space = true
+		}
+	}
+	return b.String()
+}
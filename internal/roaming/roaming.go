@@ -0,0 +1,240 @@
+// Package roaming shares recents, favorites and settings between Inkwell
+// installs through a profile file in a synced folder or git repository
+package roaming
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"inkwell/internal/atomicfile"
+	"inkwell/internal/git"
+	"inkwell/internal/recents"
+	"inkwell/internal/settings"
+)
+
+// File is the name of the profile inside a sync directory
+const File = "inkwell-profile.json"
+
+// Profile is what one machine shares with the others. Paths under the home
+// directory are stored relative to it as ~/..., so the same vault matches
+// on machines with different home directories.
+type Profile struct {
+	Settings        *settings.Settings        `json:"settings,omitempty"`
+	SettingsUpdated time.Time                 `json:"settingsUpdated,omitempty"`
+	Recents         []recents.Location        `json:"recents,omitempty"`
+	Favorites       map[string][]recents.File `json:"favorites,omitempty"`
+}
+
+// Syncer merges the local recents and settings with a shared profile
+type Syncer struct {
+	Path     string // Profile file, or a directory holding one
+	Home     string // Home directory paths are made relative to
+	Recents  *recents.Manager
+	Settings *settings.Manager
+	UseGit   bool // Pull, commit and push when the profile is in a git repository
+}
+
+// New creates a syncer for the profile at path
+func New(path string, r *recents.Manager, s *settings.Manager) (*Syncer, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	return &Syncer{Path: path, Home: home, Recents: r, Settings: s, UseGit: true}, nil
+}
+
+// file returns the profile file's path
+func (s *Syncer) file() (string, error) {
+	path, err := filepath.Abs(expandHome(s.Path, s.Home))
+	if err != nil {
+		return "", err
+	}
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return filepath.Join(path, File), nil
+	}
+	return path, nil
+}
+
+// Sync pulls the profile if it lives in a git repository with a remote,
+// merges it into the local recents and settings, then writes back the
+// merged profile and commits and pushes it if it changed
+func (s *Syncer) Sync() error {
+	file, err := s.file()
+	if err != nil {
+		return err
+	}
+
+	var repo *git.Repository
+	if s.UseGit {
+		if repo, err = git.Open(filepath.Dir(file)); err != nil {
+			return err
+		}
+	}
+	if repo != nil && repo.GetRemoteURL() != "" {
+		if _, err := repo.Pull(nil); err != nil {
+			slog.Warn("Failed to pull synced profile", "path", repo.Path(), "error", err)
+		}
+	}
+
+	shared, err := Load(file)
+	if err != nil {
+		return err
+	}
+	if err := s.apply(shared); err != nil {
+		return err
+	}
+
+	merged := s.local(shared)
+	changed, err := save(file, merged)
+	if err != nil || !changed || repo == nil {
+		return err
+	}
+
+	rel, err := filepath.Rel(repo.Path(), file)
+	if err != nil {
+		return err
+	}
+	if _, err := repo.Commit(git.CommitOptions{
+		Message: "Update Inkwell profile",
+		Files:   []string{filepath.ToSlash(rel)},
+	}); err != nil {
+		return fmt.Errorf("failed to commit synced profile: %w", err)
+	}
+	if repo.GetRemoteURL() != "" {
+		if _, err := repo.Push(nil); err != nil {
+			slog.Warn("Failed to push synced profile", "path", repo.Path(), "error", err)
+		}
+	}
+	return nil
+}
+
+// apply merges a shared profile into the local recents and settings
+func (s *Syncer) apply(p *Profile) error {
+	if s.Recents != nil {
+		locations := make([]recents.Location, len(p.Recents))
+		for i, loc := range p.Recents {
+			loc.Path = expandHome(loc.Path, s.Home)
+			locations[i] = loc
+		}
+		if err := s.Recents.Merge(locations); err != nil {
+			return fmt.Errorf("failed to merge recents: %w", err)
+		}
+
+		favorites := make(map[string][]recents.File, len(p.Favorites))
+		for root, files := range p.Favorites {
+			favorites[expandHome(root, s.Home)] = files
+		}
+		if err := s.Recents.MergeFavorites(favorites); err != nil {
+			return fmt.Errorf("failed to merge favorites: %w", err)
+		}
+	}
+
+	// The most recently saved settings win
+	if s.Settings != nil && p.Settings != nil && *p.Settings != s.Settings.Get() &&
+		p.SettingsUpdated.After(s.Settings.Modified()) {
+		if err := s.Settings.Replace(*p.Settings); err != nil {
+			return fmt.Errorf("failed to apply synced settings: %w", err)
+		}
+	}
+	return nil
+}
+
+// local builds the profile to share from the local state. Settings equal
+// to the shared copy keep its timestamp.
+func (s *Syncer) local(shared *Profile) *Profile {
+	p := &Profile{
+		Settings:        shared.Settings,
+		SettingsUpdated: shared.SettingsUpdated,
+	}
+
+	if s.Recents != nil {
+		for _, loc := range s.Recents.GetAll() {
+			loc.Path = collapseHome(loc.Path, s.Home)
+			p.Recents = append(p.Recents, loc)
+		}
+		for root, files := range s.Recents.AllFavorites() {
+			if p.Favorites == nil {
+				p.Favorites = make(map[string][]recents.File)
+			}
+			p.Favorites[collapseHome(root, s.Home)] = files
+		}
+	}
+
+	if s.Settings != nil {
+		current := s.Settings.Get()
+		if p.Settings == nil || current != *p.Settings {
+			p.Settings = &current
+			p.SettingsUpdated = s.Settings.Modified().UTC()
+		}
+	}
+	return p
+}
+
+// Load reads a profile. A missing file is an empty profile.
+func Load(file string) (*Profile, error) {
+	p := &Profile{}
+
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return p, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+	return p, nil
+}
+
+// save writes a profile, reporting whether the file changed
+func save(file string, p *Profile) (bool, error) {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return false, err
+	}
+	data = append(data, '\n')
+
+	if existing, err := os.ReadFile(file); err == nil && string(existing) == string(data) {
+		return false, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return false, err
+	}
+	return true, atomicfile.WriteFile(file, data, 0600)
+}
+
+// collapseHome rewrites a path under home as ~/...
+func collapseHome(path, home string) string {
+	if home == "" {
+		return filepath.ToSlash(path)
+	}
+	rel, err := filepath.Rel(home, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return filepath.ToSlash(path)
+	}
+	if rel == "." {
+		return "~"
+	}
+	return "~/" + filepath.ToSlash(rel)
+}
+
+// expandHome turns a ~/... path back into one under home
+func expandHome(path, home string) string {
+	switch {
+	case path == "~":
+		return home
+	case strings.HasPrefix(path, "~/"):
+		return filepath.Join(home, filepath.FromSlash(path[2:]))
+	default:
+		return filepath.FromSlash(path)
+	}
+}
@@ -0,0 +1,135 @@
+package roaming
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"inkwell/internal/git"
+	"inkwell/internal/recents"
+	"inkwell/internal/settings"
+)
+
+// machine is one Inkwell install with its own recents and settings
+type machine struct {
+	recents  *recents.Manager
+	settings *settings.Manager
+	syncer   *Syncer
+}
+
+func newMachine(t *testing.T, home, profile string) *machine {
+	t.Helper()
+
+	store, err := os.MkdirTemp("", "roaming-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(store) })
+
+	r, err := recents.NewAt(store)
+	if err != nil {
+		t.Fatalf("recents.NewAt failed: %v", err)
+	}
+	s, err := settings.NewAt(store)
+	if err != nil {
+		t.Fatalf("settings.NewAt failed: %v", err)
+	}
+	return &machine{
+		recents:  r,
+		settings: s,
+		syncer:   &Syncer{Path: profile, Home: home, Recents: r, Settings: s, UseGit: true},
+	}
+}
+
+func TestSyncBetweenMachines(t *testing.T) {
+	profile := t.TempDir()
+	homeA, homeB := t.TempDir(), t.TempDir()
+	for _, home := range []string{homeA, homeB} {
+		if err := os.MkdirAll(filepath.Join(home, "notes"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	a := newMachine(t, homeA, profile)
+	b := newMachine(t, homeB, profile)
+
+	a.recents.Add(filepath.Join(homeA, "notes"))
+	a.recents.SetFavorite(filepath.Join(homeA, "notes"), "todo.md", true)
+	if _, err := a.settings.Update([]byte(`{"fontSize": 20}`)); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if err := a.syncer.Sync(); err != nil {
+		t.Fatalf("Sync A failed: %v", err)
+	}
+
+	shared, err := Load(filepath.Join(profile, File))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(shared.Recents) != 1 || shared.Recents[0].Path != "~/notes" {
+		t.Errorf("Expected ~/notes in the shared profile, got %v", shared.Recents)
+	}
+
+	if err := b.syncer.Sync(); err != nil {
+		t.Fatalf("Sync B failed: %v", err)
+	}
+
+	got := b.recents.GetAll()
+	if len(got) != 1 || got[0].Path != filepath.Join(homeB, "notes") {
+		t.Errorf("Expected B to get notes under its own home, got %v", got)
+	}
+	if favs := b.recents.Favorites(filepath.Join(homeB, "notes")); len(favs) != 1 {
+		t.Errorf("Expected B to get the favorite, got %v", favs)
+	}
+	if size := b.settings.Get().FontSize; size != 20 {
+		t.Errorf("Expected B to get fontSize 20, got %d", size)
+	}
+
+	// Settings changed later on B win on A
+	time.Sleep(10 * time.Millisecond)
+	if _, err := b.settings.Update([]byte(`{"fontSize": 14}`)); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if err := b.syncer.Sync(); err != nil {
+		t.Fatalf("Sync B failed: %v", err)
+	}
+	if err := a.syncer.Sync(); err != nil {
+		t.Fatalf("Sync A failed: %v", err)
+	}
+	if size := a.settings.Get().FontSize; size != 14 {
+		t.Errorf("Expected A to get fontSize 14, got %d", size)
+	}
+}
+
+func TestSyncCommitsInRepository(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := git.Init(dir)
+	if err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	m := newMachine(t, t.TempDir(), dir)
+	if _, err := m.settings.Update([]byte(`{"fontSize": 18}`)); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if err := m.syncer.Sync(); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	history, err := repo.GetHistory(10, 0, "")
+	if err != nil {
+		t.Fatalf("GetHistory failed: %v", err)
+	}
+	if len(history) != 1 {
+		t.Fatalf("Expected one commit, got %d", len(history))
+	}
+
+	// Nothing changed, so nothing is committed
+	if err := m.syncer.Sync(); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if history, _ := repo.GetHistory(10, 0, ""); len(history) != 1 {
+		t.Errorf("Expected no new commit, got %d commits", len(history))
+	}
+}
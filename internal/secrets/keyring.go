@@ -0,0 +1,75 @@
+package secrets
+
+import (
+	"encoding/base64"
+	"errors"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+const (
+	keychainService = "inkwell"
+	keychainAccount = "secrets"
+)
+
+// keyring keeps the store key outside the secrets file, or protects it so
+// only the current user can read it
+type keyring interface {
+	method() Method
+	// save stores a key, returning what to keep in the secrets file
+	save(raw []byte) ([]byte, error)
+	// load returns the key, given what save returned
+	load(protected []byte) ([]byte, error)
+}
+
+// keychain keeps the key in the login keychain on macOS, or the Secret
+// Service through secret-tool elsewhere, as the "secrets" account of the
+// "inkwell" service
+type keychain struct{}
+
+// newKeychain returns the keychain if its tool is installed
+func newKeychain() keyring {
+	tool := "secret-tool"
+	if runtime.GOOS == "darwin" {
+		tool = "security"
+	}
+	if _, err := exec.LookPath(tool); err != nil {
+		return nil
+	}
+	return keychain{}
+}
+
+func (keychain) method() Method { return MethodKeychain }
+
+func (keychain) save(raw []byte) ([]byte, error) {
+	encoded := base64.StdEncoding.EncodeToString(raw)
+	var cmd *exec.Cmd
+	if runtime.GOOS == "darwin" {
+		cmd = exec.Command("security", "add-generic-password", "-U", "-s", keychainService, "-a", keychainAccount, "-l", "Inkwell credential store", "-w", encoded)
+	} else {
+		cmd = exec.Command("secret-tool", "store", "--label=Inkwell credential store", "service", keychainService, "account", keychainAccount)
+		cmd.Stdin = strings.NewReader(encoded)
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return nil, errors.New(msg)
+		}
+		return nil, err
+	}
+	return nil, nil
+}
+
+func (keychain) load([]byte) ([]byte, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "darwin" {
+		cmd = exec.Command("security", "find-generic-password", "-s", keychainService, "-a", keychainAccount, "-w")
+	} else {
+		cmd = exec.Command("secret-tool", "lookup", "service", keychainService, "account", keychainAccount)
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(strings.TrimSpace(string(out)))
+}
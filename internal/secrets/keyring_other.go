@@ -0,0 +1,8 @@
+//go:build !windows
+
+package secrets
+
+// systemKeyring returns the OS keychain, or nil if there is none
+func systemKeyring() keyring {
+	return newKeychain()
+}
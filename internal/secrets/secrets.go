@@ -0,0 +1,637 @@
+// Package secrets keeps the credentials git operations need, such as
+// HTTPS tokens and SSH key passphrases, so they don't have to be entered
+// for every push. Each secret is sealed with AES-256-GCM under a store key
+// that is kept in the OS keychain (macOS Keychain or the Secret Service),
+// protected with DPAPI on Windows, or derived from a passphrase.
+package secrets
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"inkwell/internal/encryption"
+
+	"github.com/google/uuid"
+)
+
+const (
+	inkwellDir  = ".inkwell"
+	secretsFile = "secrets.json"
+	version     = 1
+	keyLen      = 32
+	saltLen     = 16
+	checkText   = "inkwell"
+)
+
+// Method is how the store key is protected
+type Method string
+
+const (
+	MethodKeychain   Method = "keychain"   // Kept in the macOS Keychain or the Secret Service
+	MethodDPAPI      Method = "dpapi"      // Kept in the secrets file, protected with Windows DPAPI
+	MethodPassphrase Method = "passphrase" // Derived from a passphrase entered after each start
+)
+
+// Kind is what a credential is used for
+type Kind string
+
+const (
+	KindToken         Kind = "token"          // Password or token for HTTPS remotes
+	KindSSHPassphrase Kind = "ssh-passphrase" // Passphrase of an SSH private key
+)
+
+var (
+	// ErrNotConfigured is returned before the store has a key
+	ErrNotConfigured = errors.New("the credential store is not set up")
+	// ErrConfigured is returned when setting up a store that has a key
+	ErrConfigured = errors.New("the credential store is already set up")
+	// ErrLocked is returned while a passphrase-protected store is locked
+	ErrLocked = errors.New("the credential store is locked")
+	// ErrWrongPassphrase is returned when a passphrase doesn't unlock the store
+	ErrWrongPassphrase = errors.New("wrong passphrase")
+	// ErrPassphraseRequired is returned when setting up a store without a
+	// passphrase on a system without a keychain
+	ErrPassphraseRequired = errors.New("no OS keychain is available; set a passphrase for the credential store")
+	// ErrNotFound is returned for an unknown credential ID
+	ErrNotFound = errors.New("credential not found")
+	// ErrInvalid is returned for a credential that can't be stored
+	ErrInvalid = errors.New("invalid credential")
+)
+
+// Credential is a stored secret and what it is for. Secret is only filled
+// in when it is needed for authenticating; it is never listed.
+type Credential struct {
+	ID   string `json:"id"`
+	Kind Kind   `json:"kind"`
+
+	// Target is what the credential applies to. For tokens, a host such as
+	// github.com or a URL prefix such as https://github.com/team/; for SSH
+	// passphrases, the path of the key, or empty for the default key.
+	Target   string `json:"target"`
+	Username string `json:"username,omitempty"` // For tokens
+	Label    string `json:"label,omitempty"`
+
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	Secret string `json:"-"`
+}
+
+// Status describes the store
+type Status struct {
+	Configured bool   `json:"configured"`
+	Method     Method `json:"method,omitempty"`
+	Unlocked   bool   `json:"unlocked"`
+	Keychain   bool   `json:"keychain"` // Whether the store can be set up without a passphrase
+}
+
+// record is a Credential as stored
+type record struct {
+	Credential
+	Sealed []byte `json:"secret"`
+}
+
+// file is the stored form of the secrets file
+type file struct {
+	Version      int      `json:"version"`
+	Method       Method   `json:"method"`
+	Salt         []byte   `json:"salt,omitempty"`         // For MethodPassphrase
+	ProtectedKey []byte   `json:"protectedKey,omitempty"` // For MethodDPAPI
+	Check        []byte   `json:"check"`                  // checkText sealed with the key
+	Credentials  []record `json:"credentials"`
+}
+
+// Store holds credentials in ~/.inkwell/secrets.json. It is safe for
+// concurrent use.
+type Store struct {
+	mu      sync.Mutex
+	path    string
+	keyring keyring // nil when the OS has none
+	key     *encryption.Key
+}
+
+var (
+	defaultOnce  sync.Once
+	defaultStore *Store
+	defaultErr   error
+)
+
+// Default returns the store in ~/.inkwell, shared by the whole process so
+// that unlocking it once serves every git operation. A store protected by
+// the keychain or DPAPI is unlocked when it is opened.
+func Default() (*Store, error) {
+	defaultOnce.Do(func() {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			defaultErr = err
+			return
+		}
+		defaultStore = Open(filepath.Join(home, inkwellDir, secretsFile))
+	})
+	return defaultStore, defaultErr
+}
+
+// Open returns the store kept at path, unlocking it from the OS keychain
+// if that is where its key is
+func Open(path string) *Store {
+	s := &Store{path: path, keyring: systemKeyring()}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if f, err := s.load(); err == nil && f.Method != MethodPassphrase {
+		s.unlockFromKeyring(f)
+	}
+	return s
+}
+
+// Status reports whether the store is set up and unlocked
+func (s *Store) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := Status{Unlocked: s.key != nil, Keychain: s.keyring != nil}
+	if f, err := s.load(); err == nil {
+		status.Configured = true
+		status.Method = f.Method
+	}
+	return status
+}
+
+// Setup creates the store key. With an empty passphrase the key is kept in
+// the OS keychain; otherwise it is derived from the passphrase.
+func (s *Store) Setup(passphrase string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.setup(passphrase)
+}
+
+func (s *Store) setup(passphrase string) error {
+	if _, err := s.load(); err == nil {
+		return ErrConfigured
+	} else if !errors.Is(err, ErrNotConfigured) {
+		return err
+	}
+
+	f := &file{Version: version, Credentials: []record{}}
+	var key *encryption.Key
+	if passphrase == "" {
+		if s.keyring == nil {
+			return ErrPassphraseRequired
+		}
+		raw := make([]byte, keyLen)
+		if _, err := rand.Read(raw); err != nil {
+			return err
+		}
+		protected, err := s.keyring.save(raw)
+		if err != nil {
+			return fmt.Errorf("failed to store the key in the keychain: %w", err)
+		}
+		f.Method, f.ProtectedKey = s.keyring.method(), protected
+		if key, err = encryption.NewKey(raw); err != nil {
+			return err
+		}
+	} else {
+		if len(passphrase) < encryption.MinPassphraseLen {
+			return fmt.Errorf("passphrase must be at least %d characters", encryption.MinPassphraseLen)
+		}
+		f.Method, f.Salt = MethodPassphrase, make([]byte, saltLen)
+		if _, err := rand.Read(f.Salt); err != nil {
+			return err
+		}
+		var err error
+		if key, err = encryption.DeriveKey(passphrase, f.Salt); err != nil {
+			return err
+		}
+	}
+
+	var err error
+	if f.Check, err = key.Seal([]byte(checkText)); err != nil {
+		return err
+	}
+	if err := s.write(f); err != nil {
+		return err
+	}
+	s.key = key
+	return nil
+}
+
+// Unlock unlocks a passphrase-protected store
+func (s *Store) Unlock(passphrase string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := s.load()
+	if err != nil {
+		return err
+	}
+	if f.Method != MethodPassphrase {
+		if s.key == nil {
+			return s.unlockFromKeyring(f)
+		}
+		return nil
+	}
+	key, err := encryption.DeriveKey(passphrase, f.Salt)
+	if err != nil {
+		return err
+	}
+	if !checkKey(f, key) {
+		return ErrWrongPassphrase
+	}
+	s.key = key
+	return nil
+}
+
+// Lock forgets the key of a passphrase-protected store until it is
+// unlocked again. Stores kept in the keychain stay unlocked.
+func (s *Store) Lock() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if f, err := s.load(); err == nil && f.Method == MethodPassphrase {
+		s.key = nil
+	}
+}
+
+// List returns the stored credentials by target, without their secrets
+func (s *Store) List() ([]Credential, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := s.load()
+	if errors.Is(err, ErrNotConfigured) {
+		return []Credential{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	creds := make([]Credential, 0, len(f.Credentials))
+	for _, rec := range f.Credentials {
+		creds = append(creds, rec.Credential)
+	}
+	sort.Slice(creds, func(i, j int) bool {
+		if creds[i].Target != creds[j].Target {
+			return creds[i].Target < creds[j].Target
+		}
+		return creds[i].CreatedAt.Before(creds[j].CreatedAt)
+	})
+	return creds, nil
+}
+
+// Add stores a credential, replacing any of the same kind, target and
+// username. A store that isn't set up yet is set up with the OS keychain.
+func (s *Store) Add(c Credential) (*Credential, error) {
+	if err := validate(&c); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := s.load()
+	if errors.Is(err, ErrNotConfigured) {
+		if err := s.setup(""); err != nil {
+			return nil, err
+		}
+		f, err = s.load()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if s.key == nil {
+		return nil, ErrLocked
+	}
+
+	now := time.Now()
+	c.ID, c.CreatedAt, c.UpdatedAt = uuid.NewString(), now, now
+	for i, rec := range f.Credentials {
+		if rec.Kind == c.Kind && rec.Target == c.Target && rec.Username == c.Username {
+			c.ID, c.CreatedAt = rec.ID, rec.CreatedAt
+			f.Credentials = append(f.Credentials[:i], f.Credentials[i+1:]...)
+			break
+		}
+	}
+	rec := record{Credential: c}
+	if rec.Sealed, err = s.key.Seal([]byte(c.Secret)); err != nil {
+		return nil, err
+	}
+	f.Credentials = append(f.Credentials, rec)
+	if err := s.write(f); err != nil {
+		return nil, err
+	}
+	c.Secret = ""
+	return &c, nil
+}
+
+// Update changes a credential. An empty Secret keeps the stored one.
+func (s *Store) Update(id string, c Credential) (*Credential, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	i := f.find(id)
+	if i < 0 {
+		return nil, ErrNotFound
+	}
+	rec := &f.Credentials[i]
+
+	c.ID, c.CreatedAt, c.UpdatedAt = rec.ID, rec.CreatedAt, time.Now()
+	if c.Kind == "" {
+		c.Kind = rec.Kind
+	}
+	secret := c.Secret
+	if secret == "" {
+		c.Secret = "-" // Kept below; only passes validation
+	}
+	if err := validate(&c); err != nil {
+		return nil, err
+	}
+	sealed := rec.Sealed
+	if secret != "" {
+		if s.key == nil {
+			return nil, ErrLocked
+		}
+		if sealed, err = s.key.Seal([]byte(secret)); err != nil {
+			return nil, err
+		}
+	}
+	c.Secret = ""
+	*rec = record{Credential: c, Sealed: sealed}
+	if err := s.write(f); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Delete removes a credential
+func (s *Store) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := s.load()
+	if errors.Is(err, ErrNotConfigured) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	i := f.find(id)
+	if i < 0 {
+		return ErrNotFound
+	}
+	f.Credentials = append(f.Credentials[:i], f.Credentials[i+1:]...)
+	return s.write(f)
+}
+
+// Token returns the stored token for an HTTPS remote: the one whose target
+// matches most of the URL, preferring the given username when there is one
+func (s *Store) Token(remoteURL, username string) (*Credential, error) {
+	u, err := url.Parse(remoteURL)
+	if err != nil || u.Host == "" {
+		return nil, ErrNotFound
+	}
+	return s.lookup(KindToken, func(c *Credential) int {
+		if username != "" && c.Username != "" && c.Username != username {
+			return 0
+		}
+		return targetMatch(c.Target, u)
+	})
+}
+
+// SSHPassphrase returns the stored passphrase for an SSH key. Passphrases
+// stored without a path apply to the default key.
+func (s *Store) SSHPassphrase(keyPath string, isDefault bool) (string, error) {
+	keyPath = filepath.Clean(keyPath)
+	c, err := s.lookup(KindSSHPassphrase, func(c *Credential) int {
+		switch {
+		case c.Target != "" && filepath.Clean(c.Target) == keyPath:
+			return 2
+		case c.Target == "" && isDefault:
+			return 1
+		}
+		return 0
+	})
+	if err != nil {
+		return "", err
+	}
+	return c.Secret, nil
+}
+
+// HTTPSCredential returns the username and token stored for an HTTPS
+// remote in the default store, if it has them and is unlocked
+func HTTPSCredential(remoteURL, username string) (string, string, bool) {
+	store, err := Default()
+	if err != nil {
+		return "", "", false
+	}
+	c, err := store.Token(remoteURL, username)
+	if err != nil {
+		return "", "", false
+	}
+	if c.Username != "" {
+		username = c.Username
+	}
+	return username, c.Secret, true
+}
+
+// StoredSSHPassphrase returns the passphrase stored for an SSH key in the
+// default store, if it has one and is unlocked
+func StoredSSHPassphrase(keyPath string, isDefault bool) (string, bool) {
+	store, err := Default()
+	if err != nil {
+		return "", false
+	}
+	passphrase, err := store.SSHPassphrase(keyPath, isDefault)
+	return passphrase, err == nil
+}
+
+// lookup returns the credential of a kind that scores highest, with its
+// secret
+func (s *Store) lookup(kind Kind, score func(*Credential) int) (*Credential, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := s.load()
+	if errors.Is(err, ErrNotConfigured) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var best *record
+	bestScore := 0
+	for i := range f.Credentials {
+		rec := &f.Credentials[i]
+		if rec.Kind != kind {
+			continue
+		}
+		if n := score(&rec.Credential); n > bestScore {
+			best, bestScore = rec, n
+		}
+	}
+	if best == nil {
+		return nil, ErrNotFound
+	}
+	if s.key == nil {
+		return nil, ErrLocked
+	}
+	secret, err := s.key.Open(best.Sealed)
+	if err != nil {
+		return nil, err
+	}
+	c := best.Credential
+	c.Secret = string(secret)
+	return &c, nil
+}
+
+// targetMatch scores how well a token's target matches a remote: 0 for not
+// at all, then higher for longer matches
+func targetMatch(target string, u *url.URL) int {
+	if !strings.Contains(target, "://") {
+		if strings.EqualFold(target, u.Host) {
+			return 1
+		}
+		return 0
+	}
+	t, err := url.Parse(target)
+	if err != nil || !strings.EqualFold(t.Scheme, u.Scheme) || !strings.EqualFold(t.Host, u.Host) {
+		return 0
+	}
+	prefix := strings.Trim(t.Path, "/")
+	if prefix == "" {
+		return 2
+	}
+	if !strings.HasPrefix(strings.Trim(u.Path, "/")+"/", prefix+"/") {
+		return 0
+	}
+	return 2 + len(prefix)
+}
+
+// validate checks a credential before it is stored
+func validate(c *Credential) error {
+	c.Target = strings.TrimSpace(c.Target)
+	switch c.Kind {
+	case KindToken:
+		if c.Target == "" {
+			return fmt.Errorf("%w: a token needs a host or URL to be used for", ErrInvalid)
+		}
+		if strings.Contains(c.Target, "://") {
+			if u, err := url.Parse(c.Target); err != nil || u.Host == "" {
+				return fmt.Errorf("%w: bad URL %s", ErrInvalid, c.Target)
+			}
+		} else if strings.ContainsAny(c.Target, "/ ") {
+			return fmt.Errorf("%w: bad host %s", ErrInvalid, c.Target)
+		}
+	case KindSSHPassphrase:
+		if c.Username != "" {
+			return fmt.Errorf("%w: SSH passphrases have no username", ErrInvalid)
+		}
+	default:
+		return fmt.Errorf("%w: unknown kind %q", ErrInvalid, c.Kind)
+	}
+	if c.Secret == "" {
+		return fmt.Errorf("%w: the secret is empty", ErrInvalid)
+	}
+	return nil
+}
+
+// unlockFromKeyring loads the key kept in the keychain or with DPAPI.
+// The caller holds s.mu.
+func (s *Store) unlockFromKeyring(f *file) error {
+	if s.keyring == nil || s.keyring.method() != f.Method {
+		return fmt.Errorf("the credential store key is in the %s, which isn't available", f.Method)
+	}
+	raw, err := s.keyring.load(f.ProtectedKey)
+	if err != nil {
+		return fmt.Errorf("failed to read the key from the %s: %w", f.Method, err)
+	}
+	key, err := encryption.NewKey(raw)
+	if err != nil {
+		return err
+	}
+	if !checkKey(f, key) {
+		return errors.New("the key in the keychain doesn't open the credential store")
+	}
+	s.key = key
+	return nil
+}
+
+// checkKey reports whether key is the one the store was sealed with
+func checkKey(f *file, key *encryption.Key) bool {
+	if !encryption.IsSealed(f.Check) {
+		return false
+	}
+	check, err := key.Open(f.Check)
+	return err == nil && subtle.ConstantTimeCompare(check, []byte(checkText)) == 1
+}
+
+func (f *file) find(id string) int {
+	for i, rec := range f.Credentials {
+		if rec.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// load reads the secrets file. The caller holds s.mu.
+func (s *Store) load() (*file, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotConfigured
+	}
+	if err != nil {
+		return nil, err
+	}
+	f := &file{}
+	if err := json.Unmarshal(data, f); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", secretsFile, err)
+	}
+	if f.Version != version {
+		return nil, fmt.Errorf("unsupported %s version %d", secretsFile, f.Version)
+	}
+	return f, nil
+}
+
+// write saves the secrets file atomically, readable only by the owner.
+// The caller holds s.mu.
+func (s *Store) write(f *file) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(s.path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, secretsFile+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
@@ -0,0 +1,151 @@
+package secrets
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeKeychain keeps the key in memory
+type fakeKeychain struct {
+	key []byte
+}
+
+func (k *fakeKeychain) method() Method { return MethodKeychain }
+
+func (k *fakeKeychain) save(raw []byte) ([]byte, error) {
+	k.key = append([]byte(nil), raw...)
+	return nil, nil
+}
+
+func (k *fakeKeychain) load([]byte) ([]byte, error) {
+	if k.key == nil {
+		return nil, errors.New("no entry")
+	}
+	return k.key, nil
+}
+
+func TestKeychainStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), secretsFile)
+	keychain := &fakeKeychain{}
+	store := &Store{path: path, keyring: keychain}
+
+	// Adding to a new store sets it up with the keychain
+	token, err := store.Add(Credential{Kind: KindToken, Target: "github.com", Username: "alice", Secret: "ghp_secret"})
+	if err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if token.ID == "" || token.Secret != "" {
+		t.Errorf("Add() = %+v", token)
+	}
+	if status := store.Status(); !status.Configured || !status.Unlocked || status.Method != MethodKeychain {
+		t.Errorf("Status() = %+v", status)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "ghp_secret") {
+		t.Error("Secret stored unencrypted")
+	}
+
+	// A new process finds the key in the keychain
+	reopened := &Store{path: path, keyring: keychain}
+	f, err := reopened.load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := reopened.unlockFromKeyring(f); err != nil {
+		t.Fatalf("unlockFromKeyring() error = %v", err)
+	}
+	cred, err := reopened.Token("https://github.com/alice/notes.git", "")
+	if err != nil || cred.Secret != "ghp_secret" || cred.Username != "alice" {
+		t.Fatalf("Token() = %+v, %v", cred, err)
+	}
+
+	// A URL prefix wins over the host
+	team, err := reopened.Add(Credential{Kind: KindToken, Target: "https://github.com/team/", Secret: "team_secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred, _ := reopened.Token("https://github.com/team/wiki.git", ""); cred == nil || cred.Secret != "team_secret" {
+		t.Errorf("Expected the team token, got %+v", cred)
+	}
+	if cred, _ := reopened.Token("https://github.com/teams/wiki.git", ""); cred == nil || cred.Secret != "ghp_secret" {
+		t.Errorf("Expected the host token, got %+v", cred)
+	}
+	if _, err := reopened.Token("https://gitlab.com/alice/notes.git", ""); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	// Updating without a secret keeps it
+	if _, err := reopened.Update(team.ID, Credential{Target: "https://github.com/team/", Label: "Team"}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if cred, _ := reopened.Token("https://github.com/team/wiki.git", ""); cred == nil || cred.Secret != "team_secret" || cred.Label != "Team" {
+		t.Errorf("Update() lost the secret: %+v", cred)
+	}
+
+	list, err := reopened.List()
+	if err != nil || len(list) != 2 {
+		t.Fatalf("List() = %+v, %v", list, err)
+	}
+	if err := reopened.Delete(team.ID); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if err := reopened.Delete(team.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	if _, err := reopened.Add(Credential{Kind: KindToken, Target: "github.com/alice", Secret: "x"}); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Expected ErrInvalid for a host with a path, got %v", err)
+	}
+}
+
+func TestPassphraseStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), secretsFile)
+	store := &Store{path: path}
+
+	if _, err := store.Add(Credential{Kind: KindSSHPassphrase, Secret: "hunter22"}); !errors.Is(err, ErrPassphraseRequired) {
+		t.Fatalf("Expected ErrPassphraseRequired without a keychain, got %v", err)
+	}
+	if err := store.Setup("correct horse"); err != nil {
+		t.Fatalf("Setup() error = %v", err)
+	}
+	if err := store.Setup("correct horse"); !errors.Is(err, ErrConfigured) {
+		t.Errorf("Expected ErrConfigured, got %v", err)
+	}
+	if _, err := store.Add(Credential{Kind: KindSSHPassphrase, Secret: "hunter22"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Add(Credential{Kind: KindSSHPassphrase, Target: "/keys/work", Secret: "work"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := store.SSHPassphrase("/home/alice/.ssh/id_ed25519", true); err != nil || got != "hunter22" {
+		t.Errorf("SSHPassphrase(default) = %q, %v", got, err)
+	}
+	if got, err := store.SSHPassphrase("/keys/work", false); err != nil || got != "work" {
+		t.Errorf("SSHPassphrase(/keys/work) = %q, %v", got, err)
+	}
+	if _, err := store.SSHPassphrase("/keys/other", false); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	store.Lock()
+	if _, err := store.SSHPassphrase("/keys/work", false); !errors.Is(err, ErrLocked) {
+		t.Errorf("Expected ErrLocked, got %v", err)
+	}
+	if err := store.Unlock("wrong horse"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Expected ErrWrongPassphrase, got %v", err)
+	}
+	if err := store.Unlock("correct horse"); err != nil {
+		t.Fatalf("Unlock() error = %v", err)
+	}
+	if got, _ := store.SSHPassphrase("/keys/work", false); got != "work" {
+		t.Errorf("SSHPassphrase() after unlocking = %q", got)
+	}
+}
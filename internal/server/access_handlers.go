@@ -0,0 +1,209 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"inkwell/internal/audit"
+	"inkwell/internal/filesystem"
+	"inkwell/internal/git"
+	"inkwell/internal/index"
+	"inkwell/internal/users"
+)
+
+// guardFor returns the folder permissions of a signed-in user, or nil when
+// no rules restrict the actor. API keys and the local user are governed by
+// their scopes alone.
+func (s *Server) guardFor(actor string) filesystem.Guard {
+	name, ok := strings.CutPrefix(actor, "user:")
+	if !ok || s.users == nil || !s.users.HasRules() {
+		return nil
+	}
+
+	user, err := s.users.ByName(name)
+	if err != nil {
+		return func(string, bool) bool { return false }
+	}
+	if user.IsAdmin() {
+		return nil
+	}
+
+	return func(path string, write bool) bool {
+		switch s.users.Permission(user, path) {
+		case users.PermissionWrite:
+			return true
+		case users.PermissionRead:
+			return !write
+		default:
+			return false
+		}
+	}
+}
+
+// indexFor returns the index as the requester may see it, without the
+// notes their folder permissions hide
+func (s *Server) indexFor(r *http.Request) *index.Index {
+	ix := s.workspace().index
+	guard := s.guardFor(requestActor(r))
+	if guard == nil {
+		return ix
+	}
+	return ix.Filtered(func(path string) bool { return guard(path, false) })
+}
+
+// AccessRequest replaces the folder rules
+type AccessRequest struct {
+	Rules []users.FolderRule `json:"rules"`
+}
+
+// handleGetAccess returns the folder rules
+func (s *Server) handleGetAccess(w http.ResponseWriter, r *http.Request) {
+	if s.users == nil {
+		writeError(w, http.StatusInternalServerError, "User manager not initialized")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    map[string]interface{}{"rules": s.users.Rules()},
+	})
+}
+
+// handleSetAccess replaces the folder rules
+func (s *Server) handleSetAccess(w http.ResponseWriter, r *http.Request) {
+	if s.users == nil {
+		writeError(w, http.StatusInternalServerError, "User manager not initialized")
+		return
+	}
+
+	var req AccessRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	rules, err := s.users.SetRules(req.Rules)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.recordAudit(r, audit.ActionAccessChange, "", fmt.Sprintf("%d rules", len(rules)))
+
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    map[string]interface{}{"rules": rules},
+	})
+}
+
+// gitGuard returns the folder permissions of the requester for paths
+// relative to a repository, which may contain the vault rather than be it.
+// Nil means nothing is restricted; files outside the vault are refused.
+func (s *Server) gitGuard(r *http.Request, repo *git.Repository) filesystem.Guard {
+	guard := s.guardFor(requestActor(r))
+	if guard == nil {
+		return nil
+	}
+
+	root := s.workspace().rootDir
+	return func(p string, write bool) bool {
+		rel, err := filepath.Rel(root, filepath.Join(repo.Path(), filepath.FromSlash(p)))
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return false
+		}
+		return guard(filepath.ToSlash(rel), write)
+	}
+}
+
+// gitFiles checks that a restricted requester may change the files of a
+// stage, unstage or discard, turning all into the changed files they may
+// change. It reports false, having written the error, if they may not.
+func (s *Server) gitFiles(w http.ResponseWriter, r *http.Request, repo *git.Repository, files *[]string, all *bool) bool {
+	guard := s.gitGuard(r, repo)
+	if guard == nil {
+		return true
+	}
+
+	if *all {
+		status, err := repo.Status()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to get git status: "+err.Error())
+			return false
+		}
+		*files = nil
+		for _, f := range status.Files {
+			if guard(f.Path, true) {
+				*files = append(*files, f.Path)
+			}
+		}
+		*all = false
+		if len(*files) == 0 {
+			writeError(w, http.StatusBadRequest, "No changes you may commit")
+			return false
+		}
+		return true
+	}
+
+	for _, f := range *files {
+		if !guard(f, true) {
+			writeError(w, http.StatusForbidden, "You may not change "+f)
+			return false
+		}
+	}
+	return true
+}
+
+// gitStagedAllowed checks that a restricted requester may change everything
+// staged, before they commit it. It reports false, having written the
+// error, if they may not.
+func (s *Server) gitStagedAllowed(w http.ResponseWriter, r *http.Request, repo *git.Repository, files []string) bool {
+	guard := s.gitGuard(r, repo)
+	if guard == nil {
+		return true
+	}
+
+	for _, f := range files {
+		if !guard(f, true) {
+			writeError(w, http.StatusForbidden, "You may not change "+f)
+			return false
+		}
+	}
+	status, err := repo.Status()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to get git status: "+err.Error())
+		return false
+	}
+	for _, f := range status.Files {
+		if f.Staged && !guard(f.Path, true) {
+			writeError(w, http.StatusForbidden, "Staged changes include "+f.Path+", which you may not change")
+			return false
+		}
+	}
+	return true
+}
+
+// gitReadable writes a 403 and reports false if a restricted requester may
+// not read a repository path
+func (s *Server) gitReadable(w http.ResponseWriter, r *http.Request, repo *git.Repository, p string) bool {
+	if guard := s.gitGuard(r, repo); guard != nil && !guard(p, false) {
+		writeError(w, http.StatusForbidden, "You may not read "+p)
+		return false
+	}
+	return true
+}
+
+// filterGitStatus drops the files a restricted requester may not read
+func filterGitStatus(status *git.GitStatus, guard filesystem.Guard) {
+	if guard == nil || status == nil {
+		return
+	}
+	files := status.Files[:0]
+	for _, f := range status.Files {
+		if guard(f.Path, false) {
+			files = append(files, f)
+		}
+	}
+	status.Files = files
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFolderAccess(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "team"), 0755)
+	os.MkdirAll(filepath.Join(dir, "private"), 0755)
+	os.WriteFile(filepath.Join(dir, "team", "plan.md"), []byte("# Plan\n\nquarterly goals\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "private", "diary.md"), []byte("# Diary\n\nquarterly worries\n"), 0644)
+
+	srv := newTestServer(t, dir)
+
+	do := func(cookie *http.Cookie, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rec, req)
+		return rec
+	}
+	login := func(name string) *http.Cookie {
+		rec := do(nil, http.MethodPost, "/api/auth/login", `{"name":"`+name+`","password":"correct horse"}`)
+		for _, c := range rec.Result().Cookies() {
+			if c.Name == "inkwell_session" {
+				return c
+			}
+		}
+		t.Fatalf("Login as %s failed: %s", name, rec.Body.String())
+		return nil
+	}
+
+	do(nil, http.MethodPost, "/api/users", `{"name":"alex","password":"correct horse","role":"admin"}`)
+	admin := login("alex")
+	do(admin, http.MethodPost, "/api/users", `{"name":"sam","password":"correct horse","role":"editor"}`)
+	sam := login("sam")
+
+	rules := `{"rules":[{"folder":"team","role":"editor","permission":"read"},{"folder":"private","user":"sam","permission":"none"}]}`
+	if rec := do(sam, http.MethodPut, "/api/access", rules); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for an editor changing rules, got %d", rec.Code)
+	}
+	if rec := do(admin, http.MethodPut, "/api/access", rules); rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200 setting rules, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	if rec := do(sam, http.MethodGet, "/api/files?path=team/plan.md", ""); rec.Code != http.StatusOK {
+		t.Errorf("Expected a read-only note readable, got %d", rec.Code)
+	}
+	if rec := do(sam, http.MethodPut, "/api/files?path=team/plan.md", `{"content":"changed"}`); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 saving a read-only note, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := do(sam, http.MethodGet, "/api/files?path=private/diary.md", ""); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 reading a hidden note, got %d", rec.Code)
+	}
+	if rec := do(sam, http.MethodGet, "/api/tree", ""); strings.Contains(rec.Body.String(), "diary.md") {
+		t.Errorf("Expected the hidden note left out of the tree: %s", rec.Body.String())
+	}
+	if rec := do(admin, http.MethodGet, "/api/files?path=private/diary.md", ""); rec.Code != http.StatusOK {
+		t.Errorf("Expected admins unrestricted, got %d", rec.Code)
+	}
+
+	// Search only finds what the user may read
+	search := func(cookie *http.Cookie) string {
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			body := do(cookie, http.MethodGet, "/api/search?q=quarterly", "").Body.String()
+			if strings.Contains(body, "plan.md") || time.Now().After(deadline) {
+				return body
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	if body := search(admin); !strings.Contains(body, "diary.md") {
+		t.Errorf("Expected admins to find every note: %s", body)
+	}
+	if body := search(sam); strings.Contains(body, "diary.md") || !strings.Contains(body, "plan.md") {
+		t.Errorf("Unexpected search results for a restricted user: %s", body)
+	}
+}
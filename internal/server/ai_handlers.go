@@ -0,0 +1,102 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"inkwell/internal/ai"
+
+	"github.com/gorilla/mux"
+)
+
+// SummarizeRequest names a note to summarize, or gives its text
+type SummarizeRequest struct {
+	Path    string `json:"path,omitempty"`
+	Content string `json:"content,omitempty"` // Used instead of the saved note, e.g. for unsaved edits
+}
+
+// registerAIRoutes serves writing help from a language model. Only
+// registered with --ai.
+func (s *Server) registerAIRoutes(api *mux.Router) {
+	api.HandleFunc("/ai/commit-message", s.handleAICommitMessage).Methods("POST")
+	api.HandleFunc("/ai/summarize", s.handleAISummarize).Methods("POST")
+}
+
+// handleAICommitMessage drafts a commit message for the staged changes
+func (s *Server) handleAICommitMessage(w http.ResponseWriter, r *http.Request) {
+	if s.git == nil {
+		writeError(w, http.StatusBadRequest, "Git is disabled")
+		return
+	}
+	repo := s.git.CurrentRepository()
+	if repo == nil {
+		writeError(w, http.StatusBadRequest, "Not a git repository")
+		return
+	}
+
+	diff, err := repo.StagedDiff(ai.MaxInput)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to read staged changes: "+err.Error())
+		return
+	}
+	message, err := ai.CommitMessage(r.Context(), s.ai, diff)
+	if err != nil {
+		writeAIError(w, err, "Nothing is staged")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data: map[string]string{
+			"message":  message,
+			"provider": s.ai.Name(),
+		},
+	})
+}
+
+// handleAISummarize summarizes a note
+func (s *Server) handleAISummarize(w http.ResponseWriter, r *http.Request) {
+	var req SummarizeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	content := req.Content
+	if content == "" {
+		if req.Path == "" {
+			writeError(w, http.StatusBadRequest, "Path or content is required")
+			return
+		}
+		var err error
+		if content, _, err = s.workspace().readNote(requestActor(r), req.Path); err != nil {
+			writeReadError(w, err)
+			return
+		}
+	}
+
+	summary, err := ai.Summarize(r.Context(), s.ai, content)
+	if err != nil {
+		writeAIError(w, err, "The note is empty")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data: map[string]string{
+			"summary":  summary,
+			"provider": s.ai.Name(),
+		},
+	})
+}
+
+// writeAIError reports a failed request to the language model. empty
+// explains ai.ErrEmpty.
+func writeAIError(w http.ResponseWriter, err error, empty string) {
+	if errors.Is(err, ai.ErrEmpty) {
+		writeError(w, http.StatusBadRequest, empty)
+		return
+	}
+	writeError(w, http.StatusBadGateway, "The language model failed: "+err.Error())
+}
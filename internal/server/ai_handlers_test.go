@@ -0,0 +1,97 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"inkwell/internal/config"
+
+	gogit "github.com/go-git/go-git/v5"
+)
+
+func TestAI(t *testing.T) {
+	var prompts []string
+	model := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Messages []struct {
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		prompt := req.Messages[len(req.Messages)-1].Content
+		prompts = append(prompts, prompt)
+		reply := "A short summary"
+		if strings.Contains(prompt, "diff") {
+			reply = "```\nAdd ideas note\n```"
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message": map[string]string{"role": "assistant", "content": reply},
+		})
+	}))
+	defer model.Close()
+
+	dir := t.TempDir()
+	repo, err := gogit.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(dir, "ideas.md"), []byte("# Ideas\n\nWrite more\n"), 0644)
+
+	srv := newTestServer(t, dir, func(cfg *config.Config) {
+		cfg.AIProvider = "ollama"
+		cfg.AIURL = model.URL
+		cfg.AIModel = "test"
+	})
+
+	post := func(path, body string) (*httptest.ResponseRecorder, map[string]string) {
+		rec := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		var resp struct {
+			Data map[string]string `json:"data"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec, resp.Data
+	}
+
+	if rec, _ := post("/api/ai/commit-message", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 with nothing staged, got %d", rec.Code)
+	}
+
+	wt, _ := repo.Worktree()
+	if _, err := wt.Add("ideas.md"); err != nil {
+		t.Fatal(err)
+	}
+	rec, data := post("/api/ai/commit-message", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if data["message"] != "Add ideas note" {
+		t.Errorf("Expected the fences stripped, got %q", data["message"])
+	}
+	if !strings.Contains(prompts[0], "+++ b/ideas.md") || !strings.Contains(prompts[0], "+Write more") {
+		t.Errorf("Expected the staged diff in the prompt, got %q", prompts[0])
+	}
+
+	rec, data = post("/api/ai/summarize", `{"path":"ideas.md"}`)
+	if rec.Code != http.StatusOK || data["summary"] != "A short summary" {
+		t.Fatalf("Unexpected summary %d: %s", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(prompts[1], "Write more") {
+		t.Errorf("Expected the note in the prompt, got %q", prompts[1])
+	}
+}
+
+func TestAIDisabled(t *testing.T) {
+	srv := newTestServer(t, t.TempDir())
+
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/ai/summarize", strings.NewReader(`{"content":"x"}`)))
+	if rec.Code == http.StatusOK {
+		t.Errorf("Expected AI routes missing without a provider, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,191 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"inkwell/internal/apikeys"
+	"inkwell/internal/audit"
+
+	"github.com/gorilla/mux"
+)
+
+type contextKey string
+
+const apiKeyContextKey contextKey = "apiKey"
+
+// apiKeyFromContext returns the API key a request authenticated with, if any
+func apiKeyFromContext(ctx context.Context) *apikeys.Key {
+	key, _ := ctx.Value(apiKeyContextKey).(*apikeys.Key)
+	return key
+}
+
+// apiKeyAuth authenticates requests that carry an API key and enforces the
+// key's scopes. Requests without a key are treated as the local session.
+func (s *Server) apiKeyAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := requestToken(r)
+		if token == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if s.apiKeys == nil {
+			writeError(w, http.StatusUnauthorized, "API keys are not available")
+			return
+		}
+
+		key, err := s.apiKeys.Authenticate(token)
+		if err != nil {
+			writeError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+
+		if !keyAllows(key, r) {
+			writeError(w, http.StatusForbidden, "API key does not have the required scope")
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiKeyContextKey, key)))
+	})
+}
+
+// requestToken extracts an API key from the Authorization or X-API-Key header
+func requestToken(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+	}
+	return r.Header.Get("X-API-Key")
+}
+
+// keyAllows reports whether a key's scopes cover a request. Files and git
+// keys may also read within their area; read keys may only read.
+func keyAllows(key *apikeys.Key, r *http.Request) bool {
+	path := r.URL.Path
+	readOnly := r.Method == http.MethodGet || r.Method == http.MethodHead
+
+	switch {
+	case strings.HasPrefix(path, "/api/keys"), strings.HasPrefix(path, "/api/users"), path == "/api/access", path == "/api/audit", path == "/api/diagnostics", path == "/api/assets/migrate", path == "/api/vault/init":
+		return key.HasScope(apikeys.ScopeAdmin)
+	case path == "/api/directories":
+		// Browses the host and switches the vault everyone is served
+		return key.HasScope(apikeys.ScopeAdmin)
+	case strings.HasPrefix(path, "/api/debug/"), strings.HasPrefix(path, "/debug/"):
+		// Profiles show memory contents and command lines
+		return key.HasScope(apikeys.ScopeAdmin)
+	case strings.HasPrefix(path, "/api/git/auth"), strings.HasPrefix(path, "/api/secrets"):
+		// Signing in and saved credentials change what every git operation
+		// authenticates with
+		return key.HasScope(apikeys.ScopeAdmin)
+	case path == "/api/settings" && !readOnly:
+		// Settings are shared by everyone using the instance
+		return key.HasScope(apikeys.ScopeAdmin)
+	case path == "/ws":
+		// WebSocket clients can save files
+		return key.HasScope(apikeys.ScopeFiles)
+	case path == "/api/encryption/unlock", path == "/api/encryption/lock":
+		// Read-only keys need the key to read encrypted notes
+		return key.HasScope(apikeys.ScopeFiles) || key.HasScope(apikeys.ScopeRead)
+	case strings.HasPrefix(path, "/api/git/"), path == "/api/publish":
+		return key.HasScope(apikeys.ScopeGit) || (readOnly && key.HasScope(apikeys.ScopeRead))
+	case path == "/api/ai/commit-message":
+		// Reads the staged changes for a commit
+		return key.HasScope(apikeys.ScopeGit)
+	case path == "/api/ai/summarize":
+		// Only reads the note
+		return key.HasScope(apikeys.ScopeFiles) || key.HasScope(apikeys.ScopeRead)
+	default:
+		return key.HasScope(apikeys.ScopeFiles) || (readOnly && key.HasScope(apikeys.ScopeRead))
+	}
+}
+
+// APIKeyRequest represents an API key creation request
+type APIKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+// handleListAPIKeys returns all API keys without their hashes
+func (s *Server) handleListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	if s.apiKeys == nil {
+		writeError(w, http.StatusInternalServerError, "API key manager not initialized")
+		return
+	}
+
+	keys := s.apiKeys.List()
+	for i := range keys {
+		keys[i].Hash = ""
+	}
+
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    keys,
+	})
+}
+
+// handleCreateAPIKey creates a key and returns its token once
+func (s *Server) handleCreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	if s.apiKeys == nil {
+		writeError(w, http.StatusInternalServerError, "API key manager not initialized")
+		return
+	}
+
+	var req APIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if strings.TrimSpace(req.Name) == "" {
+		writeError(w, http.StatusBadRequest, "Name is required")
+		return
+	}
+
+	scopes, err := apikeys.ParseScopes(req.Scopes)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	key, token, err := s.apiKeys.Create(strings.TrimSpace(req.Name), scopes)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	key.Hash = ""
+
+	s.recordAudit(r, audit.ActionAPIKeyCreate, "", key.Name)
+
+	writeJSON(w, http.StatusCreated, APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"key":   key,
+			"token": token,
+		},
+	})
+}
+
+// handleRevokeAPIKey deletes an API key
+func (s *Server) handleRevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	if s.apiKeys == nil {
+		writeError(w, http.StatusInternalServerError, "API key manager not initialized")
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	if err := s.apiKeys.Revoke(id); err != nil {
+		if errors.Is(err, apikeys.ErrKeyNotFound) {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "Failed to revoke API key: "+err.Error())
+		return
+	}
+
+	s.recordAudit(r, audit.ActionAPIKeyRevoke, "", id)
+
+	writeJSON(w, http.StatusOK, APIResponse{Success: true})
+}
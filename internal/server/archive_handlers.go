@@ -0,0 +1,111 @@
+package server
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"inkwell/internal/audit"
+	"inkwell/internal/config"
+	"inkwell/internal/filesystem"
+)
+
+// handleExportZip streams a zip of a folder (the whole vault by default)
+func (s *Server) handleExportZip(w http.ResponseWriter, r *http.Request) {
+	fs := s.workspace().files(requestActor(r))
+	path := r.URL.Query().Get("path")
+
+	// Check the target up front so errors are still reported as JSON
+	fullPath, err := fs.ResolvePath(path)
+	if err != nil {
+		writeFileError(w, http.StatusBadRequest, "", err)
+		return
+	}
+	info, err := os.Stat(fullPath)
+	if err != nil || !info.IsDir() {
+		writeError(w, http.StatusNotFound, "Directory not found")
+		return
+	}
+
+	name := filepath.Base(fullPath)
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": name + ".zip",
+	}))
+	w.WriteHeader(http.StatusOK)
+
+	if err := fs.WriteZip(w, path); err != nil {
+		slog.Warn("Failed to export zip", "path", path, "error", err)
+	}
+}
+
+// handleImportZip extracts an uploaded archive into a folder of the vault
+func (s *Server) handleImportZip(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseMultipartForm(multipartMemory); err != nil {
+		if isTooLarge(err) {
+			writeTooLarge(w, s.bodyLimit(r))
+			return
+		}
+		writeError(w, http.StatusBadRequest, "Invalid upload: "+err.Error())
+		return
+	}
+
+	onConflict, err := filesystem.ParseConflictStrategy(r.FormValue("conflict"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Failed to get uploaded file: "+err.Error())
+		return
+	}
+	defer file.Close()
+
+	if err := s.scanUpload(r, file, header.Filename); err != nil {
+		writeScanError(w, err)
+		return
+	}
+
+	zr, err := zip.NewReader(file, header.Size)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid zip archive: "+err.Error())
+		return
+	}
+
+	// Archives may expand well past the upload limit, up to the largest
+	// size accepted for a single upload
+	maxSize := s.config.MaxChunkedSize
+	if maxSize <= 0 {
+		maxSize = config.DefaultMaxChunkedSize
+	}
+
+	target := r.FormValue("path")
+	var result *filesystem.ImportResult
+	s.saves.all(func() {
+		result, err = s.workspace().files(requestActor(r)).ExtractZip(zr, target, onConflict, maxSize)
+	})
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, filesystem.ErrImportTooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		} else if errors.Is(err, filesystem.ErrAccessDenied) {
+			status = http.StatusForbidden
+		}
+		if result != nil && len(result.Created) > 0 {
+			writeJSON(w, status, APIResponse{Success: false, Data: result, Error: err.Error()})
+			return
+		}
+		writeError(w, status, err.Error())
+		return
+	}
+
+	s.recordAudit(r, audit.ActionZipImport, target, fmt.Sprintf("%d created, %d skipped", len(result.Created), len(result.Skipped)))
+	writeJSON(w, http.StatusCreated, APIResponse{Success: true, Data: result})
+}
@@ -0,0 +1,204 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"inkwell/internal/audit"
+	"inkwell/internal/filesystem"
+	"inkwell/internal/git"
+
+	"github.com/gorilla/mux"
+)
+
+// assetCache remembers the content hash of each asset until it changes
+type assetCache struct {
+	mu     sync.Mutex
+	hashes map[string]assetHash // By file name under the assets folder
+}
+
+type assetHash struct {
+	modTime time.Time
+	size    int64
+	hash    string
+}
+
+// assetVersion returns the content hash of an open asset, reading it only
+// when its size or modification time changed since it was last hashed.
+// The file is left at its start.
+func (ws *workspace) assetVersion(filename string, file io.ReadSeeker, modTime time.Time, size int64) (string, error) {
+	c := ws.assets
+	c.mu.Lock()
+	cached, ok := c.hashes[filename]
+	c.mu.Unlock()
+	if ok && cached.modTime.Equal(modTime) && cached.size == size {
+		return cached.hash, nil
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	hash := hex.EncodeToString(h.Sum(nil)[:16])
+
+	c.mu.Lock()
+	if c.hashes == nil {
+		c.hashes = make(map[string]assetHash)
+	}
+	c.hashes[filename] = assetHash{modTime: modTime, size: size, hash: hash}
+	c.mu.Unlock()
+	return hash, nil
+}
+
+// versionedImageURL adds the content hash to a link into /images, so the
+// browser may keep the asset until it changes. Other links, links that
+// already carry a query and assets that can't be read are left alone.
+func (ws *workspace) versionedImageURL(files *filesystem.FileSystem, dest string) string {
+	name, ok := strings.CutPrefix(dest, "/images/")
+	if !ok || name == "" || strings.ContainsAny(name, "?#") {
+		return dest
+	}
+	filename, err := url.PathUnescape(name)
+	if err != nil {
+		return dest
+	}
+
+	file, info, err := files.OpenImage(filename)
+	if err != nil {
+		return dest
+	}
+	defer file.Close()
+	if info.IsDir() {
+		return dest
+	}
+	hash, err := ws.assetVersion(filename, file, info.ModTime(), info.Size())
+	if err != nil {
+		return dest
+	}
+	return dest + "?v=" + hash
+}
+
+// handleServeImage serves images from the assets directory. Every response
+// carries the content hash as its ETag, so unchanged assets are answered
+// with 304 Not Modified; URLs naming the current hash in ?v= may be cached
+// for good.
+func (s *Server) handleServeImage(w http.ResponseWriter, r *http.Request) {
+	filename := mux.Vars(r)["filename"]
+
+	ws := s.workspace()
+	file, info, err := ws.files(requestActor(r)).OpenImage(filename)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer file.Close()
+
+	if info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+
+	hash, err := ws.assetVersion(filename, file, info.ModTime(), info.Size())
+	if err != nil {
+		http.Error(w, "Failed to read image", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("ETag", `"`+hash+`"`)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if v := r.URL.Query().Get("v"); v == hash {
+		// Private, as folder permissions may hide the asset from others
+		w.Header().Set("Cache-Control", "private, max-age=31536000, immutable")
+	} else {
+		// Revalidated on every use, which the ETag makes cheap
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	http.ServeContent(w, r, info.Name(), info.ModTime(), file)
+}
+
+// MigrateAssetsRequest moves a vault's images into a layout. Layout
+// defaults to the one in the vault configuration; Stage adds the changes
+// to the git index, ready to commit.
+type MigrateAssetsRequest struct {
+	Layout string `json:"layout,omitempty"`
+	DryRun bool   `json:"dryRun,omitempty"`
+	Stage  bool   `json:"stage,omitempty"`
+}
+
+// handleMigrateAssets rearranges the images notes use, rewriting the
+// references to them
+func (s *Server) handleMigrateAssets(w http.ResponseWriter, r *http.Request) {
+	var req MigrateAssetsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	ws := s.workspace()
+	layout := req.Layout
+	if layout == "" {
+		layout = ws.vault.AssetLayout
+	}
+	if !filesystem.ValidAssetLayout(layout) {
+		writeError(w, http.StatusBadRequest, "Unknown asset layout: "+layout)
+		return
+	}
+
+	migration, err := ws.files(requestActor(r)).MigrateAssets(layout, req.DryRun)
+	if err != nil {
+		writeFileError(w, http.StatusInternalServerError, "Failed to migrate assets: ", err)
+		return
+	}
+	if req.DryRun {
+		writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: migration})
+		return
+	}
+	s.recordAudit(r, audit.ActionAssetMigrate, "", fmt.Sprintf("%s: %d moved, %d notes", migration.Layout, len(migration.Moved), len(migration.Notes)))
+
+	response := map[string]interface{}{"migration": migration}
+	if req.Stage && len(migration.Changed) > 0 {
+		if err := s.stageVaultPaths(migration.Changed); err != nil {
+			response["stageError"] = err.Error()
+		} else {
+			response["staged"] = true
+		}
+	}
+
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: response})
+}
+
+// stageVaultPaths stages the changes git sees among paths relative to the
+// vault, such as the files a migration moved
+func (s *Server) stageVaultPaths(paths []string) error {
+	repo := s.git.CurrentRepository()
+	if repo == nil {
+		return errors.New("not a git repository")
+	}
+	return repo.StageChanges(repoPaths(repo, s.workspace().rootDir, paths))
+}
+
+// repoPaths turns paths relative to the vault into paths relative to the
+// repository holding it
+func repoPaths(repo *git.Repository, rootDir string, paths []string) []string {
+	files := make([]string, 0, len(paths))
+	for _, p := range paths {
+		rel, err := filepath.Rel(repo.Path(), filepath.Join(rootDir, filepath.FromSlash(p)))
+		if err == nil && !strings.HasPrefix(rel, "..") {
+			files = append(files, filepath.ToSlash(rel))
+		}
+	}
+	return files
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestImageCaching(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "assets", "shots"), 0755)
+	os.WriteFile(filepath.Join(dir, "assets", "shots", "a.png"), []byte("first image"), 0644)
+	os.WriteFile(filepath.Join(dir, "note.md"), []byte("![A](/images/shots/a.png) [site](https://example.com)\n"), 0644)
+
+	srv := newTestServer(t, dir)
+
+	get := func(method, target, etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		rec := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get(http.MethodGet, "/images/shots/a.png", "")
+	etag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || rec.Body.String() != "first image" || etag == "" {
+		t.Fatalf("Unexpected response %d %q, ETag %q", rec.Code, rec.Body.String(), etag)
+	}
+	if cc := rec.Header().Get("Cache-Control"); cc != "no-cache" {
+		t.Errorf("Expected an unversioned URL revalidated, got %q", cc)
+	}
+
+	// HEAD has the headers without the body
+	if rec := get(http.MethodHead, "/images/shots/a.png", ""); rec.Code != http.StatusOK || rec.Body.Len() != 0 || rec.Header().Get("ETag") != etag {
+		t.Errorf("Unexpected HEAD response %d, %d bytes, ETag %q", rec.Code, rec.Body.Len(), rec.Header().Get("ETag"))
+	}
+	if rec := get(http.MethodGet, "/images/shots/a.png", etag); rec.Code != http.StatusNotModified {
+		t.Errorf("Expected 304 for a matching ETag, got %d", rec.Code)
+	}
+
+	// The preview links the image by its hash, which may be cached for good
+	rec = get(http.MethodGet, "/api/render?path=note.md", "")
+	version := strings.Trim(etag, `"`)
+	if !strings.Contains(rec.Body.String(), "/images/shots/a.png?v="+version) || !strings.Contains(rec.Body.String(), "https://example.com") {
+		t.Fatalf("Expected a versioned image URL, got %s", rec.Body.String())
+	}
+	rec = get(http.MethodGet, "/images/shots/a.png?v="+version, "")
+	if cc := rec.Header().Get("Cache-Control"); !strings.Contains(cc, "immutable") {
+		t.Errorf("Expected a versioned URL cached for good, got %q", cc)
+	}
+
+	// Changed content gets a new ETag
+	os.WriteFile(filepath.Join(dir, "assets", "shots", "a.png"), []byte("second image, longer"), 0644)
+	if rec := get(http.MethodGet, "/images/shots/a.png", etag); rec.Code != http.StatusOK || rec.Header().Get("ETag") == etag {
+		t.Errorf("Expected the changed image served afresh, got %d with ETag %q", rec.Code, rec.Header().Get("ETag"))
+	}
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"inkwell/internal/audit"
+)
+
+const defaultAuditLimit = 100
+
+// requestActor identifies who made a request for the audit log
+func requestActor(r *http.Request) string {
+	if key := apiKeyFromContext(r.Context()); key != nil {
+		return "apikey:" + key.Name
+	}
+	if user := userFromContext(r.Context()); user != nil {
+		return "user:" + user.Name
+	}
+	return "local"
+}
+
+// recordAudit appends a mutating operation to the audit log
+func (s *Server) recordAudit(r *http.Request, action, path, summary string) {
+	s.recordAuditAs(requestActor(r), action, path, summary)
+}
+
+// recordAuditAs appends an operation performed by a known actor
+func (s *Server) recordAuditAs(actor, action, path, summary string) {
+	if s.audit == nil {
+		return
+	}
+
+	err := s.audit.Record(audit.Entry{
+		Actor:   actor,
+		Action:  action,
+		Path:    path,
+		Summary: summary,
+	})
+	if err != nil {
+		slog.Warn("Failed to write audit log", "error", err)
+	}
+}
+
+// handleGetAudit returns audit log entries, newest first
+func (s *Server) handleGetAudit(w http.ResponseWriter, r *http.Request) {
+	if s.audit == nil {
+		writeError(w, http.StatusInternalServerError, "Audit log not initialized")
+		return
+	}
+
+	query := r.URL.Query()
+	filter := audit.Filter{
+		Action: query.Get("action"),
+		Actor:  query.Get("actor"),
+		Path:   query.Get("path"),
+		Limit:  defaultAuditLimit,
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		filter.Limit = n
+	}
+
+	if since := query.Get("since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid since, expected RFC 3339 time")
+			return
+		}
+		filter.Since = t
+	}
+
+	entries, err := s.audit.Entries(filter)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to read audit log: "+err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    entries,
+	})
+}
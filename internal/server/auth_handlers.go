@@ -0,0 +1,463 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"inkwell/internal/audit"
+	"inkwell/internal/recents"
+	"inkwell/internal/users"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	userContextKey    contextKey = "user"
+	sessionCookieName            = "inkwell_session"
+)
+
+// userFromContext returns the signed-in user for a request, if any
+func userFromContext(ctx context.Context) *users.User {
+	user, _ := ctx.Value(userContextKey).(*users.User)
+	return user
+}
+
+// userAuth requires a signed-in user once accounts exist. Without accounts,
+// or when an API key was presented, requests pass through unchanged.
+func (s *Server) userAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.users == nil || !s.users.HasUsers() || apiKeyFromContext(r.Context()) != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		path := r.URL.Path
+		if path == "/login" || path == "/api/auth/login" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		user := s.sessionUser(r)
+		if user == nil {
+			if isUIPath(path) {
+				http.Redirect(w, r, "/login", http.StatusFound)
+				return
+			}
+			writeError(w, http.StatusUnauthorized, "Sign in required")
+			return
+		}
+
+		if !userAllows(user, r) {
+			writeError(w, http.StatusForbidden, "Your role does not allow this action")
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userContextKey, user)))
+	})
+}
+
+// sessionUser returns the user for a request's session cookie
+func (s *Server) sessionUser(r *http.Request) *users.User {
+	cookie, err := r.Cookie(sessionCookieName)
+	if err != nil {
+		return nil
+	}
+
+	userID, ok := s.sessions.Lookup(cookie.Value)
+	if !ok {
+		return nil
+	}
+
+	user, err := s.users.Get(userID)
+	if err != nil {
+		return nil
+	}
+	return user
+}
+
+// isUIPath reports whether a path serves the web UI rather than data
+func isUIPath(path string) bool {
+	for _, prefix := range []string{"/api/", "/ws", "/raw/", "/images/", "/plantuml/", "/print"} {
+		if strings.HasPrefix(path, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// userAllows reports whether a user's role covers a request
+func userAllows(user *users.User, r *http.Request) bool {
+	path := r.URL.Path
+
+	switch {
+	case strings.HasPrefix(path, "/api/users"), strings.HasPrefix(path, "/api/keys"), path == "/api/access", path == "/api/audit", path == "/api/diagnostics", path == "/api/assets/migrate", path == "/api/vault/init":
+		return user.IsAdmin()
+	case path == "/api/directories":
+		// Browses the host and switches the vault everyone is served
+		return user.IsAdmin()
+	case strings.HasPrefix(path, "/api/debug/"), strings.HasPrefix(path, "/debug/"):
+		// Profiles show memory contents and command lines
+		return user.IsAdmin()
+	case strings.HasPrefix(path, "/api/git/auth"), strings.HasPrefix(path, "/api/secrets"):
+		// Signing in and saved credentials change what every git operation
+		// authenticates with
+		return user.IsAdmin()
+	case path == "/api/settings" && r.Method != http.MethodGet && r.Method != http.MethodHead:
+		// Shared settings; personal preferences live under /api/me/settings
+		return user.IsAdmin()
+	case strings.HasPrefix(path, "/api/me"), strings.HasPrefix(path, "/api/recents"), path == "/api/auth/logout", path == "/ws":
+		// Every user manages their own profile and recents; viewers get a
+		// read-only socket
+		return true
+	case path == "/api/encryption/unlock", path == "/api/encryption/lock":
+		// Viewers need the key to read encrypted notes
+		return true
+	case path == "/api/ai/summarize":
+		// Only reads the note
+		return true
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		return true
+	default:
+		return user.CanWrite()
+	}
+}
+
+// recentsFor returns the recents list for the requesting user
+func (s *Server) recentsFor(r *http.Request) *recents.Manager {
+	user := userFromContext(r.Context())
+	if user == nil {
+		return s.recents
+	}
+
+	s.userRecentsMu.Lock()
+	defer s.userRecentsMu.Unlock()
+
+	if m, ok := s.userRecents[user.ID]; ok {
+		return m
+	}
+
+	m, err := recents.NewAt(s.users.DataDir(user.ID))
+	if err != nil {
+		return nil
+	}
+	m.SetLimit(s.config.RecentsLimit)
+	if !s.config.KeepMissing {
+		m.DropMissing()
+	}
+	s.userRecents[user.ID] = m
+	return m
+}
+
+// LoginRequest represents a sign-in request
+type LoginRequest struct {
+	Name     string `json:"name"`
+	Password string `json:"password"`
+}
+
+// handleLogin signs a user in and sets the session cookie
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	if s.users == nil || !s.users.HasUsers() {
+		writeError(w, http.StatusBadRequest, "No accounts are configured")
+		return
+	}
+
+	var req LoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	user, err := s.users.Authenticate(req.Name, req.Password)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	token, err := s.sessions.Create(user.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to create session: "+err.Error())
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   int(users.SessionTTL.Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    user.Public(),
+	})
+}
+
+// handleLogout ends the current session
+func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(sessionCookieName); err == nil {
+		s.sessions.Revoke(cookie.Value)
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	writeJSON(w, http.StatusOK, APIResponse{Success: true})
+}
+
+// handleGetMe returns the signed-in user
+func (s *Server) handleGetMe(w http.ResponseWriter, r *http.Request) {
+	data := map[string]interface{}{
+		"accounts": s.users != nil && s.users.HasUsers(),
+	}
+	if user := userFromContext(r.Context()); user != nil {
+		data["user"] = user.Public()
+	}
+
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    data,
+	})
+}
+
+// ProfileRequest represents an update to the signed-in user's profile
+type ProfileRequest struct {
+	GitName  string `json:"gitName"`
+	GitEmail string `json:"gitEmail"`
+	Password string `json:"password"`
+}
+
+// handleUpdateMe updates the signed-in user's git identity or password
+func (s *Server) handleUpdateMe(w http.ResponseWriter, r *http.Request) {
+	user := userFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusBadRequest, "Not signed in")
+		return
+	}
+
+	var req ProfileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	updated, err := s.users.Update(user.ID, strings.TrimSpace(req.GitName), strings.TrimSpace(req.GitEmail), req.Password)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.recordAudit(r, audit.ActionUserUpdate, "", updated.Name)
+
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    updated.Public(),
+	})
+}
+
+// handleGetMySettings returns the signed-in user's preferences
+func (s *Server) handleGetMySettings(w http.ResponseWriter, r *http.Request) {
+	user := userFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusBadRequest, "Not signed in")
+		return
+	}
+
+	settings, err := s.users.Settings(user.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to read settings: "+err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    settings,
+	})
+}
+
+// handleUpdateMySettings replaces the signed-in user's preferences
+func (s *Server) handleUpdateMySettings(w http.ResponseWriter, r *http.Request) {
+	user := userFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusBadRequest, "Not signed in")
+		return
+	}
+
+	var settings map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := s.users.SaveSettings(user.ID, settings); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to save settings: "+err.Error())
+		return
+	}
+	s.recordAudit(r, audit.ActionSettingsChange, "", "")
+
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    settings,
+	})
+}
+
+// UserRequest represents an account creation request
+type UserRequest struct {
+	Name     string `json:"name"`
+	Password string `json:"password"`
+	Role     string `json:"role"`
+}
+
+// handleListUsers returns all accounts
+func (s *Server) handleListUsers(w http.ResponseWriter, r *http.Request) {
+	if s.users == nil {
+		writeError(w, http.StatusInternalServerError, "User manager not initialized")
+		return
+	}
+
+	list := s.users.List()
+	for i := range list {
+		list[i] = list[i].Public()
+	}
+
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    list,
+	})
+}
+
+// handleCreateUser creates an account. The first account must be an admin,
+// since creating it turns on sign-in for the whole instance.
+func (s *Server) handleCreateUser(w http.ResponseWriter, r *http.Request) {
+	if s.users == nil {
+		writeError(w, http.StatusInternalServerError, "User manager not initialized")
+		return
+	}
+
+	var req UserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	role, err := users.ParseRole(req.Role)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !s.users.HasUsers() && role != users.RoleAdmin {
+		writeError(w, http.StatusBadRequest, "The first account must be an admin")
+		return
+	}
+
+	user, err := s.users.Create(req.Name, req.Password, role)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, users.ErrUserExists) {
+			status = http.StatusConflict
+		}
+		writeError(w, status, err.Error())
+		return
+	}
+	s.recordAudit(r, audit.ActionUserCreate, "", user.Name+" ("+string(user.Role)+")")
+
+	writeJSON(w, http.StatusCreated, APIResponse{
+		Success: true,
+		Data:    user.Public(),
+	})
+}
+
+// handleDeleteUser removes an account and signs it out everywhere
+func (s *Server) handleDeleteUser(w http.ResponseWriter, r *http.Request) {
+	if s.users == nil {
+		writeError(w, http.StatusInternalServerError, "User manager not initialized")
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	user, err := s.users.Get(id)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	if err := s.users.Delete(id); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, users.ErrLastAdmin) {
+			status = http.StatusBadRequest
+		}
+		writeError(w, status, err.Error())
+		return
+	}
+	s.sessions.RevokeUser(id)
+
+	s.userRecentsMu.Lock()
+	delete(s.userRecents, id)
+	s.userRecentsMu.Unlock()
+
+	s.recordAudit(r, audit.ActionUserDelete, "", user.Name)
+
+	writeJSON(w, http.StatusOK, APIResponse{Success: true})
+}
+
+// handleLoginPage serves the sign-in form
+func (s *Server) handleLoginPage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	s.setDocumentPolicy(w)
+	w.Write([]byte(loginPage))
+}
+
+const loginPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Sign in - Inkwell</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", sans-serif; background: #f5f5f5; display: flex; align-items: center; justify-content: center; min-height: 100vh; margin: 0; }
+form { background: #fff; padding: 2rem; border-radius: 8px; box-shadow: 0 2px 8px rgba(0,0,0,0.1); width: 280px; }
+h1 { font-size: 1.25rem; margin: 0 0 1rem; }
+label { display: block; font-size: 0.875rem; margin-top: 0.75rem; }
+input { width: 100%; box-sizing: border-box; padding: 0.5rem; margin-top: 0.25rem; border: 1px solid #ccc; border-radius: 4px; }
+button { width: 100%; margin-top: 1.25rem; padding: 0.6rem; border: 0; border-radius: 4px; background: #2563eb; color: #fff; cursor: pointer; }
+#error { color: #b91c1c; font-size: 0.875rem; min-height: 1.25rem; margin-top: 0.75rem; }
+</style>
+</head>
+<body>
+<form id="login">
+<h1>Sign in to Inkwell</h1>
+<label>Name <input name="name" autocomplete="username" required autofocus></label>
+<label>Password <input name="password" type="password" autocomplete="current-password" required></label>
+<button type="submit">Sign in</button>
+<div id="error"></div>
+</form>
+<script>
+document.getElementById('login').addEventListener('submit', async (e) => {
+  e.preventDefault();
+  const form = new FormData(e.target);
+  const res = await fetch('/api/auth/login', {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ name: form.get('name'), password: form.get('password') }),
+  });
+  const body = await res.json();
+  if (body.success) {
+    window.location.href = '/';
+  } else {
+    document.getElementById('error').textContent = body.error;
+  }
+});
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,32 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"inkwell/internal/apikeys"
+	"inkwell/internal/users"
+)
+
+func TestAdminOnlyRoutes(t *testing.T) {
+	editor := &users.User{Name: "sam", Role: users.RoleEditor}
+	admin := &users.User{Name: "alex", Role: users.RoleAdmin}
+	filesKey := &apikeys.Key{Scopes: []apikeys.Scope{apikeys.ScopeFiles, apikeys.ScopeGit}}
+	adminKey := &apikeys.Key{Scopes: []apikeys.Scope{apikeys.ScopeAdmin}}
+
+	for _, route := range []struct{ method, path string }{
+		{http.MethodGet, "/api/directories"},
+		{http.MethodPost, "/api/directories"},
+		{http.MethodPost, "/api/assets/migrate"},
+		{http.MethodPost, "/api/vault/init"},
+	} {
+		r := httptest.NewRequest(route.method, route.path, nil)
+		if userAllows(editor, r) || keyAllows(filesKey, r) {
+			t.Errorf("%s %s: expected editors and files keys refused", route.method, route.path)
+		}
+		if !userAllows(admin, r) || !keyAllows(adminKey, r) {
+			t.Errorf("%s %s: expected admins allowed", route.method, route.path)
+		}
+	}
+}
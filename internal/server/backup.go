@@ -0,0 +1,68 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"inkwell/internal/audit"
+	"inkwell/internal/backup"
+
+	"github.com/gorilla/mux"
+)
+
+// scheduledBackups backs up the current vault once every interval has
+// passed since its newest backup, until the server shuts down. Backups
+// left by earlier runs count, so restarting doesn't back up again early.
+func (s *Server) scheduledBackups(interval time.Duration) {
+	for {
+		wait := interval
+		if last := s.backup.Status(s.workspace().rootDir).LastBackup; !last.IsZero() {
+			wait = max(interval-time.Since(last), 0)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-s.stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+			s.runBackup()
+		}
+	}
+}
+
+// runBackup backs up the current vault and sends clients the new status
+func (s *Server) runBackup() (backup.Status, error) {
+	rootDir := s.workspace().rootDir
+	status, err := s.backup.Run(rootDir)
+	if err != nil {
+		slog.Warn("Failed to back up vault", "path", rootDir, "error", err)
+	}
+	s.hub.BroadcastBackupStatus(status)
+	return status, err
+}
+
+// registerBackupRoutes serves the backup status. Only registered with
+// --backup-dir.
+func (s *Server) registerBackupRoutes(api *mux.Router) {
+	api.HandleFunc("/backup", s.handleBackupStatus).Methods("GET")
+	api.HandleFunc("/backup/now", s.handleBackupNow).Methods("POST")
+}
+
+// handleBackupStatus returns when the current vault was last backed up
+func (s *Server) handleBackupStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: s.backup.Status(s.workspace().rootDir)})
+}
+
+// handleBackupNow backs up the current vault without waiting for the
+// schedule
+func (s *Server) handleBackupNow(w http.ResponseWriter, r *http.Request) {
+	status, err := s.runBackup()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to back up vault: "+err.Error())
+		return
+	}
+	s.recordAudit(r, audit.ActionBackup, "", status.LastFile)
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: status})
+}
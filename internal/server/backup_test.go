@@ -0,0 +1,62 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"inkwell/internal/config"
+)
+
+func TestBackups(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "notes.md"), []byte("# Notes\n"), 0644)
+	dest := t.TempDir()
+
+	srv := newTestServer(t, dir, func(cfg *config.Config) {
+		cfg.BackupDir = dest
+		cfg.BackupInterval = time.Hour
+		cfg.BackupKeep = 1
+	})
+
+	for i := 0; i < 2; i++ {
+		if i > 0 {
+			time.Sleep(time.Second) // Backups are named to the second
+		}
+		rec := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/backup/now", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Backup failed: %d %s", rec.Code, rec.Body.String())
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/backup", nil))
+	var resp struct {
+		Data struct {
+			Enabled    bool      `json:"enabled"`
+			LastBackup time.Time `json:"lastBackup"`
+			LastFile   string    `json:"lastFile"`
+			Backups    int       `json:"backups"`
+		} `json:"data"`
+	}
+	json.NewDecoder(rec.Body).Decode(&resp)
+	if !resp.Data.Enabled || resp.Data.Backups != 1 || resp.Data.LastBackup.IsZero() {
+		t.Errorf("Expected 1 backup kept, got %s", rec.Body.String())
+	}
+	if filepath.Dir(resp.Data.LastFile) != dest {
+		t.Errorf("Expected the backup in %s, got %s", dest, resp.Data.LastFile)
+	}
+
+	// Without a backup folder there is nothing to trigger
+	plain := newTestServer(t, dir)
+	rec = httptest.NewRecorder()
+	plain.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/backup/now", nil))
+	if rec.Code == http.StatusOK {
+		t.Errorf("Expected no backups without a folder, got %d", rec.Code)
+	}
+}
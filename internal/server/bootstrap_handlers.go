@@ -0,0 +1,86 @@
+package server
+
+import (
+	"net/http"
+	"sync"
+
+	"inkwell/internal/filesystem"
+	"inkwell/internal/session"
+	"inkwell/internal/settings"
+)
+
+// Bootstrap is everything the web UI loads before its first paint
+type Bootstrap struct {
+	Config   map[string]interface{} `json:"config"`             // As /api/config
+	Tree     *filesystem.FileNode   `json:"tree,omitempty"`     // First level only; /api/tree has the rest
+	Git      map[string]interface{} `json:"git,omitempty"`      // As /api/git/status
+	Start    StartPage              `json:"start"`              // Recent locations and files, as /api/start
+	Settings *settings.Settings     `json:"settings,omitempty"` // As /api/settings
+	Session  *session.Session       `json:"session,omitempty"`  // As /api/session
+
+	// Errors holds what went wrong loading a part, by its field name, so
+	// the rest can still be used
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// handleBootstrap returns the configuration, the top of the file tree, the
+// git status, recents, settings and session in one response, loading the
+// slower parts at the same time
+func (s *Server) handleBootstrap(w http.ResponseWriter, r *http.Request) {
+	ws := s.workspace()
+	boot := Bootstrap{Config: s.clientConfig(ws)}
+
+	var mu sync.Mutex
+	fail := func(part string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if boot.Errors == nil {
+			boot.Errors = make(map[string]string)
+		}
+		boot.Errors[part] = err.Error()
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		tree, err := ws.files(requestActor(r)).GetTree()
+		if err != nil {
+			fail("tree", err)
+			return
+		}
+		boot.Tree = filesystem.CutTree(tree, 1)
+	}()
+	go func() {
+		defer wg.Done()
+		if s.config.NoGit {
+			return
+		}
+		git, err := s.gitStatusData(r, statusQuery{})
+		if err != nil {
+			fail("git", err)
+			return
+		}
+		boot.Git = git
+	}()
+	go func() {
+		defer wg.Done()
+		boot.Start = s.startPage(r, ws)
+	}()
+
+	if s.settings != nil {
+		current := s.settings.Get()
+		boot.Settings = &current
+	}
+	if sess, err := session.Load(ws.rootDir); err != nil {
+		fail("session", err)
+	} else {
+		boot.Session = sess
+	}
+	wg.Wait()
+
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    boot,
+	})
+}
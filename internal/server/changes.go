@@ -0,0 +1,21 @@
+package server
+
+import (
+	"net/http"
+	"time"
+)
+
+// touch records that something was changed, postponing background syncs
+func (s *Server) touch() {
+	s.lastChange.Store(time.Now().UnixNano())
+}
+
+// trackChanges postpones background syncs while requests change things
+func (s *Server) trackChanges(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			s.touch()
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,234 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"inkwell/internal/audit"
+	"inkwell/internal/config"
+	"inkwell/internal/filesystem"
+	"inkwell/internal/hooks"
+	"inkwell/internal/uploads"
+
+	"github.com/gorilla/mux"
+)
+
+// recommendedChunkSize is suggested to clients; chunks must also fit
+// within the request body limit
+const recommendedChunkSize = 5 << 20 // 5MB
+
+// ChunkedUploadRequest starts a resumable upload
+type ChunkedUploadRequest struct {
+	Path   string `json:"path"`             // Destination relative to the root directory
+	Size   int64  `json:"size"`             // Total size in bytes
+	SHA256 string `json:"sha256,omitempty"` // Optional hex digest verified on completion
+}
+
+// handleCreateChunkedUpload starts a resumable upload. Clients then PUT
+// chunks to /api/uploads/{id} with an Upload-Offset header (and optionally
+// an Upload-Checksum header holding the chunk's hex SHA-256), and can GET
+// the upload to find where to resume after a dropped connection.
+func (s *Server) handleCreateChunkedUpload(w http.ResponseWriter, r *http.Request) {
+	if s.uploads == nil {
+		writeError(w, http.StatusInternalServerError, "Upload manager not initialized")
+		return
+	}
+
+	var req ChunkedUploadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	req.Path = path.Clean(strings.TrimPrefix(filepath.ToSlash(req.Path), "/"))
+	if req.Path == "." || req.Path == "" {
+		writeError(w, http.StatusBadRequest, "Path is required")
+		return
+	}
+
+	maxSize := s.config.MaxChunkedSize
+	if maxSize <= 0 {
+		maxSize = config.DefaultMaxChunkedSize
+	}
+	if req.Size > maxSize {
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Upload exceeds the %s limit", formatSize(maxSize)))
+		return
+	}
+
+	fs := s.workspace().files(requestActor(r))
+	dest, err := fs.ResolvePath(req.Path)
+	if err != nil {
+		writeFileError(w, http.StatusBadRequest, "", err)
+		return
+	}
+	if !fs.CanWrite(req.Path) {
+		writeError(w, http.StatusForbidden, "You may not change "+req.Path)
+		return
+	}
+	if _, err := os.Stat(dest); err == nil {
+		writeError(w, http.StatusConflict, "A file already exists at "+req.Path)
+		return
+	}
+
+	upload, err := s.uploads.Create(requestActor(r), req.Path, req.Size, req.SHA256)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"upload":    upload,
+			"chunkSize": s.chunkSize(),
+		},
+	})
+}
+
+// chunkSize returns the chunk size clients should send
+func (s *Server) chunkSize() int64 {
+	limit := s.config.MaxBodySize
+	if limit <= 0 {
+		limit = config.DefaultMaxBodySize
+	}
+	return min(int64(recommendedChunkSize), limit)
+}
+
+// handleGetChunkedUpload reports how much of an upload has been received
+func (s *Server) handleGetChunkedUpload(w http.ResponseWriter, r *http.Request) {
+	if s.uploads == nil {
+		writeError(w, http.StatusInternalServerError, "Upload manager not initialized")
+		return
+	}
+
+	upload, err := s.uploads.Get(requestActor(r), mux.Vars(r)["id"])
+	if err != nil {
+		writeUploadError(w, err, nil)
+		return
+	}
+
+	w.Header().Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    upload,
+	})
+}
+
+// handleAppendChunk writes a chunk and, once all bytes have arrived,
+// verifies the upload and moves it into place, in turn with saves to the
+// same path. Only whoever started an upload may add to it.
+func (s *Server) handleAppendChunk(w http.ResponseWriter, r *http.Request) {
+	if s.uploads == nil {
+		writeError(w, http.StatusInternalServerError, "Upload manager not initialized")
+		return
+	}
+
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		writeError(w, http.StatusBadRequest, "Upload-Offset header is required")
+		return
+	}
+
+	actor := requestActor(r)
+	id := mux.Vars(r)["id"]
+	upload, err := s.uploads.Append(actor, id, offset, r.Body, r.Header.Get("Upload-Checksum"))
+	if err != nil {
+		if isTooLarge(err) {
+			writeTooLarge(w, s.bodyLimit(r))
+			return
+		}
+		writeUploadError(w, err, upload)
+		return
+	}
+
+	w.Header().Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	if !upload.Complete() {
+		writeJSON(w, http.StatusOK, APIResponse{
+			Success: true,
+			Data:    upload,
+		})
+		return
+	}
+
+	ws := s.workspace()
+	fs := ws.files(actor)
+	dest, err := fs.ResolvePath(upload.Path)
+	if err == nil && !fs.CanWrite(upload.Path) {
+		err = fmt.Errorf("%s: %w", upload.Path, filesystem.ErrAccessDenied)
+	}
+	if err != nil {
+		s.uploads.Abort(actor, id)
+		writeFileError(w, http.StatusBadRequest, "", err)
+		return
+	}
+
+	var sealErr error
+	s.saves.do(saveKey(ws, upload.Path), func() {
+		if _, err = s.uploads.Finish(actor, id, dest); err == nil {
+			sealErr = fs.SealFile(upload.Path)
+		}
+	})
+	if err != nil {
+		s.noteRejected(r, upload.Path, err)
+		writeUploadError(w, err, upload)
+		return
+	}
+	if sealErr != nil {
+		writeError(w, http.StatusInternalServerError, sealErr.Error())
+		return
+	}
+	s.recordAudit(r, audit.ActionFileUpload, upload.Path, formatSize(upload.Size))
+
+	writeJSON(w, http.StatusCreated, APIResponse{
+		Success: true,
+		Data:    upload,
+	})
+}
+
+// handleAbortChunkedUpload discards a staged upload
+func (s *Server) handleAbortChunkedUpload(w http.ResponseWriter, r *http.Request) {
+	if s.uploads == nil {
+		writeError(w, http.StatusInternalServerError, "Upload manager not initialized")
+		return
+	}
+
+	if err := s.uploads.Abort(requestActor(r), mux.Vars(r)["id"]); err != nil {
+		writeUploadError(w, err, nil)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, APIResponse{Success: true})
+}
+
+// writeUploadError maps upload errors to status codes. Offset mismatches
+// include the current state so the client can resume.
+func writeUploadError(w http.ResponseWriter, err error, upload *uploads.Upload) {
+	switch {
+	case errors.Is(err, uploads.ErrNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, uploads.ErrExists):
+		writeError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, uploads.ErrOffsetMismatch):
+		w.Header().Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+		writeJSON(w, http.StatusConflict, APIResponse{
+			Success: false,
+			Error:   err.Error(),
+			Data:    upload,
+		})
+	case errors.Is(err, uploads.ErrTooLarge):
+		writeError(w, http.StatusRequestEntityTooLarge, err.Error())
+	case errors.Is(err, uploads.ErrChecksumMismatch):
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+	case errors.As(err, new(*hooks.ScanError)):
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	}
+}
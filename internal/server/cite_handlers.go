@@ -0,0 +1,93 @@
+package server
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"inkwell/internal/cite"
+)
+
+// bibCache holds the vault's parsed bibliography until its file changes
+type bibCache struct {
+	mu      sync.Mutex
+	path    string
+	modTime time.Time
+	size    int64
+	bib     *cite.Bibliography
+}
+
+// bibliography returns the vault's bibliography, reparsing it when the
+// file changes. It is nil without an error when the vault has none.
+func (ws *workspace) bibliography() (*cite.Bibliography, error) {
+	path, err := cite.Find(ws.fs, ws.vault.Export.Bibliography)
+	if errors.Is(err, cite.ErrNoBibliography) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	full, err := ws.fs.ResolvePath(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(full)
+	if err != nil {
+		return nil, err
+	}
+
+	c := ws.bib
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.bib != nil && c.path == path && c.modTime.Equal(info.ModTime()) && c.size == info.Size() {
+		return c.bib, nil
+	}
+	bib, err := cite.Load(ws.fs, path)
+	if err != nil {
+		return nil, err
+	}
+	c.path, c.modTime, c.size, c.bib = path, info.ModTime(), info.Size(), bib
+	return bib, nil
+}
+
+// exportBibliography returns the bibliography to resolve citations against
+// when exporting. One that fails to load is logged and left out, so a typo
+// in references.bib doesn't stop notes from exporting.
+func (ws *workspace) exportBibliography() *cite.Bibliography {
+	bib, err := ws.bibliography()
+	if err != nil {
+		slog.Warn("Failed to load bibliography", "error", err)
+		return nil
+	}
+	return bib
+}
+
+// handleBibliography searches the vault's bibliography for citation
+// autocomplete. Vaults without one get no entries.
+func (s *Server) handleBibliography(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit := 0
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		limit = n
+	}
+
+	bib, err := s.workspace().bibliography()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load bibliography: "+err.Error())
+		return
+	}
+
+	entries := []cite.Entry{}
+	if bib != nil {
+		entries = bib.Search(query.Get("q"), limit)
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: entries})
+}
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBibliography(t *testing.T) {
+	dir := t.TempDir()
+	bib := "@article{doe2019, author = {Doe, Ann}, title = {Linking Notes}, year = {2019}}\n"
+	os.WriteFile(filepath.Join(dir, "references.bib"), []byte(bib), 0644)
+	os.WriteFile(filepath.Join(dir, "paper.md"), []byte("As @doe2019 shows.\n"), 0644)
+
+	srv := newTestServer(t, dir)
+
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/bibliography?q=link", nil))
+	var resp struct {
+		Data []struct {
+			Key string `json:"key"`
+		} `json:"data"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if rec.Code != http.StatusOK || len(resp.Data) != 1 || resp.Data[0].Key != "doe2019" {
+		t.Errorf("Unexpected search result %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/export/html?path=paper.md", nil))
+	if body := rec.Body.String(); !strings.Contains(body, "Doe (2019)") || !strings.Contains(body, `id="ref-doe2019"`) {
+		t.Errorf("Export should resolve citations:\n%s", body)
+	}
+}
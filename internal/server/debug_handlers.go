@@ -0,0 +1,82 @@
+package server
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"time"
+
+	"inkwell/internal/git"
+
+	"github.com/gorilla/mux"
+)
+
+// processStart is when the process started, for reporting uptime
+var processStart = time.Now()
+
+// DebugStats is a snapshot of the server's runtime state
+type DebugStats struct {
+	Uptime       string         `json:"uptime"`
+	Goroutines   int            `json:"goroutines"`
+	CPUs         int            `json:"cpus"`
+	HeapAlloc    uint64         `json:"heapAlloc"`   // Bytes of live heap objects
+	HeapInuse    uint64         `json:"heapInuse"`   // Bytes in in-use heap spans
+	HeapObjects  uint64         `json:"heapObjects"` // Live heap objects
+	Sys          uint64         `json:"sys"`         // Bytes obtained from the OS
+	NumGC        uint32         `json:"numGC"`
+	GCPauseTotal string         `json:"gcPauseTotal"`
+	WatchedDirs  int            `json:"watchedDirs"` // Directories the file watcher is watching
+	HubClients   int            `json:"hubClients"`  // Connected WebSocket clients
+	HistoryCache git.CacheStats `json:"historyCache"`
+}
+
+// registerDebugRoutes mounts pprof at /debug/pprof/ and runtime stats at
+// /api/debug/stats. Both are only served with --debug.
+func (s *Server) registerDebugRoutes(api *mux.Router) {
+	api.HandleFunc("/debug/stats", s.handleDebugStats).Methods("GET")
+
+	debug := s.router.PathPrefix("/debug/pprof").Subrouter()
+	debug.Use(noWriteDeadline)
+	debug.HandleFunc("/cmdline", pprof.Cmdline)
+	debug.HandleFunc("/profile", pprof.Profile)
+	debug.HandleFunc("/symbol", pprof.Symbol)
+	debug.HandleFunc("/trace", pprof.Trace)
+	debug.PathPrefix("/").HandlerFunc(pprof.Index)
+}
+
+// noWriteDeadline lifts the server's WriteTimeout, as CPU profiles and
+// traces take as long as the caller asks for
+func noWriteDeadline(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleDebugStats returns goroutine, memory, watcher and client counts
+func (s *Server) handleDebugStats(w http.ResponseWriter, r *http.Request) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	stats := DebugStats{
+		Uptime:       time.Since(processStart).Round(time.Second).String(),
+		Goroutines:   runtime.NumGoroutine(),
+		CPUs:         runtime.NumCPU(),
+		HeapAlloc:    mem.HeapAlloc,
+		HeapInuse:    mem.HeapInuse,
+		HeapObjects:  mem.HeapObjects,
+		Sys:          mem.Sys,
+		NumGC:        mem.NumGC,
+		GCPauseTotal: time.Duration(mem.PauseTotalNs).String(),
+		HubClients:   s.hub.ClientCount(),
+		HistoryCache: git.HistoryCacheStats(),
+	}
+	if watcher := s.workspace().watcher; watcher != nil {
+		stats.WatchedDirs = watcher.WatchCount()
+	}
+
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    stats,
+	})
+}
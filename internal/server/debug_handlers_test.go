@@ -0,0 +1,35 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"inkwell/internal/config"
+)
+
+func TestDebugEndpoints(t *testing.T) {
+	dir := t.TempDir()
+	srv := newTestServer(t, dir, func(cfg *config.Config) { cfg.Debug = true })
+
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/debug/stats", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"goroutines"`) {
+		t.Errorf("Expected runtime stats, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/goroutine?debug=1", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "goroutine profile") {
+		t.Errorf("Expected a goroutine profile, got %d", rec.Code)
+	}
+
+	plain := newTestServer(t, dir)
+
+	rec = httptest.NewRecorder()
+	plain.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/debug/stats", nil))
+	if strings.Contains(rec.Body.String(), `"goroutines"`) {
+		t.Error("Expected no debug stats without --debug")
+	}
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"encoding/json"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"inkwell/internal/desktop"
+)
+
+// handleOpenExternal opens a file in the default application of the
+// machine running Inkwell
+func (s *Server) handleOpenExternal(w http.ResponseWriter, r *http.Request) {
+	s.launchDesktop(w, r, desktop.Open)
+}
+
+// handleReveal shows a file or folder in the system file manager
+func (s *Server) handleReveal(w http.ResponseWriter, r *http.Request) {
+	s.launchDesktop(w, r, desktop.Reveal)
+}
+
+// launchDesktop resolves the requested path and hands it to launch. Only
+// clients on the same machine may launch applications, since they would
+// appear on the server's screen, and only from Inkwell's own pages: any
+// site open in a local browser can reach the loopback interface too.
+func (s *Server) launchDesktop(w http.ResponseWriter, r *http.Request, launch func(string) error) {
+	if !isLocalRequest(r) {
+		writeError(w, http.StatusForbidden, "Only available to clients on the same machine")
+		return
+	}
+	if !isSameOriginRequest(r) {
+		writeError(w, http.StatusForbidden, "Only available to Inkwell's own pages")
+		return
+	}
+
+	var req FileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	fullPath, err := s.workspace().files(requestActor(r)).ResolvePath(req.Path)
+	if err != nil {
+		writeFileError(w, http.StatusBadRequest, "", err)
+		return
+	}
+	if _, err := os.Stat(fullPath); err != nil {
+		writeError(w, http.StatusNotFound, "File not found")
+		return
+	}
+
+	if err := launch(fullPath); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to launch application: "+err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    map[string]string{"path": req.Path},
+	})
+}
+
+// isLocalRequest reports whether a request came from the loopback interface
+// and was addressed to a loopback name. A page whose DNS name was rebound to
+// 127.0.0.1 still sends its own name as the Host.
+func isLocalRequest(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback() && isLoopbackHost(r.Host)
+}
+
+// isLoopbackHost reports whether host, with or without a port, names the
+// loopback interface
+func isLoopbackHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.Trim(host, "[]"), ".")
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// isSameOriginRequest reports whether a request was made by a page served
+// from this server. Cross-site pages can send simple requests without a
+// preflight, but not with a JSON body, and browsers mark where they came
+// from with Origin and Sec-Fetch-Site.
+func isSameOriginRequest(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		return false
+	}
+	if site := r.Header.Get("Sec-Fetch-Site"); site != "" && site != "same-origin" {
+		return false
+	}
+	if origin := r.Header.Get("Origin"); origin != "" {
+		u, err := url.Parse(origin)
+		if err != nil || !strings.EqualFold(u.Host, r.Host) {
+			return false
+		}
+	}
+	return true
+}
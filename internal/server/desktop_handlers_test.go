@@ -0,0 +1,55 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLaunchDesktopRequiresLocalPage(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.md"), []byte("# A"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s := newTestServer(t, dir)
+
+	tests := []struct {
+		name        string
+		remoteAddr  string
+		host        string
+		contentType string
+		headers     map[string]string
+		path        string
+		want        int
+	}{
+		{name: "remote client", remoteAddr: "192.0.2.10:51234", host: "localhost:8000", contentType: "application/json", path: "a.md", want: http.StatusForbidden},
+		{name: "cross-origin text/plain", remoteAddr: "127.0.0.1:51234", host: "localhost:8000", contentType: "text/plain", headers: map[string]string{"Origin": "https://evil.example.com", "Sec-Fetch-Site": "cross-site"}, path: "a.md", want: http.StatusForbidden},
+		{name: "text/plain without origin", remoteAddr: "127.0.0.1:51234", host: "localhost:8000", contentType: "text/plain;charset=UTF-8", path: "a.md", want: http.StatusForbidden},
+		{name: "cross-origin JSON", remoteAddr: "127.0.0.1:51234", host: "localhost:8000", contentType: "application/json", headers: map[string]string{"Origin": "https://evil.example.com"}, path: "a.md", want: http.StatusForbidden},
+		{name: "cross-site fetch", remoteAddr: "127.0.0.1:51234", host: "localhost:8000", contentType: "application/json", headers: map[string]string{"Sec-Fetch-Site": "same-site"}, path: "a.md", want: http.StatusForbidden},
+		{name: "rebound DNS name", remoteAddr: "127.0.0.1:51234", host: "evil.example.com:8000", contentType: "application/json", headers: map[string]string{"Origin": "http://evil.example.com:8000"}, path: "a.md", want: http.StatusForbidden},
+		// A missing file gets past the checks without launching anything
+		{name: "own page", remoteAddr: "127.0.0.1:51234", host: "localhost:8000", contentType: "application/json", headers: map[string]string{"Origin": "http://localhost:8000", "Sec-Fetch-Site": "same-origin"}, path: "missing.md", want: http.StatusNotFound},
+		{name: "IPv6 loopback", remoteAddr: "[::1]:51234", host: "[::1]:8000", contentType: "application/json", headers: map[string]string{"Origin": "http://[::1]:8000"}, path: "missing.md", want: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		for _, endpoint := range []string{"/api/files/open-external", "/api/files/reveal"} {
+			req := httptest.NewRequest(http.MethodPost, endpoint, strings.NewReader(`{"path": "`+tt.path+`"}`))
+			req.RemoteAddr = tt.remoteAddr
+			req.Host = tt.host
+			req.Header.Set("Content-Type", tt.contentType)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+
+			rec := httptest.NewRecorder()
+			s.Handler().ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("%s: %s status %d, want %d", tt.name, endpoint, rec.Code, tt.want)
+			}
+		}
+	}
+}
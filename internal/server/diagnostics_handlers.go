@@ -0,0 +1,37 @@
+package server
+
+import (
+	"net/http"
+
+	"inkwell/internal/doctor"
+	"inkwell/internal/git"
+)
+
+// handleDiagnostics runs the same checks as `inkwell doctor` against the
+// running server
+func (s *Server) handleDiagnostics(w http.ResponseWriter, r *http.Request) {
+	opts := doctor.Options{
+		RootDir:    s.workspace().rootDir,
+		ConfigFile: s.config.ConfigFile,
+		Host:       s.config.Host,
+		Serving:    true,
+		NoGit:      s.config.NoGit,
+	}
+	if s.httpServer != nil {
+		// Mounted in another server, the port isn't ours to check
+		opts.Port = s.config.Port
+	}
+	if s.git != nil {
+		opts.ReposDir = s.git.ReposDir()
+	}
+
+	checks := doctor.Run(r.Context(), opts)
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"checks":       checks,
+			"failed":       doctor.Failed(checks),
+			"historyCache": git.HistoryCacheStats(),
+		},
+	})
+}
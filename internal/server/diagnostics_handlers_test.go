@@ -0,0 +1,23 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDiagnostics(t *testing.T) {
+	srv := newTestServer(t, t.TempDir())
+
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/diagnostics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	for _, want := range []string{`"name":"ssh"`, `"name":"vault config"`, `"failed":false`} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("Response %s missing %s", rec.Body.String(), want)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"inkwell/internal/audit"
+	"inkwell/internal/encryption"
+)
+
+// unlockIdleTime is how long a vault stays unlocked for someone who stops
+// using it
+const unlockIdleTime = 30 * time.Minute
+
+// errNoteLocked is returned when reading or saving an encrypted note
+// without having unlocked the vault
+var errNoteLocked = errors.New("This note is encrypted; unlock the vault to open it")
+
+// keyring holds the keys each actor has unlocked a vault with. Keys live
+// only in memory and go away with the workspace.
+type keyring struct {
+	mu   sync.Mutex
+	keys map[string]*unlockedKey // By actor
+}
+
+type unlockedKey struct {
+	key  *encryption.Key
+	used time.Time
+}
+
+func newKeyring() *keyring {
+	return &keyring{keys: make(map[string]*unlockedKey)}
+}
+
+// get returns actor's key, or nil if they haven't unlocked the vault or
+// left it idle too long
+func (k *keyring) get(actor string) *encryption.Key {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	u, ok := k.keys[actor]
+	if !ok {
+		return nil
+	}
+	if time.Since(u.used) > unlockIdleTime {
+		delete(k.keys, actor)
+		return nil
+	}
+	u.used = time.Now()
+	return u.key
+}
+
+func (k *keyring) put(actor string, key *encryption.Key) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.keys[actor] = &unlockedKey{key: key, used: time.Now()}
+}
+
+func (k *keyring) drop(actor string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	delete(k.keys, actor)
+}
+
+// readNote reads a note for actor, decrypting it if it is encrypted
+func (ws *workspace) readNote(actor, path string) (content string, encrypted bool, err error) {
+	content, err = ws.files(actor).ReadFile(path)
+	if err != nil || !encryption.IsEncrypted(content) {
+		return content, false, err
+	}
+
+	key := ws.keys.get(actor)
+	if key == nil {
+		return "", true, errNoteLocked
+	}
+	content, err = key.Decrypt(content)
+	return content, true, err
+}
+
+// writeNote saves a note for actor. Notes that are encrypted on disk stay
+// encrypted; their plaintext is never written.
+func (ws *workspace) writeNote(actor, path, content string) error {
+	fs := ws.files(actor)
+	existing, err := fs.ReadFile(path)
+	if err != nil || !encryption.IsEncrypted(existing) {
+		return fs.WriteFile(path, content)
+	}
+
+	key := ws.keys.get(actor)
+	if key == nil {
+		return errNoteLocked
+	}
+	sealed, err := key.Encrypt(content)
+	if err != nil {
+		return err
+	}
+	return fs.WriteFile(path, sealed)
+}
+
+// writeReadError reports a failure to read a note, asking for the
+// passphrase if the note is encrypted
+func writeReadError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errNoteLocked) {
+		writeError(w, http.StatusLocked, err.Error())
+		return
+	}
+	writeFileError(w, http.StatusNotFound, "Failed to read file: ", err)
+}
+
+// EncryptionStatus reports whether a vault uses encryption and whether the
+// requester has unlocked it
+type EncryptionStatus struct {
+	Configured bool `json:"configured"`
+	Unlocked   bool `json:"unlocked"`
+}
+
+// PassphraseRequest represents a request to set up or unlock encryption
+type PassphraseRequest struct {
+	Passphrase string `json:"passphrase"`
+}
+
+// handleEncryptionStatus returns the requester's encryption status
+func (s *Server) handleEncryptionStatus(w http.ResponseWriter, r *http.Request) {
+	ws := s.workspace()
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data: EncryptionStatus{
+			Configured: encryption.Configured(ws.rootDir),
+			Unlocked:   ws.keys.get(requestActor(r)) != nil,
+		},
+	})
+}
+
+// handleEncryptionSetup creates the vault key from a new passphrase and
+// unlocks the vault
+func (s *Server) handleEncryptionSetup(w http.ResponseWriter, r *http.Request) {
+	var req PassphraseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	ws := s.workspace()
+	key, err := encryption.Setup(ws.rootDir, req.Passphrase)
+	if errors.Is(err, encryption.ErrConfigured) {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Failed to set up encryption: "+err.Error())
+		return
+	}
+	ws.keys.put(requestActor(r), key)
+	s.recordAudit(r, audit.ActionFileCreate, encryption.KeyFile, "")
+
+	writeJSON(w, http.StatusCreated, APIResponse{
+		Success: true,
+		Data:    EncryptionStatus{Configured: true, Unlocked: true},
+	})
+}
+
+// handleEncryptionUnlock unlocks the vault for the requester
+func (s *Server) handleEncryptionUnlock(w http.ResponseWriter, r *http.Request) {
+	var req PassphraseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	ws := s.workspace()
+	key, err := encryption.Unlock(ws.rootDir, req.Passphrase)
+	switch {
+	case errors.Is(err, encryption.ErrNotConfigured):
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	case errors.Is(err, encryption.ErrWrongPassphrase):
+		// Not 401, which the UI takes to mean signing in again
+		writeError(w, http.StatusForbidden, err.Error())
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, "Failed to unlock: "+err.Error())
+		return
+	}
+	ws.keys.put(requestActor(r), key)
+
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    EncryptionStatus{Configured: true, Unlocked: true},
+	})
+}
+
+// handleEncryptionLock forgets the requester's key
+func (s *Server) handleEncryptionLock(w http.ResponseWriter, r *http.Request) {
+	ws := s.workspace()
+	ws.keys.drop(requestActor(r))
+
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    EncryptionStatus{Configured: encryption.Configured(ws.rootDir)},
+	})
+}
+
+// handleEncryptNote encrypts a note in place
+func (s *Server) handleEncryptNote(w http.ResponseWriter, r *http.Request) {
+	s.convertNote(w, r, true)
+}
+
+// handleDecryptNote stores an encrypted note as plaintext again
+func (s *Server) handleDecryptNote(w http.ResponseWriter, r *http.Request) {
+	s.convertNote(w, r, false)
+}
+
+func (s *Server) convertNote(w http.ResponseWriter, r *http.Request, encrypt bool) {
+	var req FileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Path == "" {
+		writeError(w, http.StatusBadRequest, "Path is required")
+		return
+	}
+
+	ws := s.workspace()
+	actor := requestActor(r)
+	key := ws.keys.get(actor)
+	if key == nil {
+		writeError(w, http.StatusLocked, "Unlock the vault first")
+		return
+	}
+
+	// Read and written in turn with saves, so none is lost in between
+	s.saves.do(saveKey(ws, req.Path), func() {
+		content, encrypted, err := ws.readNote(actor, req.Path)
+		if err != nil {
+			writeReadError(w, err)
+			return
+		}
+
+		if encrypt && !encrypted {
+			content, err = key.Encrypt(content)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, "Failed to encrypt note: "+err.Error())
+				return
+			}
+		}
+		if encrypt != encrypted {
+			if err := ws.files(requestActor(r)).WriteFile(req.Path, content); err != nil {
+				writeFileError(w, http.StatusInternalServerError, "Failed to update file: ", err)
+				return
+			}
+			s.recordAudit(r, audit.ActionFileWrite, req.Path, "")
+		}
+
+		writeJSON(w, http.StatusOK, APIResponse{
+			Success: true,
+			Data: map[string]interface{}{
+				"path":      req.Path,
+				"encrypted": encrypt,
+			},
+		})
+	})
+}
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"inkwell/internal/config"
+)
+
+func TestEncryptedNotes(t *testing.T) {
+	dir := t.TempDir()
+	notePath := filepath.Join(dir, "health.md")
+	if err := os.WriteFile(notePath, []byte("# Health\n\nBlood type O\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := newTestServer(t, dir)
+
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return rec
+	}
+	onDisk := func() string {
+		data, err := os.ReadFile(notePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	if rec := do(http.MethodPost, "/api/encryption/encrypt", `{"path":"health.md"}`); rec.Code != http.StatusLocked {
+		t.Errorf("Expected 423 encrypting before setup, got %d", rec.Code)
+	}
+	if rec := do(http.MethodPost, "/api/encryption/setup", `{"passphrase":"correct horse"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("Setup failed: %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := do(http.MethodPost, "/api/encryption/encrypt", `{"path":"health.md"}`); rec.Code != http.StatusOK {
+		t.Fatalf("Encrypt failed: %d %s", rec.Code, rec.Body.String())
+	}
+	if strings.Contains(onDisk(), "Blood") {
+		t.Fatal("Expected the note to be stored encrypted")
+	}
+
+	rec := do(http.MethodGet, "/api/files?path=health.md", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "Blood type O") || !strings.Contains(rec.Body.String(), `"encrypted":true`) {
+		t.Errorf("Expected the decrypted note, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// Saving keeps the note encrypted
+	if rec := do(http.MethodPut, "/api/files?path=health.md", `{"content":"# Health\n\nBlood type AB\n"}`); rec.Code != http.StatusOK {
+		t.Fatalf("Update failed: %d %s", rec.Code, rec.Body.String())
+	}
+	if strings.Contains(onDisk(), "Blood") {
+		t.Fatal("Expected the saved note to stay encrypted")
+	}
+
+	do(http.MethodPost, "/api/encryption/lock", "")
+	sealed := onDisk()
+	for _, target := range []string{"/api/files?path=health.md", "/api/render?path=health.md", "/print?path=health.md"} {
+		if rec := do(http.MethodGet, target, ""); rec.Code != http.StatusLocked || strings.Contains(rec.Body.String(), "Blood") {
+			t.Errorf("Expected 423 from %s while locked, got %d", target, rec.Code)
+		}
+	}
+	if rec := do(http.MethodPut, "/api/files?path=health.md", `{"content":"plaintext"}`); rec.Code != http.StatusLocked {
+		t.Errorf("Expected 423 saving while locked, got %d", rec.Code)
+	}
+	if onDisk() != sealed {
+		t.Error("Expected the note to be unchanged after a refused save")
+	}
+
+	if rec := do(http.MethodPost, "/api/encryption/unlock", `{"passphrase":"wrong horse"}`); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for the wrong passphrase, got %d", rec.Code)
+	}
+	if rec := do(http.MethodPost, "/api/encryption/unlock", `{"passphrase":"correct horse"}`); rec.Code != http.StatusOK {
+		t.Fatalf("Unlock failed: %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := do(http.MethodPost, "/api/encryption/decrypt", `{"path":"health.md"}`); rec.Code != http.StatusOK {
+		t.Fatalf("Decrypt failed: %d %s", rec.Code, rec.Body.String())
+	}
+	if got := onDisk(); got != "# Health\n\nBlood type AB\n" {
+		t.Errorf("Expected the note stored as plaintext again, got %q", got)
+	}
+}
+
+// encryptedVault configures the server as --encrypt-vault unlocked with
+// passphrase
+func encryptedVault(passphrase string) func(*config.Config) {
+	return func(cfg *config.Config) {
+		cfg.EncryptVault = true
+		cfg.Passphrase = passphrase
+	}
+}
+
+func TestEncryptedVault(t *testing.T) {
+	dir := t.TempDir()
+	notePath := filepath.Join(dir, "finances.md")
+	if err := os.WriteFile(notePath, []byte("# Finances\n\nSavings 1234\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := newTestServer(t, dir, encryptedVault("correct horse"))
+
+	onDisk := func() string {
+		data, err := os.ReadFile(notePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	if strings.Contains(onDisk(), "Savings") {
+		t.Fatal("Expected existing notes to be encrypted at startup")
+	}
+
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/files?path=finances.md", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "Savings 1234") {
+		t.Errorf("Expected the decrypted note, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/api/files?path=finances.md",
+		strings.NewReader(`{"content":"# Finances\n\nSavings 5678\n"}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Update failed: %d %s", rec.Code, rec.Body.String())
+	}
+	if strings.Contains(onDisk(), "Savings") {
+		t.Error("Expected saved notes to be encrypted")
+	}
+
+	rec = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/raw/finances.md", nil))
+	if rec.Body.String() != "# Finances\n\nSavings 5678\n" {
+		t.Errorf("Expected raw serving to decrypt, got %q", rec.Body.String())
+	}
+
+	cfg, err := config.New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encryptedVault("wrong horse")(cfg)
+	if _, err := New(cfg, nil); err == nil {
+		t.Error("Expected New to fail with the wrong passphrase")
+	}
+}
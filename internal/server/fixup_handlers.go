@@ -0,0 +1,111 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"inkwell/internal/audit"
+	"inkwell/internal/fixup"
+)
+
+// FixupRequest applies a fix-up to a note. With Content set the fixed text
+// is returned for the editor to apply; otherwise the saved note is fixed
+// in place.
+type FixupRequest struct {
+	Path    string  `json:"path"`
+	Fix     string  `json:"fix"`
+	Content *string `json:"content,omitempty"`
+}
+
+// FixupResult is a note after a fix-up
+type FixupResult struct {
+	Content string        `json:"content"`
+	Changes int           `json:"changes"`
+	Saved   bool          `json:"saved"`
+	Issues  []fixup.Issue `json:"issues"` // Left after the fix
+}
+
+// handleCheckFixups reports the footnote and reference link problems in a
+// note, with the fix-ups available for it
+func (s *Server) handleCheckFixups(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		writeError(w, http.StatusBadRequest, "Path parameter is required")
+		return
+	}
+
+	content, _, err := s.workspace().readNote(requestActor(r), path)
+	if err != nil {
+		writeReadError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"path":   path,
+			"issues": fixup.Check(content),
+			"fixes":  fixup.Fixes,
+		},
+	})
+}
+
+// handleApplyFixup runs a fix-up over a whole note
+func (s *Server) handleApplyFixup(w http.ResponseWriter, r *http.Request) {
+	var req FixupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	fix, ok := fixup.Lookup(req.Fix)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Unknown fix-up: "+req.Fix)
+		return
+	}
+
+	if req.Content != nil {
+		content, changes := fix.Apply(*req.Content)
+		writeJSON(w, http.StatusOK, APIResponse{
+			Success: true,
+			Data:    FixupResult{Content: content, Changes: changes, Issues: fixup.Check(content)},
+		})
+		return
+	}
+
+	if req.Path == "" {
+		writeError(w, http.StatusBadRequest, "Path or content is required")
+		return
+	}
+
+	s.touch()
+	ws := s.workspace()
+	actor := requestActor(r)
+	// Read and written in turn with saves, so none is lost in between
+	s.saves.do(saveKey(ws, req.Path), func() {
+		content, _, err := ws.readNote(actor, req.Path)
+		if err != nil {
+			writeReadError(w, err)
+			return
+		}
+
+		fixed, changes := fix.Apply(content)
+		result := FixupResult{Content: fixed, Changes: changes, Issues: fixup.Check(fixed)}
+		if changes > 0 {
+			if err := ws.writeNote(actor, req.Path, fixed); err != nil {
+				if errors.Is(err, errNoteLocked) {
+					writeError(w, http.StatusLocked, err.Error())
+					return
+				}
+				writeError(w, http.StatusInternalServerError, "Failed to save note: "+err.Error())
+				return
+			}
+			ws.index.Update(req.Path)
+			s.recordAudit(r, audit.ActionFileWrite, req.Path, "")
+			s.runSaveHooks(req.Path)
+			result.Saved = true
+		}
+
+		writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: result})
+	})
+}
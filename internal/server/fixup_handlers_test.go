@@ -0,0 +1,59 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFixups(t *testing.T) {
+	dir := t.TempDir()
+	note := "Claim[^b] and[^a].\n\n[^a]: A.\n[^b]: B.\n[^c]: Unused.\n"
+	os.WriteFile(filepath.Join(dir, "paper.md"), []byte(note), 0644)
+
+	srv := newTestServer(t, dir)
+
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/fixups?path=paper.md", nil))
+	var check struct {
+		Data struct {
+			Issues []struct {
+				Kind  string `json:"kind"`
+				Label string `json:"label"`
+			} `json:"issues"`
+			Fixes []struct {
+				Name string `json:"name"`
+			} `json:"fixes"`
+		} `json:"data"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &check)
+	if len(check.Data.Issues) != 1 || check.Data.Issues[0].Label != "c" || len(check.Data.Fixes) == 0 {
+		t.Errorf("Unexpected check %s", rec.Body.String())
+	}
+
+	// Unsaved editor content is fixed and returned
+	body := `{"fix": "renumber-footnotes", "content": "x[^z]\n\n[^z]: Z.\n"}`
+	rec = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/fixups", strings.NewReader(body)))
+	if !strings.Contains(rec.Body.String(), `"content":"x[^1]\n\n[^1]: Z.\n"`) || !strings.Contains(rec.Body.String(), `"saved":false`) {
+		t.Errorf("Unexpected fix of content %s", rec.Body.String())
+	}
+
+	// Otherwise the note is fixed on disk
+	rec = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/fixups", strings.NewReader(`{"path": "paper.md", "fix": "remove-unused"}`)))
+	saved, _ := os.ReadFile(filepath.Join(dir, "paper.md"))
+	if rec.Code != http.StatusOK || strings.Contains(string(saved), "[^c]") {
+		t.Errorf("Note not fixed (%d): %s", rec.Code, saved)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/fixups", strings.NewReader(`{"path": "paper.md", "fix": "tidy"}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown fix-up, got %d", rec.Code)
+	}
+}
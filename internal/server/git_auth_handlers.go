@@ -0,0 +1,136 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"inkwell/internal/audit"
+	"inkwell/internal/config"
+	"inkwell/internal/git/oauth"
+
+	"github.com/gorilla/mux"
+)
+
+// DeviceSignInRequest represents a request to sign in to a git host
+type DeviceSignInRequest struct {
+	Provider string `json:"provider"` // github or gitlab
+}
+
+// newOAuthProviders returns the providers an OAuth client ID is
+// configured for, by name
+func newOAuthProviders(cfg *config.Config) map[string]*oauth.Provider {
+	providers := make(map[string]*oauth.Provider)
+	if cfg.GitHubClientID != "" {
+		providers[oauth.GitHub] = oauth.NewGitHub(cfg.GitHubClientID)
+	}
+	if cfg.GitLabClientID != "" {
+		if p, err := oauth.NewGitLab(cfg.GitLabURL, cfg.GitLabClientID); err != nil {
+			slog.Warn("GitLab sign-in disabled", "error", err)
+		} else {
+			providers[oauth.GitLab] = p
+		}
+	}
+	return providers
+}
+
+// handleGitAuthList returns the hosts signed in to and the providers that
+// can be signed in to
+func (s *Server) handleGitAuthList(w http.ResponseWriter, r *http.Request) {
+	accounts := []oauth.Account{}
+	if s.oauthStore != nil {
+		var err error
+		if accounts, err = s.oauthStore.List(); err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to read sign-ins: "+err.Error())
+			return
+		}
+	}
+
+	providers := []map[string]string{}
+	for _, name := range []string{oauth.GitHub, oauth.GitLab} {
+		if p, ok := s.oauthProviders[name]; ok {
+			providers = append(providers, map[string]string{"name": p.Name, "host": p.Host})
+		}
+	}
+
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"accounts":  accounts,
+			"providers": providers,
+		},
+	})
+}
+
+// handleGitAuthStart starts signing in to GitHub or GitLab. The response
+// has the code the user enters on the provider's site; the sign-in then
+// finishes in the background.
+func (s *Server) handleGitAuthStart(w http.ResponseWriter, r *http.Request) {
+	var req DeviceSignInRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	p, ok := s.oauthProviders[req.Provider]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Sign-in is not configured for "+req.Provider+"; set --github-client-id or --gitlab-client-id")
+		return
+	}
+	if s.oauthFlows == nil {
+		writeError(w, http.StatusInternalServerError, "Sign-ins can't be stored")
+		return
+	}
+
+	flow, err := s.oauthFlows.Start(r.Context(), p)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, "Failed to start sign-in: "+err.Error())
+		return
+	}
+	s.recordAudit(r, audit.ActionGitSignIn, p.Host, p.Name)
+
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: flow})
+}
+
+// handleGitAuthStatus returns where a sign-in stands
+func (s *Server) handleGitAuthStatus(w http.ResponseWriter, r *http.Request) {
+	if s.oauthFlows == nil {
+		writeError(w, http.StatusNotFound, "Sign-in not found")
+		return
+	}
+	flow, ok := s.oauthFlows.Get(mux.Vars(r)["id"])
+	if !ok {
+		writeError(w, http.StatusNotFound, "Sign-in not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: flow})
+}
+
+// handleGitAuthCancel stops waiting for a sign-in
+func (s *Server) handleGitAuthCancel(w http.ResponseWriter, r *http.Request) {
+	if s.oauthFlows == nil || !s.oauthFlows.Cancel(mux.Vars(r)["id"]) {
+		writeError(w, http.StatusNotFound, "Sign-in not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true})
+}
+
+// handleGitAuthSignOut forgets the token for a host
+func (s *Server) handleGitAuthSignOut(w http.ResponseWriter, r *http.Request) {
+	host := mux.Vars(r)["host"]
+	if s.oauthStore == nil {
+		writeError(w, http.StatusNotFound, "Not signed in to "+host)
+		return
+	}
+	if err := s.oauthStore.Delete(host); err != nil {
+		if errors.Is(err, oauth.ErrNotSignedIn) {
+			writeError(w, http.StatusNotFound, "Not signed in to "+host)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "Failed to sign out: "+err.Error())
+		return
+	}
+	s.recordAudit(r, audit.ActionGitSignOut, host, "")
+
+	writeJSON(w, http.StatusOK, APIResponse{Success: true})
+}
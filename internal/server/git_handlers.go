@@ -1,53 +1,203 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
+	"inkwell/internal/audit"
 	"inkwell/internal/git"
 )
 
+// handleGitDisabled answers every git route when Inkwell runs with --no-git
+func (s *Server) handleGitDisabled(w http.ResponseWriter, r *http.Request) {
+	writeError(w, http.StatusNotFound, "Git integration is disabled")
+}
+
 // handleGitStatus returns the git status of the current repository
 func (s *Server) handleGitStatus(w http.ResponseWriter, r *http.Request) {
-	if s.git == nil {
-		writeJSON(w, http.StatusOK, APIResponse{
-			Success: true,
-			Data: map[string]interface{}{
-				"isRepo": false,
-			},
-		})
+	query, err := parseStatusQuery(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	data, err := s.gitStatusData(r, query)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to get git status: "+err.Error())
 		return
 	}
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    data,
+	})
+}
 
+// gitStatusData returns what GET /api/git/status answers: whether there is
+// a repository, its status as the requester may see it and the operation
+// running on it
+func (s *Server) gitStatusData(r *http.Request, query statusQuery) (map[string]interface{}, error) {
+	if s.git == nil {
+		return map[string]interface{}{"isRepo": false}, nil
+	}
 	repo := s.git.CurrentRepository()
 	if repo == nil {
-		writeJSON(w, http.StatusOK, APIResponse{
-			Success: true,
-			Data: map[string]interface{}{
-				"isRepo": false,
-			},
-		})
-		return
+		return map[string]interface{}{"isRepo": false}, nil
 	}
 
 	status, err := repo.Status()
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "Failed to get git status: "+err.Error())
-		return
+		return nil, err
 	}
 
 	// Add remote URL if available
 	status.RemoteURL = repo.GetRemoteURL()
+	filterGitStatus(status, s.gitGuard(r, repo))
+	s.narrowGitStatus(query, repo, status)
 
-	writeJSON(w, http.StatusOK, APIResponse{
-		Success: true,
-		Data: map[string]interface{}{
-			"isRepo": true,
-			"status": status,
-		},
+	data := map[string]interface{}{
+		"isRepo": true,
+		"status": status,
+	}
+	if op, since := s.git.Running(repo); op != "" {
+		data["operation"] = map[string]interface{}{"name": op, "since": since}
+	}
+	return data, nil
+}
+
+// statusQuery holds the options narrowing the files in a git status
+type statusQuery struct {
+	markdown bool // Only notes
+	ignored  bool // Leave out what the file tree leaves out
+	assets   bool // Leave out assets folders
+	group    bool // Group the files by directory
+}
+
+// parseStatusQuery reads the status query options: markdown=true keeps
+// only notes, exclude=ignored,assets drops files the file tree leaves out
+// or that are in an assets folder, and group=dir groups what is left by
+// directory
+func parseStatusQuery(values url.Values) (statusQuery, error) {
+	var query statusQuery
+	if v := values.Get("markdown"); v != "" {
+		var err error
+		if query.markdown, err = strconv.ParseBool(v); err != nil {
+			return query, fmt.Errorf("invalid markdown value: %s", v)
+		}
+	}
+	for _, v := range strings.Split(values.Get("exclude"), ",") {
+		switch strings.TrimSpace(v) {
+		case "":
+		case "ignored":
+			query.ignored = true
+		case "assets":
+			query.assets = true
+		default:
+			return query, fmt.Errorf("unknown exclude value: %s (use ignored or assets)", v)
+		}
+	}
+	switch group := values.Get("group"); group {
+	case "":
+	case "dir":
+		query.group = true
+	default:
+		return query, fmt.Errorf("unknown group value: %s (use dir)", group)
+	}
+	return query, nil
+}
+
+// narrowGitStatus applies the status query options to a status, counting
+// the files left out
+func (s *Server) narrowGitStatus(query statusQuery, repo *git.Repository, status *git.GitStatus) {
+	total := len(status.Files)
+	if query.markdown {
+		status.Files = git.FilterMarkdownFiles(status.Files)
+	}
+	if ws := s.workspace(); ws != nil && (query.ignored || query.assets) {
+		files := status.Files[:0]
+		for _, f := range status.Files {
+			rel, err := filepath.Rel(ws.rootDir, filepath.Join(repo.Path(), filepath.FromSlash(f.Path)))
+			inVault := err == nil && !strings.HasPrefix(rel, "..")
+			if inVault && (query.ignored && ws.fs.IsIgnoredPath(rel) || query.assets && ws.fs.IsAssetPath(rel)) {
+				continue
+			}
+			files = append(files, f)
+		}
+		status.Files = files
+	}
+	status.Filtered = total - len(status.Files)
+
+	if query.group {
+		status.Groups = git.GroupByDirectory(status.Files)
+	}
+}
+
+// gitQueueWait is how long a git request waits for the one before it
+// before being told the repository is busy
+const gitQueueWait = 10 * time.Second
+
+// serializeGit runs requests that change the current repository one at a
+// time. A request still waiting after gitQueueWait, or arriving when too
+// many are waiting, is answered 409 with the operation in progress.
+func (s *Server) serializeGit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		op := strings.TrimPrefix(r.URL.Path, "/api/git/")
+		// Init and clone make a repository rather than change one, a diff
+		// only reads, signing in doesn't touch the repository and a sync
+		// waits its turn itself
+		if s.git == nil || r.Method == http.MethodGet || r.Method == http.MethodHead || op == "init" || op == "clone" || op == "diff" || op == "sync" || op == "auth" || strings.HasPrefix(op, "auth/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		repo := s.git.CurrentRepository()
+		if repo == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), gitQueueWait)
+		release, err := s.git.Begin(ctx, repo, op)
+		cancel()
+		if err != nil {
+			writeGitBusy(w, err)
+			return
+		}
+		defer release()
+		if worktreeOps[op] {
+			s.saves.all(func() { next.ServeHTTP(w, r) })
+			return
+		}
+		next.ServeHTTP(w, r)
 	})
 }
 
+// worktreeOps are the git operations that rewrite notes in the worktree.
+// Saves wait for them, so one can't land between a save's version check
+// and its write.
+var worktreeOps = map[string]bool{
+	"pull":        true,
+	"checkout":    true,
+	"discard":     true,
+	"resolve":     true,
+	"revert":      true,
+	"cherry-pick": true,
+}
+
+// writeGitBusy answers a request that gave up waiting for another git
+// operation, telling the client to try again shortly
+func writeGitBusy(w http.ResponseWriter, err error) {
+	w.Header().Set("Retry-After", "5")
+	writeError(w, http.StatusConflict, err.Error())
+}
+
 // handleGitInit initializes a new git repository in the current directory
 func (s *Server) handleGitInit(w http.ResponseWriter, r *http.Request) {
 	if s.git == nil {
@@ -62,11 +212,12 @@ func (s *Server) handleGitInit(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Initialize the repository
-	rootDir := s.config.RootDir
+	rootDir := s.workspace().rootDir
 	if err := initGitRepository(rootDir); err != nil {
 		writeError(w, http.StatusInternalServerError, "Failed to initialize repository: "+err.Error())
 		return
 	}
+	s.recordAudit(r, audit.ActionGitInit, rootDir, "")
 
 	// Open the newly created repository
 	repo, err := s.git.OpenRepository(rootDir)
@@ -145,6 +296,7 @@ func (s *Server) handleGitClone(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusInternalServerError, "Clone failed: "+err.Error())
 		return
 	}
+	s.recordAudit(r, audit.ActionGitClone, result.Path, req.URL)
 
 	writeJSON(w, http.StatusOK, APIResponse{
 		Success: true,
@@ -220,6 +372,10 @@ func (s *Server) handleGitStage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !s.gitFiles(w, r, repo, &req.Files, &req.All) {
+		return
+	}
+
 	var err error
 	if req.All {
 		err = repo.StageAll()
@@ -234,6 +390,7 @@ func (s *Server) handleGitStage(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusInternalServerError, "Failed to stage: "+err.Error())
 		return
 	}
+	s.recordAudit(r, audit.ActionGitStage, "", filesSummary(req.Files, req.All))
 
 	// Return updated status
 	status, err := repo.Status()
@@ -241,6 +398,7 @@ func (s *Server) handleGitStage(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusInternalServerError, "Failed to get status: "+err.Error())
 		return
 	}
+	filterGitStatus(status, s.gitGuard(r, repo))
 
 	writeJSON(w, http.StatusOK, APIResponse{
 		Success: true,
@@ -270,6 +428,10 @@ func (s *Server) handleGitUnstage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !s.gitFiles(w, r, repo, &req.Files, &req.All) {
+		return
+	}
+
 	var err error
 	if req.All {
 		err = repo.UnstageAll()
@@ -284,6 +446,7 @@ func (s *Server) handleGitUnstage(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusInternalServerError, "Failed to unstage: "+err.Error())
 		return
 	}
+	s.recordAudit(r, audit.ActionGitUnstage, "", filesSummary(req.Files, req.All))
 
 	// Return updated status
 	status, err := repo.Status()
@@ -291,6 +454,7 @@ func (s *Server) handleGitUnstage(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusInternalServerError, "Failed to get status: "+err.Error())
 		return
 	}
+	filterGitStatus(status, s.gitGuard(r, repo))
 
 	writeJSON(w, http.StatusOK, APIResponse{
 		Success: true,
@@ -306,6 +470,7 @@ type CommitRequest struct {
 	Files       []string `json:"files,omitempty"`
 	AuthorName  string   `json:"authorName,omitempty"`
 	AuthorEmail string   `json:"authorEmail,omitempty"`
+	Force       bool     `json:"force,omitempty"` // Commit large files with --git-size-check block
 }
 
 // handleGitCommit creates a new commit
@@ -327,25 +492,39 @@ func (s *Server) handleGitCommit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	commit, err := repo.Commit(git.CommitOptions{
+	if !s.gitStagedAllowed(w, r, repo, req.Files) {
+		return
+	}
+	warnings, ok := s.checkCommitSize(w, repo, req.Files, false, req.Force)
+	if !ok {
+		return
+	}
+
+	opts := git.CommitOptions{
 		Message:     req.Message,
 		Files:       req.Files,
 		AuthorName:  req.AuthorName,
 		AuthorEmail: req.AuthorEmail,
-	})
+	}
+	s.commitDefaults(r, &opts)
+
+	commit, err := repo.Commit(opts)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "Failed to commit: "+err.Error())
 		return
 	}
+	s.recordAudit(r, audit.ActionGitCommit, "", commitSummary(commit))
 
 	// Return commit info and updated status
 	status, _ := repo.Status()
+	filterGitStatus(status, s.gitGuard(r, repo))
 
 	writeJSON(w, http.StatusOK, APIResponse{
 		Success: true,
 		Data: map[string]interface{}{
-			"commit": commit,
-			"status": status,
+			"commit":   commit,
+			"status":   status,
+			"warnings": warnings,
 		},
 	})
 }
@@ -370,6 +549,10 @@ func (s *Server) handleGitDiscard(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !s.gitFiles(w, r, repo, &req.Files, &req.All) {
+		return
+	}
+
 	var err error
 	if req.All {
 		err = repo.DiscardAll()
@@ -384,6 +567,7 @@ func (s *Server) handleGitDiscard(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusInternalServerError, "Failed to discard: "+err.Error())
 		return
 	}
+	s.recordAudit(r, audit.ActionGitDiscard, "", filesSummary(req.Files, req.All))
 
 	// Return updated status
 	status, err := repo.Status()
@@ -391,6 +575,7 @@ func (s *Server) handleGitDiscard(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusInternalServerError, "Failed to get status: "+err.Error())
 		return
 	}
+	filterGitStatus(status, s.gitGuard(r, repo))
 
 	writeJSON(w, http.StatusOK, APIResponse{
 		Success: true,
@@ -400,12 +585,201 @@ func (s *Server) handleGitDiscard(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// AuthRequest represents authentication info for remote operations
+// handleGitResolve settles a merge conflict in one file with our version,
+// theirs, or content merged by hand, stages the result and reports the
+// files still conflicted
+func (s *Server) handleGitResolve(w http.ResponseWriter, r *http.Request) {
+	repo := s.git.CurrentRepository()
+	if repo == nil {
+		writeError(w, http.StatusBadRequest, "Not a git repository")
+		return
+	}
+
+	var req git.ResolveConflictRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	if req.Path == "" {
+		writeError(w, http.StatusBadRequest, "Path is required")
+		return
+	}
+	switch req.Strategy {
+	case git.ResolveOurs, git.ResolveTheirs, git.ResolveCustom, "":
+	default:
+		writeError(w, http.StatusBadRequest, "Strategy must be ours, theirs or custom")
+		return
+	}
+
+	files, all := []string{req.Path}, false
+	if !s.gitFiles(w, r, repo, &files, &all) {
+		return
+	}
+
+	conflicts, err := repo.ResolveConflict(req.Path, req.Strategy, []byte(req.Content))
+	if errors.Is(err, git.ErrNotConflicted) {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to resolve conflict: "+err.Error())
+		return
+	}
+	strategy := req.Strategy
+	if strategy == "" {
+		strategy = git.ResolveCustom
+	}
+	s.recordAudit(r, audit.ActionGitResolve, req.Path, strategy)
+
+	status, err := repo.Status()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to get status: "+err.Error())
+		return
+	}
+	guard := s.gitGuard(r, repo)
+	filterGitStatus(status, guard)
+	remaining := []string{}
+	for _, p := range conflicts {
+		if guard == nil || guard(p, false) {
+			remaining = append(remaining, p)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"path":      req.Path,
+			"conflicts": remaining,
+			"status":    status,
+		},
+	})
+}
+
+// PickRequest names a commit to revert or cherry-pick
+type PickRequest struct {
+	Hash        string `json:"hash"`
+	AuthorName  string `json:"authorName,omitempty"`
+	AuthorEmail string `json:"authorEmail,omitempty"`
+}
+
+// handleGitRevert commits the inverse of a commit
+func (s *Server) handleGitRevert(w http.ResponseWriter, r *http.Request) {
+	s.handleGitPick(w, r, audit.ActionGitRevert, (*git.Repository).Revert)
+}
+
+// handleGitCherryPick commits a commit's changes on top of HEAD
+func (s *Server) handleGitCherryPick(w http.ResponseWriter, r *http.Request) {
+	s.handleGitPick(w, r, audit.ActionGitCherryPick, (*git.Repository).CherryPick)
+}
+
+// handleGitPick reverts or cherry-picks a commit, reporting the files left
+// conflicted along with the updated status
+func (s *Server) handleGitPick(w http.ResponseWriter, r *http.Request, action string, pick func(*git.Repository, string, git.CommitOptions) (*git.PickResult, error)) {
+	repo := s.git.CurrentRepository()
+	if repo == nil {
+		writeError(w, http.StatusBadRequest, "Not a git repository")
+		return
+	}
+
+	var req PickRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	if req.Hash == "" {
+		writeError(w, http.StatusBadRequest, "Commit hash is required")
+		return
+	}
+
+	// Restricted users may only pick commits touching files they may change
+	if guard := s.gitGuard(r, repo); guard != nil {
+		detail, err := repo.GetCommit(req.Hash)
+		if err != nil {
+			writeError(w, http.StatusNotFound, "Commit not found: "+err.Error())
+			return
+		}
+		for _, c := range detail.Changes {
+			for _, p := range []string{c.Path, c.OldPath} {
+				if p != "" && !guard(p, true) {
+					writeError(w, http.StatusForbidden, "You may not change "+p)
+					return
+				}
+			}
+		}
+	}
+
+	opts := git.CommitOptions{AuthorName: req.AuthorName, AuthorEmail: req.AuthorEmail}
+	s.commitDefaults(r, &opts)
+
+	result, err := pick(repo, req.Hash, opts)
+	if errors.Is(err, git.ErrUncommittedChanges) {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	} else if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	detail := req.Hash
+	if len(result.Conflicts) > 0 {
+		detail += fmt.Sprintf(", %d conflicted", len(result.Conflicts))
+	}
+	s.recordAudit(r, action, "", detail)
+
+	status, err := repo.Status()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to get status: "+err.Error())
+		return
+	}
+	filterGitStatus(status, s.gitGuard(r, repo))
+
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"result":    result,
+			"conflicts": result.Conflicts,
+			"status":    status,
+		},
+	})
+}
+
+// AuthRequest represents authentication info for remote operations. A
+// push, pull or fetch goes to Remote, or origin when it is empty. Remember
+// saves the password or passphrase in the credential store once it works.
 type AuthRequest struct {
+	Remote        string `json:"remote,omitempty"`
 	SSHKeyPath    string `json:"sshKeyPath,omitempty"`
 	SSHPassphrase string `json:"sshPassphrase,omitempty"`
 	Username      string `json:"username,omitempty"`
 	Password      string `json:"password,omitempty"`
+	Remember      bool   `json:"remember,omitempty"`
+}
+
+// PushRequest represents a push. Branch defaults to the current branch,
+// and Force overwrites the remote branch as long as it hasn't moved since
+// the last fetch.
+type PushRequest struct {
+	AuthRequest
+	Branch string `json:"branch,omitempty"`
+	Force  bool   `json:"force,omitempty"`
+}
+
+// remoteContext returns the context a push, pull or fetch for a request
+// runs under: cancelled if the client goes away, and limited to
+// --git-timeout
+func (s *Server) remoteContext(r *http.Request) (context.Context, context.CancelFunc) {
+	if s.config.GitTimeout <= 0 {
+		return context.WithCancel(r.Context())
+	}
+	return context.WithTimeout(r.Context(), s.config.GitTimeout)
+}
+
+// writeRemoteError reports a failed push, pull or fetch, with a 504 if it
+// ran out of time
+func (s *Server) writeRemoteError(w http.ResponseWriter, prefix string, err error) {
+	if errors.Is(err, context.DeadlineExceeded) {
+		writeError(w, http.StatusGatewayTimeout, fmt.Sprintf("%sremote did not respond within %s", prefix, s.config.GitTimeout))
+		return
+	}
+	writeError(w, http.StatusInternalServerError, prefix+err.Error())
 }
 
 // handleGitPush pushes commits to the remote
@@ -416,13 +790,13 @@ func (s *Server) handleGitPush(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var req AuthRequest
+	var req PushRequest
 	_ = json.NewDecoder(r.Body).Decode(&req)
 
 	// Build auth config if provided
 	var authConfig *git.AuthConfig
 	if req.SSHKeyPath != "" || req.Username != "" {
-		remoteURL := repo.GetRemoteURL()
+		remoteURL := repo.RemoteURLOf(req.Remote)
 		authType := git.DetectAuthType(remoteURL)
 		authConfig = &git.AuthConfig{
 			Type:          authType,
@@ -433,11 +807,31 @@ func (s *Server) handleGitPush(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	result, err := repo.Push(authConfig)
-	if err != nil {
-		writeError(w, http.StatusInternalServerError, "Push failed: "+err.Error())
+	ctx, cancel := s.remoteContext(r)
+	defer cancel()
+	result, err := repo.PushContext(ctx, git.PushRequest{
+		Remote: req.Remote,
+		Branch: req.Branch,
+		Force:  req.Force,
+	}, authConfig)
+	if errors.Is(err, git.ErrStaleLease) {
+		writeError(w, http.StatusConflict, "Push failed: "+err.Error())
+		return
+	} else if err != nil {
+		s.writeRemoteError(w, "Push failed: ", err)
 		return
 	}
+	s.rememberAuth(r, repo.RemoteURLOf(req.Remote), req.AuthRequest)
+
+	branch := req.Branch
+	if branch == "" {
+		branch = repo.Branch()
+	}
+	detail := remoteBranch(req.Remote, branch)
+	if req.Force {
+		detail += " (forced)"
+	}
+	s.recordAudit(r, audit.ActionGitPush, "", detail)
 
 	// Return result and updated status
 	status, _ := repo.Status()
@@ -465,7 +859,7 @@ func (s *Server) handleGitPull(w http.ResponseWriter, r *http.Request) {
 	// Build auth config if provided
 	var authConfig *git.AuthConfig
 	if req.SSHKeyPath != "" || req.Username != "" {
-		remoteURL := repo.GetRemoteURL()
+		remoteURL := repo.RemoteURLOf(req.Remote)
 		authType := git.DetectAuthType(remoteURL)
 		authConfig = &git.AuthConfig{
 			Type:          authType,
@@ -476,11 +870,15 @@ func (s *Server) handleGitPull(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	result, err := repo.Pull(authConfig)
+	ctx, cancel := s.remoteContext(r)
+	defer cancel()
+	result, err := repo.PullContext(ctx, req.Remote, authConfig)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "Pull failed: "+err.Error())
+		s.writeRemoteError(w, "Pull failed: ", err)
 		return
 	}
+	s.rememberAuth(r, repo.RemoteURLOf(req.Remote), req)
+	s.recordAudit(r, audit.ActionGitPull, "", remoteBranch(req.Remote, repo.Branch()))
 
 	// Return result and updated status
 	status, _ := repo.Status()
@@ -508,7 +906,7 @@ func (s *Server) handleGitFetch(w http.ResponseWriter, r *http.Request) {
 	// Build auth config if provided
 	var authConfig *git.AuthConfig
 	if req.SSHKeyPath != "" || req.Username != "" {
-		remoteURL := repo.GetRemoteURL()
+		remoteURL := repo.RemoteURLOf(req.Remote)
 		authType := git.DetectAuthType(remoteURL)
 		authConfig = &git.AuthConfig{
 			Type:          authType,
@@ -519,11 +917,14 @@ func (s *Server) handleGitFetch(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	result, err := repo.Fetch(authConfig)
+	ctx, cancel := s.remoteContext(r)
+	defer cancel()
+	result, err := repo.FetchContext(ctx, req.Remote, authConfig)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "Fetch failed: "+err.Error())
+		s.writeRemoteError(w, "Fetch failed: ", err)
 		return
 	}
+	s.rememberAuth(r, repo.RemoteURLOf(req.Remote), req)
 
 	// Return result and updated status
 	status, _ := repo.Status()
@@ -599,6 +1000,7 @@ func (s *Server) handleGitCheckout(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusInternalServerError, "Checkout failed: "+err.Error())
 		return
 	}
+	s.recordAudit(r, audit.ActionGitCheckout, "", req.Name)
 
 	// Return updated status
 	status, _ := repo.Status()
@@ -636,6 +1038,7 @@ func (s *Server) handleGitCreateBranch(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusInternalServerError, "Failed to create branch: "+err.Error())
 		return
 	}
+	s.recordAudit(r, audit.ActionGitBranch, "", "create "+req.Name)
 
 	branches, _ := repo.ListBranches()
 
@@ -670,6 +1073,7 @@ func (s *Server) handleGitDeleteBranch(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusInternalServerError, "Failed to delete branch: "+err.Error())
 		return
 	}
+	s.recordAudit(r, audit.ActionGitBranch, "", "delete "+req.Name)
 
 	branches, _ := repo.ListBranches()
 
@@ -704,6 +1108,7 @@ func (s *Server) handleGitRenameBranch(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusInternalServerError, "Failed to rename branch: "+err.Error())
 		return
 	}
+	s.recordAudit(r, audit.ActionGitBranch, "", "rename "+req.Name+" to "+req.NewName)
 
 	branches, _ := repo.ListBranches()
 	status, _ := repo.Status()
@@ -717,115 +1122,407 @@ func (s *Server) handleGitRenameBranch(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// handleGitHistory returns commit history
-func (s *Server) handleGitHistory(w http.ResponseWriter, r *http.Request) {
+// handleGitTags lists all tags, newest first
+func (s *Server) handleGitTags(w http.ResponseWriter, r *http.Request) {
 	repo := s.git.CurrentRepository()
 	if repo == nil {
 		writeError(w, http.StatusBadRequest, "Not a git repository")
 		return
 	}
 
-	// Parse query params
-	query := r.URL.Query()
-	limit := 50
-	skip := 0
-	filePath := query.Get("path")
-
-	if l := query.Get("limit"); l != "" {
-		if _, err := json.Number(l).Int64(); err == nil {
-			n, _ := json.Number(l).Int64()
-			limit = int(n)
-		}
-	}
-	if s := query.Get("skip"); s != "" {
-		if _, err := json.Number(s).Int64(); err == nil {
-			n, _ := json.Number(s).Int64()
-			skip = int(n)
-		}
-	}
-
-	commits, err := repo.GetHistory(limit, skip, filePath)
+	tags, err := repo.ListTags()
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "Failed to get history: "+err.Error())
+		writeError(w, http.StatusInternalServerError, "Failed to list tags: "+err.Error())
 		return
 	}
 
 	writeJSON(w, http.StatusOK, APIResponse{
 		Success: true,
 		Data: map[string]interface{}{
-			"commits": commits,
+			"tags": tags,
 		},
 	})
 }
 
-// handleGitCommitDetail returns details for a specific commit
-func (s *Server) handleGitCommitDetail(w http.ResponseWriter, r *http.Request) {
+// handleGitCreateTag tags HEAD or another commit. A tag with a message is
+// annotated, with the same tagger and signature a commit would have.
+func (s *Server) handleGitCreateTag(w http.ResponseWriter, r *http.Request) {
 	repo := s.git.CurrentRepository()
 	if repo == nil {
 		writeError(w, http.StatusBadRequest, "Not a git repository")
 		return
 	}
 
-	hash := r.URL.Query().Get("hash")
-	if hash == "" {
-		writeError(w, http.StatusBadRequest, "Commit hash is required")
+	var req git.TagOptions
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
 		return
 	}
 
-	detail, err := repo.GetCommit(hash)
+	if req.Name == "" {
+		writeError(w, http.StatusBadRequest, "Tag name is required")
+		return
+	}
+
+	identity := git.CommitOptions{AuthorName: req.TaggerName, AuthorEmail: req.TaggerEmail}
+	s.commitDefaults(r, &identity)
+	req.TaggerName, req.TaggerEmail, req.SignKey = identity.AuthorName, identity.AuthorEmail, identity.SignKey
+
+	tag, err := repo.CreateTag(req)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "Failed to get commit: "+err.Error())
+		writeError(w, http.StatusBadRequest, "Failed to create tag: "+err.Error())
 		return
 	}
+	s.recordAudit(r, audit.ActionGitTag, "", "create "+tag.Name+" at "+tag.Hash[:7])
+
+	tags, _ := repo.ListTags()
 
 	writeJSON(w, http.StatusOK, APIResponse{
 		Success: true,
-		Data: detail,
+		Data: map[string]interface{}{
+			"tag":  tag,
+			"tags": tags,
+		},
 	})
 }
 
-// DiffRequest represents a request to get a diff
-type DiffRequest struct {
-	FromHash string `json:"fromHash"`
-	ToHash   string `json:"toHash"`
-	FilePath string `json:"filePath,omitempty"`
+// TagRequest represents a request for tag operations. A push sends the
+// tags named in Tags, or every tag when it is empty.
+type TagRequest struct {
+	AuthRequest
+	Name string   `json:"name,omitempty"`
+	Tags []string `json:"tags,omitempty"`
 }
 
-// handleGitDiff returns the diff between two commits
-func (s *Server) handleGitDiff(w http.ResponseWriter, r *http.Request) {
+// handleGitDeleteTag deletes a local tag
+func (s *Server) handleGitDeleteTag(w http.ResponseWriter, r *http.Request) {
 	repo := s.git.CurrentRepository()
 	if repo == nil {
 		writeError(w, http.StatusBadRequest, "Not a git repository")
 		return
 	}
 
-	// Support both GET with query params and POST with body
-	var fromHash, toHash, filePath string
-
-	if r.Method == "GET" {
-		query := r.URL.Query()
-		fromHash = query.Get("from")
-		toHash = query.Get("to")
-		filePath = query.Get("path")
-	} else {
-		var req DiffRequest
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			writeError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
-			return
-		}
-		fromHash = req.FromHash
-		toHash = req.ToHash
-		filePath = req.FilePath
+	var req TagRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+		return
 	}
 
-	if fromHash == "" || toHash == "" {
-		writeError(w, http.StatusBadRequest, "Both from and to commit hashes are required")
+	if req.Name == "" {
+		writeError(w, http.StatusBadRequest, "Tag name is required")
+		return
+	}
+
+	if err := repo.DeleteTag(req.Name); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to delete tag: "+err.Error())
+		return
+	}
+	s.recordAudit(r, audit.ActionGitTag, "", "delete "+req.Name)
+
+	tags, _ := repo.ListTags()
+
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"tags": tags,
+		},
+	})
+}
+
+// handleGitPushTags pushes tags to the remote
+func (s *Server) handleGitPushTags(w http.ResponseWriter, r *http.Request) {
+	repo := s.git.CurrentRepository()
+	if repo == nil {
+		writeError(w, http.StatusBadRequest, "Not a git repository")
+		return
+	}
+
+	var req TagRequest
+	_ = json.NewDecoder(r.Body).Decode(&req)
+
+	// Build auth config if provided
+	var authConfig *git.AuthConfig
+	if req.SSHKeyPath != "" || req.Username != "" {
+		remoteURL := repo.GetRemoteURL()
+		authType := git.DetectAuthType(remoteURL)
+		authConfig = &git.AuthConfig{
+			Type:          authType,
+			SSHKeyPath:    req.SSHKeyPath,
+			SSHPassphrase: req.SSHPassphrase,
+			Username:      req.Username,
+			Password:      req.Password,
+		}
+	}
+
+	ctx, cancel := s.remoteContext(r)
+	defer cancel()
+	result, err := repo.PushTagsContext(ctx, req.Tags, authConfig)
+	if err != nil {
+		s.writeRemoteError(w, "Push failed: ", err)
+		return
+	}
+	detail := "all tags"
+	if len(req.Tags) > 0 {
+		detail = "tags " + strings.Join(req.Tags, ", ")
+	}
+	s.recordAudit(r, audit.ActionGitPush, "", detail)
+
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"result": result,
+		},
+	})
+}
+
+// remoteBranch describes what a push or pull went to for the audit log
+func remoteBranch(remote, branch string) string {
+	if remote == "" || remote == git.DefaultRemote {
+		return branch
+	}
+	return remote + "/" + branch
+}
+
+// RemoteRequest represents a request for remote operations. A rename moves
+// Name to NewName.
+type RemoteRequest struct {
+	Name    string `json:"name"`
+	URL     string `json:"url,omitempty"`
+	NewName string `json:"newName,omitempty"`
+}
+
+// handleGitRemotes lists the configured remotes
+func (s *Server) handleGitRemotes(w http.ResponseWriter, r *http.Request) {
+	repo := s.git.CurrentRepository()
+	if repo == nil {
+		writeError(w, http.StatusBadRequest, "Not a git repository")
+		return
+	}
+
+	remotes, err := repo.ListRemotes()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to list remotes: "+err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"remotes": remotes,
+		},
+	})
+}
+
+// handleGitAddRemote adds a remote
+func (s *Server) handleGitAddRemote(w http.ResponseWriter, r *http.Request) {
+	s.changeRemote(w, r, func(repo *git.Repository, req RemoteRequest) (string, error) {
+		return "add " + req.Name, repo.AddRemote(req.Name, req.URL)
+	})
+}
+
+// handleGitRemoveRemote removes a remote and its remote-tracking branches
+func (s *Server) handleGitRemoveRemote(w http.ResponseWriter, r *http.Request) {
+	s.changeRemote(w, r, func(repo *git.Repository, req RemoteRequest) (string, error) {
+		return "remove " + req.Name, repo.RemoveRemote(req.Name)
+	})
+}
+
+// handleGitRenameRemote renames a remote
+func (s *Server) handleGitRenameRemote(w http.ResponseWriter, r *http.Request) {
+	s.changeRemote(w, r, func(repo *git.Repository, req RemoteRequest) (string, error) {
+		if req.NewName == "" {
+			return "", errors.New("new remote name is required")
+		}
+		return "rename " + req.Name + " to " + req.NewName, repo.RenameRemote(req.Name, req.NewName)
+	})
+}
+
+// handleGitSetRemoteURL points a remote at another URL
+func (s *Server) handleGitSetRemoteURL(w http.ResponseWriter, r *http.Request) {
+	s.changeRemote(w, r, func(repo *git.Repository, req RemoteRequest) (string, error) {
+		return "set URL of " + req.Name, repo.SetRemoteURL(req.Name, req.URL)
+	})
+}
+
+// changeRemote decodes a RemoteRequest, applies change to the current
+// repository and responds with the remotes afterwards. change returns what
+// it did for the audit log.
+func (s *Server) changeRemote(w http.ResponseWriter, r *http.Request, change func(*git.Repository, RemoteRequest) (string, error)) {
+	repo := s.git.CurrentRepository()
+	if repo == nil {
+		writeError(w, http.StatusBadRequest, "Not a git repository")
+		return
+	}
+
+	var req RemoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	if req.Name == "" {
+		writeError(w, http.StatusBadRequest, "Remote name is required")
+		return
+	}
+
+	detail, err := change(repo, req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Failed to change remote: "+err.Error())
+		return
+	}
+	s.recordAudit(r, audit.ActionGitRemote, "", detail)
+
+	remotes, _ := repo.ListRemotes()
+
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"remotes": remotes,
+		},
+	})
+}
+
+// handleGitHistory returns a page of commit history. The page's next
+// cursor, passed back as cursor, fetches the following page.
+func (s *Server) handleGitHistory(w http.ResponseWriter, r *http.Request) {
+	repo := s.git.CurrentRepository()
+	if repo == nil {
+		writeError(w, http.StatusBadRequest, "Not a git repository")
+		return
+	}
+
+	// Parse query params
+	query := r.URL.Query()
+	limit := 50
+	skip := 0
+	filePath := query.Get("path")
+
+	if l := query.Get("limit"); l != "" {
+		if _, err := json.Number(l).Int64(); err == nil {
+			n, _ := json.Number(l).Int64()
+			limit = int(n)
+		}
+	}
+	if s := query.Get("skip"); s != "" {
+		if _, err := json.Number(s).Int64(); err == nil {
+			n, _ := json.Number(s).Int64()
+			skip = int(n)
+		}
+	}
+
+	if filePath != "" && !s.gitReadable(w, r, repo, filePath) {
+		return
+	}
+
+	page, err := repo.History(git.HistoryOptions{Limit: limit, Skip: skip, Cursor: query.Get("cursor"), Path: filePath})
+	if errors.Is(err, git.ErrInvalidCursor) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to get history: "+err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    page,
+	})
+}
+
+// handleGitCommitDetail returns details for a specific commit
+func (s *Server) handleGitCommitDetail(w http.ResponseWriter, r *http.Request) {
+	repo := s.git.CurrentRepository()
+	if repo == nil {
+		writeError(w, http.StatusBadRequest, "Not a git repository")
+		return
+	}
+
+	hash := r.URL.Query().Get("hash")
+	if hash == "" {
+		writeError(w, http.StatusBadRequest, "Commit hash is required")
+		return
+	}
+
+	detail, err := repo.GetCommit(hash)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to get commit: "+err.Error())
+		return
+	}
+	if guard := s.gitGuard(r, repo); guard != nil {
+		changes := detail.Changes[:0]
+		for _, c := range detail.Changes {
+			if guard(c.Path, false) {
+				changes = append(changes, c)
+			}
+		}
+		detail.Changes = changes
+	}
+
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    detail,
+	})
+}
+
+// DiffRequest represents a request to get a diff
+type DiffRequest struct {
+	FromHash string `json:"fromHash"`
+	ToHash   string `json:"toHash"`
+	FilePath string `json:"filePath,omitempty"`
+	Full     bool   `json:"full,omitempty"` // Skip the size limits
+}
+
+// diffLimits returns the limits for a diff request: none if full is set
+func diffLimits(full bool) git.DiffLimits {
+	if full {
+		return git.DiffLimits{}
+	}
+	return git.DefaultDiffLimits
+}
+
+// handleGitDiff returns the diff between two commits. Large files are cut
+// short and marked truncated unless full is set.
+func (s *Server) handleGitDiff(w http.ResponseWriter, r *http.Request) {
+	repo := s.git.CurrentRepository()
+	if repo == nil {
+		writeError(w, http.StatusBadRequest, "Not a git repository")
+		return
+	}
+
+	// Support both GET with query params and POST with body
+	var fromHash, toHash, filePath string
+	var full bool
+
+	if r.Method == "GET" {
+		query := r.URL.Query()
+		fromHash = query.Get("from")
+		toHash = query.Get("to")
+		filePath = query.Get("path")
+		full, _ = strconv.ParseBool(query.Get("full"))
+	} else {
+		var req DiffRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+			return
+		}
+		fromHash = req.FromHash
+		toHash = req.ToHash
+		filePath = req.FilePath
+		full = req.Full
+	}
+
+	if fromHash == "" || toHash == "" {
+		writeError(w, http.StatusBadRequest, "Both from and to commit hashes are required")
 		return
 	}
 
 	if filePath != "" {
+		if !s.gitReadable(w, r, repo, filePath) {
+			return
+		}
+
 		// Get diff for specific file
-		fileDiff, err := repo.GetFileDiff(fromHash, toHash, filePath)
+		fileDiff, err := repo.GetFileDiffWithLimits(fromHash, toHash, filePath, diffLimits(full))
 		if err != nil {
 			writeError(w, http.StatusInternalServerError, "Failed to get file diff: "+err.Error())
 			return
@@ -839,11 +1536,20 @@ func (s *Server) handleGitDiff(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get full diff
-	diff, err := repo.GetDiff(fromHash, toHash)
+	diff, err := repo.GetDiffWithLimits(fromHash, toHash, diffLimits(full))
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "Failed to get diff: "+err.Error())
 		return
 	}
+	if guard := s.gitGuard(r, repo); guard != nil {
+		files := diff.Files[:0]
+		for _, f := range diff.Files {
+			if guard(f.Path, false) {
+				files = append(files, f)
+			}
+		}
+		diff.Files = files
+	}
 
 	writeJSON(w, http.StatusOK, APIResponse{
 		Success: true,
@@ -851,6 +1557,125 @@ func (s *Server) handleGitDiff(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// handleGitHistoryStream streams commit history as newline-delimited JSON,
+// one commit per line. Without a limit the whole history is sent.
+func (s *Server) handleGitHistoryStream(w http.ResponseWriter, r *http.Request) {
+	repo := s.git.CurrentRepository()
+	if repo == nil {
+		writeError(w, http.StatusBadRequest, "Not a git repository")
+		return
+	}
+
+	query := r.URL.Query()
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	skip, _ := strconv.Atoi(query.Get("skip"))
+
+	if path := query.Get("path"); path != "" && !s.gitReadable(w, r, repo, path) {
+		return
+	}
+
+	stream := newNDJSONStream(w)
+	err := repo.WalkHistory(limit, skip, query.Get("path"), func(c git.Commit) error {
+		if err := r.Context().Err(); err != nil {
+			return err
+		}
+		return stream.send(c)
+	})
+	if err != nil {
+		if r.Context().Err() == nil {
+			stream.fail(http.StatusInternalServerError, "Failed to get history: "+err.Error())
+		}
+		return
+	}
+	stream.finish()
+}
+
+// handleGitDiffStream streams the diff between two commits as
+// newline-delimited JSON, one changed file per line, with the same limits
+// as handleGitDiff
+func (s *Server) handleGitDiffStream(w http.ResponseWriter, r *http.Request) {
+	repo := s.git.CurrentRepository()
+	if repo == nil {
+		writeError(w, http.StatusBadRequest, "Not a git repository")
+		return
+	}
+
+	query := r.URL.Query()
+	fromHash := query.Get("from")
+	toHash := query.Get("to")
+	if fromHash == "" || toHash == "" {
+		writeError(w, http.StatusBadRequest, "Both from and to commit hashes are required")
+		return
+	}
+
+	full, _ := strconv.ParseBool(query.Get("full"))
+
+	guard := s.gitGuard(r, repo)
+	stream := newNDJSONStream(w)
+	err := repo.WalkDiff(fromHash, toHash, diffLimits(full), func(d *git.FileDiff) error {
+		if err := r.Context().Err(); err != nil {
+			return err
+		}
+		if guard != nil && !guard(d.Path, false) {
+			return nil
+		}
+		return stream.send(d)
+	})
+	if err != nil {
+		if r.Context().Err() == nil {
+			stream.fail(http.StatusInternalServerError, "Failed to get diff: "+err.Error())
+		}
+		return
+	}
+	stream.finish()
+}
+
+// handleGitWorkingDiff returns the uncommitted changes to a file or
+// folder, or to every file: those staged for the next commit when staged
+// is set, otherwise those not yet staged. Large files are cut short and
+// marked truncated unless full is set.
+func (s *Server) handleGitWorkingDiff(w http.ResponseWriter, r *http.Request) {
+	repo := s.git.CurrentRepository()
+	if repo == nil {
+		writeError(w, http.StatusBadRequest, "Not a git repository")
+		return
+	}
+
+	query := r.URL.Query()
+	path := query.Get("path")
+	staged, _ := strconv.ParseBool(query.Get("staged"))
+	full, _ := strconv.ParseBool(query.Get("full"))
+
+	if path != "" && !s.gitReadable(w, r, repo, path) {
+		return
+	}
+
+	files, err := repo.GetWorkingDiffWithLimits(path, staged, diffLimits(full))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to get diff: "+err.Error())
+		return
+	}
+	truncated := false
+	guard := s.gitGuard(r, repo)
+	kept := files[:0]
+	for _, f := range files {
+		if guard != nil && !guard(f.Path, false) {
+			continue
+		}
+		kept = append(kept, f)
+		truncated = truncated || f.Truncated
+	}
+
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"staged":    staged,
+			"files":     kept,
+			"truncated": truncated,
+		},
+	})
+}
+
 // handleGitFileAtCommit returns file content at a specific commit
 func (s *Server) handleGitFileAtCommit(w http.ResponseWriter, r *http.Request) {
 	repo := s.git.CurrentRepository()
@@ -867,11 +1692,21 @@ func (s *Server) handleGitFileAtCommit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !s.gitReadable(w, r, repo, filePath) {
+		return
+	}
+
 	content, err := repo.GetFileAtCommit(hash, filePath)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "Failed to get file: "+err.Error())
 		return
 	}
+	plain, err := s.workspace().fs.Decrypt([]byte(content))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to get file: "+err.Error())
+		return
+	}
+	content = string(plain)
 
 	writeJSON(w, http.StatusOK, APIResponse{
 		Success: true,
@@ -888,6 +1723,7 @@ type QuickCommitRequest struct {
 	Files   []string `json:"files"`
 	Message string   `json:"message"`
 	Push    bool     `json:"push,omitempty"`
+	Force   bool     `json:"force,omitempty"` // Commit large files with --git-size-check block
 }
 
 // handleGitQuickCommit stages files, commits, and optionally pushes
@@ -909,6 +1745,15 @@ func (s *Server) handleGitQuickCommit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	all := len(req.Files) == 0
+	if !s.gitFiles(w, r, repo, &req.Files, &all) || !s.gitStagedAllowed(w, r, repo, req.Files) {
+		return
+	}
+	warnings, ok := s.checkCommitSize(w, repo, req.Files, len(req.Files) == 0, req.Force)
+	if !ok {
+		return
+	}
+
 	// Stage files
 	if len(req.Files) > 0 {
 		if err := repo.Stage(req.Files); err != nil {
@@ -924,31 +1769,40 @@ func (s *Server) handleGitQuickCommit(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Commit
-	commit, err := repo.Commit(git.CommitOptions{
+	opts := git.CommitOptions{
 		Message: req.Message,
-	})
+	}
+	s.commitDefaults(r, &opts)
+
+	commit, err := repo.Commit(opts)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "Failed to commit: "+err.Error())
 		return
 	}
+	s.recordAudit(r, audit.ActionGitCommit, "", commitSummary(commit))
 
 	response := map[string]interface{}{
-		"commit": commit,
+		"commit":   commit,
+		"warnings": warnings,
 	}
 
 	// Push if requested
 	if req.Push {
-		pushResult, err := repo.Push(nil)
+		ctx, cancel := s.remoteContext(r)
+		pushResult, err := repo.PushContext(ctx, git.PushRequest{}, nil)
+		cancel()
 		if err != nil {
 			// Commit succeeded but push failed
 			response["pushError"] = err.Error()
 		} else {
+			s.recordAudit(r, audit.ActionGitPush, "", repo.Branch())
 			response["push"] = pushResult
 		}
 	}
 
 	// Return updated status
 	status, _ := repo.Status()
+	filterGitStatus(status, s.gitGuard(r, repo))
 	response["status"] = status
 
 	writeJSON(w, http.StatusOK, APIResponse{
@@ -956,3 +1810,156 @@ func (s *Server) handleGitQuickCommit(w http.ResponseWriter, r *http.Request) {
 		Data:    response,
 	})
 }
+
+// checkCommitSize warns about large files in what is about to be
+// committed. With --git-size-check block, the commit is refused with a 422
+// listing the warnings unless the request forces it. A failed check
+// doesn't stop the commit.
+func (s *Server) checkCommitSize(w http.ResponseWriter, repo *git.Repository, files []string, all, force bool) ([]git.SizeWarning, bool) {
+	if s.config.GitSizeCheck == git.SizeCheckOff {
+		return []git.SizeWarning{}, true
+	}
+	warnings, err := repo.CheckCommitSize(files, all, git.SizeLimits{
+		MaxFileSize:   s.config.GitMaxFileSize,
+		MaxCommitSize: s.config.GitMaxCommitSize,
+	})
+	if err != nil {
+		slog.Warn("Failed to check commit size", "error", err)
+		return []git.SizeWarning{}, true
+	}
+	if warnings == nil {
+		warnings = []git.SizeWarning{}
+	}
+
+	if len(warnings) > 0 && s.config.GitSizeCheck == git.SizeCheckBlock && !force {
+		message := "Commit blocked: " + warnings[0].Message
+		if len(warnings) > 1 {
+			message += " (and " + strconv.Itoa(len(warnings)-1) + " more)"
+		}
+		writeJSON(w, http.StatusUnprocessableEntity, APIResponse{
+			Success: false,
+			Error:   message,
+			Data: map[string]interface{}{
+				"warnings": warnings,
+			},
+		})
+		return nil, false
+	}
+	return warnings, true
+}
+
+// commitSummary describes a commit for the audit log, naming its author
+// as they may differ from the actor
+func commitSummary(commit *git.Commit) string {
+	subject, _, _ := strings.Cut(strings.TrimSpace(commit.Message), "\n")
+	return commit.ShortHash + " " + subject + " (" + commit.Author + " <" + commit.Email + ">)"
+}
+
+// filesSummary describes the files affected by a git operation
+func filesSummary(files []string, all bool) string {
+	if all {
+		return "all files"
+	}
+	return strings.Join(files, ", ")
+}
+
+// commitDefaults fills in the author and signing key of a commit. Commits
+// by a signed-in user are attributed to them; only admins may name another
+// author. Other commits go to the author given, then to --git-name and
+// --git-email; the repository's git config is used after that.
+func (s *Server) commitDefaults(r *http.Request, opts *git.CommitOptions) {
+	if user := userFromContext(r.Context()); user != nil && (opts.AuthorName == "" || !user.IsAdmin()) {
+		opts.AuthorName, opts.AuthorEmail = user.GitIdentity(s.config.GitEmailDomain)
+	}
+	if opts.AuthorName == "" {
+		opts.AuthorName = s.config.GitName
+	}
+	if opts.AuthorEmail == "" {
+		opts.AuthorEmail = s.config.GitEmail
+	}
+	opts.SignKey = s.gitSignKey
+}
+
+// IgnoreRequest represents a change to .gitignore: either its new content,
+// or patterns to add to it
+type IgnoreRequest struct {
+	Content  *string  `json:"content,omitempty"`
+	Patterns []string `json:"patterns,omitempty"`
+}
+
+// handleGitIgnore returns the repository's .gitignore
+func (s *Server) handleGitIgnore(w http.ResponseWriter, r *http.Request) {
+	repo := s.git.CurrentRepository()
+	if repo == nil {
+		writeError(w, http.StatusBadRequest, "Not a git repository")
+		return
+	}
+
+	content, err := repo.ReadIgnore()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"path":    git.IgnoreFile,
+			"content": content,
+		},
+	})
+}
+
+// handleGitUpdateIgnore replaces .gitignore (PUT with content) or adds
+// patterns to it (POST with patterns), returning the status it leads to
+func (s *Server) handleGitUpdateIgnore(w http.ResponseWriter, r *http.Request) {
+	repo := s.git.CurrentRepository()
+	if repo == nil {
+		writeError(w, http.StatusBadRequest, "Not a git repository")
+		return
+	}
+
+	var req IgnoreRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	if guard := s.gitGuard(r, repo); guard != nil && !guard(git.IgnoreFile, true) {
+		writeError(w, http.StatusForbidden, "You may not change "+git.IgnoreFile)
+		return
+	}
+
+	var added []string
+	var err error
+	switch {
+	case r.Method == http.MethodPut && req.Content != nil:
+		err = repo.WriteIgnore(*req.Content)
+	case r.Method == http.MethodPost && len(req.Patterns) > 0:
+		added, err = repo.AddIgnore(req.Patterns)
+	default:
+		writeError(w, http.StatusBadRequest, "Send content to replace .gitignore, or patterns to add to it")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.recordAudit(r, audit.ActionFileWrite, git.IgnoreFile, strings.Join(added, ", "))
+
+	content, _ := repo.ReadIgnore()
+	status, err := repo.Status()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to get status: "+err.Error())
+		return
+	}
+	filterGitStatus(status, s.gitGuard(r, repo))
+
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"content": content,
+			"added":   added,
+			"status":  status,
+		},
+	})
+}
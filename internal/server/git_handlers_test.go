@@ -0,0 +1,252 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"inkwell/internal/config"
+	"inkwell/internal/git"
+)
+
+func TestNoGit(t *testing.T) {
+	srv := newTestServer(t, t.TempDir(), func(cfg *config.Config) { cfg.NoGit = true })
+
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/git/status", nil))
+	if rec.Code != http.StatusNotFound || !strings.Contains(rec.Body.String(), "Git integration is disabled") {
+		t.Errorf("Expected git routes to be disabled, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/config", nil))
+	if !strings.Contains(rec.Body.String(), `"git":false`) {
+		t.Errorf("Config %s missing \"git\":false", rec.Body.String())
+	}
+}
+
+func TestCommitIdentity(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := git.Init(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := newTestServer(t, dir)
+
+	do := func(cookie *http.Cookie, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rec, req)
+		return rec
+	}
+	login := func(name string) *http.Cookie {
+		rec := do(nil, http.MethodPost, "/api/auth/login", `{"name":"`+name+`","password":"correct horse"}`)
+		for _, c := range rec.Result().Cookies() {
+			if c.Name == "inkwell_session" {
+				return c
+			}
+		}
+		t.Fatalf("Login as %s failed: %s", name, rec.Body.String())
+		return nil
+	}
+
+	do(nil, http.MethodPost, "/api/users", `{"name":"alex","password":"correct horse","role":"admin"}`)
+	admin := login("alex")
+	do(admin, http.MethodPost, "/api/users", `{"name":"sam","password":"correct horse","role":"editor"}`)
+	sam := login("sam")
+
+	commit := func(cookie *http.Cookie, file, body string) git.Commit {
+		os.WriteFile(filepath.Join(dir, file), []byte("# "+file+"\n"), 0644)
+		rec := do(cookie, http.MethodPost, "/api/git/quick-commit", body)
+		var resp struct {
+			Data struct {
+				Commit git.Commit `json:"commit"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || rec.Code != http.StatusOK {
+			t.Fatalf("Commit failed %d: %s", rec.Code, rec.Body.String())
+		}
+		return resp.Data.Commit
+	}
+
+	if c := commit(sam, "a.md", `{"message":"Add a"}`); c.Author != "sam" || c.Email != "sam@inkwell.local" {
+		t.Errorf("Expected the commit attributed to sam, got %s <%s>", c.Author, c.Email)
+	}
+
+	// Editors can't commit as someone else
+	do(sam, http.MethodPost, "/api/git/stage", `{"all":true}`)
+	os.WriteFile(filepath.Join(dir, "b.md"), []byte("# b\n"), 0644)
+	do(sam, http.MethodPost, "/api/git/stage", `{"files":["b.md"]}`)
+	rec := do(sam, http.MethodPost, "/api/git/commit", `{"message":"Add b","authorName":"alex","authorEmail":"alex@example.com"}`)
+	if !strings.Contains(rec.Body.String(), `"author":"sam"`) {
+		t.Errorf("Expected the commit attributed to sam, got %s", rec.Body.String())
+	}
+
+	rec = do(admin, http.MethodGet, "/api/audit?action=git.commit", "")
+	if !strings.Contains(rec.Body.String(), "user:sam") || !strings.Contains(rec.Body.String(), "Add a (sam \\u003csam@inkwell.local\\u003e)") {
+		t.Errorf("Expected the commits audited with actor and author: %s", rec.Body.String())
+	}
+}
+
+func TestGitTags(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := git.Init(dir); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(dir, "notes.md"), []byte("# Notes\n"), 0644)
+
+	srv := newTestServer(t, dir)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+
+	if rec := do(http.MethodPost, "/api/git/quick-commit", `{"message":"Publish"}`); rec.Code != http.StatusOK {
+		t.Fatalf("Commit failed: %d %s", rec.Code, rec.Body.String())
+	}
+	rec := do(http.MethodPost, "/api/git/tags/create", `{"name":"v1","message":"First edition"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Create tag failed: %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := do(http.MethodPost, "/api/git/tags/create", `{"name":"v1"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected a duplicate tag refused, got %d", rec.Code)
+	}
+
+	rec = do(http.MethodGet, "/api/git/tags", "")
+	var resp struct {
+		Data struct {
+			Tags []git.Tag `json:"tags"`
+		} `json:"data"`
+	}
+	json.NewDecoder(rec.Body).Decode(&resp)
+	if len(resp.Data.Tags) != 1 || !resp.Data.Tags[0].Annotated || resp.Data.Tags[0].Tagger == "" {
+		t.Fatalf("Unexpected tags: %+v", resp.Data.Tags)
+	}
+
+	if rec := do(http.MethodPost, "/api/git/tags/delete", `{"name":"v1"}`); rec.Code != http.StatusOK {
+		t.Fatalf("Delete tag failed: %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := do(http.MethodGet, "/api/git/tags", ""); !strings.Contains(rec.Body.String(), `"tags":[]`) {
+		t.Errorf("Expected no tags left: %s", rec.Body.String())
+	}
+}
+
+func TestCommitSizeCheck(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := git.Init(dir); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(dir, "notes.md"), []byte("# Notes\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "talk.mp4"), bytes.Repeat([]byte{1}, 4096), 0644)
+
+	srv := newTestServer(t, dir, func(cfg *config.Config) {
+		cfg.GitSizeCheck = git.SizeCheckBlock
+		cfg.GitMaxFileSize = 1024
+	})
+
+	commit := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/git/quick-commit", strings.NewReader(body)))
+		return rec
+	}
+
+	rec := commit(`{"message":"Add talk"}`)
+	var resp struct {
+		Error string `json:"error"`
+		Data  struct {
+			Warnings []git.SizeWarning `json:"warnings"`
+		} `json:"data"`
+	}
+	json.NewDecoder(rec.Body).Decode(&resp)
+	if rec.Code != http.StatusUnprocessableEntity || len(resp.Data.Warnings) != 1 || resp.Data.Warnings[0].Path != "talk.mp4" || resp.Data.Warnings[0].Reason != git.SizeReasonLarge {
+		t.Fatalf("Expected the commit blocked over talk.mp4, got %d: %+v", rec.Code, resp)
+	}
+
+	if rec := commit(`{"message":"Add notes","files":["notes.md"]}`); rec.Code != http.StatusOK {
+		t.Fatalf("Expected small files committed: %d %s", rec.Code, rec.Body.String())
+	}
+	rec = commit(`{"message":"Add talk","force":true}`)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"reason":"large"`) {
+		t.Errorf("Expected a forced commit with warnings, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	cfg, err := config.New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.GitSizeCheck = "sometimes"
+	if _, err := New(cfg, nil); err == nil {
+		t.Error("Expected an unknown mode refused")
+	}
+}
+
+func TestGitRevert(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := git.Init(dir); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(dir, "notes.md"), []byte("# Notes\n"), 0644)
+
+	srv := newTestServer(t, dir)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+
+	if rec := do(http.MethodPost, "/api/git/quick-commit", `{"message":"First"}`); rec.Code != http.StatusOK {
+		t.Fatalf("Commit failed: %d %s", rec.Code, rec.Body.String())
+	}
+	os.WriteFile(filepath.Join(dir, "notes.md"), []byte("# Notes\n\nMistake\n"), 0644)
+	rec := do(http.MethodPost, "/api/git/quick-commit", `{"message":"Second"}`)
+	var commitResp struct {
+		Data struct {
+			Commit git.Commit `json:"commit"`
+		} `json:"data"`
+	}
+	json.NewDecoder(rec.Body).Decode(&commitResp)
+	hash := commitResp.Data.Commit.Hash
+	if hash == "" {
+		t.Fatalf("Commit failed: %d", rec.Code)
+	}
+
+	if rec := do(http.MethodPost, "/api/git/revert", `{}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected a missing hash refused, got %d", rec.Code)
+	}
+	rec = do(http.MethodPost, "/api/git/revert", `{"hash":"`+hash+`"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Revert failed: %d %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Data struct {
+			Result    git.PickResult `json:"result"`
+			Conflicts []string       `json:"conflicts"`
+			Status    git.GitStatus  `json:"status"`
+		} `json:"data"`
+	}
+	json.NewDecoder(rec.Body).Decode(&resp)
+	if resp.Data.Result.Commit == nil || len(resp.Data.Conflicts) != 0 || !resp.Data.Status.IsClean {
+		t.Errorf("Unexpected revert response: %+v", resp.Data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "notes.md")); string(data) != "# Notes\n" {
+		t.Errorf("Expected the change undone, got %q", data)
+	}
+
+	if rec := do(http.MethodPost, "/api/git/cherry-pick", `{"hash":"`+hash+`"}`); rec.Code != http.StatusOK {
+		t.Fatalf("Cherry-pick failed: %d %s", rec.Code, rec.Body.String())
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "notes.md")); string(data) != "# Notes\n\nMistake\n" {
+		t.Errorf("Expected the change picked back, got %q", data)
+	}
+}
@@ -1,17 +1,24 @@
 package server
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
+	"inkwell/internal/audit"
 	"inkwell/internal/filesystem"
-
-	"github.com/gorilla/mux"
+	"inkwell/internal/git"
+	"inkwell/internal/recents"
+	"inkwell/internal/version"
 )
 
 // APIResponse is a generic API response
@@ -27,13 +34,30 @@ type FileRequest struct {
 	Content string `json:"content"`
 }
 
-// handleGetTree returns the file tree
+// handleGetTree returns the file tree, with note sizes and titles if
+// ?metadata=true and only its first levels if ?depth=N
 func (s *Server) handleGetTree(w http.ResponseWriter, r *http.Request) {
-	tree, err := s.fs.GetTree()
+	fs := s.workspace().files(requestActor(r))
+	getTree := fs.GetTree
+	if metadata, _ := strconv.ParseBool(r.URL.Query().Get("metadata")); metadata {
+		getTree = fs.GetTreeWithMetadata
+	}
+	depth := -1
+	if v := r.URL.Query().Get("depth"); v != "" {
+		var err error
+		if depth, err = strconv.Atoi(v); err != nil || depth < 1 {
+			writeError(w, http.StatusBadRequest, "Invalid depth: "+v)
+			return
+		}
+	}
+	tree, err := getTree()
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "Failed to get file tree: "+err.Error())
 		return
 	}
+	if depth > 0 {
+		tree = filesystem.CutTree(tree, depth)
+	}
 
 	writeJSON(w, http.StatusOK, APIResponse{
 		Success: true,
@@ -49,17 +73,25 @@ func (s *Server) handleGetFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	content, err := s.fs.ReadFile(path)
+	ws := s.workspace()
+	actor := requestActor(r)
+	content, encrypted, err := ws.readNote(actor, path)
 	if err != nil {
-		writeError(w, http.StatusNotFound, "Failed to read file: "+err.Error())
+		writeReadError(w, err)
 		return
 	}
 
+	if m := s.recentsFor(r); m != nil {
+		m.AddFile(ws.rootDir, path)
+	}
+
 	writeJSON(w, http.StatusOK, APIResponse{
 		Success: true,
-		Data: map[string]string{
-			"path":    path,
-			"content": content,
+		Data: map[string]interface{}{
+			"path":      path,
+			"content":   content,
+			"encrypted": encrypted,
+			"version":   ws.currentVersion(actor, path),
 		},
 	})
 }
@@ -82,10 +114,45 @@ func (s *Server) handleCreateFile(w http.ResponseWriter, r *http.Request) {
 		req.Path += ".md"
 	}
 
-	if err := s.fs.CreateFile(req.Path, req.Content); err != nil {
-		writeError(w, http.StatusConflict, "Failed to create file: "+err.Error())
+	if err := s.workspace().files(requestActor(r)).CreateFile(req.Path, req.Content); err != nil {
+		writeFileError(w, http.StatusConflict, "Failed to create file: ", err)
+		return
+	}
+	s.recordAudit(r, audit.ActionFileCreate, req.Path, "")
+
+	writeJSON(w, http.StatusCreated, APIResponse{
+		Success: true,
+		Data: map[string]string{
+			"path": req.Path,
+		},
+	})
+}
+
+// handleRestoreFile re-creates a note another program removed, from the
+// content an editor still had open. A file that is back already is left
+// alone.
+func (s *Server) handleRestoreFile(w http.ResponseWriter, r *http.Request) {
+	var req FileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.Path == "" {
+		writeError(w, http.StatusBadRequest, "Path is required")
+		return
+	}
+
+	ws := s.workspace()
+	var err error
+	s.saves.do(saveKey(ws, req.Path), func() {
+		err = ws.files(requestActor(r)).CreateFile(req.Path, req.Content)
+	})
+	if err != nil {
+		writeFileError(w, http.StatusConflict, "Failed to restore file: ", err)
 		return
 	}
+	s.recordAudit(r, audit.ActionFileRestore, req.Path, "")
 
 	writeJSON(w, http.StatusCreated, APIResponse{
 		Success: true,
@@ -113,15 +180,31 @@ func (s *Server) handleUpdateFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := s.fs.WriteFile(path, req.Content); err != nil {
-		writeError(w, http.StatusInternalServerError, "Failed to update file: "+err.Error())
+	// Wait for saves to the note already queued over the WebSocket
+	ws := s.workspace()
+	actor := requestActor(r)
+	var err error
+	var version string
+	s.saves.do(saveKey(ws, path), func() {
+		if err = ws.writeNote(actor, path, req.Content); err == nil {
+			version = ws.currentVersion(actor, path)
+		}
+	})
+	if errors.Is(err, errNoteLocked) {
+		writeError(w, http.StatusLocked, err.Error())
+		return
+	} else if err != nil {
+		writeFileError(w, http.StatusInternalServerError, "Failed to update file: ", err)
 		return
 	}
+	s.recordAudit(r, audit.ActionFileWrite, path, "")
+	s.runSaveHooks(path)
 
 	writeJSON(w, http.StatusOK, APIResponse{
 		Success: true,
 		Data: map[string]string{
-			"path": path,
+			"path":    path,
+			"version": version,
 		},
 	})
 }
@@ -134,26 +217,35 @@ func (s *Server) handleDeleteFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := s.fs.DeleteFile(path); err != nil {
-		writeError(w, http.StatusInternalServerError, "Failed to delete file: "+err.Error())
+	if err := s.workspace().files(requestActor(r)).DeleteFile(path); err != nil {
+		writeFileError(w, http.StatusInternalServerError, "Failed to delete file: ", err)
 		return
 	}
+	s.recordAudit(r, audit.ActionFileDelete, path, "")
 
 	writeJSON(w, http.StatusOK, APIResponse{
 		Success: true,
 	})
 }
 
-
 // FileMetadata contains file information for tooltips
 type FileMetadata struct {
-	Path         string `json:"path"`
-	Size         int64  `json:"size"`
-	ModifiedTime string `json:"modifiedTime"`
-	IsDir        bool   `json:"isDir"`
+	Path         string          `json:"path"`
+	Size         int64           `json:"size"`         // Bytes; for a folder, of every file inside it
+	ModifiedTime time.Time       `json:"modifiedTime"` // For a folder, the latest change anywhere inside it
+	IsDir        bool            `json:"isDir"`
+	Contents     *FolderContents `json:"contents,omitempty"` // Only for folders
+}
+
+// FolderContents counts what's anywhere inside a folder
+type FolderContents struct {
+	Files   int `json:"files"`
+	Notes   int `json:"notes"`
+	Folders int `json:"folders"`
 }
 
-// handleGetFileMetadata returns metadata about a file
+// handleGetFileMetadata returns metadata about a file, or totals for a
+// folder so the client needn't walk it
 func (s *Server) handleGetFileMetadata(w http.ResponseWriter, r *http.Request) {
 	path := r.URL.Query().Get("path")
 	if path == "" {
@@ -162,9 +254,10 @@ func (s *Server) handleGetFileMetadata(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Validate and get full path
-	fullPath := filepath.Join(s.config.RootDir, path)
-	if !strings.HasPrefix(fullPath, s.config.RootDir) {
-		writeError(w, http.StatusBadRequest, "Invalid path")
+	files := s.workspace().files(requestActor(r))
+	fullPath, err := files.ResolvePath(path)
+	if err != nil {
+		writeFileError(w, http.StatusBadRequest, "Invalid path: ", err)
 		return
 	}
 
@@ -177,9 +270,23 @@ func (s *Server) handleGetFileMetadata(w http.ResponseWriter, r *http.Request) {
 	metadata := FileMetadata{
 		Path:         path,
 		Size:         info.Size(),
-		ModifiedTime: info.ModTime().Format("Jan 2, 2006 3:04 PM"),
+		ModifiedTime: info.ModTime(),
 		IsDir:        info.IsDir(),
 	}
+	if info.IsDir() {
+		stats, err := files.DirStats(path)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to read folder: "+err.Error())
+			return
+		}
+		metadata.Size = stats.Size
+		metadata.ModifiedTime = stats.LatestModified
+		metadata.Contents = &FolderContents{
+			Files:   stats.Files,
+			Notes:   stats.Notes,
+			Folders: stats.Folders,
+		}
+	}
 
 	writeJSON(w, http.StatusOK, APIResponse{
 		Success: true,
@@ -189,8 +296,22 @@ func (s *Server) handleGetFileMetadata(w http.ResponseWriter, r *http.Request) {
 
 // handleUploadImage handles image uploads
 func (s *Server) handleUploadImage(w http.ResponseWriter, r *http.Request) {
-	// Limit upload size to 10MB
-	r.ParseMultipartForm(10 << 20)
+	// Upload size is capped by limitRequestBody
+	if err := r.ParseMultipartForm(multipartMemory); err != nil {
+		if isTooLarge(err) {
+			writeTooLarge(w, s.bodyLimit(r))
+			return
+		}
+		writeError(w, http.StatusBadRequest, "Invalid upload: "+err.Error())
+		return
+	}
+
+	// Batches and folder drops send several "files", each optionally
+	// paired with a "paths" entry giving its place in the folder
+	if files := r.MultipartForm.File["files"]; len(files) > 0 {
+		s.uploadImages(w, r, files, r.MultipartForm.Value["paths"])
+		return
+	}
 
 	file, header, err := r.FormFile("image")
 	if err != nil {
@@ -218,48 +339,107 @@ func (s *Server) handleUploadImage(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "File is not an image")
 		return
 	}
+	if err := s.scanUpload(r, bytes.NewReader(data), header.Filename); err != nil {
+		writeScanError(w, err)
+		return
+	}
 
 	// Save image
-	path, err := s.fs.SaveImage(data, ext)
+	ws := s.workspace()
+	path, err := ws.files(requestActor(r)).SaveNoteImage(r.FormValue("note"), data, ext)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "Failed to save image: "+err.Error())
+		writeFileError(w, http.StatusInternalServerError, "Failed to save image: ", err)
 		return
 	}
+	s.recordAudit(r, audit.ActionImageUpload, path, "")
 
 	writeJSON(w, http.StatusCreated, APIResponse{
 		Success: true,
 		Data: map[string]string{
 			"path": path,
+			"url":  ws.fs.ImageURL(path),
 		},
 	})
 }
 
-// handleServeImage serves images from the assets directory
-func (s *Server) handleServeImage(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	filename := vars["filename"]
+// handleGetConfig returns the current configuration
+func (s *Server) handleGetConfig(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    s.clientConfig(s.workspace()),
+	})
+}
 
-	fullPath, err := s.fs.GetImagePath(filename)
-	if err != nil {
-		http.NotFound(w, r)
-		return
+// clientConfig is the configuration the web UI starts with
+func (s *Server) clientConfig(ws *workspace) map[string]interface{} {
+	// A saved theme outlasts the --theme flag
+	theme := s.config.Theme
+	if s.settings != nil && s.settings.Get().Theme != "" {
+		theme = s.settings.Get().Theme
+	}
+
+	initialFiles, activeFile := s.startupFiles(ws)
+	return map[string]interface{}{
+		"theme":        theme,
+		"rootDir":      ws.rootDir,
+		"initialFile":  s.config.InitialFile,
+		"initialFiles": initialFiles,
+		"activeFile":   activeFile,
+		"render":       ws.renderer.Load().Options(),
+		"vault":        ws.vault,
+		"git":          !s.config.NoGit,
+		"ai":           s.ai != nil,
+		"configFile":   s.config.ConfigFile,
+		"options":      s.config.Options,
 	}
+}
 
-	http.ServeFile(w, r, fullPath)
+// handleGetVersion returns which build of Inkwell is running
+func (s *Server) handleGetVersion(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    version.Get(),
+	})
 }
 
-// handleGetConfig returns the current configuration
-func (s *Server) handleGetConfig(w http.ResponseWriter, r *http.Request) {
+// TemplateInfo describes a note template
+type TemplateInfo struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+// handleListTemplates lists the notes in the vault's templates folder
+func (s *Server) handleListTemplates(w http.ResponseWriter, r *http.Request) {
+	ws := s.workspace()
+	templates := []TemplateInfo{}
+
+	if ws.vault.TemplatesDir != "" {
+		paths, err := ws.files(requestActor(r)).ListNotes(ws.vault.TemplatesDir)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to list templates: "+err.Error())
+			return
+		}
+		for _, path := range paths {
+			name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+			templates = append(templates, TemplateInfo{Name: name, Path: path})
+		}
+	}
+
 	writeJSON(w, http.StatusOK, APIResponse{
 		Success: true,
-		Data: map[string]interface{}{
-			"theme":       s.config.Theme,
-			"rootDir":     s.config.RootDir,
-			"initialFile": s.config.InitialFile,
-		},
+		Data:    templates,
 	})
 }
 
+// writeFileError reports a failed file operation, as 403 when folder
+// permissions refused it
+func writeFileError(w http.ResponseWriter, status int, message string, err error) {
+	if errors.Is(err, filesystem.ErrAccessDenied) {
+		status = http.StatusForbidden
+	}
+	writeError(w, status, message+err.Error())
+}
+
 // writeJSON writes a JSON response
 func writeJSON(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
@@ -294,11 +474,8 @@ func (s *Server) handleChangeDirectory(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Expand ~ to home directory
-	if strings.HasPrefix(req.Path, "~") {
-		home, err := os.UserHomeDir()
-		if err == nil {
-			req.Path = filepath.Join(home, req.Path[1:])
-		}
+	if expanded, err := filesystem.ExpandHome(req.Path); err == nil {
+		req.Path = expanded
 	}
 
 	// Convert to absolute path
@@ -324,63 +501,228 @@ func (s *Server) handleChangeDirectory(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Update the filesystem and config
-	s.config.RootDir = absPath
-	s.fs = filesystem.New(absPath)
-
-	// Restart the watcher for the new directory (with proper locking)
-	s.watcherMu.Lock()
-	oldWatcher := s.watcher
-	s.watcherMu.Unlock()
-
-	// Close old watcher first (this will close listener channels and terminate old goroutine)
-	if oldWatcher != nil {
-		log.Printf("Closing old watcher (watching %d directories)", oldWatcher.WatchCount())
-		oldWatcher.Close()
-	}
-
-	// Create new watcher
-	newWatcher, err := filesystem.NewWatcher(absPath)
+	// Swap in the new directory and its watcher
+	ws, err := s.switchWorkspace(absPath)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "Failed to watch directory: "+err.Error())
 		return
 	}
+	slog.Info("Watching directory", "path", absPath, "directories", ws.watcher.WatchCount())
 
-	// Update the watcher reference
-	s.watcherMu.Lock()
-	s.watcher = newWatcher
-	s.watcherMu.Unlock()
-
-	log.Printf("New watcher created for %s (watching %d directories)", absPath, newWatcher.WatchCount())
-
-	// Start forwarding events from new watcher
-	go s.forwardFileEvents()
+	s.recordAudit(r, audit.ActionDirectoryChange, absPath, "")
 
-	// Add to recents
-	if s.recents != nil {
-		s.recents.Add(absPath)
+	// Add to recents, noting the file to resume at
+	var lastFile string
+	if m := s.recentsFor(r); m != nil {
+		if last := m.LastFile(absPath); last != "" && ws.fs.FileExists(last) {
+			lastFile = last
+		}
+		m.Add(absPath)
 	}
 
 	// Try to open as git repository
 	if s.git != nil {
 		if _, err := s.git.OpenRepository(absPath); err != nil {
-			log.Printf("Note: %s is not a git repository", absPath)
+			slog.Info("Not a git repository", "path", absPath)
 		} else if repo := s.git.CurrentRepository(); repo != nil {
-			log.Printf("Git repository detected at root: %s (opened from: %s, branch: %s)", repo.Path(), absPath, repo.Branch())
+			slog.Info("Git repository detected", "root", repo.Path(), "path", absPath, "branch", repo.Branch())
 		}
 	}
 
 	writeJSON(w, http.StatusOK, APIResponse{
 		Success: true,
 		Data: map[string]string{
-			"path": absPath,
+			"path":     absPath,
+			"lastFile": lastFile,
 		},
 	})
 }
 
 // handleGetRecents returns recent locations
 func (s *Server) handleGetRecents(w http.ResponseWriter, r *http.Request) {
-	if s.recents == nil {
+	m := s.recentsFor(r)
+	if m == nil {
+		writeJSON(w, http.StatusOK, APIResponse{
+			Success: true,
+			Data:    []interface{}{},
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    s.describeRecents(m.GetAll()),
+	})
+}
+
+// RecentVault is a recent location with a summary of the vault in it
+type RecentVault struct {
+	recents.Location
+	Exists bool   `json:"exists"`
+	Notes  int    `json:"notes"`
+	Git    bool   `json:"git"`
+	Branch string `json:"branch,omitempty"`
+	Dirty  bool   `json:"dirty"`
+	Ahead  int    `json:"ahead,omitempty"`  // Commits not pushed yet
+	Behind int    `json:"behind,omitempty"` // Commits not pulled yet
+}
+
+// describeRecents counts the notes in each location and reads its git
+// state, looking at the locations in parallel
+func (s *Server) describeRecents(locations []recents.Location) []RecentVault {
+	vaults := make([]RecentVault, len(locations))
+
+	var wg sync.WaitGroup
+	for i, loc := range locations {
+		vaults[i].Location = loc
+		wg.Add(1)
+		go func(v *RecentVault) {
+			defer wg.Done()
+			s.describeVault(v)
+		}(&vaults[i])
+	}
+	wg.Wait()
+
+	return vaults
+}
+
+// describeVault fills in the summary of a single location
+func (s *Server) describeVault(v *RecentVault) {
+	if info, err := os.Stat(v.Path); err != nil || !info.IsDir() {
+		return
+	}
+	v.Exists = true
+
+	if notes, err := filesystem.New(v.Path).ListNotes(""); err == nil {
+		v.Notes = len(notes)
+	}
+
+	if s.config.NoGit {
+		return
+	}
+	repo, err := git.Open(v.Path)
+	if err != nil || repo == nil {
+		return
+	}
+	v.Git = true
+	v.Branch = repo.Branch()
+	if status, err := repo.Status(); err == nil {
+		v.Dirty = !status.IsClean
+		v.Ahead = status.Ahead
+		v.Behind = status.Behind
+	}
+}
+
+// StartPage is everything the landing screen shows
+type StartPage struct {
+	Current   string         `json:"current"`   // Directory being served
+	Pinned    []RecentVault  `json:"pinned"`    // Pinned locations
+	Recents   []RecentVault  `json:"recents"`   // Other recent locations, newest first
+	Files     []recents.File `json:"files"`     // Files recently used in the current directory
+	Favorites []recents.File `json:"favorites"` // Favorite files in the current directory
+}
+
+// handleGetStart returns the recent and pinned locations with their note
+// counts and git state, and the recent and favorite files of the current
+// directory, so the landing screen needs a single request
+func (s *Server) handleGetStart(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    s.startPage(r, s.workspace()),
+	})
+}
+
+// startPage gathers what the landing screen shows for a requester
+func (s *Server) startPage(r *http.Request, ws *workspace) StartPage {
+	page := StartPage{
+		Current:   ws.rootDir,
+		Pinned:    []RecentVault{},
+		Recents:   []RecentVault{},
+		Files:     []recents.File{},
+		Favorites: []recents.File{},
+	}
+
+	if m := s.recentsFor(r); m != nil {
+		for _, v := range s.describeRecents(m.GetAll()) {
+			if v.Pinned {
+				page.Pinned = append(page.Pinned, v)
+			} else {
+				page.Recents = append(page.Recents, v)
+			}
+		}
+		page.Files = m.Files(ws.rootDir, time.Time{})
+		page.Favorites = m.Favorites(ws.rootDir)
+	}
+	return page
+}
+
+// handleGetRecentFiles returns the files recently opened or saved in the
+// current workspace, optionally only those used in the last ?days=N days
+func (s *Server) handleGetRecentFiles(w http.ResponseWriter, r *http.Request) {
+	var since time.Time
+	if days := r.URL.Query().Get("days"); days != "" {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 1 {
+			writeError(w, http.StatusBadRequest, "Invalid days parameter")
+			return
+		}
+		since = time.Now().AddDate(0, 0, -n)
+	}
+
+	m := s.recentsFor(r)
+	if m == nil {
+		writeJSON(w, http.StatusOK, APIResponse{
+			Success: true,
+			Data:    []interface{}{},
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    m.Files(s.workspace().rootDir, since),
+	})
+}
+
+// PinRequest pins or unpins a recent location
+type PinRequest struct {
+	Path   string `json:"path"`
+	Pinned bool   `json:"pinned"`
+}
+
+// handlePinRecent pins a location so it stays in recents, or unpins it
+func (s *Server) handlePinRecent(w http.ResponseWriter, r *http.Request) {
+	var req PinRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Path == "" {
+		writeError(w, http.StatusBadRequest, "Path is required")
+		return
+	}
+
+	m := s.recentsFor(r)
+	if m == nil {
+		writeError(w, http.StatusInternalServerError, "Recents are not available")
+		return
+	}
+
+	if err := m.Pin(req.Path, req.Pinned); err != nil {
+		writeError(w, http.StatusBadRequest, "Failed to pin location: "+err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    s.describeRecents(m.GetAll()),
+	})
+}
+
+// handleGetFavorites returns the favorite files of the current workspace
+func (s *Server) handleGetFavorites(w http.ResponseWriter, r *http.Request) {
+	m := s.recentsFor(r)
+	if m == nil {
 		writeJSON(w, http.StatusOK, APIResponse{
 			Success: true,
 			Data:    []interface{}{},
@@ -388,10 +730,109 @@ func (s *Server) handleGetRecents(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	locations := s.recents.GetAll()
 	writeJSON(w, http.StatusOK, APIResponse{
 		Success: true,
-		Data:    locations,
+		Data:    m.Favorites(s.workspace().rootDir),
+	})
+}
+
+// handleAddFavorite adds a file of the current workspace to the favorites
+func (s *Server) handleAddFavorite(w http.ResponseWriter, r *http.Request) {
+	var req FileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	s.setFavorite(w, r, req.Path, true)
+}
+
+// handleRemoveFavorite removes a file from the favorites
+func (s *Server) handleRemoveFavorite(w http.ResponseWriter, r *http.Request) {
+	s.setFavorite(w, r, r.URL.Query().Get("path"), false)
+}
+
+func (s *Server) setFavorite(w http.ResponseWriter, r *http.Request, path string, favorite bool) {
+	if path == "" {
+		writeError(w, http.StatusBadRequest, "Path is required")
+		return
+	}
+
+	m := s.recentsFor(r)
+	if m == nil {
+		writeError(w, http.StatusInternalServerError, "Recents are not available")
+		return
+	}
+
+	ws := s.workspace()
+	if favorite {
+		if !ws.files(requestActor(r)).FileExists(path) {
+			writeError(w, http.StatusNotFound, "File not found")
+			return
+		}
+	}
+
+	changed, err := m.SetFavorite(ws.rootDir, path, favorite)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to update favorites: "+err.Error())
+		return
+	}
+	if !changed && !favorite {
+		writeError(w, http.StatusNotFound, "File is not a favorite")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    m.Favorites(ws.rootDir),
+	})
+}
+
+// handleRemoveRecent removes one location from the recents list
+func (s *Server) handleRemoveRecent(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		writeError(w, http.StatusBadRequest, "Path is required")
+		return
+	}
+
+	m := s.recentsFor(r)
+	if m == nil {
+		writeError(w, http.StatusInternalServerError, "Recents are not available")
+		return
+	}
+
+	removed, err := m.Remove(path)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to remove recent location: "+err.Error())
+		return
+	}
+	if !removed {
+		writeError(w, http.StatusNotFound, "Location is not in recents")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    s.describeRecents(m.GetAll()),
+	})
+}
+
+// handleClearRecents removes every location from the recents list
+func (s *Server) handleClearRecents(w http.ResponseWriter, r *http.Request) {
+	m := s.recentsFor(r)
+	if m == nil {
+		writeError(w, http.StatusInternalServerError, "Recents are not available")
+		return
+	}
+
+	if err := m.Clear(); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to clear recent locations: "+err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    []interface{}{},
 	})
 }
 
@@ -410,11 +851,8 @@ func (s *Server) handleListDirectories(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Expand ~ to home directory
-	if strings.HasPrefix(path, "~") {
-		home, err := os.UserHomeDir()
-		if err == nil {
-			path = filepath.Join(home, path[1:])
-		}
+	if expanded, err := filesystem.ExpandHome(path); err == nil {
+		path = expanded
 	}
 
 	// Convert to absolute path
@@ -440,7 +878,7 @@ func (s *Server) handleListDirectories(w http.ResponseWriter, r *http.Request) {
 	dirs := []DirEntry{}
 	for _, entry := range entries {
 		// Skip hidden files/directories
-		if strings.HasPrefix(entry.Name(), ".") {
+		if filesystem.IsHidden(filepath.Join(absPath, entry.Name())) {
 			continue
 		}
 
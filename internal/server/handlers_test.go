@@ -0,0 +1,249 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"inkwell/internal/git"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestConcurrentDirectoryChange(t *testing.T) {
+	dirs := []string{t.TempDir(), t.TempDir()}
+	srv := newTestServer(t, dirs[0])
+
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10; i++ {
+			body := strings.NewReader(`{"path":"` + filepath.ToSlash(dirs[i%2]) + `"}`)
+			resp, err := http.Post(ts.URL+"/api/directories", "application/json", body)
+			if err == nil {
+				resp.Body.Close()
+			}
+		}
+	}()
+
+	for i := 0; i < 20; i++ {
+		resp, err := http.Get(ts.URL + "/api/tree")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("Expected 200 from /api/tree, got %d", resp.StatusCode)
+		}
+	}
+	<-done
+}
+
+func TestVaultConfig(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		".inkwell/config.json": `{"extensions": [".md", ".mdx"], "templatesDir": "templates", "export": {"math": false}}`,
+		"page.mdx":             "# Page",
+		"templates/daily.md":   "# Daily",
+	}
+	for path, content := range files {
+		full := filepath.Join(dir, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	srv := newTestServer(t, dir)
+
+	tests := map[string]string{
+		"/api/tree":      `"page.mdx"`,
+		"/api/templates": `"name":"daily"`,
+		"/api/config":    `"math":false`,
+	}
+	for endpoint, want := range tests {
+		rec := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, endpoint, nil))
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("%s: response %s missing %s", endpoint, rec.Body.String(), want)
+		}
+	}
+}
+
+func TestRecentVaultInfo(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.md", "b.md", "image.png"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := git.Init(dir); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	srv := newTestServer(t, dir)
+
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/recents", nil))
+	for _, want := range []string{`"exists":true`, `"notes":2`, `"git":true`, `"dirty":true`} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("Recents %s missing %s", rec.Body.String(), want)
+		}
+	}
+}
+
+func TestStartPage(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.md"), []byte("# A"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	other := t.TempDir()
+
+	srv := newTestServer(t, dir)
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodPost, "/api/recents/pin", strings.NewReader(`{"path": "`+other+`", "pinned": true}`)),
+		httptest.NewRequest(http.MethodPost, "/api/recents/favorites", strings.NewReader(`{"path": "a.md"}`)),
+		httptest.NewRequest(http.MethodGet, "/api/files?path=a.md", nil),
+	} {
+		rec := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s %s: expected 200, got %d: %s", req.Method, req.URL, rec.Code, rec.Body.String())
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/start", nil))
+	var resp struct {
+		Data struct {
+			Current   string `json:"current"`
+			Pinned    []struct{ Path string }
+			Recents   []struct{ Path string }
+			Files     []struct{ Path string }
+			Favorites []struct{ Path string }
+		}
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Invalid response %s: %v", rec.Body.String(), err)
+	}
+
+	page := resp.Data
+	if page.Current != dir {
+		t.Errorf("Expected current %s, got %s", dir, page.Current)
+	}
+	if len(page.Pinned) != 1 || page.Pinned[0].Path != other {
+		t.Errorf("Expected %s pinned, got %v", other, page.Pinned)
+	}
+	if len(page.Recents) != 1 || page.Recents[0].Path != dir {
+		t.Errorf("Expected %s in recents, got %v", dir, page.Recents)
+	}
+	if len(page.Files) != 1 || page.Files[0].Path != "a.md" {
+		t.Errorf("Expected a.md in recent files, got %v", page.Files)
+	}
+	if len(page.Favorites) != 1 || page.Favorites[0].Path != "a.md" {
+		t.Errorf("Expected a.md in favorites, got %v", page.Favorites)
+	}
+}
+
+func TestExternalDeletion(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"plan.md", "old.md"} {
+		os.WriteFile(filepath.Join(dir, name), []byte("# "+name+"\n"), 0644)
+	}
+
+	srv := newTestServer(t, dir)
+
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+	for _, name := range []string{"plan.md", "old.md"} {
+		conn.WriteJSON(map[string]string{"type": "subscribe", "path": name})
+	}
+
+	type message struct {
+		Type    string `json:"type"`
+		Path    string `json:"path"`
+		Content string `json:"content"`
+		Data    struct {
+			EventType  string `json:"eventType"`
+			Restorable bool   `json:"restorable"`
+		} `json:"data"`
+	}
+	// waitFor reads frames until one holds a message of type for path
+	waitFor := func(typ, path string) message {
+		t.Helper()
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				t.Fatalf("Never received %s for %s: %v", typ, path, err)
+			}
+			if data[0] != '[' {
+				data = append(append([]byte{'['}, data...), ']')
+			}
+			var messages []message
+			json.Unmarshal(data, &messages)
+			for _, m := range messages {
+				if m.Type == "fileRemoved" && m.Path != path {
+					t.Fatalf("Unexpected fileRemoved for %s", m.Path)
+				}
+				if m.Type == typ && m.Path == path {
+					return m
+				}
+			}
+		}
+	}
+
+	// Deleting through Inkwell isn't an external deletion
+	req, _ := http.NewRequest(http.MethodDelete, ts.URL+"/api/files?path=old.md", nil)
+	if resp, err := http.DefaultClient.Do(req); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	waitFor("fileEvent", "old.md")
+
+	// The content sent back is the latest seen, edits by other programs
+	// included
+	os.WriteFile(filepath.Join(dir, "plan.md"), []byte("# Plan\n\nEdited elsewhere\n"), 0644)
+	waitFor("fileEvent", "plan.md")
+	os.Remove(filepath.Join(dir, "plan.md"))
+	removed := waitFor("fileRemoved", "plan.md")
+	if !removed.Data.Restorable || removed.Content != "# Plan\n\nEdited elsewhere\n" {
+		t.Fatalf("Unexpected fileRemoved: %+v", removed)
+	}
+
+	restore := func() int {
+		body, _ := json.Marshal(map[string]string{"path": "plan.md", "content": removed.Content})
+		resp, err := http.Post(ts.URL+"/api/files/restore", "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := restore(); code != http.StatusCreated {
+		t.Fatalf("Restore failed: %d", code)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "plan.md")); string(data) != removed.Content {
+		t.Errorf("Expected the note restored, got %q", data)
+	}
+	if code := restore(); code != http.StatusConflict {
+		t.Errorf("Expected restoring over a file refused, got %d", code)
+	}
+}
@@ -0,0 +1,200 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"inkwell/internal/audit"
+	"inkwell/internal/index"
+)
+
+// handleIndexStatus reports how far indexing the vault has got
+func (s *Server) handleIndexStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    s.workspace().index.Status(),
+	})
+}
+
+// handleReindex rebuilds the vault's index in the background, for changes
+// the watcher missed
+func (s *Server) handleReindex(w http.ResponseWriter, r *http.Request) {
+	ws := s.workspace()
+	ws.index.Start(s.hub.BroadcastIndexStatus)
+
+	writeJSON(w, http.StatusAccepted, APIResponse{
+		Success: true,
+		Data:    ws.index.Status(),
+	})
+}
+
+// handleSearch returns the notes matching ?q=. Results are incomplete
+// while the index is being built.
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("q")
+	if query == "" {
+		writeError(w, http.StatusBadRequest, "Query parameter q is required")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    s.indexFor(r).Search(query),
+	})
+}
+
+// handleBacklinks returns the notes linking to ?path=
+func (s *Server) handleBacklinks(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		writeError(w, http.StatusBadRequest, "Path parameter is required")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    s.indexFor(r).Backlinks(path),
+	})
+}
+
+// handleTags lists the vault's tags, or with ?tag= the notes carrying one
+func (s *Server) handleTags(w http.ResponseWriter, r *http.Request) {
+	ix := s.indexFor(r)
+	if tag := r.URL.Query().Get("tag"); tag != "" {
+		writeJSON(w, http.StatusOK, APIResponse{
+			Success: true,
+			Data:    ix.Tagged(tag),
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    ix.Tags(),
+	})
+}
+
+// ToggleTaskRequest checks or unchecks a task
+type ToggleTaskRequest struct {
+	Path string `json:"path"`
+	Line int    `json:"line"`
+	Done bool   `json:"done"`
+	Text string `json:"text,omitempty"` // The task's text as listed, to catch edits since
+}
+
+// handleTasks lists the vault's "- [ ]" tasks, filtered by ?done=, ?tag=,
+// ?path= (a folder or note), ?due_before= and ?due_after=
+func (s *Server) handleTasks(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := index.TaskFilter{
+		Tag:       q.Get("tag"),
+		Folder:    q.Get("path"),
+		DueBefore: q.Get("due_before"),
+		DueAfter:  q.Get("due_after"),
+	}
+	if done := q.Get("done"); done != "" {
+		d, err := strconv.ParseBool(done)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid done parameter")
+			return
+		}
+		filter.Done = &d
+	}
+
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    s.indexFor(r).Tasks(filter),
+	})
+}
+
+// handleToggleTask checks or unchecks a task, saving the change to its note
+func (s *Server) handleToggleTask(w http.ResponseWriter, r *http.Request) {
+	var req ToggleTaskRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	s.touch()
+	ws := s.workspace()
+	actor := requestActor(r)
+	// Read and written in turn with saves, so none is lost in between
+	s.saves.do(saveKey(ws, req.Path), func() {
+		content, _, err := ws.readNote(actor, req.Path)
+		if err != nil {
+			writeReadError(w, err)
+			return
+		}
+		updated, task, err := index.ToggleTask(req.Path, content, req.Line, req.Done, req.Text)
+		switch {
+		case errors.Is(err, index.ErrTaskChanged):
+			writeError(w, http.StatusConflict, "The task has changed; reload the list")
+			return
+		case err != nil:
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		if err := ws.writeNote(actor, req.Path, updated); err != nil {
+			if errors.Is(err, errNoteLocked) {
+				writeError(w, http.StatusLocked, err.Error())
+				return
+			}
+			writeError(w, http.StatusInternalServerError, "Failed to save note: "+err.Error())
+			return
+		}
+		ws.index.Update(req.Path)
+		s.recordAudit(r, audit.ActionFileWrite, req.Path, "")
+		s.runSaveHooks(req.Path)
+
+		writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: task})
+	})
+}
+
+// handleCalendar returns the notes dated in ?month= (YYYY-MM, default this
+// month) by their front matter or file name, with the daily-note streak
+func (s *Server) handleCalendar(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	month := r.URL.Query().Get("month")
+	if month == "" {
+		month = now.Format("2006-01")
+	} else if _, err := time.Parse("2006-01", month); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid month; use YYYY-MM")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    s.indexFor(r).Calendar(month, now),
+	})
+}
+
+// handleDuplicates returns groups of identical or near-identical notes, at
+// least ?threshold= (0-1, default 0.8) alike. ?path= limits the result to
+// the copies of one note.
+func (s *Server) handleDuplicates(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	threshold := index.DefaultDuplicateThreshold
+	if t := q.Get("threshold"); t != "" {
+		var err error
+		threshold, err = strconv.ParseFloat(t, 64)
+		if err != nil || threshold <= 0 || threshold > 1 {
+			writeError(w, http.StatusBadRequest, "Invalid threshold; use a number between 0 and 1")
+			return
+		}
+	}
+
+	path := q.Get("path")
+	if path != "" {
+		path = filepath.Clean(path)
+	}
+
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    s.indexFor(r).Duplicates(threshold, path),
+	})
+}
@@ -0,0 +1,132 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTasks(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "todo.md"), []byte("# Todo\n\n- [ ] Call Sam due:2024-05-02 #work\n- [x] Pay rent\n"), 0644)
+
+	srv := newTestServer(t, dir)
+
+	get := func(path string) []map[string]interface{} {
+		rec := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		var resp struct {
+			Data []map[string]interface{} `json:"data"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		return resp.Data
+	}
+
+	// The index is built in the background
+	deadline := time.Now().Add(5 * time.Second)
+	for len(get("/api/tasks")) < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	open := get("/api/tasks?done=false&tag=work")
+	if len(open) != 1 || open[0]["text"] != "Call Sam due:2024-05-02 #work" || open[0]["due"] != "2024-05-02" {
+		t.Fatalf("Unexpected open tasks %v", open)
+	}
+
+	body := `{"path":"todo.md","line":3,"done":true,"text":"Call Sam due:2024-05-02 #work"}`
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/tasks/toggle", strings.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "todo.md"))
+	if !strings.Contains(string(data), "- [x] Call Sam") {
+		t.Errorf("Expected the task checked in the note, got %q", data)
+	}
+	if open := get("/api/tasks?done=false"); len(open) != 0 {
+		t.Errorf("Expected no open tasks after toggling, got %v", open)
+	}
+
+	// A stale toggle is refused
+	rec = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/tasks/toggle", strings.NewReader(`{"path":"todo.md","line":4,"done":false,"text":"Pay bills"}`)))
+	if rec.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for a changed task, got %d", rec.Code)
+	}
+}
+
+func TestCalendar(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "journal"), 0755)
+	today := time.Now().Format("2006-01-02")
+	os.WriteFile(filepath.Join(dir, "journal", today+".md"), []byte("# Today\n"), 0644)
+
+	srv := newTestServer(t, dir)
+
+	var resp struct {
+		Data struct {
+			Month string `json:"month"`
+			Days  []struct {
+				Date string `json:"date"`
+			} `json:"days"`
+			Streak struct {
+				Current int `json:"current"`
+			} `json:"streak"`
+		} `json:"data"`
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for len(resp.Data.Days) == 0 && time.Now().Before(deadline) {
+		rec := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/calendar", nil))
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		time.Sleep(10 * time.Millisecond)
+	}
+	if resp.Data.Month != today[:7] || len(resp.Data.Days) != 1 || resp.Data.Days[0].Date != today || resp.Data.Streak.Current != 1 {
+		t.Errorf("Unexpected calendar %+v", resp.Data)
+	}
+
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/calendar?month=May", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a bad month, got %d", rec.Code)
+	}
+}
+
+func TestDuplicates(t *testing.T) {
+	dir := t.TempDir()
+	note := "# Plan\n\nShip the import tool, then write the migration guide and announce it on the mailing list.\n"
+	os.WriteFile(filepath.Join(dir, "plan.md"), []byte(note), 0644)
+	os.WriteFile(filepath.Join(dir, "plan (1).md"), []byte(note), 0644)
+	os.WriteFile(filepath.Join(dir, "other.md"), []byte("# Other\n\nSomething else entirely, with no words in common at all.\n"), 0644)
+
+	srv := newTestServer(t, dir)
+
+	var resp struct {
+		Data []struct {
+			Notes []struct {
+				Path string `json:"path"`
+			} `json:"notes"`
+			Identical bool `json:"identical"`
+		} `json:"data"`
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for len(resp.Data) == 0 && time.Now().Before(deadline) {
+		rec := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/duplicates", nil))
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(resp.Data) != 1 || len(resp.Data[0].Notes) != 2 || !resp.Data[0].Identical || resp.Data[0].Notes[0].Path != "plan (1).md" {
+		t.Errorf("Unexpected duplicates %+v", resp.Data)
+	}
+
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/duplicates?threshold=2", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a bad threshold, got %d", rec.Code)
+	}
+}
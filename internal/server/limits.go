@@ -0,0 +1,77 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+
+	"inkwell/internal/config"
+)
+
+// multipartMemory is how much of a multipart upload is kept in memory
+// before spilling to temporary files
+const multipartMemory = 8 << 20
+
+// limitRequestBody rejects request bodies over the configured limits with
+// a 413. Multipart uploads use the upload limit, everything else the body
+// limit.
+func (s *Server) limitRequestBody(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Body == nil || r.Body == http.NoBody {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		limit := s.bodyLimit(r)
+		if r.ContentLength > limit {
+			writeTooLarge(w, limit)
+			return
+		}
+
+		// Bodies without a declared length are cut off while reading
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// bodyLimit returns the size limit that applies to a request
+func (s *Server) bodyLimit(r *http.Request) int64 {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		if s.config.MaxUploadSize > 0 {
+			return s.config.MaxUploadSize
+		}
+		return config.DefaultMaxUploadSize
+	}
+
+	if s.config.MaxBodySize > 0 {
+		return s.config.MaxBodySize
+	}
+	return config.DefaultMaxBodySize
+}
+
+// isTooLarge reports whether an error came from exceeding a body limit
+func isTooLarge(err error) bool {
+	var maxErr *http.MaxBytesError
+	return errors.As(err, &maxErr)
+}
+
+// writeTooLarge writes a 413 naming the limit that was exceeded
+func writeTooLarge(w http.ResponseWriter, limit int64) {
+	writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Request body exceeds the %s limit", formatSize(limit)))
+}
+
+// formatSize formats a byte count for error messages
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<30 && n%(1<<30) == 0:
+		return fmt.Sprintf("%dGB", n>>30)
+	case n >= 1<<20 && n%(1<<20) == 0:
+		return fmt.Sprintf("%dMB", n>>20)
+	case n >= 1<<10 && n%(1<<10) == 0:
+		return fmt.Sprintf("%dKB", n>>10)
+	default:
+		return fmt.Sprintf("%d bytes", n)
+	}
+}
@@ -0,0 +1,27 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"inkwell/internal/config"
+)
+
+func TestMaxBodySize(t *testing.T) {
+	srv := newTestServer(t, t.TempDir(), func(cfg *config.Config) { cfg.MaxBodySize = 16 })
+
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	body := strings.NewReader(`{"path":"a.md","content":"far more than sixteen bytes"}`)
+	resp, err := http.Post(ts.URL+"/api/files", "application/json", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,154 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+
+	"inkwell/internal/audit"
+	"inkwell/internal/export"
+	"inkwell/internal/git"
+	"inkwell/internal/i18n"
+)
+
+// PublishRequest overrides the vault's publish settings for one publish
+type PublishRequest struct {
+	AuthRequest
+	Branch  string `json:"branch,omitempty"`  // Defaults to the vault's branch, then gh-pages
+	Repo    string `json:"repo,omitempty"`    // Separate repository to push to
+	Folder  string `json:"folder,omitempty"`  // Part of the vault to publish
+	Message string `json:"message,omitempty"` // Commit message
+}
+
+// PublishProgress is one line of a publish's progress
+type PublishProgress struct {
+	Stage   string `json:"stage"`             // rendering, committing or pushing
+	Current int    `json:"current,omitempty"` // Notes rendered so far
+	Total   int    `json:"total,omitempty"`   // Notes to render
+	Message string `json:"message,omitempty"` // Progress reported by the remote
+}
+
+// pushProgress passes what the remote reports while pushing on to the
+// stream
+type pushProgress struct {
+	stream *ndjsonStream
+}
+
+func (p *pushProgress) Write(b []byte) (int, error) {
+	for _, line := range strings.FieldsFunc(string(b), func(r rune) bool { return r == '\r' || r == '\n' }) {
+		if line = strings.TrimSpace(line); line != "" {
+			p.stream.send(PublishProgress{Stage: "pushing", Message: line})
+		}
+	}
+	return len(b), nil
+}
+
+// handlePublish renders the vault as a static site, commits it to a branch
+// (gh-pages by default) and pushes it. Progress is streamed as
+// newline-delimited JSON, ending with {"result": ...} or {"error": ...}.
+func (s *Server) handlePublish(w http.ResponseWriter, r *http.Request) {
+	if s.git == nil {
+		writeError(w, http.StatusBadRequest, "Git is disabled")
+		return
+	}
+
+	var req PublishRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+			return
+		}
+	}
+
+	ws := s.workspace()
+	settings := ws.vault.Publish
+	if req.Branch == "" {
+		req.Branch = settings.Branch
+	}
+	if req.Repo == "" {
+		req.Repo = settings.Repo
+	}
+	if req.Folder == "" {
+		req.Folder = settings.Folder
+	}
+	if req.Folder == "" {
+		req.Folder = "."
+	}
+
+	repo := s.git.CurrentRepository()
+	if req.Repo == "" && repo == nil {
+		writeError(w, http.StatusBadRequest, "Not a git repository; set a repository to publish to")
+		return
+	}
+
+	if !s.publishMu.TryLock() {
+		writeError(w, http.StatusConflict, "A publish is already running")
+		return
+	}
+	defer s.publishMu.Unlock()
+
+	out, err := os.MkdirTemp("", "inkwell-publish-*")
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to create output folder: "+err.Error())
+		return
+	}
+	defer os.RemoveAll(out)
+
+	stream := newNDJSONStream(w)
+	e := &export.Exporter{
+		FS:           ws.files(requestActor(r)),
+		Renderer:     ws.renderer.Load(),
+		Bibliography: ws.exportBibliography(),
+		Progress: func(done, total int) {
+			stream.send(PublishProgress{Stage: "rendering", Current: done, Total: total})
+		},
+		Locale: i18n.Negotiate(r.Header.Get("Accept-Language")),
+	}
+	if _, err := e.Export(r.Context(), export.FormatSite, req.Folder, out); err != nil {
+		stream.fail(http.StatusBadRequest, "Failed to render site: "+err.Error())
+		return
+	}
+
+	opts := git.PublishOptions{
+		Dir:      out,
+		Branch:   req.Branch,
+		Message:  req.Message,
+		Progress: &pushProgress{stream: stream},
+	}
+	commit := git.CommitOptions{}
+	s.commitDefaults(r, &commit)
+	opts.AuthorName, opts.AuthorEmail = commit.AuthorName, commit.AuthorEmail
+
+	remoteURL := req.Repo
+	if remoteURL == "" {
+		remoteURL = repo.GetRemoteURL()
+	}
+	if req.SSHKeyPath != "" || req.Username != "" {
+		opts.Auth = &git.AuthConfig{
+			Type:          git.DetectAuthType(remoteURL),
+			SSHKeyPath:    req.SSHKeyPath,
+			SSHPassphrase: req.SSHPassphrase,
+			Username:      req.Username,
+			Password:      req.Password,
+		}
+	}
+
+	stream.send(PublishProgress{Stage: "committing"})
+	var result *git.PublishResult
+	if req.Repo != "" {
+		result, err = git.PublishTo(r.Context(), req.Repo, opts)
+	} else {
+		result, err = repo.Publish(r.Context(), opts)
+	}
+	if err != nil {
+		stream.fail(http.StatusBadGateway, "Publish failed: "+err.Error())
+		return
+	}
+	if result.Commit != "" {
+		s.recordAudit(r, audit.ActionGitPush, "", "publish "+result.Branch)
+	}
+
+	stream.send(map[string]interface{}{"result": result})
+	stream.finish()
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gogit "github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+)
+
+func TestPublish(t *testing.T) {
+	remoteDir := t.TempDir()
+	if _, err := gogit.PlainInit(remoteDir, true); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	vault, err := gogit.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := vault.CreateRemote(&gitconfig.RemoteConfig{Name: "origin", URLs: []string{remoteDir}}); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(dir, "index.md"), []byte("# Home\n\nSee [notes](notes.md)\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "notes.md"), []byte("# Notes\n"), 0644)
+
+	srv := newTestServer(t, dir)
+
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/publish", nil))
+	if rec.Header().Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("Expected an NDJSON stream, got %d: %s", rec.Code, rec.Body.String())
+	}
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	if !strings.Contains(lines[0], `"stage":"rendering"`) {
+		t.Errorf("Expected rendering progress first, got %s", lines[0])
+	}
+	var last struct {
+		Result struct {
+			Branch string `json:"branch"`
+			Commit string `json:"commit"`
+		} `json:"result"`
+		Error string `json:"error"`
+	}
+	json.Unmarshal([]byte(lines[len(lines)-1]), &last)
+	if last.Error != "" || last.Result.Branch != "gh-pages" || last.Result.Commit == "" {
+		t.Fatalf("Unexpected final line: %s", lines[len(lines)-1])
+	}
+
+	remote, err := gogit.PlainOpen(remoteDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := remote.Reference("refs/heads/gh-pages", true)
+	if err != nil {
+		t.Fatalf("Expected gh-pages pushed: %v", err)
+	}
+	commit, err := remote.CommitObject(ref.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, err := commit.File("index.html")
+	if err != nil {
+		t.Fatalf("Expected index.html published: %v", err)
+	}
+	if html, _ := page.Contents(); !strings.Contains(html, `href="notes.html"`) {
+		t.Errorf("Expected links pointing at pages, got %s", html)
+	}
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"inkwell/internal/filesystem"
+)
+
+// rawContentType returns the Content-Type for a file served raw
+func rawContentType(path string) string {
+	ext := strings.ToLower(filepath.Ext(path))
+	switch ext {
+	case ".md", ".markdown":
+		return "text/markdown; charset=utf-8"
+	case "":
+		return ""
+	}
+	return mime.TypeByExtension(ext)
+}
+
+// activeContentTypes can run scripts when a browser opens them, which from
+// /raw/ would run with the signed-in user's access to the API
+var activeContentTypes = map[string]bool{
+	"text/html":              true,
+	"application/xhtml+xml":  true,
+	"image/svg+xml":          true,
+	"text/javascript":        true,
+	"application/javascript": true,
+	"text/xml":               true,
+	"application/xml":        true,
+}
+
+// isActiveContent reports whether a file served raw could run scripts
+func isActiveContent(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && activeContentTypes[mediaType]
+}
+
+// handleServeRaw streams a file from the root directory as-is
+func (s *Server) handleServeRaw(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/raw/")
+	if path == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	fs := s.workspace().files(requestActor(r))
+	if _, err := fs.ResolvePath(path); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, filesystem.ErrAccessDenied) {
+			status = http.StatusForbidden
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	file, info, err := fs.Open(path)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer file.Close()
+
+	if info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Without an explicit type, ServeContent sniffs the first 512 bytes
+	contentType := rawContentType(path)
+	if contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+
+	// Files are whatever users uploaded, so they are never run as Inkwell's
+	// own pages: documents open in a sandbox without scripts, and anything
+	// that could run scripts is downloaded instead. Images still display.
+	policy := w.Header().Get("Content-Security-Policy")
+	if policy != "" {
+		policy += "; "
+	}
+	w.Header().Set("Content-Security-Policy", policy+"sandbox")
+	if r.URL.Query().Get("download") == "1" || isActiveContent(contentType) {
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+			"filename": filepath.Base(path),
+		}))
+	}
+
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, info.Name(), info.ModTime(), file)
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestServeRawActiveContent(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"page.html":   "<script>fetch('/api/files')</script>",
+		"image.svg":   `<svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script></svg>`,
+		"script.js":   "alert(1)",
+		"feed.xml":    "<?xml version=\"1.0\"?><feed/>",
+		"note.md":     "# Note",
+		"photo.png":   "\x89PNG\r\n\x1a\n",
+		"noextension": "<html><script>alert(1)</script></html>",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := newTestServer(t, dir)
+
+	for name := range files {
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/raw/"+name, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status %d", name, rec.Code)
+		}
+		if csp := rec.Header().Get("Content-Security-Policy"); !strings.HasSuffix(csp, "; sandbox") {
+			t.Errorf("%s: Content-Security-Policy = %q, want a sandbox", name, csp)
+		}
+
+		attachment := strings.HasPrefix(rec.Header().Get("Content-Disposition"), "attachment")
+		switch filepath.Ext(name) {
+		case ".html", ".svg", ".js", ".xml":
+			if !attachment {
+				t.Errorf("%s: served inline as %s", name, rec.Header().Get("Content-Type"))
+			}
+		default:
+			if attachment {
+				t.Errorf("%s: served as an attachment", name)
+			}
+		}
+	}
+}
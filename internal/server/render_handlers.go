@@ -0,0 +1,296 @@
+package server
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"inkwell/internal/render"
+
+	"github.com/gorilla/mux"
+)
+
+// handleRender returns the HTML rendering of a markdown file
+func (s *Server) handleRender(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		writeError(w, http.StatusBadRequest, "Path parameter is required")
+		return
+	}
+
+	ws := s.workspace()
+	content, _, err := ws.readNote(requestActor(r), path)
+	if err != nil {
+		writeReadError(w, err)
+		return
+	}
+
+	html, err := ws.renderPreview(requestActor(r), content)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data: map[string]string{
+			"path": path,
+			"html": html,
+		},
+	})
+}
+
+// renderPreview renders a note for the preview. Versioned image URLs let
+// the browser keep unchanged images cached.
+func (ws *workspace) renderPreview(actor, content string) (string, error) {
+	files := ws.files(actor)
+	return ws.renderer.Load().RenderLinks([]byte(content), func(dest string) string {
+		return ws.versionedImageURL(files, dest)
+	})
+}
+
+// handleRenderFolder returns the landing page of a folder, its index.md or
+// README.md, both as markdown and rendered. The root is the default.
+func (s *Server) handleRenderFolder(w http.ResponseWriter, r *http.Request) {
+	folder := r.URL.Query().Get("path")
+
+	ws := s.workspace()
+	actor := requestActor(r)
+	path, err := ws.files(actor).LandingPage(folder)
+	if err != nil {
+		writeFileError(w, http.StatusNotFound, "Failed to find landing page: ", err)
+		return
+	}
+
+	content, _, err := ws.readNote(actor, path)
+	if err != nil {
+		writeReadError(w, err)
+		return
+	}
+	html, err := ws.renderPreview(actor, content)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data: map[string]string{
+			"folder":  filepath.ToSlash(folder),
+			"path":    filepath.ToSlash(path),
+			"content": content,
+			"html":    html,
+		},
+	})
+}
+
+// exportNavigation reads the toc, anchors and slug parameters of an export,
+// falling back to the vault's export options
+func (ws *workspace) exportNavigation(query url.Values) (render.Navigation, error) {
+	nav := ws.vault.Export.Navigation()
+	for name, field := range map[string]*bool{"toc": &nav.TOC, "anchors": &nav.Anchors} {
+		if v := query.Get(name); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return nav, fmt.Errorf("invalid %s parameter", name)
+			}
+			*field = b
+		}
+	}
+	if query.Has("slug") {
+		nav.Slug = query.Get("slug")
+		if !render.ValidSlug(nav.Slug) {
+			return nav, fmt.Errorf("unknown slug %q (use %s)", nav.Slug, strings.Join(render.Slugs, ", "))
+		}
+	}
+	return nav, nil
+}
+
+// handleOutline returns the headings of a note with the anchors they are
+// exported with
+func (s *Server) handleOutline(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		writeError(w, http.StatusBadRequest, "Path parameter is required")
+		return
+	}
+
+	ws := s.workspace()
+	content, _, err := ws.readNote(requestActor(r), path)
+	if err != nil {
+		writeReadError(w, err)
+		return
+	}
+	nav, err := ws.exportNavigation(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"path":     path,
+			"headings": ws.renderer.Load().WithNavigation(nav).Outline([]byte(content)),
+		},
+	})
+}
+
+// handleExportHTML returns a markdown file as a standalone HTML document
+func (s *Server) handleExportHTML(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		writeError(w, http.StatusBadRequest, "Path parameter is required")
+		return
+	}
+
+	ws := s.workspace()
+	content, _, err := ws.readNote(requestActor(r), path)
+	if err != nil {
+		writeReadError(w, err)
+		return
+	}
+
+	nav, err := ws.exportNavigation(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	source, refs := ws.exportBibliography().Resolve(content)
+	body, err := ws.renderer.Load().WithNavigation(nav).Render([]byte(source))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	body += refs
+
+	title := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	s.setDocumentPolicy(w)
+	if r.URL.Query().Get("download") != "" {
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+			"filename": title + ".html",
+		}))
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(ws.renderer.Load().Document(title, body)))
+}
+
+// PlantUMLRequest represents a diagram rendering request
+type PlantUMLRequest struct {
+	Source string `json:"source"`
+}
+
+// handleRenderPlantUML renders a PlantUML diagram and returns its cached URL
+func (s *Server) handleRenderPlantUML(w http.ResponseWriter, r *http.Request) {
+	plantuml := s.workspace().plantuml
+	if plantuml == nil {
+		writeError(w, http.StatusServiceUnavailable, "PlantUML rendering is not configured")
+		return
+	}
+
+	var req PlantUMLRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if strings.TrimSpace(req.Source) == "" {
+		writeError(w, http.StatusBadRequest, "Source is required")
+		return
+	}
+
+	svg, err := plantuml.RenderSVG(r.Context(), req.Source)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, "Failed to render diagram: "+err.Error())
+		return
+	}
+
+	hash := plantuml.Hash(req.Source)
+	src := "/plantuml/" + hash + ".svg"
+	if _, err := plantuml.Cached(hash); err != nil {
+		// Diagrams with errors aren't cached, so they come back inline
+		src = "data:image/svg+xml;base64," + base64.StdEncoding.EncodeToString(svg)
+	}
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data: map[string]string{
+			"hash": hash,
+			"url":  src,
+		},
+	})
+}
+
+// handleServePlantUML serves a previously rendered diagram from the cache
+func (s *Server) handleServePlantUML(w http.ResponseWriter, r *http.Request) {
+	plantuml := s.workspace().plantuml
+	if plantuml == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	svg, err := plantuml.Cached(mux.Vars(r)["hash"])
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Diagrams are keyed by content hash, so they never change
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Header().Set("Cache-Control", "private, max-age=31536000, immutable")
+	w.Write(svg)
+}
+
+// handlePrint returns a print-friendly HTML page for a markdown file
+func (s *Server) handlePrint(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		http.Error(w, "Path parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	ws := s.workspace()
+	content, _, err := ws.readNote(requestActor(r), path)
+	if errors.Is(err, errNoteLocked) {
+		http.Error(w, err.Error(), http.StatusLocked)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to read file: "+err.Error(), http.StatusNotFound)
+		return
+	}
+
+	nav, err := ws.exportNavigation(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	source, refs := ws.exportBibliography().Resolve(content)
+	body, err := ws.renderer.Load().WithNavigation(nav).RenderPrint([]byte(source))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	body += refs
+
+	// Resolve relative images and links through raw file serving
+	baseURL := "/raw/"
+	if dir := filepath.ToSlash(filepath.Dir(path)); dir != "." {
+		baseURL += dir + "/"
+	}
+
+	title := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	s.setDocumentPolicy(w)
+	w.Write([]byte(ws.renderer.Load().PrintDocument(title, body, baseURL)))
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFolderLandingPage(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "projects"), 0755)
+	os.MkdirAll(filepath.Join(dir, "journal"), 0755)
+	os.WriteFile(filepath.Join(dir, "projects", "README.md"), []byte("# Projects\n\nEverything in flight.\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "projects", "launch.md"), []byte("# Launch\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "journal", "monday.md"), []byte("# Monday\n"), 0644)
+
+	srv := newTestServer(t, dir)
+
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/tree", nil))
+	if !strings.Contains(rec.Body.String(), `"index":"projects/README.md"`) {
+		t.Errorf("Expected the tree to flag the projects landing page, got %s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/render/folder?path=projects", nil))
+	var resp struct {
+		Data map[string]string `json:"data"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if rec.Code != http.StatusOK || resp.Data["path"] != "projects/README.md" || !strings.Contains(resp.Data["html"], "Everything in flight.") {
+		t.Errorf("Unexpected folder render %d: %v", rec.Code, resp.Data)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/render/folder?path=journal", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a folder without a landing page, got %d", rec.Code)
+	}
+}
+
+func TestMarkdownFlavor(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "note.md"), []byte("| a |\n|---|\n| 1 |\n\nfirst\nsecond\n"), 0644)
+
+	srv := newTestServer(t, dir)
+
+	do := func(method, target, body string) string {
+		rec := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s %s: %d %s", method, target, rec.Code, rec.Body.String())
+		}
+		return rec.Body.String()
+	}
+	flavor := func() string {
+		var config struct {
+			Data struct {
+				Render struct {
+					Flavor string `json:"flavor"`
+				} `json:"render"`
+			} `json:"data"`
+		}
+		json.Unmarshal([]byte(do(http.MethodGet, "/api/config", "")), &config)
+		return config.Data.Render.Flavor
+	}
+	render := func() string {
+		var rendered struct {
+			Data struct {
+				HTML string `json:"html"`
+			} `json:"data"`
+		}
+		json.Unmarshal([]byte(do(http.MethodGet, "/api/render?path=note.md", "")), &rendered)
+		return rendered.Data.HTML
+	}
+
+	if got := flavor(); got != "github" {
+		t.Errorf("Expected the github flavor by default, got %q", got)
+	}
+	if body := render(); !strings.Contains(body, "<table>") {
+		t.Errorf("Expected a table rendered, got %s", body)
+	}
+
+	do(http.MethodPut, "/api/settings", `{"markdownFlavor": "obsidian"}`)
+	if got := flavor(); got != "obsidian" {
+		t.Errorf("Expected the obsidian flavor reported, got %q", got)
+	}
+	if body := render(); !strings.Contains(body, "<br>") {
+		t.Errorf("Expected line breaks kept, got %s", body)
+	}
+
+	do(http.MethodPut, "/api/settings", `{"markdownFlavor": "commonmark"}`)
+	if body := render(); strings.Contains(body, "<table>") {
+		t.Errorf("Expected no tables with commonmark, got %s", body)
+	}
+}
+
+func TestExportDownloadName(t *testing.T) {
+	dir := t.TempDir()
+	name := `q3 "final"; draft.md`
+	os.WriteFile(filepath.Join(dir, name), []byte("# Plan\n"), 0644)
+
+	srv := newTestServer(t, dir)
+
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/export/html?download=1&path="+url.QueryEscape(name), nil))
+	disposition, params, err := mime.ParseMediaType(rec.Header().Get("Content-Disposition"))
+	if err != nil || disposition != "attachment" || params["filename"] != `q3 "final"; draft.html` {
+		t.Errorf("Unexpected Content-Disposition %q", rec.Header().Get("Content-Disposition"))
+	}
+}
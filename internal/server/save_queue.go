@@ -0,0 +1,102 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+	"sync"
+)
+
+// saveQueue runs writes to each note one at a time, in the order they
+// arrived, so saves from several clients, or autosave racing a client's
+// own typing, can't interleave. Writes to different notes run in parallel.
+//
+// Everything that writes notes goes through it: a single note with do or
+// enqueue, and anything that may write many at once, such as a git pull
+// or a zip import, with all.
+type saveQueue struct {
+	mu      sync.Mutex
+	pending map[string][]func() // Writes waiting for each note, the running one first
+
+	// writes is held shared by each note write while it runs, and
+	// exclusively by writes to many notes
+	writes sync.RWMutex
+}
+
+func newSaveQueue() *saveQueue {
+	return &saveQueue{pending: make(map[string][]func())}
+}
+
+// saveKey identifies a note across workspaces, however its path is spelled
+func saveKey(ws *workspace, path string) string {
+	return ws.rootDir + "\x00" + filepath.ToSlash(filepath.Clean(path))
+}
+
+// enqueue runs write after the writes already queued for key, without
+// waiting for it
+func (q *saveQueue) enqueue(key string, write func()) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.pending[key] = append(q.pending[key], write)
+	if len(q.pending[key]) == 1 {
+		go q.drain(key)
+	}
+}
+
+// do runs write after the writes already queued for key and waits for it
+func (q *saveQueue) do(key string, write func()) {
+	done := make(chan struct{})
+	q.enqueue(key, func() {
+		defer close(done)
+		write()
+	})
+	<-done
+}
+
+// all runs write once the note writes already running are done, holding
+// back new ones until it returns. write must not wait for a note write.
+func (q *saveQueue) all(write func()) {
+	q.writes.Lock()
+	defer q.writes.Unlock()
+	write()
+}
+
+// drain runs the writes queued for key until there are none left
+func (q *saveQueue) drain(key string) {
+	for {
+		q.mu.Lock()
+		write := q.pending[key][0]
+		q.mu.Unlock()
+
+		q.writes.RLock()
+		write()
+		q.writes.RUnlock()
+
+		q.mu.Lock()
+		q.pending[key] = q.pending[key][1:]
+		if len(q.pending[key]) == 0 {
+			delete(q.pending, key)
+			q.mu.Unlock()
+			return
+		}
+		q.mu.Unlock()
+	}
+}
+
+// noteVersion is a token for a note's content on disk, which changes
+// whenever the file does. Clients send back the version they edited so a
+// save over someone else's newer write is refused.
+func noteVersion(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:8])
+}
+
+// currentVersion returns the version of a note on disk for actor, or ""
+// if it can't be read
+func (ws *workspace) currentVersion(actor, path string) string {
+	raw, err := ws.files(actor).ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return noteVersion(raw)
+}
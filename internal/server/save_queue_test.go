@@ -0,0 +1,52 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSaveQueueAllWaitsForNoteWrites(t *testing.T) {
+	q := newSaveQueue()
+
+	// A note write is running
+	running := make(chan struct{})
+	finish := make(chan struct{})
+	q.enqueue("a", func() {
+		close(running)
+		<-finish
+	})
+	<-running
+
+	// Writing everything waits for it
+	allDone := make(chan struct{})
+	go q.all(func() { close(allDone) })
+	select {
+	case <-allDone:
+		t.Fatal("all ran while a note write was running")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(finish)
+	<-allDone
+
+	// and holds back note writes while it runs
+	var order []string
+	release := make(chan struct{})
+	started := make(chan struct{})
+	go q.all(func() {
+		close(started)
+		<-release
+		order = append(order, "all")
+	})
+	<-started
+	written := make(chan struct{})
+	go func() {
+		q.do("b", func() { order = append(order, "b") })
+		close(written)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	<-written
+	if len(order) != 2 || order[0] != "all" {
+		t.Errorf("Expected the note write after all, got %v", order)
+	}
+}
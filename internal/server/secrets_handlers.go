@@ -0,0 +1,205 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+
+	"inkwell/internal/audit"
+	"inkwell/internal/git"
+	"inkwell/internal/secrets"
+
+	"github.com/gorilla/mux"
+)
+
+// CredentialRequest represents a credential to store or a change to one.
+// An empty secret keeps the stored one when updating.
+type CredentialRequest struct {
+	Kind     secrets.Kind `json:"kind"`
+	Target   string       `json:"target"`
+	Username string       `json:"username,omitempty"`
+	Label    string       `json:"label,omitempty"`
+	Secret   string       `json:"secret,omitempty"`
+}
+
+func (req CredentialRequest) credential() secrets.Credential {
+	return secrets.Credential{Kind: req.Kind, Target: req.Target, Username: req.Username, Label: req.Label, Secret: req.Secret}
+}
+
+// writeSecretsError reports a failed credential store operation
+func writeSecretsError(w http.ResponseWriter, prefix string, err error) {
+	switch {
+	case errors.Is(err, secrets.ErrNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, secrets.ErrLocked), errors.Is(err, secrets.ErrNotConfigured):
+		writeError(w, http.StatusLocked, err.Error())
+	case errors.Is(err, secrets.ErrConfigured):
+		writeError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, secrets.ErrWrongPassphrase):
+		// Not 401, which the UI takes to mean signing in again
+		writeError(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, secrets.ErrInvalid), errors.Is(err, secrets.ErrPassphraseRequired):
+		writeError(w, http.StatusBadRequest, err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, prefix+err.Error())
+	}
+}
+
+// handleListSecrets returns the credential store's status and the stored
+// credentials, without their secrets
+func (s *Server) handleListSecrets(w http.ResponseWriter, r *http.Request) {
+	if s.secrets == nil {
+		writeError(w, http.StatusServiceUnavailable, "The credential store is not available")
+		return
+	}
+	creds, err := s.secrets.List()
+	if err != nil {
+		writeSecretsError(w, "Failed to read credentials: ", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"status":      s.secrets.Status(),
+			"credentials": creds,
+		},
+	})
+}
+
+// handleSetupSecrets creates the credential store's key, in the OS
+// keychain or from a passphrase
+func (s *Server) handleSetupSecrets(w http.ResponseWriter, r *http.Request) {
+	if s.secrets == nil {
+		writeError(w, http.StatusServiceUnavailable, "The credential store is not available")
+		return
+	}
+	var req PassphraseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := s.secrets.Setup(req.Passphrase); err != nil {
+		writeSecretsError(w, "Failed to set up the credential store: ", err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, APIResponse{Success: true, Data: s.secrets.Status()})
+}
+
+// handleUnlockSecrets unlocks a passphrase-protected credential store for
+// the whole server, until it restarts or the store is locked
+func (s *Server) handleUnlockSecrets(w http.ResponseWriter, r *http.Request) {
+	if s.secrets == nil {
+		writeError(w, http.StatusServiceUnavailable, "The credential store is not available")
+		return
+	}
+	var req PassphraseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := s.secrets.Unlock(req.Passphrase); err != nil {
+		writeSecretsError(w, "Failed to unlock: ", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: s.secrets.Status()})
+}
+
+// handleLockSecrets locks a passphrase-protected credential store
+func (s *Server) handleLockSecrets(w http.ResponseWriter, r *http.Request) {
+	if s.secrets == nil {
+		writeError(w, http.StatusServiceUnavailable, "The credential store is not available")
+		return
+	}
+	s.secrets.Lock()
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: s.secrets.Status()})
+}
+
+// handleCreateSecret stores a credential, setting up the store with the OS
+// keychain if it isn't yet
+func (s *Server) handleCreateSecret(w http.ResponseWriter, r *http.Request) {
+	if s.secrets == nil {
+		writeError(w, http.StatusServiceUnavailable, "The credential store is not available")
+		return
+	}
+	var req CredentialRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	cred, err := s.secrets.Add(req.credential())
+	if err != nil {
+		writeSecretsError(w, "Failed to store credential: ", err)
+		return
+	}
+	s.recordAudit(r, audit.ActionSecretCreate, cred.Target, string(cred.Kind))
+
+	writeJSON(w, http.StatusCreated, APIResponse{Success: true, Data: cred})
+}
+
+// handleUpdateSecret changes a stored credential
+func (s *Server) handleUpdateSecret(w http.ResponseWriter, r *http.Request) {
+	if s.secrets == nil {
+		writeError(w, http.StatusServiceUnavailable, "The credential store is not available")
+		return
+	}
+	var req CredentialRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	cred, err := s.secrets.Update(mux.Vars(r)["id"], req.credential())
+	if err != nil {
+		writeSecretsError(w, "Failed to update credential: ", err)
+		return
+	}
+	s.recordAudit(r, audit.ActionSecretUpdate, cred.Target, string(cred.Kind))
+
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: cred})
+}
+
+// handleDeleteSecret removes a stored credential
+func (s *Server) handleDeleteSecret(w http.ResponseWriter, r *http.Request) {
+	if s.secrets == nil {
+		writeError(w, http.StatusServiceUnavailable, "The credential store is not available")
+		return
+	}
+	id := mux.Vars(r)["id"]
+	if err := s.secrets.Delete(id); err != nil {
+		writeSecretsError(w, "Failed to delete credential: ", err)
+		return
+	}
+	s.recordAudit(r, audit.ActionSecretDelete, id, "")
+
+	writeJSON(w, http.StatusOK, APIResponse{Success: true})
+}
+
+// rememberAuth saves the credentials a push, pull or fetch succeeded with
+// when the request asked for it, so they aren't needed the next time
+func (s *Server) rememberAuth(r *http.Request, remoteURL string, req AuthRequest) {
+	if !req.Remember || s.secrets == nil {
+		return
+	}
+
+	var cred secrets.Credential
+	switch {
+	case git.DetectAuthType(remoteURL) == git.AuthTypeHTTPS && req.Password != "":
+		u, err := url.Parse(remoteURL)
+		if err != nil || u.Host == "" {
+			return
+		}
+		cred = secrets.Credential{Kind: secrets.KindToken, Target: u.Host, Username: req.Username, Secret: req.Password}
+	case req.SSHPassphrase != "":
+		cred = secrets.Credential{Kind: secrets.KindSSHPassphrase, Target: req.SSHKeyPath, Secret: req.SSHPassphrase}
+	default:
+		return
+	}
+
+	saved, err := s.secrets.Add(cred)
+	if err != nil {
+		slog.Warn("Failed to remember git credentials", "error", err)
+		return
+	}
+	s.recordAudit(r, audit.ActionSecretCreate, saved.Target, string(saved.Kind))
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"inkwell/internal/config"
+)
+
+// appPolicy is the Content-Security-Policy for the UI and API. Styles may
+// be inline because the editor and diagram renderers inject them.
+const appPolicy = "default-src 'self'; " +
+	"script-src 'self'; " +
+	"style-src 'self' 'unsafe-inline' https://fonts.googleapis.com; " +
+	"font-src 'self' data: https://fonts.gstatic.com; " +
+	"img-src 'self' data: blob: http: https:; " +
+	"connect-src 'self' ws: wss:; " +
+	"object-src 'none'; base-uri 'self'; form-action 'self'"
+
+// documentPolicy applies to standalone HTML pages (sign-in, print and
+// export), which carry their own inline scripts and load KaTeX from its CDN
+const documentPolicy = "default-src 'self'; " +
+	"script-src 'self' 'unsafe-inline' https://cdn.jsdelivr.net; " +
+	"style-src 'self' 'unsafe-inline' https://cdn.jsdelivr.net; " +
+	"font-src 'self' data: https://cdn.jsdelivr.net; " +
+	"img-src 'self' data: blob: http: https:; " +
+	"connect-src 'self'; " +
+	"object-src 'none'; base-uri 'self'; form-action 'self'"
+
+// securityHeaders sets the Content-Security-Policy and related headers on
+// every response
+func (s *Server) securityHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		h.Set("X-Content-Type-Options", "nosniff")
+		h.Set("Referrer-Policy", s.referrerPolicy())
+		h.Set("Content-Security-Policy", s.contentSecurityPolicy(appPolicy))
+
+		// Older browsers ignore frame-ancestors, so mirror the common cases
+		switch s.frameAncestors() {
+		case "'none'":
+			h.Set("X-Frame-Options", "DENY")
+		case "'self'":
+			h.Set("X-Frame-Options", "SAMEORIGIN")
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// setDocumentPolicy relaxes the policy for a standalone HTML page. A
+// policy given with --csp is left alone.
+func (s *Server) setDocumentPolicy(w http.ResponseWriter) {
+	if s.config.ContentSecurityPolicy == "" {
+		w.Header().Set("Content-Security-Policy", s.contentSecurityPolicy(documentPolicy))
+	}
+}
+
+// contentSecurityPolicy returns the configured policy, or base when none
+// was configured, with the allowed frame ancestors appended
+func (s *Server) contentSecurityPolicy(base string) string {
+	policy := base
+	if s.config.ContentSecurityPolicy != "" {
+		policy = s.config.ContentSecurityPolicy
+	}
+	if strings.Contains(policy, "frame-ancestors") {
+		return policy
+	}
+	return strings.TrimSuffix(strings.TrimSpace(policy), ";") + "; frame-ancestors " + s.frameAncestors()
+}
+
+// frameAncestors returns the sources allowed to embed Inkwell in a frame
+func (s *Server) frameAncestors() string {
+	if s.config.FrameAncestors != "" {
+		return s.config.FrameAncestors
+	}
+	return config.DefaultFrameAncestors
+}
+
+// referrerPolicy returns the configured Referrer-Policy
+func (s *Server) referrerPolicy() string {
+	if s.config.ReferrerPolicy != "" {
+		return s.config.ReferrerPolicy
+	}
+	return config.DefaultReferrerPolicy
+}
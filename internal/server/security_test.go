@@ -0,0 +1,42 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"inkwell/internal/config"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	srv := newTestServer(t, t.TempDir())
+
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/config", nil))
+
+	csp := rec.Header().Get("Content-Security-Policy")
+	if !strings.Contains(csp, "frame-ancestors 'none'") || !strings.Contains(csp, "script-src 'self';") {
+		t.Errorf("Unexpected Content-Security-Policy: %s", csp)
+	}
+	if rec.Header().Get("X-Content-Type-Options") != "nosniff" {
+		t.Error("Missing X-Content-Type-Options")
+	}
+	if rec.Header().Get("X-Frame-Options") != "DENY" {
+		t.Errorf("X-Frame-Options = %q, want DENY", rec.Header().Get("X-Frame-Options"))
+	}
+}
+
+func TestFrameAncestors(t *testing.T) {
+	srv := newTestServer(t, t.TempDir(), func(cfg *config.Config) { cfg.FrameAncestors = "https://portal.example.com" })
+
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/config", nil))
+
+	if csp := rec.Header().Get("Content-Security-Policy"); !strings.HasSuffix(csp, "frame-ancestors https://portal.example.com") {
+		t.Errorf("Unexpected Content-Security-Policy: %s", csp)
+	}
+	if xfo := rec.Header().Get("X-Frame-Options"); xfo != "" {
+		t.Errorf("X-Frame-Options should be omitted when framing is allowed, got %q", xfo)
+	}
+}
@@ -2,90 +2,319 @@ package server
 
 import (
 	"context"
-	"embed"
 	"fmt"
+	"io"
 	"io/fs"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"inkwell/internal/ai"
+	"inkwell/internal/apikeys"
+	"inkwell/internal/audit"
+	"inkwell/internal/backup"
+	"inkwell/internal/branding"
 	"inkwell/internal/config"
 	"inkwell/internal/filesystem"
 	"inkwell/internal/git"
+	"inkwell/internal/git/oauth"
+	"inkwell/internal/hooks"
 	"inkwell/internal/recents"
-
+	"inkwell/internal/roaming"
+	"inkwell/internal/secrets"
+	"inkwell/internal/settings"
+	"inkwell/internal/storage"
+	autosync "inkwell/internal/sync"
+	"inkwell/internal/uploads"
+	"inkwell/internal/users"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
 	"github.com/gorilla/mux"
 )
 
 // Server represents the HTTP server
 type Server struct {
 	config     *config.Config
-	fs         *filesystem.FileSystem
-	watcher    *filesystem.Watcher
-	watcherMu  sync.RWMutex // Protects watcher during directory changes
+	current    atomic.Pointer[workspace] // Directory being served
+	switchMu   sync.Mutex                // Serializes directory changes
 	router     *mux.Router
 	httpServer *http.Server
 	hub        *Hub
-	webContent embed.FS
+	webContent fs.FS
 	recents    *recents.Manager
+	apiKeys    *apikeys.Manager
+	audit      *audit.Log
+	users      *users.Manager
+	sessions   *users.Sessions
+	settings   *settings.Manager
+	uploads    *uploads.Manager
 	git        *git.Manager
+	gitSignKey *openpgp.Entity
+	roaming    *roaming.Syncer
+	hooks      *hooks.Runner
+	scanner    *hooks.Scanner
+	runOnce    sync.Once
+	stop       chan struct{} // Closed on shutdown, stopping background work
+	stopOnce   sync.Once
+
+	lastChange atomic.Int64        // When a request last changed something, in Unix nanoseconds
+	sync       *autosync.Scheduler // Keeps the repository in step with origin; nil without git
+
+	storage     *storage.Mirror // Remote storage the vault is mirrored from; nil for a local vault
+	storageKick chan struct{}   // Asks the storage loop to push local changes soon
+
+	backup *backup.Service // Zips the vault on a schedule; nil without --backup-dir
+
+	publishMu sync.Mutex // Held while publishing, so publishes don't race
+
+	saves *saveQueue // Orders writes to each note
+
+	ai ai.Provider // Language model for writing help; nil when not configured
+
+	oauthProviders map[string]*oauth.Provider // Git hosts that can be signed in to, by provider name
+	oauthStore     *oauth.Store               // Tokens from signing in; nil if the home directory is unknown
+	oauthFlows     *oauth.Flows               // Sign-ins waiting for the user
+
+	secrets *secrets.Store // Saved git credentials; nil if the home directory is unknown
+
+	userRecents   map[string]*recents.Manager // Per-user recents by user ID
+	userRecentsMu sync.Mutex
 }
 
-// New creates a new server instance
-func New(cfg *config.Config, webContent embed.FS) (*Server, error) {
-	fileSystem := filesystem.New(cfg.RootDir)
+// New creates a new server instance. webContent must contain the built UI
+// under a "web" directory; if nil, only the API is served.
+func New(cfg *config.Config, webContent fs.FS) (*Server, error) {
+	// Edit the local cache of a vault in remote storage
+	var mirror *storage.Mirror
+	if cfg.Storage != "" {
+		var err error
+		if mirror, err = openStorage(cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.GitSizeCheck != "" && !git.ValidSizeCheck(cfg.GitSizeCheck) {
+		return nil, fmt.Errorf("invalid --git-size-check %q: use warn, block or off", cfg.GitSizeCheck)
+	}
+	if cfg.AutoSync != "" && !autosync.ValidMode(cfg.AutoSync) {
+		return nil, fmt.Errorf("invalid --auto-sync %q: use fetch, pull or push", cfg.AutoSync)
+	}
+
+	var backups *backup.Service
+	if cfg.BackupDir != "" {
+		var err error
+		if backups, err = backup.New(cfg.BackupDir, cfg.BackupKeep); err != nil {
+			return nil, err
+		}
+	}
+
+	var assistant ai.Provider
+	if cfg.AIProvider != "" {
+		var err error
+		if assistant, err = ai.New(cfg.AIProvider, cfg.AIURL, cfg.AIModel); err != nil {
+			return nil, err
+		}
+		slog.Info("AI assistance enabled", "provider", assistant.Name())
+	}
 
-	watcher, err := filesystem.NewWatcher(cfg.RootDir)
+	ws, err := newWorkspace(cfg.RootDir, cfg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create watcher: %w", err)
+		return nil, err
 	}
 
 	recentsManager, err := recents.New()
 	if err != nil {
-		log.Printf("Warning: Failed to initialize recents manager: %v", err)
+		slog.Warn("Failed to initialize recents manager", "error", err)
+	} else {
+		recentsManager.SetLimit(cfg.RecentsLimit)
+		if !cfg.KeepMissing {
+			recentsManager.DropMissing()
+		}
+	}
+
+	apiKeyManager, err := apikeys.New()
+	if err != nil {
+		slog.Warn("Failed to initialize API key manager", "error", err)
+	}
+
+	auditLog, err := audit.New()
+	if err != nil {
+		slog.Warn("Failed to initialize audit log", "error", err)
+	}
+
+	userManager, err := users.New()
+	if err != nil {
+		slog.Warn("Failed to initialize user manager", "error", err)
+	}
+
+	settingsManager, err := settings.New()
+	if err != nil {
+		slog.Warn("Failed to initialize settings manager", "error", err)
 	}
 
-	gitManager, err := git.NewManager()
+	uploadManager, err := uploads.New()
 	if err != nil {
-		log.Printf("Warning: Failed to initialize git manager: %v", err)
+		slog.Warn("Failed to initialize upload manager", "error", err)
+	}
+
+	scanner := hooks.NewScanner(cfg.ScanUploads, cfg.ScanTimeout)
+	if scanner.Enabled() {
+		if uploadManager != nil {
+			uploadManager.SetScanner(func(path string, upload *uploads.Upload) error {
+				return scanner.Scan(context.Background(), path, upload.Path)
+			})
+		}
+		slog.Info("Upload scanning enabled", "command", cfg.ScanUploads)
+	}
+
+	git.SetHistoryCacheSize(cfg.HistoryCacheSize)
+
+	var gitManager *git.Manager
+	if !cfg.NoGit {
+		if gitManager, err = newGitManager(cfg); err != nil {
+			slog.Warn("Failed to initialize git manager", "error", err)
+		}
+	}
+
+	var gitSignKey *openpgp.Entity
+	if cfg.GitSignKey != "" && !cfg.NoGit {
+		if gitSignKey, err = git.LoadSigningKey(cfg.GitSignKey); err != nil {
+			return nil, err
+		}
+	}
+
+	oauthStore, err := oauth.DefaultStore()
+	if err != nil {
+		slog.Warn("Failed to locate the git sign-in store", "error", err)
+	}
+
+	secretStore, err := secrets.Default()
+	if err != nil {
+		slog.Warn("Failed to open the credential store", "error", err)
 	}
 
 	s := &Server{
 		config:     cfg,
-		fs:         fileSystem,
-		watcher:    watcher,
 		router:     mux.NewRouter(),
 		webContent: webContent,
 		recents:    recentsManager,
+		apiKeys:    apiKeyManager,
+		audit:      auditLog,
+		users:      userManager,
+		sessions:   users.NewSessions(),
+		settings:   settingsManager,
+		uploads:    uploadManager,
 		git:        gitManager,
+		gitSignKey: gitSignKey,
+		hooks:      hooks.NewRunner(cfg.OnSave, cfg.HookTimeout),
+		scanner:    scanner,
+
+		userRecents: make(map[string]*recents.Manager),
+		stop:        make(chan struct{}),
+
+		storage:     mirror,
+		storageKick: make(chan struct{}, 1),
+
+		backup: backups,
+
+		ai: assistant,
+
+		oauthProviders: newOAuthProviders(cfg),
+		oauthStore:     oauthStore,
+
+		secrets: secretStore,
+
+		saves: newSaveQueue(),
 	}
+	if oauthStore != nil {
+		s.oauthFlows = oauth.NewFlows(oauthStore)
+	}
+	ws.access = s.guardFor
+	s.current.Store(ws)
 
 	// Create WebSocket hub
 	s.hub = NewHub(s)
+	if s.git != nil {
+		s.sync = s.newSyncScheduler()
+	}
 
 	// Setup routes
 	s.setupRoutes()
 
-	// Add current directory to recents
+	// Add current directory to recents, resuming at the file last opened
+	// there when none was asked for
 	if s.recents != nil {
+		if cfg.InitialFile == "" && len(cfg.InitialFiles) == 0 && !cfg.RestoreSession {
+			if last := s.recents.LastFile(cfg.RootDir); last != "" && ws.fs.FileExists(last) {
+				cfg.InitialFile = last
+			}
+		}
 		s.recents.Add(cfg.RootDir)
 	}
 
+	// Merge recents and settings from other machines
+	if cfg.SyncProfile != "" {
+		if syncer, err := roaming.New(cfg.SyncProfile, s.recents, s.settings); err != nil {
+			slog.Warn("Failed to set up profile sync", "error", err)
+		} else {
+			syncer.UseGit = !cfg.NoGit
+			s.roaming = syncer
+			s.syncProfile()
+		}
+	}
+	s.applyMarkdown(ws)
+
 	// Try to open as git repository
 	if s.git != nil {
 		if _, err := s.git.OpenRepository(cfg.RootDir); err != nil {
-			log.Printf("Note: %s is not a git repository", cfg.RootDir)
+			slog.Info("Not a git repository", "path", cfg.RootDir)
 		} else if repo := s.git.CurrentRepository(); repo != nil {
-			log.Printf("Git repository detected at root: %s (opened from: %s, branch: %s)", repo.Path(), cfg.RootDir, repo.Branch())
+			slog.Info("Git repository detected", "root", repo.Path(), "path", cfg.RootDir, "branch", repo.Branch())
 		}
 	}
 
 	return s, nil
 }
 
+// newGitManager creates the git manager, moving existing clones over from
+// ~/.inkwell/repos when --repos-dir points elsewhere
+func newGitManager(cfg *config.Config) (*git.Manager, error) {
+	if cfg.ReposDir == "" {
+		return git.NewManager()
+	}
+
+	if defaultDir, err := git.DefaultReposDir(); err == nil {
+		if rel, err := filepath.Rel(defaultDir, cfg.RootDir); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			slog.Warn("Not moving cloned repositories while one of them is open", "from", defaultDir, "to", cfg.ReposDir)
+		} else {
+			moved, err := git.MigrateRepos(defaultDir, cfg.ReposDir)
+			for _, path := range moved {
+				slog.Info("Moved cloned repository", "path", path)
+			}
+			if err != nil {
+				slog.Warn("Failed to move cloned repositories", "error", err)
+			}
+		}
+	}
+
+	return git.NewManagerAt(cfg.ReposDir)
+}
+
 // setupRoutes configures all HTTP routes
 func (s *Server) setupRoutes() {
+	s.router.Use(s.securityHeaders)
+	s.router.Use(s.limitRequestBody)
+	s.router.Use(s.apiKeyAuth)
+	s.router.Use(s.userAuth)
+	s.router.Use(s.trackChanges)
+
 	// API routes
 	api := s.router.PathPrefix("/api").Subrouter()
 	api.Use(jsonContentType)
@@ -97,45 +326,188 @@ func (s *Server) setupRoutes() {
 	api.HandleFunc("/files", s.handleUpdateFile).Methods("PUT")
 	api.HandleFunc("/files", s.handleDeleteFile).Methods("DELETE")
 	api.HandleFunc("/files/metadata", s.handleGetFileMetadata).Methods("GET")
+	api.HandleFunc("/files/restore", s.handleRestoreFile).Methods("POST")
+	api.HandleFunc("/files/open-external", s.handleOpenExternal).Methods("POST")
+	api.HandleFunc("/files/reveal", s.handleReveal).Methods("POST")
 
 	// Image operations
 	api.HandleFunc("/images", s.handleUploadImage).Methods("POST")
-	s.router.HandleFunc("/images/{filename}", s.handleServeImage).Methods("GET")
+	api.HandleFunc("/images/paste", s.handlePasteImage).Methods("POST")
+	api.HandleFunc("/assets/migrate", s.handleMigrateAssets).Methods("POST")
+	s.router.HandleFunc("/images/{filename:.+}", s.handleServeImage).Methods("GET", "HEAD")
+
+	// Resumable chunked uploads for large attachments
+	api.HandleFunc("/uploads", s.handleCreateChunkedUpload).Methods("POST")
+	api.HandleFunc("/uploads/{id}", s.handleGetChunkedUpload).Methods("GET", "HEAD")
+	api.HandleFunc("/uploads/{id}", s.handleAppendChunk).Methods("PUT", "PATCH")
+	api.HandleFunc("/uploads/{id}", s.handleAbortChunkedUpload).Methods("DELETE")
+
+	// Rendering and export
+	api.HandleFunc("/render", s.handleRender).Methods("GET")
+	api.HandleFunc("/render/folder", s.handleRenderFolder).Methods("GET")
+	api.HandleFunc("/outline", s.handleOutline).Methods("GET")
+	api.HandleFunc("/export/html", s.handleExportHTML).Methods("GET")
+	api.HandleFunc("/export/zip", s.handleExportZip).Methods("GET")
+	api.HandleFunc("/publish", s.handlePublish).Methods("POST")
+	api.HandleFunc("/import/zip", s.handleImportZip).Methods("POST")
+	api.HandleFunc("/plantuml", s.handleRenderPlantUML).Methods("POST")
+	s.router.HandleFunc("/plantuml/{hash}.svg", s.handleServePlantUML).Methods("GET")
+	s.router.HandleFunc("/print", s.handlePrint).Methods("GET")
 
 	// Config
+	api.HandleFunc("/bootstrap", s.handleBootstrap).Methods("GET")
 	api.HandleFunc("/config", s.handleGetConfig).Methods("GET")
+	api.HandleFunc("/version", s.handleGetVersion).Methods("GET")
+	api.HandleFunc("/diagnostics", s.handleDiagnostics).Methods("GET")
+	api.HandleFunc("/templates", s.handleListTemplates).Methods("GET")
+	api.HandleFunc("/settings", s.handleGetSettings).Methods("GET")
+	api.HandleFunc("/settings", s.handleUpdateSettings).Methods("PUT")
+	api.HandleFunc("/session", s.handleGetSession).Methods("GET")
+	api.HandleFunc("/session", s.handleSaveSession).Methods("PUT")
+
+	// Search, links and tags
+	api.HandleFunc("/index/status", s.handleIndexStatus).Methods("GET")
+	api.HandleFunc("/index", s.handleReindex).Methods("POST")
+	api.HandleFunc("/search", s.handleSearch).Methods("GET")
+	api.HandleFunc("/backlinks", s.handleBacklinks).Methods("GET")
+	api.HandleFunc("/tags", s.handleTags).Methods("GET")
+	api.HandleFunc("/tasks", s.handleTasks).Methods("GET")
+	api.HandleFunc("/tasks/toggle", s.handleToggleTask).Methods("POST")
+	api.HandleFunc("/calendar", s.handleCalendar).Methods("GET")
+	api.HandleFunc("/duplicates", s.handleDuplicates).Methods("GET")
+	api.HandleFunc("/bibliography", s.handleBibliography).Methods("GET")
+	api.HandleFunc("/fixups", s.handleCheckFixups).Methods("GET")
+	api.HandleFunc("/fixups", s.handleApplyFixup).Methods("POST")
+
+	// Encrypted notes
+	api.HandleFunc("/encryption", s.handleEncryptionStatus).Methods("GET")
+	api.HandleFunc("/encryption/setup", s.handleEncryptionSetup).Methods("POST")
+	api.HandleFunc("/encryption/unlock", s.handleEncryptionUnlock).Methods("POST")
+	api.HandleFunc("/encryption/lock", s.handleEncryptionLock).Methods("POST")
+	api.HandleFunc("/encryption/encrypt", s.handleEncryptNote).Methods("POST")
+	api.HandleFunc("/encryption/decrypt", s.handleDecryptNote).Methods("POST")
+
+	// Saved git credentials
+	api.HandleFunc("/secrets", s.handleListSecrets).Methods("GET")
+	api.HandleFunc("/secrets", s.handleCreateSecret).Methods("POST")
+	api.HandleFunc("/secrets/setup", s.handleSetupSecrets).Methods("POST")
+	api.HandleFunc("/secrets/unlock", s.handleUnlockSecrets).Methods("POST")
+	api.HandleFunc("/secrets/lock", s.handleLockSecrets).Methods("POST")
+	api.HandleFunc("/secrets/{id}", s.handleUpdateSecret).Methods("PUT")
+	api.HandleFunc("/secrets/{id}", s.handleDeleteSecret).Methods("DELETE")
+
+	// Conflicted copies left by Dropbox, Nextcloud, Syncthing and the like
+	api.HandleFunc("/sync-conflicts", s.handleListSyncConflicts).Methods("GET")
+	api.HandleFunc("/sync-conflicts/merge", s.handleMergeSyncConflict).Methods("GET")
+	api.HandleFunc("/sync-conflicts/resolve", s.handleResolveSyncConflict).Methods("POST")
 
 	// Directory operations
 	api.HandleFunc("/directories", s.handleListDirectories).Methods("GET")
 	api.HandleFunc("/directories", s.handleChangeDirectory).Methods("POST")
+	api.HandleFunc("/vault/init", s.handleInitVault).Methods("POST")
 
 	// Recent locations
+	api.HandleFunc("/start", s.handleGetStart).Methods("GET")
 	api.HandleFunc("/recents", s.handleGetRecents).Methods("GET")
+	api.HandleFunc("/recents", s.handleRemoveRecent).Methods("DELETE")
+	api.HandleFunc("/recents/all", s.handleClearRecents).Methods("DELETE")
+	api.HandleFunc("/recents/files", s.handleGetRecentFiles).Methods("GET")
+	api.HandleFunc("/recents/pin", s.handlePinRecent).Methods("POST")
+	api.HandleFunc("/recents/favorites", s.handleGetFavorites).Methods("GET")
+	api.HandleFunc("/recents/favorites", s.handleAddFavorite).Methods("POST")
+	api.HandleFunc("/recents/favorites", s.handleRemoveFavorite).Methods("DELETE")
+
+	// API keys
+	api.HandleFunc("/keys", s.handleListAPIKeys).Methods("GET")
+	api.HandleFunc("/keys", s.handleCreateAPIKey).Methods("POST")
+	api.HandleFunc("/keys/{id}", s.handleRevokeAPIKey).Methods("DELETE")
+
+	// Accounts and sign-in
+	s.router.HandleFunc("/login", s.handleLoginPage).Methods("GET")
+	api.HandleFunc("/auth/login", s.handleLogin).Methods("POST")
+	api.HandleFunc("/auth/logout", s.handleLogout).Methods("POST")
+	api.HandleFunc("/me", s.handleGetMe).Methods("GET")
+	api.HandleFunc("/me", s.handleUpdateMe).Methods("PUT")
+	api.HandleFunc("/me/settings", s.handleGetMySettings).Methods("GET")
+	api.HandleFunc("/me/settings", s.handleUpdateMySettings).Methods("PUT")
+	api.HandleFunc("/users", s.handleListUsers).Methods("GET")
+	api.HandleFunc("/users", s.handleCreateUser).Methods("POST")
+	api.HandleFunc("/users/{id}", s.handleDeleteUser).Methods("DELETE")
+	api.HandleFunc("/access", s.handleGetAccess).Methods("GET")
+	api.HandleFunc("/access", s.handleSetAccess).Methods("PUT")
+
+	// Audit log
+	api.HandleFunc("/audit", s.handleGetAudit).Methods("GET")
 
 	// Git operations
 	gitAPI := api.PathPrefix("/git").Subrouter()
-	gitAPI.HandleFunc("/status", s.handleGitStatus).Methods("GET")
-	gitAPI.HandleFunc("/init", s.handleGitInit).Methods("POST")
-	gitAPI.HandleFunc("/clone", s.handleGitClone).Methods("POST")
-	gitAPI.HandleFunc("/repos", s.handleGitListRepos).Methods("GET")
-	gitAPI.HandleFunc("/validate-url", s.handleGitValidateURL).Methods("GET")
-	gitAPI.HandleFunc("/stage", s.handleGitStage).Methods("POST")
-	gitAPI.HandleFunc("/unstage", s.handleGitUnstage).Methods("POST")
-	gitAPI.HandleFunc("/commit", s.handleGitCommit).Methods("POST")
-	gitAPI.HandleFunc("/discard", s.handleGitDiscard).Methods("POST")
-	gitAPI.HandleFunc("/push", s.handleGitPush).Methods("POST")
-	gitAPI.HandleFunc("/pull", s.handleGitPull).Methods("POST")
-	gitAPI.HandleFunc("/fetch", s.handleGitFetch).Methods("POST")
-	gitAPI.HandleFunc("/branches", s.handleGitBranches).Methods("GET")
-	gitAPI.HandleFunc("/checkout", s.handleGitCheckout).Methods("POST")
-	gitAPI.HandleFunc("/branches/create", s.handleGitCreateBranch).Methods("POST")
-	gitAPI.HandleFunc("/branches/delete", s.handleGitDeleteBranch).Methods("POST")
-	gitAPI.HandleFunc("/branches/rename", s.handleGitRenameBranch).Methods("POST")
-	gitAPI.HandleFunc("/history", s.handleGitHistory).Methods("GET")
-	gitAPI.HandleFunc("/commit-detail", s.handleGitCommitDetail).Methods("GET")
-	gitAPI.HandleFunc("/diff", s.handleGitDiff).Methods("GET", "POST")
-	gitAPI.HandleFunc("/file-at-commit", s.handleGitFileAtCommit).Methods("GET")
-	gitAPI.HandleFunc("/quick-commit", s.handleGitQuickCommit).Methods("POST")
+	if s.config.NoGit {
+		gitAPI.PathPrefix("/").HandlerFunc(s.handleGitDisabled)
+	} else {
+		gitAPI.Use(s.serializeGit)
+		gitAPI.HandleFunc("/status", s.handleGitStatus).Methods("GET")
+		gitAPI.HandleFunc("/init", s.handleGitInit).Methods("POST")
+		gitAPI.HandleFunc("/clone", s.handleGitClone).Methods("POST")
+		gitAPI.HandleFunc("/repos", s.handleGitListRepos).Methods("GET")
+		gitAPI.HandleFunc("/validate-url", s.handleGitValidateURL).Methods("GET")
+		gitAPI.HandleFunc("/stage", s.handleGitStage).Methods("POST")
+		gitAPI.HandleFunc("/unstage", s.handleGitUnstage).Methods("POST")
+		gitAPI.HandleFunc("/commit", s.handleGitCommit).Methods("POST")
+		gitAPI.HandleFunc("/discard", s.handleGitDiscard).Methods("POST")
+		gitAPI.HandleFunc("/push", s.handleGitPush).Methods("POST")
+		gitAPI.HandleFunc("/pull", s.handleGitPull).Methods("POST")
+		gitAPI.HandleFunc("/fetch", s.handleGitFetch).Methods("POST")
+		gitAPI.HandleFunc("/sync", s.handleGitSyncStatus).Methods("GET")
+		gitAPI.HandleFunc("/sync", s.handleGitSyncNow).Methods("POST")
+		gitAPI.HandleFunc("/branches", s.handleGitBranches).Methods("GET")
+		gitAPI.HandleFunc("/checkout", s.handleGitCheckout).Methods("POST")
+		gitAPI.HandleFunc("/branches/create", s.handleGitCreateBranch).Methods("POST")
+		gitAPI.HandleFunc("/branches/delete", s.handleGitDeleteBranch).Methods("POST")
+		gitAPI.HandleFunc("/branches/rename", s.handleGitRenameBranch).Methods("POST")
+		gitAPI.HandleFunc("/tags", s.handleGitTags).Methods("GET")
+		gitAPI.HandleFunc("/tags/create", s.handleGitCreateTag).Methods("POST")
+		gitAPI.HandleFunc("/tags/delete", s.handleGitDeleteTag).Methods("POST")
+		gitAPI.HandleFunc("/tags/push", s.handleGitPushTags).Methods("POST")
+		gitAPI.HandleFunc("/remotes", s.handleGitRemotes).Methods("GET")
+		gitAPI.HandleFunc("/remotes/add", s.handleGitAddRemote).Methods("POST")
+		gitAPI.HandleFunc("/remotes/remove", s.handleGitRemoveRemote).Methods("POST")
+		gitAPI.HandleFunc("/remotes/rename", s.handleGitRenameRemote).Methods("POST")
+		gitAPI.HandleFunc("/remotes/set-url", s.handleGitSetRemoteURL).Methods("POST")
+		gitAPI.HandleFunc("/history", s.handleGitHistory).Methods("GET")
+		gitAPI.HandleFunc("/history/stream", s.handleGitHistoryStream).Methods("GET")
+		gitAPI.HandleFunc("/commit-detail", s.handleGitCommitDetail).Methods("GET")
+		gitAPI.HandleFunc("/diff", s.handleGitDiff).Methods("GET", "POST")
+		gitAPI.HandleFunc("/diff/stream", s.handleGitDiffStream).Methods("GET")
+		gitAPI.HandleFunc("/diff/worktree", s.handleGitWorkingDiff).Methods("GET")
+		gitAPI.HandleFunc("/resolve", s.handleGitResolve).Methods("POST")
+		gitAPI.HandleFunc("/revert", s.handleGitRevert).Methods("POST")
+		gitAPI.HandleFunc("/cherry-pick", s.handleGitCherryPick).Methods("POST")
+		gitAPI.HandleFunc("/file-at-commit", s.handleGitFileAtCommit).Methods("GET")
+		gitAPI.HandleFunc("/quick-commit", s.handleGitQuickCommit).Methods("POST")
+		gitAPI.HandleFunc("/ignore", s.handleGitIgnore).Methods("GET")
+		gitAPI.HandleFunc("/ignore", s.handleGitUpdateIgnore).Methods("PUT", "POST")
+		gitAPI.HandleFunc("/auth", s.handleGitAuthList).Methods("GET")
+		gitAPI.HandleFunc("/auth/device", s.handleGitAuthStart).Methods("POST")
+		gitAPI.HandleFunc("/auth/device/{id}", s.handleGitAuthStatus).Methods("GET")
+		gitAPI.HandleFunc("/auth/device/{id}", s.handleGitAuthCancel).Methods("DELETE")
+		gitAPI.HandleFunc("/auth/{host}", s.handleGitAuthSignOut).Methods("DELETE")
+	}
+
+	if s.storage != nil {
+		s.registerStorageRoutes(api)
+	}
+	if s.backup != nil {
+		s.registerBackupRoutes(api)
+	}
+	if s.ai != nil {
+		s.registerAIRoutes(api)
+	}
+	if s.config.Debug {
+		s.registerDebugRoutes(api)
+	}
+
+	// Raw file access for external tools
+	s.router.PathPrefix("/raw/").HandlerFunc(s.handleServeRaw).Methods("GET", "HEAD")
 
 	// WebSocket
 	s.router.HandleFunc("/ws", s.hub.HandleWebSocket)
@@ -146,6 +518,10 @@ func (s *Server) setupRoutes() {
 
 // staticFileHandler returns a handler for serving the embedded web UI
 func (s *Server) staticFileHandler() http.Handler {
+	if s.webContent == nil {
+		return http.NotFoundHandler()
+	}
+
 	// Get the web subdirectory from the embedded filesystem
 	webFS, err := fs.Sub(s.webContent, "web")
 	if err != nil {
@@ -161,8 +537,16 @@ func (s *Server) staticFileHandler() http.Handler {
 			path = "/index.html"
 		}
 
+		b, err := branding.Load(s.workspace().rootDir)
+		if err != nil {
+			slog.Warn("Failed to load branding", "error", err)
+		}
+		if b != nil && serveBranded(w, r, b, webFS, path[1:]) {
+			return
+		}
+
 		// Check if file exists in embedded FS
-		_, err := fs.Stat(webFS, path[1:]) // Remove leading /
+		_, err = fs.Stat(webFS, path[1:]) // Remove leading /
 		if err != nil {
 			// Serve index.html for SPA routing
 			r.URL.Path = "/"
@@ -172,6 +556,41 @@ func (s *Server) staticFileHandler() http.Handler {
 	})
 }
 
+// serveBranded serves a page or asset from the vault's branding, reporting
+// whether it handled the request. Branding files replace embedded assets
+// with the same path, and the index page (including SPA routes) is
+// rewritten with the branded title, logo and stylesheet.
+func serveBranded(w http.ResponseWriter, r *http.Request, b *branding.Branding, webFS fs.FS, name string) bool {
+	if name != "index.html" {
+		if f, err := b.Open(name); err == nil {
+			defer f.Close()
+			info, err := f.Stat()
+			if rs, ok := f.(io.ReadSeeker); ok && err == nil && info.Mode().IsRegular() {
+				http.ServeContent(w, r, info.Name(), info.ModTime(), rs)
+				return true
+			}
+		}
+
+		if _, err := fs.Stat(webFS, name); err == nil {
+			return false
+		}
+		name = "index.html"
+	}
+
+	page, err := fs.ReadFile(b, name)
+	if err != nil {
+		page, err = fs.ReadFile(webFS, name)
+	}
+	if err != nil {
+		return false
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(b.Apply(page))
+	return true
+}
+
 // jsonContentType middleware sets Content-Type to application/json
 func jsonContentType(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -183,43 +602,148 @@ func jsonContentType(next http.Handler) http.Handler {
 // Start starts the HTTP server
 func (s *Server) Start() error {
 	s.httpServer = &http.Server{
-		Addr:         fmt.Sprintf(":%d", s.config.Port),
+		Addr:         net.JoinHostPort(s.config.Host, strconv.Itoa(s.config.Port)),
 		Handler:      s.router,
 		ReadTimeout:  15 * time.Second,
 		WriteTimeout: 15 * time.Second,
 		IdleTimeout:  60 * time.Second,
 	}
 
-	// Start WebSocket hub
-	go s.hub.Run()
-
-	// Start file watcher events forwarding
-	go s.forwardFileEvents()
+	s.run()
 
-	log.Printf("Server starting on http://localhost:%d", s.config.Port)
+	slog.Info("Server starting", "addr", s.httpServer.Addr, "url", s.config.URL())
 	return s.httpServer.ListenAndServe()
 }
 
+// Handler returns the server's routes for mounting in another HTTP server.
+// Background workers are started on first use.
+func (s *Server) Handler() http.Handler {
+	s.run()
+	return s.router
+}
+
+// run starts the background workers exactly once
+func (s *Server) run() {
+	s.runOnce.Do(func() {
+		// Start WebSocket hub
+		go s.hub.Run()
+
+		// Start file watcher events forwarding and indexing. Later
+		// workspaces start their own when switched in.
+		ws := s.workspace()
+		go s.forwardFileEvents(ws)
+		ws.index.Start(s.hub.BroadcastIndexStatus)
+
+		if s.sync != nil {
+			go s.sync.Run(s.stop)
+		}
+		if s.storage != nil {
+			go s.syncStorage(s.config.StorageInterval)
+		}
+		if s.backup != nil && s.config.BackupInterval > 0 {
+			go s.scheduledBackups(s.config.BackupInterval)
+		}
+	})
+}
+
+// syncProfile shares recents and settings through --sync-profile
+func (s *Server) syncProfile() {
+	if err := s.roaming.Sync(); err != nil {
+		slog.Warn("Failed to sync profile", "path", s.config.SyncProfile, "error", err)
+	}
+}
+
 // Shutdown gracefully shuts down the server
 func (s *Server) Shutdown(ctx context.Context) error {
-	s.watcher.Close()
+	if s.roaming != nil {
+		s.syncProfile()
+	}
+	s.flushRecents()
+	s.stopOnce.Do(func() { close(s.stop) })
+	if s.storage != nil {
+		if err := s.storage.Push(ctx); err != nil {
+			slog.Warn("Failed to push to remote storage", "error", err)
+		}
+	}
+	s.workspace().watcher.Close()
+	s.workspace().index.Close()
 	s.hub.Close()
+	if s.httpServer == nil {
+		return nil
+	}
 	return s.httpServer.Shutdown(ctx)
 }
 
-// forwardFileEvents forwards file system events to WebSocket clients
-func (s *Server) forwardFileEvents() {
-	s.watcherMu.RLock()
-	watcher := s.watcher
-	s.watcherMu.RUnlock()
+// flushRecents writes recents still waiting to be saved, so the last
+// opened files are there on the next start
+func (s *Server) flushRecents() {
+	managers := []*recents.Manager{s.recents}
+	s.userRecentsMu.Lock()
+	for _, m := range s.userRecents {
+		managers = append(managers, m)
+	}
+	s.userRecentsMu.Unlock()
 
-	if watcher == nil {
+	for _, m := range managers {
+		if m == nil {
+			continue
+		}
+		if err := m.Flush(); err != nil {
+			slog.Warn("Failed to save recents", "error", err)
+		}
+	}
+}
+
+// FileSystem returns the file system for the current root directory
+func (s *Server) FileSystem() *filesystem.FileSystem {
+	return s.workspace().fs
+}
+
+// RootDir returns the directory currently being served
+func (s *Server) RootDir() string {
+	return s.workspace().rootDir
+}
+
+// Git returns the git manager, or nil if git is unavailable
+func (s *Server) Git() *git.Manager {
+	return s.git
+}
+
+// runSaveHooks runs the on-save commands for a file in the background,
+// broadcasting each command's output to WebSocket clients
+func (s *Server) runSaveHooks(path string) {
+	if !s.hooks.Enabled() {
 		return
 	}
 
-	events := watcher.Subscribe()
+	rootDir := s.workspace().rootDir
+	go s.hooks.Run(context.Background(), rootDir, path, func(result hooks.Result) {
+		if result.Error != "" {
+			slog.Warn("On-save command failed", "path", path, "command", result.Command, "error", result.Error)
+		}
+		s.hub.BroadcastHookResult(result)
+	})
+}
+
+// forwardFileEvents keeps a workspace's index up to date with its
+// watcher's events and forwards them to WebSocket clients
+func (s *Server) forwardFileEvents(ws *workspace) {
+	events := ws.watcher.Subscribe()
 	for event := range events {
+		switch event.Type {
+		case filesystem.EventDeleted, filesystem.EventRenamed:
+			ws.index.Remove(event.Path)
+			if !ws.fs.RemovedHere(event.Path) && !ws.fs.FileExists(event.Path) {
+				s.hub.NotifyRemoved(event)
+			}
+		default:
+			ws.index.Update(event.Path)
+			s.hub.RefreshBuffers(ws, event.Path)
+		}
 		s.hub.BroadcastFileEvent(event)
+		if s.storage != nil && ws.rootDir == s.storage.Dir() {
+			s.kickStorage()
+		}
 	}
 	// Channel closed means watcher was closed, goroutine exits naturally
 }
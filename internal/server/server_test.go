@@ -0,0 +1,31 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"inkwell/internal/config"
+)
+
+// newTestServer creates a server for dir, with HOME pointed at a temporary
+// directory so recents and settings stay out of the real one. Options
+// adjust the configuration before the server is created. The server is
+// shut down when the test ends.
+func newTestServer(t *testing.T, dir string, opts ...func(*config.Config)) *Server {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+
+	cfg, err := config.New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	s, err := New(cfg, nil)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	t.Cleanup(func() { s.Shutdown(context.Background()) })
+	return s
+}
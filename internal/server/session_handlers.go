@@ -0,0 +1,82 @@
+package server
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"inkwell/internal/session"
+)
+
+// handleGetSession returns the tabs last open in the current vault
+func (s *Server) handleGetSession(w http.ResponseWriter, r *http.Request) {
+	sess, err := session.Load(s.workspace().rootDir)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load session: "+err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    sess,
+	})
+}
+
+// handleSaveSession records the tabs open in the current vault
+func (s *Server) handleSaveSession(w http.ResponseWriter, r *http.Request) {
+	var sess session.Session
+	if err := json.NewDecoder(r.Body).Decode(&sess); err != nil {
+		if isTooLarge(err) {
+			writeTooLarge(w, s.bodyLimit(r))
+			return
+		}
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := session.Save(s.workspace().rootDir, &sess); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to save session: "+err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    sess,
+	})
+}
+
+// startupFiles returns the files the UI opens when it loads and which of
+// them is shown: those named on the command line, or with --restore-session
+// the vault's saved tabs that still exist
+func (s *Server) startupFiles(ws *workspace) ([]string, string) {
+	if len(s.config.InitialFiles) > 0 {
+		return s.config.InitialFiles, s.config.InitialFiles[0]
+	}
+	if s.config.InitialFile != "" {
+		return []string{s.config.InitialFile}, s.config.InitialFile
+	}
+	if !s.config.RestoreSession {
+		return []string{}, ""
+	}
+
+	sess, err := session.Load(ws.rootDir)
+	if err != nil {
+		slog.Warn("Failed to load session", "error", err)
+		return []string{}, ""
+	}
+
+	files := make([]string, 0, len(sess.Files))
+	for _, f := range sess.Files {
+		if ws.fs.FileExists(f) {
+			files = append(files, f)
+		}
+	}
+	sess.Files = files
+	sess.Clean()
+
+	active := sess.Active
+	if active == "" && len(files) > 0 {
+		active = files[0]
+	}
+	return files, active
+}
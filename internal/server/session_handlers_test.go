@@ -0,0 +1,38 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"inkwell/internal/config"
+)
+
+func TestRestoreSession(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.md", "b.md"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("# "+name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	srv := newTestServer(t, dir, func(cfg *config.Config) { cfg.RestoreSession = true })
+
+	body := `{"files": ["a.md", "gone.md", "b.md"], "active": "b.md"}`
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/api/session", strings.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200 saving session, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/config", nil))
+	for _, want := range []string{`"activeFile":"b.md"`, `"initialFiles":["a.md","b.md"]`} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("Config %s missing %s", rec.Body.String(), want)
+		}
+	}
+}
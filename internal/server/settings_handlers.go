@@ -0,0 +1,63 @@
+package server
+
+import (
+	"io"
+	"net/http"
+
+	"inkwell/internal/audit"
+)
+
+// handleGetSettings returns the saved editor and server preferences
+func (s *Server) handleGetSettings(w http.ResponseWriter, r *http.Request) {
+	if s.settings == nil {
+		writeError(w, http.StatusInternalServerError, "Settings manager not initialized")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    s.settings.Get(),
+	})
+}
+
+// applyMarkdown renders a workspace's notes with the saved markdown flavor
+func (s *Server) applyMarkdown(ws *workspace) {
+	if s.settings != nil {
+		ws.setMarkdown(s.settings.Get())
+	}
+}
+
+// handleUpdateSettings applies changes to the saved preferences and pushes
+// the result to every connected client. Fields left out of the body keep
+// their values.
+func (s *Server) handleUpdateSettings(w http.ResponseWriter, r *http.Request) {
+	if s.settings == nil {
+		writeError(w, http.StatusInternalServerError, "Settings manager not initialized")
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		if isTooLarge(err) {
+			writeTooLarge(w, s.bodyLimit(r))
+			return
+		}
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	updated, err := s.settings.Update(body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.recordAudit(r, audit.ActionSettingsChange, "", "shared settings")
+	s.workspace().setMarkdown(updated)
+	s.hub.BroadcastSettings(updated)
+
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    updated,
+	})
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"inkwell/internal/config"
+	"inkwell/internal/storage"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// storageOpenTimeout bounds the first sync at startup
+	storageOpenTimeout = 2 * time.Minute
+	// storagePushDelay gathers a burst of saves into one upload
+	storagePushDelay = 2 * time.Second
+)
+
+// openStorage mirrors the vault in --storage into its local cache and
+// points RootDir at the cache. A failed first sync is logged, so a cached
+// vault can still be edited offline.
+func openStorage(cfg *config.Config) (*storage.Mirror, error) {
+	backend, err := storage.Open(cfg.Storage)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := storage.CacheDir(cfg.Storage)
+	if err != nil {
+		return nil, err
+	}
+	mirror, err := storage.NewMirror(backend, cfg.Storage, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), storageOpenTimeout)
+	defer cancel()
+	if err := mirror.Sync(ctx); err != nil {
+		slog.Warn("Failed to sync remote storage; using the cached copy", "storage", storage.Redact(cfg.Storage), "error", err)
+	} else {
+		slog.Info("Synced remote storage", "storage", storage.Redact(cfg.Storage), "cache", mirror.Dir())
+	}
+
+	cfg.RootDir = mirror.Dir()
+	cfg.InitialFile = ""
+	cfg.InitialFiles = nil
+	return mirror, nil
+}
+
+// kickStorage asks the sync loop to push local changes soon
+func (s *Server) kickStorage() {
+	select {
+	case s.storageKick <- struct{}{}:
+	default:
+	}
+}
+
+// syncStorage pulls from remote storage every interval and pushes shortly
+// after local changes, until the server shuts down
+func (s *Server) syncStorage(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var push <-chan time.Time
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-s.storageKick:
+			if push == nil {
+				push = time.After(storagePushDelay)
+			}
+		case <-push:
+			push = nil
+			if err := s.storage.Push(context.Background()); err != nil {
+				slog.Warn("Failed to push to remote storage", "error", err)
+			}
+		case <-ticker.C:
+			var err error
+			s.saves.all(func() { err = s.storage.Sync(context.Background()) })
+			if err != nil {
+				slog.Warn("Failed to sync remote storage", "error", err)
+			}
+		}
+	}
+}
+
+// registerStorageRoutes serves the sync status. Only registered with
+// --storage.
+func (s *Server) registerStorageRoutes(api *mux.Router) {
+	api.HandleFunc("/storage", s.handleStorageStatus).Methods("GET")
+	api.HandleFunc("/storage/sync", s.handleStorageSync).Methods("POST")
+}
+
+// handleStorageStatus returns where the vault is stored and how the last
+// sync went
+func (s *Server) handleStorageStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: s.storage.Status()})
+}
+
+// handleStorageSync syncs with remote storage now
+func (s *Server) handleStorageSync(w http.ResponseWriter, r *http.Request) {
+	var err error
+	s.saves.all(func() { err = s.storage.Sync(r.Context()) })
+	if err != nil {
+		writeError(w, http.StatusBadGateway, "Failed to sync remote storage: "+err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: s.storage.Status()})
+}
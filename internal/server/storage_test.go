@@ -0,0 +1,83 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"inkwell/internal/config"
+
+	"golang.org/x/net/webdav"
+)
+
+func TestStorage(t *testing.T) {
+	remote := webdav.NewMemFS()
+	ctx := context.Background()
+	remote.Mkdir(ctx, "/notes", 0755)
+	f, err := remote.OpenFile(ctx, "/notes/todo.md", os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte("# Todo\n\nBuy milk\n"))
+	f.Close()
+
+	dav := httptest.NewServer(&webdav.Handler{FileSystem: remote, LockSystem: webdav.NewMemLS()})
+	defer dav.Close()
+
+	readRemote := func(name string) string {
+		f, err := remote.OpenFile(ctx, "/notes/"+name, os.O_RDONLY, 0)
+		if err != nil {
+			return ""
+		}
+		defer f.Close()
+		var b strings.Builder
+		io.Copy(&b, f)
+		return b.String()
+	}
+
+	srv := newTestServer(t, t.TempDir(), func(cfg *config.Config) {
+		cfg.Storage = "webdav://" + strings.TrimPrefix(dav.URL, "http://") + "/notes"
+		cfg.StorageInterval = time.Hour
+	})
+
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/files?path=todo.md", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "Buy milk") {
+		t.Fatalf("Expected the remote note, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/api/files?path=todo.md",
+		strings.NewReader(`{"content":"# Todo\n\nBuy bread\n"}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Update failed: %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/storage/sync", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Sync failed: %d %s", rec.Code, rec.Body.String())
+	}
+	if got := readRemote("todo.md"); got != "# Todo\n\nBuy bread\n" {
+		t.Errorf("Expected the edit uploaded, remote has %q", got)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/storage", nil))
+	var resp struct {
+		Data struct {
+			Storage  string    `json:"storage"`
+			LastSync time.Time `json:"lastSync"`
+		} `json:"data"`
+	}
+	json.NewDecoder(rec.Body).Decode(&resp)
+	if !strings.HasPrefix(resp.Data.Storage, "webdav://") || resp.Data.LastSync.IsZero() {
+		t.Errorf("Unexpected storage status: %s", rec.Body.String())
+	}
+}
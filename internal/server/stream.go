@@ -0,0 +1,65 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// streamWriteTimeout is how long writing each line of a stream may take.
+// The deadline moves forward with every line, so a long stream isn't cut
+// off by the server's WriteTimeout.
+const streamWriteTimeout = 15 * time.Second
+
+// ndjsonStream writes a response as newline-delimited JSON, one value per
+// line, flushing each line as it is written
+type ndjsonStream struct {
+	w       http.ResponseWriter
+	rc      *http.ResponseController
+	enc     *json.Encoder
+	started bool
+}
+
+func newNDJSONStream(w http.ResponseWriter) *ndjsonStream {
+	return &ndjsonStream{
+		w:   w,
+		rc:  http.NewResponseController(w),
+		enc: json.NewEncoder(w),
+	}
+}
+
+// send writes one line
+func (s *ndjsonStream) send(v interface{}) error {
+	s.start()
+	// Not every ResponseWriter supports deadlines; the stream works without
+	_ = s.rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+	if err := s.enc.Encode(v); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
+
+// fail reports an error: as an error response if nothing was sent yet, or
+// else as a final {"error": ...} line
+func (s *ndjsonStream) fail(status int, message string) {
+	if !s.started {
+		writeError(s.w, status, message)
+		return
+	}
+	s.send(map[string]string{"error": message})
+}
+
+// finish ends a stream, sending the headers if nothing was sent
+func (s *ndjsonStream) finish() {
+	s.start()
+}
+
+func (s *ndjsonStream) start() {
+	if s.started {
+		return
+	}
+	s.started = true
+	s.w.Header().Set("Content-Type", "application/x-ndjson")
+	s.w.Header().Set("Cache-Control", "no-cache")
+	s.w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"inkwell/internal/git"
+)
+
+func TestStreamHistory(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := git.Init(dir)
+	if err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	for _, name := range []string{"a.md", "b.md"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("# "+name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repo.Commit(git.CommitOptions{Message: "Add " + name, Files: []string{name}}); err != nil {
+			t.Fatalf("Commit failed: %v", err)
+		}
+	}
+
+	srv := newTestServer(t, dir)
+
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/git/history/stream", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("Expected an NDJSON stream, got %d %q: %s", rec.Code, rec.Header().Get("Content-Type"), rec.Body.String())
+	}
+
+	var commits []git.Commit
+	dec := json.NewDecoder(rec.Body)
+	for dec.More() {
+		var c git.Commit
+		if err := dec.Decode(&c); err != nil {
+			t.Fatalf("Invalid line: %v", err)
+		}
+		commits = append(commits, c)
+	}
+	if len(commits) != 2 || commits[0].Message != "Add b.md" {
+		t.Fatalf("Expected 2 commits newest first, got %+v", commits)
+	}
+
+	rec = httptest.NewRecorder()
+	url := "/api/git/diff/stream?from=" + commits[1].Hash + "&to=" + commits[0].Hash
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+	if lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n"); len(lines) != 1 || !strings.Contains(lines[0], `"path":"b.md"`) {
+		t.Errorf("Expected one line for b.md, got %s", rec.Body.String())
+	}
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"inkwell/internal/audit"
+	"inkwell/internal/git"
+	autosync "inkwell/internal/sync"
+)
+
+// newSyncScheduler syncs the current repository every --fetch-interval as
+// far as --auto-sync allows, postponed while requests change things
+func (s *Server) newSyncScheduler() *autosync.Scheduler {
+	return autosync.New(s.git, autosync.Options{
+		Interval: s.config.FetchInterval,
+		Mode:     autosync.Mode(s.config.AutoSync),
+		Timeout:  s.config.GitTimeout,
+		IdleTime: autosync.DefaultIdleTime,
+		Wait:     gitQueueWait,
+		Worktree: &s.saves.writes,
+		LastChange: func() time.Time {
+			return time.Unix(0, s.lastChange.Load())
+		},
+		Notify: func(result autosync.Status, status *git.GitStatus) {
+			if status != nil {
+				s.hub.BroadcastGitStatus(status)
+			}
+			s.hub.BroadcastSyncStatus(result)
+		},
+	})
+}
+
+// handleGitSyncStatus returns how the last sync went
+func (s *Server) handleGitSyncStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: s.sync.Status()})
+}
+
+// handleGitSyncNow syncs the current repository without waiting for the
+// schedule, also resuming syncs that stopped for want of credentials
+func (s *Server) handleGitSyncNow(w http.ResponseWriter, r *http.Request) {
+	status, err := s.sync.Now(r.Context())
+	if errors.Is(err, autosync.ErrNoRepository) || errors.Is(err, autosync.ErrNoRemote) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if errors.Is(err, git.ErrBusy) {
+		writeGitBusy(w, err)
+		return
+	}
+	if err != nil {
+		s.writeRemoteError(w, "Sync failed: ", err)
+		return
+	}
+	s.recordAudit(r, audit.ActionGitSync, "", string(status.Mode))
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: status})
+}
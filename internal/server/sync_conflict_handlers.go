@@ -0,0 +1,119 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path/filepath"
+
+	"inkwell/internal/audit"
+	"inkwell/internal/filesystem"
+)
+
+// SyncConflictMerge is a conflict copy with a merge of it and its original
+type SyncConflictMerge struct {
+	filesystem.SyncConflict
+	Merged string `json:"merged"`
+}
+
+// ResolveSyncConflictRequest says how to resolve a conflict copy
+type ResolveSyncConflictRequest struct {
+	Path    string `json:"path"`              // The conflicted copy
+	Keep    string `json:"keep"`              // original, copy or merged
+	Content string `json:"content,omitempty"` // Saved over the original when keeping merged
+}
+
+// handleListSyncConflicts returns the conflict copies sync tools left in
+// the vault
+func (s *Server) handleListSyncConflicts(w http.ResponseWriter, r *http.Request) {
+	conflicts, err := s.workspace().files(requestActor(r)).SyncConflicts()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to look for conflicts: "+err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: conflicts})
+}
+
+// handleMergeSyncConflict merges a conflict copy with its original line by
+// line, for the user to finish resolving
+func (s *Server) handleMergeSyncConflict(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+	ws := s.workspace()
+	c, err := ws.files(requestActor(r)).SyncConflict(path)
+	if err != nil {
+		writeFileError(w, http.StatusBadRequest, "", err)
+		return
+	}
+
+	actor := requestActor(r)
+	merged, _, err := ws.readNote(actor, c.Path)
+	if err != nil {
+		writeReadError(w, err)
+		return
+	}
+	if c.OriginalExists {
+		ours, _, err := ws.readNote(actor, c.Original)
+		if err != nil {
+			writeReadError(w, err)
+			return
+		}
+		merged = filesystem.MergeLines(ours, merged, filepath.Base(c.Original), filepath.Base(c.Path))
+	}
+
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    SyncConflictMerge{SyncConflict: c, Merged: merged},
+	})
+}
+
+// handleResolveSyncConflict keeps the original, the copy or merged content
+// and removes the copy
+func (s *Server) handleResolveSyncConflict(w http.ResponseWriter, r *http.Request) {
+	var req ResolveSyncConflictRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	ws := s.workspace()
+	fs := ws.files(requestActor(r))
+	c, err := fs.SyncConflict(req.Path)
+	if err != nil {
+		writeFileError(w, http.StatusBadRequest, "", err)
+		return
+	}
+
+	// Keeping the copy or the merge writes the original, in turn with saves
+	var original string
+	var mergeErr error
+	s.saves.do(saveKey(ws, c.Original), func() {
+		keep := req.Keep
+		if keep == filesystem.KeepMerged {
+			if mergeErr = ws.writeNote(requestActor(r), c.Original, req.Content); mergeErr != nil {
+				return
+			}
+			keep = filesystem.KeepOriginal
+		}
+		original, err = fs.ResolveSyncConflict(c.Path, keep)
+	})
+	if errors.Is(mergeErr, errNoteLocked) {
+		writeError(w, http.StatusLocked, mergeErr.Error())
+		return
+	} else if mergeErr != nil {
+		writeFileError(w, http.StatusInternalServerError, "Failed to save merged note: ", mergeErr)
+		return
+	}
+	if err != nil {
+		writeFileError(w, http.StatusBadRequest, "Failed to resolve conflict: ", err)
+		return
+	}
+	if req.Keep != filesystem.KeepOriginal {
+		s.recordAudit(r, audit.ActionFileWrite, original, "")
+	}
+	s.recordAudit(r, audit.ActionFileDelete, c.Path, "")
+
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    map[string]string{"path": original},
+	})
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSyncConflictsAPI(t *testing.T) {
+	dir := t.TempDir()
+	copyName := "plan (conflicted copy 2024-05-01 150423).md"
+	os.WriteFile(filepath.Join(dir, "plan.md"), []byte("# Plan\nOurs\n"), 0644)
+	os.WriteFile(filepath.Join(dir, copyName), []byte("# Plan\nTheirs\n"), 0644)
+
+	srv := newTestServer(t, dir)
+
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/sync-conflicts", nil))
+	var list struct {
+		Data []struct {
+			Path     string `json:"path"`
+			Original string `json:"original"`
+			Source   string `json:"source"`
+		} `json:"data"`
+	}
+	json.NewDecoder(rec.Body).Decode(&list)
+	if len(list.Data) != 1 || list.Data[0].Path != copyName || list.Data[0].Original != "plan.md" || list.Data[0].Source != "nextcloud" {
+		t.Fatalf("Unexpected conflicts: %+v", list.Data)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/sync-conflicts/merge?path="+url.QueryEscape(copyName), nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `Ours\n=======\nTheirs`) {
+		t.Errorf("Expected a merge with conflict markers, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	body, _ := json.Marshal(map[string]string{"path": copyName, "keep": "merged", "content": "# Plan\nBoth\n"})
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/sync-conflicts/resolve", bytes.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Resolve failed: %d %s", rec.Code, rec.Body.String())
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "plan.md")); string(data) != "# Plan\nBoth\n" {
+		t.Errorf("Expected the merged note saved, got %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(dir, copyName)); err == nil {
+		t.Error("Expected the conflicted copy removed")
+	}
+
+	rec = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/sync-conflicts/resolve", strings.NewReader(`{"path":"plan.md","keep":"copy"}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a file that isn't a conflict, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"inkwell/internal/config"
+	"inkwell/internal/git"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/gorilla/websocket"
+)
+
+func TestBackgroundFetch(t *testing.T) {
+	upstreamDir := t.TempDir()
+	upstream, err := git.Init(upstreamDir)
+	if err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	commit := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(upstreamDir, "a.md"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := upstream.Commit(git.CommitOptions{Message: "Update", Files: []string{"a.md"}}); err != nil {
+			t.Fatalf("Commit failed: %v", err)
+		}
+	}
+	commit("one")
+
+	dir := filepath.Join(t.TempDir(), "vault")
+	if _, err := gogit.PlainClone(dir, false, &gogit.CloneOptions{URL: upstreamDir}); err != nil {
+		t.Fatalf("Clone failed: %v", err)
+	}
+
+	srv := newTestServer(t, dir, func(cfg *config.Config) { cfg.FetchInterval = 50 * time.Millisecond })
+
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	commit("two")
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("Never received the status after fetching: %v", err)
+		}
+		if strings.Contains(string(data), `"type":"gitStatus"`) && strings.Contains(string(data), `"behind":1`) {
+			return
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package server
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"inkwell/internal/audit"
+	"inkwell/internal/hooks"
+)
+
+var errNotImage = errors.New("file is not an image")
+
+// UploadResult is the outcome for one file of a multi-file upload
+type UploadResult struct {
+	Name  string `json:"name"`
+	Path  string `json:"path,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// uploadImages saves each file of a multi-file upload and reports per-file
+// results. paths, when given, holds each file's location within a dropped
+// folder, in the same order as files.
+func (s *Server) uploadImages(w http.ResponseWriter, r *http.Request, files []*multipart.FileHeader, paths []string) {
+	if len(paths) > 0 && len(paths) != len(files) {
+		writeError(w, http.StatusBadRequest, "Expected one path per file")
+		return
+	}
+
+	fs := s.workspace().files(requestActor(r))
+	results := make([]UploadResult, 0, len(files))
+	saved := 0
+
+	for i, header := range files {
+		result := UploadResult{Name: header.Filename}
+
+		relativePath := ""
+		if len(paths) > 0 {
+			relativePath = path.Clean(strings.TrimPrefix(filepath.ToSlash(paths[i]), "/"))
+		}
+
+		data, err := readImage(header)
+		if err == nil {
+			err = s.scanUpload(r, bytes.NewReader(data), header.Filename)
+		}
+		if err == nil {
+			if relativePath != "" {
+				result.Path, err = fs.SaveImageAt(relativePath, data)
+			} else {
+				result.Path, err = fs.SaveImage(data, imageExtension(header.Filename))
+			}
+		}
+
+		if err != nil {
+			result.Error = err.Error()
+		} else {
+			saved++
+			s.recordAudit(r, audit.ActionImageUpload, result.Path, "")
+		}
+		results = append(results, result)
+	}
+
+	status := http.StatusCreated
+	if saved < len(files) {
+		status = http.StatusMultiStatus
+	}
+
+	writeJSON(w, status, APIResponse{
+		Success: saved > 0,
+		Data: map[string]interface{}{
+			"results": results,
+			"saved":   saved,
+			"failed":  len(files) - saved,
+		},
+	})
+}
+
+// scanUpload runs the upload scanner, if one is configured, over an upload
+func (s *Server) scanUpload(r *http.Request, data io.Reader, name string) error {
+	err := s.scanner.ScanReader(r.Context(), data, name)
+	s.noteRejected(r, name, err)
+	return err
+}
+
+// noteRejected logs and audits an upload the scanner turned away
+func (s *Server) noteRejected(r *http.Request, name string, err error) {
+	var scanErr *hooks.ScanError
+	if !errors.As(err, &scanErr) {
+		return
+	}
+	slog.Warn("Upload rejected by scanner", "name", name, "exitCode", scanErr.Result.ExitCode, "output", scanErr.Result.Output)
+	s.recordAudit(r, audit.ActionUploadRejected, name, strings.TrimSpace(scanErr.Result.Output))
+}
+
+// writeScanError reports an upload that failed scanning, as 422 when the
+// scanner rejected it
+func writeScanError(w http.ResponseWriter, err error) {
+	var scanErr *hooks.ScanError
+	if errors.As(err, &scanErr) {
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	writeError(w, http.StatusInternalServerError, "Failed to scan upload: "+err.Error())
+}
+
+// PasteImageRequest is an image pasted as a data URL, such as
+// "data:image/png;base64,iVBOR...", or as bare base64
+type PasteImageRequest struct {
+	Data string `json:"data"`
+	Name string `json:"name,omitempty"` // Original file name, if the clipboard had one
+	Alt  string `json:"alt,omitempty"`  // Alt text for the returned markdown
+	Note string `json:"note,omitempty"` // Note the image is pasted into
+}
+
+// imageExtensions maps detected image types to the extension they are
+// saved with
+var imageExtensions = map[string]string{
+	"image/png":                ".png",
+	"image/jpeg":               ".jpg",
+	"image/gif":                ".gif",
+	"image/webp":               ".webp",
+	"image/bmp":                ".bmp",
+	"image/x-icon":             ".ico",
+	"image/vnd.microsoft.icon": ".ico",
+}
+
+// handlePasteImage saves an image sent as base64 rather than multipart,
+// as some editors paste clipboard images, and returns the markdown to
+// insert for it
+func (s *Server) handlePasteImage(w http.ResponseWriter, r *http.Request) {
+	var req PasteImageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		if isTooLarge(err) {
+			writeTooLarge(w, s.bodyLimit(r))
+			return
+		}
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	data, err := decodeDataURL(req.Data)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	contentType := http.DetectContentType(data)
+	if !strings.HasPrefix(contentType, "image/") {
+		writeError(w, http.StatusBadRequest, "File is not an image")
+		return
+	}
+
+	ext, ok := imageExtensions[contentType]
+	if !ok {
+		ext = imageExtension(req.Name)
+	}
+	name := req.Name
+	if name == "" {
+		name = "pasted" + ext
+	}
+	if err := s.scanUpload(r, bytes.NewReader(data), name); err != nil {
+		writeScanError(w, err)
+		return
+	}
+
+	ws := s.workspace()
+	path, err := ws.files(requestActor(r)).SaveNoteImage(req.Note, data, ext)
+	if err != nil {
+		writeFileError(w, http.StatusInternalServerError, "Failed to save image: ", err)
+		return
+	}
+	s.recordAudit(r, audit.ActionImageUpload, path, "")
+
+	alt := req.Alt
+	if alt == "" && req.Name != "" {
+		alt = strings.TrimSuffix(filepath.Base(req.Name), filepath.Ext(req.Name))
+	}
+	url := ws.fs.ImageURL(path)
+	writeJSON(w, http.StatusCreated, APIResponse{
+		Success: true,
+		Data: map[string]string{
+			"path":     path,
+			"url":      url,
+			"markdown": "![" + escapeAlt(alt) + "](" + url + ")",
+		},
+	})
+}
+
+// decodeDataURL returns the bytes of a base64 data URL or of bare base64
+func decodeDataURL(value string) ([]byte, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, errors.New("Image data is required")
+	}
+	if rest, ok := strings.CutPrefix(value, "data:"); ok {
+		meta, payload, found := strings.Cut(rest, ",")
+		if !found || !strings.HasSuffix(meta, ";base64") {
+			return nil, errors.New("Only base64 data URLs are supported")
+		}
+		value = payload
+	}
+
+	// Clipboard data is often wrapped or unpadded
+	value = strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\r' || r == ' ' || r == '\t' {
+			return -1
+		}
+		return r
+	}, value)
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if data, err := enc.DecodeString(value); err == nil {
+			return data, nil
+		}
+	}
+	return nil, errors.New("Image data is not valid base64")
+}
+
+// escapeAlt makes text safe to use as an image's alt text in markdown
+func escapeAlt(text string) string {
+	return strings.NewReplacer("\\", "\\\\", "[", "\\[", "]", "\\]", "\n", " ").Replace(text)
+}
+
+// readImage reads an uploaded file, rejecting anything that isn't an image
+func readImage(header *multipart.FileHeader) ([]byte, error) {
+	file, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasPrefix(http.DetectContentType(data), "image/") {
+		return nil, errNotImage
+	}
+	return data, nil
+}
+
+// imageExtension returns the extension to save an upload with
+func imageExtension(filename string) string {
+	if ext := filepath.Ext(filename); ext != "" {
+		return ext
+	}
+	return ".png"
+}
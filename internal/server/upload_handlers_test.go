@@ -0,0 +1,88 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"inkwell/internal/config"
+)
+
+func TestUploadScanner(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires sh")
+	}
+	dir := t.TempDir()
+	srv := newTestServer(t, dir, func(cfg *config.Config) { cfg.ScanUploads = "! grep -q EICAR {file}" })
+
+	upload := func(content string) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		part, _ := mw.CreateFormFile("image", "photo.png")
+		part.Write([]byte("\x89PNG\r\n\x1a\n" + content))
+		mw.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/api/images", &body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		rec := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := upload("pixels"); rec.Code != http.StatusCreated {
+		t.Errorf("Expected a clean image saved, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := upload("EICAR"); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422 for a rejected image, got %d: %s", rec.Code, rec.Body.String())
+	}
+	assets, _ := os.ReadDir(filepath.Join(dir, "assets"))
+	if len(assets) != 1 {
+		t.Errorf("Expected only the clean image kept, got %d files", len(assets))
+	}
+}
+
+func TestPasteImage(t *testing.T) {
+	dir := t.TempDir()
+	srv := newTestServer(t, dir)
+
+	paste := func(body string) (int, map[string]string) {
+		rec := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/images/paste", strings.NewReader(body)))
+		var resp struct {
+			Data map[string]string `json:"data"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec.Code, resp.Data
+	}
+
+	// A 1x1 transparent GIF
+	gif := "R0lGODlhAQABAIAAAAAAAP///yH5BAEAAAAALAAAAAABAAEAAAIBRAA7"
+	code, data := paste(`{"data": "data:image/gif;base64,` + gif + `", "name": "Screen Shot.gif"}`)
+	if code != http.StatusCreated || !strings.HasSuffix(data["path"], ".gif") {
+		t.Fatalf("Unexpected paste result %d: %v", code, data)
+	}
+	if data["markdown"] != "![Screen Shot]("+data["url"]+")" {
+		t.Errorf("Unexpected markdown %q", data["markdown"])
+	}
+	if saved, err := os.ReadFile(filepath.Join(dir, data["path"])); err != nil || len(saved) != 42 {
+		t.Errorf("Expected the decoded image saved, got %d bytes, %v", len(saved), err)
+	}
+
+	// Bare base64 without padding works too
+	if code, _ := paste(`{"data": "` + strings.TrimRight(gif, "=") + `"}`); code != http.StatusCreated {
+		t.Errorf("Expected bare base64 accepted, got %d", code)
+	}
+	if code, _ := paste(`{"data": "data:text/plain;base64,aGVsbG8="}`); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for text, got %d", code)
+	}
+	if code, _ := paste(`{"data": "not base64!"}`); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for bad data, got %d", code)
+	}
+}
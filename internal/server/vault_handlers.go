@@ -0,0 +1,75 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+
+	"inkwell/internal/audit"
+	"inkwell/internal/filesystem"
+	"inkwell/internal/git"
+	"inkwell/internal/starter"
+)
+
+// InitVaultRequest represents a request to create a starter vault
+type InitVaultRequest struct {
+	Path string `json:"path,omitempty"` // Folder to fill; empty for the open vault
+	Git  bool   `json:"git,omitempty"`  // Also make it a git repository with a first commit
+}
+
+// handleInitVault fills a folder with the starter notes and templates. A
+// folder other than the open vault is added to the recents, ready to open.
+func (s *Server) handleInitVault(w http.ResponseWriter, r *http.Request) {
+	var req InitVaultRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Git && s.git == nil {
+		writeError(w, http.StatusBadRequest, "Git integration is disabled")
+		return
+	}
+
+	rootDir := s.workspace().rootDir
+	absPath := rootDir
+	if req.Path != "" {
+		if expanded, err := filesystem.ExpandHome(req.Path); err == nil {
+			req.Path = expanded
+		}
+		var err error
+		if absPath, err = filepath.Abs(req.Path); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid path: "+err.Error())
+			return
+		}
+	}
+
+	opts := starter.Options{Git: req.Git}
+	if req.Git {
+		commit := git.CommitOptions{}
+		s.commitDefaults(r, &commit)
+		opts.AuthorName, opts.AuthorEmail, opts.SignKey = commit.AuthorName, commit.AuthorEmail, commit.SignKey
+	}
+	result, err := starter.Create(absPath, opts)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to create vault: "+err.Error())
+		return
+	}
+	s.recordAudit(r, audit.ActionVaultInit, absPath, "")
+
+	current := filesystem.SamePath(absPath, rootDir)
+	if current && result.Commit != "" {
+		// Show the new repository in the git panel
+		if _, err := s.git.OpenRepository(absPath); err != nil {
+			slog.Warn("Failed to open new repository", "path", absPath, "error", err)
+		}
+	}
+	if !current {
+		if m := s.recentsFor(r); m != nil {
+			m.Add(absPath)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: result})
+}
@@ -1,13 +1,22 @@
 package server
 
 import (
+	"bytes"
 	"encoding/json"
-	"log"
+	"log/slog"
 	"net/http"
 	"sync"
 	"time"
 
+	"inkwell/internal/audit"
+	"inkwell/internal/backup"
 	"inkwell/internal/filesystem"
+	"inkwell/internal/git"
+	"inkwell/internal/hooks"
+	"inkwell/internal/index"
+	"inkwell/internal/recents"
+	"inkwell/internal/settings"
+	autosync "inkwell/internal/sync"
 
 	"github.com/gorilla/websocket"
 )
@@ -24,6 +33,10 @@ const (
 
 	// Maximum message size allowed from peer
 	maxMessageSize = 512 * 1024 // 512KB
+
+	// Broadcasts within this window are sent to clients together, as one
+	// JSON array
+	broadcastWindow = 50 * time.Millisecond
 )
 
 var upgrader = websocket.Upgrader{
@@ -40,6 +53,10 @@ type WSMessage struct {
 	Path    string          `json:"path,omitempty"`
 	Content string          `json:"content,omitempty"`
 	Data    json.RawMessage `json:"data,omitempty"`
+
+	// Version of the note a save was edited from, or was written as in
+	// the acknowledgment; see noteVersion
+	Version string `json:"version,omitempty"`
 }
 
 // Client represents a WebSocket client
@@ -47,15 +64,22 @@ type Client struct {
 	hub        *Hub
 	conn       *websocket.Conn
 	send       chan []byte
-	subscribed map[string]bool // Paths this client is subscribed to
+	subscribed map[string]bool   // Paths this client is subscribed to
+	buffers    map[string]string // Last content seen of each subscribed note, for restoring it if it is removed
+	actor      string            // Who connected, for the audit log
+	readOnly   bool              // Client may not save files
+	recents    *recents.Manager  // Recents of whoever connected, if any
 	mu         sync.RWMutex
+
+	sendClosed bool // Set by the hub once send is closed; guarded by mu
 }
 
-// Hub maintains the set of active clients and broadcasts messages
+// Hub maintains the set of active clients and broadcasts messages. Only
+// Run changes the set of clients and closes their send channels.
 type Hub struct {
 	server     *Server
 	clients    map[*Client]bool
-	broadcast  chan []byte
+	broadcast  chan broadcastMessage
 	register   chan *Client
 	unregister chan *Client
 	done       chan struct{}
@@ -67,15 +91,25 @@ func NewHub(server *Server) *Hub {
 	return &Hub{
 		server:     server,
 		clients:    make(map[*Client]bool),
-		broadcast:  make(chan []byte, 256),
+		broadcast:  make(chan broadcastMessage, 256),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
 		done:       make(chan struct{}),
 	}
 }
 
+// broadcastMessage is a message waiting to be sent to every client
+type broadcastMessage struct {
+	key  string // Messages with the same non-empty key replace each other
+	path string // Only clients that can read this note get the message; empty for all
+	data []byte
+}
+
 // Run starts the hub's main loop
 func (h *Hub) Run() {
+	var pending []broadcastMessage
+	var flush <-chan time.Time
+
 	for {
 		select {
 		case <-h.done:
@@ -86,23 +120,138 @@ func (h *Hub) Run() {
 			h.mu.Unlock()
 		case client := <-h.unregister:
 			h.mu.Lock()
-			if _, ok := h.clients[client]; ok {
-				delete(h.clients, client)
+			delete(h.clients, client)
+			h.mu.Unlock()
+			client.mu.Lock()
+			if !client.sendClosed {
+				client.sendClosed = true
 				close(client.send)
 			}
-			h.mu.Unlock()
+			client.mu.Unlock()
 		case message := <-h.broadcast:
-			h.mu.RLock()
-			for client := range h.clients {
-				select {
-				case client.send <- message:
-				default:
-					close(client.send)
-					delete(h.clients, client)
+			pending = coalesce(pending, message)
+			if flush == nil {
+				flush = time.After(broadcastWindow)
+			}
+		case <-flush:
+			h.sendAll(pending)
+			pending = nil
+			flush = nil
+		}
+	}
+}
+
+// coalesce adds a message to those waiting, replacing an earlier one with
+// the same key
+func coalesce(pending []broadcastMessage, message broadcastMessage) []broadcastMessage {
+	if message.key != "" {
+		for i := range pending {
+			if pending[i].key == message.key {
+				pending = append(pending[:i], pending[i+1:]...)
+				break
+			}
+		}
+	}
+	return append(pending, message)
+}
+
+// sendAll sends messages to every client, as a JSON array if there are
+// several, leaving out those about notes the client's folder permissions
+// hide. A client too slow to keep up is disconnected; its read loop then
+// unregisters it.
+func (h *Hub) sendAll(messages []broadcastMessage) {
+	if len(messages) == 0 {
+		return
+	}
+
+	all := encodeBatch(messages)
+	ws := h.server.workspace()
+	var slow []*Client
+	h.mu.RLock()
+	for client := range h.clients {
+		data := all
+		if visible := client.visible(ws, messages); len(visible) == 0 {
+			continue
+		} else if len(visible) < len(messages) {
+			data = encodeBatch(visible)
+		}
+		select {
+		case client.send <- data:
+		default:
+			slow = append(slow, client)
+		}
+	}
+	h.mu.RUnlock()
+
+	if len(slow) == 0 {
+		return
+	}
+	h.mu.Lock()
+	for _, client := range slow {
+		delete(h.clients, client)
+	}
+	h.mu.Unlock()
+	for _, client := range slow {
+		slog.Warn("Disconnecting slow WebSocket client", "actor", client.actor)
+		client.conn.Close()
+	}
+}
+
+// encodeBatch joins messages into one frame, as a JSON array if there are
+// several
+func encodeBatch(messages []broadcastMessage) []byte {
+	if len(messages) == 1 {
+		return messages[0].data
+	}
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	for i, m := range messages {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.Write(m.data)
+	}
+	buf.WriteByte(']')
+	return buf.Bytes()
+}
+
+// visible returns the messages the client may see
+func (c *Client) visible(ws *workspace, messages []broadcastMessage) []broadcastMessage {
+	var files *filesystem.FileSystem
+	var visible []broadcastMessage // Set once a message is left out
+	for i, m := range messages {
+		if m.path != "" {
+			if files == nil {
+				files = ws.files(c.actor)
+			}
+			if !files.CanRead(m.path) {
+				if visible == nil {
+					visible = append(make([]broadcastMessage, 0, len(messages)), messages[:i]...)
 				}
+				continue
 			}
-			h.mu.RUnlock()
 		}
+		if visible != nil {
+			visible = append(visible, m)
+		}
+	}
+	if visible == nil {
+		return messages
+	}
+	return visible
+}
+
+// queue hands a message to Run for the next batch
+func (h *Hub) queue(key string, data []byte) {
+	h.queueFor("", key, data)
+}
+
+// queueFor hands a message about a note to Run for the next batch, to be
+// sent only to clients that can read it
+func (h *Hub) queueFor(path, key string, data []byte) {
+	select {
+	case h.broadcast <- broadcastMessage{key: key, path: path, data: data}:
+	case <-h.done:
 	}
 }
 
@@ -111,7 +260,15 @@ func (h *Hub) Close() {
 	close(h.done)
 }
 
-// BroadcastFileEvent sends a file event to all clients
+// ClientCount returns the number of connected clients
+func (h *Hub) ClientCount() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.clients)
+}
+
+// BroadcastFileEvent sends a file event to the clients that can read the
+// file
 func (h *Hub) BroadcastFileEvent(event filesystem.FileEvent) {
 	msg := WSMessage{
 		Type: "fileEvent",
@@ -127,14 +284,176 @@ func (h *Hub) BroadcastFileEvent(event filesystem.FileEvent) {
 		return
 	}
 
-	h.broadcast <- msgBytes
+	// Repeats of an event within a batch say nothing new
+	h.queueFor(event.Path, "fileEvent\x00"+string(event.Type)+"\x00"+event.Path, msgBytes)
+}
+
+// subscribers returns the clients with a path open
+func (h *Hub) subscribers(path string) []*Client {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	var clients []*Client
+	for client := range h.clients {
+		client.mu.RLock()
+		if client.subscribed[path] {
+			clients = append(clients, client)
+		}
+		client.mu.RUnlock()
+	}
+	return clients
+}
+
+// RefreshBuffers rereads a note changed on disk for the clients that have it
+// open
+func (h *Hub) RefreshBuffers(ws *workspace, path string) {
+	for _, client := range h.subscribers(path) {
+		client.refreshBuffer(ws, path)
+	}
+}
+
+// NotifyRemoved tells the clients with a note open that another program
+// deleted it or moved it away, such as to the trash, sending the content
+// they last saw so they can offer to restore it
+func (h *Hub) NotifyRemoved(event filesystem.FileEvent) {
+	for _, client := range h.subscribers(event.Path) {
+		client.mu.Lock()
+		content, restorable := client.buffers[event.Path]
+		delete(client.buffers, event.Path)
+		client.mu.Unlock()
+
+		data, _ := json.Marshal(map[string]interface{}{
+			"eventType":  event.Type,
+			"restorable": restorable,
+		})
+		client.sendMessage(WSMessage{
+			Type:    "fileRemoved",
+			Path:    event.Path,
+			Content: content,
+			Data:    data,
+		})
+	}
+}
+
+// BroadcastHookResult sends the outcome of an on-save command to the
+// clients that can read the file saved, as its output may quote it
+func (h *Hub) BroadcastHookResult(result hooks.Result) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return
+	}
+
+	msgBytes, err := json.Marshal(WSMessage{
+		Type: "hookResult",
+		Path: result.Path,
+		Data: data,
+	})
+	if err != nil {
+		return
+	}
+
+	h.queueFor(result.Path, "", msgBytes)
+}
+
+// BroadcastIndexStatus sends the progress of indexing the vault to all
+// clients
+func (h *Hub) BroadcastIndexStatus(status index.Status) {
+	data, err := json.Marshal(status)
+	if err != nil {
+		return
+	}
+
+	msgBytes, err := json.Marshal(WSMessage{
+		Type: "indexStatus",
+		Data: data,
+	})
+	if err != nil {
+		return
+	}
+
+	// Only the latest progress matters
+	h.queue("indexStatus", msgBytes)
+}
+
+// BroadcastGitStatus sends the status of the current repository to all
+// clients, e.g. after a background fetch
+func (h *Hub) BroadcastGitStatus(status *git.GitStatus) {
+	data, err := json.Marshal(status)
+	if err != nil {
+		return
+	}
+
+	msgBytes, err := json.Marshal(WSMessage{
+		Type: "gitStatus",
+		Data: data,
+	})
+	if err != nil {
+		return
+	}
+
+	h.queue("gitStatus", msgBytes)
+}
+
+// BroadcastSyncStatus sends how the last background or manual sync went to
+// all clients
+func (h *Hub) BroadcastSyncStatus(status autosync.Status) {
+	data, err := json.Marshal(status)
+	if err != nil {
+		return
+	}
+
+	msgBytes, err := json.Marshal(WSMessage{
+		Type: "syncStatus",
+		Data: data,
+	})
+	if err != nil {
+		return
+	}
+
+	h.queue("syncStatus", msgBytes)
+}
+
+// BroadcastBackupStatus sends how the last backup went to all clients
+func (h *Hub) BroadcastBackupStatus(status backup.Status) {
+	data, err := json.Marshal(status)
+	if err != nil {
+		return
+	}
+
+	msgBytes, err := json.Marshal(WSMessage{
+		Type: "backupStatus",
+		Data: data,
+	})
+	if err != nil {
+		return
+	}
+
+	h.queue("backupStatus", msgBytes)
+}
+
+// BroadcastSettings sends updated settings to all clients
+func (h *Hub) BroadcastSettings(current settings.Settings) {
+	data, err := json.Marshal(current)
+	if err != nil {
+		return
+	}
+
+	msgBytes, err := json.Marshal(WSMessage{
+		Type: "settings",
+		Data: data,
+	})
+	if err != nil {
+		return
+	}
+
+	h.queue("settings", msgBytes)
 }
 
 // HandleWebSocket handles WebSocket connections
 func (h *Hub) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Println("WebSocket upgrade error:", err)
+		slog.Warn("WebSocket upgrade failed", "error", err)
 		return
 	}
 
@@ -143,6 +462,12 @@ func (h *Hub) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 		conn:       conn,
 		send:       make(chan []byte, 256),
 		subscribed: make(map[string]bool),
+		buffers:    make(map[string]string),
+		actor:      requestActor(r),
+		recents:    h.server.recentsFor(r),
+	}
+	if user := userFromContext(r.Context()); user != nil {
+		client.readOnly = !user.CanWrite()
 	}
 
 	h.register <- client
@@ -170,7 +495,7 @@ func (c *Client) readPump() {
 		_, message, err := c.conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Printf("WebSocket error: %v", err)
+				slog.Warn("WebSocket error", "error", err)
 			}
 			break
 		}
@@ -196,20 +521,8 @@ func (c *Client) writePump() {
 				return
 			}
 
-			w, err := c.conn.NextWriter(websocket.TextMessage)
-			if err != nil {
-				return
-			}
-			w.Write(message)
-
-			// Add queued messages to the current WebSocket frame
-			n := len(c.send)
-			for i := 0; i < n; i++ {
-				w.Write([]byte{'\n'})
-				w.Write(<-c.send)
-			}
-
-			if err := w.Close(); err != nil {
+			// Each message is its own frame, so it parses as JSON
+			if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
 				return
 			}
 		case <-ticker.C:
@@ -225,7 +538,7 @@ func (c *Client) writePump() {
 func (c *Client) handleMessage(data []byte) {
 	var msg WSMessage
 	if err := json.Unmarshal(data, &msg); err != nil {
-		log.Println("Invalid WebSocket message:", err)
+		slog.Debug("Invalid WebSocket message", "error", err)
 		return
 	}
 
@@ -234,25 +547,84 @@ func (c *Client) handleMessage(data []byte) {
 		c.mu.Lock()
 		c.subscribed[msg.Path] = true
 		c.mu.Unlock()
+		c.refreshBuffer(c.hub.server.workspace(), msg.Path)
 
 	case "unsubscribe":
 		c.mu.Lock()
 		delete(c.subscribed, msg.Path)
+		delete(c.buffers, msg.Path)
 		c.mu.Unlock()
 
 	case "save":
-		// Save file and notify
-		if err := c.hub.server.fs.WriteFile(msg.Path, msg.Content); err != nil {
-			c.sendError("Failed to save file: " + err.Error())
+		if c.readOnly {
+			c.sendError("Your role does not allow saving files")
 			return
 		}
-		c.sendMessage(WSMessage{
-			Type: "saved",
-			Path: msg.Path,
+		// Saves wait their turn off the read loop, which keeps reading
+		ws := c.hub.server.workspace()
+		c.hub.server.saves.enqueue(saveKey(ws, msg.Path), func() {
+			c.save(ws, msg)
 		})
 	}
 }
 
+// save writes a note if it hasn't changed since the version the client
+// edited, acknowledging with the new version. A save without a version
+// overwrites whatever is there.
+func (c *Client) save(ws *workspace, msg WSMessage) {
+	c.hub.server.touch()
+	if msg.Version != "" {
+		if current := ws.currentVersion(c.actor, msg.Path); current != "" && current != msg.Version {
+			// Send what's there now so the client can merge or choose
+			content, _, err := ws.readNote(c.actor, msg.Path)
+			if err != nil {
+				c.sendError("Failed to save file: " + err.Error())
+				return
+			}
+			c.sendMessage(WSMessage{
+				Type:    "saveConflict",
+				Path:    msg.Path,
+				Content: content,
+				Version: current,
+			})
+			return
+		}
+	}
+
+	if err := ws.writeNote(c.actor, msg.Path, msg.Content); err != nil {
+		c.sendError("Failed to save file: " + err.Error())
+		return
+	}
+	if c.recents != nil {
+		c.recents.AddFile(ws.rootDir, msg.Path)
+	}
+	c.sendMessage(WSMessage{
+		Type:    "saved",
+		Path:    msg.Path,
+		Version: ws.currentVersion(c.actor, msg.Path),
+	})
+	c.hub.server.recordAuditAs(c.actor, audit.ActionFileWrite, msg.Path, "")
+	c.hub.server.runSaveHooks(msg.Path)
+}
+
+// refreshBuffer rereads a subscribed note so its content can be offered
+// back if the file is removed. Notes the client can't read, and encrypted
+// ones, whose plaintext shouldn't linger on the server, aren't kept.
+func (c *Client) refreshBuffer(ws *workspace, path string) {
+	content, encrypted, err := ws.readNote(c.actor, path)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.subscribed[path] {
+		return
+	}
+	if err != nil || encrypted {
+		delete(c.buffers, path)
+		return
+	}
+	c.buffers[path] = content
+}
+
 // sendMessage sends a message to the client
 func (c *Client) sendMessage(msg WSMessage) {
 	data, err := json.Marshal(msg)
@@ -260,6 +632,12 @@ func (c *Client) sendMessage(msg WSMessage) {
 		return
 	}
 
+	// Queued saves and file events may finish after the client has gone
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.sendClosed {
+		return
+	}
 	select {
 	case c.send <- data:
 	default:
@@ -0,0 +1,191 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"inkwell/internal/filesystem"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestWebSocketBatches(t *testing.T) {
+	srv := newTestServer(t, t.TempDir())
+
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	for _, size := range []string{"14", "15", "16"} {
+		req, _ := http.NewRequest(http.MethodPut, ts.URL+"/api/settings", strings.NewReader(`{"fontSize":`+size+`}`))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("PUT failed: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	// Every frame is one message or an array of them; settings sent close
+	// together arrive as the latest only
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("Never received the latest settings: %v", err)
+		}
+		var messages []struct {
+			Type string `json:"type"`
+			Data struct {
+				FontSize int `json:"fontSize"`
+			} `json:"data"`
+		}
+		if data[0] != '[' {
+			data = append(append([]byte{'['}, data...), ']')
+		}
+		if err := json.Unmarshal(data, &messages); err != nil {
+			t.Fatalf("Invalid frame %s: %v", data, err)
+		}
+		for _, m := range messages {
+			if m.Type == "settings" && m.Data.FontSize == 16 {
+				return
+			}
+		}
+	}
+}
+
+func TestWebSocketSave(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "plan.md"), []byte("# Plan\n"), 0644)
+
+	srv := newTestServer(t, dir)
+
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/api/files?path=plan.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var file struct {
+		Data struct {
+			Version string `json:"version"`
+		} `json:"data"`
+	}
+	json.NewDecoder(resp.Body).Decode(&file)
+	resp.Body.Close()
+	original := file.Data.Version
+	if original == "" {
+		t.Fatal("Expected the note's version")
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	type message struct {
+		Type    string `json:"type"`
+		Path    string `json:"path"`
+		Content string `json:"content"`
+		Version string `json:"version"`
+	}
+	var received []message
+	// next returns the next save acknowledgment or conflict
+	next := func() message {
+		t.Helper()
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		for len(received) == 0 {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				t.Fatalf("Read failed: %v", err)
+			}
+			if data[0] != '[' {
+				data = append(append([]byte{'['}, data...), ']')
+			}
+			var messages []message
+			json.Unmarshal(data, &messages)
+			for _, m := range messages {
+				if m.Type == "saved" || m.Type == "saveConflict" || m.Type == "error" {
+					received = append(received, m)
+				}
+			}
+		}
+		m := received[0]
+		received = received[1:]
+		return m
+	}
+
+	// Saves sent faster than they're written land in order
+	const saves = 20
+	for i := 1; i <= saves; i++ {
+		conn.WriteJSON(map[string]string{"type": "save", "path": "plan.md", "content": fmt.Sprintf("# Plan\n\nDraft %d\n", i)})
+	}
+	var latest string
+	for i := 1; i <= saves; i++ {
+		m := next()
+		if m.Type != "saved" || m.Version == "" {
+			t.Fatalf("Expected save %d acknowledged, got %+v", i, m)
+		}
+		latest = m.Version
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "plan.md")); string(data) != fmt.Sprintf("# Plan\n\nDraft %d\n", saves) {
+		t.Fatalf("Expected the last save on disk, got %q", data)
+	}
+
+	// A save edited from an old version doesn't overwrite newer content
+	conn.WriteJSON(map[string]string{"type": "save", "path": "plan.md", "content": "# Stale\n", "version": original})
+	conflict := next()
+	if conflict.Type != "saveConflict" || conflict.Version != latest || !strings.Contains(conflict.Content, fmt.Sprintf("Draft %d", saves)) {
+		t.Fatalf("Expected a conflict with the current note, got %+v", conflict)
+	}
+
+	conn.WriteJSON(map[string]string{"type": "save", "path": "plan.md", "content": "# Merged\n", "version": latest})
+	if m := next(); m.Type != "saved" || m.Version == latest {
+		t.Fatalf("Expected the save from the current version written, got %+v", m)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "plan.md")); string(data) != "# Merged\n" {
+		t.Errorf("Expected the merged note on disk, got %q", data)
+	}
+}
+
+func TestBroadcastsFollowFolderRules(t *testing.T) {
+	ws := &workspace{
+		fs: filesystem.New(t.TempDir()),
+		access: func(actor string) filesystem.Guard {
+			if actor != "user:sam" {
+				return nil
+			}
+			return func(path string, write bool) bool { return !strings.HasPrefix(path, "private/") }
+		},
+	}
+	messages := []broadcastMessage{
+		{path: "private/diary.md", data: []byte(`"diary"`)},
+		{data: []byte(`"settings"`)},
+		{path: "team/plan.md", data: []byte(`"plan"`)},
+	}
+
+	sam := &Client{actor: "user:sam"}
+	if got := encodeBatch(sam.visible(ws, messages)); string(got) != `["settings","plan"]` {
+		t.Errorf("Expected the hidden note left out, got %s", got)
+	}
+	if got := sam.visible(ws, messages[:1]); len(got) != 0 {
+		t.Errorf("Expected nothing for a hidden note alone, got %d messages", len(got))
+	}
+	alex := &Client{actor: "user:alex"}
+	if got := encodeBatch(alex.visible(ws, messages)); string(got) != `["diary","settings","plan"]` {
+		t.Errorf("Expected everything without rules, got %s", got)
+	}
+}
@@ -0,0 +1,194 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+
+	"inkwell/internal/config"
+	"inkwell/internal/encryption"
+	"inkwell/internal/filesystem"
+	"inkwell/internal/index"
+	"inkwell/internal/render"
+	"inkwell/internal/settings"
+)
+
+// workspace is the directory being served. Changing directory builds a new
+// workspace and swaps it in whole, so a handler that loads it once sees a
+// root, file system and watcher that belong together even if the directory
+// changes mid-request.
+type workspace struct {
+	rootDir string
+	fs      *filesystem.FileSystem
+	watcher *filesystem.Watcher
+	index   *index.Index        // Built in the background once the workspace is in use
+	keys    *keyring            // Keys unlocking encrypted notes, by actor
+	vault   *config.VaultConfig // The vault's own .inkwell/config.json
+	bib     *bibCache           // Parsed bibliography for citations
+	assets  *assetCache         // Content hashes of served assets
+
+	// plantuml renders diagrams, or is nil when no PlantUML backend is
+	// configured
+	plantuml *render.PlantUML
+
+	// renderer converts notes to HTML. It is replaced when the markdown
+	// flavor changes.
+	renderer atomic.Pointer[render.Renderer]
+
+	// access returns the folder permissions of an actor, or nil when
+	// nothing restricts them
+	access func(actor string) filesystem.Guard
+}
+
+// newWorkspace opens a directory and starts watching it. Settings from the
+// vault's configuration file take precedence over cfg.
+func newWorkspace(rootDir string, cfg *config.Config) (*workspace, error) {
+	vault, err := config.LoadVault(rootDir)
+	if err != nil {
+		slog.Warn("Failed to load vault config", "error", err)
+		vault = &config.VaultConfig{}
+	}
+
+	ignore := cfg.Ignore
+	if vault.Ignore != nil {
+		ignore = vault.Ignore
+	}
+
+	math := !cfg.NoMath
+	if vault.Export.Math != nil {
+		math = *vault.Export.Math
+	}
+	renderer := render.New(render.Options{Math: math})
+	plantuml := newPlantUML(rootDir, cfg)
+	if plantuml != nil {
+		renderer.SetPlantUML(plantuml)
+	}
+
+	fs := &filesystem.FileSystem{
+		RootDir:     rootDir,
+		Ignore:      ignore,
+		Extensions:  vault.Extensions,
+		AssetsDir:   vault.AssetsDir,
+		AssetLayout: vault.AssetLayout,
+	}
+
+	// Files left unencrypted are sealed before anything watches the vault
+	if cfg.EncryptVault {
+		key, err := vaultKey(rootDir, cfg.Passphrase)
+		if err != nil {
+			return nil, err
+		}
+		fs.Cipher = key
+		sealed, err := fs.SealAll()
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt vault: %w", err)
+		}
+		if sealed > 0 {
+			slog.Info("Encrypted vault files", "count", sealed)
+		}
+	}
+
+	watcher, err := filesystem.NewWatcher(rootDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create watcher: %w", err)
+	}
+	watcher.SetExtensions(vault.Extensions)
+	fs.TrackChanges(watcher)
+
+	ws := &workspace{
+		rootDir: rootDir,
+		fs:      fs,
+		watcher: watcher,
+		index:   index.New(fs),
+		keys:    newKeyring(),
+		vault:   vault,
+		bib:     &bibCache{},
+		assets:  &assetCache{},
+
+		plantuml: plantuml,
+	}
+	ws.renderer.Store(renderer)
+	return ws, nil
+}
+
+// setMarkdown renders notes with the markdown flavor chosen in the settings
+func (ws *workspace) setMarkdown(current settings.Settings) {
+	renderer := ws.renderer.Load()
+	opts := renderer.Options()
+	if opts.Flavor == current.MarkdownFlavor && (opts.Flavor != render.FlavorCustom || opts.Extensions == current.MarkdownExtensions) {
+		return
+	}
+	opts.Flavor = current.MarkdownFlavor
+	opts.Extensions = current.MarkdownExtensions
+	ws.renderer.Store(renderer.With(opts))
+}
+
+// newPlantUML returns a diagram renderer caching in the vault at rootDir,
+// or nil when no PlantUML backend is configured
+func newPlantUML(rootDir string, cfg *config.Config) *render.PlantUML {
+	if cfg.PlantUMLServer == "" && cfg.PlantUMLJar == "" {
+		return nil
+	}
+	p, err := render.NewPlantUML(cfg.PlantUMLServer, cfg.PlantUMLJar, render.PlantUMLCacheDir(rootDir))
+	if err != nil {
+		slog.Warn("Failed to initialize PlantUML", "error", err)
+		return nil
+	}
+	return p
+}
+
+// vaultKey unlocks an encrypted vault, setting up its key the first time
+func vaultKey(rootDir, passphrase string) (*encryption.Key, error) {
+	key, err := encryption.Unlock(rootDir, passphrase)
+	if errors.Is(err, encryption.ErrNotConfigured) {
+		key, err = encryption.Setup(rootDir, passphrase)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to unlock vault: %w", err)
+	}
+	return key, nil
+}
+
+// files returns the file system as actor may see it, with folders their
+// permissions hide or make read-only
+func (ws *workspace) files(actor string) *filesystem.FileSystem {
+	if ws.access == nil {
+		return ws.fs
+	}
+	if guard := ws.access(actor); guard != nil {
+		return ws.fs.WithGuard(guard)
+	}
+	return ws.fs
+}
+
+// workspace returns the current workspace. Callers should load it once per
+// operation rather than calling it repeatedly.
+func (s *Server) workspace() *workspace {
+	return s.current.Load()
+}
+
+// switchWorkspace makes rootDir the served directory, closing the previous
+// watcher once the new one is in place
+func (s *Server) switchWorkspace(rootDir string) (*workspace, error) {
+	s.switchMu.Lock()
+	defer s.switchMu.Unlock()
+
+	ws, err := newWorkspace(rootDir, s.config)
+	if err != nil {
+		return nil, err
+	}
+	ws.access = s.guardFor
+	s.applyMarkdown(ws)
+
+	old := s.current.Swap(ws)
+	if old != nil {
+		old.watcher.Close()
+		old.index.Close()
+	}
+
+	go s.forwardFileEvents(ws)
+	ws.index.Start(s.hub.BroadcastIndexStatus)
+
+	return ws, nil
+}
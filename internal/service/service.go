@@ -0,0 +1,318 @@
+// Package service installs Inkwell as a per-user background service, a
+// systemd user unit on Linux, a launch agent on macOS or a scheduled task
+// run at logon on Windows
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// ErrUnsupported is returned on platforms without a supported service manager
+var ErrUnsupported = errors.New("services are only supported with systemd (Linux), launchd (macOS) and Task Scheduler (Windows)")
+
+// Spec describes the service to install
+type Spec struct {
+	Name       string   // Service name, unique per vault
+	Executable string   // Absolute path of the inkwell binary
+	Vault      string   // Absolute path of the vault to serve
+	Args       []string // Extra command-line options
+}
+
+// Command returns the full command line the service runs. The browser is
+// never opened by a service.
+func (s Spec) Command() []string {
+	cmd := []string{s.Executable, "--no-browser"}
+	cmd = append(cmd, s.Args...)
+	return append(cmd, s.Vault)
+}
+
+var invalidNameChars = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// Name derives a service name from a vault path
+func Name(vault string) string {
+	name := strings.Trim(invalidNameChars.ReplaceAllString(filepath.Base(vault), "-"), "-.")
+	if name == "" {
+		name = "vault"
+	}
+	return strings.ToLower(name)
+}
+
+// Path returns where the service definition is written for an OS
+func Path(goos, name string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	switch goos {
+	case "linux":
+		dir := os.Getenv("XDG_CONFIG_HOME")
+		if dir == "" {
+			dir = filepath.Join(home, ".config")
+		}
+		return filepath.Join(dir, "systemd", "user", unitName(name)), nil
+	case "darwin":
+		return filepath.Join(home, "Library", "LaunchAgents", label(name)+".plist"), nil
+	case "windows":
+		dir := os.Getenv("LOCALAPPDATA")
+		if dir == "" {
+			dir = filepath.Join(home, "AppData", "Local")
+		}
+		return filepath.Join(dir, "Inkwell", "services", name+".xml"), nil
+	default:
+		return "", ErrUnsupported
+	}
+}
+
+// Definition renders the service definition for an OS
+func Definition(goos string, s Spec) (string, error) {
+	switch goos {
+	case "linux":
+		return systemdUnit(s), nil
+	case "darwin":
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		return launchdPlist(s, filepath.Join(home, "Library", "Logs")), nil
+	case "windows":
+		return taskXML(s), nil
+	default:
+		return "", ErrUnsupported
+	}
+}
+
+// Install writes the service definition and, if start is set, enables and
+// starts it. It returns the file written.
+func Install(ctx context.Context, goos string, s Spec, start bool) (string, error) {
+	path, err := Path(goos, s.Name)
+	if err != nil {
+		return "", err
+	}
+	def, err := Definition(goos, s)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	data := []byte(def)
+	if goos == "windows" {
+		data = utf16LE(def) // What schtasks expects of task XML
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", err
+	}
+
+	if !start {
+		return path, nil
+	}
+	switch goos {
+	case "linux":
+		if err := run(ctx, "systemctl", "--user", "daemon-reload"); err != nil {
+			return path, err
+		}
+		err = run(ctx, "systemctl", "--user", "enable", "--now", unitName(s.Name))
+	case "darwin":
+		// Reload in case an older definition is already loaded
+		run(ctx, "launchctl", "unload", path)
+		err = run(ctx, "launchctl", "load", "-w", path)
+	case "windows":
+		if err := run(ctx, "schtasks", "/Create", "/TN", taskName(s.Name), "/XML", path, "/F"); err != nil {
+			return path, err
+		}
+		err = run(ctx, "schtasks", "/Run", "/TN", taskName(s.Name))
+	}
+	return path, err
+}
+
+// Uninstall stops the service and removes its definition
+func Uninstall(ctx context.Context, goos, name string) (string, error) {
+	path, err := Path(goos, name)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("service %q is not installed", name)
+	}
+
+	switch goos {
+	case "linux":
+		run(ctx, "systemctl", "--user", "disable", "--now", unitName(name))
+	case "darwin":
+		run(ctx, "launchctl", "unload", "-w", path)
+	case "windows":
+		run(ctx, "schtasks", "/End", "/TN", taskName(name))
+		run(ctx, "schtasks", "/Delete", "/TN", taskName(name), "/F")
+	}
+
+	if err := os.Remove(path); err != nil {
+		return "", err
+	}
+	if goos == "linux" {
+		run(ctx, "systemctl", "--user", "daemon-reload")
+	}
+	return path, nil
+}
+
+// unitName is the systemd unit for a service
+func unitName(name string) string {
+	return "inkwell-" + name + ".service"
+}
+
+// label is the launchd label for a service
+func label(name string) string {
+	return "com.inkwell." + name
+}
+
+// taskName is the Task Scheduler path for a service
+func taskName(name string) string {
+	return `Inkwell\` + name
+}
+
+// systemdUnit renders a user unit that restarts Inkwell if it fails
+func systemdUnit(s Spec) string {
+	quoted := make([]string, 0, len(s.Command()))
+	for _, arg := range s.Command() {
+		quoted = append(quoted, systemdQuote(arg))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "[Unit]\n")
+	fmt.Fprintf(&b, "Description=Inkwell (%s)\n", s.Vault)
+	fmt.Fprintf(&b, "After=network.target\n\n")
+	fmt.Fprintf(&b, "[Service]\n")
+	fmt.Fprintf(&b, "ExecStart=%s\n", strings.Join(quoted, " "))
+	fmt.Fprintf(&b, "WorkingDirectory=%s\n", s.Vault)
+	fmt.Fprintf(&b, "Restart=on-failure\n")
+	fmt.Fprintf(&b, "RestartSec=5\n\n")
+	fmt.Fprintf(&b, "[Install]\n")
+	fmt.Fprintf(&b, "WantedBy=default.target\n")
+	return b.String()
+}
+
+// systemdQuote quotes an argument for ExecStart, escaping % specifiers
+func systemdQuote(arg string) string {
+	arg = strings.ReplaceAll(arg, `\`, `\\`)
+	arg = strings.ReplaceAll(arg, `"`, `\"`)
+	arg = strings.ReplaceAll(arg, "%", "%%")
+	return `"` + arg + `"`
+}
+
+// launchdPlist renders a launch agent that starts at login and restarts
+// Inkwell if it exits with an error. Output goes to a log in logDir.
+func launchdPlist(s Spec, logDir string) string {
+	logFile := filepath.Join(logDir, "inkwell-"+s.Name+".log")
+
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	b.WriteString(`<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">` + "\n")
+	b.WriteString("<plist version=\"1.0\">\n<dict>\n")
+	fmt.Fprintf(&b, "  <key>Label</key>\n  <string>%s</string>\n", html.EscapeString(label(s.Name)))
+	b.WriteString("  <key>ProgramArguments</key>\n  <array>\n")
+	for _, arg := range s.Command() {
+		fmt.Fprintf(&b, "    <string>%s</string>\n", html.EscapeString(arg))
+	}
+	b.WriteString("  </array>\n")
+	fmt.Fprintf(&b, "  <key>WorkingDirectory</key>\n  <string>%s</string>\n", html.EscapeString(s.Vault))
+	b.WriteString("  <key>RunAtLoad</key>\n  <true/>\n")
+	b.WriteString("  <key>KeepAlive</key>\n  <dict>\n    <key>SuccessfulExit</key>\n    <false/>\n  </dict>\n")
+	b.WriteString("  <key>ThrottleInterval</key>\n  <integer>5</integer>\n")
+	fmt.Fprintf(&b, "  <key>StandardOutPath</key>\n  <string>%s</string>\n", html.EscapeString(logFile))
+	fmt.Fprintf(&b, "  <key>StandardErrorPath</key>\n  <string>%s</string>\n", html.EscapeString(logFile))
+	b.WriteString("</dict>\n</plist>\n")
+	return b.String()
+}
+
+// taskXML renders a scheduled task that starts Inkwell at logon, without a
+// console window or time limit, and restarts it if it fails
+func taskXML(s Spec) string {
+	cmd := s.Command()
+	args := make([]string, 0, len(cmd)-1)
+	for _, arg := range cmd[1:] {
+		args = append(args, windowsQuote(arg))
+	}
+
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-16"?>` + "\n")
+	b.WriteString(`<Task version="1.2" xmlns="http://schemas.microsoft.com/windows/2004/02/mit/task">` + "\n")
+	fmt.Fprintf(&b, "  <RegistrationInfo>\n    <Description>Inkwell (%s)</Description>\n  </RegistrationInfo>\n", html.EscapeString(s.Vault))
+	b.WriteString("  <Triggers>\n    <LogonTrigger>\n      <Enabled>true</Enabled>\n    </LogonTrigger>\n  </Triggers>\n")
+	b.WriteString("  <Principals>\n    <Principal id=\"Author\">\n      <LogonType>InteractiveToken</LogonType>\n      <RunLevel>LeastPrivilege</RunLevel>\n    </Principal>\n  </Principals>\n")
+	b.WriteString("  <Settings>\n")
+	b.WriteString("    <MultipleInstancesPolicy>IgnoreNew</MultipleInstancesPolicy>\n")
+	b.WriteString("    <DisallowStartIfOnBatteries>false</DisallowStartIfOnBatteries>\n")
+	b.WriteString("    <StopIfGoingOnBatteries>false</StopIfGoingOnBatteries>\n")
+	b.WriteString("    <ExecutionTimeLimit>PT0S</ExecutionTimeLimit>\n")
+	b.WriteString("    <RestartOnFailure>\n      <Interval>PT1M</Interval>\n      <Count>999</Count>\n    </RestartOnFailure>\n")
+	b.WriteString("    <Hidden>true</Hidden>\n")
+	b.WriteString("  </Settings>\n")
+	b.WriteString("  <Actions Context=\"Author\">\n    <Exec>\n")
+	fmt.Fprintf(&b, "      <Command>%s</Command>\n", html.EscapeString(cmd[0]))
+	fmt.Fprintf(&b, "      <Arguments>%s</Arguments>\n", html.EscapeString(strings.Join(args, " ")))
+	fmt.Fprintf(&b, "      <WorkingDirectory>%s</WorkingDirectory>\n", html.EscapeString(s.Vault))
+	b.WriteString("    </Exec>\n  </Actions>\n</Task>\n")
+	return b.String()
+}
+
+// windowsQuote quotes an argument so Windows programs split it back out
+// the way CommandLineToArgvW does
+func windowsQuote(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\"") {
+		return arg
+	}
+	var b strings.Builder
+	b.WriteByte('"')
+	slashes := 0
+	for _, c := range arg {
+		switch c {
+		case '\\':
+			slashes++
+		case '"':
+			// Backslashes before a quote are doubled, and the quote escaped
+			b.WriteString(strings.Repeat(`\`, slashes+1))
+			slashes = 0
+		default:
+			slashes = 0
+		}
+		b.WriteRune(c)
+	}
+	// So are backslashes before the closing quote
+	b.WriteString(strings.Repeat(`\`, slashes))
+	b.WriteByte('"')
+	return b.String()
+}
+
+// utf16LE encodes text as UTF-16 with a byte order mark
+func utf16LE(text string) []byte {
+	units := utf16.Encode([]rune(text))
+	out := make([]byte, 2, 2+2*len(units))
+	out[0], out[1] = 0xFF, 0xFE
+	for _, u := range units {
+		out = append(out, byte(u), byte(u>>8))
+	}
+	return out
+}
+
+// run executes a service manager command
+func run(ctx context.Context, name string, args ...string) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s %s failed: %w: %s", name, strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
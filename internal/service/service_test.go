@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestName(t *testing.T) {
+	tests := map[string]string{
+		"/home/ada/My Notes": "my-notes",
+		"/srv/wiki":          "wiki",
+		"/":                  "vault",
+	}
+	for vault, want := range tests {
+		if got := Name(vault); got != want {
+			t.Errorf("Name(%q) = %q, want %q", vault, got, want)
+		}
+	}
+}
+
+func TestSystemdUnit(t *testing.T) {
+	unit := systemdUnit(Spec{
+		Name:       "notes",
+		Executable: "/usr/local/bin/inkwell",
+		Vault:      "/home/ada/My Notes",
+		Args:       []string{"--port", "8080", "--on-save", `echo "100%"`},
+	})
+
+	for _, want := range []string{
+		`ExecStart="/usr/local/bin/inkwell" "--no-browser" "--port" "8080" "--on-save" "echo \"100%%\"" "/home/ada/My Notes"`,
+		"Restart=on-failure",
+		"WantedBy=default.target",
+	} {
+		if !strings.Contains(unit, want) {
+			t.Errorf("Unit missing %s:\n%s", want, unit)
+		}
+	}
+}
+
+func TestLaunchdPlist(t *testing.T) {
+	plist := launchdPlist(Spec{
+		Name:       "notes",
+		Executable: "/usr/local/bin/inkwell",
+		Vault:      "/Users/ada/R&D",
+	}, "/Users/ada/Library/Logs")
+
+	for _, want := range []string{
+		"<string>com.inkwell.notes</string>",
+		"<string>/Users/ada/R&amp;D</string>",
+		"<key>SuccessfulExit</key>\n    <false/>",
+		"<string>/Users/ada/Library/Logs/inkwell-notes.log</string>",
+	} {
+		if !strings.Contains(plist, want) {
+			t.Errorf("Plist missing %s:\n%s", want, plist)
+		}
+	}
+}
+
+func TestTaskXML(t *testing.T) {
+	task := taskXML(Spec{
+		Name:       "notes",
+		Executable: `C:\Program Files\Inkwell\inkwell.exe`,
+		Vault:      `C:\Users\ada\R&D Notes\`,
+		Args:       []string{"--on-save", `echo "saved"`},
+	})
+
+	for _, want := range []string{
+		"<Command>C:\\Program Files\\Inkwell\\inkwell.exe</Command>",
+		`<Arguments>--no-browser --on-save &#34;echo \&#34;saved\&#34;&#34; &#34;C:\Users\ada\R&amp;D Notes\\&#34;</Arguments>`,
+		"<LogonTrigger>",
+		"<ExecutionTimeLimit>PT0S</ExecutionTimeLimit>",
+	} {
+		if !strings.Contains(task, want) {
+			t.Errorf("Task missing %s:\n%s", want, task)
+		}
+	}
+}
+
+func TestWindowsQuote(t *testing.T) {
+	tests := map[string]string{
+		"--port":       "--port",
+		"":             `""`,
+		"My Notes":     `"My Notes"`,
+		`C:\a b\`:      `"C:\a b\\"`,
+		`say "hi"`:     `"say \"hi\""`,
+		`a\"b`:         `"a\\\"b"`,
+		`C:\no\spaces`: `C:\no\spaces`,
+	}
+	for in, want := range tests {
+		if got := windowsQuote(in); got != want {
+			t.Errorf("windowsQuote(%q) = %s, want %s", in, got, want)
+		}
+	}
+}
+
+func TestInstallWithoutStart(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+
+	spec := Spec{Name: "notes", Executable: "/bin/inkwell", Vault: "/srv/notes"}
+	path, err := Install(context.Background(), "linux", spec, false)
+	if err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+	if want := filepath.Join(home, ".config", "systemd", "user", "inkwell-notes.service"); path != want {
+		t.Errorf("Path = %s, want %s", path, want)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("Unit not written: %v", err)
+	}
+
+	if _, err := Install(context.Background(), "plan9", spec, false); err != ErrUnsupported {
+		t.Errorf("Expected ErrUnsupported, got %v", err)
+	}
+}
+
+func TestInstallWindowsWithoutStart(t *testing.T) {
+	local := t.TempDir()
+	t.Setenv("LOCALAPPDATA", local)
+
+	spec := Spec{Name: "notes", Executable: `C:\inkwell.exe`, Vault: `C:\notes`}
+	path, err := Install(context.Background(), "windows", spec, false)
+	if err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+	if want := filepath.Join(local, "Inkwell", "services", "notes.xml"); path != want {
+		t.Errorf("Path = %s, want %s", path, want)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || len(data) < 2 || data[0] != 0xFF || data[1] != 0xFE {
+		t.Errorf("Expected UTF-16 task XML with a byte order mark, got %.8q, %v", data, err)
+	}
+}
@@ -0,0 +1,104 @@
+// Package session remembers where the user left off in a vault: the notes
+// open in tabs, the folders expanded in the tree and how far each note was
+// scrolled
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"inkwell/internal/atomicfile"
+)
+
+// File holds the session, relative to the vault root
+const File = ".inkwell/session.json"
+
+// Session lists the notes open in tabs, in tab order
+type Session struct {
+	Files    []string       `json:"files"`
+	Active   string         `json:"active,omitempty"`
+	Expanded []string       `json:"expanded,omitempty"` // Folders expanded in the file tree
+	Scroll   map[string]int `json:"scroll,omitempty"`   // Scroll offset of each open note, in pixels
+}
+
+// Load reads the session saved under rootDir. A vault without one gets an
+// empty session.
+func Load(rootDir string) (*Session, error) {
+	s := &Session{Files: []string{}}
+
+	data, err := os.ReadFile(filepath.Join(rootDir, filepath.FromSlash(File)))
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", File, err)
+	}
+	s.Clean()
+	return s, nil
+}
+
+// Save writes the session under rootDir
+func Save(rootDir string, s *Session) error {
+	s.Clean()
+
+	file := filepath.Join(rootDir, filepath.FromSlash(File))
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(file, data, 0600)
+}
+
+// Clean drops duplicate paths and any that are absolute or leave the vault,
+// and scroll offsets of notes that are not open
+func (s *Session) Clean() {
+	seen := make(map[string]bool)
+	s.Files = cleanPaths(s.Files, seen)
+
+	if !seen[s.Active] {
+		s.Active = ""
+	}
+
+	s.Expanded = cleanPaths(s.Expanded, make(map[string]bool))
+
+	for f, offset := range s.Scroll {
+		if !seen[f] || offset <= 0 {
+			delete(s.Scroll, f)
+		}
+	}
+	if len(s.Scroll) == 0 {
+		s.Scroll = nil
+	}
+}
+
+// cleanPaths returns the valid paths in order, skipping any already seen
+func cleanPaths(paths []string, seen map[string]bool) []string {
+	cleaned := make([]string, 0, len(paths))
+	for _, p := range paths {
+		if !validPath(p) || seen[p] {
+			continue
+		}
+		seen[p] = true
+		cleaned = append(cleaned, p)
+	}
+	return cleaned
+}
+
+// validPath reports whether p is a clean slash-separated path inside the vault
+func validPath(p string) bool {
+	return p != "" && !path.IsAbs(p) && !strings.Contains(p, "\\") &&
+		path.Clean(p) == p && p != ".." && !strings.HasPrefix(p, "../")
+}
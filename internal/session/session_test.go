@@ -0,0 +1,74 @@
+package session
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLoadMissing(t *testing.T) {
+	s, err := Load(t.TempDir())
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(s.Files) != 0 || s.Active != "" {
+		t.Errorf("Expected empty session, got %+v", s)
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	dir := t.TempDir()
+
+	saved := &Session{
+		Files:  []string{"a.md", "notes/b.md", "a.md", "../outside.md", "/etc/passwd", "x/../y.md"},
+		Active: "notes/b.md",
+	}
+	if err := Save(dir, saved); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	loaded, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	want := []string{"a.md", "notes/b.md"}
+	if !reflect.DeepEqual(loaded.Files, want) {
+		t.Errorf("Files = %v, want %v", loaded.Files, want)
+	}
+	if loaded.Active != "notes/b.md" {
+		t.Errorf("Active = %q", loaded.Active)
+	}
+}
+
+func TestCleanDropsUnknownActive(t *testing.T) {
+	s := &Session{Files: []string{"a.md"}, Active: "b.md"}
+	s.Clean()
+	if s.Active != "" {
+		t.Errorf("Active = %q, want empty", s.Active)
+	}
+}
+
+func TestSaveTreeAndScroll(t *testing.T) {
+	dir := t.TempDir()
+
+	saved := &Session{
+		Files:    []string{"a.md", "b.md"},
+		Expanded: []string{"notes", "notes/daily", "notes", "../up"},
+		Scroll:   map[string]int{"a.md": 420, "b.md": 0, "closed.md": 100},
+	}
+	if err := Save(dir, saved); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	loaded, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if want := []string{"notes", "notes/daily"}; !reflect.DeepEqual(loaded.Expanded, want) {
+		t.Errorf("Expanded = %v, want %v", loaded.Expanded, want)
+	}
+	if want := map[string]int{"a.md": 420}; !reflect.DeepEqual(loaded.Scroll, want) {
+		t.Errorf("Scroll = %v, want %v", loaded.Scroll, want)
+	}
+}
@@ -0,0 +1,218 @@
+// Package settings persists editor and server preferences for Inkwell
+package settings
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"inkwell/internal/atomicfile"
+	"inkwell/internal/render"
+)
+
+const (
+	inkwellDir   = ".inkwell"
+	settingsFile = "settings.json"
+
+	DefaultFontSize         = 16
+	DefaultAutosaveInterval = 1000 // Milliseconds
+	DefaultExtension        = ".md"
+
+	minFontSize         = 10
+	maxFontSize         = 32
+	maxAutosaveInterval = 60000
+)
+
+// Themes lists the themes the UI ships with
+var Themes = []string{"light", "light-sepia", "light-ocean", "dark", "dark-nord", "dark-monokai"}
+
+// Settings are the stored preferences. An empty Theme follows the server's
+// --theme flag.
+type Settings struct {
+	Theme            string `json:"theme,omitempty"`
+	FontSize         int    `json:"fontSize"`
+	AutosaveInterval int    `json:"autosaveInterval"` // Milliseconds; 0 turns autosave off
+	DefaultExtension string `json:"defaultExtension"` // Added to new files without one
+
+	MarkdownFlavor     string            `json:"markdownFlavor"`     // Extensions notes are rendered with: github, obsidian, commonmark or custom
+	MarkdownExtensions render.Extensions `json:"markdownExtensions"` // Used with the custom flavor
+}
+
+// Defaults returns the settings used before anything is saved
+func Defaults() Settings {
+	// The custom flavor starts out with GitHub's extensions
+	extensions, _ := render.FlavorExtensions(render.FlavorGitHub)
+	return Settings{
+		FontSize:           DefaultFontSize,
+		AutosaveInterval:   DefaultAutosaveInterval,
+		DefaultExtension:   DefaultExtension,
+		MarkdownFlavor:     render.FlavorGitHub,
+		MarkdownExtensions: extensions,
+	}
+}
+
+// Validate checks that every field holds an accepted value
+func (s Settings) Validate() error {
+	if s.Theme != "" && !validTheme(s.Theme) {
+		return fmt.Errorf("unknown theme: %s", s.Theme)
+	}
+	if s.FontSize < minFontSize || s.FontSize > maxFontSize {
+		return fmt.Errorf("fontSize must be between %d and %d", minFontSize, maxFontSize)
+	}
+	if s.AutosaveInterval < 0 || s.AutosaveInterval > maxAutosaveInterval {
+		return fmt.Errorf("autosaveInterval must be between 0 and %d", maxAutosaveInterval)
+	}
+	ext := s.DefaultExtension
+	if len(ext) < 2 || len(ext) > 10 || ext[0] != '.' || strings.ContainsAny(ext[1:], `./\ `) {
+		return fmt.Errorf("invalid defaultExtension: %q", ext)
+	}
+	if !validFlavor(s.MarkdownFlavor) {
+		return fmt.Errorf("unknown markdownFlavor: %s", s.MarkdownFlavor)
+	}
+	return nil
+}
+
+func validFlavor(flavor string) bool {
+	for _, f := range render.Flavors {
+		if f == flavor {
+			return true
+		}
+	}
+	return false
+}
+
+func validTheme(theme string) bool {
+	for _, t := range Themes {
+		if t == theme {
+			return true
+		}
+	}
+	return false
+}
+
+// Manager loads and saves the settings file
+type Manager struct {
+	mu       sync.RWMutex
+	settings Settings
+	filePath string
+}
+
+// New creates a settings manager backed by ~/.inkwell/settings.json
+func New() (*Manager, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewAt(filepath.Join(home, inkwellDir))
+}
+
+// NewAt creates a settings manager that stores its file in dir
+func NewAt(dir string) (*Manager, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	m := &Manager{
+		filePath: filepath.Join(dir, settingsFile),
+		settings: Defaults(),
+	}
+
+	if err := m.load(); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to load settings: %w", err)
+	}
+
+	return m, nil
+}
+
+// load reads the settings file, keeping defaults for missing fields
+func (m *Manager) load() error {
+	data, err := os.ReadFile(m.filePath)
+	if err != nil {
+		return err
+	}
+
+	loaded := Defaults()
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		return err
+	}
+	if err := loaded.Validate(); err != nil {
+		return err
+	}
+
+	m.settings = loaded
+	return nil
+}
+
+// Get returns the current settings
+func (m *Manager) Get() Settings {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.settings
+}
+
+// Update applies a JSON object of changes over the current settings.
+// Fields left out keep their values. The result is validated before it
+// is saved.
+func (m *Manager) Update(changes []byte) (Settings, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	updated := m.settings
+	if err := json.Unmarshal(changes, &updated); err != nil {
+		return Settings{}, fmt.Errorf("invalid settings: %w", err)
+	}
+	if err := updated.Validate(); err != nil {
+		return Settings{}, err
+	}
+
+	data, err := json.MarshalIndent(updated, "", "  ")
+	if err != nil {
+		return Settings{}, err
+	}
+	if err := atomicfile.WriteFile(m.filePath, data, 0600); err != nil {
+		return Settings{}, fmt.Errorf("failed to save settings: %w", err)
+	}
+
+	m.settings = updated
+	return updated, nil
+}
+
+// Replace saves a complete set of settings, such as one synced from
+// another machine
+func (m *Manager) Replace(s Settings) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := atomicfile.WriteFile(m.filePath, data, 0600); err != nil {
+		return fmt.Errorf("failed to save settings: %w", err)
+	}
+
+	m.settings = s
+	return nil
+}
+
+// Modified returns when the settings were last saved, or the zero time if
+// they never were
+func (m *Manager) Modified() time.Time {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	info, err := os.Stat(m.filePath)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
@@ -0,0 +1,82 @@
+package settings
+
+import (
+	"testing"
+)
+
+func TestDefaults(t *testing.T) {
+	m, err := NewAt(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewAt failed: %v", err)
+	}
+
+	if got := m.Get(); got != Defaults() {
+		t.Errorf("Get = %+v, want defaults", got)
+	}
+}
+
+func TestUpdatePersists(t *testing.T) {
+	dir := t.TempDir()
+	m, err := NewAt(dir)
+	if err != nil {
+		t.Fatalf("NewAt failed: %v", err)
+	}
+
+	updated, err := m.Update([]byte(`{"theme": "dark-nord", "fontSize": 18}`))
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if updated.Theme != "dark-nord" || updated.FontSize != 18 || updated.AutosaveInterval != DefaultAutosaveInterval {
+		t.Errorf("Unexpected settings: %+v", updated)
+	}
+
+	reloaded, err := NewAt(dir)
+	if err != nil {
+		t.Fatalf("NewAt failed: %v", err)
+	}
+	if reloaded.Get() != updated {
+		t.Errorf("Reloaded %+v, want %+v", reloaded.Get(), updated)
+	}
+}
+
+func TestUpdateMarkdownExtensions(t *testing.T) {
+	m, err := NewAt(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewAt failed: %v", err)
+	}
+
+	// Extensions left out keep their values
+	updated, err := m.Update([]byte(`{"markdownFlavor": "custom", "markdownExtensions": {"hardWraps": true, "footnotes": false}}`))
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	ext := updated.MarkdownExtensions
+	if updated.MarkdownFlavor != "custom" || !ext.HardWraps || ext.Footnotes || !ext.Tables {
+		t.Errorf("Unexpected markdown settings: %+v", updated)
+	}
+}
+
+func TestUpdateValidation(t *testing.T) {
+	m, err := NewAt(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewAt failed: %v", err)
+	}
+
+	for _, changes := range []string{
+		`{"theme": "neon"}`,
+		`{"fontSize": 4}`,
+		`{"autosaveInterval": -1}`,
+		`{"defaultExtension": "md"}`,
+		`{"defaultExtension": "./x"}`,
+		`{"fontSize": "large"}`,
+		`{"markdownFlavor": "wiki"}`,
+	} {
+		if _, err := m.Update([]byte(changes)); err == nil {
+			t.Errorf("Update(%s) should fail", changes)
+		}
+	}
+
+	if m.Get() != Defaults() {
+		t.Errorf("Rejected updates changed settings: %+v", m.Get())
+	}
+}
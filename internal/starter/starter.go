@@ -0,0 +1,175 @@
+// Package starter fills a new vault with notes that introduce Inkwell, so
+// first-time users don't start from an empty folder
+package starter
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"inkwell/internal/config"
+	"inkwell/internal/git"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+)
+
+//go:embed vault
+var files embed.FS
+
+// TemplatesDir is the folder of the starter vault's note templates
+const TemplatesDir = "templates"
+
+// gitignore keeps files that don't belong in a vault's history out of git
+const gitignore = `# Operating system files
+.DS_Store
+Thumbs.db
+desktop.ini
+
+# Editor backups and swap files
+*~
+*.swp
+
+# Notes deleted in Inkwell, and the tabs left open
+.trash/
+.inkwell/session.json
+`
+
+// vaultConfig points Inkwell at the starter templates
+const vaultConfig = `{
+  "templatesDir": "` + TemplatesDir + `"
+}
+`
+
+// Options controls what Create adds besides the notes
+type Options struct {
+	Git bool // Make the folder a git repository and commit the starter files
+
+	// Author of the first commit; empty for the git config identity
+	AuthorName  string
+	AuthorEmail string
+	SignKey     *openpgp.Entity // Signs the first commit when set
+}
+
+// Result reports what Create did
+type Result struct {
+	Path    string   `json:"path"`
+	Created []string `json:"created"`           // Relative to Path
+	Skipped []string `json:"skipped,omitempty"` // Already there and left alone
+	Commit  string   `json:"commit,omitempty"`  // The first commit, when git was set up
+}
+
+// Create writes the starter notes, templates, a .gitignore and a vault
+// configuration into dir, creating it if needed. Files that already exist
+// are never overwritten. With Options.Git, a folder that isn't in a git
+// repository yet becomes one, with the created files as its first commit;
+// an existing repository is left for the user to commit to.
+func Create(dir string, opts Options) (*Result, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(absDir); err == nil && !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", absDir)
+	}
+	if err := os.MkdirAll(absDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create vault directory: %w", err)
+	}
+
+	contents := map[string][]byte{
+		".gitignore":           []byte(gitignore),
+		config.VaultConfigFile: []byte(vaultConfig),
+	}
+	err = fs.WalkDir(files, "vault", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := files.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel("vault", p)
+		contents[filepath.ToSlash(rel)] = data
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(contents))
+	for name := range contents {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result := &Result{Path: absDir, Created: []string{}}
+	for _, name := range names {
+		created, err := writeNew(filepath.Join(absDir, filepath.FromSlash(name)), contents[name])
+		if err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", name, err)
+		}
+		if created {
+			result.Created = append(result.Created, name)
+		} else {
+			result.Skipped = append(result.Skipped, name)
+		}
+	}
+
+	if opts.Git {
+		commit, err := initRepository(absDir, result.Created, opts)
+		if err != nil {
+			return result, err
+		}
+		result.Commit = commit
+	}
+	return result, nil
+}
+
+// writeNew writes a file unless something is already at its path
+func writeNew(file string, data []byte) (bool, error) {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return false, err
+	}
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if errors.Is(err, fs.ErrExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return false, err
+	}
+	return true, f.Close()
+}
+
+// initRepository makes dir a git repository and commits the created
+// files, unless dir is already in one
+func initRepository(dir string, created []string, opts Options) (string, error) {
+	if existing, err := git.Open(dir); err != nil || existing != nil {
+		return "", err
+	}
+	repo, err := git.Init(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to initialize git repository: %w", err)
+	}
+	if len(created) == 0 {
+		return "", nil
+	}
+
+	commit, err := repo.Commit(git.CommitOptions{
+		Message:     "Initial commit",
+		AuthorName:  opts.AuthorName,
+		AuthorEmail: opts.AuthorEmail,
+		SignKey:     opts.SignKey,
+		Files:       created,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to commit starter files: %w", err)
+	}
+	return commit.Hash, nil
+}
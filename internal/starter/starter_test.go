@@ -0,0 +1,59 @@
+package starter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"inkwell/internal/config"
+	"inkwell/internal/git"
+)
+
+func TestCreate(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "vault")
+
+	result, err := Create(dir, Options{Git: true, AuthorName: "Sam", AuthorEmail: "sam@example.com"})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	for _, name := range []string{"Welcome.md", "templates/Daily note.md", ".gitignore", config.VaultConfigFile} {
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name))); err != nil {
+			t.Errorf("Expected %s to be created: %v", name, err)
+		}
+	}
+	if len(result.Skipped) != 0 {
+		t.Errorf("Expected nothing skipped, got %v", result.Skipped)
+	}
+
+	vault, err := config.LoadVault(dir)
+	if err != nil || vault.TemplatesDir != TemplatesDir {
+		t.Errorf("Expected the vault config to set the templates folder, got %+v, %v", vault, err)
+	}
+
+	repo, err := git.Open(dir)
+	if err != nil || repo == nil {
+		t.Fatalf("Expected a git repository, got %v", err)
+	}
+	status, err := repo.Status()
+	if err != nil || !status.IsClean {
+		t.Errorf("Expected the starter files committed, got %+v, %v", status, err)
+	}
+	if result.Commit == "" {
+		t.Error("Expected the first commit to be reported")
+	}
+
+	// Running again leaves edited notes alone and doesn't commit
+	if err := os.WriteFile(filepath.Join(dir, "Welcome.md"), []byte("# Mine"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	result, err = Create(dir, Options{Git: true})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if len(result.Created) != 0 || result.Commit != "" {
+		t.Errorf("Expected nothing created or committed, got %+v", result)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "Welcome.md")); string(data) != "# Mine" {
+		t.Errorf("Expected Welcome.md to be kept, got %q", data)
+	}
+}
@@ -0,0 +1,324 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	vaultDir  = "vault"
+	stateFile = "state.json"
+)
+
+// syncState records what each file looked like when it was last synced,
+// so changes on either side can be told apart
+type syncState struct {
+	ETag    string    `json:"etag"`    // Remote version
+	Hash    string    `json:"hash"`    // SHA-256 of the content
+	Size    int64     `json:"size"`    // Local size and modification time, to
+	ModTime time.Time `json:"modTime"` // skip hashing unchanged files
+}
+
+// Status reports how syncing is going
+type Status struct {
+	Storage  string    `json:"storage"` // URL with any password hidden
+	LastSync time.Time `json:"lastSync,omitempty"`
+	Error    string    `json:"error,omitempty"` // From the last sync, if it failed
+}
+
+// Mirror keeps a local copy of a vault in remote storage. When a file
+// changed on both sides, the local version wins and the remote one is
+// kept beside it as a conflicted copy.
+type Mirror struct {
+	backend Backend
+	url     string
+	dir     string // Holds the cached vault and the sync state
+
+	mu     sync.Mutex // Serializes syncs
+	state  map[string]*syncState
+	status Status
+}
+
+// NewMirror caches the vault from backend under dir, which is created if
+// needed. Nothing is synced until Sync is called.
+func NewMirror(backend Backend, rawURL, dir string) (*Mirror, error) {
+	m := &Mirror{
+		backend: backend,
+		url:     rawURL,
+		dir:     dir,
+		state:   make(map[string]*syncState),
+		status:  Status{Storage: Redact(rawURL)},
+	}
+	if err := os.MkdirAll(m.Dir(), 0755); err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, stateFile))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &m.state); err != nil {
+			return nil, fmt.Errorf("failed to parse sync state: %w", err)
+		}
+	}
+	return m, nil
+}
+
+// Dir returns the folder holding the local copy of the vault
+func (m *Mirror) Dir() string {
+	return filepath.Join(m.dir, vaultDir)
+}
+
+// Status returns the outcome of the last sync
+func (m *Mirror) Status() Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.status
+}
+
+// Sync pulls changes made elsewhere, then pushes local ones
+func (m *Mirror) Sync(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	err := m.pull(ctx)
+	if err == nil {
+		err = m.push(ctx)
+	}
+	return m.finish(err)
+}
+
+// Push uploads local changes without looking for remote ones
+func (m *Mirror) Push(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.finish(m.push(ctx))
+}
+
+// finish saves the sync state and records the outcome
+func (m *Mirror) finish(err error) error {
+	if saveErr := m.saveState(); err == nil {
+		err = saveErr
+	}
+	m.status.Error = ""
+	if err != nil {
+		m.status.Error = err.Error()
+	} else {
+		m.status.LastSync = time.Now()
+	}
+	return err
+}
+
+// pull downloads files changed in remote storage and removes local files
+// deleted there
+func (m *Mirror) pull(ctx context.Context) error {
+	objects, err := m.backend.List(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list remote files: %w", err)
+	}
+
+	remote := make(map[string]bool, len(objects))
+	for _, obj := range objects {
+		if !validPath(obj.Path) {
+			continue
+		}
+		remote[obj.Path] = true
+
+		st := m.state[obj.Path]
+		if st != nil && st.ETag == obj.ETag && obj.ETag != "" {
+			continue
+		}
+		data, err := m.backend.Get(ctx, obj.Path)
+		if errors.Is(err, ErrNotFound) {
+			continue // Deleted since it was listed
+		}
+		if err != nil {
+			return fmt.Errorf("failed to download %s: %w", obj.Path, err)
+		}
+		hash := hashOf(data)
+
+		local := m.localPath(obj.Path)
+		localData, err := os.ReadFile(local)
+		localExists := err == nil
+		switch {
+		case localExists && hashOf(localData) == hash:
+			// Same content on both sides
+		case localExists && (st == nil || hashOf(localData) != st.Hash):
+			// Changed on both sides: keep the remote version beside
+			// the local one, which push uploads
+			if err := writeFile(conflictPath(local, time.Now()), data); err != nil {
+				return err
+			}
+			m.state[obj.Path] = &syncState{ETag: obj.ETag}
+			if st != nil {
+				m.state[obj.Path].Hash = st.Hash
+			}
+			continue
+		default:
+			if err := writeFile(local, data); err != nil {
+				return err
+			}
+		}
+		m.record(obj.Path, obj.ETag, hash)
+	}
+
+	// Files deleted remotely go locally too, unless they were edited
+	for p, st := range m.state {
+		if remote[p] {
+			continue
+		}
+		local := m.localPath(p)
+		data, err := os.ReadFile(local)
+		if err == nil && hashOf(data) == st.Hash {
+			if err := os.Remove(local); err != nil {
+				return err
+			}
+		}
+		delete(m.state, p)
+	}
+	return nil
+}
+
+// push uploads local files that changed since they were last synced and
+// deletes remote files removed locally
+func (m *Mirror) push(ctx context.Context) error {
+	seen := make(map[string]bool)
+	root := m.Dir()
+	err := filepath.WalkDir(root, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return nil // Skip entries we can't read
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || isTempFile(d.Name()) {
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		seen[rel] = true
+
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		st := m.state[rel]
+		if st != nil && st.Size == info.Size() && st.ModTime.Equal(info.ModTime()) {
+			return nil
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return nil
+		}
+		hash := hashOf(data)
+		if st != nil && st.Hash == hash {
+			st.Size, st.ModTime = info.Size(), info.ModTime()
+			return nil
+		}
+
+		etag, err := m.backend.Put(ctx, rel, data)
+		if err != nil {
+			return fmt.Errorf("failed to upload %s: %w", rel, err)
+		}
+		m.record(rel, etag, hash)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for p := range m.state {
+		if seen[p] {
+			continue
+		}
+		if err := m.backend.Delete(ctx, p); err != nil {
+			return fmt.Errorf("failed to delete %s: %w", p, err)
+		}
+		delete(m.state, p)
+	}
+	return nil
+}
+
+// record notes that a file was synced, reading its local size and
+// modification time
+func (m *Mirror) record(p, etag, hash string) {
+	st := &syncState{ETag: etag, Hash: hash}
+	if info, err := os.Stat(m.localPath(p)); err == nil {
+		st.Size, st.ModTime = info.Size(), info.ModTime()
+	}
+	m.state[p] = st
+}
+
+func (m *Mirror) saveState() error {
+	data, err := json.Marshal(m.state)
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(m.dir, stateFile+".tmp")
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to save sync state: %w", err)
+	}
+	return os.Rename(tmp, filepath.Join(m.dir, stateFile))
+}
+
+func (m *Mirror) localPath(p string) string {
+	return filepath.Join(m.Dir(), filepath.FromSlash(p))
+}
+
+// validPath rejects remote names that would land outside the vault
+func validPath(p string) bool {
+	clean := path.Clean(p)
+	return p != "" && clean == p && !path.IsAbs(p) && clean != ".." && !strings.HasPrefix(clean, "../")
+}
+
+// isTempFile reports whether a name belongs to a file being written, which
+// is synced once it is renamed into place
+func isTempFile(name string) bool {
+	return strings.HasPrefix(name, ".") && strings.HasSuffix(name, ".tmp")
+}
+
+// conflictPath names the copy kept when a file changed on both sides, e.g.
+// "notes/plan (conflict 2024-05-01 1504).md"
+func conflictPath(local string, now time.Time) string {
+	ext := filepath.Ext(local)
+	return strings.TrimSuffix(local, ext) + " (conflict " + now.Format("2006-01-02 1504") + ")" + ext
+}
+
+// writeFile replaces a file through a temporary one, so a watcher never
+// sees it half written
+func writeFile(p string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	tmp := filepath.Join(filepath.Dir(p), "."+filepath.Base(p)+".tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, p); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+func hashOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
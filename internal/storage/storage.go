@@ -0,0 +1,100 @@
+// Package storage keeps a vault that lives in remote storage, such as a
+// WebDAV share or an S3-compatible bucket, mirrored in a local cache.
+// Inkwell edits the cache like any other folder; a Mirror pushes local
+// changes up and pulls changes made elsewhere down.
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DefaultInterval is how often remote storage is checked for changes made
+// elsewhere
+const DefaultInterval = 30 * time.Second
+
+const (
+	inkwellDir  = ".inkwell"
+	storageDir  = "storage"
+	passwordEnv = "INKWELL_STORAGE_PASSWORD"
+)
+
+// ErrNotFound is returned for files missing from remote storage
+var ErrNotFound = errors.New("not found in remote storage")
+
+// Object describes a file in remote storage
+type Object struct {
+	Path    string    // Relative to the vault root, slash separated
+	Size    int64     // In bytes
+	ModTime time.Time // As reported by the backend
+	ETag    string    // Changes whenever the content does
+}
+
+// Backend reads and writes the files of a vault in remote storage. Paths
+// are relative to the vault root and slash separated.
+type Backend interface {
+	List(ctx context.Context) ([]Object, error)
+	Get(ctx context.Context, path string) ([]byte, error)
+	// Put stores a file, creating folders as needed, and returns its new
+	// ETag if the backend reports one
+	Put(ctx context.Context, path string, data []byte) (string, error)
+	Delete(ctx context.Context, path string) error
+}
+
+// Open returns the backend for a storage URL:
+//
+//	webdav://user@host/path, webdavs://user@host/path
+//	s3://bucket/prefix?region=eu-west-1&endpoint=https://minio.local:9000
+//
+// The WebDAV password comes from the URL or INKWELL_STORAGE_PASSWORD; S3
+// credentials from AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY.
+func Open(rawURL string) (Backend, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid storage URL: %w", err)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid storage URL %q: missing host", Redact(rawURL))
+	}
+
+	switch u.Scheme {
+	case "webdav", "webdavs":
+		return newWebDAV(u), nil
+	case "s3":
+		return newS3(u)
+	default:
+		return nil, fmt.Errorf("unsupported storage %q (use webdav, webdavs or s3)", u.Scheme)
+	}
+}
+
+// Redact hides the password in a storage URL for logs and the API
+func Redact(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	return u.Redacted()
+}
+
+// CacheDir returns where the vault at a storage URL is cached, under
+// ~/.inkwell/storage
+func CacheDir(rawURL string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	u.User = nil // The same vault is cached once whoever signs in
+	sum := sha256.Sum256([]byte(u.String()))
+	return filepath.Join(home, inkwellDir, storageDir, hex.EncodeToString(sum[:8])), nil
+}
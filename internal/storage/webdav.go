@@ -0,0 +1,249 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// webDAV stores a vault on a WebDAV server, such as Nextcloud
+type webDAV struct {
+	base     *url.URL // Folder holding the vault, ending in a slash
+	user     string
+	password string
+	client   *http.Client
+}
+
+func newWebDAV(u *url.URL) *webDAV {
+	base := *u
+	base.Scheme = "http"
+	if u.Scheme == "webdavs" {
+		base.Scheme = "https"
+	}
+	base.User = nil
+	if !strings.HasSuffix(base.Path, "/") {
+		base.Path += "/"
+	}
+
+	d := &webDAV{
+		base:     &base,
+		password: os.Getenv(passwordEnv),
+		client:   &http.Client{Timeout: 5 * time.Minute},
+	}
+	if u.User != nil {
+		d.user = u.User.Username()
+		if password, ok := u.User.Password(); ok {
+			d.password = password
+		}
+	}
+	return d
+}
+
+// propfindBody asks only for what List needs
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:"><d:prop>
+<d:resourcetype/><d:getcontentlength/><d:getlastmodified/><d:getetag/>
+</d:prop></d:propfind>`
+
+type multistatus struct {
+	Responses []struct {
+		Href string `xml:"href"`
+		Prop struct {
+			ResourceType struct {
+				Collection *struct{} `xml:"collection"`
+			} `xml:"resourcetype"`
+			ContentLength string `xml:"getcontentlength"`
+			LastModified  string `xml:"getlastmodified"`
+			ETag          string `xml:"getetag"`
+		} `xml:"propstat>prop"`
+	} `xml:"response"`
+}
+
+// List walks the vault one folder at a time, as many servers refuse
+// "Depth: infinity"
+func (d *webDAV) List(ctx context.Context) ([]Object, error) {
+	var objects []Object
+	pending := []string{""}
+	for len(pending) > 0 {
+		dir := pending[0]
+		pending = pending[1:]
+
+		req, err := d.request(ctx, "PROPFIND", dir, strings.NewReader(propfindBody))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Depth", "1")
+		req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+
+		resp, err := d.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		var ms multistatus
+		err = xml.NewDecoder(resp.Body).Decode(&ms)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusMultiStatus {
+			return nil, fmt.Errorf("listing %s: %s", d.base.Path+dir, resp.Status)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("listing %s: %w", d.base.Path+dir, err)
+		}
+
+		for _, r := range ms.Responses {
+			rel, ok := d.relative(r.Href)
+			if !ok || strings.TrimSuffix(rel, "/") == strings.TrimSuffix(dir, "/") {
+				continue // The folder itself
+			}
+			if r.Prop.ResourceType.Collection != nil {
+				pending = append(pending, strings.TrimSuffix(rel, "/")+"/")
+				continue
+			}
+			size, _ := strconv.ParseInt(r.Prop.ContentLength, 10, 64)
+			modTime, _ := http.ParseTime(r.Prop.LastModified)
+			objects = append(objects, Object{
+				Path:    rel,
+				Size:    size,
+				ModTime: modTime,
+				ETag:    strings.Trim(r.Prop.ETag, `"`),
+			})
+		}
+	}
+	return objects, nil
+}
+
+func (d *webDAV) Get(ctx context.Context, p string) ([]byte, error) {
+	req, err := d.request(ctx, http.MethodGet, p, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, ErrNotFound
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("downloading %s: %s", p, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func (d *webDAV) Put(ctx context.Context, p string, data []byte) (string, error) {
+	resp, err := d.put(ctx, p, data)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode == http.StatusConflict {
+		// The folder doesn't exist yet
+		if err := d.mkdirAll(ctx, path.Dir(p)); err != nil {
+			return "", err
+		}
+		if resp, err = d.put(ctx, p, data); err != nil {
+			return "", err
+		}
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("uploading %s: %s", p, resp.Status)
+	}
+	return strings.Trim(resp.Header.Get("ETag"), `"`), nil
+}
+
+func (d *webDAV) put(ctx context.Context, p string, data []byte) (*http.Response, error) {
+	req, err := d.request(ctx, http.MethodPut, p, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return resp, nil
+}
+
+// mkdirAll creates a folder and its parents, ignoring those that exist
+func (d *webDAV) mkdirAll(ctx context.Context, dir string) error {
+	if dir == "." || dir == "" {
+		return nil
+	}
+	if err := d.mkdirAll(ctx, path.Dir(dir)); err != nil {
+		return err
+	}
+
+	req, err := d.request(ctx, "MKCOL", dir+"/", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	// 405 means the folder is already there
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusMethodNotAllowed {
+		return fmt.Errorf("creating folder %s: %s", dir, resp.Status)
+	}
+	return nil
+}
+
+func (d *webDAV) Delete(ctx context.Context, p string) error {
+	req, err := d.request(ctx, http.MethodDelete, p, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("deleting %s: %s", p, resp.Status)
+	}
+	return nil
+}
+
+// request builds an authenticated request for a path in the vault
+func (d *webDAV) request(ctx context.Context, method, p string, body io.Reader) (*http.Request, error) {
+	u := *d.base
+	u.Path += p
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if d.user != "" {
+		req.SetBasicAuth(d.user, d.password)
+	}
+	return req, nil
+}
+
+// relative turns an href from a listing into a path in the vault
+func (d *webDAV) relative(href string) (string, bool) {
+	u, err := url.Parse(href)
+	if err != nil {
+		return "", false
+	}
+	rel, ok := strings.CutPrefix(u.Path, d.base.Path)
+	if !ok {
+		// Some servers leave the folder's own trailing slash off
+		if u.Path+"/" == d.base.Path {
+			return "", true
+		}
+		return "", false
+	}
+	return rel, true
+}
@@ -0,0 +1,310 @@
+// Package sync keeps the open repository in step with its remote in the
+// background. It fetches on a schedule so the ahead and behind counts stay
+// fresh and, when asked to, pulls and pushes while nothing local is in the
+// way.
+package sync
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"inkwell/internal/git"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+// Mode is how far a sync goes
+type Mode string
+
+const (
+	ModeFetch Mode = "fetch" // Only fetch, keeping ahead and behind fresh
+	ModePull  Mode = "pull"  // Also fast-forward when nothing local is changed
+	ModePush  Mode = "push"  // Also push local commits when nothing is behind
+)
+
+// Returned by Now when there is nothing to sync
+var (
+	ErrNoRepository = errors.New("not a git repository")
+	ErrNoRemote     = errors.New("no remote configured")
+)
+
+// DefaultIdleTime is how long nothing must have been changed before a
+// scheduled sync runs
+const DefaultIdleTime = 30 * time.Second
+
+// ValidMode reports whether mode is a known sync mode
+func ValidMode(mode string) bool {
+	switch Mode(mode) {
+	case ModeFetch, ModePull, ModePush:
+		return true
+	}
+	return false
+}
+
+// Status describes the last sync and what the branch looks like next to
+// its remote afterwards
+type Status struct {
+	Enabled  bool      `json:"enabled"` // Syncs run on a schedule
+	Mode     Mode      `json:"mode"`
+	Running  bool      `json:"running"`
+	LastSync time.Time `json:"lastSync,omitempty"` // When the last sync finished
+	NextSync time.Time `json:"nextSync,omitempty"` // When the next scheduled sync is due
+	Branch   string    `json:"branch,omitempty"`
+	Ahead    int       `json:"ahead"`
+	Behind   int       `json:"behind"`
+	Pulled   int       `json:"pulled,omitempty"`  // Commits the last sync brought in
+	Pushed   bool      `json:"pushed,omitempty"`  // The last sync pushed the branch
+	Skipped  string    `json:"skipped,omitempty"` // Why the last sync didn't pull or push
+	Paused   bool      `json:"paused,omitempty"`  // The remote needs credentials; scheduled syncs wait for a manual one
+	Error    string    `json:"error,omitempty"`   // From the last sync, if it failed
+}
+
+// Options configures a Scheduler
+type Options struct {
+	Interval time.Duration // How often to sync; 0 only syncs when asked
+	Mode     Mode          // Defaults to ModeFetch
+	Timeout  time.Duration // Longest a sync may take; 0 never gives up
+	IdleTime time.Duration // Scheduled syncs wait until nothing was changed for this long
+	Wait     time.Duration // Longest Now waits for other git operations; 0 waits as long as its context
+	Worktree sync.Locker   // Held while a pull changes the worktree, keeping other writes out; nil for none
+
+	// LastChange returns when something was last changed; nil never waits
+	LastChange func() time.Time
+	// Notify is called after every sync with its status, and with the
+	// repository's status when the sync changed it
+	Notify func(Status, *git.GitStatus)
+}
+
+// Scheduler syncs the manager's current repository on a schedule
+type Scheduler struct {
+	git  *git.Manager
+	opts Options
+
+	mu      sync.Mutex
+	status  Status
+	refused map[string]bool // Remotes that asked for credentials
+}
+
+// New creates a scheduler for the manager's current repository. Nothing
+// runs until Run or Now is called.
+func New(manager *git.Manager, opts Options) *Scheduler {
+	if opts.Mode == "" {
+		opts.Mode = ModeFetch
+	}
+	return &Scheduler{
+		git:     manager,
+		opts:    opts,
+		status:  Status{Enabled: opts.Interval > 0, Mode: opts.Mode},
+		refused: make(map[string]bool),
+	}
+}
+
+// Status returns how the last sync went
+func (s *Scheduler) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := s.status
+	if repo := s.git.CurrentRepository(); repo != nil {
+		status.Paused = s.refused[repo.GetRemoteURL()]
+	}
+	return status
+}
+
+// Run syncs every interval until stop is closed
+func (s *Scheduler) Run(stop <-chan struct{}) {
+	if s.opts.Interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(s.opts.Interval)
+	defer ticker.Stop()
+
+	s.setNext(time.Now().Add(s.opts.Interval))
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			s.scheduled()
+			s.setNext(time.Now().Add(s.opts.Interval))
+		}
+	}
+}
+
+func (s *Scheduler) setNext(next time.Time) {
+	s.mu.Lock()
+	s.status.NextSync = next
+	s.mu.Unlock()
+}
+
+// scheduled syncs if nothing was changed lately, the remote needs no
+// credentials from the user and nothing else is using the repository
+func (s *Scheduler) scheduled() {
+	if s.opts.LastChange != nil && time.Since(s.opts.LastChange()) < s.opts.IdleTime {
+		return
+	}
+	repo := s.git.CurrentRepository()
+	if repo == nil {
+		return
+	}
+	url := repo.GetRemoteURL()
+	s.mu.Lock()
+	refused := s.refused[url]
+	s.mu.Unlock()
+	if url == "" || refused || !git.CanAuthenticateSilently(url) {
+		return
+	}
+
+	release, ok := s.git.TryBegin(repo, "sync")
+	if !ok {
+		return
+	}
+	defer release()
+	s.run(context.Background(), repo)
+}
+
+// Now syncs the current repository straight away, waiting for other git
+// operations to finish first. It returns a git.BusyError if they are still
+// running after the Wait option. It also resumes syncing a remote that
+// asked for credentials.
+func (s *Scheduler) Now(ctx context.Context) (Status, error) {
+	repo := s.git.CurrentRepository()
+	if repo == nil {
+		return Status{}, ErrNoRepository
+	}
+	if repo.GetRemoteURL() == "" {
+		return Status{}, ErrNoRemote
+	}
+	wait := ctx
+	if s.opts.Wait > 0 {
+		var cancel context.CancelFunc
+		wait, cancel = context.WithTimeout(ctx, s.opts.Wait)
+		defer cancel()
+	}
+	release, err := s.git.Begin(wait, repo, "sync")
+	if err != nil {
+		return Status{}, err
+	}
+	defer release()
+	return s.run(ctx, repo)
+}
+
+// run syncs repo, which the caller has begun an operation on, and records
+// and announces how it went
+func (s *Scheduler) run(ctx context.Context, repo *git.Repository) (Status, error) {
+	s.mu.Lock()
+	s.status.Running = true
+	s.mu.Unlock()
+
+	if s.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.opts.Timeout)
+		defer cancel()
+	}
+	url := repo.GetRemoteURL()
+	result, changed, err := s.sync(ctx, repo)
+
+	s.mu.Lock()
+	result.Enabled = s.status.Enabled
+	result.NextSync = s.status.NextSync
+	result.LastSync = time.Now()
+	if err != nil {
+		result.Error = err.Error()
+		if errors.Is(err, transport.ErrAuthenticationRequired) || errors.Is(err, transport.ErrAuthorizationFailed) {
+			// Asking again every interval won't help
+			if !s.refused[url] {
+				slog.Info("Sync needs credentials; stopped for this remote", "remote", url)
+			}
+			s.refused[url] = true
+		} else {
+			slog.Debug("Sync failed", "remote", url, "error", err)
+		}
+	} else {
+		delete(s.refused, url)
+	}
+	result.Paused = s.refused[url]
+	s.status = result
+	s.mu.Unlock()
+
+	if s.opts.Notify != nil {
+		var status *git.GitStatus
+		if changed {
+			status, _ = repo.Status()
+		}
+		s.opts.Notify(result, status)
+	}
+	return result, err
+}
+
+// sync fetches, then pulls and pushes as far as the mode allows. It
+// reports whether the repository or its remote branches changed.
+func (s *Scheduler) sync(ctx context.Context, repo *git.Repository) (Status, bool, error) {
+	result := Status{Mode: s.opts.Mode, Branch: repo.Branch()}
+
+	fetched, err := repo.FetchContext(ctx, git.DefaultRemote, nil)
+	if err != nil {
+		return result, false, err
+	}
+	changed := fetched.Message != "Already up to date"
+
+	status, err := repo.Status()
+	if err != nil {
+		return result, changed, err
+	}
+
+	if s.opts.Mode != ModeFetch && status.Behind > 0 {
+		if reason := pullBlocked(status); reason != "" {
+			result.Skipped = reason
+		} else {
+			if s.opts.Worktree != nil {
+				s.opts.Worktree.Lock()
+			}
+			pulled, err := repo.PullContext(ctx, git.DefaultRemote, nil)
+			if s.opts.Worktree != nil {
+				s.opts.Worktree.Unlock()
+			}
+			if err != nil {
+				return result, changed, err
+			}
+			result.Pulled = pulled.NewCommits
+			changed = true
+			if status, err = repo.Status(); err != nil {
+				return result, changed, err
+			}
+		}
+	}
+
+	if s.opts.Mode == ModePush && status.Ahead > 0 && status.Behind == 0 && !status.HasConflicts {
+		if _, err := repo.PushContext(ctx, git.PushRequest{}, nil); err != nil {
+			return result, changed, err
+		}
+		result.Pushed = true
+		changed = true
+		if status, err = repo.Status(); err != nil {
+			return result, changed, err
+		}
+	}
+
+	result.Ahead = status.Ahead
+	result.Behind = status.Behind
+	return result, changed, nil
+}
+
+// pullBlocked returns why pulling into the worktree could lose or tangle
+// local work, or an empty string if it's safe. Untracked files don't count.
+func pullBlocked(status *git.GitStatus) string {
+	if status.HasConflicts {
+		return "the repository has unresolved conflicts"
+	}
+	if status.Ahead > 0 {
+		return "local and remote commits have diverged"
+	}
+	for _, f := range status.Files {
+		if f.Status != "untracked" {
+			return "there are uncommitted changes"
+		}
+	}
+	return ""
+}
@@ -0,0 +1,172 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"inkwell/internal/git"
+
+	gogit "github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// commitFile writes a file into a clone and commits it
+func commitFile(t *testing.T, repo *gogit.Repository, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wt.Add(name); err != nil {
+		t.Fatal(err)
+	}
+	sig := &object.Signature{Name: "Test User", Email: "test@example.com", When: time.Now()}
+	if _, err := wt.Commit("Update "+name, &gogit.CommitOptions{Author: sig}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// setup returns a manager with a clone of a bare remote open, and a second
+// clone standing in for another machine
+func setup(t *testing.T) (manager *git.Manager, local string, other *gogit.Repository, otherDir string) {
+	t.Helper()
+	remote := t.TempDir()
+	if _, err := gogit.PlainInit(remote, true); err != nil {
+		t.Fatal(err)
+	}
+
+	otherDir = t.TempDir()
+	other, err := gogit.PlainInit(otherDir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.CreateRemote(&gitconfig.RemoteConfig{Name: "origin", URLs: []string{remote}}); err != nil {
+		t.Fatal(err)
+	}
+	commitFile(t, other, otherDir, "a.md", "# A\n")
+	if err := other.Push(&gogit.PushOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	local = filepath.Join(t.TempDir(), "local")
+	if _, err := gogit.PlainClone(local, false, &gogit.CloneOptions{URL: remote}); err != nil {
+		t.Fatal(err)
+	}
+	manager, err = git.NewManagerAt(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := manager.OpenRepository(local); err != nil {
+		t.Fatal(err)
+	}
+	return manager, local, other, otherDir
+}
+
+func TestFetchOnly(t *testing.T) {
+	manager, _, other, otherDir := setup(t)
+	var notified []Status
+	s := New(manager, Options{Notify: func(status Status, _ *git.GitStatus) { notified = append(notified, status) }})
+
+	commitFile(t, other, otherDir, "b.md", "# B\n")
+	if err := other.Push(&gogit.PushOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	status, err := s.Now(context.Background())
+	if err != nil {
+		t.Fatalf("Now() error = %v", err)
+	}
+	if status.Behind != 1 || status.Pulled != 0 || status.LastSync.IsZero() {
+		t.Errorf("Expected one commit behind and nothing pulled, got %+v", status)
+	}
+	if len(notified) != 1 || s.Status().Behind != 1 {
+		t.Errorf("Expected one notification, got %+v", notified)
+	}
+}
+
+func TestPullAndPush(t *testing.T) {
+	manager, local, other, otherDir := setup(t)
+	s := New(manager, Options{Mode: ModePush})
+
+	commitFile(t, other, otherDir, "b.md", "# B\n")
+	if err := other.Push(&gogit.PushOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Local edits to tracked files hold the pull back
+	os.WriteFile(filepath.Join(local, "a.md"), []byte("# A, edited\n"), 0644)
+	status, err := s.Now(context.Background())
+	if err != nil || status.Behind != 1 || status.Skipped == "" {
+		t.Fatalf("Expected the pull skipped, got %+v, %v", status, err)
+	}
+
+	// Untracked files don't
+	os.WriteFile(filepath.Join(local, "a.md"), []byte("# A\n"), 0644)
+	os.WriteFile(filepath.Join(local, "draft.md"), []byte("draft\n"), 0644)
+	status, err = s.Now(context.Background())
+	if err != nil || status.Behind != 0 || status.Pulled != 1 {
+		t.Fatalf("Expected one commit pulled, got %+v, %v", status, err)
+	}
+	if _, err := os.Stat(filepath.Join(local, "b.md")); err != nil {
+		t.Errorf("Expected b.md pulled: %v", err)
+	}
+
+	repo, err := gogit.PlainOpen(local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commitFile(t, repo, local, "c.md", "# C\n")
+	status, err = s.Now(context.Background())
+	if err != nil || !status.Pushed || status.Ahead != 0 {
+		t.Fatalf("Expected the commit pushed, got %+v, %v", status, err)
+	}
+}
+
+func TestNothingToSync(t *testing.T) {
+	manager, err := git.NewManagerAt(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(manager, Options{}).Now(context.Background()); err != ErrNoRepository {
+		t.Errorf("Expected ErrNoRepository, got %v", err)
+	}
+
+	dir := t.TempDir()
+	if _, err := gogit.PlainInit(dir, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := manager.OpenRepository(dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(manager, Options{}).Now(context.Background()); err != ErrNoRemote {
+		t.Errorf("Expected ErrNoRemote, got %v", err)
+	}
+}
+
+func TestNowGivesUpWaiting(t *testing.T) {
+	manager, _, _, _ := setup(t)
+	s := New(manager, Options{Wait: 50 * time.Millisecond})
+
+	release, err := manager.Begin(context.Background(), manager.CurrentRepository(), "commit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	_, err = s.Now(context.Background())
+	var busy *git.BusyError
+	if !errors.As(err, &busy) || busy.Operation != "commit" {
+		t.Fatalf("Expected a BusyError naming the commit, got %v", err)
+	}
+	if status := s.Status(); !status.LastSync.IsZero() {
+		t.Errorf("Expected nothing synced, got %+v", status)
+	}
+}
@@ -0,0 +1,315 @@
+// Package update replaces the running binary with the latest GitHub release
+package update
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ChecksumsFile is the release asset listing the SHA-256 of every binary,
+// in sha256sum format. A signature of it is published as ChecksumsFile+".sig".
+const ChecksumsFile = "checksums.txt"
+
+// maxAssetSize bounds downloads so a bad release cannot fill the disk
+const maxAssetSize = 200 << 20
+
+// Build-time settings, overridable with -ldflags -X
+var (
+	Repo      = "samart/inkwell"
+	APIURL    = "https://api.github.com"
+	PublicKey = "" // Base64 Ed25519 key checksums are signed with; replaces the release key
+)
+
+// releaseKey is the base64 Ed25519 key releases are signed with
+//
+//go:embed release.pub
+var releaseKey string
+
+// ErrUnsigned is returned by Download when a release can't be verified:
+// it has no signature, or this build has no key to check one with
+var ErrUnsigned = errors.New("release signature can't be verified")
+
+// Release is a published GitHub release
+type Release struct {
+	Tag    string  `json:"tag_name"`
+	URL    string  `json:"html_url"`
+	Assets []Asset `json:"assets"`
+}
+
+// Asset is a file attached to a release
+type Asset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+}
+
+// Updater checks for and downloads releases
+type Updater struct {
+	Client    *http.Client
+	APIURL    string
+	Repo      string
+	PublicKey string // Checksums must be signed with this key
+	Insecure  bool   // Accept releases that can't be verified; a bad signature is still refused
+}
+
+// New returns an Updater using the build-time settings
+func New() *Updater {
+	key := PublicKey
+	if key == "" {
+		key = strings.TrimSpace(releaseKey)
+	}
+	return &Updater{
+		Client:    &http.Client{Timeout: 5 * time.Minute},
+		APIURL:    APIURL,
+		Repo:      Repo,
+		PublicKey: key,
+	}
+}
+
+// Latest returns the newest published release
+func (u *Updater) Latest(ctx context.Context) (*Release, error) {
+	url := fmt.Sprintf("%s/repos/%s/releases/latest", strings.TrimSuffix(u.APIURL, "/"), u.Repo)
+	data, err := u.get(ctx, url, 1<<20)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for updates: %w", err)
+	}
+
+	var rel Release
+	if err := json.Unmarshal(data, &rel); err != nil {
+		return nil, fmt.Errorf("invalid release data: %w", err)
+	}
+	if rel.Tag == "" {
+		return nil, errors.New("invalid release data: missing tag")
+	}
+	return &rel, nil
+}
+
+// Download fetches the release binary for this platform and verifies it
+// against the release checksums, which must be signed with the public key
+// unless the updater is insecure
+func (u *Updater) Download(ctx context.Context, rel *Release) ([]byte, error) {
+	name := AssetName(runtime.GOOS, runtime.GOARCH)
+	binary := rel.asset(name)
+	if binary == nil {
+		return nil, fmt.Errorf("release %s has no binary for %s/%s", rel.Tag, runtime.GOOS, runtime.GOARCH)
+	}
+	sums := rel.asset(ChecksumsFile)
+	if sums == nil {
+		return nil, fmt.Errorf("release %s has no %s", rel.Tag, ChecksumsFile)
+	}
+
+	sumData, err := u.get(ctx, sums.URL, 1<<20)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download checksums: %w", err)
+	}
+
+	sig := rel.asset(ChecksumsFile + ".sig")
+	switch {
+	case u.PublicKey != "" && sig != nil:
+		sigData, err := u.get(ctx, sig.URL, 4<<10)
+		if err != nil {
+			return nil, fmt.Errorf("failed to download signature: %w", err)
+		}
+		if err := VerifySignature(u.PublicKey, sumData, sigData); err != nil {
+			return nil, err
+		}
+	case u.Insecure:
+	case u.PublicKey == "":
+		return nil, fmt.Errorf("%w: this build has no release key", ErrUnsigned)
+	default:
+		return nil, fmt.Errorf("%w: release %s is not signed", ErrUnsigned, rel.Tag)
+	}
+
+	want, err := Checksum(sumData, name)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := u.get(ctx, binary.URL, maxAssetSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", name, err)
+	}
+
+	got := sha256.Sum256(data)
+	if hex.EncodeToString(got[:]) != want {
+		return nil, fmt.Errorf("checksum mismatch for %s", name)
+	}
+	return data, nil
+}
+
+// get downloads url, failing if the body is larger than limit
+func (u *Updater) get(ctx context.Context, url string, limit int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := u.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("%s: response too large", url)
+	}
+	return data, nil
+}
+
+// asset returns the named asset, or nil
+func (r *Release) asset(name string) *Asset {
+	for i := range r.Assets {
+		if r.Assets[i].Name == name {
+			return &r.Assets[i]
+		}
+	}
+	return nil
+}
+
+// AssetName returns the release binary name for a platform, matching
+// `make build-all`
+func AssetName(goos, goarch string) string {
+	name := fmt.Sprintf("inkwell-%s-%s", goos, goarch)
+	if goos == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// Checksum finds the hex SHA-256 of name in a sha256sum listing
+func Checksum(sums []byte, name string) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(sums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf("no checksum listed for %s", name)
+}
+
+// VerifySignature checks a base64 Ed25519 signature of data
+func VerifySignature(publicKey string, data, signature []byte) error {
+	key, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return errors.New("invalid update public key")
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature)))
+	if err != nil {
+		return errors.New("invalid checksum signature")
+	}
+	if !ed25519.Verify(ed25519.PublicKey(key), data, sig) {
+		return errors.New("checksum signature does not match")
+	}
+	return nil
+}
+
+// Newer reports whether release version latest is newer than current.
+// Development builds are older than every release.
+func Newer(latest, current string) bool {
+	l, ok := parseVersion(latest)
+	if !ok {
+		return false
+	}
+	c, ok := parseVersion(current)
+	if !ok {
+		return true
+	}
+	for i := range l {
+		if l[i] != c[i] {
+			return l[i] > c[i]
+		}
+	}
+	return false
+}
+
+// parseVersion reads major.minor.patch from versions such as v1.2.3 or
+// v1.2.3-4-gabcdef (as produced by git describe)
+func parseVersion(v string) ([3]int, bool) {
+	var parts [3]int
+
+	v = strings.TrimPrefix(v, "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+	fields := strings.Split(v, ".")
+	if len(fields) == 0 || len(fields) > 3 {
+		return parts, false
+	}
+	for i, f := range fields {
+		n, err := strconv.Atoi(f)
+		if err != nil || n < 0 {
+			return parts, false
+		}
+		parts[i] = n
+	}
+	return parts, true
+}
+
+// Replace atomically swaps the executable at path for data. The old binary
+// is kept alongside as path+".old" until the next update, since Windows
+// cannot delete a running executable.
+func Replace(path string, data []byte) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".*.new")
+	if err != nil {
+		return fmt.Errorf("cannot write to %s: %w", dir, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()|0111); err != nil {
+		return err
+	}
+
+	old := path + ".old"
+	os.Remove(old)
+	if err := os.Rename(path, old); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		// Put the original back
+		os.Rename(old, path)
+		return err
+	}
+	if runtime.GOOS != "windows" {
+		os.Remove(old)
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package update
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestNewer(t *testing.T) {
+	tests := []struct {
+		latest, current string
+		want            bool
+	}{
+		{"v1.2.0", "v1.1.9", true},
+		{"v1.2.0", "v1.2.0", false},
+		{"v1.2.0", "v1.10.0", false},
+		{"v2.0", "v1.9.9", true},
+		{"v1.2.0", "dev", true},
+		{"v1.2.0", "v1.2.0-3-gabcdef-dirty", false},
+		{"nightly", "v1.0.0", false},
+	}
+	for _, tt := range tests {
+		if got := Newer(tt.latest, tt.current); got != tt.want {
+			t.Errorf("Newer(%q, %q) = %v, want %v", tt.latest, tt.current, got, tt.want)
+		}
+	}
+}
+
+// releaseServer serves a latest release with a binary for this platform
+func releaseServer(t *testing.T, binary []byte, sums string, sig string) *httptest.Server {
+	t.Helper()
+
+	name := AssetName(runtime.GOOS, runtime.GOARCH)
+	mux := http.NewServeMux()
+	var srv *httptest.Server
+	mux.HandleFunc("/repos/samart/inkwell/releases/latest", func(w http.ResponseWriter, r *http.Request) {
+		rel := Release{Tag: "v9.0.0", Assets: []Asset{
+			{Name: name, URL: srv.URL + "/download/bin"},
+			{Name: ChecksumsFile, URL: srv.URL + "/download/sums"},
+		}}
+		if sig != "" {
+			rel.Assets = append(rel.Assets, Asset{Name: ChecksumsFile + ".sig", URL: srv.URL + "/download/sig"})
+		}
+		json.NewEncoder(w).Encode(rel)
+	})
+	mux.HandleFunc("/download/bin", func(w http.ResponseWriter, r *http.Request) { w.Write(binary) })
+	mux.HandleFunc("/download/sums", func(w http.ResponseWriter, r *http.Request) { fmt.Fprint(w, sums) })
+	mux.HandleFunc("/download/sig", func(w http.ResponseWriter, r *http.Request) { fmt.Fprint(w, sig) })
+	srv = httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestDownload(t *testing.T) {
+	binary := []byte("new inkwell")
+	sum := sha256.Sum256(binary)
+	sums := fmt.Sprintf("%s  %s\n", hex.EncodeToString(sum[:]), AssetName(runtime.GOOS, runtime.GOARCH))
+
+	srv := releaseServer(t, binary, sums, "")
+	u := &Updater{Client: srv.Client(), APIURL: srv.URL, Repo: "samart/inkwell"}
+
+	rel, err := u.Latest(context.Background())
+	if err != nil {
+		t.Fatalf("Latest failed: %v", err)
+	}
+	if rel.Tag != "v9.0.0" {
+		t.Errorf("Tag = %q", rel.Tag)
+	}
+
+	// Without a key nothing can be verified
+	if _, err := u.Download(context.Background(), rel); !errors.Is(err, ErrUnsigned) {
+		t.Fatalf("Expected ErrUnsigned without a key, got %v", err)
+	}
+
+	u.Insecure = true
+	data, err := u.Download(context.Background(), rel)
+	if err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	if string(data) != "new inkwell" {
+		t.Errorf("Downloaded %q", data)
+	}
+
+	bad := releaseServer(t, []byte("tampered"), sums, "")
+	u = &Updater{Client: bad.Client(), APIURL: bad.URL, Repo: "samart/inkwell", Insecure: true}
+	rel, _ = u.Latest(context.Background())
+	if _, err := u.Download(context.Background(), rel); err == nil {
+		t.Error("Download should fail on a checksum mismatch")
+	}
+}
+
+func TestDownloadSigned(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := base64.StdEncoding.EncodeToString(pub)
+
+	binary := []byte("signed inkwell")
+	sum := sha256.Sum256(binary)
+	sums := fmt.Sprintf("%s  %s\n", hex.EncodeToString(sum[:]), AssetName(runtime.GOOS, runtime.GOARCH))
+	sig := base64.StdEncoding.EncodeToString(ed25519.Sign(priv, []byte(sums)))
+
+	srv := releaseServer(t, binary, sums, sig)
+	u := &Updater{Client: srv.Client(), APIURL: srv.URL, Repo: "samart/inkwell", PublicKey: key}
+	rel, _ := u.Latest(context.Background())
+	if _, err := u.Download(context.Background(), rel); err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+
+	unsigned := releaseServer(t, binary, sums, "")
+	u = &Updater{Client: unsigned.Client(), APIURL: unsigned.URL, Repo: "samart/inkwell", PublicKey: key}
+	rel, _ = u.Latest(context.Background())
+	if _, err := u.Download(context.Background(), rel); !errors.Is(err, ErrUnsigned) {
+		t.Errorf("Download should require a signature when a key is set, got %v", err)
+	}
+	u.Insecure = true
+	if _, err := u.Download(context.Background(), rel); err != nil {
+		t.Errorf("An insecure download should accept an unsigned release: %v", err)
+	}
+
+	forged := base64.StdEncoding.EncodeToString(ed25519.Sign(priv, []byte("other")))
+	wrong := releaseServer(t, binary, sums, forged)
+	u = &Updater{Client: wrong.Client(), APIURL: wrong.URL, Repo: "samart/inkwell", PublicKey: key}
+	rel, _ = u.Latest(context.Background())
+	if _, err := u.Download(context.Background(), rel); err == nil {
+		t.Error("Download should reject a bad signature")
+	}
+	u.Insecure = true
+	if _, err := u.Download(context.Background(), rel); err == nil {
+		t.Error("An insecure download should still reject a bad signature")
+	}
+}
+
+func TestReplace(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "inkwell")
+	if err := os.WriteFile(path, []byte("old"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Replace(path, []byte("new")); err != nil {
+		t.Fatalf("Replace failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "new" {
+		t.Errorf("Binary = %q, %v", data, err)
+	}
+	info, _ := os.Stat(path)
+	if info.Mode().Perm()&0100 == 0 {
+		t.Errorf("Replaced binary is not executable: %v", info.Mode())
+	}
+}
@@ -0,0 +1,389 @@
+// Package uploads stages large files sent in chunks so interrupted uploads
+// can resume where they stopped
+package uploads
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	inkwellDir = ".inkwell"
+	uploadsDir = "uploads"
+
+	// staleAfter is how long an untouched upload is kept before cleanup
+	staleAfter = 24 * time.Hour
+)
+
+var (
+	// ErrNotFound is returned for unknown or expired upload IDs
+	ErrNotFound = errors.New("upload not found")
+
+	// ErrOffsetMismatch is returned when a chunk doesn't start where the
+	// staged data ends
+	ErrOffsetMismatch = errors.New("chunk offset does not match upload offset")
+
+	// ErrTooLarge is returned when a chunk would exceed the declared size
+	ErrTooLarge = errors.New("chunk exceeds declared upload size")
+
+	// ErrChecksumMismatch is returned when data fails integrity verification
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+
+	// ErrIncomplete is returned when finishing an upload that is missing data
+	ErrIncomplete = errors.New("upload is incomplete")
+
+	// ErrExists is returned when finishing an upload whose destination was
+	// created after the upload started
+	ErrExists = errors.New("a file already exists at the upload's destination")
+)
+
+// Upload describes a staged upload
+type Upload struct {
+	ID        string    `json:"id"`
+	Owner     string    `json:"owner"`            // Who started the upload; only they may continue it
+	Path      string    `json:"path"`             // Destination relative to the root directory
+	Size      int64     `json:"size"`             // Declared total size in bytes
+	SHA256    string    `json:"sha256,omitempty"` // Expected hex digest of the whole file
+	Offset    int64     `json:"offset"`           // Bytes received so far
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Complete reports whether all declared bytes have been received
+func (u *Upload) Complete() bool {
+	return u.Offset == u.Size
+}
+
+// Manager stages uploads under ~/.inkwell/uploads. Each upload has a data
+// file and a JSON descriptor, so uploads survive restarts.
+type Manager struct {
+	mu   sync.Mutex
+	dir  string
+	scan func(path string, upload *Upload) error
+}
+
+// New creates an upload manager and removes stale uploads
+func New() (*Manager, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Join(home, inkwellDir, uploadsDir)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	m := &Manager{dir: dir}
+	m.cleanup()
+	return m, nil
+}
+
+// Create starts a new upload for owner
+func (m *Manager) Create(owner, path string, size int64, sha string) (*Upload, error) {
+	if size < 0 {
+		return nil, errors.New("size must not be negative")
+	}
+	if sha != "" {
+		if b, err := hex.DecodeString(sha); err != nil || len(b) != sha256.Size {
+			return nil, errors.New("sha256 must be a hex-encoded SHA-256 digest")
+		}
+	}
+
+	now := time.Now()
+	upload := &Upload{
+		ID:        uuid.NewString(),
+		Owner:     owner,
+		Path:      path,
+		Size:      size,
+		SHA256:    strings.ToLower(sha),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f, err := os.OpenFile(m.dataPath(upload.ID), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stage upload: %w", err)
+	}
+	f.Close()
+
+	if err := m.saveMeta(upload); err != nil {
+		os.Remove(m.dataPath(upload.ID))
+		return nil, err
+	}
+
+	return upload, nil
+}
+
+// Get returns the current state of one of owner's uploads
+func (m *Manager) Get(owner, id string) (*Upload, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.load(owner, id)
+}
+
+// Append writes a chunk starting at offset. If chunkSHA is set, the chunk
+// is verified before anything is written.
+func (m *Manager) Append(owner, id string, offset int64, chunk io.Reader, chunkSHA string) (*Upload, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	upload, err := m.load(owner, id)
+	if err != nil {
+		return nil, err
+	}
+	if offset != upload.Offset {
+		return upload, ErrOffsetMismatch
+	}
+
+	// Read one byte past the remaining size to detect oversized chunks
+	remaining := upload.Size - upload.Offset
+	data, err := io.ReadAll(io.LimitReader(chunk, remaining+1))
+	if err != nil {
+		return upload, fmt.Errorf("failed to read chunk: %w", err)
+	}
+	if int64(len(data)) > remaining {
+		return upload, ErrTooLarge
+	}
+
+	if chunkSHA != "" {
+		sum := sha256.Sum256(data)
+		if !strings.EqualFold(hex.EncodeToString(sum[:]), chunkSHA) {
+			return upload, ErrChecksumMismatch
+		}
+	}
+
+	f, err := os.OpenFile(m.dataPath(id), os.O_WRONLY, 0600)
+	if err != nil {
+		return upload, fmt.Errorf("failed to open staged upload: %w", err)
+	}
+	if _, err := f.WriteAt(data, offset); err != nil {
+		f.Close()
+		return upload, fmt.Errorf("failed to write chunk: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return upload, fmt.Errorf("failed to write chunk: %w", err)
+	}
+
+	upload.Offset += int64(len(data))
+	upload.UpdatedAt = time.Now()
+	if err := m.saveMeta(upload); err != nil {
+		return upload, err
+	}
+
+	return upload, nil
+}
+
+// Finish verifies a complete upload and moves it to dest, which must not
+// exist. The staged data is removed whether or not verification succeeds,
+// except when the upload is still incomplete or dest is taken.
+func (m *Manager) Finish(owner, id, dest string) (*Upload, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	upload, err := m.load(owner, id)
+	if err != nil {
+		return nil, err
+	}
+	if !upload.Complete() {
+		return upload, ErrIncomplete
+	}
+
+	if upload.SHA256 != "" {
+		sum, err := fileSHA256(m.dataPath(id))
+		if err != nil {
+			return upload, err
+		}
+		if sum != upload.SHA256 {
+			m.remove(id)
+			return upload, ErrChecksumMismatch
+		}
+	}
+
+	if m.scan != nil {
+		if err := m.scan(m.dataPath(id), upload); err != nil {
+			m.remove(id)
+			return upload, err
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return upload, fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := moveFile(m.dataPath(id), dest); err != nil {
+		if os.IsExist(err) {
+			return upload, ErrExists
+		}
+		return upload, fmt.Errorf("failed to move upload into place: %w", err)
+	}
+	os.Chmod(dest, 0644)
+	os.Remove(m.metaPath(id))
+
+	return upload, nil
+}
+
+// SetScanner sets a check each completed upload must pass before Finish
+// moves it into place. Uploads it fails are discarded, with its error.
+func (m *Manager) SetScanner(scan func(path string, upload *Upload) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.scan = scan
+}
+
+// Abort discards one of owner's uploads
+func (m *Manager) Abort(owner, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, err := m.load(owner, id); err != nil {
+		return err
+	}
+	m.remove(id)
+	return nil
+}
+
+// cleanup removes uploads that have not been touched recently
+func (m *Manager) cleanup() {
+	entries, err := os.ReadDir(m.dir)
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok {
+			continue
+		}
+		upload, err := m.loadMeta(id)
+		if err != nil || time.Since(upload.UpdatedAt) > staleAfter {
+			m.remove(id)
+		}
+	}
+}
+
+// remove deletes an upload's staged files
+func (m *Manager) remove(id string) {
+	os.Remove(m.dataPath(id))
+	os.Remove(m.metaPath(id))
+}
+
+func (m *Manager) dataPath(id string) string {
+	return filepath.Join(m.dir, id+".part")
+}
+
+func (m *Manager) metaPath(id string) string {
+	return filepath.Join(m.dir, id+".json")
+}
+
+// load reads an upload descriptor, as if it didn't exist for anyone but
+// its owner
+func (m *Manager) load(owner, id string) (*Upload, error) {
+	upload, err := m.loadMeta(id)
+	if err != nil {
+		return nil, err
+	}
+	if upload.Owner != owner {
+		return nil, ErrNotFound
+	}
+	return upload, nil
+}
+
+// loadMeta reads an upload descriptor
+func (m *Manager) loadMeta(id string) (*Upload, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrNotFound
+	}
+
+	data, err := os.ReadFile(m.metaPath(id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	var upload Upload
+	if err := json.Unmarshal(data, &upload); err != nil {
+		return nil, err
+	}
+	return &upload, nil
+}
+
+// saveMeta writes an upload descriptor atomically
+func (m *Manager) saveMeta(upload *Upload) error {
+	data, err := json.Marshal(upload)
+	if err != nil {
+		return err
+	}
+
+	tmp := m.metaPath(upload.ID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to save upload state: %w", err)
+	}
+	return os.Rename(tmp, m.metaPath(upload.ID))
+}
+
+// fileSHA256 returns the hex SHA-256 digest of a file
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// moveFile moves src to dest, copying when they are on different devices.
+// It never replaces dest, failing with an error os.IsExist reports if a
+// file is there.
+func moveFile(src, dest string) error {
+	err := os.Link(src, dest)
+	if err == nil {
+		return os.Remove(src)
+	}
+	if os.IsExist(err) {
+		return err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dest)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dest)
+		return err
+	}
+
+	in.Close()
+	return os.Remove(src)
+}
@@ -0,0 +1,210 @@
+package uploads
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// owner started the uploads in these tests
+const owner = "user:sam"
+
+func digest(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestChunkedUpload(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	m, err := New()
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	content := "hello, resumable world"
+	upload, err := m.Create(owner, "assets/big.bin", int64(len(content)), digest(content))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	if _, err := m.Append(owner, upload.ID, 0, strings.NewReader(content[:5]), digest(content[:5])); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+
+	// A retried chunk at a stale offset is rejected with the current offset
+	got, err := m.Append(owner, upload.ID, 0, strings.NewReader(content[:5]), "")
+	if err != ErrOffsetMismatch || got.Offset != 5 {
+		t.Errorf("Expected offset mismatch at 5, got %v at %d", err, got.Offset)
+	}
+
+	if _, err := m.Append(owner, upload.ID, 5, strings.NewReader("x"), digest("y")); err != ErrChecksumMismatch {
+		t.Errorf("Expected chunk checksum mismatch, got %v", err)
+	}
+
+	if _, err := m.Finish(owner, upload.ID, filepath.Join(t.TempDir(), "out")); err != ErrIncomplete {
+		t.Errorf("Expected ErrIncomplete, got %v", err)
+	}
+
+	if _, err := m.Append(owner, upload.ID, 5, strings.NewReader(content[5:]+"extra"), ""); err != ErrTooLarge {
+		t.Errorf("Expected ErrTooLarge, got %v", err)
+	}
+
+	// Resume from a fresh manager, as after a restart
+	m2, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, err := m2.Get(owner, upload.ID)
+	if err != nil || state.Offset != 5 {
+		t.Fatalf("Get = %+v, %v", state, err)
+	}
+	if _, err := m2.Append(owner, upload.ID, 5, strings.NewReader(content[5:]), ""); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+
+	dest := filepath.Join(t.TempDir(), "sub", "big.bin")
+	if _, err := m2.Finish(owner, upload.ID, dest); err != nil {
+		t.Fatalf("Finish failed: %v", err)
+	}
+
+	data, err := os.ReadFile(dest)
+	if err != nil || string(data) != content {
+		t.Errorf("Destination = %q, %v", data, err)
+	}
+	if _, err := m2.Get(owner, upload.ID); err != ErrNotFound {
+		t.Errorf("Finished upload should be gone, got %v", err)
+	}
+}
+
+func TestFinishChecksumMismatch(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	m, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	upload, err := m.Create(owner, "a.bin", 3, digest("abc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Append(owner, upload.ID, 0, strings.NewReader("abd"), ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Finish(owner, upload.ID, filepath.Join(t.TempDir(), "a.bin")); err != ErrChecksumMismatch {
+		t.Errorf("Expected ErrChecksumMismatch, got %v", err)
+	}
+}
+
+func TestFinishScanner(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	m, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rejected := errors.New("infected")
+	m.SetScanner(func(path string, upload *Upload) error {
+		data, _ := os.ReadFile(path)
+		if string(data) == "bad" {
+			return rejected
+		}
+		return nil
+	})
+
+	upload, err := m.Create(owner, "a.bin", 3, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Append(owner, upload.ID, 0, strings.NewReader("bad"), ""); err != nil {
+		t.Fatal(err)
+	}
+	dest := filepath.Join(t.TempDir(), "a.bin")
+	if _, err := m.Finish(owner, upload.ID, dest); err != rejected {
+		t.Errorf("Expected the scanner's error, got %v", err)
+	}
+	if _, err := os.Stat(dest); !os.IsNotExist(err) {
+		t.Error("Expected a rejected upload kept out of place")
+	}
+	if _, err := m.Get(owner, upload.ID); err != ErrNotFound {
+		t.Errorf("Expected a rejected upload discarded, got %v", err)
+	}
+}
+
+func TestCreateValidation(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	m, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Create(owner, "a.bin", 1, "not-a-digest"); err == nil {
+		t.Error("Expected error for invalid digest")
+	}
+	if _, err := m.Get(owner, "../../etc/passwd"); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound for invalid ID, got %v", err)
+	}
+}
+
+func TestUploadOwner(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	m, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	upload, err := m.Create(owner, "a.bin", 3, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other := "user:alex"
+	if _, err := m.Get(other, upload.ID); err != ErrNotFound {
+		t.Errorf("Get: expected ErrNotFound for someone else, got %v", err)
+	}
+	if _, err := m.Append(other, upload.ID, 0, strings.NewReader("abc"), ""); err != ErrNotFound {
+		t.Errorf("Append: expected ErrNotFound for someone else, got %v", err)
+	}
+	if err := m.Abort(other, upload.ID); err != ErrNotFound {
+		t.Errorf("Abort: expected ErrNotFound for someone else, got %v", err)
+	}
+	if _, err := m.Append(owner, upload.ID, 0, strings.NewReader("abc"), ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Finish(other, upload.ID, filepath.Join(t.TempDir(), "a.bin")); err != ErrNotFound {
+		t.Errorf("Finish: expected ErrNotFound for someone else, got %v", err)
+	}
+}
+
+func TestFinishKeepsExistingFile(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	m, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	upload, err := m.Create(owner, "a.bin", 3, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Append(owner, upload.ID, 0, strings.NewReader("new"), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// Someone else saved a file there while the upload ran
+	dest := filepath.Join(t.TempDir(), "a.bin")
+	if err := os.WriteFile(dest, []byte("theirs"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Finish(owner, upload.ID, dest); err != ErrExists {
+		t.Errorf("Expected ErrExists, got %v", err)
+	}
+	if data, _ := os.ReadFile(dest); string(data) != "theirs" {
+		t.Errorf("Expected the existing file kept, got %q", data)
+	}
+}
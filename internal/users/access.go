@@ -0,0 +1,169 @@
+package users
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"inkwell/internal/atomicfile"
+	"inkwell/internal/filesystem"
+)
+
+const accessFile = "access.json"
+
+// Permission is what a user may do in a folder
+type Permission string
+
+const (
+	PermissionNone  Permission = "none"  // The folder is hidden
+	PermissionRead  Permission = "read"  // Read but not change
+	PermissionWrite Permission = "write" // Read and change, as far as the role allows
+)
+
+// FolderRule sets the permission of a user, or of everyone with a role, in
+// a folder and everything under it. The rule for the deepest folder
+// applies; at the same folder a rule naming the user beats one for their
+// role.
+type FolderRule struct {
+	Folder     string     `json:"folder"`         // Relative to the vault root, slash-separated
+	User       string     `json:"user,omitempty"` // Account name
+	Role       Role       `json:"role,omitempty"`
+	Permission Permission `json:"permission"`
+}
+
+// ParsePermission validates a permission name
+func ParsePermission(name string) (Permission, error) {
+	switch p := Permission(strings.TrimSpace(name)); p {
+	case PermissionNone, PermissionRead, PermissionWrite:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown permission: %s", name)
+	}
+}
+
+// cleanFolder normalizes a rule's folder, rejecting ones outside the vault
+func cleanFolder(folder string) (string, error) {
+	clean := path.Clean(strings.Trim(filepath.ToSlash(strings.TrimSpace(folder)), "/"))
+	if clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("folder %q must be inside the vault", folder)
+	}
+	return clean, nil
+}
+
+// loadRules reads the folder rules from disk
+func (m *Manager) loadRules() error {
+	data, err := os.ReadFile(filepath.Join(m.baseDir, accessFile))
+	if err != nil {
+		return err
+	}
+
+	var rules []FolderRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rules = rules
+	return nil
+}
+
+// Rules returns the folder rules
+func (m *Manager) Rules() []FolderRule {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make([]FolderRule, len(m.rules))
+	copy(result, m.rules)
+	return result
+}
+
+// HasRules reports whether any folder rules are set
+func (m *Manager) HasRules() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.rules) > 0
+}
+
+// SetRules validates and replaces the folder rules
+func (m *Manager) SetRules(rules []FolderRule) ([]FolderRule, error) {
+	cleaned := make([]FolderRule, 0, len(rules))
+	for _, rule := range rules {
+		folder, err := cleanFolder(rule.Folder)
+		if err != nil {
+			return nil, err
+		}
+		rule.Folder = folder
+		rule.User = strings.TrimSpace(rule.User)
+		if (rule.User == "") == (rule.Role == "") {
+			return nil, fmt.Errorf("rule for %s must name either a user or a role", folder)
+		}
+		if rule.Role != "" {
+			if rule.Role, err = ParseRole(string(rule.Role)); err != nil {
+				return nil, err
+			}
+		}
+		if rule.Permission, err = ParsePermission(string(rule.Permission)); err != nil {
+			return nil, err
+		}
+		cleaned = append(cleaned, rule)
+	}
+
+	data, err := json.MarshalIndent(cleaned, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := atomicfile.WriteFile(filepath.Join(m.baseDir, accessFile), data, 0600); err != nil {
+		return nil, fmt.Errorf("failed to save rules: %w", err)
+	}
+
+	m.mu.Lock()
+	m.rules = cleaned
+	m.mu.Unlock()
+	return cleaned, nil
+}
+
+// Permission returns what user may do with a path relative to the vault
+// root. Admins may do anything, and no rule lets a viewer write.
+func (m *Manager) Permission(user *User, relativePath string) Permission {
+	if user.IsAdmin() {
+		return PermissionWrite
+	}
+	max := PermissionRead
+	if user.CanWrite() {
+		max = PermissionWrite
+	}
+
+	p := path.Clean(strings.Trim(filepath.ToSlash(relativePath), "/"))
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	best, depth, byUser := max, -1, false
+	for _, rule := range m.rules {
+		if rule.User != "" && !strings.EqualFold(rule.User, user.Name) || rule.Role != "" && rule.Role != user.Role {
+			continue
+		}
+		// Folder names are matched the way the platform's file system does,
+		// so a rule can't be sidestepped by changing case on Windows
+		if !filesystem.InDir(p, rule.Folder) {
+			continue
+		}
+		d := 0
+		if rule.Folder != "." {
+			d = strings.Count(rule.Folder, "/") + 1
+		}
+		if d < depth || d == depth && byUser && rule.User == "" {
+			continue
+		}
+		best, depth, byUser = rule.Permission, d, rule.User != ""
+	}
+
+	if best == PermissionWrite && max == PermissionRead {
+		return PermissionRead
+	}
+	return best
+}
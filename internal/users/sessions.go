@@ -0,0 +1,83 @@
+package users
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"sync"
+	"time"
+)
+
+// SessionTTL is how long a login stays valid
+const SessionTTL = 30 * 24 * time.Hour
+
+type session struct {
+	userID  string
+	expires time.Time
+}
+
+// Sessions tracks logged-in browsers. Sessions are kept in memory, so a
+// restart signs everyone out.
+type Sessions struct {
+	mu       sync.Mutex
+	sessions map[string]session
+}
+
+// NewSessions creates an empty session store
+func NewSessions() *Sessions {
+	return &Sessions{
+		sessions: make(map[string]session),
+	}
+}
+
+// Create starts a session for a user and returns its token
+func (s *Sessions) Create(userID string) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sessions[token] = session{
+		userID:  userID,
+		expires: time.Now().Add(SessionTTL),
+	}
+	return token, nil
+}
+
+// Lookup returns the user ID for a valid session token
+func (s *Sessions) Lookup(token string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess, ok := s.sessions[token]
+	if !ok {
+		return "", false
+	}
+	if time.Now().After(sess.expires) {
+		delete(s.sessions, token)
+		return "", false
+	}
+	return sess.userID, true
+}
+
+// Revoke ends a session
+func (s *Sessions) Revoke(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, token)
+}
+
+// RevokeUser ends every session belonging to a user
+func (s *Sessions) RevokeUser(userID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for token, sess := range s.sessions {
+		if sess.userID == userID {
+			delete(s.sessions, token)
+		}
+	}
+}
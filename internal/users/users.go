@@ -0,0 +1,431 @@
+// Package users manages local accounts for shared Inkwell instances
+package users
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"inkwell/internal/atomicfile"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	inkwellDir   = ".inkwell"
+	usersFile    = "users.json"
+	usersDir     = "users"
+	settingsFile = "settings.json"
+
+	minPasswordLength = 8
+)
+
+// Role controls what a user may do
+type Role string
+
+const (
+	RoleViewer Role = "viewer" // Read files and history
+	RoleEditor Role = "editor" // Edit files and run git operations
+	RoleAdmin  Role = "admin"  // Everything, including account management
+)
+
+var (
+	// ErrInvalidCredentials is returned when a name or password is wrong
+	ErrInvalidCredentials = errors.New("invalid name or password")
+
+	// ErrUserNotFound is returned for unknown user IDs
+	ErrUserNotFound = errors.New("user not found")
+
+	// ErrUserExists is returned when creating a duplicate name
+	ErrUserExists = errors.New("a user with that name already exists")
+
+	// ErrLastAdmin is returned when removing the only admin
+	ErrLastAdmin = errors.New("cannot remove the last admin")
+)
+
+// User is a local account. The password is only stored as a bcrypt hash.
+type User struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	Role         Role      `json:"role"`
+	PasswordHash string    `json:"passwordHash,omitempty"`
+	GitName      string    `json:"gitName,omitempty"`
+	GitEmail     string    `json:"gitEmail,omitempty"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// CanWrite reports whether the user may modify files and repositories
+func (u *User) CanWrite() bool {
+	return u.Role == RoleEditor || u.Role == RoleAdmin
+}
+
+// IsAdmin reports whether the user may manage accounts and keys
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
+
+// DefaultEmailDomain is the domain of the commit email made up for users
+// who haven't set one
+const DefaultEmailDomain = "inkwell.local"
+
+// GitIdentity returns the author name and email for the user's commits,
+// falling back to the account name, and to an email at domain made from
+// it, so each user's commits stay apart
+func (u *User) GitIdentity(domain string) (name, email string) {
+	name = u.GitName
+	if name == "" {
+		name = u.Name
+	}
+	email = u.GitEmail
+	if email == "" {
+		if domain == "" {
+			domain = DefaultEmailDomain
+		}
+		email = emailLocalPart(u.Name, u.ID) + "@" + domain
+	}
+	return name, email
+}
+
+// emailLocalPart turns an account name into the part of an email before
+// the @, falling back to id for names with nothing usable
+func emailLocalPart(name, id string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '.', r == '_', r == '-':
+			b.WriteRune(r)
+		case r == ' ':
+			b.WriteRune('.')
+		}
+	}
+	if local := strings.Trim(b.String(), "."); local != "" {
+		return local
+	}
+	return id
+}
+
+// Public returns a copy safe to send to clients
+func (u User) Public() User {
+	u.PasswordHash = ""
+	return u
+}
+
+// ParseRole validates a role name
+func ParseRole(name string) (Role, error) {
+	switch role := Role(strings.TrimSpace(name)); role {
+	case RoleViewer, RoleEditor, RoleAdmin:
+		return role, nil
+	default:
+		return "", fmt.Errorf("unknown role: %s", name)
+	}
+}
+
+// Manager handles account storage and per-user data
+type Manager struct {
+	mu       sync.RWMutex
+	users    []User
+	rules    []FolderRule // Folder permissions, from access.json
+	baseDir  string
+	filePath string
+}
+
+// New creates a new user manager
+func New() (*Manager, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+
+	inkwellPath := filepath.Join(home, inkwellDir)
+	if err := os.MkdirAll(inkwellPath, 0755); err != nil {
+		return nil, err
+	}
+
+	m := &Manager{
+		users:    make([]User, 0),
+		baseDir:  inkwellPath,
+		filePath: filepath.Join(inkwellPath, usersFile),
+	}
+
+	if err := m.load(); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to load users: %w", err)
+	}
+	if err := m.loadRules(); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to load folder rules: %w", err)
+	}
+
+	return m, nil
+}
+
+// load reads users from disk
+func (m *Manager) load() error {
+	data, err := os.ReadFile(m.filePath)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return json.Unmarshal(data, &m.users)
+}
+
+// save writes users to disk atomically, readable only by the owner
+func (m *Manager) save() error {
+	m.mu.RLock()
+	data, err := json.MarshalIndent(m.users, "", "  ")
+	m.mu.RUnlock()
+
+	if err != nil {
+		return err
+	}
+
+	return atomicfile.WriteFile(m.filePath, data, 0600)
+}
+
+// HasUsers reports whether any accounts exist. Without accounts Inkwell
+// runs as a single local user.
+func (m *Manager) HasUsers() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.users) > 0
+}
+
+// Create adds an account
+func (m *Manager) Create(name, password string, role Role) (User, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return User{}, errors.New("name is required")
+	}
+	if len(password) < minPasswordLength {
+		return User{}, fmt.Errorf("password must be at least %d characters", minPasswordLength)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return User{}, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	user := User{
+		ID:           uuid.NewString(),
+		Name:         name,
+		Role:         role,
+		PasswordHash: string(hash),
+		CreatedAt:    time.Now(),
+	}
+
+	m.mu.Lock()
+	for _, u := range m.users {
+		if strings.EqualFold(u.Name, name) {
+			m.mu.Unlock()
+			return User{}, ErrUserExists
+		}
+	}
+	m.users = append(m.users, user)
+	m.mu.Unlock()
+
+	if err := m.save(); err != nil {
+		m.remove(user.ID)
+		return User{}, fmt.Errorf("failed to save user: %w", err)
+	}
+
+	return user, nil
+}
+
+// Authenticate returns the user matching a name and password
+func (m *Manager) Authenticate(name, password string) (*User, error) {
+	m.mu.RLock()
+	var found *User
+	for i := range m.users {
+		if strings.EqualFold(m.users[i].Name, strings.TrimSpace(name)) {
+			u := m.users[i]
+			found = &u
+			break
+		}
+	}
+	m.mu.RUnlock()
+
+	if found == nil {
+		return nil, ErrInvalidCredentials
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(found.PasswordHash), []byte(password)); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	return found, nil
+}
+
+// Get returns a user by ID
+func (m *Manager) Get(id string) (*User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for i := range m.users {
+		if m.users[i].ID == id {
+			u := m.users[i]
+			return &u, nil
+		}
+	}
+	return nil, ErrUserNotFound
+}
+
+// ByName returns a user by account name
+func (m *Manager) ByName(name string) (*User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for i := range m.users {
+		if strings.EqualFold(m.users[i].Name, name) {
+			u := m.users[i]
+			return &u, nil
+		}
+	}
+	return nil, ErrUserNotFound
+}
+
+// List returns all users
+func (m *Manager) List() []User {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make([]User, len(m.users))
+	copy(result, m.users)
+	return result
+}
+
+// Update changes a user's profile. Empty values are left unchanged; a
+// non-empty password replaces the current one.
+func (m *Manager) Update(id string, gitName, gitEmail, password string) (User, error) {
+	var hash []byte
+	if password != "" {
+		if len(password) < minPasswordLength {
+			return User{}, fmt.Errorf("password must be at least %d characters", minPasswordLength)
+		}
+		var err error
+		hash, err = bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return User{}, fmt.Errorf("failed to hash password: %w", err)
+		}
+	}
+
+	m.mu.Lock()
+	var updated *User
+	for i := range m.users {
+		if m.users[i].ID == id {
+			updated = &m.users[i]
+			break
+		}
+	}
+	if updated == nil {
+		m.mu.Unlock()
+		return User{}, ErrUserNotFound
+	}
+
+	if gitName != "" {
+		updated.GitName = gitName
+	}
+	if gitEmail != "" {
+		updated.GitEmail = gitEmail
+	}
+	if hash != nil {
+		updated.PasswordHash = string(hash)
+	}
+	result := *updated
+	m.mu.Unlock()
+
+	if err := m.save(); err != nil {
+		return User{}, fmt.Errorf("failed to save user: %w", err)
+	}
+	return result, nil
+}
+
+// Delete removes an account and its per-user data
+func (m *Manager) Delete(id string) error {
+	m.mu.RLock()
+	admins := 0
+	var target *User
+	for i := range m.users {
+		if m.users[i].Role == RoleAdmin {
+			admins++
+		}
+		if m.users[i].ID == id {
+			target = &m.users[i]
+		}
+	}
+	lastAdmin := target != nil && target.Role == RoleAdmin && admins == 1 && len(m.users) > 1
+	m.mu.RUnlock()
+
+	if target == nil {
+		return ErrUserNotFound
+	}
+	if lastAdmin {
+		return ErrLastAdmin
+	}
+
+	m.remove(id)
+	if err := m.save(); err != nil {
+		return err
+	}
+
+	return os.RemoveAll(m.DataDir(id))
+}
+
+// remove deletes a user from memory
+func (m *Manager) remove(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, u := range m.users {
+		if u.ID == id {
+			m.users = append(m.users[:i], m.users[i+1:]...)
+			return
+		}
+	}
+}
+
+// DataDir returns the directory holding a user's recents and settings
+func (m *Manager) DataDir(id string) string {
+	return filepath.Join(m.baseDir, usersDir, id)
+}
+
+// Settings returns a user's stored preferences
+func (m *Manager) Settings(id string) (map[string]interface{}, error) {
+	settings := make(map[string]interface{})
+
+	data, err := os.ReadFile(filepath.Join(m.DataDir(id), settingsFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return settings, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// SaveSettings replaces a user's stored preferences
+func (m *Manager) SaveSettings(id string, settings map[string]interface{}) error {
+	if _, err := m.Get(id); err != nil {
+		return err
+	}
+
+	dir := m.DataDir(id)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return atomicfile.WriteFile(filepath.Join(dir, settingsFile), data, 0600)
+}
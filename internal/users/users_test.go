@@ -0,0 +1,199 @@
+package users
+
+import (
+	"testing"
+)
+
+func TestCreateAndAuthenticate(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	m, err := New()
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if m.HasUsers() {
+		t.Fatal("Expected no users")
+	}
+
+	user, err := m.Create("alex", "correct horse", RoleAdmin)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if user.PasswordHash == "correct horse" {
+		t.Error("Password stored in plaintext")
+	}
+
+	if _, err := m.Create("Alex", "another password", RoleEditor); err != ErrUserExists {
+		t.Errorf("Expected ErrUserExists, got %v", err)
+	}
+	if _, err := m.Create("sam", "short", RoleEditor); err == nil {
+		t.Error("Expected error for short password")
+	}
+
+	// Accounts survive a reload
+	reloaded, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := reloaded.Authenticate("ALEX", "correct horse")
+	if err != nil {
+		t.Fatalf("Authenticate failed: %v", err)
+	}
+	if got.ID != user.ID || !got.IsAdmin() {
+		t.Errorf("Unexpected user: %+v", got)
+	}
+	if _, err := reloaded.Authenticate("alex", "wrong password"); err != ErrInvalidCredentials {
+		t.Errorf("Expected ErrInvalidCredentials, got %v", err)
+	}
+}
+
+func TestUpdateAndSettings(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	m, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := m.Create("sam", "password123", RoleEditor)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updated, err := m.Update(user.ID, "Sam Doe", "sam@example.com", "")
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if updated.GitName != "Sam Doe" || updated.GitEmail != "sam@example.com" {
+		t.Errorf("Git identity not updated: %+v", updated)
+	}
+	if _, err := m.Authenticate("sam", "password123"); err != nil {
+		t.Error("Password should be unchanged")
+	}
+
+	if err := m.SaveSettings(user.ID, map[string]interface{}{"theme": "dark"}); err != nil {
+		t.Fatalf("SaveSettings failed: %v", err)
+	}
+	settings, err := m.Settings(user.ID)
+	if err != nil || settings["theme"] != "dark" {
+		t.Errorf("Settings = %v, %v", settings, err)
+	}
+}
+
+func TestGitIdentity(t *testing.T) {
+	tests := []struct {
+		user      User
+		domain    string
+		wantName  string
+		wantEmail string
+	}{
+		{User{Name: "sam", GitName: "Sam Doe", GitEmail: "sam@example.com"}, "", "Sam Doe", "sam@example.com"},
+		{User{Name: "sam"}, "", "sam", "sam@inkwell.local"},
+		{User{Name: "Ada Lovelace"}, "example.com", "Ada Lovelace", "ada.lovelace@example.com"},
+		{User{ID: "u1", Name: "Zoë!"}, "example.com", "Zoë!", "zo@example.com"},
+		{User{ID: "u2", Name: "李"}, "", "李", "u2@inkwell.local"},
+	}
+	for _, tt := range tests {
+		name, email := tt.user.GitIdentity(tt.domain)
+		if name != tt.wantName || email != tt.wantEmail {
+			t.Errorf("GitIdentity(%q) for %q = %q, %q; want %q, %q", tt.domain, tt.user.Name, name, email, tt.wantName, tt.wantEmail)
+		}
+	}
+}
+
+func TestDeleteLastAdmin(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	m, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin, _ := m.Create("admin", "password123", RoleAdmin)
+	editor, _ := m.Create("editor", "password123", RoleEditor)
+
+	if err := m.Delete(admin.ID); err != ErrLastAdmin {
+		t.Errorf("Expected ErrLastAdmin, got %v", err)
+	}
+	if err := m.Delete(editor.ID); err != nil {
+		t.Errorf("Delete failed: %v", err)
+	}
+	if err := m.Delete(editor.ID); err != ErrUserNotFound {
+		t.Errorf("Expected ErrUserNotFound, got %v", err)
+	}
+}
+
+func TestSessions(t *testing.T) {
+	s := NewSessions()
+
+	token, err := s.Create("u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, ok := s.Lookup(token); !ok || id != "u1" {
+		t.Errorf("Lookup = %q, %v", id, ok)
+	}
+
+	s.RevokeUser("u1")
+	if _, ok := s.Lookup(token); ok {
+		t.Error("Session should be revoked")
+	}
+}
+
+func TestPermission(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	m, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin, _ := m.Create("alex", "correct horse", RoleAdmin)
+	editor, _ := m.Create("sam", "correct horse", RoleEditor)
+	viewer, _ := m.Create("robin", "correct horse", RoleViewer)
+
+	if _, err := m.SetRules([]FolderRule{{Folder: "team", Permission: PermissionRead}}); err == nil {
+		t.Error("Expected an error for a rule naming neither user nor role")
+	}
+	if _, err := m.SetRules([]FolderRule{{Folder: "../out", Role: RoleEditor, Permission: PermissionRead}}); err == nil {
+		t.Error("Expected an error for a folder outside the vault")
+	}
+
+	_, err = m.SetRules([]FolderRule{
+		{Folder: "/team/", Role: RoleEditor, Permission: PermissionRead},
+		{Folder: "team/drafts", Role: RoleEditor, Permission: PermissionWrite},
+		{Folder: "team", User: "SAM", Permission: PermissionNone},
+		{Folder: "team/shared", Role: RoleViewer, Permission: PermissionWrite},
+		{Folder: ".", Role: RoleViewer, Permission: PermissionNone},
+		{Folder: "public", Role: RoleViewer, Permission: PermissionRead},
+	})
+	if err != nil {
+		t.Fatalf("SetRules failed: %v", err)
+	}
+
+	tests := []struct {
+		user *User
+		path string
+		want Permission
+	}{
+		{&admin, "team/plan.md", PermissionWrite},
+		{&editor, "notes/today.md", PermissionWrite},
+		{&editor, "team/plan.md", PermissionNone}, // The user rule beats the role rule
+		{&editor, "team/drafts/idea.md", PermissionWrite},
+		{&editor, "teamwork.md", PermissionWrite},
+		{&viewer, "notes/today.md", PermissionNone},
+		{&viewer, "public/index.md", PermissionRead},
+		{&viewer, "team/shared/a.md", PermissionRead}, // Viewers never write
+	}
+	for _, tt := range tests {
+		if got := m.Permission(tt.user, tt.path); got != tt.want {
+			t.Errorf("Permission(%s, %q) = %s, want %s", tt.user.Name, tt.path, got, tt.want)
+		}
+	}
+
+	// Rules survive a reload
+	reloaded, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rules := reloaded.Rules(); len(rules) != 6 || rules[0].Folder != "team" {
+		t.Errorf("Unexpected rules after reload: %+v", rules)
+	}
+}
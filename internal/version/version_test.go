@@ -0,0 +1,23 @@
+package version
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGet(t *testing.T) {
+	oldVersion, oldCommit, oldTime := Version, Commit, BuildTime
+	defer func() { Version, Commit, BuildTime = oldVersion, oldCommit, oldTime }()
+
+	Version, Commit, BuildTime = "v1.2.3", "0123456789abcdef", "2024-05-01_10:00:00"
+
+	info := Get()
+	if info.Version != "v1.2.3" || info.Commit != "0123456789ab" || info.BuildTime != "2024-05-01_10:00:00" {
+		t.Errorf("Unexpected info: %+v", info)
+	}
+
+	s := info.String()
+	if !strings.HasPrefix(s, "inkwell v1.2.3 (0123456789ab) built 2024-05-01_10:00:00, go") {
+		t.Errorf("Unexpected string: %s", s)
+	}
+}
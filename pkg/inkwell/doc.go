@@ -0,0 +1,22 @@
+// Package inkwell embeds the Inkwell markdown editor in other Go programs.
+//
+// A server is created for a directory (or a single file) and configured
+// with functional options:
+//
+//	srv, err := inkwell.New("./notes",
+//		inkwell.WithPort(8080),
+//		inkwell.WithTheme("dark"),
+//		inkwell.WithWebContent(webFS),
+//	)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	log.Fatal(srv.Start())
+//
+// Instead of calling Start, the server's routes can be mounted in an
+// existing HTTP server through Handler. Without WithWebContent only the
+// API and WebSocket endpoints are served.
+//
+// The file system and git managers are also exposed for tools that only
+// need Inkwell's file and repository handling.
+package inkwell
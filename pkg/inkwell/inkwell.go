@@ -0,0 +1,310 @@
+package inkwell
+
+import (
+	"context"
+	"io/fs"
+	"net/http"
+	"time"
+
+	"inkwell/internal/config"
+	"inkwell/internal/filesystem"
+	"inkwell/internal/git"
+	"inkwell/internal/server"
+)
+
+// Option configures a Server
+type Option func(*options)
+
+type options struct {
+	cfg *config.Config
+	web fs.FS
+}
+
+// WithPort sets the HTTP port. By default a random available port is used.
+func WithPort(port int) Option {
+	return func(o *options) {
+		o.cfg.Port = port
+	}
+}
+
+// WithTheme sets the initial theme (light/dark)
+func WithTheme(theme string) Option {
+	return func(o *options) {
+		o.cfg.Theme = theme
+	}
+}
+
+// WithMath enables or disables math rendering in HTML output
+func WithMath(enabled bool) Option {
+	return func(o *options) {
+		o.cfg.NoMath = !enabled
+	}
+}
+
+// WithRestoreSession reopens the tabs that were open when the vault was
+// last used
+func WithRestoreSession() Option {
+	return func(o *options) {
+		o.cfg.RestoreSession = true
+	}
+}
+
+// WithoutGit disables git integration
+func WithoutGit() Option {
+	return func(o *options) {
+		o.cfg.NoGit = true
+	}
+}
+
+// WithReposDir clones repositories into dir instead of ~/.inkwell/repos
+func WithReposDir(dir string) Option {
+	return func(o *options) {
+		o.cfg.ReposDir = dir
+	}
+}
+
+// WithPlantUMLServer renders PlantUML diagrams through the given server
+func WithPlantUMLServer(url string) Option {
+	return func(o *options) {
+		o.cfg.PlantUMLServer = url
+	}
+}
+
+// WithPlantUMLJar renders PlantUML diagrams with a local plantuml.jar
+func WithPlantUMLJar(path string) Option {
+	return func(o *options) {
+		o.cfg.PlantUMLJar = path
+	}
+}
+
+// WithMaxBodySize limits request bodies to n bytes
+func WithMaxBodySize(n int64) Option {
+	return func(o *options) {
+		o.cfg.MaxBodySize = n
+	}
+}
+
+// WithMaxUploadSize limits multipart uploads to n bytes
+func WithMaxUploadSize(n int64) Option {
+	return func(o *options) {
+		o.cfg.MaxUploadSize = n
+	}
+}
+
+// WithUploadScanner checks every upload with command before it is kept,
+// rejecting it when the command exits non-zero. {file} and {name} are
+// replaced with the upload's temporary path and its name.
+func WithUploadScanner(command string) Option {
+	return func(o *options) {
+		o.cfg.ScanUploads = command
+	}
+}
+
+// WithDebug serves pprof profiles at /debug/pprof/ and runtime stats at
+// /api/debug/stats
+func WithDebug() Option {
+	return func(o *options) {
+		o.cfg.Debug = true
+	}
+}
+
+// WithHistoryCacheSize keeps up to n bytes of file versions and diffs from
+// git history in memory. Zero disables the cache.
+func WithHistoryCacheSize(n int64) Option {
+	return func(o *options) {
+		o.cfg.HistoryCacheSize = n
+	}
+}
+
+// WithCommitSizeCheck sets what commits holding large files do: "warn",
+// "block" unless forced, or "off". Files over maxFile bytes, or commits
+// over maxCommit in total, are reported; zero keeps the default limit.
+func WithCommitSizeCheck(mode string, maxFile, maxCommit int64) Option {
+	return func(o *options) {
+		o.cfg.GitSizeCheck = mode
+		if maxFile > 0 {
+			o.cfg.GitMaxFileSize = maxFile
+		}
+		if maxCommit > 0 {
+			o.cfg.GitMaxCommitSize = maxCommit
+		}
+	}
+}
+
+// WithFetchInterval fetches from origin this often while no changes are
+// being made, keeping the behind count fresh. Zero disables it.
+func WithFetchInterval(d time.Duration) Option {
+	return func(o *options) {
+		o.cfg.FetchInterval = d
+	}
+}
+
+// WithGitTimeout gives up on a push, pull or fetch that takes longer than
+// d. Zero waits as long as the remote takes.
+func WithGitTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.cfg.GitTimeout = d
+	}
+}
+
+// WithEncryptedVault encrypts the contents of every file in the vault at
+// rest with a key derived from passphrase. Files still in plaintext are
+// encrypted when the server starts.
+func WithEncryptedVault(passphrase string) Option {
+	return func(o *options) {
+		o.cfg.EncryptVault = true
+		o.cfg.Passphrase = passphrase
+	}
+}
+
+// WithStorage edits a vault in remote storage, such as
+// "webdavs://user@cloud.example.com/remote.php/dav/files/user/notes" or
+// "s3://bucket/notes", instead of the directory passed to New. The vault
+// is cached under ~/.inkwell/storage and synced every interval and after
+// each change.
+func WithStorage(url string, interval time.Duration) Option {
+	return func(o *options) {
+		o.cfg.Storage = url
+		if interval > 0 {
+			o.cfg.StorageInterval = interval
+		}
+	}
+}
+
+// WithBackups zips the vault into dir every interval, keeping the newest
+// keep backups. Zero interval and keep use the defaults of a day and 7.
+func WithBackups(dir string, interval time.Duration, keep int) Option {
+	return func(o *options) {
+		o.cfg.BackupDir = dir
+		if interval > 0 {
+			o.cfg.BackupInterval = interval
+		}
+		if keep > 0 {
+			o.cfg.BackupKeep = keep
+		}
+	}
+}
+
+// WithAI drafts commit messages and summarizes notes with a language
+// model. provider is "openai" for any OpenAI-compatible API or "ollama";
+// empty baseURL and model use the provider's defaults. The OpenAI key is
+// read from INKWELL_AI_KEY or OPENAI_API_KEY.
+func WithAI(provider, baseURL, model string) Option {
+	return func(o *options) {
+		o.cfg.AIProvider = provider
+		o.cfg.AIURL = baseURL
+		o.cfg.AIModel = model
+	}
+}
+
+// WithFrameAncestors sets the sources allowed to embed the UI in an
+// iframe, such as "'self' https://portal.example.com". Framing is denied
+// by default.
+func WithFrameAncestors(sources string) Option {
+	return func(o *options) {
+		o.cfg.FrameAncestors = sources
+	}
+}
+
+// WithContentSecurityPolicy replaces the built-in Content-Security-Policy
+func WithContentSecurityPolicy(policy string) Option {
+	return func(o *options) {
+		o.cfg.ContentSecurityPolicy = policy
+	}
+}
+
+// WithWebContent serves the built web UI from fsys, which must contain it
+// under a "web" directory (as embedded by cmd/inkwell)
+func WithWebContent(fsys fs.FS) Option {
+	return func(o *options) {
+		o.web = fsys
+	}
+}
+
+// Server is an embeddable Inkwell server
+type Server struct {
+	cfg *config.Config
+	srv *server.Server
+}
+
+// New creates a server for a directory, or for the directory containing
+// path when it is a file (which is then opened initially)
+func New(path string, opts ...Option) (*Server, error) {
+	cfg, err := config.New(path)
+	if err != nil {
+		return nil, err
+	}
+
+	o := &options{cfg: cfg}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if err := cfg.AssignPort(); err != nil {
+		return nil, err
+	}
+
+	srv, err := server.New(cfg, o.web)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Server{cfg: cfg, srv: srv}, nil
+}
+
+// Start listens on the configured port and blocks until the server stops
+func (s *Server) Start() error {
+	return s.srv.Start()
+}
+
+// Shutdown gracefully shuts down the server
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.srv.Shutdown(ctx)
+}
+
+// Handler returns the server's routes for mounting in another HTTP server
+func (s *Server) Handler() http.Handler {
+	return s.srv.Handler()
+}
+
+// URL returns the address the server is reachable at once started
+func (s *Server) URL() string {
+	return s.cfg.URL()
+}
+
+// RootDir returns the directory being served
+func (s *Server) RootDir() string {
+	return s.srv.RootDir()
+}
+
+// FileSystem returns the file system for the served directory
+func (s *Server) FileSystem() *FileSystem {
+	return s.srv.FileSystem()
+}
+
+// Git returns the git manager, or nil if git is unavailable
+func (s *Server) Git() *GitManager {
+	return s.srv.Git()
+}
+
+// FileSystem provides sandboxed access to markdown files under a root directory
+type FileSystem = filesystem.FileSystem
+
+// FileNode is a file or directory in the tree returned by FileSystem.GetTree
+type FileNode = filesystem.FileNode
+
+// NewFileSystem creates a file system rooted at rootDir
+func NewFileSystem(rootDir string) *FileSystem {
+	return filesystem.New(rootDir)
+}
+
+// GitManager opens and tracks git repositories
+type GitManager = git.Manager
+
+// Repository is an open git repository
+type Repository = git.Repository
+
+// NewGitManager creates a git manager
+func NewGitManager() (*GitManager, error) {
+	return git.NewManager()
+}
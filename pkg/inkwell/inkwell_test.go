@@ -0,0 +1,111 @@
+package inkwell
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newTestServer creates a server for path with HOME pointed at a temporary
+// directory, and shuts it down when the test ends
+func newTestServer(t *testing.T, path string, opts ...Option) *Server {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+
+	srv, err := New(path, opts...)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	t.Cleanup(func() { srv.Shutdown(context.Background()) })
+	return srv
+}
+
+func TestNewWithOptions(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "readme.md"), []byte("# Hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := newTestServer(t, filepath.Join(dir, "readme.md"), WithPort(4321), WithTheme("dark"))
+
+	if srv.URL() != "http://localhost:4321?file=readme.md" {
+		t.Errorf("Unexpected URL: %s", srv.URL())
+	}
+
+	resolved, _ := filepath.EvalSymlinks(dir)
+	if got, _ := filepath.EvalSymlinks(srv.RootDir()); got != resolved {
+		t.Errorf("Expected root %s, got %s", resolved, got)
+	}
+
+	content, err := srv.FileSystem().ReadFile("readme.md")
+	if err != nil || content != "# Hello" {
+		t.Errorf("FileSystem().ReadFile = %q, %v", content, err)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	srv := newTestServer(t, t.TempDir())
+
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/api/config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected 200 from /api/config, got %d", resp.StatusCode)
+	}
+
+	// Without web content the UI is not served
+	resp, err = http.Get(ts.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 without web content, got %d", resp.StatusCode)
+	}
+}
+
+func TestWithoutGit(t *testing.T) {
+	srv := newTestServer(t, t.TempDir(), WithoutGit())
+
+	if srv.Git() != nil {
+		t.Error("Expected no git manager with WithoutGit")
+	}
+}
+
+func TestResumeLastFile(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.md"), []byte("# A"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	srv, err := New(dir, WithPort(4321))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/files?path=a.md", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200 opening a.md, got %d: %s", rec.Code, rec.Body.String())
+	}
+	srv.Shutdown(context.Background())
+
+	srv, err = New(dir, WithPort(4321))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer srv.Shutdown(context.Background())
+
+	if srv.URL() != "http://localhost:4321?file=a.md" {
+		t.Errorf("Expected the URL to resume at a.md, got %s", srv.URL())
+	}
+}